@@ -0,0 +1,298 @@
+// Package audit provides the audit log of the gateway: a stream of the security relevant events, like the
+// authentication and authorization decisions, the rejected requests, the configuration reloads and the
+// actions of the admin API, written into pluggable sinks and kept apart from the access log
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/metrics"
+)
+
+// Namespace is the key to look for the audit options in the extra config of the service
+const Namespace = "github.com/devopsfaith/krakend/audit"
+
+// DefaultBufferSize is the number of events waiting to be written when it is not configured
+const DefaultBufferSize = 1024
+
+// maxBatchSize is the max number of events written into the sinks at once
+const maxBatchSize = 100
+
+// Types of the events
+const (
+	EventAuthentication  = "authentication"
+	EventAuthorization   = "authorization"
+	EventRateLimit       = "rate_limit"
+	EventIPBlock         = "ip_block"
+	EventSchemaViolation = "schema_violation"
+	EventConfigReload    = "config_reload"
+	EventAdminAction     = "admin_action"
+)
+
+// Outcomes of the events
+const (
+	OutcomeAllowed = "allowed"
+	OutcomeDenied  = "denied"
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+var (
+	// ErrNoSinks is the error returned when the audit options do not declare any sink
+	ErrNoSinks = errors.New("audit: at least a sink is required")
+	// ErrUnknownSink is the error returned when the type of a sink is not registered
+	ErrUnknownSink = errors.New("audit: unknown sink")
+)
+
+var droppedEvents = metrics.DefaultRegistry.Counter("krakend_audit_dropped_events_total",
+	"Audit events dropped because the buffer of the audit log was full.")
+
+// Event is a security relevant event
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Outcome string    `json:"outcome"`
+	// Actor identifies the client or the operator, like the common name of a client certificate or an admin
+	// user
+	Actor    string `json:"actor,omitempty"`
+	ClientIP string `json:"client_ip,omitempty"`
+	Method   string `json:"method,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// Reason explains the decision or the failure
+	Reason    string                 `json:"reason,omitempty"`
+	TraceID   string                 `json:"trace_id,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Sink writes the events of the audit log into a destination
+type Sink interface {
+	// Write writes a batch of events
+	Write(events []Event) error
+	// Close releases the resources of the sink
+	Close() error
+}
+
+// SinkFactory creates a Sink with the options of a sink of the audit config
+type SinkFactory func(cfg map[string]interface{}) (Sink, error)
+
+var (
+	sinksMu sync.RWMutex
+	sinks   = map[string]SinkFactory{
+		FileSinkName:    NewFileSink,
+		SyslogSinkName:  NewSyslogSink,
+		WebhookSinkName: NewWebhookSink,
+	}
+)
+
+// RegisterSink registers the sink factory with the given type
+func RegisterSink(name string, f SinkFactory) error {
+	sinksMu.Lock()
+	sinks[name] = f
+	sinksMu.Unlock()
+	return nil
+}
+
+// Config defines the audit log of the service
+type Config struct {
+	// Events are the types of the recorded events. By default, all of them
+	Events []string `json:"events"`
+	// Sinks are the options of the sinks. Every one declares its 'type'
+	Sinks []map[string]interface{} `json:"sinks"`
+	// BufferSize is the number of events waiting to be written. The events recorded while it is full are
+	// dropped. By default, the DefaultBufferSize
+	BufferSize int `json:"buffer_size"`
+}
+
+// ConfigGetter parses the audit options from the extra config of the service. The second value is false if
+// they are not declared
+func ConfigGetter(extra config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, err
+	}
+	if len(cfg.Sinks) == 0 {
+		return cfg, true, ErrNoSinks
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultBufferSize
+	}
+	return cfg, true, nil
+}
+
+// Auditor writes the recorded events into its sinks in background, so the requests are not delayed by them
+type Auditor struct {
+	events map[string]bool
+	sinks  []namedSink
+	logger logging.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan Event
+	done   chan struct{}
+}
+
+type namedSink struct {
+	name string
+	Sink
+}
+
+// NewAuditor returns an Auditor writing the events into the sinks of the config. The failed writes are logged
+func NewAuditor(cfg Config, logger logging.Logger) (*Auditor, error) {
+	a := &Auditor{
+		logger: logger,
+		queue:  make(chan Event, cfg.BufferSize),
+		done:   make(chan struct{}),
+	}
+	if len(cfg.Events) > 0 {
+		a.events = make(map[string]bool, len(cfg.Events))
+		for _, e := range cfg.Events {
+			a.events[e] = true
+		}
+	}
+	for _, sc := range cfg.Sinks {
+		name, _ := sc["type"].(string)
+		sinksMu.RLock()
+		f, ok := sinks[name]
+		sinksMu.RUnlock()
+		if !ok {
+			a.closeSinks()
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSink, name)
+		}
+		s, err := f(sc)
+		if err != nil {
+			a.closeSinks()
+			return nil, err
+		}
+		a.sinks = append(a.sinks, namedSink{name, s})
+	}
+	go a.loop()
+	return a, nil
+}
+
+// Record queues the event, if its type is recorded. The events without time get the current one
+func (a *Auditor) Record(e Event) {
+	if a.events != nil && !a.events[e.Type] {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.queue <- e:
+	default:
+		droppedEvents.Inc()
+	}
+}
+
+// Close writes the queued events, waiting until the context is done, and closes the sinks
+func (a *Auditor) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return a.closeSinks()
+}
+
+func (a *Auditor) closeSinks() error {
+	var errs []error
+	for _, s := range a.sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *Auditor) loop() {
+	defer close(a.done)
+	for e := range a.queue {
+		batch := []Event{e}
+	drain:
+		for len(batch) < maxBatchSize {
+			select {
+			case e, ok := <-a.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, e)
+			default:
+				break drain
+			}
+		}
+		for _, s := range a.sinks {
+			if err := s.Write(batch); err != nil {
+				a.logger.Error("writing the audit events to", s.name+":", err.Error())
+			}
+		}
+	}
+}
+
+var (
+	currentMu sync.RWMutex
+	current   *Auditor
+)
+
+// Register enables the audit log declared in the extra config of the service, so the events recorded with
+// Record are written into its sinks. It returns the function writing the pending events and closing the
+// sinks, to call on shutdown. If the audit log is not declared, the returned function does nothing
+func Register(cfg config.ServiceConfig, logger logging.Logger) (func(context.Context) error, error) {
+	ac, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if err != nil || !ok {
+		return func(context.Context) error { return nil }, err
+	}
+	a, err := NewAuditor(ac, logger)
+	if err != nil {
+		return nil, err
+	}
+	currentMu.Lock()
+	current = a
+	currentMu.Unlock()
+	return a.Close, nil
+}
+
+// Enabled returns true if an audit log is registered, so the callers can skip building the events otherwise
+func Enabled() bool {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current != nil
+}
+
+// Record records the event into the registered audit log, if any
+func Record(e Event) {
+	currentMu.RLock()
+	a := current
+	currentMu.RUnlock()
+	if a != nil {
+		a.Record(e)
+	}
+}
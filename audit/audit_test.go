@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+	closed bool
+	err    error
+}
+
+func (s *recordingSink) Write(events []Event) error {
+	s.mu.Lock()
+	s.events = append(s.events, events...)
+	s.mu.Unlock()
+	return s.err
+}
+
+func (s *recordingSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return nil
+}
+
+func discardLogger(t *testing.T) logging.Logger {
+	logger, err := logging.NewLogger("CRITICAL", io.Discard, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger
+}
+
+func registerRecordingSink(name string) *recordingSink {
+	s := &recordingSink{}
+	RegisterSink(name, func(_ map[string]interface{}) (Sink, error) { return s, nil })
+	return s
+}
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, err := ConfigGetter(config.ExtraConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{}}); !ok || err != ErrNoSinks {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"sinks": []interface{}{map[string]interface{}{"type": "file", "path": "stdout"}},
+	}})
+	if !ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+		return
+	}
+	if cfg.BufferSize != DefaultBufferSize {
+		t.Errorf("unexpected buffer size: %d", cfg.BufferSize)
+	}
+}
+
+func TestNewAuditor_unknownSink(t *testing.T) {
+	_, err := NewAuditor(Config{Sinks: []map[string]interface{}{{"type": "unknown"}}, BufferSize: 1}, discardLogger(t))
+	if !errors.Is(err, ErrUnknownSink) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAuditor(t *testing.T) {
+	s := registerRecordingSink("recording_auditor")
+	buf := new(bytes.Buffer)
+	logger, _ := logging.NewLogger("ERROR", buf, "")
+	s.err = errors.New("write error")
+
+	a, err := NewAuditor(Config{
+		Events:     []string{EventRateLimit, EventIPBlock},
+		Sinks:      []map[string]interface{}{{"type": "recording_auditor"}},
+		BufferSize: 10,
+	}, logger)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	a.Record(Event{Type: EventRateLimit, Outcome: OutcomeDenied, Path: "/supu"})
+	a.Record(Event{Type: EventConfigReload, Outcome: OutcomeSuccess})
+	a.Record(Event{Type: EventIPBlock, Outcome: OutcomeDenied, ClientIP: "10.0.0.1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := a.Close(ctx); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+	a.Record(Event{Type: EventIPBlock, Outcome: OutcomeDenied})
+
+	if !s.closed {
+		t.Error("the sink was not closed")
+	}
+	if len(s.events) != 2 {
+		t.Errorf("unexpected events: %v", s.events)
+		return
+	}
+	if s.events[0].Type != EventRateLimit || s.events[0].Path != "/supu" || s.events[0].Time.IsZero() {
+		t.Errorf("unexpected event: %v", s.events[0])
+	}
+	if s.events[1].Type != EventIPBlock || s.events[1].ClientIP != "10.0.0.1" {
+		t.Errorf("unexpected event: %v", s.events[1])
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("writing the audit events to recording_auditor: write error")) {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+}
+
+func TestRegister(t *testing.T) {
+	closeAudit, err := Register(config.ServiceConfig{}, discardLogger(t))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if Enabled() {
+		t.Error("the audit log should not be enabled")
+	}
+	closeAudit(context.Background())
+
+	s := registerRecordingSink("recording_register")
+	closeAudit, err = Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"sinks": []interface{}{map[string]interface{}{"type": "recording_register"}},
+	}}}, discardLogger(t))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if !Enabled() {
+		t.Error("the audit log should be enabled")
+	}
+	defer func() {
+		currentMu.Lock()
+		current = nil
+		currentMu.Unlock()
+	}()
+	Record(Event{Type: EventAdminAction, Outcome: OutcomeSuccess, Actor: "admin"})
+	if err := closeAudit(context.Background()); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+	if len(s.events) != 1 || s.events[0].Actor != "admin" {
+		t.Errorf("unexpected events: %v", s.events)
+	}
+}
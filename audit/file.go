@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileSinkName is the type of the sinks writing the events into a file
+const FileSinkName = "file"
+
+// ErrNoPath is the error returned when the file sink has no path
+var ErrNoPath = errors.New("audit: the file sink has no path")
+
+// NewFileSink returns a sink appending the events as JSON lines to the file at the 'path', or to the stdout or
+// the stderr if the path is "stdout" or "stderr"
+func NewFileSink(cfg map[string]interface{}) (Sink, error) {
+	path, _ := cfg["path"].(string)
+	switch path {
+	case "":
+		return nil, ErrNoPath
+	case "stdout":
+		return NewWriterSink(os.Stdout, false), nil
+	case "stderr":
+		return NewWriterSink(os.Stderr, false), nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterSink(f, true), nil
+}
+
+// NewWriterSink returns a sink writing the events as JSON lines into the writer. The writer is closed with
+// the sink if it is an io.Closer and closeWriter is true
+func NewWriterSink(w io.Writer, closeWriter bool) Sink {
+	return &writerSink{w: w, close: closeWriter}
+}
+
+type writerSink struct {
+	mu    sync.Mutex
+	w     io.Writer
+	close bool
+}
+
+// Write implements the Sink interface
+func (s *writerSink) Write(events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enc := json.NewEncoder(s.w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements the Sink interface
+func (s *writerSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok && s.close {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewFileSink(t *testing.T) {
+	if _, err := NewFileSink(map[string]interface{}{}); err != ErrNoPath {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for i := 0; i < 2; i++ {
+		s, err := NewFileSink(map[string]interface{}{"path": path})
+		if err != nil {
+			t.Error("unexpected error:", err.Error())
+			return
+		}
+		if err := s.Write([]Event{{Time: time.Now(), Type: EventAuthentication, Outcome: OutcomeDenied, Actor: "supu"}}); err != nil {
+			t.Error("unexpected error:", err.Error())
+		}
+		if err := s.Close(); err != nil {
+			t.Error("unexpected error:", err.Error())
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	defer f.Close()
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Error("unexpected error:", err.Error())
+		}
+		if e.Type != EventAuthentication || e.Actor != "supu" {
+			t.Errorf("unexpected event: %v", e)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("the events were not appended: %d lines", lines)
+	}
+}
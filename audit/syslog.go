@@ -0,0 +1,10 @@
+package audit
+
+// SyslogSinkName is the type of the sinks sending the events to a syslog server
+const SyslogSinkName = "syslog"
+
+// Defaults of the syslog sinks
+const (
+	DefaultSyslogTag      = "krakend"
+	DefaultSyslogFacility = "auth"
+)
@@ -0,0 +1,11 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package audit
+
+import "errors"
+
+// NewSyslogSink returns an error, since syslog is not supported by this platform
+func NewSyslogSink(_ map[string]interface{}) (Sink, error) {
+	return nil, errors.New("audit: syslog is not supported by this platform")
+}
@@ -0,0 +1,88 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+// SyslogConfig defines a syslog sink
+type SyslogConfig struct {
+	// Network is the network of the syslog server: udp, tcp or unix. If empty, the local syslog server is used
+	Network string `json:"network"`
+	// Address of the syslog server
+	Address string `json:"address"`
+	// Tag of the messages. By default, DefaultSyslogTag
+	Tag string `json:"tag"`
+	// Facility of the messages, like auth or local0. By default, DefaultSyslogFacility
+	Facility string `json:"facility"`
+}
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "mail": syslog.LOG_MAIL, "daemon": syslog.LOG_DAEMON,
+	"auth": syslog.LOG_AUTH, "syslog": syslog.LOG_SYSLOG, "lpr": syslog.LOG_LPR, "news": syslog.LOG_NEWS,
+	"uucp": syslog.LOG_UUCP, "cron": syslog.LOG_CRON, "authpriv": syslog.LOG_AUTHPRIV, "ftp": syslog.LOG_FTP,
+	"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1, "local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3, "local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+}
+
+// NewSyslogSink returns a sink sending every event as a JSON message to a syslog server. The denied and the
+// failed events have the warning severity and the rest of them, the info one
+func NewSyslogSink(cfg map[string]interface{}) (Sink, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sc := SyslogConfig{}
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return nil, err
+	}
+	if sc.Tag == "" {
+		sc.Tag = DefaultSyslogTag
+	}
+	if sc.Facility == "" {
+		sc.Facility = DefaultSyslogFacility
+	}
+	facility, ok := syslogFacilities[strings.ToLower(sc.Facility)]
+	if !ok {
+		return nil, fmt.Errorf("audit: unknown syslog facility %s", sc.Facility)
+	}
+	w, err := syslog.Dial(sc.Network, sc.Address, facility|syslog.LOG_INFO, sc.Tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w}, nil
+}
+
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// Write implements the Sink interface
+func (s *syslogSink) Write(events []Event) error {
+	for _, e := range events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if e.Outcome == OutcomeDenied || e.Outcome == OutcomeFailure {
+			err = s.w.Warning(string(b))
+		} else {
+			err = s.w.Info(string(b))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements the Sink interface
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,54 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package audit
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSyslogSink(t *testing.T) {
+	if _, err := NewSyslogSink(map[string]interface{}{"network": "udp", "address": "127.0.0.1:1", "facility": "unknown"}); err == nil {
+		t.Error("expecting an error")
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	s, err := NewSyslogSink(map[string]interface{}{"network": "udp", "address": conn.LocalAddr().String()})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	defer s.Close()
+
+	if err := s.Write([]Event{
+		{Type: EventAuthentication, Outcome: OutcomeDenied, Actor: "supu"},
+		{Type: EventConfigReload, Outcome: OutcomeSuccess},
+	}); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+
+	// the priority is the facility * 8 + the severity: auth (4) with warning (4) and info (6)
+	for i, prefix := range []string{"<36>", "<38>"} {
+		buf := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %s", i, err.Error())
+			return
+		}
+		msg := string(buf[:n])
+		if !strings.HasPrefix(msg, prefix) || !strings.Contains(msg, DefaultSyslogTag) {
+			t.Errorf("#%d: unexpected message: %s", i, msg)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// WebhookSinkName is the type of the sinks posting the events to a HTTP endpoint
+const WebhookSinkName = "webhook"
+
+// DefaultWebhookTimeout is the timeout of the requests of the webhook sinks when it is not configured
+const DefaultWebhookTimeout = 5 * time.Second
+
+// ErrNoURL is the error returned when the webhook sink has no URL
+var ErrNoURL = errors.New("audit: the webhook sink has no url")
+
+// WebhookConfig defines a webhook sink
+type WebhookConfig struct {
+	// URL receiving the events
+	URL string `json:"url"`
+	// Headers are added to the requests, like the credentials of the endpoint
+	Headers map[string]string `json:"headers"`
+	// Timeout of the requests, as a duration string. By default, the DefaultWebhookTimeout
+	Timeout string `json:"timeout"`
+}
+
+// NewWebhookSink returns a sink posting every batch of events as a JSON array to the 'url'. The responses
+// without a 2xx status code are errors
+func NewWebhookSink(cfg map[string]interface{}) (Sink, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	wc := WebhookConfig{}
+	if err := json.Unmarshal(b, &wc); err != nil {
+		return nil, err
+	}
+	if wc.URL == "" {
+		return nil, ErrNoURL
+	}
+	timeout := DefaultWebhookTimeout
+	if wc.Timeout != "" {
+		if timeout, err = time.ParseDuration(wc.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	return &webhookSink{cfg: wc, client: &http.Client{Timeout: timeout}}, nil
+}
+
+type webhookSink struct {
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+// Write implements the Sink interface
+func (s *webhookSink) Write(events []Event) error {
+	b, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.cfg.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("audit: the webhook responded with %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close implements the Sink interface
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
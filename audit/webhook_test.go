@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewWebhookSink(t *testing.T) {
+	if _, err := NewWebhookSink(map[string]interface{}{}); err != ErrNoURL {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var received []Event
+	status := http.StatusAccepted
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected authorization header: %s", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type: %s", r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	s, err := NewWebhookSink(map[string]interface{}{
+		"url":     ts.URL,
+		"headers": map[string]interface{}{"Authorization": "Bearer secret"},
+		"timeout": "1s",
+	})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	defer s.Close()
+
+	events := []Event{
+		{Type: EventRateLimit, Outcome: OutcomeDenied},
+		{Type: EventIPBlock, Outcome: OutcomeDenied},
+	}
+	if err := s.Write(events); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+	if len(received) != 2 || received[1].Type != EventIPBlock {
+		t.Errorf("unexpected events: %v", received)
+	}
+
+	status = http.StatusInternalServerError
+	if err := s.Write(events); err == nil {
+		t.Error("expecting an error")
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a problem found checking a configuration, located by the file, the line (when available) and
+// the JSON pointer of the offending value
+type Diagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Pointer string `json:"pointer,omitempty"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	parts := []string{}
+	if d.File != "" {
+		location := d.File
+		if d.Line > 0 {
+			location += ":" + strconv.Itoa(d.Line)
+		}
+		parts = append(parts, location)
+	}
+	if d.Pointer != "" {
+		parts = append(parts, d.Pointer)
+	}
+	return strings.Join(append(parts, d.Message), ": ")
+}
+
+// Diagnostics is the list of problems found checking a configuration
+type Diagnostics []Diagnostic
+
+// Check parses and initializes the configuration at the path without starting anything, so the bad
+// configurations can be rejected before deploying them. It returns the initialized configuration, to be
+// checked by the next stages (see proxy.Check), and the diagnostics with the schema violations, the parsing
+// and initialization errors and the errors returned by the registered ConfigGetters of the extra_config
+// namespaces in use
+func Check(parser Parser, path string) (ServiceConfig, Diagnostics) {
+	cfg, err := parser.Parse(path)
+	if err != nil {
+		if errs, ok := err.(ValidationErrors); ok {
+			diagnostics := make(Diagnostics, len(errs))
+			for i, e := range errs {
+				diagnostics[i] = Diagnostic{File: e.File, Line: e.Line, Pointer: e.Pointer, Message: e.Message}
+			}
+			return cfg, diagnostics
+		}
+		return cfg, Diagnostics{{File: path, Message: strings.TrimSpace(err.Error())}}
+	}
+
+	diagnostics := checkExtraConfig(path, "", cfg.ExtraConfig)
+	for i, e := range cfg.Endpoints {
+		pointer := "/endpoints/" + strconv.Itoa(i)
+		diagnostics = append(diagnostics, checkExtraConfig(path, pointer, e.ExtraConfig)...)
+		for j, b := range e.Backend {
+			backendPointer := pointer + "/backend/" + strconv.Itoa(j)
+			diagnostics = append(diagnostics, checkExtraConfig(path, backendPointer, b.ExtraConfig)...)
+		}
+	}
+	return cfg, diagnostics
+}
+
+// checkExtraConfig reports the namespaces of the extra config rejected by their ConfigGetters, the ones
+// returning an error
+func checkExtraConfig(file, pointer string, extra ExtraConfig) Diagnostics {
+	diagnostics := Diagnostics{}
+	for namespace := range extra {
+		getter, ok := ConfigGetters[namespace]
+		if !ok {
+			continue
+		}
+		if err, ok := getter(extra).(error); ok {
+			diagnostics = append(diagnostics, Diagnostic{
+				File:    file,
+				Pointer: fmt.Sprintf("%s/extra_config/%s", pointer, escapePointer(namespace)),
+				Message: err.Error(),
+			})
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	ConfigGetters["supu"] = func(extra ExtraConfig) interface{} {
+		if _, ok := extra["supu"].(string); !ok {
+			return errors.New("the supu config must be a string")
+		}
+		return extra["supu"]
+	}
+	defer delete(ConfigGetters, "supu")
+
+	dir := writeFiles(t, map[string]string{
+		"ok.json": `{"version": 2, "extra_config": {"supu": "tupu"}, "endpoints": [
+			{"endpoint": "/supu", "backend": [{"host": ["http://127.0.0.1:8081"], "url_pattern": "/tupu", "extra_config": {"supu": 42}}]}
+		]}`,
+		"invalid.json": "{\n\t\"version\": 2,\n\t\"prot\": 8080\n}",
+		"version.json": `{"version": 1}`,
+	})
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ok.json")
+	cfg, diagnostics := Check(NewParser(), path)
+	expected := Diagnostics{{File: path, Pointer: "/endpoints/0/backend/0/extra_config/supu", Message: "the supu config must be a string"}}
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+	if len(cfg.Endpoints) != 1 || cfg.Endpoints[0].Method != "GET" {
+		t.Errorf("the config was not initialized: %+v", cfg)
+	}
+
+	path = filepath.Join(dir, "invalid.json")
+	_, diagnostics = Check(NewParser(), path)
+	if len(diagnostics) != 1 || diagnostics[0].String() != path+`:3: /prot: unknown key "prot", did you mean "port"?` {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+
+	path = filepath.Join(dir, "version.json")
+	_, diagnostics = Check(NewParser(), path)
+	expected = Diagnostics{{File: path, Message: "Unsupported version: 1 (want: 2)"}}
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+}
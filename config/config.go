@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +26,9 @@ const (
 
 	// ConfigVersion is the current version of the config struct
 	ConfigVersion = 2
+
+	// WildcardParam is the name of the param capturing the rest of the path of the endpoints ending with '/*'
+	WildcardParam = "path"
 )
 
 // RoutingPattern to use during route conversion. By default, use the colon router pattern
@@ -51,22 +56,105 @@ type ServiceConfig struct {
 	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
 	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
 
+	// ShutdownDelay is the time the service keeps accepting connections, while failing its readiness check,
+	// before draining on shutdown, so the load balancers stop sending it traffic
+	ShutdownDelay time.Duration `mapstructure:"shutdown_delay"`
+	// DrainTimeout is the max time to wait for the requests in flight on shutdown before canceling them
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
+	// ReusePort sets the SO_REUSEPORT option of the listeners, so a new process can bind the same port before
+	// the current one stops
+	ReusePort bool `mapstructure:"reuse_port"`
+
 	MaxIdleConnsPerHost int `mapstructure:"max_idle_connections"`
 
+	// MaxHeaderBytes is the max size of the headers of the requests. By default, the one of net/http (1MB)
+	MaxHeaderBytes int `mapstructure:"max_header_bytes"`
+	// MaxBodySize is the default max size of the bodies of the requests to the endpoints, in bytes. Zero
+	// means unlimited
+	MaxBodySize int64 `mapstructure:"max_body_size"`
+
 	// DisableStrictREST flags if the REST enforcement is disabled
 	DisableStrictREST bool `mapstructure:"disable_rest"`
 
+	// default encoding of the responses returned by the endpoints
+	OutputEncoding string `mapstructure:"output_encoding"`
+
+	// TLS settings of the server. If nil or disabled, the server accepts plaintext connections
+	TLS *TLS `mapstructure:"tls"`
+	// ProxyProtocol settings of the server. If nil or disabled, the connections do not start with a PROXY
+	// protocol header
+	ProxyProtocol *ProxyProtocol `mapstructure:"proxy_protocol"`
+
+	// Listeners are the additional ports of the service, serving the endpoints bound to them
+	Listeners []*Listener `mapstructure:"listeners"`
+
 	// run krakend in debug mode
 	Debug     bool
 	uriParser URIParser
 }
 
+// TLS defines the TLS settings of the server
+type TLS struct {
+	// IsDisabled keeps the server accepting plaintext connections
+	IsDisabled bool `mapstructure:"disabled"`
+	// Keys are the certificates of the server. They are selected by the server name requested by the
+	// clients (SNI), and the first one is used when none of them matches it
+	Keys []TLSKeyPair `mapstructure:"keys"`
+	// MinVersion is the min TLS version accepted (SSL3.0, TLS10, TLS11, TLS12 or TLS13)
+	MinVersion string `mapstructure:"min_version"`
+	// MaxVersion is the max TLS version accepted (SSL3.0, TLS10, TLS11, TLS12 or TLS13)
+	MaxVersion string `mapstructure:"max_version"`
+	// CurvePreferences are the ids of the elliptic curves of the handshakes, in order of preference
+	CurvePreferences []uint16 `mapstructure:"curve_preferences"`
+	// CipherSuites are the ids of the cipher suites enabled for TLS 1.2 and lower versions
+	CipherSuites []uint16 `mapstructure:"cipher_suites"`
+	// PreferServerCipherSuites selects the cipher suite in the order of the server instead of the client one
+	PreferServerCipherSuites bool `mapstructure:"prefer_server_cipher_suites"`
+	// ReloadInterval is the time between two checks of the files of the keys, reloading them when they change
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+	// ClientAuth is the policy for the client certificates (none, request, require, verify_if_given or
+	// require_and_verify). By default, verify_if_given when the ClientCAs are declared and none otherwise
+	ClientAuth string `mapstructure:"client_auth"`
+	// ClientCAs are the PEM encoded certificates of the authorities verifying the client certificates
+	ClientCAs []string `mapstructure:"client_ca_certs"`
+}
+
+// Listener defines an additional port of the service, serving its own group of endpoints
+type Listener struct {
+	// Name identifies the listener in the endpoints bound to it
+	Name string `mapstructure:"name"`
+	// Port to bind the listener
+	Port int `mapstructure:"port"`
+	// TLS settings of the listener. If nil or disabled, the listener accepts plaintext connections
+	TLS *TLS `mapstructure:"tls"`
+	// ProxyProtocol settings of the listener. If nil or disabled, the connections do not start with a PROXY
+	// protocol header
+	ProxyProtocol *ProxyProtocol `mapstructure:"proxy_protocol"`
+}
+
+// ProxyProtocol defines the load balancers sending a PROXY protocol (v1 or v2) header at the start of their
+// connections, declaring the address of the client
+type ProxyProtocol struct {
+	// IsDisabled ignores the PROXY protocol headers
+	IsDisabled bool `mapstructure:"disabled"`
+	// AllowedIPs are the IPs and CIDRs of the load balancers. The connections from the rest of the peers are
+	// accepted untouched
+	AllowedIPs []string `mapstructure:"allowed_ips"`
+}
+
+// TLSKeyPair is a certificate and its private key, PEM encoded
+type TLSKeyPair struct {
+	PublicKey  string `mapstructure:"public_key"`
+	PrivateKey string `mapstructure:"private_key"`
+}
+
 // EndpointConfig defines the configuration of a single endpoint to be exposed
 // by the krakend service
 type EndpointConfig struct {
 	// url pattern to be registered and exposed to the world
 	Endpoint string `mapstructure:"endpoint"`
-	// HTTP method of the endpoint (GET, POST, PUT, etc)
+	// HTTP method of the endpoint (GET, POST, PUT, etc). The endpoints with the AnyMethod are expanded into an
+	// endpoint for every one of the AnyMethods by Init
 	Method string `mapstructure:"method"`
 	// set of definitions of the backends to be linked to this endpoint
 	Backend []*Backend `mapstructure:"backend"`
@@ -82,6 +170,17 @@ type EndpointConfig struct {
 	ExtraConfig ExtraConfig `mapstructure:"extra_config"`
 	// HeadersToPass defines the list of headers to pass to the backends
 	HeadersToPass []string `mapstructure:"headers_to_pass"`
+	// OutputEncoding defines the encoding of the response returned to the client
+	OutputEncoding string `mapstructure:"output_encoding"`
+	// MaxBodySize is the max size of the body of the requests, in bytes. The larger ones are rejected with a
+	// 413 status code
+	MaxBodySize int64 `mapstructure:"max_body_size"`
+	// Wildcard is the name of the param capturing the rest of the path of the catch-all endpoints, declared
+	// with a trailing '/*' or '/{name...}'. It is empty for the rest of the endpoints
+	Wildcard string
+	// Listener is the name of the listener serving the endpoint. If empty, the endpoint is served on the
+	// port of the service
+	Listener string `mapstructure:"listener"`
 }
 
 // Backend defines how krakend should connect to the backend service (the API resource to consume)
@@ -135,16 +234,35 @@ func DefaultConfigGetter(extra ExtraConfig) interface{} { return extra }
 
 const defaultNamespace = "github.com/devopsfaith/krakend/config"
 
+// proxyNamespace is the key of the proxy extra config, declared by the proxy package
+const proxyNamespace = "github.com/devopsfaith/krakend/proxy"
+
+// cookiesNamespace is the key of the cookie policy of the endpoints, declared by the router package
+const cookiesNamespace = "github.com/devopsfaith/krakend/router/cookies"
+
+// queryStringKey is the option of the proxy extra config of the backends manipulating their query strings
+const queryStringKey = "querystring"
+
+// QueryStringWildcard in the querystring_params of an endpoint passes all the query string params of the
+// requests to the proxy layer. Init adds it to the endpoints with backends forwarding all of them
+const QueryStringWildcard = "*"
+
 // ConfigGetters map than match namespaces and ConfigGetter so the components knows which type to expect returned by the
 // ConfigGetter ie: if we look for the defaultNamespace in the map, we will get the DefaultConfigGetter implementation
 // which will return a ExtraConfig when called
 var ConfigGetters = map[string]ConfigGetter{defaultNamespace: DefaultConfigGetter}
 
 var (
-	simpleURLKeysPattern = regexp.MustCompile(`\{([a-zA-Z\-_0-9]+)\}`)
-	debugPattern         = "^[^/]|/__debug(/.*)?$"
-	errInvalidHost       = errors.New("invalid host")
-	defaultPort          = 8080
+	simpleURLKeysPattern     = regexp.MustCompile(`\{([a-zA-Z\-_0-9]+)\}`)
+	sequentialURLKeysPattern = regexp.MustCompile(`\{([a-zA-Z\-_0-9\.]+)\}`)
+	sequentialParamsPattern  = regexp.MustCompile(`^resp([0-9]+)_.+$`)
+	wildcardPattern          = regexp.MustCompile(`/(\*|\{([a-zA-Z\-_0-9]+)\.\.\.\})$`)
+	debugPattern             = "^[^/]|/__debug(/.*)?$"
+	errInvalidHost           = errors.New("invalid host")
+	errNoTLSKeys             = errors.New("the TLS settings require at least a key pair")
+	errNoListenerName        = errors.New("the listeners require a name")
+	errNoProxyProtocolIPs    = errors.New("the PROXY protocol settings require at least an allowed IP")
+	defaultPort              = 8080
 )
 
 // Init initializes the configuration struct and its defined endpoints and backends.
@@ -164,33 +282,72 @@ func (s *ServiceConfig) Init() error {
 	if s.Timeout == 0 {
 		s.Timeout = DefaultTimeout
 	}
+	if s.TLS != nil && !s.TLS.IsDisabled && len(s.TLS.Keys) == 0 {
+		return errNoTLSKeys
+	}
+	if err := s.ProxyProtocol.init(); err != nil {
+		return err
+	}
+	if err := s.initListeners(); err != nil {
+		return err
+	}
 
 	s.Host = s.uriParser.CleanHosts(s.Host)
 
+	endpoints := make([]*EndpointConfig, 0, len(s.Endpoints))
+	for _, e := range s.Endpoints {
+		endpoints = append(endpoints, expandMethods(e, []string{e.Method})...)
+	}
+	s.Endpoints = endpoints
+
 	for i, e := range s.Endpoints {
 		e.Endpoint = s.uriParser.CleanPath(e.Endpoint)
 
 		if err := e.validate(); err != nil {
 			return err
 		}
+		if e.Listener != "" && s.listener(e.Listener) == nil {
+			return fmt.Errorf("the endpoint [%s] is bound to the undefined listener [%s]", e.Endpoint, e.Listener)
+		}
+
+		if matches := wildcardPattern.FindStringSubmatch(e.Endpoint); matches != nil {
+			e.Wildcard = matches[2]
+			if e.Wildcard == "" {
+				e.Wildcard = WildcardParam
+			}
+			e.Endpoint = strings.TrimSuffix(e.Endpoint, matches[0]) + "/{" + e.Wildcard + "}"
+		}
 
 		inputParams := s.extractPlaceHoldersFromURLTemplate(e.Endpoint, s.paramExtractionPattern())
 		inputSet := map[string]interface{}{}
 		for ip := range inputParams {
 			inputSet[inputParams[ip]] = nil
 		}
+		// the cookies mapped into params are available in the url patterns of the backends
+		for _, param := range e.cookieParams() {
+			inputSet[param] = nil
+		}
 
 		e.Endpoint = s.uriParser.GetEndpointPath(e.Endpoint, inputParams)
+		if e.Wildcard != "" {
+			e.Endpoint = wildcardEndpointPath(e.Endpoint, e.Wildcard)
+		}
 
 		s.initEndpointDefaults(i)
 
+		if e.OutputEncoding == encoding.NOOP && len(e.Backend) > 1 {
+			return fmt.Errorf("ERROR: the no-op endpoint [%s] must have a single backend! Ignoring\n", e.Endpoint)
+		}
+
 		for j, b := range e.Backend {
 
-			s.initBackendDefaults(i, j)
+			if err := s.initBackendDefaults(i, j); err != nil {
+				return err
+			}
 
 			b.Method = strings.ToTitle(b.Method)
 
-			if err := s.initBackendURLMappings(i, j, inputSet); err != nil {
+			if err := s.initBackendURLMappings(i, j, s.initBackendQueryString(i, j, inputSet)); err != nil {
 				return err
 			}
 		}
@@ -199,6 +356,88 @@ func (s *ServiceConfig) Init() error {
 	return nil
 }
 
+// initListeners checks the names, the ports, the TLS and the PROXY protocol settings of the listeners
+func (s *ServiceConfig) initListeners() error {
+	ports := map[int]bool{s.Port: true}
+	names := map[string]bool{}
+	for _, l := range s.Listeners {
+		if l.Name == "" {
+			return errNoListenerName
+		}
+		if names[l.Name] {
+			return fmt.Errorf("the listener [%s] is defined twice", l.Name)
+		}
+		names[l.Name] = true
+		if l.Port == 0 || ports[l.Port] {
+			return fmt.Errorf("the listener [%s] requires a port not used by the service: %d", l.Name, l.Port)
+		}
+		ports[l.Port] = true
+		if l.TLS != nil && !l.TLS.IsDisabled && len(l.TLS.Keys) == 0 {
+			return errNoTLSKeys
+		}
+		if err := l.ProxyProtocol.init(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// init checks the allowed IPs of the enabled PROXY protocol settings
+func (p *ProxyProtocol) init() error {
+	if p == nil || p.IsDisabled {
+		return nil
+	}
+	if len(p.AllowedIPs) == 0 {
+		return errNoProxyProtocolIPs
+	}
+	for _, ip := range p.AllowedIPs {
+		if net.ParseIP(ip) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(ip); err != nil {
+			return fmt.Errorf("invalid IP of the PROXY protocol settings: %s", ip)
+		}
+	}
+	return nil
+}
+
+// listener returns the listener with the name or nil if it is not defined
+func (s *ServiceConfig) listener(name string) *Listener {
+	for _, l := range s.Listeners {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// ListenerConfig returns a copy of the service config with the port, the TLS and the PROXY protocol settings
+// and the endpoints of the listener with the name. The empty name is the port of the service, serving the endpoints not bound to
+// any listener
+func (s ServiceConfig) ListenerConfig(name string) ServiceConfig {
+	if l := s.listener(name); l != nil {
+		s.Port = l.Port
+		s.TLS = l.TLS
+		s.ProxyProtocol = l.ProxyProtocol
+	}
+	endpoints := []*EndpointConfig{}
+	for _, e := range s.Endpoints {
+		if e.Listener == name {
+			endpoints = append(endpoints, e)
+		}
+	}
+	s.Endpoints = endpoints
+	return s
+}
+
+// wildcardEndpointPath replaces the last param of the route pattern with the catch-all param of the router
+func wildcardEndpointPath(path, name string) string {
+	if RoutingPattern == ColonRouterPatternBuilder {
+		return strings.TrimSuffix(path, ":"+name) + "*" + name
+	}
+	return strings.TrimSuffix(path, "{"+name+"}") + "{" + name + ":.*}"
+}
+
 func (s *ServiceConfig) paramExtractionPattern() *regexp.Regexp {
 	if s.DisableStrictREST {
 		return simpleURLKeysPattern
@@ -231,9 +470,24 @@ func (s *ServiceConfig) initEndpointDefaults(e int) {
 	if endpoint.ConcurrentCalls == 0 {
 		endpoint.ConcurrentCalls = 1
 	}
+	if endpoint.OutputEncoding == "" {
+		endpoint.OutputEncoding = s.OutputEncoding
+	}
+	if endpoint.MaxBodySize == 0 {
+		endpoint.MaxBodySize = s.MaxBodySize
+	}
+	// the static responses are rendered untouched, with their status code and headers
+	if endpoint.hasStaticResponse() {
+		endpoint.OutputEncoding = encoding.NOOP
+	}
+	if endpoint.OutputEncoding == encoding.NOOP {
+		for _, b := range endpoint.Backend {
+			b.Encoding = encoding.NOOP
+		}
+	}
 }
 
-func (s *ServiceConfig) initBackendDefaults(e, b int) {
+func (s *ServiceConfig) initBackendDefaults(e, b int) error {
 	endpoint := s.Endpoints[e]
 	backend := endpoint.Backend[b]
 	if len(backend.Host) == 0 {
@@ -246,18 +500,44 @@ func (s *ServiceConfig) initBackendDefaults(e, b int) {
 	}
 	backend.Timeout = endpoint.Timeout
 	backend.ConcurrentCalls = endpoint.ConcurrentCalls
-	backend.Decoder = encoding.Get(strings.ToLower(backend.Encoding))(backend.IsCollection)
+	// with the 'auto_group' flag, every backend without group gets its own one, so the merged
+	// responses can not collide
+	if backend.Group == "" && len(endpoint.Backend) > 1 && endpoint.isProxyFlagEnabled("auto_group") {
+		backend.Group = fmt.Sprintf("backend%d", b)
+	}
+	decoderFactory, err := encoding.GetConfigured(strings.ToLower(backend.Encoding), backend.ExtraConfig)
+	if err != nil {
+		return fmt.Errorf("invalid encoding for the backend %s: %s", backend.URLPattern, err.Error())
+	}
+	backend.Decoder = decoderFactory(backend.IsCollection)
+	return nil
 }
 
 func (s *ServiceConfig) initBackendURLMappings(e, b int, inputParams map[string]interface{}) error {
-	backend := s.Endpoints[e].Backend[b]
+	endpoint := s.Endpoints[e]
+	backend := endpoint.Backend[b]
 
 	backend.URLPattern = s.uriParser.CleanPath(backend.URLPattern)
+	if _, ok := inputParams["method"]; !ok {
+		backend.URLPattern = strings.Replace(backend.URLPattern, methodPlaceholder, strings.ToLower(endpoint.Method), -1)
+	}
+	if endpoint.Wildcard != "" && !strings.Contains(backend.URLPattern, "{"+endpoint.Wildcard+"}") {
+		// the rest of the path is forwarded to the backend
+		backend.URLPattern = strings.TrimSuffix(backend.URLPattern, "/") + "/{" + endpoint.Wildcard + "}"
+	}
 
-	outputParams := s.extractPlaceHoldersFromURLTemplate(backend.URLPattern, simpleURLKeysPattern)
+	pattern := simpleURLKeysPattern
+	isSequential := endpoint.IsSequential()
+	if isSequential {
+		pattern = sequentialURLKeysPattern
+	}
+	outputParams := s.extractPlaceHoldersFromURLTemplate(backend.URLPattern, pattern)
 
 	outputSet := map[string]interface{}{}
 	for op := range outputParams {
+		if isSequential && sequentialParamsPattern.MatchString(outputParams[op]) {
+			continue
+		}
 		outputSet[outputParams[op]] = nil
 	}
 
@@ -268,16 +548,111 @@ func (s *ServiceConfig) initBackendURLMappings(e, b int, inputParams map[string]
 	tmp := backend.URLPattern
 	backend.URLKeys = make([]string, len(outputParams))
 	for o := range outputParams {
-		if _, ok := inputParams[outputParams[o]]; !ok {
+		key := strings.Title(outputParams[o])
+		if matches := sequentialParamsPattern.FindStringSubmatch(outputParams[o]); isSequential && matches != nil {
+			if idx, _ := strconv.Atoi(matches[1]); idx >= b {
+				return fmt.Errorf("Invalid sequential param [%s]! The backend %d can only use the responses of the previous ones\n", outputParams[o], b)
+			}
+			// the dotted path to the value must be preserved
+			key = "R" + outputParams[o][1:]
+		} else if _, ok := inputParams[outputParams[o]]; !ok {
 			return fmt.Errorf("Undefined output param [%s]! input: %v, output: %v\n", outputParams[o], inputParams, outputParams)
 		}
-		tmp = strings.Replace(tmp, "{"+outputParams[o]+"}", "{{."+strings.Title(outputParams[o])+"}}", -1)
-		backend.URLKeys = append(backend.URLKeys, strings.Title(outputParams[o]))
+		tmp = strings.Replace(tmp, "{"+outputParams[o]+"}", "{{."+key+"}}", -1)
+		backend.URLKeys = append(backend.URLKeys, key)
 	}
 	backend.URLPattern = tmp
 	return nil
 }
 
+// initBackendQueryString adds the query string params required by the querystring options of the backend
+// to the endpoint. It returns the input params of the URL pattern of the backend, including the query
+// string params mapped into its path
+func (s *ServiceConfig) initBackendQueryString(e, b int, inputParams map[string]interface{}) map[string]interface{} {
+	endpoint := s.Endpoints[e]
+	extra, ok := endpoint.Backend[b].ExtraConfig[proxyNamespace].(map[string]interface{})
+	if !ok {
+		return inputParams
+	}
+	cfg, ok := extra[queryStringKey].(map[string]interface{})
+	if !ok {
+		return inputParams
+	}
+	if forwardAll, ok := cfg["forward_all"].(bool); ok && forwardAll {
+		endpoint.addQueryString(QueryStringWildcard)
+	}
+	toPath, ok := cfg["to_path"].([]interface{})
+	if !ok || len(toPath) == 0 {
+		return inputParams
+	}
+	params := make(map[string]interface{}, len(inputParams)+len(toPath))
+	for k, v := range inputParams {
+		params[k] = v
+	}
+	for _, v := range toPath {
+		if name, ok := v.(string); ok && name != "" {
+			endpoint.addQueryString(name)
+			params[name] = nil
+		}
+	}
+	return params
+}
+
+// cookieParams returns the params the cookie policy of the endpoint maps the cookies into
+func (e *EndpointConfig) cookieParams() []string {
+	cfg, ok := e.ExtraConfig[cookiesNamespace].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	toParams, ok := cfg["to_params"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	params := make([]string, 0, len(toParams))
+	for _, v := range toParams {
+		if param, ok := v.(string); ok && param != "" {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+func (e *EndpointConfig) addQueryString(param string) {
+	for _, q := range e.QueryString {
+		if q == param {
+			return
+		}
+	}
+	e.QueryString = append(e.QueryString, param)
+}
+
+// IsSequential checks if the backends of the endpoint must be called in sequence, so every backend
+// can use the responses of the previous ones in its URL pattern. It is enabled with the 'sequential'
+// flag of the proxy extra config
+func (e *EndpointConfig) IsSequential() bool {
+	return e.isProxyFlagEnabled("sequential")
+}
+
+// hasStaticResponse checks if the endpoint returns a static response or a redirect instead of calling its
+// backends. It is declared with the 'static_response' option of the proxy extra config
+func (e *EndpointConfig) hasStaticResponse() bool {
+	extra, ok := e.ExtraConfig[proxyNamespace].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = extra["static_response"]
+	return ok
+}
+
+func (e *EndpointConfig) isProxyFlagEnabled(name string) bool {
+	extra, ok := e.ExtraConfig[proxyNamespace].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	flag, ok := extra[name].(bool)
+	return ok && flag
+}
+
 func (e *EndpointConfig) validate() error {
 	matched, err := regexp.MatchString(debugPattern, e.Endpoint)
 	if err != nil {
@@ -288,7 +663,7 @@ func (e *EndpointConfig) validate() error {
 		return fmt.Errorf("ERROR: the endpoint url path [%s] is not a valid one!!! Ignoring\n", e.Endpoint)
 	}
 
-	if len(e.Backend) == 0 {
+	if len(e.Backend) == 0 && !e.hasStaticResponse() {
 		return fmt.Errorf("WARNING: the [%s] endpoint has 0 backends defined! Ignoring\n", e.Endpoint)
 	}
 	return nil
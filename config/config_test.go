@@ -1,9 +1,13 @@
 package config
 
 import (
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/devopsfaith/krakend/encoding"
 )
 
 func TestConfig_rejectInvalidVersion(t *testing.T) {
@@ -105,6 +109,58 @@ func TestConfig_initBackendURLMappings_undefinedOutput(t *testing.T) {
 	}
 }
 
+func TestConfig_initBackendURLMappings_sequential(t *testing.T) {
+	first := Backend{URLPattern: "/user/{id}"}
+	second := Backend{URLPattern: "/posts/{resp0_user.id}?page={page}"}
+	endpoint := EndpointConfig{
+		Backend:     []*Backend{&first, &second},
+		ExtraConfig: ExtraConfig{proxyNamespace: map[string]interface{}{"sequential": true}},
+	}
+	subject := ServiceConfig{Endpoints: []*EndpointConfig{&endpoint}, uriParser: NewURIParser()}
+
+	inputSet := map[string]interface{}{
+		"id":   nil,
+		"page": nil,
+	}
+
+	if err := subject.initBackendURLMappings(0, 0, inputSet); err != nil {
+		t.Error(err)
+	}
+	if err := subject.initBackendURLMappings(0, 1, inputSet); err != nil {
+		t.Error(err)
+	}
+	if expected := "/posts/{{.Resp0_user.id}}?page={{.Page}}"; second.URLPattern != expected {
+		t.Errorf("want: %s, have: %s\n", expected, second.URLPattern)
+	}
+}
+
+func TestConfig_initBackendURLMappings_sequentialForwardReference(t *testing.T) {
+	first := Backend{URLPattern: "/user/{resp1_id}"}
+	second := Backend{URLPattern: "/posts"}
+	endpoint := EndpointConfig{
+		Backend:     []*Backend{&first, &second},
+		ExtraConfig: ExtraConfig{proxyNamespace: map[string]interface{}{"sequential": true}},
+	}
+	subject := ServiceConfig{Endpoints: []*EndpointConfig{&endpoint}, uriParser: NewURIParser()}
+
+	err := subject.initBackendURLMappings(0, 0, map[string]interface{}{})
+	if err == nil || strings.Index(err.Error(), "Invalid sequential param [resp1_id]") != 0 {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestConfig_initBackendURLMappings_notSequential(t *testing.T) {
+	first := Backend{URLPattern: "/user"}
+	second := Backend{URLPattern: "/posts/{resp0_id}"}
+	endpoint := EndpointConfig{Backend: []*Backend{&first, &second}}
+	subject := ServiceConfig{Endpoints: []*EndpointConfig{&endpoint}, uriParser: NewURIParser()}
+
+	err := subject.initBackendURLMappings(0, 1, map[string]interface{}{"id": nil})
+	if err == nil || strings.Index(err.Error(), "Undefined output param [resp0_id]") != 0 {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestConfig_init(t *testing.T) {
 	supuBackend := Backend{
 		URLPattern: "/__debug/supu",
@@ -189,6 +245,217 @@ func TestConfig_init(t *testing.T) {
 	}
 }
 
+func TestConfig_initListeners(t *testing.T) {
+	newEndpoint := func(path, listener string) *EndpointConfig {
+		return &EndpointConfig{
+			Endpoint: path,
+			Listener: listener,
+			Backend:  []*Backend{{URLPattern: "/", Host: []string{"http://127.0.0.1:8081"}}},
+		}
+	}
+	subject := ServiceConfig{
+		Version:   ConfigVersion,
+		Port:      8080,
+		Listeners: []*Listener{{Name: "admin", Port: 8090, TLS: &TLS{IsDisabled: true}}},
+		Endpoints: []*EndpointConfig{newEndpoint("/public", ""), newEndpoint("/admin", "admin")},
+	}
+	if err := subject.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := subject.ListenerConfig("admin")
+	if admin.Port != 8090 || admin.TLS == nil || !admin.TLS.IsDisabled {
+		t.Errorf("unexpected listener settings: %d %+v", admin.Port, admin.TLS)
+	}
+	if len(admin.Endpoints) != 1 || admin.Endpoints[0].Endpoint != "/admin" {
+		t.Errorf("unexpected endpoints of the listener: %v", admin.Endpoints)
+	}
+	public := subject.ListenerConfig("")
+	if public.Port != 8080 || public.TLS != nil {
+		t.Errorf("unexpected service settings: %d %+v", public.Port, public.TLS)
+	}
+	if len(public.Endpoints) != 1 || public.Endpoints[0].Endpoint != "/public" {
+		t.Errorf("unexpected endpoints of the service: %v", public.Endpoints)
+	}
+	if len(subject.Endpoints) != 2 {
+		t.Error("the endpoints of the service config must not change")
+	}
+}
+
+func TestConfig_initListenersKO(t *testing.T) {
+	for i, tc := range []struct {
+		listeners []*Listener
+		listener  string
+	}{
+		{listeners: []*Listener{{Port: 8090}}},
+		{listeners: []*Listener{{Name: "admin", Port: 8090}, {Name: "admin", Port: 8091}}},
+		{listeners: []*Listener{{Name: "admin", Port: 8080}}},
+		{listeners: []*Listener{{Name: "admin"}}},
+		{listeners: []*Listener{{Name: "admin", Port: 8090, TLS: &TLS{}}}},
+		{listeners: []*Listener{{Name: "admin", Port: 8090, ProxyProtocol: &ProxyProtocol{}}}},
+		{listeners: []*Listener{{Name: "admin", Port: 8090, ProxyProtocol: &ProxyProtocol{AllowedIPs: []string{"10.0.0.0/33"}}}}},
+		{listeners: []*Listener{{Name: "admin", Port: 8090}}, listener: "metrics"},
+	} {
+		subject := ServiceConfig{
+			Version:   ConfigVersion,
+			Port:      8080,
+			Listeners: tc.listeners,
+			Endpoints: []*EndpointConfig{{
+				Endpoint: "/supu",
+				Listener: tc.listener,
+				Backend:  []*Backend{{URLPattern: "/", Host: []string{"http://127.0.0.1:8081"}}},
+			}},
+		}
+		if err := subject.Init(); err == nil {
+			t.Errorf("%d: error expected", i)
+		}
+	}
+}
+
+func TestConfig_initWildcard(t *testing.T) {
+	defer func(p int) { RoutingPattern = p }(RoutingPattern)
+
+	for _, tc := range []struct {
+		routingPattern int
+		endpoint       string
+		urlPattern     string
+		expectedPath   string
+		expectedName   string
+		expectedURL    string
+	}{
+		{ColonRouterPatternBuilder, "/users/*", "/api/", "/users/*path", "path", "/api/{{.Path}}"},
+		{ColonRouterPatternBuilder, "/legacy/{id}/{rest...}", "/v1/{id}", "/legacy/:id/*rest", "rest", "/v1/{{.Id}}/{{.Rest}}"},
+		{ColonRouterPatternBuilder, "/legacy/{rest...}", "/v1/{rest}/raw", "/legacy/*rest", "rest", "/v1/{{.Rest}}/raw"},
+		{BracketsRouterPatternBuilder, "/users/*", "/api", "/users/{path:.*}", "path", "/api/{{.Path}}"},
+	} {
+		RoutingPattern = tc.routingPattern
+		backend := &Backend{URLPattern: tc.urlPattern}
+		endpoint := &EndpointConfig{Endpoint: tc.endpoint, Backend: []*Backend{backend}}
+		subject := ServiceConfig{
+			Version:   ConfigVersion,
+			Host:      []string{"http://127.0.0.1:8080"},
+			Endpoints: []*EndpointConfig{endpoint},
+		}
+		if err := subject.Init(); err != nil {
+			t.Error("Error at the configuration init:", err.Error())
+			continue
+		}
+		if endpoint.Endpoint != tc.expectedPath || endpoint.Wildcard != tc.expectedName || backend.URLPattern != tc.expectedURL {
+			t.Errorf("%s: unexpected result: %s %s %s", tc.endpoint, endpoint.Endpoint, endpoint.Wildcard, backend.URLPattern)
+		}
+	}
+}
+
+func TestConfig_initQueryString(t *testing.T) {
+	forwardAll := &Backend{
+		URLPattern:  "/all",
+		ExtraConfig: ExtraConfig{proxyNamespace: map[string]interface{}{queryStringKey: map[string]interface{}{"forward_all": true}}},
+	}
+	toPath := &Backend{
+		URLPattern:  "/users/{id}/page/{page}",
+		ExtraConfig: ExtraConfig{proxyNamespace: map[string]interface{}{queryStringKey: map[string]interface{}{"to_path": []interface{}{"page"}}}},
+	}
+	endpoint := &EndpointConfig{Endpoint: "/users/{id}", QueryString: []string{"q"}, Backend: []*Backend{forwardAll, toPath}}
+	subject := ServiceConfig{
+		Version:   ConfigVersion,
+		Host:      []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{endpoint},
+	}
+	if err := subject.Init(); err != nil {
+		t.Fatal("Error at the configuration init:", err.Error())
+	}
+	if !reflect.DeepEqual(endpoint.QueryString, []string{"q", QueryStringWildcard, "page"}) {
+		t.Errorf("unexpected query string params: %v", endpoint.QueryString)
+	}
+	if toPath.URLPattern != "/users/{{.Id}}/page/{{.Page}}" {
+		t.Errorf("unexpected url pattern: %s", toPath.URLPattern)
+	}
+
+	undefined := &Backend{URLPattern: "/users/{page}"}
+	subject = ServiceConfig{
+		Version:   ConfigVersion,
+		Host:      []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{{Endpoint: "/users", Backend: []*Backend{undefined}}},
+	}
+	if err := subject.Init(); err == nil {
+		t.Error("expecting an error with a param not mapped from the query string")
+	}
+}
+
+func TestConfig_initCookieParams(t *testing.T) {
+	backend := &Backend{URLPattern: "/tenants/{tenant}/users/{id}"}
+	subject := ServiceConfig{
+		Version: ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{{
+			Endpoint:    "/users/{id}",
+			Backend:     []*Backend{backend},
+			ExtraConfig: ExtraConfig{cookiesNamespace: map[string]interface{}{"to_params": map[string]interface{}{"tenant_id": "tenant"}}},
+		}},
+	}
+	if err := subject.Init(); err != nil {
+		t.Fatal("Error at the configuration init:", err.Error())
+	}
+	if backend.URLPattern != "/tenants/{{.Tenant}}/users/{{.Id}}" {
+		t.Errorf("unexpected url pattern: %s", backend.URLPattern)
+	}
+}
+
+func TestConfig_initStaticResponse(t *testing.T) {
+	endpoint := &EndpointConfig{
+		Endpoint:    "/old",
+		ExtraConfig: ExtraConfig{proxyNamespace: map[string]interface{}{"static_response": map[string]interface{}{"redirect": "/new"}}},
+	}
+	subject := ServiceConfig{
+		Version:   ConfigVersion,
+		Host:      []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{endpoint},
+	}
+	if err := subject.Init(); err != nil {
+		t.Fatal("Error at the configuration init:", err.Error())
+	}
+	if endpoint.OutputEncoding != encoding.NOOP {
+		t.Errorf("unexpected output encoding: %s", endpoint.OutputEncoding)
+	}
+
+	subject.Endpoints = []*EndpointConfig{{Endpoint: "/old"}}
+	if err := subject.Init(); err == nil {
+		t.Error("the endpoints without backends nor static responses must be rejected")
+	}
+}
+
+func TestConfig_initAutoGroup(t *testing.T) {
+	grouped := &Backend{URLPattern: "/a", Group: "custom"}
+	first := &Backend{URLPattern: "/b"}
+	second := &Backend{URLPattern: "/c"}
+	subject := ServiceConfig{
+		Version: ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{
+			{
+				Endpoint:    "/supu",
+				Backend:     []*Backend{grouped, first, second},
+				ExtraConfig: ExtraConfig{proxyNamespace: map[string]interface{}{"auto_group": true}},
+			},
+		},
+	}
+
+	if err := subject.Init(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if grouped.Group != "custom" {
+		t.Errorf("unexpected group: %s", grouped.Group)
+	}
+	if first.Group != "backend1" {
+		t.Errorf("unexpected group: %s", first.Group)
+	}
+	if second.Group != "backend2" {
+		t.Errorf("unexpected group: %s", second.Group)
+	}
+}
+
 func TestConfig_initKONoBackends(t *testing.T) {
 	subject := ServiceConfig{
 		Version: ConfigVersion,
@@ -208,6 +475,53 @@ func TestConfig_initKONoBackends(t *testing.T) {
 	}
 }
 
+func TestConfig_initKOInvalidEncoding(t *testing.T) {
+	encoding.RegisterConfigurable("failing", func(_ map[string]interface{}) (encoding.DecoderFactory, error) {
+		return nil, errors.New("invalid config")
+	})
+	subject := ServiceConfig{
+		Version: ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{
+			{
+				Endpoint: "/supu",
+				Backend:  []*Backend{{URLPattern: "/tupu", Encoding: "failing"}},
+			},
+		},
+	}
+
+	if err := subject.Init(); err == nil || err.Error() != "invalid encoding for the backend /tupu: invalid config" {
+		t.Error("Expecting an error at the configuration init!", err)
+	}
+}
+
+func TestConfig_initNoOp(t *testing.T) {
+	backend := Backend{URLPattern: "/tupu", Encoding: "json"}
+	subject := ServiceConfig{
+		Version: ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{
+			{
+				Endpoint:       "/supu",
+				OutputEncoding: encoding.NOOP,
+				Backend:        []*Backend{&backend},
+			},
+		},
+	}
+
+	if err := subject.Init(); err != nil {
+		t.Error("Error at the configuration init:", err.Error())
+	}
+	if backend.Encoding != encoding.NOOP {
+		t.Error("Unexpected encoding for the backend of a no-op endpoint:", backend.Encoding)
+	}
+
+	subject.Endpoints[0].Backend = append(subject.Endpoints[0].Backend, &Backend{URLPattern: "/foo"})
+	if err := subject.Init(); err == nil || !strings.HasPrefix(err.Error(), "ERROR: the no-op endpoint [/supu] must have a single backend!") {
+		t.Error("Expecting an error at the configuration init!", err)
+	}
+}
+
 func TestConfig_initKOInvalidHost(t *testing.T) {
 	defer func() {
 		if r := recover(); r == nil {
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// JSONFormat is the name of the default format of the configuration files
+const JSONFormat = "json"
+
+// Decoder decodes the content of a configuration file in some format into generic values (maps with string
+// keys, slices, strings, numbers and booleans), so it can be processed as the JSON files
+type Decoder func(data []byte) (interface{}, error)
+
+// ErrUnknownFormat is the error returned when there is no decoder registered for the format
+var ErrUnknownFormat = errors.New("unknown format of the configuration")
+
+var (
+	decoders   = map[string]Decoder{}
+	decodersMu sync.RWMutex
+)
+
+// RegisterDecoder registers the decoder for the configuration files with the format, that is also the extension
+// of the files
+func RegisterDecoder(format string, d Decoder) {
+	decodersMu.Lock()
+	decoders[format] = d
+	decodersMu.Unlock()
+}
+
+func decoderFor(format string) (Decoder, bool) {
+	decodersMu.RLock()
+	d, ok := decoders[format]
+	decodersMu.RUnlock()
+	return d, ok
+}
+
+// formatOf returns the format of the file with the extension of a registered decoder and JSON for the rest
+func formatOf(location string) string {
+	ext := strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(stripFragment(location), TemplateExtension)), ".")
+	if _, ok := decoderFor(ext); ok {
+		return ext
+	}
+	return JSONFormat
+}
+
+// decode decodes the data with the format into generic values
+func decode(format string, data []byte) (interface{}, error) {
+	var v interface{}
+	if format == JSONFormat {
+		err := decodeJSON(data, &v)
+		return v, err
+	}
+	d, ok := decoderFor(format)
+	if !ok {
+		return nil, ErrUnknownFormat
+	}
+	return d(data)
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// lineDecoder decodes 'key=value' lines, with the dotted keys as nested objects
+func lineDecoder(data []byte) (interface{}, error) {
+	result := map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("invalid line: " + line)
+		}
+		keys := strings.Split(parts[0], ".")
+		node := result
+		for _, k := range keys[:len(keys)-1] {
+			child, ok := node[k].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[k] = child
+			}
+			node = child
+		}
+		if n, err := strconv.Atoi(parts[1]); err == nil {
+			node[keys[len(keys)-1]] = n
+			continue
+		}
+		node[keys[len(keys)-1]] = parts[1]
+	}
+	return result, nil
+}
+
+func TestNewParser_decoder(t *testing.T) {
+	RegisterDecoder("lines", lineDecoder)
+	dir, err := ioutil.TempDir("", "krakend_decoder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"krakend.lines":   "version=2\nextra_config.supu=${KRAKEND_TEST_DECODER:tupu}\nextra_config.backend.$ref=backend.json",
+		"backend.json":    `{"url_pattern": "/supu"}`,
+		"krakend.unknown": `{"version": 2, "extra_config": {"supu": "json"}}`,
+		"conf.d/a.lines":  "extra_config.a=supu",
+		"conf.d/b.json":   `{"version": 2, "extra_config": {"b": "2"}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := NewParser().Parse(filepath.Join(dir, "krakend.lines"))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if cfg.ExtraConfig["supu"] != "tupu" {
+		t.Errorf("unexpected extra config: %v", cfg.ExtraConfig)
+	}
+	if backend, ok := cfg.ExtraConfig["backend"].(map[string]interface{}); !ok || backend["url_pattern"] != "/supu" {
+		t.Errorf("unexpected extra config: %v", cfg.ExtraConfig)
+	}
+
+	// the files with unknown extensions are JSON
+	cfg, err = NewParser().Parse(filepath.Join(dir, "krakend.unknown"))
+	if err != nil || cfg.ExtraConfig["supu"] != "json" {
+		t.Errorf("unexpected result: %v %v", cfg.ExtraConfig, err)
+	}
+
+	// the explicit format ignores the extension
+	if _, err := NewParserWithFormat("lines").Parse(filepath.Join(dir, "krakend.unknown")); err == nil {
+		t.Error("error expected")
+	}
+	if _, err := NewParserWithFormat("unregistered").Parse(filepath.Join(dir, "krakend.unknown")); err == nil ||
+		!strings.Contains(err.Error(), ErrUnknownFormat.Error()) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg, err = NewParser().Parse(filepath.Join(dir, "conf.d"))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if cfg.ExtraConfig["a"] != "supu" || cfg.ExtraConfig["b"] != "2" {
+		t.Errorf("unexpected extra config: %v", cfg.ExtraConfig)
+	}
+}
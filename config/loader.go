@@ -0,0 +1,155 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Loader fetches the content of the configuration from the location
+type Loader func(location string) ([]byte, error)
+
+var (
+	// RemoteClient is the http client used by the loaders of the remote configurations
+	RemoteClient = &http.Client{Timeout: 10 * time.Second}
+
+	// ErrConfigNotFound is the error returned when the remote source does not have the configuration
+	ErrConfigNotFound = errors.New("the configuration was not found in the remote source")
+
+	loaders = map[string]Loader{
+		"http":   loadHTTP,
+		"https":  loadHTTP,
+		"consul": loadConsul,
+		"etcd":   loadEtcd,
+		"s3":     loadS3,
+	}
+	loadersMu sync.RWMutex
+)
+
+// RegisterLoader registers the loader for the locations with the scheme, so the parsers can read the
+// configurations from other sources
+func RegisterLoader(scheme string, l Loader) {
+	loadersMu.Lock()
+	loaders[scheme] = l
+	loadersMu.Unlock()
+}
+
+// Load returns the content of the configuration at the location. The locations with the scheme of
+// a registered loader, like http(s)://host/path, s3://bucket/key, consul://[agent]/key or etcd://[endpoint]/key,
+// are fetched with it and the rest of them are read from the local filesystem
+func Load(location string) ([]byte, error) {
+	if l, ok := loaderFor(location); ok {
+		return l(location)
+	}
+	return ioutil.ReadFile(location)
+}
+
+func loaderFor(location string) (Loader, bool) {
+	i := strings.Index(location, "://")
+	if i < 1 {
+		return nil, false
+	}
+	loadersMu.RLock()
+	l, ok := loaders[location[:i]]
+	loadersMu.RUnlock()
+	return l, ok
+}
+
+func loadHTTP(location string) ([]byte, error) {
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+	return doRemote(req)
+}
+
+// loadConsul reads the key from the Consul KV store. The token of the ACLs is taken from the 'token' param
+// of the location or from the CONSUL_HTTP_TOKEN env var
+func loadConsul(location string) ([]byte, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	agent := u.Host
+	if agent == "" {
+		agent = "127.0.0.1:8500"
+	}
+	query := url.Values{}
+	query.Set("raw", "")
+	if dc := u.Query().Get("dc"); dc != "" {
+		query.Set("dc", dc)
+	}
+	req, err := http.NewRequest("GET", "http://"+agent+"/v1/kv/"+strings.TrimPrefix(u.Path, "/")+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	token := u.Query().Get("token")
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	if token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	return doRemote(req)
+}
+
+// loadEtcd reads the key from etcd with the JSON gateway of its v3 API
+func loadEtcd(location string) ([]byte, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := u.Host
+	if endpoint == "" {
+		endpoint = "127.0.0.1:2379"
+	}
+	body, err := json.Marshal(map[string][]byte{"key": []byte(u.Path)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", "http://"+endpoint+"/v3/kv/range", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	data, err := doRemote(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := struct {
+		Kvs []struct {
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}{}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrConfigNotFound
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+func doRemote(req *http.Request) ([]byte, error) {
+	resp, err := RemoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ioutil.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ErrConfigNotFound
+	default:
+		return nil, fmt.Errorf("unexpected status code %d fetching the configuration from %s", resp.StatusCode, req.URL.Host)
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const remoteConfig = `{"version": 2, "port": 8080, "endpoints": [{"endpoint": "/supu", "backend": [{"host": ["http://127.0.0.1:8081"], "url_pattern": "/tupu"}]}]}`
+
+func TestLoad_http(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/krakend.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, remoteConfig)
+	}))
+	defer server.Close()
+
+	data, err := Load(server.URL + "/krakend.json")
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if string(data) != remoteConfig {
+		t.Errorf("unexpected content: %s", data)
+	}
+
+	if _, err := Load(server.URL + "/unknown.json"); err != ErrConfigNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoad_consul(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/gateways/krakend.json" || r.URL.Query().Get("dc") != "eu" {
+			t.Errorf("unexpected request: %s", r.URL.String())
+		}
+		if _, ok := r.URL.Query()["raw"]; !ok {
+			t.Error("the raw value was not requested")
+		}
+		if r.Header.Get("X-Consul-Token") != "secret" {
+			t.Error("the token was not sent")
+		}
+		fmt.Fprint(w, remoteConfig)
+	}))
+	defer server.Close()
+
+	data, err := Load("consul://" + strings.TrimPrefix(server.URL, "http://") + "/gateways/krakend.json?dc=eu&token=secret")
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if string(data) != remoteConfig {
+		t.Errorf("unexpected content: %s", data)
+	}
+}
+
+func TestLoad_etcd(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/v3/kv/range" || req["key"] != base64.StdEncoding.EncodeToString([]byte("/gateways/krakend.json")) {
+			fmt.Fprint(w, `{"header":{}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"header":{},"kvs":[{"value":"%s"}]}`, base64.StdEncoding.EncodeToString([]byte(remoteConfig)))
+	}))
+	defer server.Close()
+
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+	data, err := Load("etcd://" + endpoint + "/gateways/krakend.json")
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if string(data) != remoteConfig {
+		t.Errorf("unexpected content: %s", data)
+	}
+
+	if _, err := Load("etcd://" + endpoint + "/unknown"); err != ErrConfigNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRegisterLoader(t *testing.T) {
+	RegisterLoader("supu", func(location string) ([]byte, error) {
+		return []byte(location), nil
+	})
+	data, err := Load("supu://tupu")
+	if err != nil || string(data) != "supu://tupu" {
+		t.Errorf("unexpected result: %s %v", data, err)
+	}
+
+	// the locations without a registered scheme are local files
+	if _, err := Load("unknown://tupu"); !os.IsNotExist(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewParserWithCache(t *testing.T) {
+	cache, err := ioutil.TempFile("", "krakend_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Close()
+	defer os.Remove(cache.Name())
+
+	available := true
+	RegisterLoader("cached", func(_ string) ([]byte, error) {
+		if !available {
+			return nil, ErrConfigNotFound
+		}
+		return []byte(remoteConfig), nil
+	})
+	parser := NewParserWithCache(cache.Name())
+
+	cfg, err := parser.Parse("cached://krakend.json")
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if cfg.Port != 8080 || len(cfg.Endpoints) != 1 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	// the last valid configuration is used when the remote source is not available
+	available = false
+	cfg, err = parser.Parse("cached://krakend.json")
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if cfg.Port != 8080 || len(cfg.Endpoints) != 1 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	if _, err := NewParser().Parse("cached://krakend.json"); err == nil {
+		t.Error("error expected")
+	}
+}
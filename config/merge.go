@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const endpointsKey = "endpoints"
+
+// mergeDir renders and merges the configuration files of the directory (JSON or any registered format), in
+// the order of their names. The objects are merged recursively and the endpoints of all the files are added
+// to the service. The same key with different values in two files and the endpoints declared twice are
+// errors. The subdirectories are not merged, so they can keep the files included with $ref
+func mergeDir(dir string) ([]byte, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		if e.IsDir() || !isConfigFile(e.Name()) {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	merged := map[string]interface{}{}
+	sources := map[string]string{}
+	endpoints := map[string]string{}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = render(path, data); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		if err := Validate(path, data); err != nil {
+			if errs, ok := err.(ValidationErrors); ok {
+				return nil, errs
+			}
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		v, err := decode(JSONFormat, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		cfg, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: the configuration must be an object", name)
+		}
+
+		if v, ok := cfg[endpointsKey]; ok {
+			delete(cfg, endpointsKey)
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: the endpoints must be a list", name)
+			}
+			for _, e := range list {
+				key := endpointKey(e)
+				if previous, ok := endpoints[key]; ok {
+					return nil, fmt.Errorf("the endpoint %s is declared in %s and %s", key, previous, name)
+				}
+				endpoints[key] = name
+			}
+			current, _ := merged[endpointsKey].([]interface{})
+			merged[endpointsKey] = append(current, list...)
+		}
+		if err := mergeObjects(merged, cfg, "", name, sources); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// mergeObjects adds the keys of the src to the dst, recording the file declaring every value
+func mergeObjects(dst, src map[string]interface{}, prefix, file string, sources map[string]string) error {
+	for k, v := range src {
+		path := prefix + "/" + k
+		current, ok := dst[k]
+		if !ok {
+			dst[k] = v
+			sources[path] = file
+			continue
+		}
+		currentObject, isObject := current.(map[string]interface{})
+		object, ok := v.(map[string]interface{})
+		if isObject && ok {
+			if err := mergeObjects(currentObject, object, path, file, sources); err != nil {
+				return err
+			}
+			continue
+		}
+		if !reflect.DeepEqual(current, v) {
+			return fmt.Errorf("conflicting values for %s in %s and %s", path, sourceOf(path, sources), file)
+		}
+	}
+	return nil
+}
+
+// sourceOf returns the file declaring the value or the object containing it
+func sourceOf(path string, sources map[string]string) string {
+	for ; path != ""; path = path[:strings.LastIndex(path, "/")] {
+		if file, ok := sources[path]; ok {
+			return file
+		}
+	}
+	return ""
+}
+
+func endpointKey(e interface{}) string {
+	endpoint, _ := e.(map[string]interface{})
+	method, _ := endpoint["method"].(string)
+	if method == "" {
+		method = "GET"
+	}
+	path, _ := endpoint["endpoint"].(string)
+	return strings.ToUpper(method) + " " + path
+}
+
+// isConfigFile returns true for the JSON files and the files with the extension of a registered decoder
+func isConfigFile(name string) bool {
+	name = strings.TrimSuffix(name, TemplateExtension)
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	if ext == JSONFormat {
+		return true
+	}
+	_, ok := decoderFor(ext)
+	return ok
+}
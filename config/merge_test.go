@@ -0,0 +1,82 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "krakend_merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestNewParser_dir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"00_service.json": `{"version": 2, "port": 8080, "timeout": "3s", "extra_config": {"supu": {"a": 1}}}`,
+		"10_users.json": `{"extra_config": {"supu": {"b": 2}}, "endpoints": [
+			{"endpoint": "/users", "backend": [{"$ref": "backends/users.json"}]}
+		]}`,
+		"20_orders.json": `{"port": 8080, "endpoints": [
+			{"endpoint": "/orders", "backend": [{"host": ["http://127.0.0.1:8082"], "url_pattern": "/orders"}]},
+			{"endpoint": "/users", "method": "POST", "backend": [{"$ref": "backends/users.json"}]}
+		]}`,
+		"backends/users.json": `{"host": ["http://127.0.0.1:8081"], "url_pattern": "/users"}`,
+		"README.md":           "the files without the json extension are ignored",
+	})
+	defer os.RemoveAll(dir)
+
+	cfg, err := NewParser().Parse(dir)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if cfg.Port != 8080 || len(cfg.Endpoints) != 3 {
+		t.Errorf("unexpected config: %+v", cfg)
+		return
+	}
+	for i, expected := range []string{"GET /users", "GET /orders", "POST /users"} {
+		if e := cfg.Endpoints[i]; e.Method+" "+e.Endpoint != expected {
+			t.Errorf("unexpected endpoint #%d: %s %s", i, e.Method, e.Endpoint)
+		}
+	}
+	if supu, ok := cfg.ExtraConfig["supu"].(map[string]interface{}); !ok || len(supu) != 2 {
+		t.Errorf("unexpected extra config: %v", cfg.ExtraConfig)
+	}
+}
+
+func TestMergeDir_conflicts(t *testing.T) {
+	for expected, files := range map[string]map[string]string{
+		"conflicting values for /extra_config/supu/a in a.json and b.json": {
+			"a.json": `{"extra_config": {"supu": {"a": 1}}}`,
+			"b.json": `{"extra_config": {"supu": {"a": 2}}}`,
+		},
+		"conflicting values for /port in a.json and b.json": {
+			"a.json": `{"port": 8080}`,
+			"b.json": `{"port": 8081}`,
+		},
+		"the endpoint GET /supu is declared in a.json and b.json": {
+			"a.json": `{"endpoints": [{"endpoint": "/supu"}]}`,
+			"b.json": `{"endpoints": [{"endpoint": "/supu", "method": "get"}]}`,
+		},
+	} {
+		dir := writeFiles(t, files)
+		_, err := mergeDir(dir)
+		os.RemoveAll(dir)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("unexpected error: %v, expected: %s", err, expected)
+		}
+	}
+}
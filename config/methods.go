@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// AnyMethod is the method of the endpoints accepting all the AnyMethods
+const AnyMethod = "ANY"
+
+// AnyMethods is the list of methods accepted by the endpoints with the AnyMethod
+var AnyMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// methodPlaceholder is replaced with the method of the endpoint in the URL patterns of the backends, unless the
+// endpoint has a param with the same name
+const methodPlaceholder = "{method}"
+
+// expandMethods returns a copy of the endpoint for every method, so every one is initialized and registered as
+// an independent endpoint. The AnyMethod is replaced by the AnyMethods. The endpoints with a single method are
+// returned untouched
+func expandMethods(e *EndpointConfig, methods []string) []*EndpointConfig {
+	expanded := []string{}
+	seen := map[string]bool{}
+	for _, m := range methods {
+		m = strings.ToUpper(m)
+		list := []string{m}
+		if m == AnyMethod {
+			list = AnyMethods
+		}
+		for _, method := range list {
+			if !seen[method] {
+				seen[method] = true
+				expanded = append(expanded, method)
+			}
+		}
+	}
+	if len(expanded) <= 1 {
+		if len(expanded) == 1 {
+			e.Method = expanded[0]
+		}
+		return []*EndpointConfig{e}
+	}
+
+	endpoints := make([]*EndpointConfig, len(expanded))
+	for i, method := range expanded {
+		endpoint := *e
+		endpoint.Method = method
+		endpoint.Backend = make([]*Backend, len(e.Backend))
+		for j, b := range e.Backend {
+			backend := *b
+			endpoint.Backend[j] = &backend
+		}
+		endpoints[i] = &endpoint
+	}
+	return endpoints
+}
+
+// endpointMethods is the method of a parseable endpoint, declared as a single one or as a list
+type endpointMethods []string
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (m *endpointMethods) UnmarshalJSON(b []byte) error {
+	var method string
+	if err := json.Unmarshal(b, &method); err == nil {
+		*m = endpointMethods{method}
+		return nil
+	}
+	var methods []string
+	if err := json.Unmarshal(b, &methods); err != nil {
+		return err
+	}
+	*m = endpointMethods(methods)
+	return nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandMethods(t *testing.T) {
+	backend := &Backend{URLPattern: "/supu"}
+	e := &EndpointConfig{Endpoint: "/supu", Backend: []*Backend{backend}}
+
+	if endpoints := expandMethods(e, []string{"post"}); len(endpoints) != 1 || endpoints[0] != e || e.Method != "POST" {
+		t.Errorf("unexpected endpoints: %v", endpoints)
+	}
+
+	endpoints := expandMethods(e, []string{"GET", "put", "ANY"})
+	expected := []string{"GET", "PUT", "POST", "PATCH", "DELETE"}
+	if len(endpoints) != len(expected) {
+		t.Errorf("unexpected endpoints: %v", endpoints)
+		return
+	}
+	for i, endpoint := range endpoints {
+		if endpoint.Method != expected[i] || endpoint.Endpoint != "/supu" {
+			t.Errorf("unexpected endpoint #%d: %+v", i, endpoint)
+		}
+		if endpoint.Backend[0] == backend || endpoint.Backend[0].URLPattern != "/supu" {
+			t.Errorf("the backends of the endpoint #%d are not copied", i)
+		}
+	}
+}
+
+func TestNewParser_methods(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"krakend.json": `{"version": 2, "host": ["http://127.0.0.1:8080"], "endpoints": [
+			{"endpoint": "/supu", "method": ["GET", "POST"], "backend": [{"url_pattern": "/tupu/{method}"}]},
+			{"endpoint": "/any", "method": "ANY", "backend": [{"url_pattern": "/any"}]},
+			{"endpoint": "/param/{method}", "method": "PUT", "backend": [{"url_pattern": "/param/{method}"}]}
+		]}`,
+		"invalid.json": `{"version": 2, "endpoints": [{"endpoint": "/supu", "method": 42}]}`,
+	})
+	defer os.RemoveAll(dir)
+
+	cfg, err := NewParser().Parse(filepath.Join(dir, "krakend.json"))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if len(cfg.Endpoints) != 8 {
+		t.Errorf("unexpected number of endpoints: %d", len(cfg.Endpoints))
+		return
+	}
+	for i, expected := range []struct{ method, urlPattern string }{
+		{"GET", "/tupu/get"},
+		{"POST", "/tupu/post"},
+	} {
+		e := cfg.Endpoints[i]
+		if e.Method != expected.method || e.Backend[0].Method != expected.method || e.Backend[0].URLPattern != expected.urlPattern {
+			t.Errorf("unexpected endpoint #%d: %s %s", i, e.Method, e.Backend[0].URLPattern)
+		}
+	}
+	if e := cfg.Endpoints[6]; e.Endpoint != "/any" || e.Method != "DELETE" {
+		t.Errorf("unexpected endpoint: %s %s", e.Method, e.Endpoint)
+	}
+	// the params of the endpoint are not replaced
+	if e := cfg.Endpoints[7]; e.Backend[0].URLPattern != "/param/{{.Method}}" {
+		t.Errorf("unexpected url pattern: %s", e.Backend[0].URLPattern)
+	}
+
+	if _, err := NewParser().Parse(filepath.Join(dir, "invalid.json")); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestConfig_initAnyMethod(t *testing.T) {
+	subject := ServiceConfig{
+		Version: ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*EndpointConfig{
+			{Endpoint: "/supu", Method: "any", Backend: []*Backend{{URLPattern: "/{method}"}}},
+		},
+	}
+	if err := subject.Init(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if len(subject.Endpoints) != len(AnyMethods) {
+		t.Errorf("unexpected number of endpoints: %d", len(subject.Endpoints))
+		return
+	}
+	if e := subject.Endpoints[1]; e.Method != "POST" || e.Backend[0].URLPattern != "/post" {
+		t.Errorf("unexpected endpoint: %s %s", e.Method, e.Backend[0].URLPattern)
+	}
+}
@@ -0,0 +1,189 @@
+package openapi
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// Version is the version of the OpenAPI specification of the exported documents
+const Version = "3.0.0"
+
+// Document is an OpenAPI 3 document describing the endpoints of a service
+type Document struct {
+	OpenAPI string                          `json:"openapi"`
+	Info    Info                            `json:"info"`
+	Servers []Server                        `json:"servers,omitempty"`
+	Paths   map[string]map[string]Operation `json:"paths"`
+}
+
+// Info is the metadata of the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server is the url where the API is served
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Operation describes an endpoint
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter describes a param of the endpoint
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// Response describes the response of an endpoint
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes the content of a response
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema is the shape of a value
+type Schema struct {
+	Type       string             `json:"type,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+}
+
+// MediaTypes maps the output encodings with the media types of the responses
+var MediaTypes = map[string][]string{
+	"json":      {"application/json"},
+	"xml":       {"application/xml"},
+	"yaml":      {"application/x-yaml"},
+	"msgpack":   {"application/msgpack"},
+	"cbor":      {"application/cbor"},
+	"ndjson":    {"application/x-ndjson"},
+	"csv":       {"text/csv"},
+	"rss":       {"application/rss+xml"},
+	"sse":       {"text/event-stream"},
+	"no-op":     {"*/*"},
+	"negotiate": {"application/json", "application/xml", "application/x-yaml", "application/msgpack", "application/cbor", "application/x-ndjson"},
+}
+
+var (
+	colonParamPattern = regexp.MustCompile(`/:([a-zA-Z\-_0-9\.]+)`)
+	paramPattern      = regexp.MustCompile(`\{([a-zA-Z\-_0-9\.]+)\}`)
+)
+
+// Export returns the OpenAPI document describing the endpoints of the initialized configuration: their
+// methods, path, query and header params, the media types of their output encodings and the shape of the
+// aggregated responses, as far as the groups, the collections and the whitelists of the backends tell
+func Export(cfg config.ServiceConfig, info Info, servers ...string) Document {
+	if info.Title == "" {
+		info.Title = "KrakenD"
+	}
+	if info.Version == "" {
+		info.Version = "1.0"
+	}
+	doc := Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]Operation{},
+	}
+	for _, s := range servers {
+		doc.Servers = append(doc.Servers, Server{URL: s})
+	}
+
+	for _, e := range cfg.Endpoints {
+		path := e.Endpoint
+		if e.Wildcard != "" {
+			path = path[:strings.LastIndex(path, "/")] + "/{" + e.Wildcard + "}"
+		}
+		path = colonParamPattern.ReplaceAllString(path, "/{$1}")
+		method := strings.ToLower(e.Method)
+		if method == "" {
+			method = "get"
+		}
+		op := Operation{
+			OperationID: method + strings.Replace(strings.Replace(strings.Replace(path, "/", "_", -1), "{", "", -1), "}", "", -1),
+			Responses:   map[string]Response{"200": response(e, cfg.OutputEncoding)},
+		}
+		for _, m := range paramPattern.FindAllStringSubmatch(path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		for _, q := range e.QueryString {
+			op.Parameters = append(op.Parameters, Parameter{Name: q, In: "query", Schema: &Schema{Type: "string"}})
+		}
+		for _, h := range e.HeadersToPass {
+			op.Parameters = append(op.Parameters, Parameter{Name: h, In: "header", Schema: &Schema{Type: "string"}})
+		}
+
+		if _, ok := doc.Paths[path]; !ok {
+			doc.Paths[path] = map[string]Operation{}
+		}
+		doc.Paths[path][method] = op
+	}
+	return doc
+}
+
+func response(e *config.EndpointConfig, defaultEncoding string) Response {
+	outputEncoding := e.OutputEncoding
+	if outputEncoding == "" {
+		outputEncoding = defaultEncoding
+	}
+	if outputEncoding == "" {
+		outputEncoding = "json"
+	}
+	r := Response{Description: "the aggregated response of the backends", Content: map[string]MediaType{}}
+	mediaTypes, ok := MediaTypes[outputEncoding]
+	if !ok {
+		return r
+	}
+	var schema *Schema
+	if outputEncoding != "no-op" {
+		schema = responseSchema(e.Backend)
+	}
+	for _, mediaType := range mediaTypes {
+		r.Content[mediaType] = MediaType{Schema: schema}
+	}
+	return r
+}
+
+// responseSchema merges the shapes of the responses of the backends. The backends without whitelist add
+// no known properties
+func responseSchema(backends []*config.Backend) *Schema {
+	root := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, b := range backends {
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for _, field := range b.Whitelist {
+			name := strings.Split(field, ".")[0]
+			if renamed, ok := b.Mapping[name]; ok {
+				name = renamed
+			}
+			s.Properties[name] = &Schema{}
+		}
+		if b.IsCollection {
+			name := "collection"
+			if renamed, ok := b.Mapping[name]; ok {
+				name = renamed
+			}
+			s = &Schema{Type: "object", Properties: map[string]*Schema{name: {Type: "array", Items: s}}}
+		}
+		if b.Group != "" {
+			root.Properties[b.Group] = s
+			continue
+		}
+		for k, v := range s.Properties {
+			root.Properties[k] = v
+		}
+	}
+	return root
+}
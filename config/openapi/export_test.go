@@ -0,0 +1,68 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestExport(t *testing.T) {
+	cfg := config.ServiceConfig{
+		Version: config.ConfigVersion,
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint:      "/users/{id}",
+				QueryString:   []string{"fields"},
+				HeadersToPass: []string{"X-Tenant"},
+				Backend: []*config.Backend{
+					{Host: []string{"http://a"}, URLPattern: "/users/{id}", Whitelist: []string{"name", "address.city"}, Mapping: map[string]string{"name": "full_name"}},
+					{Host: []string{"http://b"}, URLPattern: "/orders/{id}", Group: "orders", IsCollection: true, Whitelist: []string{"total"}},
+				},
+			},
+			{
+				Endpoint:       "/users",
+				Method:         "POST",
+				OutputEncoding: "no-op",
+				Backend:        []*config.Backend{{Host: []string{"http://a"}, URLPattern: "/users"}},
+			},
+		},
+	}
+	if err := cfg.Init(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+
+	doc := Export(cfg, Info{Title: "users"}, "https://api.example.com")
+	if doc.OpenAPI != Version || doc.Info.Title != "users" || doc.Info.Version != "1.0" {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	if !reflect.DeepEqual(doc.Servers, []Server{{URL: "https://api.example.com"}}) {
+		t.Errorf("unexpected servers: %v", doc.Servers)
+	}
+
+	get, ok := doc.Paths["/users/{id}"]["get"]
+	if !ok {
+		t.Errorf("unexpected paths: %v", doc.Paths)
+		return
+	}
+	expectedParams := []Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+		{Name: "fields", In: "query", Schema: &Schema{Type: "string"}},
+		{Name: "X-Tenant", In: "header", Schema: &Schema{Type: "string"}},
+	}
+	if get.OperationID != "get_users_id" || !reflect.DeepEqual(get.Parameters, expectedParams) {
+		t.Errorf("unexpected operation: %+v", get)
+	}
+	schema, _ := json.Marshal(get.Responses["200"].Content["application/json"].Schema)
+	expectedSchema := `{"type":"object","properties":{"address":{},"full_name":{},"orders":{"type":"object","properties":{"collection":{"type":"array","items":{"type":"object","properties":{"total":{}}}}}}}}`
+	if string(schema) != expectedSchema {
+		t.Errorf("unexpected response schema: %s", schema)
+	}
+
+	post := doc.Paths["/users"]["post"]
+	if content, ok := post.Responses["200"].Content["*/*"]; !ok || content.Schema != nil {
+		t.Errorf("unexpected response: %+v", post.Responses)
+	}
+}
@@ -0,0 +1,206 @@
+// Package openapi imports the endpoints of an OpenAPI 3 spec, so an existing API contract can be exposed
+// through the gateway without declaring every endpoint by hand
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+var (
+	// ErrUnsupportedVersion is the error returned when the spec is not an OpenAPI 3 one
+	ErrUnsupportedVersion = errors.New("openapi: only the OpenAPI 3 specs are supported")
+	// ErrNoHosts is the error returned when the spec does not declare any absolute server url and there are
+	// no hosts in the options
+	ErrNoHosts = errors.New("openapi: no hosts for the backends")
+
+	methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+)
+
+// Options are the options of the import
+type Options struct {
+	// Hosts of the backends. When empty, the host of the first server of the spec is used
+	Hosts []string
+	// Prefix added to the paths of the imported endpoints
+	Prefix string
+}
+
+// Import decodes the OpenAPI 3 spec (as JSON) and returns an endpoint for every operation, sorted by path and
+// method, with a backend requesting the same path to the server declared in the spec. The query params and
+// the headers of the operations are passed to the backends. The endpoints still need to be initialized, as
+// part of a ServiceConfig
+func Import(data []byte, opts Options) ([]*config.EndpointConfig, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, ErrUnsupportedVersion
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	endpoints := []*config.EndpointConfig{}
+	for _, path := range paths {
+		item := doc.Paths[path]
+		for _, method := range methods {
+			op, ok := item.operations()[method]
+			if !ok || op == nil {
+				continue
+			}
+			servers := doc.Servers
+			if len(item.Servers) > 0 {
+				servers = item.Servers
+			}
+			if len(op.Servers) > 0 {
+				servers = op.Servers
+			}
+			hosts, basePath, err := resolveServers(servers, opts.Hosts)
+			if err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %s", strings.ToUpper(method), path, err.Error())
+			}
+			params, err := doc.parameters(append(append([]parameter{}, item.Parameters...), op.Parameters...))
+			if err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %s", strings.ToUpper(method), path, err.Error())
+			}
+
+			endpoint := &config.EndpointConfig{
+				Endpoint: opts.Prefix + path,
+				Method:   strings.ToUpper(method),
+				Backend: []*config.Backend{
+					{
+						Host:       hosts,
+						Method:     strings.ToUpper(method),
+						URLPattern: basePath + path,
+					},
+				},
+			}
+			for _, p := range params {
+				switch p.In {
+				case "query":
+					endpoint.QueryString = append(endpoint.QueryString, p.Name)
+				case "header":
+					endpoint.HeadersToPass = append(endpoint.HeadersToPass, p.Name)
+				}
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+type document struct {
+	OpenAPI    string              `json:"openapi"`
+	Servers    []server            `json:"servers"`
+	Paths      map[string]pathItem `json:"paths"`
+	Components struct {
+		Parameters map[string]parameter `json:"parameters"`
+	} `json:"components"`
+}
+
+// parameters resolves the references to the components and removes the duplicated parameters, keeping the
+// last ones, so the parameters of the operations override the ones of the path
+func (d document) parameters(params []parameter) ([]parameter, error) {
+	result := []parameter{}
+	index := map[string]int{}
+	for _, p := range params {
+		if p.Ref != "" {
+			name := strings.TrimPrefix(p.Ref, "#/components/parameters/")
+			resolved, ok := d.Components.Parameters[name]
+			if !ok || name == p.Ref {
+				return nil, fmt.Errorf("unknown parameter %s", p.Ref)
+			}
+			p = resolved
+		}
+		key := p.In + " " + p.Name
+		if i, ok := index[key]; ok {
+			result[i] = p
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+type server struct {
+	URL       string `json:"url"`
+	Variables map[string]struct {
+		Default string `json:"default"`
+	} `json:"variables"`
+}
+
+type pathItem struct {
+	Get        *operation  `json:"get"`
+	Put        *operation  `json:"put"`
+	Post       *operation  `json:"post"`
+	Delete     *operation  `json:"delete"`
+	Options    *operation  `json:"options"`
+	Head       *operation  `json:"head"`
+	Patch      *operation  `json:"patch"`
+	Trace      *operation  `json:"trace"`
+	Servers    []server    `json:"servers"`
+	Parameters []parameter `json:"parameters"`
+}
+
+func (p pathItem) operations() map[string]*operation {
+	return map[string]*operation{
+		"get":     p.Get,
+		"put":     p.Put,
+		"post":    p.Post,
+		"delete":  p.Delete,
+		"options": p.Options,
+		"head":    p.Head,
+		"patch":   p.Patch,
+		"trace":   p.Trace,
+	}
+}
+
+type operation struct {
+	Servers    []server    `json:"servers"`
+	Parameters []parameter `json:"parameters"`
+}
+
+type parameter struct {
+	Ref  string `json:"$ref"`
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+// resolveServers returns the hosts and the base path of the backends. The hosts of the options replace the
+// one of the server, but the base path of the server is kept
+func resolveServers(servers []server, hosts []string) ([]string, string, error) {
+	if len(servers) == 0 {
+		if len(hosts) == 0 {
+			return nil, "", ErrNoHosts
+		}
+		return hosts, "", nil
+	}
+	s := servers[0]
+	rawURL := s.URL
+	for name, v := range s.Variables {
+		rawURL = strings.Replace(rawURL, "{"+name+"}", v.Default, -1)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if len(hosts) > 0 {
+		return hosts, basePath, nil
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, "", ErrNoHosts
+	}
+	return []string{u.Scheme + "://" + u.Host}, basePath, nil
+}
@@ -0,0 +1,123 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const spec = `{
+	"openapi": "3.0.0",
+	"info": {"title": "users", "version": "1.0"},
+	"servers": [{"url": "https://{env}.example.com/v1/", "variables": {"env": {"default": "api"}}}],
+	"components": {
+		"parameters": {
+			"page": {"name": "page", "in": "query", "schema": {"type": "integer"}}
+		}
+	},
+	"paths": {
+		"/users/{id}": {
+			"parameters": [
+				{"name": "id", "in": "path", "required": true},
+				{"name": "X-Tenant", "in": "header"}
+			],
+			"get": {
+				"parameters": [{"name": "fields", "in": "query"}]
+			},
+			"delete": {
+				"servers": [{"url": "http://admin.example.com"}]
+			}
+		},
+		"/users": {
+			"summary": "the users",
+			"get": {
+				"parameters": [
+					{"$ref": "#/components/parameters/page"},
+					{"name": "session", "in": "cookie"}
+				]
+			},
+			"post": {}
+		}
+	}
+}`
+
+func TestImport(t *testing.T) {
+	endpoints, err := Import([]byte(spec), Options{Prefix: "/api"})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	expected := []*config.EndpointConfig{
+		{
+			Endpoint:    "/api/users",
+			Method:      "GET",
+			QueryString: []string{"page"},
+			Backend:     []*config.Backend{{Host: []string{"https://api.example.com"}, Method: "GET", URLPattern: "/v1/users"}},
+		},
+		{
+			Endpoint: "/api/users",
+			Method:   "POST",
+			Backend:  []*config.Backend{{Host: []string{"https://api.example.com"}, Method: "POST", URLPattern: "/v1/users"}},
+		},
+		{
+			Endpoint:      "/api/users/{id}",
+			Method:        "GET",
+			QueryString:   []string{"fields"},
+			HeadersToPass: []string{"X-Tenant"},
+			Backend:       []*config.Backend{{Host: []string{"https://api.example.com"}, Method: "GET", URLPattern: "/v1/users/{id}"}},
+		},
+		{
+			Endpoint:      "/api/users/{id}",
+			Method:        "DELETE",
+			HeadersToPass: []string{"X-Tenant"},
+			Backend:       []*config.Backend{{Host: []string{"http://admin.example.com"}, Method: "DELETE", URLPattern: "/users/{id}"}},
+		},
+	}
+	if len(endpoints) != len(expected) {
+		t.Errorf("unexpected number of endpoints: %d", len(endpoints))
+		return
+	}
+	for i, e := range endpoints {
+		if !reflect.DeepEqual(e, expected[i]) {
+			t.Errorf("unexpected endpoint #%d: %+v %+v", i, e, e.Backend[0])
+		}
+	}
+
+	// the imported endpoints are valid ones
+	cfg := config.ServiceConfig{Version: config.ConfigVersion, Endpoints: endpoints}
+	if err := cfg.Init(); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+}
+
+func TestImport_hosts(t *testing.T) {
+	endpoints, err := Import([]byte(`{"openapi": "3.0.1", "servers": [{"url": "/v2"}], "paths": {"/supu": {"get": {}}}}`),
+		Options{Hosts: []string{"http://127.0.0.1:8080"}})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if len(endpoints) != 1 || endpoints[0].Backend[0].URLPattern != "/v2/supu" ||
+		!reflect.DeepEqual(endpoints[0].Backend[0].Host, []string{"http://127.0.0.1:8080"}) {
+		t.Errorf("unexpected endpoints: %+v", endpoints)
+	}
+
+	if _, err := Import([]byte(`{"openapi": "3.0.1", "servers": [{"url": "/v2"}], "paths": {"/supu": {"get": {}}}}`),
+		Options{}); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestImport_errors(t *testing.T) {
+	for _, data := range []string{
+		`{"swagger": "2.0", "paths": {}}`,
+		`{"openapi": "3.0.0", "paths": {"/supu": {"get": {}}}}`,
+		`{"openapi": "3.0.0", "servers": [{"url": "http://a"}], "paths": {"/supu": {"get": {"parameters": [{"$ref": "#/components/parameters/unknown"}]}}}}`,
+		`{"openapi": 3}`,
+	} {
+		if _, err := Import([]byte(data), Options{}); err == nil {
+			t.Errorf("error expected for %s", data)
+		}
+	}
+}
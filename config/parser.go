@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"time"
 )
 
@@ -19,28 +20,99 @@ type ParserFunc func(string) (ServiceConfig, error)
 // Parse implements the Parser interface
 func (f ParserFunc) Parse(configFile string) (ServiceConfig, error) { return f(configFile) }
 
-// NewParser creates a new parser using the json library
+// NewParser creates a new parser using the json library or the decoder registered for the extension of the
+// configuration file (see RegisterDecoder). The configuration file can also be the location of a remote
+// configuration (see Load). The configurations can use ${ENV_VAR:default} expressions and $ref includes,
+// and the files with the TemplateExtension are rendered as text/templates. When the configuration file is
+// a directory, the configuration files in it are merged in the order of their names. The configurations are
+// validated against the Schema, so the unknown keys and the values of the wrong type are reported as
+// ValidationErrors instead of being ignored. The defaults and the profiles declared in the configuration are
+// applied to the endpoints and their backends
 func NewParser() Parser {
 	return parser{}
 }
 
-type parser struct{}
+// NewParserWithCache creates a new parser keeping a copy of the last valid configuration in the cache file,
+// so the service can start with it when the remote source is not available
+func NewParserWithCache(cacheFile string) Parser {
+	return parser{cacheFile: cacheFile}
+}
+
+// NewParserWithFormat creates a new parser decoding the configuration file with the registered decoder of
+// the format, whatever its extension is
+func NewParserWithFormat(format string) Parser {
+	return parser{format: format}
+}
+
+// NewParserWithSnapshots creates a new parser adding every parsed configuration to the snapshots, so the
+// service can be rolled back to any of them
+func NewParserWithSnapshots(snapshots *Snapshots) Parser {
+	return parser{snapshots: snapshots}
+}
+
+type parser struct {
+	cacheFile string
+	format    string
+	snapshots *Snapshots
+}
 
 // Parser implements the Parse interface
 func (p parser) Parse(configFile string) (ServiceConfig, error) {
 	var result ServiceConfig
-	var cfg parseableServiceConfig
-	data, err := ioutil.ReadFile(configFile)
+	data, err := Load(configFile)
+	switch {
+	case err == nil:
+		format := p.format
+		if format == "" {
+			format = formatOf(configFile)
+		}
+		if data, err = renderFormat(configFile, format, data); err != nil {
+			return result, fmt.Errorf("Fatal error config file: While rendering config: %s \n", err.Error())
+		}
+	case isDir(configFile):
+		if data, err = mergeDir(configFile); err != nil {
+			if errs, ok := err.(ValidationErrors); ok {
+				return result, errs
+			}
+			return result, fmt.Errorf("Fatal error config file: While merging config: %s \n", err.Error())
+		}
+	case p.cacheFile != "":
+		// the cached configurations are already rendered
+		data, err = ioutil.ReadFile(p.cacheFile)
+	}
 	if err != nil {
 		return result, fmt.Errorf("Fatal error config file: %s \n", configFile)
 	}
-	if err = json.Unmarshal(data, &cfg); err != nil {
+	if err = Validate(configFile, data); err != nil {
+		if errs, ok := err.(ValidationErrors); ok {
+			return result, errs
+		}
 		return result, fmt.Errorf("Fatal error config file: While parsing config: %s \n", err.Error())
 	}
-	result = cfg.normalize()
-	err = result.Init()
+	if data, err = applyProfiles(configFile, data); err != nil {
+		return result, err
+	}
+	if result, err = parseRendered(data); err != nil {
+		return result, err
+	}
+	if p.cacheFile != "" {
+		ioutil.WriteFile(p.cacheFile, data, 0600)
+	}
+	if p.snapshots != nil {
+		p.snapshots.Add(configFile, data)
+	}
 
-	return result, err
+	return result, nil
+}
+
+// parseRendered parses and initializes the rendered configuration
+func parseRendered(data []byte) (ServiceConfig, error) {
+	var cfg parseableServiceConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return ServiceConfig{}, fmt.Errorf("Fatal error config file: While parsing config: %s \n", err.Error())
+	}
+	result := cfg.normalize()
+	return result, result.Init()
 }
 
 type parseableServiceConfig struct {
@@ -55,10 +127,89 @@ type parseableServiceConfig struct {
 	WriteTimeout        string                     `json:"write_timeout"`
 	IdleTimeout         string                     `json:"idle_timeout"`
 	ReadHeaderTimeout   string                     `json:"read_header_timeout"`
+	ShutdownDelay       string                     `json:"shutdown_delay"`
+	DrainTimeout        string                     `json:"drain_timeout"`
+	ReusePort           bool                       `json:"reuse_port"`
 	MaxIdleConnsPerHost int                        `json:"max_idle_connections"`
+	MaxHeaderBytes      int                        `json:"max_header_bytes"`
+	MaxBodySize         int64                      `json:"max_body_size"`
+	OutputEncoding      string                     `json:"output_encoding"`
+	TLS                 *parseableTLS              `json:"tls,omitempty"`
+	ProxyProtocol       *parseableProxyProtocol    `json:"proxy_protocol,omitempty"`
+	Listeners           []*parseableListener       `json:"listeners"`
 	Debug               bool
 }
 
+type parseableListener struct {
+	Name          string                  `json:"name"`
+	Port          int                     `json:"port"`
+	TLS           *parseableTLS           `json:"tls,omitempty"`
+	ProxyProtocol *parseableProxyProtocol `json:"proxy_protocol,omitempty"`
+}
+
+func (p *parseableListener) normalize() *Listener {
+	l := Listener{Name: p.Name, Port: p.Port}
+	if p.TLS != nil {
+		l.TLS = p.TLS.normalize()
+	}
+	if p.ProxyProtocol != nil {
+		l.ProxyProtocol = p.ProxyProtocol.normalize()
+	}
+	return &l
+}
+
+type parseableProxyProtocol struct {
+	IsDisabled bool     `json:"disabled"`
+	AllowedIPs []string `json:"allowed_ips"`
+}
+
+func (p *parseableProxyProtocol) normalize() *ProxyProtocol {
+	return &ProxyProtocol{IsDisabled: p.IsDisabled, AllowedIPs: p.AllowedIPs}
+}
+
+type parseableTLS struct {
+	IsDisabled               bool                  `json:"disabled"`
+	PublicKey                string                `json:"public_key"`
+	PrivateKey               string                `json:"private_key"`
+	Keys                     []parseableTLSKeyPair `json:"keys"`
+	MinVersion               string                `json:"min_version"`
+	MaxVersion               string                `json:"max_version"`
+	CurvePreferences         []uint16              `json:"curve_preferences"`
+	CipherSuites             []uint16              `json:"cipher_suites"`
+	PreferServerCipherSuites bool                  `json:"prefer_server_cipher_suites"`
+	ReloadInterval           string                `json:"reload_interval"`
+	ClientAuth               string                `json:"client_auth"`
+	ClientCAs                []string              `json:"client_ca_certs"`
+}
+
+type parseableTLSKeyPair struct {
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+// normalize returns the TLS settings. The public_key and the private_key are a shortcut for the first key pair
+func (p *parseableTLS) normalize() *TLS {
+	keys := []TLSKeyPair{}
+	if p.PublicKey != "" || p.PrivateKey != "" {
+		keys = append(keys, TLSKeyPair{PublicKey: p.PublicKey, PrivateKey: p.PrivateKey})
+	}
+	for _, k := range p.Keys {
+		keys = append(keys, TLSKeyPair{PublicKey: k.PublicKey, PrivateKey: k.PrivateKey})
+	}
+	return &TLS{
+		IsDisabled:               p.IsDisabled,
+		Keys:                     keys,
+		MinVersion:               p.MinVersion,
+		MaxVersion:               p.MaxVersion,
+		CurvePreferences:         p.CurvePreferences,
+		CipherSuites:             p.CipherSuites,
+		PreferServerCipherSuites: p.PreferServerCipherSuites,
+		ReloadInterval:           parseDuration(p.ReloadInterval),
+		ClientAuth:               p.ClientAuth,
+		ClientCAs:                p.ClientCAs,
+	}
+}
+
 func (p *parseableServiceConfig) normalize() ServiceConfig {
 	cfg := ServiceConfig{
 		Timeout:             parseDuration(p.Timeout),
@@ -71,14 +222,29 @@ func (p *parseableServiceConfig) normalize() ServiceConfig {
 		WriteTimeout:        parseDuration(p.WriteTimeout),
 		IdleTimeout:         parseDuration(p.IdleTimeout),
 		ReadHeaderTimeout:   parseDuration(p.ReadHeaderTimeout),
+		ShutdownDelay:       parseDuration(p.ShutdownDelay),
+		DrainTimeout:        parseDuration(p.DrainTimeout),
+		ReusePort:           p.ReusePort,
 		MaxIdleConnsPerHost: p.MaxIdleConnsPerHost,
+		MaxHeaderBytes:      p.MaxHeaderBytes,
+		MaxBodySize:         p.MaxBodySize,
+		OutputEncoding:      p.OutputEncoding,
 	}
 	if p.ExtraConfig != nil {
 		cfg.ExtraConfig = *p.ExtraConfig
 	}
+	if p.TLS != nil {
+		cfg.TLS = p.TLS.normalize()
+	}
+	if p.ProxyProtocol != nil {
+		cfg.ProxyProtocol = p.ProxyProtocol.normalize()
+	}
+	for _, l := range p.Listeners {
+		cfg.Listeners = append(cfg.Listeners, l.normalize())
+	}
 	endpoints := []*EndpointConfig{}
 	for _, e := range p.Endpoints {
-		endpoints = append(endpoints, e.normalize())
+		endpoints = append(endpoints, expandMethods(e.normalize(), e.Method)...)
 	}
 	cfg.Endpoints = endpoints
 	return cfg
@@ -86,7 +252,7 @@ func (p *parseableServiceConfig) normalize() ServiceConfig {
 
 type parseableEndpointConfig struct {
 	Endpoint        string              `json:"endpoint"`
-	Method          string              `json:"method"`
+	Method          endpointMethods     `json:"method"`
 	Backend         []*parseableBackend `json:"backend"`
 	ConcurrentCalls int                 `json:"concurrent_calls"`
 	Timeout         string              `json:"timeout"`
@@ -94,17 +260,22 @@ type parseableEndpointConfig struct {
 	QueryString     []string            `json:"querystring_params"`
 	ExtraConfig     *ExtraConfig        `json:"extra_config,omitempty"`
 	HeadersToPass   []string            `json:"headers_to_pass"`
+	OutputEncoding  string              `json:"output_encoding"`
+	MaxBodySize     int64               `json:"max_body_size"`
+	Listener        string              `json:"listener"`
 }
 
 func (p *parseableEndpointConfig) normalize() *EndpointConfig {
 	e := EndpointConfig{
 		Endpoint:        p.Endpoint,
-		Method:          p.Method,
 		ConcurrentCalls: p.ConcurrentCalls,
 		Timeout:         parseDuration(p.Timeout),
 		CacheTTL:        time.Duration(p.CacheTTL) * time.Second,
 		QueryString:     p.QueryString,
 		HeadersToPass:   p.HeadersToPass,
+		OutputEncoding:  p.OutputEncoding,
+		MaxBodySize:     p.MaxBodySize,
+		Listener:        p.Listener,
 	}
 	if p.ExtraConfig != nil {
 		e.ExtraConfig = *p.ExtraConfig
@@ -161,3 +332,8 @@ func parseDuration(v string) time.Duration {
 	}
 	return d
 }
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
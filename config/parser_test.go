@@ -3,8 +3,10 @@ package config
 import (
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewParser_ok(t *testing.T) {
@@ -148,7 +150,7 @@ func TestNewParser_readingError(t *testing.T) {
 
 func TestNewParser_initError(t *testing.T) {
 	wrongConfigPath := "/tmp/unmarshall.json"
-	wrongConfigContent := []byte("{\"a\":42}")
+	wrongConfigContent := []byte("{\"port\":42}")
 	if err := ioutil.WriteFile(wrongConfigPath, wrongConfigContent, 0644); err != nil {
 		t.FailNow()
 	}
@@ -172,3 +174,133 @@ func TestParserFunc(t *testing.T) {
 		t.Error("unexpected parsed config:", result)
 	}
 }
+
+func TestParseRendered_tls(t *testing.T) {
+	cfg, err := parseRendered([]byte(`{
+	"version": 2,
+	"tls": {
+		"public_key": "cert.pem",
+		"private_key": "key.pem",
+		"keys": [{"public_key": "other.pem", "private_key": "other.key"}],
+		"min_version": "TLS12",
+		"curve_preferences": [29, 23],
+		"cipher_suites": [49199],
+		"reload_interval": "30s",
+		"client_auth": "require_and_verify",
+		"client_ca_certs": ["ca.pem"]
+	}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &TLS{
+		Keys:             []TLSKeyPair{{"cert.pem", "key.pem"}, {"other.pem", "other.key"}},
+		MinVersion:       "TLS12",
+		CurvePreferences: []uint16{29, 23},
+		CipherSuites:     []uint16{49199},
+		ReloadInterval:   30 * time.Second,
+		ClientAuth:       "require_and_verify",
+		ClientCAs:        []string{"ca.pem"},
+	}
+	if !reflect.DeepEqual(cfg.TLS, expected) {
+		t.Errorf("unexpected TLS settings: %+v", cfg.TLS)
+	}
+
+	if _, err := parseRendered([]byte(`{"version": 2, "tls": {"min_version": "TLS12"}}`)); err != errNoTLSKeys {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cfg, err = parseRendered([]byte(`{"version": 2, "tls": {"disabled": true}}`))
+	if err != nil || !cfg.TLS.IsDisabled {
+		t.Errorf("unexpected result: %+v %v", cfg.TLS, err)
+	}
+}
+
+func TestParseRendered_shutdown(t *testing.T) {
+	cfg, err := parseRendered([]byte(`{"version": 2, "shutdown_delay": "5s", "drain_timeout": "1m"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ShutdownDelay != 5*time.Second || cfg.DrainTimeout != time.Minute {
+		t.Errorf("unexpected shutdown settings: %v %v", cfg.ShutdownDelay, cfg.DrainTimeout)
+	}
+}
+
+func TestParseRendered_reusePort(t *testing.T) {
+	cfg, err := parseRendered([]byte(`{"version": 2, "reuse_port": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.ReusePort {
+		t.Error("the reuse_port option was not parsed")
+	}
+}
+
+func TestParseRendered_listeners(t *testing.T) {
+	cfg, err := parseRendered([]byte(`{
+		"version": 2,
+		"listeners": [{"name": "admin", "port": 8090, "tls": {"disabled": true}}],
+		"endpoints": [{"endpoint": "/supu", "listener": "admin", "backend": [{"host": ["http://127.0.0.1:8081"], "url_pattern": "/"}]}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Listeners) != 1 || cfg.Listeners[0].Name != "admin" || cfg.Listeners[0].Port != 8090 || !cfg.Listeners[0].TLS.IsDisabled {
+		t.Errorf("unexpected listeners: %+v", cfg.Listeners)
+	}
+	if cfg.Endpoints[0].Listener != "admin" {
+		t.Errorf("unexpected listener of the endpoint: %s", cfg.Endpoints[0].Listener)
+	}
+}
+
+func TestParseRendered_proxyProtocol(t *testing.T) {
+	cfg, err := parseRendered([]byte(`{
+		"version": 2,
+		"proxy_protocol": {"allowed_ips": ["10.0.0.0/8", "192.168.1.1"]},
+		"listeners": [
+			{"name": "admin", "port": 8090, "proxy_protocol": {"disabled": true}},
+			{"name": "metrics", "port": 8091}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.ProxyProtocol, &ProxyProtocol{AllowedIPs: []string{"10.0.0.0/8", "192.168.1.1"}}) {
+		t.Errorf("unexpected proxy protocol settings: %+v", cfg.ProxyProtocol)
+	}
+	if p := cfg.ListenerConfig("admin").ProxyProtocol; p == nil || !p.IsDisabled {
+		t.Errorf("unexpected proxy protocol settings of the listener: %+v", p)
+	}
+	if p := cfg.ListenerConfig("metrics").ProxyProtocol; p != nil {
+		t.Errorf("unexpected proxy protocol settings of the listener: %+v", p)
+	}
+
+	for _, src := range []string{
+		`{"version": 2, "proxy_protocol": {}}`,
+		`{"version": 2, "proxy_protocol": {"allowed_ips": ["supu"]}}`,
+	} {
+		if _, err := parseRendered([]byte(src)); err == nil {
+			t.Errorf("%s: error expected", src)
+		}
+	}
+}
+
+func TestParseRendered_limits(t *testing.T) {
+	cfg, err := parseRendered([]byte(`{
+		"version": 2,
+		"max_header_bytes": 8192,
+		"max_body_size": 1024,
+		"endpoints": [
+			{"endpoint": "/supu", "backend": [{"host": ["http://127.0.0.1:8081"], "url_pattern": "/"}]},
+			{"endpoint": "/upload", "method": "POST", "max_body_size": 10485760, "backend": [{"host": ["http://127.0.0.1:8081"], "url_pattern": "/"}]}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxHeaderBytes != 8192 {
+		t.Errorf("unexpected max header bytes: %d", cfg.MaxHeaderBytes)
+	}
+	if cfg.Endpoints[0].MaxBodySize != 1024 || cfg.Endpoints[1].MaxBodySize != 10485760 {
+		t.Errorf("unexpected max body sizes: %d %d", cfg.Endpoints[0].MaxBodySize, cfg.Endpoints[1].MaxBodySize)
+	}
+}
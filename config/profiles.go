@@ -0,0 +1,150 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	defaultsKey = "defaults"
+	profilesKey = "profiles"
+	profileKey  = "profile"
+)
+
+// applyProfiles expands the defaults and the profiles of the configuration. The defaults and the profiles
+// declare an 'endpoint' and a 'backend' object with the settings shared by the endpoints and their backends.
+// The settings are applied in this order, so every one overrides the previous:
+//
+// - the defaults
+//
+// - the profile of the endpoint (its backend object is also applied to all the backends of the endpoint)
+//
+// - the profile of the backend
+//
+// - the settings of the endpoint or the backend itself
+//
+// The objects, like the extra_config, are merged recursively and the rest of the values are replaced
+func applyProfiles(file string, data []byte) ([]byte, error) {
+	v, err := decode(JSONFormat, data)
+	if err != nil {
+		return nil, err
+	}
+	cfg, ok := v.(map[string]interface{})
+	if !ok {
+		return data, nil
+	}
+	defaults, _ := cfg[defaultsKey].(map[string]interface{})
+	profiles, _ := cfg[profilesKey].(map[string]interface{})
+	endpoints, _ := cfg[endpointsKey].([]interface{})
+	if defaults == nil && profiles == nil && !usesProfiles(endpoints) {
+		return data, nil
+	}
+	delete(cfg, defaultsKey)
+	delete(cfg, profilesKey)
+
+	errs := ValidationErrors{}
+	lookup := func(node map[string]interface{}, pointer string) map[string]interface{} {
+		name, ok := node[profileKey]
+		if !ok {
+			return nil
+		}
+		delete(node, profileKey)
+		s, _ := name.(string)
+		profile, ok := profiles[s].(map[string]interface{})
+		if !ok {
+			errs = append(errs, ValidationError{File: file, Pointer: pointer + "/" + profileKey, Message: fmt.Sprintf("unknown profile %q", s)})
+		}
+		return profile
+	}
+
+	for i, e := range endpoints {
+		endpoint, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pointer := fmt.Sprintf("/%s/%d", endpointsKey, i)
+		profile := lookup(endpoint, pointer)
+		endpoint = mergeLayers(section(defaults, "endpoint"), section(profile, "endpoint"), endpoint)
+
+		backends, _ := endpoint["backend"].([]interface{})
+		for j, b := range backends {
+			backend, ok := b.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			backendProfile := lookup(backend, fmt.Sprintf("%s/backend/%d", pointer, j))
+			backends[j] = mergeLayers(section(defaults, "backend"), section(profile, "backend"), section(backendProfile, "backend"), backend)
+		}
+		endpoints[i] = endpoint
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return json.Marshal(cfg)
+}
+
+func usesProfiles(endpoints []interface{}) bool {
+	for _, e := range endpoints {
+		endpoint, _ := e.(map[string]interface{})
+		if _, ok := endpoint[profileKey]; ok {
+			return true
+		}
+		backends, _ := endpoint["backend"].([]interface{})
+		for _, b := range backends {
+			backend, _ := b.(map[string]interface{})
+			if _, ok := backend[profileKey]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func section(profile map[string]interface{}, name string) map[string]interface{} {
+	s, _ := profile[name].(map[string]interface{})
+	return s
+}
+
+// mergeLayers returns a new object with the keys of all the layers, the last ones overriding the first ones
+func mergeLayers(layers ...map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, layer := range layers {
+		overrideObject(result, layer)
+	}
+	return result
+}
+
+func overrideObject(dst, src map[string]interface{}) {
+	for k, v := range src {
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			dst[k] = copyValue(v)
+			continue
+		}
+		current, ok := dst[k].(map[string]interface{})
+		if !ok {
+			current = map[string]interface{}{}
+			dst[k] = current
+		}
+		overrideObject(current, object)
+	}
+}
+
+// copyValue copies the objects and the lists, so the values of the profiles are not shared
+func copyValue(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(node))
+		for k, child := range node {
+			m[k] = copyValue(child)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(node))
+		for i, child := range node {
+			l[i] = copyValue(child)
+		}
+		return l
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewParser_profiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"krakend.json": `{
+	"version": 2,
+	"defaults": {
+		"endpoint": {"timeout": "3s", "headers_to_pass": ["X-Request-Id"]},
+		"backend": {"host": ["http://127.0.0.1:8080"], "extra_config": {"supu": {"a": 1, "b": 1}}}
+	},
+	"profiles": {
+		"users": {
+			"endpoint": {"concurrent_calls": 2, "querystring_params": ["page"]},
+			"backend": {"host": ["http://127.0.0.1:8081"], "extra_config": {"supu": {"b": 2}}}
+		},
+		"legacy": {
+			"backend": {"encoding": "xml"}
+		}
+	},
+	"endpoints": [
+		{"endpoint": "/supu", "backend": [{"url_pattern": "/supu"}]},
+		{"endpoint": "/users", "profile": "users", "timeout": "1s", "backend": [
+			{"url_pattern": "/users"},
+			{"url_pattern": "/legacy", "profile": "legacy", "extra_config": {"supu": {"c": 3}}}
+		]}
+	]
+}`,
+		"unknown.json": `{"version": 2, "endpoints": [{"endpoint": "/supu", "backend": [{"url_pattern": "/supu", "profile": "unknown"}]}]}`,
+	})
+	defer os.RemoveAll(dir)
+
+	cfg, err := NewParser().Parse(filepath.Join(dir, "krakend.json"))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+
+	supu := cfg.Endpoints[0]
+	if supu.Timeout != 3*time.Second || !reflect.DeepEqual(supu.HeadersToPass, []string{"X-Request-Id"}) || supu.ConcurrentCalls != 1 {
+		t.Errorf("unexpected endpoint: %+v", supu)
+	}
+	if b := supu.Backend[0]; !reflect.DeepEqual(b.Host, []string{"http://127.0.0.1:8080"}) ||
+		!reflect.DeepEqual(b.ExtraConfig["supu"], map[string]interface{}{"a": 1.0, "b": 1.0}) {
+		t.Errorf("unexpected backend: %+v", b)
+	}
+
+	users := cfg.Endpoints[1]
+	if users.Timeout != time.Second || users.ConcurrentCalls != 2 || !reflect.DeepEqual(users.QueryString, []string{"page"}) {
+		t.Errorf("unexpected endpoint: %+v", users)
+	}
+	if b := users.Backend[0]; !reflect.DeepEqual(b.Host, []string{"http://127.0.0.1:8081"}) || b.Encoding != "" ||
+		!reflect.DeepEqual(b.ExtraConfig["supu"], map[string]interface{}{"a": 1.0, "b": 2.0}) {
+		t.Errorf("unexpected backend: %+v", b)
+	}
+	if b := users.Backend[1]; !reflect.DeepEqual(b.Host, []string{"http://127.0.0.1:8081"}) || b.Encoding != "xml" ||
+		!reflect.DeepEqual(b.ExtraConfig["supu"], map[string]interface{}{"a": 1.0, "b": 2.0, "c": 3.0}) {
+		t.Errorf("unexpected backend: %+v", b)
+	}
+
+	_, err = NewParser().Parse(filepath.Join(dir, "unknown.json"))
+	if _, ok := err.(ValidationErrors); !ok || !strings.Contains(err.Error(), `/endpoints/0/backend/0/profile: unknown profile "unknown"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestApplyProfiles_untouched(t *testing.T) {
+	data := []byte(`{"version": 2, "endpoints": [{"endpoint": "/supu"}]}`)
+	result, err := applyProfiles("krakend.json", data)
+	if err != nil || string(result) != string(data) {
+		t.Errorf("unexpected result: %s %v", result, err)
+	}
+}
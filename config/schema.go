@@ -0,0 +1,507 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is the JSON Schema of the ServiceConfig. The extra_config objects accept any key, so the components
+// can declare their own namespaces, but the rest of the unknown keys are violations
+const Schema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "KrakenD service",
+	"type": "object",
+	"additionalProperties": false,
+	"definitions": {
+		"duration": {"type": "string", "pattern": "^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"},
+		"strings": {"type": "array", "items": {"type": "string"}},
+		"extra_config": {"type": "object"},
+		"endpoint": {
+			"type": "object",
+			"additionalProperties": false,
+			"properties": {
+				"endpoint": {"type": "string"},
+				"method": {"type": ["string", "array"], "items": {"type": "string"}},
+				"profile": {"type": "string"},
+				"concurrent_calls": {"type": "integer", "minimum": 0},
+				"timeout": {"$ref": "#/definitions/duration"},
+				"cache_ttl": {"type": "integer", "minimum": 0},
+				"querystring_params": {"$ref": "#/definitions/strings"},
+				"headers_to_pass": {"$ref": "#/definitions/strings"},
+				"output_encoding": {"type": "string"},
+				"max_body_size": {"type": "integer", "minimum": 0},
+				"extra_config": {"$ref": "#/definitions/extra_config"},
+				"listener": {"type": "string"},
+				"backend": {"type": "array", "items": {"$ref": "#/definitions/backend"}}
+			}
+		},
+		"backend": {
+			"type": "object",
+			"additionalProperties": false,
+			"properties": {
+				"group": {"type": "string"},
+				"method": {"type": "string"},
+				"profile": {"type": "string"},
+				"host": {"$ref": "#/definitions/strings"},
+				"disable_host_sanitize": {"type": "boolean"},
+				"url_pattern": {"type": "string"},
+				"blacklist": {"$ref": "#/definitions/strings"},
+				"whitelist": {"$ref": "#/definitions/strings"},
+				"mapping": {"type": "object", "additionalProperties": {"type": "string"}},
+				"encoding": {"type": "string"},
+				"is_collection": {"type": "boolean"},
+				"target": {"type": "string"},
+				"sd": {"type": "string"},
+				"extra_config": {"$ref": "#/definitions/extra_config"}
+			}
+		},
+		"key_pair": {
+			"type": "object",
+			"additionalProperties": false,
+			"required": ["public_key", "private_key"],
+			"properties": {
+				"public_key": {"type": "string"},
+				"private_key": {"type": "string"}
+			}
+		},
+		"tls_version": {"type": "string", "enum": ["SSL3.0", "TLS10", "TLS11", "TLS12", "TLS13"]},
+		"tls": {
+			"type": "object",
+			"additionalProperties": false,
+			"properties": {
+				"disabled": {"type": "boolean"},
+				"public_key": {"type": "string"},
+				"private_key": {"type": "string"},
+				"keys": {"type": "array", "items": {"$ref": "#/definitions/key_pair"}},
+				"min_version": {"$ref": "#/definitions/tls_version"},
+				"max_version": {"$ref": "#/definitions/tls_version"},
+				"curve_preferences": {"type": "array", "items": {"type": "integer", "minimum": 0}},
+				"cipher_suites": {"type": "array", "items": {"type": "integer", "minimum": 0}},
+				"prefer_server_cipher_suites": {"type": "boolean"},
+				"reload_interval": {"$ref": "#/definitions/duration"},
+				"client_auth": {"type": "string", "enum": ["none", "request", "require", "verify_if_given", "require_and_verify"]},
+				"client_ca_certs": {"type": "array", "items": {"type": "string"}}
+			}
+		},
+		"listener": {
+			"type": "object",
+			"additionalProperties": false,
+			"required": ["name", "port"],
+			"properties": {
+				"name": {"type": "string"},
+				"port": {"type": "integer", "minimum": 1},
+				"tls": {"$ref": "#/definitions/tls"},
+				"proxy_protocol": {"$ref": "#/definitions/proxy_protocol"}
+			}
+		},
+		"proxy_protocol": {
+			"type": "object",
+			"additionalProperties": false,
+			"properties": {
+				"disabled": {"type": "boolean"},
+				"allowed_ips": {"$ref": "#/definitions/strings"}
+			}
+		},
+		"profile": {
+			"type": "object",
+			"additionalProperties": false,
+			"properties": {
+				"endpoint": {"$ref": "#/definitions/endpoint"},
+				"backend": {"$ref": "#/definitions/backend"}
+			}
+		}
+	},
+	"properties": {
+		"version": {"type": "integer"},
+		"name": {"type": "string"},
+		"port": {"type": "integer", "minimum": 0},
+		"host": {"$ref": "#/definitions/strings"},
+		"timeout": {"$ref": "#/definitions/duration"},
+		"cache_ttl": {"$ref": "#/definitions/duration"},
+		"read_timeout": {"$ref": "#/definitions/duration"},
+		"write_timeout": {"$ref": "#/definitions/duration"},
+		"idle_timeout": {"$ref": "#/definitions/duration"},
+		"read_header_timeout": {"$ref": "#/definitions/duration"},
+		"shutdown_delay": {"$ref": "#/definitions/duration"},
+		"drain_timeout": {"$ref": "#/definitions/duration"},
+		"reuse_port": {"type": "boolean"},
+		"max_idle_connections": {"type": "integer", "minimum": 0},
+		"max_header_bytes": {"type": "integer", "minimum": 0},
+		"max_body_size": {"type": "integer", "minimum": 0},
+		"output_encoding": {"type": "string"},
+		"tls": {"$ref": "#/definitions/tls"},
+		"proxy_protocol": {"$ref": "#/definitions/proxy_protocol"},
+		"listeners": {"type": "array", "items": {"$ref": "#/definitions/listener"}},
+		"debug": {"type": "boolean"},
+		"extra_config": {"$ref": "#/definitions/extra_config"},
+		"defaults": {"$ref": "#/definitions/profile"},
+		"profiles": {"type": "object", "additionalProperties": {"$ref": "#/definitions/profile"}},
+		"endpoints": {
+			"type": "array",
+			"items": {"allOf": [{"$ref": "#/definitions/endpoint"}, {"required": ["endpoint"]}]}
+		}
+	}
+}`
+
+var serviceSchema map[string]interface{}
+
+func init() {
+	if err := json.Unmarshal([]byte(Schema), &serviceSchema); err != nil {
+		panic(err)
+	}
+}
+
+// ValidationError is a violation of the Schema, located by the file, the line (when the rendered configuration
+// keeps the layout of the file) and the JSON pointer of the value
+type ValidationError struct {
+	File    string
+	Line    int
+	Pointer string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+	}
+	pointer := e.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	if location == "" {
+		return fmt.Sprintf("%s: %s", pointer, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, pointer, e.Message)
+}
+
+// ValidationErrors is the list of violations of the Schema found in a configuration
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid config:\n" + strings.Join(msgs, "\n")
+}
+
+// Validate validates the rendered JSON configuration of the file against the Schema, returning the
+// ValidationErrors with all the violations or the error decoding the data
+func Validate(file string, data []byte) error {
+	v, err := decode(JSONFormat, data)
+	if err != nil {
+		return err
+	}
+	errs := ValidationErrors{}
+	validateValue(v, serviceSchema, serviceSchema, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	var offsets map[string]int
+	if bytes.Contains(bytes.TrimSpace(data), []byte("\n")) {
+		s := &offsetScanner{data: data, offsets: map[string]int{}}
+		s.value("")
+		offsets = s.offsets
+	}
+	for i := range errs {
+		errs[i].File = file
+		if offset, ok := offsets[errs[i].Pointer]; ok {
+			errs[i].Line = 1 + bytes.Count(data[:offset], []byte("\n"))
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Pointer < errs[j].Pointer
+	})
+	return errs
+}
+
+// ValidateJSON validates the decoded JSON value against the JSON Schema, returning all the violations. The
+// numbers of the value must be json.Number ones and the $ref keywords are resolved against the schema.
+// Only the subset of keywords used by the Schema and the length and size limits are supported
+func ValidateJSON(schema map[string]interface{}, v interface{}) ValidationErrors {
+	errs := ValidationErrors{}
+	validateValue(v, schema, schema, "", &errs)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Pointer < errs[j].Pointer })
+	return errs
+}
+
+func validateValue(v interface{}, s, root map[string]interface{}, pointer string, errs *ValidationErrors) {
+	addError := func(pointer, format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		resolved, err := jsonPointer(root, strings.TrimPrefix(ref, "#"))
+		if err != nil {
+			addError(pointer, "unresolved schema reference %q", ref)
+			return
+		}
+		if s, ok = resolved.(map[string]interface{}); !ok {
+			addError(pointer, "unresolved schema reference %q", ref)
+			return
+		}
+	}
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if sub, ok := sub.(map[string]interface{}); ok {
+				validateValue(v, sub, root, pointer, errs)
+			}
+		}
+		return
+	}
+
+	switch expected := s["type"].(type) {
+	case string:
+		if !hasType(v, expected) {
+			addError(pointer, "expected %s, got %s", expected, typeOf(v))
+			return
+		}
+	case []interface{}:
+		names := make([]string, len(expected))
+		matched := false
+		for i, t := range expected {
+			names[i] = t.(string)
+			matched = matched || hasType(v, names[i])
+		}
+		if !matched {
+			addError(pointer, "expected %s, got %s", strings.Join(names, " or "), typeOf(v))
+			return
+		}
+	}
+
+	switch node := v.(type) {
+	case map[string]interface{}:
+		properties, _ := s["properties"].(map[string]interface{})
+		if required, ok := s["required"].([]interface{}); ok {
+			for _, k := range required {
+				if _, ok := node[k.(string)]; !ok {
+					addError(pointer, "missing required key %q", k)
+				}
+			}
+		}
+		for k, child := range node {
+			childPointer := pointer + "/" + escapePointer(k)
+			if property, ok := properties[k].(map[string]interface{}); ok {
+				validateValue(child, property, root, childPointer, errs)
+				continue
+			}
+			switch additional := s["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					addError(childPointer, "unknown key %q%s", k, suggestion(k, properties))
+				}
+			case map[string]interface{}:
+				validateValue(child, additional, root, childPointer, errs)
+			}
+		}
+	case []interface{}:
+		if min, ok := s["minItems"].(float64); ok && float64(len(node)) < min {
+			addError(pointer, "%d items are fewer than the minimum %v", len(node), min)
+		}
+		if max, ok := s["maxItems"].(float64); ok && float64(len(node)) > max {
+			addError(pointer, "%d items are more than the maximum %v", len(node), max)
+		}
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, child := range node {
+				validateValue(child, items, root, pointer+"/"+strconv.Itoa(i), errs)
+			}
+		}
+	case string:
+		if pattern, ok := s["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err != nil {
+				addError(pointer, "invalid pattern %s: %s", pattern, err)
+			} else if !re.MatchString(node) {
+				addError(pointer, "%q does not match the pattern %s", node, pattern)
+			}
+		}
+		if enum, ok := s["enum"].([]interface{}); ok && !inEnum(node, enum) {
+			addError(pointer, "%q is not one of %v", node, enum)
+		}
+		length := utf8.RuneCountInString(node)
+		if min, ok := s["minLength"].(float64); ok && float64(length) < min {
+			addError(pointer, "%q is shorter than the minimum length %v", node, min)
+		}
+		if max, ok := s["maxLength"].(float64); ok && float64(length) > max {
+			addError(pointer, "%q is longer than the maximum length %v", node, max)
+		}
+	case json.Number:
+		f, _ := node.Float64()
+		if min, ok := s["minimum"].(float64); ok && f < min {
+			addError(pointer, "%s is lower than the minimum %v", node, min)
+		}
+		if max, ok := s["maximum"].(float64); ok && f > max {
+			addError(pointer, "%s is greater than the maximum %v", node, max)
+		}
+	}
+}
+
+func inEnum(v string, enum []interface{}) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// escapePointer escapes the key to be used as a token of a JSON pointer
+func escapePointer(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
+
+func hasType(v interface{}, expected string) bool {
+	actual := typeOf(v)
+	return actual == expected || (expected == "number" && actual == "integer")
+}
+
+func typeOf(v interface{}) string {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := node.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// suggestion returns a hint with the closest known key, so the typos are easier to fix
+func suggestion(key string, properties map[string]interface{}) string {
+	best, distance := "", 3
+	for k := range properties {
+		if d := levenshtein(strings.ToLower(key), k); d < distance || (d == distance && k < best) {
+			best, distance = k, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// offsetScanner records the offset of every value of a valid JSON document by its JSON pointer. The members
+// of the objects are located by their keys
+type offsetScanner struct {
+	data    []byte
+	pos     int
+	offsets map[string]int
+}
+
+func (s *offsetScanner) value(pointer string) {
+	s.skipSpaces()
+	if s.pos >= len(s.data) {
+		return
+	}
+	if _, ok := s.offsets[pointer]; !ok {
+		s.offsets[pointer] = s.pos
+	}
+	switch s.data[s.pos] {
+	case '{':
+		s.pos++
+		for s.skipSpaces(); s.pos < len(s.data) && s.data[s.pos] != '}'; s.skipSpaces() {
+			start := s.pos
+			var key string
+			json.Unmarshal(s.data[start:s.stringEnd()], &key)
+			child := pointer + "/" + escapePointer(key)
+			s.offsets[child] = start
+			s.skipSpaces()
+			s.pos++ // the colon
+			s.value(child)
+			s.skipSpaces()
+			if s.pos < len(s.data) && s.data[s.pos] == ',' {
+				s.pos++
+			}
+		}
+		s.pos++
+	case '[':
+		s.pos++
+		for i := 0; ; i++ {
+			s.skipSpaces()
+			if s.pos >= len(s.data) || s.data[s.pos] == ']' {
+				break
+			}
+			s.value(pointer + "/" + strconv.Itoa(i))
+			s.skipSpaces()
+			if s.pos < len(s.data) && s.data[s.pos] == ',' {
+				s.pos++
+			}
+		}
+		s.pos++
+	case '"':
+		s.stringEnd()
+	default:
+		for s.pos < len(s.data) && !bytes.ContainsRune([]byte(",}] \t\r\n"), rune(s.data[s.pos])) {
+			s.pos++
+		}
+	}
+}
+
+// stringEnd moves the scanner after the string starting at the current position and returns its end
+func (s *offsetScanner) stringEnd() int {
+	for s.pos++; s.pos < len(s.data); s.pos++ {
+		switch s.data[s.pos] {
+		case '\\':
+			s.pos++
+		case '"':
+			s.pos++
+			return s.pos
+		}
+	}
+	return s.pos
+}
+
+func (s *offsetScanner) skipSpaces() {
+	for s.pos < len(s.data) && bytes.ContainsRune([]byte(" \t\r\n"), rune(s.data[s.pos])) {
+		s.pos++
+	}
+}
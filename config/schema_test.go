@@ -0,0 +1,150 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	data := []byte(`{
+	"version": 2,
+	"port": "8080",
+	"timeout": "3 s",
+	"extra_config": {"any": {"namespace": true}},
+	"endpoints": [
+		{
+			"endpoint": "/supu",
+			"backend": [
+				{
+					"host": ["http://127.0.0.1:8081"],
+					"url_patern": "/tupu",
+					"mapping": {"a": 1}
+				}
+			]
+		},
+		{
+			"method": "POST",
+			"concurrent_calls": -1
+		}
+	]
+}`)
+	err := Validate("krakend.json", data)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	expected := ValidationErrors{
+		{File: "krakend.json", Line: 3, Pointer: "/port", Message: "expected integer, got string"},
+		{File: "krakend.json", Line: 4, Pointer: "/timeout", Message: `"3 s" does not match the pattern ^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`},
+		{File: "krakend.json", Line: 12, Pointer: "/endpoints/0/backend/0/url_patern", Message: `unknown key "url_patern", did you mean "url_pattern"?`},
+		{File: "krakend.json", Line: 13, Pointer: "/endpoints/0/backend/0/mapping/a", Message: "expected string, got integer"},
+		{File: "krakend.json", Line: 17, Pointer: "/endpoints/1", Message: `missing required key "endpoint"`},
+		{File: "krakend.json", Line: 19, Pointer: "/endpoints/1/concurrent_calls", Message: "-1 is lower than the minimum 0"},
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("unexpected errors:\n%s", errs.Error())
+	}
+	if !strings.Contains(errs.Error(), "krakend.json:12: /endpoints/0/backend/0/url_patern: unknown key") {
+		t.Errorf("unexpected message: %s", errs.Error())
+	}
+}
+
+func TestValidate_compact(t *testing.T) {
+	// the configurations without layout, like the rendered YAML files, are located by the pointer
+	err := Validate("krakend.yml", []byte(`{"version": 2, "prot": 8080}`))
+	expected := "invalid config:\nkrakend.yml: /prot: unknown key \"prot\", did you mean \"port\"?"
+	if err == nil || err.Error() != expected {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := Validate("krakend.json", []byte(`{"version": 2, "debug": true, "name": "supu"}`)); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+	if err := Validate("krakend.json", []byte(`[`)); err == nil {
+		t.Error("error expected")
+	} else if _, ok := err.(ValidationErrors); ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewParser_validation(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"krakend.json":           "{\n\t\"version\": 2,\n\t\"timeuot\": \"3s\"\n}",
+		"conf.d/00_service.json": `{"version": 2}`,
+		"conf.d/10_users.json":   "{\"endpoints\": [\n\t{\"endpoint\": \"/users\", \"backends\": []}\n]}",
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := NewParser().Parse(filepath.Join(dir, "krakend.json"))
+	expected := filepath.Join(dir, "krakend.json") + `:3: /timeuot: unknown key "timeuot", did you mean "timeout"?`
+	if _, ok := err.(ValidationErrors); !ok || !strings.Contains(err.Error(), expected) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// the violations of the merged files are located in their files
+	_, err = NewParser().Parse(filepath.Join(dir, "conf.d"))
+	expected = filepath.Join(dir, "conf.d", "10_users.json") + `:2: /endpoints/0/backends: unknown key "backends", did you mean "backend"?`
+	if _, ok := err.(ValidationErrors); !ok || !strings.Contains(err.Error(), expected) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidate_tls(t *testing.T) {
+	data := []byte(`{"version": 2, "tls": {"public_key": "cert.pem", "private_key": "key.pem", "min_version": "TLS9", "client_auth": "always", "keys": [{"public_key": "a.pem"}]}}`)
+	err := Validate("krakend.yml", data)
+	expected := "invalid config:\n" +
+		"krakend.yml: /tls/client_auth: \"always\" is not one of [none request require verify_if_given require_and_verify]\n" +
+		"krakend.yml: /tls/keys/0: missing required key \"private_key\"\n" +
+		"krakend.yml: /tls/min_version: \"TLS9\" is not one of [SSL3.0 TLS10 TLS11 TLS12 TLS13]"
+	if err == nil || err.Error() != expected {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	schema := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["name", "tags"],
+		"definitions": {"tag": {"type": "string", "minLength": 2, "maxLength": 4}},
+		"properties": {
+			"name": {"type": "string", "pattern": "^[a-z]+$"},
+			"age": {"type": "integer", "minimum": 0, "maximum": 150},
+			"tags": {"type": "array", "maxItems": 2, "items": {"$ref": "#/definitions/tag"}}
+		}
+	}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := decode(JSONFormat, []byte(`{"name": "Supu", "age": 200, "tags": ["a", "ok", "toolong"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"/age: 200 is greater than the maximum 150",
+		"/name: \"Supu\" does not match the pattern ^[a-z]+$",
+		"/tags: 3 items are more than the maximum 2",
+		"/tags/0: \"a\" is shorter than the minimum length 2",
+		"/tags/2: \"toolong\" is longer than the maximum length 4",
+	}
+	errs := ValidateJSON(schema, v)
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("unexpected violations: %v", msgs)
+	}
+
+	if v, err = decode(JSONFormat, []byte(`{"name": "supu", "age": 42, "tags": ["ok"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if errs := ValidateJSON(schema, v); len(errs) > 0 {
+		t.Errorf("unexpected violations: %v", errs)
+	}
+}
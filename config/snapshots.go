@@ -0,0 +1,187 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSnapshots is the default number of configurations kept by the Snapshots
+const DefaultMaxSnapshots = 10
+
+// ErrUnknownSnapshot is the error returned when the requested snapshot is not kept
+var ErrUnknownSnapshot = errors.New("unknown config snapshot")
+
+// Snapshot is a loaded configuration, already rendered and with the profiles applied
+type Snapshot struct {
+	ID       int             `json:"id"`
+	Source   string          `json:"source"`
+	LoadedAt time.Time       `json:"loaded_at"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// Config parses and initializes the configuration of the snapshot
+func (s Snapshot) Config() (ServiceConfig, error) {
+	return parseRendered(s.Data)
+}
+
+// Snapshots keeps the last loaded configurations in memory and, optionally, in a directory, so the service
+// can be rolled back to any of them
+type Snapshots struct {
+	mu      sync.RWMutex
+	max     int
+	dir     string
+	list    []Snapshot
+	next    int
+	current int
+}
+
+// NewSnapshots creates a store keeping the last max configurations. A non positive max means the
+// DefaultMaxSnapshots. If dir is not empty, every snapshot is also written to it and the ones found there
+// are loaded, so the history survives the restarts of the service
+func NewSnapshots(max int, dir string) (*Snapshots, error) {
+	if max <= 0 {
+		max = DefaultMaxSnapshots
+	}
+	s := &Snapshots{max: max, dir: dir, list: []Snapshot{}, next: 1}
+	if dir == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range files {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(b, &snapshot); err != nil {
+			return nil, fmt.Errorf("config snapshot %s: %s", name, err.Error())
+		}
+		s.list = append(s.list, snapshot)
+	}
+	sort.Slice(s.list, func(i, j int) bool { return s.list[i].ID < s.list[j].ID })
+	if len(s.list) > 0 {
+		last := s.list[len(s.list)-1]
+		s.next = last.ID + 1
+		s.current = last.ID
+	}
+	s.prune()
+	return s, nil
+}
+
+// Add stores the rendered configuration as a new snapshot and makes it the current one. If the data is
+// the same as the one of the last snapshot, no new snapshot is created, but the last one becomes the current
+func (s *Snapshots) Add(source string, data []byte) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l := len(s.list); l > 0 && bytes.Equal(s.list[l-1].Data, data) {
+		s.current = s.list[l-1].ID
+		return s.list[l-1], nil
+	}
+
+	snapshot := Snapshot{
+		ID:       s.next,
+		Source:   source,
+		LoadedAt: time.Now(),
+		Data:     append(json.RawMessage{}, data...),
+	}
+	if s.dir != "" {
+		b, err := json.Marshal(snapshot)
+		if err != nil {
+			return snapshot, err
+		}
+		if err := ioutil.WriteFile(s.filename(snapshot.ID), b, 0600); err != nil {
+			return snapshot, err
+		}
+	}
+	s.next++
+	s.current = snapshot.ID
+	s.list = append(s.list, snapshot)
+	s.prune()
+	return snapshot, nil
+}
+
+// List returns the kept snapshots, from the oldest to the newest, without their data
+func (s *Snapshots) List() []Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Snapshot, len(s.list))
+	for i, snapshot := range s.list {
+		snapshot.Data = nil
+		result[i] = snapshot
+	}
+	return result
+}
+
+// Get returns the snapshot with the id
+func (s *Snapshots) Get(id int) (Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, snapshot := range s.list {
+		if snapshot.ID == id {
+			return snapshot, nil
+		}
+	}
+	return Snapshot{}, ErrUnknownSnapshot
+}
+
+// Current returns the id of the snapshot in use. It is zero if there are no snapshots
+func (s *Snapshots) Current() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Rollback parses the configuration of the snapshot and, only if it is valid, makes it the current one.
+// The returned configuration is the one the service should be updated with
+func (s *Snapshots) Rollback(id int) (ServiceConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, snapshot := range s.list {
+		if snapshot.ID != id {
+			continue
+		}
+		cfg, err := snapshot.Config()
+		if err != nil {
+			return cfg, err
+		}
+		s.current = id
+		return cfg, nil
+	}
+	return ServiceConfig{}, ErrUnknownSnapshot
+}
+
+// prune removes the oldest snapshots over the max, but never the current one
+func (s *Snapshots) prune() {
+	for len(s.list) > s.max {
+		i := 0
+		if s.list[0].ID == s.current {
+			i = 1
+		}
+		if s.dir != "" {
+			os.Remove(s.filename(s.list[i].ID))
+		}
+		s.list = append(s.list[:i], s.list[i+1:]...)
+	}
+}
+
+func (s *Snapshots) filename(id int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.json", id))
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func snapshotData(port int) []byte {
+	return []byte(fmt.Sprintf(`{"version":2,"port":%d,"endpoints":[{"endpoint":"/a","backend":[{"host":["http://127.0.0.1"],"url_pattern":"/a"}]}]}`, port))
+}
+
+func TestSnapshots(t *testing.T) {
+	s, err := NewSnapshots(2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Current() != 0 {
+		t.Errorf("unexpected current snapshot: %d", s.Current())
+	}
+	for _, port := range []int{8080, 8081, 8081, 8082} {
+		if _, err := s.Add("test.json", snapshotData(port)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := s.List()
+	if len(list) != 2 || list[0].ID != 2 || list[1].ID != 3 {
+		t.Fatalf("unexpected snapshots: %v", list)
+	}
+	if list[0].Data != nil || list[0].Source != "test.json" {
+		t.Errorf("unexpected snapshot: %v", list[0])
+	}
+	if s.Current() != 3 {
+		t.Errorf("unexpected current snapshot: %d", s.Current())
+	}
+	if _, err := s.Get(1); err != ErrUnknownSnapshot {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg, err := s.Rollback(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 8081 || len(cfg.Endpoints) != 1 || cfg.Endpoints[0].Method != "GET" {
+		t.Errorf("unexpected config: %v", cfg)
+	}
+	if s.Current() != 2 {
+		t.Errorf("unexpected current snapshot: %d", s.Current())
+	}
+
+	s.Add("test.json", snapshotData(8083))
+	list = s.List()
+	if len(list) != 2 || list[0].ID != 3 || list[1].ID != 4 {
+		t.Errorf("unexpected snapshots: %v", list)
+	}
+	if _, err := s.Rollback(42); err != ErrUnknownSnapshot {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSnapshots_invalidRollback(t *testing.T) {
+	s, _ := NewSnapshots(0, "")
+	s.Add("test.json", snapshotData(8080))
+	s.Add("test.json", []byte(`{"version":2,"endpoints":[{"endpoint":"/a","backend":[]}]}`))
+	if _, err := s.Rollback(2); err == nil {
+		t.Error("error expected")
+	}
+	if s.Current() != 2 {
+		t.Errorf("unexpected current snapshot: %d", s.Current())
+	}
+	if _, err := s.Rollback(1); err != nil {
+		t.Error(err)
+	}
+	if s.Current() != 1 {
+		t.Errorf("unexpected current snapshot: %d", s.Current())
+	}
+}
+
+func TestSnapshots_dir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := NewSnapshots(2, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, port := range []int{8080, 8081, 8082} {
+		s.Add("test.json", snapshotData(port))
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 2 {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	s, err = NewSnapshots(2, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Current() != 3 {
+		t.Errorf("unexpected current snapshot: %d", s.Current())
+	}
+	snapshot, err := s.Get(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(snapshot.Data) != string(snapshotData(8081)) {
+		t.Errorf("unexpected data: %s", snapshot.Data)
+	}
+	if snapshot, _ := s.Add("test.json", snapshotData(8083)); snapshot.ID != 4 {
+		t.Errorf("unexpected snapshot: %v", snapshot)
+	}
+}
+
+func TestNewParserWithSnapshots(t *testing.T) {
+	f, err := ioutil.TempFile("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(snapshotData(8080))
+	f.Close()
+
+	s, _ := NewSnapshots(0, "")
+	if _, err := NewParserWithSnapshots(s).Parse(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	list := s.List()
+	if len(list) != 1 || list[0].Source != f.Name() {
+		t.Errorf("unexpected snapshots: %v", list)
+	}
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	refKey = "$ref"
+	// TemplateExtension is the extension of the configuration files rendered as text/templates
+	TemplateExtension = ".tmpl"
+)
+
+var (
+	// MaxRefDepth is the max number of nested includes
+	MaxRefDepth = 10
+
+	// ErrRefTooDeep is the error returned when the includes are nested too deep or they form a cycle
+	ErrRefTooDeep = errors.New("too many nested $ref")
+	// ErrInvalidRef is the error returned when the $ref is not a string
+	ErrInvalidRef = errors.New("the $ref must be a string")
+
+	envVarPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:([^}]*))?\}`)
+
+	templateFuncs = template.FuncMap{
+		"env": os.Getenv,
+		"default": func(def, v string) string {
+			if v == "" {
+				return def
+			}
+			return v
+		},
+	}
+)
+
+// render preprocesses the content of the configuration at the location:
+//
+// - the files with the TemplateExtension are executed as text/templates, with the 'env' and 'default' funcs
+// and the env vars as data
+//
+// - the ${ENV_VAR} and ${ENV_VAR:default} expressions are replaced with the value of the env var ($$ escapes
+// a dollar sign). The undefined env vars without default are errors
+//
+// - the objects with a '$ref' are replaced by the content of the referenced file, relative to the location of
+// the including file. The ref can select a part of the file with a JSON pointer, like 'backends.json#/users/0'
+func render(location string, data []byte) ([]byte, error) {
+	return renderFormat(location, formatOf(location), data)
+}
+
+// renderFormat renders the data with the format, returning it as JSON
+func renderFormat(location, format string, data []byte) ([]byte, error) {
+	data, err := renderFile(location, data)
+	if err != nil {
+		return nil, err
+	}
+	hasRefs := bytes.Contains(data, []byte(refKey))
+	if format == JSONFormat && !hasRefs {
+		return data, nil
+	}
+	v, err := decode(format, data)
+	if err != nil {
+		return nil, err
+	}
+	if hasRefs {
+		if v, err = resolveRefs(location, v, 0); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(v)
+}
+
+// renderFile executes the template and replaces the env vars of a single file
+func renderFile(location string, data []byte) ([]byte, error) {
+	if strings.HasSuffix(stripFragment(location), TemplateExtension) {
+		tmpl, err := template.New(location).Funcs(templateFuncs).Option("missingkey=zero").Parse(string(data))
+		if err != nil {
+			return nil, err
+		}
+		env := map[string]string{}
+		for _, kv := range os.Environ() {
+			if i := strings.Index(kv, "="); i > 0 {
+				env[kv[:i]] = kv[i+1:]
+			}
+		}
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, env); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
+	return expandEnv(data)
+}
+
+func expandEnv(data []byte) ([]byte, error) {
+	var err error
+	result := envVarPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		if string(match) == "$$" {
+			return []byte("$")
+		}
+		groups := envVarPattern.FindSubmatch(match)
+		if v, ok := os.LookupEnv(string(groups[1])); ok {
+			return []byte(v)
+		}
+		if len(groups[2]) > 0 {
+			return groups[3]
+		}
+		if err == nil {
+			err = fmt.Errorf("undefined env var %s", groups[1])
+		}
+		return match
+	})
+	return result, err
+}
+
+func resolveRefs(location string, v interface{}, depth int) (interface{}, error) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := node[refKey]; ok && len(node) == 1 {
+			s, ok := ref.(string)
+			if !ok {
+				return nil, ErrInvalidRef
+			}
+			return include(location, s, depth)
+		}
+		for k, child := range node {
+			resolved, err := resolveRefs(location, child, depth)
+			if err != nil {
+				return nil, err
+			}
+			node[k] = resolved
+		}
+	case []interface{}:
+		for i, child := range node {
+			resolved, err := resolveRefs(location, child, depth)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = resolved
+		}
+	}
+	return v, nil
+}
+
+func include(base, ref string, depth int) (interface{}, error) {
+	if depth >= MaxRefDepth {
+		return nil, ErrRefTooDeep
+	}
+	location := resolveLocation(base, ref)
+	data, err := Load(stripFragment(location))
+	if err != nil {
+		return nil, fmt.Errorf("including %s: %s", ref, err.Error())
+	}
+	if data, err = renderFile(location, data); err != nil {
+		return nil, fmt.Errorf("including %s: %s", ref, err.Error())
+	}
+	v, err := decode(formatOf(location), data)
+	if err != nil {
+		return nil, fmt.Errorf("including %s: %s", ref, err.Error())
+	}
+	if i := strings.Index(location, "#"); i >= 0 {
+		if v, err = jsonPointer(v, location[i+1:]); err != nil {
+			return nil, fmt.Errorf("including %s: %s", ref, err.Error())
+		}
+	}
+	return resolveRefs(location, v, depth+1)
+}
+
+// resolveLocation returns the location of the ref relative to the location of the including file
+func resolveLocation(base, ref string) string {
+	if _, ok := loaderFor(base); ok {
+		b, err := url.Parse(base)
+		if err != nil {
+			return ref
+		}
+		r, err := url.Parse(ref)
+		if err != nil {
+			return ref
+		}
+		return b.ResolveReference(r).String()
+	}
+	if _, ok := loaderFor(ref); ok || filepath.IsAbs(ref) {
+		return ref
+	}
+	return filepath.Join(filepath.Dir(stripFragment(base)), ref)
+}
+
+func jsonPointer(v interface{}, pointer string) (interface{}, error) {
+	if pointer == "" || pointer == "/" {
+		return v, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("unknown key %s", token)
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("invalid index %s", token)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("unknown key %s", token)
+		}
+	}
+	return v, nil
+}
+
+func stripFragment(location string) string {
+	if i := strings.Index(location, "#"); i >= 0 {
+		return location[:i]
+	}
+	return location
+}
+
+func decodeJSON(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
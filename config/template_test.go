@@ -0,0 +1,94 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("KRAKEND_TEST_HOST", "http://127.0.0.1:8080")
+	defer os.Unsetenv("KRAKEND_TEST_HOST")
+
+	data, err := expandEnv([]byte(`{"host": "${KRAKEND_TEST_HOST}", "port": ${KRAKEND_TEST_PORT:8080}, "empty": "${KRAKEND_TEST_EMPTY:}", "literal": "$${KRAKEND_TEST_HOST}"}`))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	expected := `{"host": "http://127.0.0.1:8080", "port": 8080, "empty": "", "literal": "${KRAKEND_TEST_HOST}"}`
+	if string(data) != expected {
+		t.Errorf("unexpected content: %s", data)
+	}
+
+	if _, err := expandEnv([]byte(`{"host": "${KRAKEND_TEST_UNDEFINED}"}`)); err == nil || err.Error() != "undefined env var KRAKEND_TEST_UNDEFINED" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewParser_includes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krakend_includes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("KRAKEND_TEST_PORT", "8081")
+	defer os.Unsetenv("KRAKEND_TEST_PORT")
+
+	for name, content := range map[string]string{
+		"krakend.json.tmpl": `{
+			"version": 2,
+			"port": ${KRAKEND_TEST_PORT},
+			"endpoints": [
+				{"endpoint": "/{{ default "supu" (env "KRAKEND_TEST_ENDPOINT") }}", "backend": [{"$ref": "partials/backends.json#/users"}]},
+				{"$ref": "partials/endpoint.json"}
+			]
+		}`,
+		"partials/backends.json": `{"users": {"host": ["http://127.0.0.1:${KRAKEND_TEST_PORT}"], "url_pattern": "/users"}}`,
+		"partials/endpoint.json": `{"endpoint": "/tupu", "backend": [{"$ref": "../backend.json"}]}`,
+		"backend.json":           `{"host": ["http://127.0.0.1:8082"], "url_pattern": "/tupu"}`,
+		"loop.json":              `{"$ref": "loop.json"}`,
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := NewParser().Parse(filepath.Join(dir, "krakend.json.tmpl"))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if cfg.Port != 8081 || len(cfg.Endpoints) != 2 {
+		t.Errorf("unexpected config: %+v", cfg)
+		return
+	}
+	if e := cfg.Endpoints[0]; e.Endpoint != "/supu" || len(e.Backend) != 1 || e.Backend[0].Host[0] != "http://127.0.0.1:8081" {
+		t.Errorf("unexpected endpoint: %+v", e)
+	}
+	if e := cfg.Endpoints[1]; e.Endpoint != "/tupu" || len(e.Backend) != 1 || e.Backend[0].URLPattern != "/tupu" {
+		t.Errorf("unexpected endpoint: %+v", e)
+	}
+
+	if _, err := render(filepath.Join(dir, "loop.json"), []byte(`{"$ref": "loop.json"}`)); err == nil {
+		t.Error("the cycle was not detected")
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	v := map[string]interface{}{"a/b": []interface{}{"supu", "tupu"}}
+	for pointer, expected := range map[string]interface{}{
+		"/a~1b/1": "tupu",
+		"/a~1b/0": "supu",
+	} {
+		if r, err := jsonPointer(v, pointer); err != nil || r != expected {
+			t.Errorf("%s: unexpected result %v %v", pointer, r, err)
+		}
+	}
+	for _, pointer := range []string{"/unknown", "/a~1b/2", "/a~1b/0/supu"} {
+		if _, err := jsonPointer(v, pointer); err == nil {
+			t.Errorf("%s: error expected", pointer)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Package toml registers a decoder for the TOML configuration files
+package toml
+
+import (
+	"github.com/BurntSushi/toml"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// Format is the extension of the TOML configuration files
+const Format = "toml"
+
+// Register registers the TOML decoder for the configuration files with the toml extension
+func Register() error {
+	config.RegisterDecoder(Format, Decode)
+	return nil
+}
+
+// Decode decodes the TOML document into generic values, as the JSON ones
+func Decode(data []byte) (interface{}, error) {
+	v := map[string]interface{}{}
+	if _, err := toml.Decode(string(data), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,53 @@
+package toml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestRegister(t *testing.T) {
+	if err := Register(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	dir, err := ioutil.TempDir("", "krakend_toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "krakend.toml")
+	content := `
+version = 2
+port = 8080
+timeout = "3s"
+
+[[endpoints]]
+endpoint = "/supu"
+
+  [[endpoints.backend]]
+  host = ["http://127.0.0.1:8081"]
+  url_pattern = "/tupu"
+`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.NewParser().Parse(path)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if cfg.Port != 8080 || len(cfg.Endpoints) != 1 || cfg.Endpoints[0].Backend[0].URLPattern != "/tupu" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestDecode_invalid(t *testing.T) {
+	if _, err := Decode([]byte("version = ")); err == nil {
+		t.Error("error expected")
+	}
+}
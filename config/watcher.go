@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// DefaultWatchInterval is the default time between two checks of the configuration file
+const DefaultWatchInterval = 5 * time.Second
+
+// Watcher watches the service configuration, parsing and validating it again when the file changes, when
+// the process receives a SIGHUP or when Reload is called. The remote configurations are polled and reloaded
+// when their content changes
+type Watcher struct {
+	parser   Parser
+	path     string
+	interval time.Duration
+	trigger  chan struct{}
+}
+
+// NewWatcher creates a watcher of the configuration file, checking it for changes every interval.
+// A non positive interval disables the checks, so the configuration is only reloaded on demand
+func NewWatcher(parser Parser, path string, interval time.Duration) *Watcher {
+	return &Watcher{
+		parser:   parser,
+		path:     path,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Reload requests a reload of the configuration. It does not block, so it can be called from an admin API
+func (w *Watcher) Reload() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Watch starts watching the configuration until the context is canceled. The valid configurations are sent
+// through the first channel and the parsing and validation errors through the second one, so the previous
+// configuration can be kept. The errors are dropped if nobody is receiving them
+func (w *Watcher) Watch(ctx context.Context) (<-chan ServiceConfig, <-chan error) {
+	configs := make(chan ServiceConfig)
+	errs := make(chan error, 1)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	var ticks <-chan time.Time
+	var ticker *time.Ticker
+	if w.interval > 0 {
+		ticker = time.NewTicker(w.interval)
+		ticks = ticker.C
+	}
+
+	lastVersion := w.version()
+	go func() {
+		defer signal.Stop(signals)
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticks:
+				version := w.version()
+				if version == lastVersion {
+					continue
+				}
+				lastVersion = version
+			case <-signals:
+			case <-w.trigger:
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+			cfg, err := w.parser.Parse(w.path)
+			if err != nil {
+				select {
+				case errs <- err:
+				default:
+				}
+				continue
+			}
+			select {
+			case configs <- cfg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return configs, errs
+}
+
+// version identifies the content of the configuration: the modification time of the local files (or the files
+// of the directory) and the hash of the remote configurations
+func (w *Watcher) version() string {
+	if l, ok := loaderFor(w.path); ok {
+		data, err := l(w.path)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return ""
+	}
+	if !info.IsDir() {
+		return info.ModTime().String()
+	}
+	version := ""
+	filepath.Walk(w.path, func(path string, info os.FileInfo, err error) error {
+		if err == nil {
+			version += path + info.ModTime().String()
+		}
+		return nil
+	})
+	return version
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	f, err := ioutil.TempFile("", "krakend_watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	calls := 0
+	parser := ParserFunc(func(path string) (ServiceConfig, error) {
+		if path != f.Name() {
+			t.Errorf("unexpected path: %s", path)
+		}
+		calls++
+		if calls == 2 {
+			return ServiceConfig{}, errors.New("supu")
+		}
+		return ServiceConfig{Port: calls}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := NewWatcher(parser, f.Name(), 10*time.Millisecond)
+	configs, errs := w.Watch(ctx)
+
+	w.Reload()
+	if cfg := <-configs; cfg.Port != 1 {
+		t.Errorf("unexpected config: %v", cfg.Port)
+	}
+
+	// the invalid configurations are not sent
+	w.Reload()
+	select {
+	case err := <-errs:
+		if err.Error() != "supu" {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+	case cfg := <-configs:
+		t.Errorf("unexpected config: %v", cfg.Port)
+	}
+
+	if err := os.Chtimes(f.Name(), time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cfg := <-configs:
+		if cfg.Port != 3 {
+			t.Errorf("unexpected config: %v", cfg.Port)
+		}
+	case <-time.After(time.Second):
+		t.Error("the change of the file was not detected")
+	}
+
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Skip("sending the signal:", err.Error())
+	}
+	select {
+	case cfg := <-configs:
+		if cfg.Port != 4 {
+			t.Errorf("unexpected config: %v", cfg.Port)
+		}
+	case <-time.After(time.Second):
+		t.Error("the signal was not handled")
+	}
+
+	// nothing is reloaded after the cancellation
+	cancel()
+	w.Reload()
+	select {
+	case cfg := <-configs:
+		t.Errorf("unexpected config: %v", cfg.Port)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatcher_remote(t *testing.T) {
+	content := make(chan string, 1)
+	content <- "v1"
+	current := ""
+	RegisterLoader("watched", func(_ string) ([]byte, error) {
+		select {
+		case current = <-content:
+		default:
+		}
+		return []byte(current), nil
+	})
+	parser := ParserFunc(func(path string) (ServiceConfig, error) {
+		data, err := Load(path)
+		return ServiceConfig{ExtraConfig: ExtraConfig{"content": string(data)}}, err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	configs, _ := NewWatcher(parser, "watched://krakend.json", 10*time.Millisecond).Watch(ctx)
+
+	select {
+	case cfg := <-configs:
+		t.Errorf("unexpected config: %s", cfg.ExtraConfig["content"])
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	content <- "v2"
+	select {
+	case cfg := <-configs:
+		if cfg.ExtraConfig["content"] != "v2" {
+			t.Errorf("unexpected config: %s", cfg.ExtraConfig["content"])
+		}
+	case <-time.After(time.Second):
+		t.Error("the change of the remote configuration was not detected")
+	}
+}
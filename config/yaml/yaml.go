@@ -0,0 +1,46 @@
+// Package yaml registers a decoder for the YAML configuration files
+package yaml
+
+import (
+	"fmt"
+
+	goyaml "gopkg.in/yaml.v2"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// Formats are the extensions of the YAML configuration files
+var Formats = []string{"yaml", "yml"}
+
+// Register registers the YAML decoder for the configuration files with the yaml and yml extensions
+func Register() error {
+	for _, format := range Formats {
+		config.RegisterDecoder(format, Decode)
+	}
+	return nil
+}
+
+// Decode decodes the YAML document into generic values with string keys, as the JSON ones
+func Decode(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := goyaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return normalize(v), nil
+}
+
+func normalize(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(node))
+		for k, child := range node {
+			m[fmt.Sprint(k)] = normalize(child)
+		}
+		return m
+	case []interface{}:
+		for i, child := range node {
+			node[i] = normalize(child)
+		}
+	}
+	return v
+}
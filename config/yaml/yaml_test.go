@@ -0,0 +1,69 @@
+package yaml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestDecode(t *testing.T) {
+	v, err := Decode([]byte(`
+a: 1
+b:
+  c: [x, {d: true}]
+  2: two
+`))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	expected := map[string]interface{}{
+		"a": 1,
+		"b": map[string]interface{}{
+			"c": []interface{}{"x", map[string]interface{}{"d": true}},
+			"2": "two",
+		},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("unexpected result: %v", v)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	dir, err := ioutil.TempDir("", "krakend_yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "krakend.yml")
+	content := `
+version: 2
+port: 8080
+timeout: 3s
+endpoints:
+  - endpoint: /supu
+    backend:
+      - host: ["http://127.0.0.1:8081"]
+        url_pattern: /tupu
+`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.NewParser().Parse(path)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if cfg.Port != 8080 || len(cfg.Endpoints) != 1 || cfg.Endpoints[0].Backend[0].URLPattern != "/tupu" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
@@ -0,0 +1,339 @@
+package encoding
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// CBOR is the key for the cbor encoding
+const CBOR = "cbor"
+
+const (
+	cborUint byte = iota << 5
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+const cborBreak = 0xff
+
+// NewCBORDecoder return the right CBOR decoder
+func NewCBORDecoder(isCollection bool) Decoder {
+	if isCollection {
+		return CBORCollectionDecoder
+	}
+	return CBORDecoder
+}
+
+// CBORDecoder implements the Decoder interface
+func CBORDecoder(r io.Reader, v *map[string]interface{}) error {
+	data, err := readCBOR(bufio.NewReader(r), 0)
+	if err != nil {
+		return err
+	}
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return ErrUnexpectedRoot
+	}
+	*(v) = m
+	return nil
+}
+
+// CBORCollectionDecoder implements the Decoder interface over a collection
+func CBORCollectionDecoder(r io.Reader, v *map[string]interface{}) error {
+	data, err := readCBOR(bufio.NewReader(r), 0)
+	if err != nil {
+		return err
+	}
+	collection, ok := data.([]interface{})
+	if !ok {
+		return ErrUnexpectedRoot
+	}
+	*(v) = map[string]interface{}{"collection": collection}
+	return nil
+}
+
+// CBOREncoder implements the Encoder interface
+func CBOREncoder(w io.Writer, v interface{}) error {
+	bw := bufio.NewWriter(w)
+	if err := writeCBOR(bw, v); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeCBOR(w *bufio.Writer, v interface{}) error {
+	switch t := v.(type) {
+	case nil:
+		return w.WriteByte(cborSimple | 22)
+	case bool:
+		if t {
+			return w.WriteByte(cborSimple | 21)
+		}
+		return w.WriteByte(cborSimple | 20)
+	case string:
+		writeCBORHead(w, cborText, uint64(len(t)))
+		_, err := w.WriteString(t)
+		return err
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return writeCBORInt(w, i)
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return err
+		}
+		return writeCBORFloat(w, f)
+	case int:
+		return writeCBORInt(w, int64(t))
+	case int64:
+		return writeCBORInt(w, t)
+	case int32:
+		return writeCBORInt(w, int64(t))
+	case float64:
+		return writeCBORFloat(w, t)
+	case float32:
+		return writeCBORFloat(w, float64(t))
+	case []interface{}:
+		writeCBORHead(w, cborArray, uint64(len(t)))
+		for _, e := range t {
+			if err := writeCBOR(w, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		writeCBORHead(w, cborMap, uint64(len(t)))
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := writeCBOR(w, k); err != nil {
+				return err
+			}
+			if err := writeCBOR(w, t[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// any other type is normalized through its JSON representation
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return err
+	}
+	return writeCBOR(w, normalized)
+}
+
+// writeCBORHead writes the initial byte of the data item and its argument using the shortest form
+func writeCBORHead(w *bufio.Writer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		w.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		w.WriteByte(major | 24)
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(major | 25)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		w.WriteByte(major | 26)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	default:
+		w.WriteByte(major | 27)
+		binary.Write(w, binary.BigEndian, n)
+	}
+}
+
+func writeCBORInt(w *bufio.Writer, i int64) error {
+	if i < 0 {
+		writeCBORHead(w, cborNegInt, uint64(-1-i))
+		return nil
+	}
+	writeCBORHead(w, cborUint, uint64(i))
+	return nil
+}
+
+func writeCBORFloat(w *bufio.Writer, f float64) error {
+	w.WriteByte(cborSimple | 27)
+	return binary.Write(w, binary.BigEndian, math.Float64bits(f))
+}
+
+func readCBOR(r *bufio.Reader, depth int) (interface{}, error) {
+	code, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	return readCBORItem(r, code, depth)
+}
+
+func readCBORItem(r *bufio.Reader, code byte, depth int) (interface{}, error) {
+	if depth > maxDecodingDepth {
+		return nil, ErrMaxDepthExceeded
+	}
+	major, info := code&0xe0, code&0x1f
+
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		case 25:
+			n, err := readUint(r, 2)
+			return numberFromFloat(halfToFloat(uint16(n))), err
+		case 26:
+			n, err := readUint(r, 4)
+			return numberFromFloat(float64(math.Float32frombits(uint32(n)))), err
+		case 27:
+			n, err := readUint(r, 8)
+			return numberFromFloat(math.Float64frombits(n)), err
+		}
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+	}
+
+	if info == 31 {
+		return readCBORIndefinite(r, major, depth)
+	}
+
+	n, err := readCBORArgument(r, info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		return json.Number(strconv.FormatUint(n, 10)), nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return numberFromFloat(-1 - float64(n)), nil
+		}
+		return json.Number(strconv.FormatInt(-1-int64(n), 10)), nil
+	case cborBytes, cborText:
+		return readString(r, n)
+	case cborArray:
+		res := []interface{}{}
+		for i := uint64(0); i < n; i++ {
+			v, err := readCBOR(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+		return res, nil
+	case cborMap:
+		res := map[string]interface{}{}
+		for i := uint64(0); i < n; i++ {
+			k, err := readCBOR(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			v, err := readCBOR(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res[mapKey(k)] = v
+		}
+		return res, nil
+	}
+	// tags are ignored and the tagged item is returned
+	return readCBOR(r, depth+1)
+}
+
+func readCBORArgument(r *bufio.Reader, info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info <= 27:
+		return readUint(r, 1<<(info-24))
+	}
+	return 0, fmt.Errorf("cbor: invalid additional information %d", info)
+}
+
+// readCBORIndefinite reads the items of an indefinite length string, array or map until the break code
+func readCBORIndefinite(r *bufio.Reader, major byte, depth int) (interface{}, error) {
+	var items []interface{}
+	for {
+		code, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if code == cborBreak {
+			break
+		}
+		v, err := readCBORItem(r, code, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+
+	switch major {
+	case cborBytes, cborText:
+		s := ""
+		for _, chunk := range items {
+			c, ok := chunk.(string)
+			if !ok {
+				return nil, fmt.Errorf("cbor: invalid chunk in indefinite length string")
+			}
+			s += c
+		}
+		return s, nil
+	case cborArray:
+		if items == nil {
+			items = []interface{}{}
+		}
+		return items, nil
+	case cborMap:
+		if len(items)%2 != 0 {
+			return nil, fmt.Errorf("cbor: odd number of items in indefinite length map")
+		}
+		res := map[string]interface{}{}
+		for i := 0; i < len(items); i += 2 {
+			res[mapKey(items[i])] = items[i+1]
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("cbor: indefinite length not allowed for major type %d", major>>5)
+}
+
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
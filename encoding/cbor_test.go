@@ -0,0 +1,156 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCBOREncoder(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		in       interface{}
+		expected []byte
+	}{
+		{"null", nil, []byte{0xf6}},
+		{"bool", []interface{}{true, false}, []byte{0x82, 0xf5, 0xf4}},
+		{"small uint", json.Number("10"), []byte{0x0a}},
+		{"uint8", 24, []byte{0x18, 0x18}},
+		{"uint16", 1000, []byte{0x19, 0x03, 0xe8}},
+		{"negative", -1000, []byte{0x39, 0x03, 0xe7}},
+		{"float", json.Number("1.1"), []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{"text", "IETF", []byte{0x64, 'I', 'E', 'T', 'F'}},
+		{"map", map[string]interface{}{"b": 1, "a": "x"}, []byte{0xa2, 0x61, 'a', 0x61, 'x', 0x61, 'b', 0x01}},
+		{"other types", []string{"a"}, []byte{0x81, 0x61, 'a'}},
+	} {
+		buf := new(bytes.Buffer)
+		if err := CBOREncoder(buf, tc.in); err != nil {
+			t.Errorf("%s: %s", tc.name, err.Error())
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), tc.expected) {
+			t.Errorf("%s: unexpected result: %x", tc.name, buf.Bytes())
+		}
+	}
+}
+
+func TestCBORDecoder(t *testing.T) {
+	data := map[string]interface{}{
+		"supu":  json.Number("42"),
+		"neg":   json.Number("-100000"),
+		"float": json.Number("1.5"),
+		"tupu":  true,
+		"none":  nil,
+		"str":   strings.Repeat("a", 300),
+		"items": []interface{}{map[string]interface{}{"id": json.Number("1")}, "b"},
+	}
+	buf := new(bytes.Buffer)
+	if err := CBOREncoder(buf, data); err != nil {
+		t.Error(err)
+		return
+	}
+	var result map[string]interface{}
+	if err := NewCBORDecoder(false)(buf, &result); err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(result, data) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestCBORDecoder_types(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		in       []byte
+		expected interface{}
+	}{
+		{"half float", []byte{0xf9, 0x3e, 0x00}, json.Number("1.5")},
+		{"half float subnormal", []byte{0xf9, 0x00, 0x01}, json.Number("5.960464477539063e-08")},
+		{"single float", []byte{0xfa, 0x47, 0xc3, 0x50, 0x00}, json.Number("100000")},
+		{"uint64", []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, json.Number("18446744073709551615")},
+		{"undefined", []byte{0xf7}, nil},
+		{"bytes", []byte{0x42, 'h', 'i'}, "hi"},
+		{"tagged date", append([]byte{0xc0, 0x74}, []byte("2013-03-21T20:04:00Z")...), "2013-03-21T20:04:00Z"},
+		{"indefinite text", []byte{0x7f, 0x62, 's', 't', 0x63, 'r', 'e', 'a', 0xff}, "strea"},
+	} {
+		result, err := readCBORTest(append([]byte{0xa1, 0x61, 'a'}, tc.in...))
+		if err != nil {
+			t.Errorf("%s: %s", tc.name, err.Error())
+			continue
+		}
+		if result["a"] != tc.expected {
+			t.Errorf("%s: unexpected result: %v", tc.name, result)
+		}
+	}
+
+	result, err := readCBORTest([]byte{0xa1, 0x61, 'a', 0xf9, 0x7c, 0x00})
+	if err != nil {
+		t.Error(err)
+	} else if f, ok := result["a"].(float64); !ok || !math.IsInf(f, 1) {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	result, err = readCBORTest([]byte{0xbf, 0x61, 'a', 0x9f, 0x01, 0x02, 0xff, 0xff})
+	if err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(result, map[string]interface{}{"a": []interface{}{json.Number("1"), json.Number("2")}}) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestCBORCollectionDecoder(t *testing.T) {
+	var result map[string]interface{}
+	if err := NewCBORDecoder(true)(bytes.NewReader([]byte{0x82, 0x01, 0x61, 'a'}), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"collection": []interface{}{json.Number("1"), "a"}}) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestCBORDecoder_ko(t *testing.T) {
+	for name, in := range map[string][]byte{
+		"empty":            {},
+		"truncated":        {0xa1, 0x63, 'a'},
+		"huge string":      {0xa1, 0x61, 'a', 0x7b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"unexpected":       {0x81, 0x01},
+		"invalid info":     {0xa1, 0x61, 'a', 0x1c},
+		"unknown simple":   {0xa1, 0x61, 'a', 0xf0},
+		"odd map":          {0xbf, 0x61, 'a', 0xff},
+		"indefinite uint":  {0x1f},
+		"bad string chunk": {0xa1, 0x61, 'a', 0x7f, 0x01, 0xff},
+	} {
+		if _, err := readCBORTest(in); err == nil {
+			t.Errorf("%s: error expected", name)
+		}
+	}
+	var result map[string]interface{}
+	if err := CBORCollectionDecoder(bytes.NewReader([]byte{0xa0}), &result); err != ErrUnexpectedRoot {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCBORDecoder_tooDeep(t *testing.T) {
+	for name, nested := range map[string]byte{
+		"array":            0x81,
+		"indefinite array": 0x9f,
+		"tag":              0xc0,
+	} {
+		in := append([]byte{0xa1, 0x61, 'a'}, bytes.Repeat([]byte{nested}, 1000000)...)
+		in = append(in, 0x01)
+		if _, err := readCBORTest(in); err != ErrMaxDepthExceeded {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+}
+
+func readCBORTest(in []byte) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := CBORDecoder(bytes.NewReader(in), &result)
+	return result, err
+}
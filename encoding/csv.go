@@ -0,0 +1,74 @@
+package encoding
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// CSV is the key for the csv encoding
+const CSV = "csv"
+
+// CSVNamespace is the key to look for the csv decoder options in the extra config of the backends
+const CSVNamespace = "github.com/devopsfaith/krakend/encoding/csv"
+
+// NewCSVDecoder return the CSV decoder using a comma as delimiter. Since CSV payloads are always
+// tabular, both the entity and the collection decoders return the rows under the 'collection' key
+func NewCSVDecoder(_ bool) Decoder {
+	return NewCSVDecoderWithDelimiter(',')
+}
+
+// NewCSVDecoderWithDelimiter returns a CSV decoder using the received delimiter. The first line is
+// used as header and every other row is decoded as an object with the header fields as keys
+func NewCSVDecoderWithDelimiter(delimiter rune) Decoder {
+	return func(r io.Reader, v *map[string]interface{}) error {
+		reader := csv.NewReader(r)
+		reader.Comma = delimiter
+		reader.FieldsPerRecord = -1
+
+		header, err := reader.Read()
+		if err == io.EOF {
+			*(v) = map[string]interface{}{"collection": []interface{}{}}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		collection := []interface{}{}
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			row := make(map[string]interface{}, len(header))
+			for i, field := range header {
+				if i < len(record) {
+					row[field] = record[i]
+				}
+			}
+			collection = append(collection, row)
+		}
+		*(v) = map[string]interface{}{"collection": collection}
+		return nil
+	}
+}
+
+func newCSVConfigurableDecoderFactory(extra map[string]interface{}) (DecoderFactory, error) {
+	delimiter := ','
+	if cfg, ok := extra[CSVNamespace].(map[string]interface{}); ok {
+		if d, ok := cfg["delimiter"].(string); ok {
+			if utf8.RuneCountInString(d) != 1 || d == "\n" || d == "\r" || d == "\"" {
+				return nil, fmt.Errorf("invalid csv delimiter: %q", d)
+			}
+			delimiter, _ = utf8.DecodeRuneInString(d)
+		}
+	}
+	return func(_ bool) Decoder {
+		return NewCSVDecoderWithDelimiter(delimiter)
+	}, nil
+}
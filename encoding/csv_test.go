@@ -0,0 +1,75 @@
+package encoding
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVDecoder(t *testing.T) {
+	input := "id,name,email\n1,supu,supu@example.com\n2,\"tupu, jr\",\n3,foo\n"
+	expected := map[string]interface{}{
+		"collection": []interface{}{
+			map[string]interface{}{"id": "1", "name": "supu", "email": "supu@example.com"},
+			map[string]interface{}{"id": "2", "name": "tupu, jr", "email": ""},
+			map[string]interface{}{"id": "3", "name": "foo"},
+		},
+	}
+	for _, isCollection := range []bool{true, false} {
+		var result map[string]interface{}
+		if err := NewCSVDecoder(isCollection)(strings.NewReader(input), &result); err != nil {
+			t.Error(err)
+			continue
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("unexpected result: %v", result)
+		}
+	}
+}
+
+func TestCSVDecoder_empty(t *testing.T) {
+	var result map[string]interface{}
+	if err := NewCSVDecoder(true)(strings.NewReader(""), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"collection": []interface{}{}}) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestCSVDecoder_delimiter(t *testing.T) {
+	df, err := GetConfigured(CSV, map[string]interface{}{
+		CSVNamespace: map[string]interface{}{"delimiter": ";"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var result map[string]interface{}
+	if err := df(true)(strings.NewReader("a;b\n1;2\n"), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"collection": []interface{}{map[string]interface{}{"a": "1", "b": "2"}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	for _, d := range []string{"", ";;", "\n", "\""} {
+		if _, err := GetConfigured(CSV, map[string]interface{}{
+			CSVNamespace: map[string]interface{}{"delimiter": d},
+		}); err == nil {
+			t.Errorf("error expected for the delimiter %q", d)
+		}
+	}
+}
+
+func TestCSVDecoder_ko(t *testing.T) {
+	var result map[string]interface{}
+	if err := NewCSVDecoder(true)(strings.NewReader("a,b\n\"1,2\n"), &result); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -1,5 +1,5 @@
 /*
-Package encoding provides Decoding implementations.
+Package encoding provides Decoding and Encoding implementations.
 
 Decode decodes HTTP responses:
 
@@ -8,19 +8,39 @@ Decode decodes HTTP responses:
 	var data map[string]interface{}
 	err := JSONDecoder(resp.Body, &data)
 
+Encode encodes the response data:
+
+	err := XMLEncoder(w, data)
+
 */
 package encoding
 
-import "io"
+import (
+	"errors"
+	"io"
+)
 
 // A Decoder is a function that reads from the reader and decodes it
 // into an map of interfaces
 type Decoder func(io.Reader, *map[string]interface{}) error
 
+// An Encoder is a function that writes the encoded version of the received value into the writer
+type Encoder func(io.Writer, interface{}) error
+
 // A DecoderFactory is a function that returns CollectionDecoder or an EntityDecoder
 type DecoderFactory func(bool) Decoder
 
-var decoders = map[string]DecoderFactory{JSON: NewJSONDecoder}
+var decoders = map[string]DecoderFactory{
+	JSON:    NewJSONDecoder,
+	XML:     NewXMLDecoder,
+	MSGPACK: NewMsgPackDecoder,
+	CBOR:    NewCBORDecoder,
+	CSV:     NewCSVDecoder,
+	NDJSON:  NewNDJSONDecoder,
+	RSS:     NewRSSDecoder,
+	SSE:     NewSSEDecoder,
+	NOOP:    NewNoOpDecoder,
+}
 
 // Register registers the decoder factory with the given name
 func Register(name string, dec DecoderFactory) error {
@@ -38,3 +58,81 @@ func Get(name string) DecoderFactory {
 	}
 	return NewJSONDecoder
 }
+
+// A ConfigurableDecoderFactory returns a DecoderFactory set up with the extra config of the backend
+type ConfigurableDecoderFactory func(extra map[string]interface{}) (DecoderFactory, error)
+
+var configurableDecoders = map[string]ConfigurableDecoderFactory{
+	XML: newXMLConfigurableDecoderFactory,
+	CSV: newCSVConfigurableDecoderFactory,
+}
+
+// RegisterConfigurable registers the configurable decoder factory with the given name
+func RegisterConfigurable(name string, dec ConfigurableDecoderFactory) error {
+	configurableDecoders[name] = dec
+	return nil
+}
+
+// GetConfigured returns the decoder factory registered with the given name, set up with the received
+// extra config. If there is no configurable factory with the received name, it behaves like Get
+func GetConfigured(name string, extra map[string]interface{}) (DecoderFactory, error) {
+	if dec, ok := configurableDecoders[name]; ok {
+		return dec(extra)
+	}
+	return Get(name), nil
+}
+
+// An EncoderFactory returns an Encoder set up with the extra config of the endpoint
+type EncoderFactory func(extra map[string]interface{}) (Encoder, error)
+
+// ErrUnknownEncoder is the error returned when there is no encoder registered with the requested name
+var ErrUnknownEncoder = errors.New("unknown encoder")
+
+type encoderFactory struct {
+	contentType string
+	factory     EncoderFactory
+}
+
+var encoders = map[string]encoderFactory{}
+
+// RegisterEncoder registers the encoder factory with the given name and the content type of the
+// encoded responses
+func RegisterEncoder(name, contentType string, enc EncoderFactory) error {
+	encoders[name] = encoderFactory{contentType: contentType, factory: enc}
+	return nil
+}
+
+// GetEncoder returns the encoder registered with the given name, set up with the received extra config,
+// and its content type
+func GetEncoder(name string, extra map[string]interface{}) (Encoder, string, error) {
+	enc, ok := encoders[name]
+	if !ok {
+		return nil, "", ErrUnknownEncoder
+	}
+	e, err := enc.factory(extra)
+	return e, enc.contentType, err
+}
+
+// MediaTypes maps the media types selected by the content negotiation with the name of the encoding
+// rendering them
+var MediaTypes = map[string]string{
+	"application/json":      JSON,
+	"application/xml":       XML,
+	"text/xml":              XML,
+	"application/yaml":      YAML,
+	"application/x-yaml":    YAML,
+	"text/yaml":             YAML,
+	"application/msgpack":   MSGPACK,
+	"application/x-msgpack": MSGPACK,
+	"application/cbor":      CBOR,
+	"application/x-ndjson":  NDJSON,
+	"application/jsonl":     NDJSON,
+	"text/event-stream":     SSE,
+}
+
+// RegisterMediaType registers the media type rendered by the encoding with the given name, so it can be
+// selected by the content negotiation
+func RegisterMediaType(mediaType, name string) error {
+	MediaTypes[mediaType] = name
+	return nil
+}
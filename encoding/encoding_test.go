@@ -1,6 +1,8 @@
 package encoding
 
 import (
+	"bytes"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -8,7 +10,7 @@ import (
 func TestRegister(t *testing.T) {
 	original := decoders
 
-	if len(decoders) != 1 {
+	if len(decoders) != 9 {
 		t.Error("Unexpected number of registered factories:", len(decoders))
 	}
 
@@ -25,7 +27,7 @@ func TestRegister(t *testing.T) {
 func TestGet(t *testing.T) {
 	original := decoders
 
-	if len(decoders) != 1 {
+	if len(decoders) != 9 {
 		t.Error("Unexpected number of registered factories:", len(decoders))
 	}
 
@@ -57,3 +59,58 @@ func checkDecoder(t *testing.T, name string) {
 		t.Error("Unexpected value:", result["foo"])
 	}
 }
+
+func TestGetConfigured(t *testing.T) {
+	original := configurableDecoders
+	defer func() { configurableDecoders = original }()
+
+	errExpected := errors.New("expected")
+	RegisterConfigurable("some", func(extra map[string]interface{}) (DecoderFactory, error) {
+		if _, ok := extra["fail"]; ok {
+			return nil, errExpected
+		}
+		return NewJSONDecoder, nil
+	})
+
+	for _, name := range []string{"some", JSON, "unknown"} {
+		df, err := GetConfigured(name, map[string]interface{}{})
+		if err != nil {
+			t.Error("Unexpected error:", err.Error())
+			continue
+		}
+		var result map[string]interface{}
+		if err := df(false)(strings.NewReader(`{"foo": "bar"}`), &result); err != nil || result["foo"] != "bar" {
+			t.Error("Unexpected result:", result, err)
+		}
+	}
+
+	if _, err := GetConfigured("some", map[string]interface{}{"fail": true}); err != errExpected {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestGetEncoder(t *testing.T) {
+	original := encoders
+	encoders = map[string]encoderFactory{}
+	defer func() { encoders = original }()
+
+	if _, _, err := GetEncoder("some", nil); err != ErrUnknownEncoder {
+		t.Error("Unexpected error:", err)
+	}
+
+	RegisterEncoder("some", "text/some", func(extra map[string]interface{}) (Encoder, error) {
+		return JSONEncoder, nil
+	})
+	enc, contentType, err := GetEncoder("some", nil)
+	if err != nil {
+		t.Error("Unexpected error:", err.Error())
+		return
+	}
+	if contentType != "text/some" {
+		t.Error("Unexpected content type:", contentType)
+	}
+	buf := new(bytes.Buffer)
+	if err := enc(buf, map[string]interface{}{"foo": "bar"}); err != nil || buf.String() != `{"foo":"bar"}` {
+		t.Error("Unexpected result:", buf.String(), err)
+	}
+}
@@ -0,0 +1,171 @@
+// Package html provides a decoder extracting the fields of the HTML backend responses with CSS selectors
+package html
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+const (
+	// Name is the key of the html encoding
+	Name = "html"
+	// Namespace is the key to look for the html decoder options in the extra config of the backends
+	Namespace = "github.com/devopsfaith/krakend/encoding/html"
+)
+
+var (
+	// ErrNoFields is the error returned when the extra config does not define any field to extract
+	ErrNoFields = errors.New("html: at least one field is required")
+	// ErrNoItems is the error returned when a collection decoder is requested without an items selector
+	ErrNoItems = errors.New("html: the items selector is required by the collection decoder")
+)
+
+// Register registers the html decoder
+func Register() error {
+	return encoding.RegisterConfigurable(Name, ConfigurableDecoderFactory)
+}
+
+// Field defines how to extract a value from the document
+type Field struct {
+	// Selector is the CSS selector of the elements containing the value
+	Selector string `json:"selector"`
+	// Attr is the attribute to extract. If empty, the text of the element is used
+	Attr string `json:"attr"`
+	// Multiple returns the values of all the matching elements instead of just the first one
+	Multiple bool `json:"multiple"`
+}
+
+// Config is the html decoder options set at the extra config
+type Config struct {
+	// Items is the CSS selector of the elements to decode as the items of a collection. The field
+	// selectors are relative to every item
+	Items string `json:"items"`
+	// Fields maps the keys of the response with the definition of the field to extract
+	Fields map[string]Field `json:"fields"`
+}
+
+// ConfigGetter parses the html decoder options from the extra config. A field can be declared with
+// just its selector
+func ConfigGetter(extra map[string]interface{}) (Config, error) {
+	cfg := Config{Fields: map[string]Field{}}
+	tmp, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, ErrNoFields
+	}
+	if items, ok := tmp["items"].(string); ok {
+		cfg.Items = items
+	}
+	fields, ok := tmp["fields"].(map[string]interface{})
+	if !ok || len(fields) == 0 {
+		return cfg, ErrNoFields
+	}
+	for name, v := range fields {
+		if selector, ok := v.(string); ok {
+			cfg.Fields[name] = Field{Selector: selector}
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return cfg, err
+		}
+		f := Field{}
+		if err := json.Unmarshal(b, &f); err != nil {
+			return cfg, fmt.Errorf("html: invalid definition of the field %s: %s", name, err.Error())
+		}
+		cfg.Fields[name] = f
+	}
+	return cfg, nil
+}
+
+// ConfigurableDecoderFactory implements the encoding.ConfigurableDecoderFactory interface
+func ConfigurableDecoderFactory(extra map[string]interface{}) (encoding.DecoderFactory, error) {
+	cfg, err := ConfigGetter(extra)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecoderFactory(cfg)
+}
+
+// NewDecoderFactory returns a DecoderFactory extracting the configured fields. The collection decoder
+// requires the items selector and returns the items under the 'collection' key
+func NewDecoderFactory(cfg Config) (encoding.DecoderFactory, error) {
+	selectors := []string{}
+	if cfg.Items != "" {
+		selectors = append(selectors, cfg.Items)
+	}
+	for name, f := range cfg.Fields {
+		if f.Selector == "" {
+			return nil, fmt.Errorf("html: the field %s has no selector", name)
+		}
+		selectors = append(selectors, f.Selector)
+	}
+	for _, s := range selectors {
+		if _, err := cascadia.Compile(s); err != nil {
+			return nil, fmt.Errorf("html: invalid selector %s: %s", s, err.Error())
+		}
+	}
+
+	return func(isCollection bool) encoding.Decoder {
+		if isCollection && cfg.Items == "" {
+			return func(_ io.Reader, _ *map[string]interface{}) error {
+				return ErrNoItems
+			}
+		}
+		return func(r io.Reader, v *map[string]interface{}) error {
+			doc, err := goquery.NewDocumentFromReader(r)
+			if err != nil {
+				return err
+			}
+			if cfg.Items == "" {
+				*(v) = extractFields(doc.Selection, cfg.Fields)
+				return nil
+			}
+			collection := []interface{}{}
+			doc.Find(cfg.Items).Each(func(_ int, item *goquery.Selection) {
+				collection = append(collection, extractFields(item, cfg.Fields))
+			})
+			*(v) = map[string]interface{}{"collection": collection}
+			return nil
+		}
+	}, nil
+}
+
+func extractFields(s *goquery.Selection, fields map[string]Field) map[string]interface{} {
+	res := make(map[string]interface{}, len(fields))
+	for name, f := range fields {
+		matches := s.Find(f.Selector)
+		if !f.Multiple {
+			if matches.Length() == 0 {
+				res[name] = nil
+				continue
+			}
+			res[name] = extractValue(matches.First(), f.Attr)
+			continue
+		}
+		values := []interface{}{}
+		matches.Each(func(_ int, m *goquery.Selection) {
+			values = append(values, extractValue(m, f.Attr))
+		})
+		res[name] = values
+	}
+	return res
+}
+
+func extractValue(s *goquery.Selection, attr string) interface{} {
+	if attr == "" {
+		return strings.TrimSpace(s.Text())
+	}
+	v, ok := s.Attr(attr)
+	if !ok {
+		return nil
+	}
+	return v
+}
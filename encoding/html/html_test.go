@@ -0,0 +1,114 @@
+package html
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+const page = `<html><head><title>Supu</title></head><body>
+	<h1 class="title"> Products </h1>
+	<table>
+		<tr class="row"><td class="name">foo</td><td><a href="/foo">details</a></td></tr>
+		<tr class="row"><td class="name">bar</td><td><a href="/bar">details</a></td></tr>
+	</table>
+</body></html>`
+
+func TestDecoder_fields(t *testing.T) {
+	df, err := ConfigurableDecoderFactory(map[string]interface{}{
+		Namespace: map[string]interface{}{
+			"fields": map[string]interface{}{
+				"title":   "h1.title",
+				"names":   map[string]interface{}{"selector": "td.name", "multiple": true},
+				"link":    map[string]interface{}{"selector": "a", "attr": "href"},
+				"missing": "p.missing",
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var result map[string]interface{}
+	if err := df(false)(strings.NewReader(page), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"title":   "Products",
+		"names":   []interface{}{"foo", "bar"},
+		"link":    "/foo",
+		"missing": nil,
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestDecoder_collection(t *testing.T) {
+	df, err := ConfigurableDecoderFactory(map[string]interface{}{
+		Namespace: map[string]interface{}{
+			"items": "tr.row",
+			"fields": map[string]interface{}{
+				"name": "td.name",
+				"link": map[string]interface{}{"selector": "a", "attr": "href"},
+			},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var result map[string]interface{}
+	if err := df(true)(strings.NewReader(page), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"collection": []interface{}{
+			map[string]interface{}{"name": "foo", "link": "/foo"},
+			map[string]interface{}{"name": "bar", "link": "/bar"},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestConfigurableDecoderFactory_ko(t *testing.T) {
+	for _, extra := range []map[string]interface{}{
+		{},
+		{Namespace: map[string]interface{}{"fields": map[string]interface{}{}}},
+		{Namespace: map[string]interface{}{"fields": map[string]interface{}{"a": "td["}}},
+		{Namespace: map[string]interface{}{"fields": map[string]interface{}{"a": map[string]interface{}{}}}},
+		{Namespace: map[string]interface{}{"fields": map[string]interface{}{"a": 42}}},
+	} {
+		if _, err := ConfigurableDecoderFactory(extra); err == nil {
+			t.Errorf("error expected with the config %v", extra)
+		}
+	}
+
+	df, err := ConfigurableDecoderFactory(map[string]interface{}{
+		Namespace: map[string]interface{}{"fields": map[string]interface{}{"a": "td"}},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var result map[string]interface{}
+	if err := df(true)(strings.NewReader(page), &result); err != ErrNoItems {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := encoding.GetConfigured(Name, map[string]interface{}{}); err != ErrNoFields {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -34,3 +34,13 @@ func JSONCollectionDecoder(r io.Reader, v *map[string]interface{}) error {
 	*(v) = map[string]interface{}{"collection": collection}
 	return nil
 }
+
+// JSONEncoder implements the Encoder interface
+func JSONEncoder(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,363 @@
+package encoding
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MSGPACK is the key for the msgpack encoding
+const MSGPACK = "msgpack"
+
+// maxDecodingDepth is the max number of nested elements accepted by the binary decoders, so a malicious
+// payload can not exhaust the stack
+const maxDecodingDepth = 10000
+
+var (
+	// ErrUnexpectedRoot is the error returned by the binary decoders when the root element is not a map
+	// (or an array, for the collection decoders)
+	ErrUnexpectedRoot = errors.New("unexpected root element")
+	// ErrMaxDepthExceeded is the error returned by the binary decoders when the elements are nested
+	// too deep
+	ErrMaxDepthExceeded = errors.New("max nesting depth exceeded")
+)
+
+// NewMsgPackDecoder return the right MsgPack decoder
+func NewMsgPackDecoder(isCollection bool) Decoder {
+	if isCollection {
+		return MsgPackCollectionDecoder
+	}
+	return MsgPackDecoder
+}
+
+// MsgPackDecoder implements the Decoder interface
+func MsgPackDecoder(r io.Reader, v *map[string]interface{}) error {
+	data, err := readMsgPack(bufio.NewReader(r), 0)
+	if err != nil {
+		return err
+	}
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return ErrUnexpectedRoot
+	}
+	*(v) = m
+	return nil
+}
+
+// MsgPackCollectionDecoder implements the Decoder interface over a collection
+func MsgPackCollectionDecoder(r io.Reader, v *map[string]interface{}) error {
+	data, err := readMsgPack(bufio.NewReader(r), 0)
+	if err != nil {
+		return err
+	}
+	collection, ok := data.([]interface{})
+	if !ok {
+		return ErrUnexpectedRoot
+	}
+	*(v) = map[string]interface{}{"collection": collection}
+	return nil
+}
+
+// MsgPackEncoder implements the Encoder interface
+func MsgPackEncoder(w io.Writer, v interface{}) error {
+	bw := bufio.NewWriter(w)
+	if err := writeMsgPack(bw, v); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeMsgPack(w *bufio.Writer, v interface{}) error {
+	switch t := v.(type) {
+	case nil:
+		return w.WriteByte(0xc0)
+	case bool:
+		if t {
+			return w.WriteByte(0xc3)
+		}
+		return w.WriteByte(0xc2)
+	case string:
+		writeMsgPackLength(w, len(t), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		_, err := w.WriteString(t)
+		return err
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return writeMsgPackInt(w, i)
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return err
+		}
+		return writeMsgPackFloat(w, f)
+	case int:
+		return writeMsgPackInt(w, int64(t))
+	case int64:
+		return writeMsgPackInt(w, t)
+	case int32:
+		return writeMsgPackInt(w, int64(t))
+	case float64:
+		return writeMsgPackFloat(w, t)
+	case float32:
+		return writeMsgPackFloat(w, float64(t))
+	case []interface{}:
+		writeMsgPackLength(w, len(t), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, e := range t {
+			if err := writeMsgPack(w, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		writeMsgPackLength(w, len(t), 0x80, 16, 0, 0xde, 0xdf)
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := writeMsgPack(w, k); err != nil {
+				return err
+			}
+			if err := writeMsgPack(w, t[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// any other type is normalized through its JSON representation
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return err
+	}
+	return writeMsgPack(w, normalized)
+}
+
+// writeMsgPackLength writes the header of a string, array or map with the received length. If the
+// length is lower than fixMax, the fix format is used. A zero code8 means there is no 8 bits format
+func writeMsgPackLength(w *bufio.Writer, l int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case l < fixMax:
+		w.WriteByte(fix | byte(l))
+	case code8 != 0 && l <= math.MaxUint8:
+		w.WriteByte(code8)
+		w.WriteByte(byte(l))
+	case l <= math.MaxUint16:
+		w.WriteByte(code16)
+		binary.Write(w, binary.BigEndian, uint16(l))
+	default:
+		w.WriteByte(code32)
+		binary.Write(w, binary.BigEndian, uint32(l))
+	}
+}
+
+func writeMsgPackInt(w *bufio.Writer, i int64) error {
+	switch {
+	case i >= 0 && i < 128:
+		return w.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		return w.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		w.WriteByte(0xd0)
+		return w.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		w.WriteByte(0xd1)
+		return binary.Write(w, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		w.WriteByte(0xd2)
+		return binary.Write(w, binary.BigEndian, int32(i))
+	}
+	w.WriteByte(0xd3)
+	return binary.Write(w, binary.BigEndian, i)
+}
+
+func writeMsgPackFloat(w *bufio.Writer, f float64) error {
+	w.WriteByte(0xcb)
+	return binary.Write(w, binary.BigEndian, math.Float64bits(f))
+}
+
+func readMsgPack(r *bufio.Reader, depth int) (interface{}, error) {
+	if depth > maxDecodingDepth {
+		return nil, ErrMaxDepthExceeded
+	}
+	code, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code <= 0x7f:
+		return json.Number(strconv.Itoa(int(code))), nil
+	case code >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(code)))), nil
+	case code >= 0xa0 && code <= 0xbf:
+		return readString(r, uint64(code&0x1f))
+	case code >= 0x90 && code <= 0x9f:
+		return readMsgPackArray(r, uint64(code&0x0f), depth)
+	case code >= 0x80 && code <= 0x8f:
+		return readMsgPackMap(r, uint64(code&0x0f), depth)
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9:
+		return readMsgPackSized(r, 1, readString)
+	case 0xc5, 0xda:
+		return readMsgPackSized(r, 2, readString)
+	case 0xc6, 0xdb:
+		return readMsgPackSized(r, 4, readString)
+	case 0xca:
+		n, err := readUint(r, 4)
+		return numberFromFloat(float64(math.Float32frombits(uint32(n)))), err
+	case 0xcb:
+		n, err := readUint(r, 8)
+		return numberFromFloat(math.Float64frombits(n)), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readUint(r, 1<<(code-0xcc))
+		return json.Number(strconv.FormatUint(n, 10)), err
+	case 0xd0:
+		n, err := readUint(r, 1)
+		return json.Number(strconv.FormatInt(int64(int8(n)), 10)), err
+	case 0xd1:
+		n, err := readUint(r, 2)
+		return json.Number(strconv.FormatInt(int64(int16(n)), 10)), err
+	case 0xd2:
+		n, err := readUint(r, 4)
+		return json.Number(strconv.FormatInt(int64(int32(n)), 10)), err
+	case 0xd3:
+		n, err := readUint(r, 8)
+		return json.Number(strconv.FormatInt(int64(n), 10)), err
+	case 0xdc, 0xdd:
+		l, err := readUint(r, 2<<(code-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackArray(r, l, depth)
+	case 0xde, 0xdf:
+		l, err := readUint(r, 2<<(code-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackMap(r, l, depth)
+	case 0xd6:
+		return readMsgPackExt(r, 4)
+	case 0xd7:
+		return readMsgPackExt(r, 8)
+	case 0xc7:
+		l, err := readUint(r, 1)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackExt(r, l)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%x", code)
+}
+
+func readMsgPackSized(r *bufio.Reader, size int, f func(*bufio.Reader, uint64) (interface{}, error)) (interface{}, error) {
+	l, err := readUint(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return f(r, l)
+}
+
+func readMsgPackArray(r *bufio.Reader, l uint64, depth int) (interface{}, error) {
+	res := []interface{}{}
+	for i := uint64(0); i < l; i++ {
+		v, err := readMsgPack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func readMsgPackMap(r *bufio.Reader, l uint64, depth int) (interface{}, error) {
+	res := map[string]interface{}{}
+	for i := uint64(0); i < l; i++ {
+		k, err := readMsgPack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readMsgPack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		res[mapKey(k)] = v
+	}
+	return res, nil
+}
+
+// readMsgPackExt only supports the timestamp extension type (-1) with 32 and 64 bits
+func readMsgPackExt(r *bufio.Reader, l uint64) (interface{}, error) {
+	extType, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if int8(extType) != -1 || (l != 4 && l != 8) {
+		return nil, fmt.Errorf("msgpack: unsupported extension type %d", int8(extType))
+	}
+	n, err := readUint(r, int(l))
+	if err != nil {
+		return nil, err
+	}
+	if l == 4 {
+		return time.Unix(int64(n), 0).UTC().Format(time.RFC3339Nano), nil
+	}
+	return time.Unix(int64(n&0x3ffffffff), int64(n>>34)).UTC().Format(time.RFC3339Nano), nil
+}
+
+// readString reads l bytes from the reader without preallocating them, so a malformed length
+// can not exhaust the memory
+func readString(r *bufio.Reader, l uint64) (interface{}, error) {
+	if l > math.MaxInt64 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	buf := new(bytes.Buffer)
+	if _, err := io.CopyN(buf, r, int64(l)); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.String(), nil
+}
+
+func readUint(r *bufio.Reader, size int) (uint64, error) {
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(r, b[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// numberFromFloat returns the finite floats as json numbers, so they are handled like the ones
+// decoded by the JSON decoder
+func numberFromFloat(f float64) interface{} {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return f
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+func mapKey(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	return fmt.Sprint(k)
+}
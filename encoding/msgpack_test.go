@@ -0,0 +1,133 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMsgPackEncoder(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		in       interface{}
+		expected []byte
+	}{
+		{"nil", nil, []byte{0xc0}},
+		{"bool", []interface{}{true, false}, []byte{0x92, 0xc3, 0xc2}},
+		{"fixint", json.Number("42"), []byte{0x2a}},
+		{"negative fixint", -3, []byte{0xfd}},
+		{"int8", -100, []byte{0xd0, 0x9c}},
+		{"int16", 1000, []byte{0xd1, 0x03, 0xe8}},
+		{"int32", int64(100000), []byte{0xd2, 0x00, 0x01, 0x86, 0xa0}},
+		{"float", json.Number("1.5"), []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"fixstr", "abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{"str8", strings.Repeat("a", 40), append([]byte{0xd9, 40}, []byte(strings.Repeat("a", 40))...)},
+		{"map", map[string]interface{}{"b": 1, "a": "x"}, []byte{0x82, 0xa1, 'a', 0xa1, 'x', 0xa1, 'b', 0x01}},
+		{"other types", []string{"a"}, []byte{0x91, 0xa1, 'a'}},
+	} {
+		buf := new(bytes.Buffer)
+		if err := MsgPackEncoder(buf, tc.in); err != nil {
+			t.Errorf("%s: %s", tc.name, err.Error())
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), tc.expected) {
+			t.Errorf("%s: unexpected result: %x", tc.name, buf.Bytes())
+		}
+	}
+}
+
+func TestMsgPackDecoder(t *testing.T) {
+	data := map[string]interface{}{
+		"supu":  json.Number("42"),
+		"neg":   json.Number("-100000"),
+		"float": json.Number("1.5"),
+		"tupu":  true,
+		"none":  nil,
+		"str":   strings.Repeat("a", 300),
+		"items": []interface{}{map[string]interface{}{"id": json.Number("1")}, "b"},
+	}
+	buf := new(bytes.Buffer)
+	if err := MsgPackEncoder(buf, data); err != nil {
+		t.Error(err)
+		return
+	}
+	var result map[string]interface{}
+	if err := NewMsgPackDecoder(false)(buf, &result); err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(result, data) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestMsgPackDecoder_types(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		in       []byte
+		expected interface{}
+	}{
+		{"uint64", []byte{0x81, 0xa1, 'a', 0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, json.Number("18446744073709551615")},
+		{"float32", []byte{0x81, 0xa1, 'a', 0xca, 0x3f, 0xc0, 0, 0}, json.Number("1.5")},
+		{"bin8", []byte{0x81, 0xa1, 'a', 0xc4, 0x02, 'h', 'i'}, "hi"},
+		{"timestamp32", []byte{0x81, 0xa1, 'a', 0xd6, 0xff, 0, 0, 0, 0x0a}, "1970-01-01T00:00:10Z"},
+		{"int key", []byte{0x81, 0x01, 0xc3}, nil},
+	} {
+		var result map[string]interface{}
+		if err := MsgPackDecoder(bytes.NewReader(tc.in), &result); err != nil {
+			t.Errorf("%s: %s", tc.name, err.Error())
+			continue
+		}
+		if tc.name == "int key" {
+			if result["1"] != true {
+				t.Errorf("%s: unexpected result: %v", tc.name, result)
+			}
+			continue
+		}
+		if result["a"] != tc.expected {
+			t.Errorf("%s: unexpected result: %v", tc.name, result)
+		}
+	}
+}
+
+func TestMsgPackCollectionDecoder(t *testing.T) {
+	var result map[string]interface{}
+	if err := NewMsgPackDecoder(true)(bytes.NewReader([]byte{0x92, 0x01, 0xa1, 'a'}), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"collection": []interface{}{json.Number("1"), "a"}}) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestMsgPackDecoder_ko(t *testing.T) {
+	for name, in := range map[string][]byte{
+		"empty":         {},
+		"truncated":     {0x81, 0xa3, 'a'},
+		"huge string":   {0x81, 0xa1, 'a', 0xdb, 0xff, 0xff, 0xff, 0xff, 'a'},
+		"unexpected":    {0x91, 0x01},
+		"unknown ext":   {0x81, 0xa1, 'a', 0xd6, 0x01, 0, 0, 0, 0},
+		"reserved code": {0xc1},
+	} {
+		var result map[string]interface{}
+		if err := MsgPackDecoder(bytes.NewReader(in), &result); err == nil {
+			t.Errorf("%s: error expected", name)
+		}
+	}
+	var result map[string]interface{}
+	if err := MsgPackCollectionDecoder(bytes.NewReader([]byte{0x80}), &result); err != ErrUnexpectedRoot {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMsgPackDecoder_tooDeep(t *testing.T) {
+	in := append([]byte{0x81, 0xa1, 'a'}, bytes.Repeat([]byte{0x91}, 1000000)...)
+	in = append(in, 0x01)
+	var result map[string]interface{}
+	if err := MsgPackDecoder(bytes.NewReader(in), &result); err != ErrMaxDepthExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package encoding
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// NDJSON is the key for the newline delimited json encoding (also known as JSON Lines)
+const NDJSON = "ndjson"
+
+// NewNDJSONDecoder return the NDJSON decoder. Since the payload is a sequence of values, both the entity
+// and the collection decoders return them under the 'collection' key
+func NewNDJSONDecoder(_ bool) Decoder {
+	return NDJSONDecoder
+}
+
+// NDJSONDecoder implements the Decoder interface. The values are decoded one by one while reading
+// the stream, so the whole body is never buffered
+func NDJSONDecoder(r io.Reader, v *map[string]interface{}) error {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	collection := []interface{}{}
+	for {
+		var item interface{}
+		err := d.Decode(&item)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		collection = append(collection, item)
+	}
+	*(v) = map[string]interface{}{"collection": collection}
+	return nil
+}
+
+// NDJSONEncoder implements the Encoder interface. If the value is an object with a single 'collection'
+// array, every item is written in its own line. Any other value is written as a single line
+func NDJSONEncoder(w io.Writer, v interface{}) error {
+	items := []interface{}{v}
+	if m, ok := v.(map[string]interface{}); ok && len(m) == 1 {
+		if collection, ok := m["collection"].([]interface{}); ok {
+			items = collection
+		}
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
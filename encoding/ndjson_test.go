@@ -0,0 +1,67 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNDJSONDecoder(t *testing.T) {
+	input := "{\"id\":1}\n{\"id\":2}\n\n[1,2]\n\"supu\"\n"
+	expected := map[string]interface{}{
+		"collection": []interface{}{
+			map[string]interface{}{"id": json.Number("1")},
+			map[string]interface{}{"id": json.Number("2")},
+			[]interface{}{json.Number("1"), json.Number("2")},
+			"supu",
+		},
+	}
+	for _, isCollection := range []bool{true, false} {
+		var result map[string]interface{}
+		if err := NewNDJSONDecoder(isCollection)(strings.NewReader(input), &result); err != nil {
+			t.Error(err)
+			continue
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("unexpected result: %v", result)
+		}
+	}
+}
+
+func TestNDJSONDecoder_ko(t *testing.T) {
+	var result map[string]interface{}
+	if err := NDJSONDecoder(strings.NewReader("{\"id\":1}\n{\"id\":"), &result); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestNDJSONEncoder(t *testing.T) {
+	for _, tc := range []struct {
+		in       interface{}
+		expected string
+	}{
+		{
+			map[string]interface{}{"collection": []interface{}{map[string]interface{}{"id": 1}, "a"}},
+			"{\"id\":1}\n\"a\"\n",
+		},
+		{
+			map[string]interface{}{"collection": []interface{}{}, "total": 0},
+			"{\"collection\":[],\"total\":0}\n",
+		},
+		{
+			map[string]interface{}{"supu": "tupu"},
+			"{\"supu\":\"tupu\"}\n",
+		},
+	} {
+		buf := new(bytes.Buffer)
+		if err := NDJSONEncoder(buf, tc.in); err != nil {
+			t.Error(err)
+			continue
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("unexpected result: %s", buf.String())
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package encoding
+
+import "io"
+
+// NOOP is the key for the NoOp encoding. The responses of the backends using it are not decoded, so
+// their bodies can be sent to the client untouched
+const NOOP = "no-op"
+
+// NewNoOpDecoder returns the NoOp decoder
+func NewNoOpDecoder(_ bool) Decoder {
+	return NoOpDecoder
+}
+
+// NoOpDecoder implements the Decoder interface without consuming the reader
+func NoOpDecoder(_ io.Reader, _ *map[string]interface{}) error {
+	return nil
+}
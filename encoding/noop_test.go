@@ -0,0 +1,20 @@
+package encoding
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNoOpDecoder(t *testing.T) {
+	r := strings.NewReader("supu")
+	var result map[string]interface{}
+	if err := NewNoOpDecoder(false)(r, &result); err != nil {
+		t.Error(err)
+	}
+	if result != nil {
+		t.Errorf("unexpected result: %v", result)
+	}
+	if r.Len() != 4 {
+		t.Error("the reader should not be consumed")
+	}
+}
@@ -0,0 +1,229 @@
+// Package protobuf provides a decoder and an encoder for protobuf payloads, using the message
+// descriptors compiled into the binary or loaded from descriptor set files
+package protobuf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+const (
+	// Name is the key of the protobuf encoding
+	Name = "protobuf"
+	// Namespace is the key to look for the protobuf options in the extra config of the backends
+	// and the endpoints
+	Namespace = "github.com/devopsfaith/krakend/encoding/protobuf"
+	// ContentType is the content type of the protobuf responses
+	ContentType = "application/x-protobuf"
+)
+
+// ErrNoMessage is the error returned when the extra config does not define the message to use
+var ErrNoMessage = errors.New("protobuf: the message type is required")
+
+// Register registers the protobuf decoder and encoder and adds the protobuf media types
+// to the ones accepted by the content negotiation
+func Register() error {
+	for _, mediaType := range []string{ContentType, "application/protobuf"} {
+		if err := encoding.RegisterMediaType(mediaType, Name); err != nil {
+			return err
+		}
+	}
+	if err := encoding.RegisterConfigurable(Name, ConfigurableDecoderFactory); err != nil {
+		return err
+	}
+	return encoding.RegisterEncoder(Name, ContentType, EncoderFactory)
+}
+
+// Config is the protobuf options set at the extra config
+type Config struct {
+	// Message is the full name of the message type
+	Message string `json:"message"`
+	// DescriptorSets is the list of descriptor set files (as generated by protoc --descriptor_set_out)
+	// to look for the message type. If empty, the descriptors compiled into the binary are used
+	DescriptorSets []string `json:"descriptor_sets"`
+}
+
+// ConfigGetter parses the protobuf options from the extra config
+func ConfigGetter(extra map[string]interface{}) (Config, error) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, ErrNoMessage
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.Message == "" {
+		return cfg, ErrNoMessage
+	}
+	return cfg, nil
+}
+
+// ConfigurableDecoderFactory implements the encoding.ConfigurableDecoderFactory interface
+func ConfigurableDecoderFactory(extra map[string]interface{}) (encoding.DecoderFactory, error) {
+	cfg, err := ConfigGetter(extra)
+	if err != nil {
+		return nil, err
+	}
+	md, err := MessageDescriptor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecoderFactory(md), nil
+}
+
+// EncoderFactory implements the encoding.EncoderFactory interface
+func EncoderFactory(extra map[string]interface{}) (encoding.Encoder, error) {
+	cfg, err := ConfigGetter(extra)
+	if err != nil {
+		return nil, err
+	}
+	md, err := MessageDescriptor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncoder(md), nil
+}
+
+// Files returns the registry of the file descriptors declared in the received descriptor set files
+// (as generated by protoc --descriptor_set_out). If there are no files, the registry of the descriptors
+// compiled into the binary is returned
+func Files(descriptorSets []string) (*protoregistry.Files, error) {
+	if len(descriptorSets) == 0 {
+		return protoregistry.GlobalFiles, nil
+	}
+	fds := &descriptorpb.FileDescriptorSet{}
+	for _, path := range descriptorSets {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		set := &descriptorpb.FileDescriptorSet{}
+		if err := proto.Unmarshal(b, set); err != nil {
+			return nil, fmt.Errorf("protobuf: parsing the descriptor set %s: %s", path, err.Error())
+		}
+		fds.File = append(fds.File, set.File...)
+	}
+	return protodesc.NewFiles(fds)
+}
+
+// MessageDescriptor looks for the descriptor of the configured message type
+func MessageDescriptor(cfg Config) (protoreflect.MessageDescriptor, error) {
+	resolver, err := Files(cfg.DescriptorSets)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := resolver.FindDescriptorByName(protoreflect.FullName(cfg.Message))
+	if err != nil {
+		return nil, fmt.Errorf("protobuf: looking for the message %s: %s", cfg.Message, err.Error())
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %s is not a message", cfg.Message)
+	}
+	return md, nil
+}
+
+// NewDecoderFactory returns a DecoderFactory for the received message type. The collection decoder
+// expects a stream of varint length-delimited messages
+func NewDecoderFactory(md protoreflect.MessageDescriptor) encoding.DecoderFactory {
+	return func(isCollection bool) encoding.Decoder {
+		if isCollection {
+			return func(r io.Reader, v *map[string]interface{}) error {
+				return decodeCollection(r, v, md)
+			}
+		}
+		return func(r io.Reader, v *map[string]interface{}) error {
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			return decodeMessage(b, v, md)
+		}
+	}
+}
+
+// NewEncoder returns an Encoder serializing the response data as the received message type.
+// Fields not defined in the message are discarded
+func NewEncoder(md protoreflect.MessageDescriptor) encoding.Encoder {
+	return func(w io.Writer, v interface{}) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		msg := dynamicpb.NewMessage(md)
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(b, msg); err != nil {
+			return err
+		}
+		b, err = proto.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+}
+
+func decodeMessage(b []byte, v *map[string]interface{}, md protoreflect.MessageDescriptor) error {
+	msg := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return err
+	}
+	return toMap(msg, v)
+}
+
+func decodeCollection(r io.Reader, v *map[string]interface{}, md protoreflect.MessageDescriptor) error {
+	br := bufio.NewReader(r)
+	collection := []interface{}{}
+	for {
+		size, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		buf := new(bytes.Buffer)
+		if _, err := io.CopyN(buf, br, int64(size)); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		var item map[string]interface{}
+		if err := decodeMessage(buf.Bytes(), &item, md); err != nil {
+			return err
+		}
+		collection = append(collection, item)
+	}
+	*(v) = map[string]interface{}{"collection": collection}
+	return nil
+}
+
+// toMap converts the message into a generic map through its canonical JSON mapping, keeping
+// the field names declared in the proto file
+func toMap(msg proto.Message, v *map[string]interface{}) error {
+	b, err := (protojson.MarshalOptions{UseProtoNames: true}).Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return encoding.JSONDecoder(bytes.NewReader(b), v)
+}
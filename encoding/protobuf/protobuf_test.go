@@ -0,0 +1,193 @@
+package protobuf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+func testFileDescriptorSet() *descriptorpb.FileDescriptorSet {
+	return &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("user.proto"),
+				Package: proto.String("test"),
+				Syntax:  proto.String("proto3"),
+				MessageType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("User"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							{
+								Name:     proto.String("user_name"),
+								JsonName: proto.String("userName"),
+								Number:   proto.Int32(1),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+							},
+							{
+								Name:     proto.String("age"),
+								JsonName: proto.String("age"),
+								Number:   proto.Int32(2),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func testDescriptorSetFile(t *testing.T) string {
+	b, err := proto.Marshal(testFileDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "krakend_protobuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(b)
+	f.Close()
+	return f.Name()
+}
+
+func testUserDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	files, err := protodesc.NewFiles(testFileDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := files.FindDescriptorByName("test.User")
+	return d.(protoreflect.MessageDescriptor)
+}
+
+func testUser(md protoreflect.MessageDescriptor, name string, age int32) *dynamicpb.Message {
+	msg := dynamicpb.NewMessage(md)
+	msg.Set(md.Fields().ByName("user_name"), protoreflect.ValueOfString(name))
+	msg.Set(md.Fields().ByName("age"), protoreflect.ValueOfInt32(age))
+	return msg
+}
+
+func testMessage(t *testing.T, name string, age int32) []byte {
+	b, err := proto.Marshal(testUser(testUserDescriptor(t), name, age))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestConfigurableDecoderFactory(t *testing.T) {
+	path := testDescriptorSetFile(t)
+	defer os.Remove(path)
+
+	extra := map[string]interface{}{
+		Namespace: map[string]interface{}{
+			"message":         "test.User",
+			"descriptor_sets": []interface{}{path},
+		},
+	}
+	df, err := ConfigurableDecoderFactory(extra)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var result map[string]interface{}
+	if err := df(false)(bytes.NewReader(testMessage(t, "supu", 42)), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{"user_name": "supu", "age": json.Number("42")}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	stream := new(bytes.Buffer)
+	for _, msg := range [][]byte{testMessage(t, "supu", 1), testMessage(t, "tupu", 2)} {
+		size := make([]byte, binary.MaxVarintLen64)
+		stream.Write(size[:binary.PutUvarint(size, uint64(len(msg)))])
+		stream.Write(msg)
+	}
+	if err := df(true)(stream, &result); err != nil {
+		t.Error(err)
+		return
+	}
+	collection, ok := result["collection"].([]interface{})
+	if !ok || len(collection) != 2 {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestEncoderFactory(t *testing.T) {
+	path := testDescriptorSetFile(t)
+	defer os.Remove(path)
+
+	extra := map[string]interface{}{
+		Namespace: map[string]interface{}{
+			"message":         "test.User",
+			"descriptor_sets": []interface{}{path},
+		},
+	}
+	enc, err := EncoderFactory(extra)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	buf := new(bytes.Buffer)
+	data := map[string]interface{}{"user_name": "supu", "age": json.Number("42"), "unknown": true}
+	if err := enc(buf, data); err != nil {
+		t.Error(err)
+		return
+	}
+	// the marshaled bytes are not deterministic, so the decoded messages are compared
+	md := testUserDescriptor(t)
+	msg := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(buf.Bytes(), msg); err != nil {
+		t.Error(err)
+		return
+	}
+	if !proto.Equal(msg, testUser(md, "supu", 42)) {
+		t.Errorf("unexpected result: %x", buf.Bytes())
+	}
+}
+
+func TestConfigGetter_ko(t *testing.T) {
+	for _, extra := range []map[string]interface{}{
+		{},
+		{Namespace: map[string]interface{}{}},
+	} {
+		if _, err := ConfigGetter(extra); err != ErrNoMessage {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	extra := map[string]interface{}{
+		Namespace: map[string]interface{}{"message": "test.Unknown"},
+	}
+	if _, err := ConfigurableDecoderFactory(extra); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, _, err := encoding.GetEncoder(Name, map[string]interface{}{}); err != ErrNoMessage {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// RSS is the key for the feed encoding. It accepts RSS 1.0, RSS 2.0 and Atom feeds
+const RSS = "rss"
+
+// NewRSSDecoder return the right feed decoder. The entity decoder returns the title, link and
+// description of the feed with its items under the 'items' key, while the collection decoder
+// returns just the items under the 'collection' key
+func NewRSSDecoder(isCollection bool) Decoder {
+	if isCollection {
+		return RSSCollectionDecoder
+	}
+	return RSSDecoder
+}
+
+// RSSDecoder implements the Decoder interface
+func RSSDecoder(r io.Reader, v *map[string]interface{}) error {
+	feed, err := decodeFeed(r)
+	if err != nil {
+		return err
+	}
+	*(v) = map[string]interface{}{
+		"title":       feed.title(),
+		"link":        feed.link(),
+		"description": feed.description(),
+		"items":       feed.normalizedItems(),
+	}
+	return nil
+}
+
+// RSSCollectionDecoder implements the Decoder interface over the items of the feed
+func RSSCollectionDecoder(r io.Reader, v *map[string]interface{}) error {
+	feed, err := decodeFeed(r)
+	if err != nil {
+		return err
+	}
+	*(v) = map[string]interface{}{"collection": feed.normalizedItems()}
+	return nil
+}
+
+// feedTimeLayouts are the date formats found in the wild, tried in order
+var feedTimeLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+type feedItem struct {
+	Title       string     `xml:"title"`
+	Links       []feedLink `xml:"link"`
+	PubDate     string     `xml:"pubDate"`
+	Published   string     `xml:"published"`
+	Date        string     `xml:"date"`
+	Updated     string     `xml:"updated"`
+	Description string     `xml:"description"`
+	Summary     string     `xml:"summary"`
+	Content     string     `xml:"content"`
+}
+
+type feedChannel struct {
+	Title       string     `xml:"title"`
+	Links       []feedLink `xml:"link"`
+	Description string     `xml:"description"`
+	Items       []feedItem `xml:"item"`
+}
+
+// feed maps the three formats: RSS 2.0 items are children of the channel, while RSS 1.0 items
+// and Atom entries are children of the root element
+type feed struct {
+	XMLName  xml.Name
+	Title    string       `xml:"title"`
+	Subtitle string       `xml:"subtitle"`
+	Links    []feedLink   `xml:"link"`
+	Channel  *feedChannel `xml:"channel"`
+	Items    []feedItem   `xml:"item"`
+	Entries  []feedItem   `xml:"entry"`
+}
+
+func decodeFeed(r io.Reader) (*feed, error) {
+	d := xml.NewDecoder(r)
+	d.CharsetReader = feedCharsetReader
+	f := &feed{}
+	if err := d.Decode(f); err != nil {
+		return nil, err
+	}
+	switch f.XMLName.Local {
+	case "rss", "RDF", "feed":
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown feed format: %s", f.XMLName.Local)
+}
+
+func (f *feed) title() string {
+	if f.Channel != nil {
+		return strings.TrimSpace(f.Channel.Title)
+	}
+	return strings.TrimSpace(f.Title)
+}
+
+func (f *feed) link() string {
+	if f.Channel != nil {
+		return feedLinkValue(f.Channel.Links)
+	}
+	return feedLinkValue(f.Links)
+}
+
+func (f *feed) description() string {
+	if f.Channel != nil {
+		return strings.TrimSpace(f.Channel.Description)
+	}
+	return strings.TrimSpace(f.Subtitle)
+}
+
+func (f *feed) normalizedItems() []interface{} {
+	items := append(append([]feedItem{}, f.Items...), f.Entries...)
+	if f.Channel != nil {
+		items = append(items, f.Channel.Items...)
+	}
+	res := make([]interface{}, len(items))
+	for i, item := range items {
+		res[i] = map[string]interface{}{
+			"title":     strings.TrimSpace(item.Title),
+			"link":      feedLinkValue(item.Links),
+			"published": feedTime(firstNonEmpty(item.PubDate, item.Published, item.Date, item.Updated)),
+			"summary":   firstNonEmpty(item.Description, item.Summary, item.Content),
+		}
+	}
+	return res
+}
+
+// feedLinkValue returns the first alternate link, either declared as text (RSS) or as the
+// href attribute (Atom)
+func feedLinkValue(links []feedLink) string {
+	for _, l := range links {
+		if l.Rel != "" && l.Rel != "alternate" {
+			continue
+		}
+		if v := firstNonEmpty(l.Href, l.Text); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// feedTime normalizes the received date to RFC3339. Unknown formats are returned untouched
+func feedTime(s string) string {
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return s
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// feedCharsetReader adds support for the latin1 encoded feeds
+func feedCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "latin-1":
+	default:
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+	b, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(b)))
+	for _, c := range b {
+		if c < utf8.RuneSelf {
+			buf.WriteByte(c)
+			continue
+		}
+		buf.WriteRune(rune(c))
+	}
+	return buf, nil
+}
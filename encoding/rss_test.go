@@ -0,0 +1,137 @@
+package encoding
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRSSDecoder_rss2(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+	<channel>
+		<title>Supu feed</title>
+		<atom:link href="http://example.com/feed.xml" rel="self"/>
+		<link>http://example.com/</link>
+		<description>the supu news</description>
+		<item>
+			<title>First</title>
+			<link>http://example.com/1</link>
+			<pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate>
+			<description><![CDATA[<p>first post</p>]]></description>
+		</item>
+		<item>
+			<title>Second</title>
+			<link>http://example.com/2</link>
+			<pubDate>not a date</pubDate>
+		</item>
+	</channel>
+</rss>`
+	var result map[string]interface{}
+	if err := NewRSSDecoder(false)(strings.NewReader(input), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"title":       "Supu feed",
+		"link":        "http://example.com/",
+		"description": "the supu news",
+		"items": []interface{}{
+			map[string]interface{}{
+				"title":     "First",
+				"link":      "http://example.com/1",
+				"published": "2006-01-02T15:04:05Z",
+				"summary":   "<p>first post</p>",
+			},
+			map[string]interface{}{
+				"title":     "Second",
+				"link":      "http://example.com/2",
+				"published": "not a date",
+				"summary":   "",
+			},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestRSSDecoder_atom(t *testing.T) {
+	input := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Tupu feed</title>
+	<subtitle>the tupu news</subtitle>
+	<link href="http://example.com/feed" rel="self"/>
+	<link href="http://example.com/"/>
+	<entry>
+		<title>Atom entry</title>
+		<link rel="alternate" href="http://example.com/atom"/>
+		<updated>2006-01-03T10:00:00Z</updated>
+		<published>2006-01-02T10:00:00+02:00</published>
+		<summary>an entry</summary>
+	</entry>
+</feed>`
+	var result map[string]interface{}
+	if err := NewRSSDecoder(true)(strings.NewReader(input), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"collection": []interface{}{
+			map[string]interface{}{
+				"title":     "Atom entry",
+				"link":      "http://example.com/atom",
+				"published": "2006-01-02T10:00:00+02:00",
+				"summary":   "an entry",
+			},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	if err := NewRSSDecoder(false)(strings.NewReader(input), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	if result["title"] != "Tupu feed" || result["link"] != "http://example.com/" || result["description"] != "the tupu news" {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestRSSDecoder_rdf(t *testing.T) {
+	input := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">` +
+		`<channel><title>Caf` + "\xe9" + `</title><link>http://example.com/</link></channel>` +
+		`<item><title>RDF item</title><link>http://example.com/rdf</link><dc:date>2006-01-02</dc:date></item>` +
+		`</rdf:RDF>`
+	var result map[string]interface{}
+	if err := NewRSSDecoder(false)(strings.NewReader(input), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	if result["title"] != "Café" {
+		t.Errorf("unexpected title: %v", result["title"])
+	}
+	items := result["items"].([]interface{})
+	if len(items) != 1 {
+		t.Errorf("unexpected items: %v", items)
+		return
+	}
+	if item := items[0].(map[string]interface{}); item["published"] != "2006-01-02T00:00:00Z" || item["link"] != "http://example.com/rdf" {
+		t.Errorf("unexpected item: %v", item)
+	}
+}
+
+func TestRSSDecoder_ko(t *testing.T) {
+	for _, input := range []string{
+		"<html><body/></html>",
+		"<rss><channel>",
+		"<?xml version=\"1.0\" encoding=\"shift_jis\"?><rss/>",
+	} {
+		var result map[string]interface{}
+		if err := NewRSSDecoder(true)(strings.NewReader(input), &result); err == nil {
+			t.Errorf("error expected decoding %s", input)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package encoding
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// SSE is the key for the server-sent events encoding (text/event-stream)
+const SSE = "sse"
+
+// SSEEvent is an event of a server-sent events stream
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry string
+}
+
+// Bytes returns the event encoded as a block of fields ended by a blank line
+func (e SSEEvent) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	if e.ID != "" {
+		buf.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		buf.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry != "" {
+		buf.WriteString("retry: " + e.Retry + "\n")
+	}
+	if e.Data != "" || (e.ID == "" && e.Event == "" && e.Retry == "") {
+		for _, line := range strings.Split(e.Data, "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+// SSEReader reads the events of a server-sent events stream, skipping the comments
+type SSEReader struct {
+	r *bufio.Reader
+}
+
+// NewSSEReader returns a reader of the events of the stream
+func NewSSEReader(r io.Reader) *SSEReader {
+	return &SSEReader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next event of the stream. The incomplete event at the end of the stream is discarded
+// and io.EOF is returned
+func (r *SSEReader) Read() (SSEEvent, error) {
+	e := SSEEvent{}
+	data := []string{}
+	pending := false
+	for {
+		line, err := r.r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line != "" {
+				return e, io.ErrUnexpectedEOF
+			}
+			return e, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			if !pending {
+				continue
+			}
+			e.Data = strings.Join(data, "\n")
+			return e, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "id":
+			e.ID = value
+		case "event":
+			e.Event = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			e.Retry = value
+		default:
+			continue
+		}
+		pending = true
+	}
+}
+
+// NewSSEDecoder returns the SSE decoder. Since the payload is a sequence of events, both the entity and the
+// collection decoders return them under the 'collection' key
+func NewSSEDecoder(_ bool) Decoder {
+	return SSEDecoder
+}
+
+// SSEDecoder implements the Decoder interface. Every event is decoded as an object with its 'id', 'event'
+// and 'data' (only when they are not empty). The data is decoded as JSON when possible
+func SSEDecoder(r io.Reader, v *map[string]interface{}) error {
+	reader := NewSSEReader(r)
+	collection := []interface{}{}
+	for {
+		e, err := reader.Read()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		item := map[string]interface{}{}
+		if e.ID != "" {
+			item["id"] = e.ID
+		}
+		if e.Event != "" {
+			item["event"] = e.Event
+		}
+		if e.Data != "" {
+			var data interface{}
+			d := json.NewDecoder(strings.NewReader(e.Data))
+			d.UseNumber()
+			if err := d.Decode(&data); err != nil || d.More() {
+				data = e.Data
+			}
+			item["data"] = data
+		}
+		collection = append(collection, item)
+	}
+	*(v) = map[string]interface{}{"collection": collection}
+	return nil
+}
+
+// SSEEncoder implements the Encoder interface. If the value is an object with a single 'collection' array,
+// every item is written as an event. Any other value is written as a single event. The objects with a
+// 'data' key are written with their 'id' and 'event' (so the decoded events are encoded back) and the rest
+// of the values are written as the JSON data of the event
+func SSEEncoder(w io.Writer, v interface{}) error {
+	items := []interface{}{v}
+	if m, ok := v.(map[string]interface{}); ok && len(m) == 1 {
+		if collection, ok := m["collection"].([]interface{}); ok {
+			items = collection
+		}
+	}
+	bw := bufio.NewWriter(w)
+	for _, item := range items {
+		e := SSEEvent{}
+		if m, ok := item.(map[string]interface{}); ok {
+			if data, ok := m["data"]; ok {
+				e.ID, _ = m["id"].(string)
+				e.Event, _ = m["event"].(string)
+				item = data
+			}
+		}
+		if s, ok := item.(string); ok {
+			e.Data = s
+		} else {
+			b, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			e.Data = string(b)
+		}
+		if _, err := bw.Write(e.Bytes()); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,99 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSSEReader(t *testing.T) {
+	input := ": comment\n\nid: 1\nevent: update\ndata: first\ndata:second\nunknown: field\n\r\n" +
+		"retry: 1000\n\ndata\n\ndata: incomplete"
+	reader := NewSSEReader(strings.NewReader(input))
+	for _, expected := range []SSEEvent{
+		{ID: "1", Event: "update", Data: "first\nsecond"},
+		{Retry: "1000"},
+		{},
+	} {
+		e, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e != expected {
+			t.Errorf("unexpected event: %+v", e)
+		}
+	}
+	if _, err := reader.Read(); err != io.ErrUnexpectedEOF {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := NewSSEReader(strings.NewReader("data: a\n\n")).Read(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSSEEvent_Bytes(t *testing.T) {
+	for _, tc := range []struct {
+		in       SSEEvent
+		expected string
+	}{
+		{SSEEvent{ID: "1", Event: "update", Data: "a\nb"}, "id: 1\nevent: update\ndata: a\ndata: b\n\n"},
+		{SSEEvent{Retry: "10"}, "retry: 10\n\n"},
+		{SSEEvent{}, "data: \n\n"},
+	} {
+		if out := string(tc.in.Bytes()); out != tc.expected {
+			t.Errorf("unexpected output: %q", out)
+		}
+	}
+}
+
+func TestSSEDecoder(t *testing.T) {
+	input := "id: 1\nevent: update\ndata: {\"id\":1}\n\ndata: plain text\n\ndata: {\"id\":1} trailing\n\n"
+	expected := map[string]interface{}{
+		"collection": []interface{}{
+			map[string]interface{}{"id": "1", "event": "update", "data": map[string]interface{}{"id": json.Number("1")}},
+			map[string]interface{}{"data": "plain text"},
+			map[string]interface{}{"data": "{\"id\":1} trailing"},
+		},
+	}
+	for _, isCollection := range []bool{true, false} {
+		var result map[string]interface{}
+		if err := NewSSEDecoder(isCollection)(strings.NewReader(input), &result); err != nil {
+			t.Error(err)
+			continue
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("unexpected result: %v", result)
+		}
+	}
+}
+
+func TestSSEEncoder(t *testing.T) {
+	for _, tc := range []struct {
+		in       interface{}
+		expected string
+	}{
+		{
+			map[string]interface{}{"collection": []interface{}{
+				map[string]interface{}{"id": "1", "event": "update", "data": map[string]interface{}{"a": 1}},
+				"text",
+			}},
+			"id: 1\nevent: update\ndata: {\"a\":1}\n\ndata: text\n\n",
+		},
+		{
+			map[string]interface{}{"supu": "tupu"},
+			"data: {\"supu\":\"tupu\"}\n\n",
+		},
+	} {
+		buf := new(bytes.Buffer)
+		if err := SSEEncoder(buf, tc.in); err != nil {
+			t.Error(err)
+			continue
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("unexpected output: %q", buf.String())
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package encoding
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// XML is the key for the xml encoding
+const XML = "xml"
+
+const (
+	xmlRootElement = "response"
+	xmlItemElement = "item"
+)
+
+// XMLEncoder implements the Encoder interface. Objects are encoded as elements named after their
+// keys and arrays as a repeated element, everything wrapped by a 'response' root element
+func XMLEncoder(w io.Writer, v interface{}) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	if err := writeXMLElement(bw, xmlRootElement, v); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeXMLElement(w *bufio.Writer, name string, v interface{}) error {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			if err := writeXMLElement(w, name, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		w.WriteString("<" + name + ">")
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := xmlName(k)
+			if nested, ok := t[k].([]interface{}); ok && len(nested) > 0 {
+				if _, isArray := nested[0].([]interface{}); isArray {
+					w.WriteString("<" + child + ">")
+					if err := writeXMLElement(w, xmlItemElement, nested); err != nil {
+						return err
+					}
+					w.WriteString("</" + child + ">")
+					continue
+				}
+			}
+			if err := writeXMLElement(w, child, t[k]); err != nil {
+				return err
+			}
+		}
+		w.WriteString("</" + name + ">")
+		return nil
+	case nil:
+		w.WriteString("<" + name + "/>")
+		return nil
+	}
+
+	w.WriteString("<" + name + ">")
+	if err := xml.EscapeText(w, []byte(xmlScalar(v))); err != nil {
+		return err
+	}
+	w.WriteString("</" + name + ">")
+	return nil
+}
+
+func xmlScalar(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// xmlName sanitizes the received key, so it can be used as an element name
+func xmlName(k string) string {
+	if k == "" {
+		return "_"
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, k)
+	if r := []rune(name)[0]; !unicode.IsLetter(r) && r != '_' {
+		name = "_" + name
+	}
+	return name
+}
+
+// XMLNamespace is the key to look for the xml decoder options in the extra config of the backends
+const XMLNamespace = "github.com/devopsfaith/krakend/encoding/xml"
+
+// XMLOptions defines how the xml decoder maps the elements into the response data
+type XMLOptions struct {
+	// AttributePrefix is the prefix added to the keys storing the attributes of an element
+	AttributePrefix string
+	// TextKey is the key storing the text of the elements with attributes or children
+	TextKey string
+	// IgnoreAttributes drops all the attributes
+	IgnoreAttributes bool
+	// StripNamespaces removes the namespace prefixes from the element and attribute names
+	// and drops the namespace declarations
+	StripNamespaces bool
+}
+
+// DefaultXMLOptions are the options used by the registered xml decoder
+var DefaultXMLOptions = XMLOptions{
+	AttributePrefix: "@",
+	TextKey:         "#text",
+	StripNamespaces: true,
+}
+
+// XMLConfigGetter parses the extra config of the xml decoder. Missing or invalid values are
+// replaced by the defaults
+func XMLConfigGetter(cfg interface{}) XMLOptions {
+	opts := DefaultXMLOptions
+	tmp, ok := cfg.(map[string]interface{})
+	if !ok {
+		return opts
+	}
+	if v, ok := tmp["attribute_prefix"].(string); ok {
+		opts.AttributePrefix = v
+	}
+	if v, ok := tmp["text_key"].(string); ok && v != "" {
+		opts.TextKey = v
+	}
+	if v, ok := tmp["ignore_attributes"].(bool); ok {
+		opts.IgnoreAttributes = v
+	}
+	if v, ok := tmp["strip_namespaces"].(bool); ok {
+		opts.StripNamespaces = v
+	}
+	return opts
+}
+
+func newXMLConfigurableDecoderFactory(extra map[string]interface{}) (DecoderFactory, error) {
+	return NewXMLDecoderFactory(XMLConfigGetter(extra[XMLNamespace])), nil
+}
+
+// NewXMLDecoder return the right XML decoder with the default options
+func NewXMLDecoder(isCollection bool) Decoder {
+	return NewXMLDecoderFactory(DefaultXMLOptions)(isCollection)
+}
+
+// NewXMLDecoderFactory returns a DecoderFactory using the received options
+func NewXMLDecoderFactory(opts XMLOptions) DecoderFactory {
+	return func(isCollection bool) Decoder {
+		if isCollection {
+			return func(r io.Reader, v *map[string]interface{}) error {
+				return xmlCollectionDecoder(r, v, opts)
+			}
+		}
+		return func(r io.Reader, v *map[string]interface{}) error {
+			return xmlDecoder(r, v, opts)
+		}
+	}
+}
+
+// xmlDecoder decodes the content of the root element into the response
+func xmlDecoder(r io.Reader, v *map[string]interface{}, opts XMLOptions) error {
+	d := xml.NewDecoder(r)
+	start, err := xmlRootStart(d)
+	if err != nil {
+		return err
+	}
+	data, err := decodeXMLElement(d, start, opts)
+	if err != nil {
+		return err
+	}
+	if m, ok := data.(map[string]interface{}); ok {
+		*(v) = m
+		return nil
+	}
+	*(v) = map[string]interface{}{opts.TextKey: data}
+	return nil
+}
+
+// xmlCollectionDecoder decodes every child of the root element as an item of the collection
+func xmlCollectionDecoder(r io.Reader, v *map[string]interface{}, opts XMLOptions) error {
+	d := xml.NewDecoder(r)
+	if _, err := xmlRootStart(d); err != nil {
+		return err
+	}
+	collection := []interface{}{}
+	for {
+		token, err := d.RawToken()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			item, err := decodeXMLElement(d, t, opts)
+			if err != nil {
+				return err
+			}
+			collection = append(collection, item)
+		case xml.EndElement:
+			*(v) = map[string]interface{}{"collection": collection}
+			return nil
+		}
+	}
+}
+
+func xmlRootStart(d *xml.Decoder) (xml.StartElement, error) {
+	for {
+		token, err := d.RawToken()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+// decodeXMLElement consumes the tokens of the element until its end. Elements without attributes
+// nor children are decoded as strings and repeated children are grouped into arrays
+func decodeXMLElement(d *xml.Decoder, start xml.StartElement, opts XMLOptions) (interface{}, error) {
+	node := map[string]interface{}{}
+	if !opts.IgnoreAttributes {
+		for _, attr := range start.Attr {
+			if opts.StripNamespaces && (attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns") {
+				continue
+			}
+			node[opts.AttributePrefix+xmlTokenName(attr.Name, opts)] = attr.Value
+		}
+	}
+
+	text := new(strings.Builder)
+	for {
+		token, err := d.RawToken()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(d, t, opts)
+			if err != nil {
+				return nil, err
+			}
+			name := xmlTokenName(t.Name, opts)
+			switch previous := node[name].(type) {
+			case nil:
+				node[name] = child
+			case []interface{}:
+				node[name] = append(previous, child)
+			default:
+				node[name] = []interface{}{previous, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(node) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				node[opts.TextKey] = content
+			}
+			return node, nil
+		}
+	}
+}
+
+func xmlTokenName(name xml.Name, opts XMLOptions) string {
+	if opts.StripNamespaces || name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,129 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLEncoder(t *testing.T) {
+	data := map[string]interface{}{
+		"supu":    json.Number("42"),
+		"tupu":    true,
+		"foo bar": "a < b",
+		"1st":     nil,
+		"items": []interface{}{
+			map[string]interface{}{"id": 1},
+			map[string]interface{}{"id": 2},
+		},
+		"matrix": []interface{}{[]interface{}{1, 2}, []interface{}{3}},
+	}
+	buf := new(bytes.Buffer)
+	if err := XMLEncoder(buf, data); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<response><_1st/><foo_bar>a &lt; b</foo_bar><items><id>1</id></items><items><id>2</id></items>` +
+		`<matrix><item>1</item><item>2</item><item>3</item></matrix><supu>42</supu><tupu>true</tupu></response>`
+	if buf.String() != expected {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+}
+
+func TestXMLDecoder(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope">
+	<soap:Body>
+		<user id="42" soap:mustUnderstand="1">
+			<name>supu</name>
+			<role>admin</role>
+			<role>dev</role>
+			<email type="work">supu@example.com</email>
+			<!-- ignored -->
+			<empty/>
+		</user>
+	</soap:Body>
+</soap:Envelope>`
+	var result map[string]interface{}
+	if err := NewXMLDecoder(false)(strings.NewReader(input), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"Body": map[string]interface{}{
+			"user": map[string]interface{}{
+				"@id":             "42",
+				"@mustUnderstand": "1",
+				"name":            "supu",
+				"role":            []interface{}{"admin", "dev"},
+				"email":           map[string]interface{}{"@type": "work", "#text": "supu@example.com"},
+				"empty":           "",
+			},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestXMLDecoder_options(t *testing.T) {
+	input := `<a:root xmlns:a="urn:a" version="2"><a:item lang="en">supu</a:item></a:root>`
+	opts := XMLConfigGetter(map[string]interface{}{
+		"attribute_prefix": "-",
+		"text_key":         "value",
+		"strip_namespaces": false,
+	})
+	var result map[string]interface{}
+	if err := NewXMLDecoderFactory(opts)(false)(strings.NewReader(input), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"-xmlns:a": "urn:a",
+		"-version": "2",
+		"a:item":   map[string]interface{}{"-lang": "en", "value": "supu"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	opts = XMLConfigGetter(map[string]interface{}{"ignore_attributes": true})
+	if err := NewXMLDecoderFactory(opts)(false)(strings.NewReader(input), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"item": "supu"}) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestXMLDecoder_collection(t *testing.T) {
+	input := `<items><item><id>1</id></item><item><id>2</id></item></items>`
+	var result map[string]interface{}
+	if err := NewXMLDecoder(true)(strings.NewReader(input), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := map[string]interface{}{
+		"collection": []interface{}{
+			map[string]interface{}{"id": "1"},
+			map[string]interface{}{"id": "2"},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestXMLDecoder_ko(t *testing.T) {
+	var result map[string]interface{}
+	if err := NewXMLDecoder(false)(strings.NewReader(`<root><a>`), &result); err == nil {
+		t.Error("error expected")
+	}
+	if err := NewXMLDecoder(true)(strings.NewReader(""), &result); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -0,0 +1,92 @@
+package encoding
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+// YAML is the key for the yaml encoding
+const YAML = "yaml"
+
+// YAMLEncoder implements the Encoder interface. Scalars and keys are written as JSON values, since
+// they are also valid YAML flow scalars
+func YAMLEncoder(w io.Writer, v interface{}) error {
+	bw := bufio.NewWriter(w)
+	if err := writeYAML(bw, v, 0); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeYAML(w *bufio.Writer, v interface{}, indent int) error {
+	prefix := strings.Repeat("  ", indent)
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(t) == 0 {
+			w.WriteString(prefix + "{}\n")
+			return nil
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			key, err := json.Marshal(k)
+			if err != nil {
+				return err
+			}
+			w.WriteString(prefix)
+			w.Write(key)
+			w.WriteString(":")
+			if err := writeYAMLValue(w, t[k], indent); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if len(t) == 0 {
+			w.WriteString(prefix + "[]\n")
+			return nil
+		}
+		for _, e := range t {
+			w.WriteString(prefix + "-")
+			if err := writeYAMLValue(w, e, indent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	w.WriteString(prefix)
+	return writeYAMLScalar(w, v)
+}
+
+func writeYAMLValue(w *bufio.Writer, v interface{}, indent int) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(t) > 0 {
+			w.WriteString("\n")
+			return writeYAML(w, t, indent+1)
+		}
+	case []interface{}:
+		if len(t) > 0 {
+			w.WriteString("\n")
+			return writeYAML(w, t, indent+1)
+		}
+	}
+	w.WriteString(" ")
+	return writeYAMLScalar(w, v)
+}
+
+func writeYAMLScalar(w *bufio.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Write(b)
+	w.WriteString("\n")
+	return nil
+}
@@ -0,0 +1,47 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestYAMLEncoder(t *testing.T) {
+	data := map[string]interface{}{
+		"supu": json.Number("42"),
+		"tupu": "multi\nline",
+		"user": map[string]interface{}{
+			"name":  "supu",
+			"empty": map[string]interface{}{},
+		},
+		"items": []interface{}{
+			map[string]interface{}{"id": 1, "tags": []interface{}{"a", "b"}},
+			"plain",
+			[]interface{}{},
+		},
+		"nothing": nil,
+	}
+	buf := new(bytes.Buffer)
+	if err := YAMLEncoder(buf, data); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := `"items":
+  -
+    "id": 1
+    "tags":
+      - "a"
+      - "b"
+  - "plain"
+  - []
+"nothing": null
+"supu": 42
+"tupu": "multi\nline"
+"user":
+  "empty": {}
+  "name": "supu"
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected result:\n%s", buf.String())
+	}
+}
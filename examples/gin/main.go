@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"github.com/devopsfaith/krakend/config"
 	"github.com/devopsfaith/krakend/logging"
 	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
 	krakendgin "github.com/devopsfaith/krakend/router/gin"
 )
 
@@ -69,7 +71,13 @@ func main() {
 		},
 	})
 
-	routerFactory.New().Run(serviceConfig)
+	// the requests in flight are drained on SIGINT and SIGTERM
+	ctx, cancel := router.NotifyShutdown(context.Background())
+	defer cancel()
+	// a new process takes the listeners over on SIGUSR2, stopping this one once it is ready
+	router.NotifyRestart(ctx, logger)
+
+	routerFactory.NewWithContext(ctx).Run(serviceConfig)
 }
 
 // customProxyFactory adds a logging middleware wrapping the internal factory
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -10,10 +12,16 @@ import (
 	"github.com/geekypanda/httpcache"
 	"gopkg.in/unrolled/secure.v1"
 
+	"github.com/devopsfaith/krakend/audit"
 	"github.com/devopsfaith/krakend/config"
 	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/metrics"
+	"github.com/devopsfaith/krakend/metrics/otlp"
 	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
+	"github.com/devopsfaith/krakend/router/admin"
 	"github.com/devopsfaith/krakend/router/mux"
+	"github.com/devopsfaith/krakend/telemetry/opentelemetry"
 )
 
 func main() {
@@ -21,9 +29,20 @@ func main() {
 	logLevel := flag.String("l", "ERROR", "Logging level")
 	debug := flag.Bool("d", false, "Enable the debug")
 	configFile := flag.String("c", "/etc/krakend/configuration.json", "Path to the configuration filename")
+	snapshotsDir := flag.String("s", "", "Path to the directory keeping the snapshots of the configuration")
 	flag.Parse()
 
 	parser := config.NewParser()
+	if flag.Arg(0) == "check" {
+		os.Exit(check(parser, *configFile, *logLevel))
+	}
+
+	snapshots, err := config.NewSnapshots(config.DefaultMaxSnapshots, *snapshotsDir)
+	if err != nil {
+		log.Fatal("ERROR:", err.Error())
+	}
+	parser = config.NewParserWithSnapshots(snapshots)
+
 	serviceConfig, err := parser.Parse(*configFile)
 	if err != nil {
 		log.Fatal("ERROR:", err.Error())
@@ -33,11 +52,29 @@ func main() {
 		serviceConfig.Port = *port
 	}
 
-	logger, err := logging.NewLogger(*logLevel, os.Stdout, "[KRAKEND]")
+	var logger logging.Logger
+	logCfg, ok, err := logging.ConfigGetter(serviceConfig.ExtraConfig)
+	if err == nil && ok {
+		logger, err = logging.NewFromConfig(logCfg, os.Stdout)
+	} else if err == nil {
+		logger, err = logging.NewLogger(*logLevel, os.Stdout, "[KRAKEND]")
+	}
 	if err != nil {
 		log.Fatal("ERROR:", err.Error())
 	}
 
+	shutdownTracing, err := opentelemetry.Register(context.Background(), serviceConfig)
+	if err != nil {
+		log.Fatal("ERROR:", err.Error())
+	}
+	defer shutdownTracing(context.Background())
+
+	closeAudit, err := audit.Register(serviceConfig, logger)
+	if err != nil {
+		log.Fatal("ERROR:", err.Error())
+	}
+	defer closeAudit(context.Background())
+
 	secureMiddleware := secure.New(secure.Options{
 		AllowedHosts:          []string{"127.0.0.1:8080", "example.com", "ssl.example.com"},
 		SSLRedirect:           false,
@@ -54,6 +91,22 @@ func main() {
 
 	// routerFactory := mux.DefaultFactory(proxy.DefaultFactory(logger), logger)
 
+	// the configuration is reloaded when the file changes and on SIGHUP
+	watcher := config.NewWatcher(parser, *configFile, config.DefaultWatchInterval)
+
+	updates := make(chan config.ServiceConfig)
+	rollback := func(cfg config.ServiceConfig) {
+		cfg.Debug = cfg.Debug || *debug
+		updates <- cfg
+	}
+
+	// the admin API, if enabled, reports the configuration loaded by the router, reloads it and rolls it back
+	// to any of the last loaded ones
+	adminServer, err := admin.NewServer(serviceConfig, watcher.Reload, snapshots, rollback, logger)
+	if err != nil {
+		log.Fatal("ERROR:", err.Error())
+	}
+
 	routerFactory := mux.NewFactory(mux.Config{
 		Engine:       mux.DefaultEngine(),
 		ProxyFactory: proxy.DefaultFactory(logger),
@@ -64,5 +117,61 @@ func main() {
 		},
 	})
 
-	routerFactory.New().Run(serviceConfig)
+	// the requests in flight are drained on SIGINT and SIGTERM
+	ctx, cancel := router.NotifyShutdown(context.Background())
+	defer cancel()
+	// a new process takes the listeners over on SIGUSR2, stopping this one once it is ready
+	router.NotifyRestart(ctx, logger)
+
+	if adminServer != nil {
+		go func() {
+			if err := adminServer.Run(ctx); err != nil {
+				logger.Error("running the admin API:", err.Error())
+			}
+		}()
+	}
+
+	// the metrics are pushed to the exporters of the config until the shutdown
+	otlp.Register()
+	if err := metrics.StartExporters(ctx, serviceConfig, metrics.DefaultRegistry, logger); err != nil {
+		log.Fatal("ERROR:", err.Error())
+	}
+
+	configs, errs := watcher.Watch(ctx)
+	go func() {
+		for {
+			select {
+			case cfg := <-configs:
+				cfg.Debug = cfg.Debug || *debug
+				adminServer.Update(cfg)
+				updates <- cfg
+			case err := <-errs:
+				logger.Error("reloading the configuration:", err.Error())
+				audit.Record(audit.Event{Type: audit.EventConfigReload, Outcome: audit.OutcomeFailure, Reason: err.Error()})
+			}
+		}
+	}()
+
+	routerFactory.NewWithContext(ctx).(router.UpdatableRouter).RunWithUpdates(serviceConfig, updates)
+}
+
+// check validates the configuration and builds all the proxies without starting the service, returning the
+// exit code of the check subcommand
+func check(parser config.Parser, configFile, logLevel string) int {
+	cfg, diagnostics := config.Check(parser, configFile)
+	if len(diagnostics) == 0 {
+		logger, err := logging.NewLogger(logLevel, os.Stderr, "[KRAKEND]")
+		if err != nil {
+			log.Fatal("ERROR:", err.Error())
+		}
+		diagnostics = proxy.Check(proxy.DefaultFactory(logger), cfg)
+	}
+	for _, d := range diagnostics {
+		fmt.Println(d.String())
+	}
+	if len(diagnostics) > 0 {
+		return 1
+	}
+	fmt.Println("Syntax OK!")
+	return 0
 }
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LevelCritical is the slog level of the CRITICAL records
+const LevelCritical = slog.LevelError + 4
+
+// NewSlogHandler returns a Handler sending the records to the slog logger. The module of the records is
+// added as the 'module' attribute
+func NewSlogHandler(l *slog.Logger) Handler {
+	return HandlerFunc(func(r Record) error {
+		level := slogLevel(r.Level)
+		ctx := context.Background()
+		if !l.Enabled(ctx, level) {
+			return nil
+		}
+		rec := slog.NewRecord(r.Time, level, r.Message, 0)
+		if r.Module != "" {
+			rec.AddAttrs(slog.String("module", r.Module))
+		}
+		for _, f := range r.Fields {
+			rec.AddAttrs(slog.Any(f.Key, f.Value))
+		}
+		return l.Handler().Handle(ctx, rec)
+	})
+}
+
+func slogLevel(level int) slog.Level {
+	switch level {
+	case LEVEL_DEBUG:
+		return slog.LevelDebug
+	case LEVEL_INFO:
+		return slog.LevelInfo
+	case LEVEL_WARNING:
+		return slog.LevelWarn
+	case LEVEL_ERROR:
+		return slog.LevelError
+	}
+	return LevelCritical
+}
@@ -0,0 +1,423 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Namespace is the key to look for the logging options in the extra config of the service
+const Namespace = "github.com/devopsfaith/krakend/logging"
+
+// Modules of the gateway accepting their own level
+const (
+	ModuleProxy  = "proxy"
+	ModuleRouter = "router"
+	ModuleSD     = "sd"
+)
+
+// Formats of the records written by the structured loggers
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var levelNames = map[int]string{
+	LEVEL_DEBUG:    "DEBUG",
+	LEVEL_INFO:     "INFO",
+	LEVEL_WARNING:  "WARNING",
+	LEVEL_ERROR:    "ERROR",
+	LEVEL_CRITICAL: "CRITICAL",
+}
+
+// LevelName returns the name of the level
+func LevelName(level int) string {
+	if name, ok := levelNames[level]; ok {
+		return name
+	}
+	return fmt.Sprintf("LEVEL(%d)", level)
+}
+
+// Field is a key/value pair of a record
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Record is a message logged by a StructuredLogger
+type Record struct {
+	Time    time.Time
+	Level   int
+	Module  string
+	Message string
+	Fields  []Field
+}
+
+// Handler writes the records of the structured loggers into a logging backend
+type Handler interface {
+	Handle(Record) error
+}
+
+// HandlerFunc is a function implementing the Handler interface
+type HandlerFunc func(Record) error
+
+// Handle implements the Handler interface
+func (f HandlerFunc) Handle(r Record) error { return f(r) }
+
+// StructuredLogger is a Logger also logging messages with key/value pairs. The values received by the
+// methods of the Logger interface are joined into the message, so the components using them keep working
+type StructuredLogger interface {
+	Logger
+	// Log logs the message with the level and the key/value pairs
+	Log(level int, msg string, keyvals ...interface{})
+	// With returns a logger adding the key/value pairs to all its records
+	With(keyvals ...interface{}) StructuredLogger
+	// Module returns the logger of the module, with the level of the module
+	Module(name string) StructuredLogger
+}
+
+// LevelController is implemented by the loggers able to change their levels at runtime, so the operators can
+// debug a running service
+type LevelController interface {
+	// Levels returns the level of the logger, with the empty name, and the levels of the modules overriding it
+	Levels() map[string]string
+	// SetLevel changes the level of the module, or the one of the logger if the module is empty. The change
+	// applies to all the loggers derived from the same one
+	SetLevel(module, level string) error
+}
+
+// Module returns the logger of the module if the logger is a StructuredLogger, or the logger itself
+func Module(l Logger, name string) Logger {
+	if sl, ok := l.(StructuredLogger); ok {
+		return sl.Module(name)
+	}
+	return l
+}
+
+// Config defines the structured logger of the service
+type Config struct {
+	// Level is the minimum level logged. By default, INFO
+	Level string `json:"level"`
+	// Format is the format of the records, FormatText (key=value pairs) or FormatJSON. By default, FormatText
+	Format string `json:"format"`
+	// Modules overrides the level of the modules
+	Modules map[string]string `json:"modules"`
+	// Sampling limits the records logged for the repeated messages
+	Sampling *SamplingConfig `json:"sampling"`
+}
+
+// SamplingConfig defines the sampling of the repeated messages. In every period, the first Initial records
+// with the same level and message are logged, and then one of every Thereafter. The ERROR and CRITICAL
+// records are never sampled
+type SamplingConfig struct {
+	// Period of the counters. By default, a second
+	Period string `json:"period"`
+	// Initial is the number of records logged in every period before sampling them
+	Initial int `json:"initial"`
+	// Thereafter is the ratio of the records logged once sampled. Zero drops them
+	Thereafter int `json:"thereafter"`
+}
+
+// ConfigGetter parses the logging options from the extra config of the service. The second value is false if
+// they are not declared
+func ConfigGetter(extra map[string]interface{}) (Config, bool, error) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false, err
+	}
+	return cfg, true, nil
+}
+
+// NewFromConfig returns a StructuredLogger writing the records into the out writer, with the format of the
+// config
+func NewFromConfig(cfg Config, out io.Writer) (StructuredLogger, error) {
+	var h Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatText:
+		h = NewTextHandler(out)
+	case FormatJSON:
+		h = NewJSONHandler(out)
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+	return NewStructuredLogger(cfg, h)
+}
+
+// NewStructuredLogger returns a StructuredLogger sending the records to the handler. The Format of the
+// config is ignored
+func NewStructuredLogger(cfg Config, h Handler) (StructuredLogger, error) {
+	level := LEVEL_INFO
+	if cfg.Level != "" {
+		l, ok := logLevels[strings.ToUpper(cfg.Level)]
+		if !ok {
+			return nil, ErrInvalidLogLevel
+		}
+		level = l
+	}
+	modules := make(map[string]int, len(cfg.Modules))
+	for name, v := range cfg.Modules {
+		l, ok := logLevels[strings.ToUpper(v)]
+		if !ok {
+			return nil, ErrInvalidLogLevel
+		}
+		modules[name] = l
+	}
+	var s *sampler
+	if cfg.Sampling != nil {
+		period := time.Second
+		if cfg.Sampling.Period != "" {
+			d, err := time.ParseDuration(cfg.Sampling.Period)
+			if err != nil {
+				return nil, err
+			}
+			period = d
+		}
+		s = &sampler{
+			period:     period,
+			initial:    cfg.Sampling.Initial,
+			thereafter: cfg.Sampling.Thereafter,
+			counts:     map[string]int{},
+		}
+	}
+	return structuredLogger{
+		handler: h,
+		levels:  &levels{level: level, modules: modules},
+		sampler: s,
+	}, nil
+}
+
+type structuredLogger struct {
+	handler Handler
+	levels  *levels
+	sampler *sampler
+	module  string
+	fields  []Field
+}
+
+// levels are shared by a logger and all the loggers derived from it
+type levels struct {
+	mu      sync.RWMutex
+	level   int
+	modules map[string]int
+}
+
+func (l *levels) get(module string) int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if level, ok := l.modules[module]; ok && module != "" {
+		return level
+	}
+	return l.level
+}
+
+// Log implements the StructuredLogger interface
+func (l structuredLogger) Log(level int, msg string, keyvals ...interface{}) {
+	if level < l.levels.get(l.module) {
+		return
+	}
+	now := time.Now()
+	if l.sampler != nil && level < LEVEL_ERROR && !l.sampler.allow(l.module+"\x00"+LevelName(level)+"\x00"+msg, now) {
+		return
+	}
+	l.handler.Handle(Record{
+		Time:    now,
+		Level:   level,
+		Module:  l.module,
+		Message: msg,
+		Fields:  append(append([]Field{}, l.fields...), toFields(keyvals)...),
+	})
+}
+
+// With implements the StructuredLogger interface
+func (l structuredLogger) With(keyvals ...interface{}) StructuredLogger {
+	l.fields = append(append([]Field{}, l.fields...), toFields(keyvals)...)
+	return l
+}
+
+// Module implements the StructuredLogger interface
+func (l structuredLogger) Module(name string) StructuredLogger {
+	l.module = name
+	return l
+}
+
+// Levels implements the LevelController interface
+func (l structuredLogger) Levels() map[string]string {
+	l.levels.mu.RLock()
+	defer l.levels.mu.RUnlock()
+	res := map[string]string{"": LevelName(l.levels.level)}
+	for name, level := range l.levels.modules {
+		res[name] = LevelName(level)
+	}
+	return res
+}
+
+// SetLevel implements the LevelController interface
+func (l structuredLogger) SetLevel(module, level string) error {
+	v, ok := logLevels[strings.ToUpper(level)]
+	if !ok {
+		return ErrInvalidLogLevel
+	}
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+	if module == "" {
+		l.levels.level = v
+		return nil
+	}
+	l.levels.modules[module] = v
+	return nil
+}
+
+// Debug implements the Logger interface
+func (l structuredLogger) Debug(v ...interface{}) { l.Log(LEVEL_DEBUG, message(v)) }
+
+// Info implements the Logger interface
+func (l structuredLogger) Info(v ...interface{}) { l.Log(LEVEL_INFO, message(v)) }
+
+// Warning implements the Logger interface
+func (l structuredLogger) Warning(v ...interface{}) { l.Log(LEVEL_WARNING, message(v)) }
+
+// Error implements the Logger interface
+func (l structuredLogger) Error(v ...interface{}) { l.Log(LEVEL_ERROR, message(v)) }
+
+// Critical implements the Logger interface
+func (l structuredLogger) Critical(v ...interface{}) { l.Log(LEVEL_CRITICAL, message(v)) }
+
+// Fatal logs the message with the CRITICAL level and calls os.Exit(1)
+func (l structuredLogger) Fatal(v ...interface{}) {
+	l.Log(LEVEL_CRITICAL, message(v))
+	os.Exit(1)
+}
+
+func message(v []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
+
+// toFields pairs the keys and the values. A key without a value gets a nil one
+func toFields(keyvals []interface{}) []Field {
+	fields := make([]Field, 0, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		f := Field{Key: fmt.Sprint(keyvals[i])}
+		if i+1 < len(keyvals) {
+			f.Value = keyvals[i+1]
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// sampler counts the records with the same key in every period
+type sampler struct {
+	mu         sync.Mutex
+	period     time.Duration
+	initial    int
+	thereafter int
+	reset      time.Time
+	counts     map[string]int
+}
+
+func (s *sampler) allow(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.After(s.reset) {
+		s.counts = map[string]int{}
+		s.reset = now.Add(s.period)
+	}
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
+
+// NewTextHandler returns a Handler writing the records as lines of key=value pairs
+func NewTextHandler(out io.Writer) Handler {
+	w := &syncWriter{w: out}
+	return HandlerFunc(func(r Record) error {
+		b := new(strings.Builder)
+		b.WriteString("time=" + r.Time.Format(time.RFC3339Nano))
+		b.WriteString(" level=" + LevelName(r.Level))
+		if r.Module != "" {
+			b.WriteString(" module=" + textValue(r.Module))
+		}
+		b.WriteString(" msg=" + textValue(r.Message))
+		for _, f := range r.Fields {
+			b.WriteString(" " + textValue(f.Key) + "=" + textValue(fieldValue(f.Value)))
+		}
+		b.WriteString("\n")
+		return w.write([]byte(b.String()))
+	})
+}
+
+func textValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// NewJSONHandler returns a Handler writing the records as JSON objects, one per line
+func NewJSONHandler(out io.Writer) Handler {
+	w := &syncWriter{w: out}
+	return HandlerFunc(func(r Record) error {
+		b := new(strings.Builder)
+		b.WriteString(`{"time":` + jsonValue(r.Time.Format(time.RFC3339Nano)))
+		b.WriteString(`,"level":` + jsonValue(LevelName(r.Level)))
+		if r.Module != "" {
+			b.WriteString(`,"module":` + jsonValue(r.Module))
+		}
+		b.WriteString(`,"msg":` + jsonValue(r.Message))
+		for _, f := range r.Fields {
+			b.WriteString("," + jsonValue(f.Key) + ":" + jsonValue(fieldValue(f.Value)))
+		}
+		b.WriteString("}\n")
+		return w.write([]byte(b.String()))
+	})
+}
+
+func jsonValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return string(b)
+}
+
+// fieldValue returns the errors, the durations and the stringers as strings
+func fieldValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case error:
+		return value.Error()
+	case time.Duration:
+		return value.String()
+	case fmt.Stringer:
+		return value.String()
+	}
+	return v
+}
+
+// syncWriter serializes the writes of the records
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) write(b []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.w.Write(b)
+	return err
+}
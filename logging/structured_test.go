@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewFromConfig_text(t *testing.T) {
+	buff := new(bytes.Buffer)
+	cfg, ok, err := ConfigGetter(map[string]interface{}{Namespace: map[string]interface{}{
+		"level":   "warning",
+		"modules": map[string]interface{}{ModuleProxy: "debug"},
+	}})
+	if err != nil || !ok {
+		t.Fatalf("unexpected config: %v %v", ok, err)
+	}
+	logger, err := NewFromConfig(cfg, buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("dropped", "message")
+	logger.Warning("kept", "message")
+	Module(logger, ModuleProxy).Debug("proxy", "message")
+	logger.Module(ModuleRouter).Log(LEVEL_ERROR, "router message", "status", 502, "error", errors.New("bad gateway"))
+	logger.With("service", "gateway").Log(LEVEL_CRITICAL, "shutdown", "after", 2*time.Second)
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	expected := []string{
+		`level=WARNING msg="kept message"`,
+		`level=DEBUG module=proxy msg="proxy message"`,
+		`level=ERROR module=router msg="router message" status=502 error="bad gateway"`,
+		`level=CRITICAL msg=shutdown service=gateway after=2s`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("unexpected output: %s", buff.String())
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "time=") || !strings.HasSuffix(line, expected[i]) {
+			t.Errorf("unexpected line #%d: %s", i, line)
+		}
+	}
+}
+
+func TestNewFromConfig_json(t *testing.T) {
+	buff := new(bytes.Buffer)
+	logger, err := NewFromConfig(Config{Format: FormatJSON}, buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Module(ModuleSD).Log(LEVEL_INFO, "resolved", "hosts", []string{"a", "b"}, "dangling")
+
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(buff.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["level"] != "INFO" || record["module"] != ModuleSD || record["msg"] != "resolved" ||
+		len(record["hosts"].([]interface{})) != 2 || record["dangling"] != nil {
+		t.Errorf("unexpected record: %v", record)
+	}
+	if _, ok := record["time"]; !ok {
+		t.Errorf("the record has no time: %v", record)
+	}
+}
+
+func TestNewFromConfig_ko(t *testing.T) {
+	for _, cfg := range []Config{
+		{Format: "xml"},
+		{Level: "verbose"},
+		{Modules: map[string]string{ModuleProxy: "verbose"}},
+		{Sampling: &SamplingConfig{Period: "soon"}},
+	} {
+		if _, err := NewFromConfig(cfg, new(bytes.Buffer)); err == nil {
+			t.Errorf("%+v: error expected", cfg)
+		}
+	}
+}
+
+func TestStructuredLogger_sampling(t *testing.T) {
+	records := map[string]int{}
+	h := HandlerFunc(func(r Record) error {
+		records[LevelName(r.Level)+" "+r.Message]++
+		return nil
+	})
+	logger, err := NewStructuredLogger(Config{Sampling: &SamplingConfig{Period: "1h", Initial: 2, Thereafter: 3}}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 11; i++ {
+		logger.Info("repeated")
+		logger.Error("failure")
+	}
+	logger.Info("other")
+
+	// the first 2 messages and then 1 of every 3: #5 and #8 and #11
+	expected := map[string]int{"INFO repeated": 5, "ERROR failure": 11, "INFO other": 1}
+	for k, v := range expected {
+		if records[k] != v {
+			t.Errorf("unexpected records of %s: %d", k, records[k])
+		}
+	}
+}
+
+func TestStructuredLogger_SetLevel(t *testing.T) {
+	records := []string{}
+	h := HandlerFunc(func(r Record) error {
+		records = append(records, LevelName(r.Level)+" "+r.Module+" "+r.Message)
+		return nil
+	})
+	logger, err := NewStructuredLogger(Config{Level: "error", Modules: map[string]string{ModuleSD: "info"}}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyLogger := logger.Module(ModuleProxy)
+	sdLogger := logger.Module(ModuleSD)
+
+	proxyLogger.Info("dropped")
+	sdLogger.Debug("dropped")
+
+	lc, ok := logger.(LevelController)
+	if !ok {
+		t.Fatal("the structured logger must be a LevelController")
+	}
+	if err := lc.SetLevel("", "info"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lc.SetLevel(ModuleSD, "debug"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lc.SetLevel(ModuleSD, "verbose"); err != ErrInvalidLogLevel {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	proxyLogger.Info("kept")
+	sdLogger.Debug("kept")
+	logger.Debug("dropped")
+
+	expected := []string{"INFO proxy kept", "DEBUG sd kept"}
+	if strings.Join(records, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected records: %v", records)
+	}
+	if levels := lc.Levels(); len(levels) != 2 || levels[""] != "INFO" || levels[ModuleSD] != "DEBUG" {
+		t.Errorf("unexpected levels: %v", levels)
+	}
+}
+
+func TestNewSlogHandler(t *testing.T) {
+	buff := new(bytes.Buffer)
+	l := slog.New(slog.NewJSONHandler(buff, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	logger, err := NewStructuredLogger(Config{Level: "DEBUG"}, NewSlogHandler(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("filtered by slog")
+	logger.Module(ModuleProxy).Log(LEVEL_WARNING, "slow backend", "backend", "/users")
+
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(buff.Bytes(), &record); err != nil {
+		t.Fatalf("unexpected output: %s", buff.String())
+	}
+	if record["level"] != "WARN" || record["msg"] != "slow backend" || record["module"] != ModuleProxy || record["backend"] != "/users" {
+		t.Errorf("unexpected record: %v", record)
+	}
+}
@@ -0,0 +1,42 @@
+// Package zap adapts the zap loggers to the structured loggers of the logging package
+package zap
+
+import (
+	"github.com/devopsfaith/krakend/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewHandler returns a logging.Handler sending the records to the zap logger. The module of the records is
+// added as the 'module' field and the CRITICAL records are logged with the error level, since the DPanic,
+// Panic and Fatal levels of zap stop the flow of the program
+func NewHandler(l *zap.Logger) logging.Handler {
+	return logging.HandlerFunc(func(r logging.Record) error {
+		ce := l.Check(level(r.Level), r.Message)
+		if ce == nil {
+			return nil
+		}
+		ce.Time = r.Time
+		fields := make([]zap.Field, 0, len(r.Fields)+1)
+		if r.Module != "" {
+			fields = append(fields, zap.String("module", r.Module))
+		}
+		for _, f := range r.Fields {
+			fields = append(fields, zap.Any(f.Key, f.Value))
+		}
+		ce.Write(fields...)
+		return nil
+	})
+}
+
+func level(l int) zapcore.Level {
+	switch l {
+	case logging.LEVEL_DEBUG:
+		return zapcore.DebugLevel
+	case logging.LEVEL_INFO:
+		return zapcore.InfoLevel
+	case logging.LEVEL_WARNING:
+		return zapcore.WarnLevel
+	}
+	return zapcore.ErrorLevel
+}
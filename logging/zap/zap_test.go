@@ -0,0 +1,32 @@
+package zap
+
+import (
+	"testing"
+
+	"github.com/devopsfaith/krakend/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewHandler(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger, err := logging.NewStructuredLogger(logging.Config{Level: "DEBUG"}, NewHandler(zap.New(core)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("filtered by zap")
+	logger.Module(logging.ModuleRouter).Log(logging.LEVEL_CRITICAL, "listener down", "port", 8080)
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	e := entries[0]
+	if e.Level != zapcore.ErrorLevel || e.Message != "listener down" {
+		t.Errorf("unexpected entry: %v", e)
+	}
+	if fields := e.ContextMap(); fields["module"] != logging.ModuleRouter || fields["port"] != int64(8080) {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+}
@@ -0,0 +1,45 @@
+// Package zerolog adapts the zerolog loggers to the structured loggers of the logging package
+package zerolog
+
+import (
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/rs/zerolog"
+)
+
+// NewHandler returns a logging.Handler sending the records to the zerolog logger. The module of the records
+// is added as the 'module' field and the CRITICAL records are logged with the fatal level, without exiting.
+// The timestamps are added by the zerolog logger, if enabled
+func NewHandler(l zerolog.Logger) logging.Handler {
+	return logging.HandlerFunc(func(r logging.Record) error {
+		e := l.WithLevel(level(r.Level))
+		if e == nil {
+			return nil
+		}
+		if r.Module != "" {
+			e = e.Str("module", r.Module)
+		}
+		for _, f := range r.Fields {
+			if err, ok := f.Value.(error); ok {
+				e = e.AnErr(f.Key, err)
+				continue
+			}
+			e = e.Interface(f.Key, f.Value)
+		}
+		e.Msg(r.Message)
+		return nil
+	})
+}
+
+func level(l int) zerolog.Level {
+	switch l {
+	case logging.LEVEL_DEBUG:
+		return zerolog.DebugLevel
+	case logging.LEVEL_INFO:
+		return zerolog.InfoLevel
+	case logging.LEVEL_WARNING:
+		return zerolog.WarnLevel
+	case logging.LEVEL_ERROR:
+		return zerolog.ErrorLevel
+	}
+	return zerolog.FatalLevel
+}
@@ -0,0 +1,31 @@
+package zerolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/rs/zerolog"
+)
+
+func TestNewHandler(t *testing.T) {
+	buff := new(bytes.Buffer)
+	l := zerolog.New(buff).Level(zerolog.InfoLevel)
+	logger, err := logging.NewStructuredLogger(logging.Config{Level: "DEBUG"}, NewHandler(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("filtered by zerolog")
+	logger.Module(logging.ModuleProxy).Log(logging.LEVEL_ERROR, "backend failed", "error", errors.New("timeout"), "retries", 2)
+
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(buff.Bytes(), &record); err != nil {
+		t.Fatalf("unexpected output: %s", buff.String())
+	}
+	if record["level"] != "error" || record["message"] != "backend failed" || record["module"] != logging.ModuleProxy ||
+		record["error"] != "timeout" || record["retries"] != float64(2) {
+		t.Errorf("unexpected record: %v", record)
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+)
+
+// DefaultFlushInterval is the period of the pushes of the exporters when it is not configured
+const DefaultFlushInterval = 10 * time.Second
+
+// Exporter pushes the metrics of the registry to a monitoring system
+type Exporter interface {
+	// Export sends the gathered families
+	Export(ctx context.Context, families []Family) error
+}
+
+// ExporterFactory creates an Exporter with the options of an exporter of the metrics config and the global
+// tags of the service
+type ExporterFactory func(cfg map[string]interface{}, tags map[string]string) (Exporter, error)
+
+// ErrUnknownExporter is the error returned when the type of an exporter is not registered
+var ErrUnknownExporter = errors.New("unknown metrics exporter")
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]ExporterFactory{
+		StatsDExporterName:    NewStatsDExporterFactory(false),
+		DogStatsDExporterName: NewStatsDExporterFactory(true),
+	}
+)
+
+// RegisterExporter registers the exporter factory with the given type
+func RegisterExporter(name string, f ExporterFactory) error {
+	exportersMu.Lock()
+	exporters[name] = f
+	exportersMu.Unlock()
+	return nil
+}
+
+// StartExporters starts pushing the families of the registry with the exporters declared at the extra config
+// of the service, every flush interval, until the context is done. A last push is done then. The failed
+// pushes are logged
+func StartExporters(ctx context.Context, cfg config.ServiceConfig, r *Registry, logger logging.Logger) error {
+	mc, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if err != nil || !ok {
+		return err
+	}
+	for _, ec := range mc.Exporters {
+		name, _ := ec["type"].(string)
+		exportersMu.RLock()
+		f, ok := exporters[name]
+		exportersMu.RUnlock()
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownExporter, name)
+		}
+		interval := DefaultFlushInterval
+		if v, ok := ec["flush_interval"].(string); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			interval = d
+		}
+		if interval <= 0 {
+			return fmt.Errorf("invalid flush interval of the %s metrics exporter: %s", name, interval)
+		}
+		e, err := f(ec, mc.Tags)
+		if err != nil {
+			return err
+		}
+		go push(ctx, name, e, interval, r, logger)
+	}
+	return nil
+}
+
+func push(ctx context.Context, name string, e Exporter, interval time.Duration, r *Registry, logger logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// the last push must not be cancelled with the context
+			flushCtx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := e.Export(flushCtx, r.Gather()); err != nil {
+				logger.Error("pushing the metrics to", name+":", err.Error())
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := e.Export(ctx, r.Gather()); err != nil {
+				logger.Error("pushing the metrics to", name+":", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+)
+
+type recordingExporter struct {
+	mu      sync.Mutex
+	exports [][]Family
+}
+
+func (e *recordingExporter) Export(_ context.Context, families []Family) error {
+	e.mu.Lock()
+	e.exports = append(e.exports, families)
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *recordingExporter) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.exports)
+}
+
+var logger, _ = logging.NewLogger("CRITICAL", io.Discard, "")
+
+func TestStartExporters(t *testing.T) {
+	e := &recordingExporter{}
+	var tags map[string]string
+	RegisterExporter("recording", func(cfg map[string]interface{}, t map[string]string) (Exporter, error) {
+		tags = t
+		return e, nil
+	})
+
+	r := NewRegistry()
+	r.Counter("requests_total", "Requests.").Inc()
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"exporters": []interface{}{map[string]interface{}{"type": "recording", "flush_interval": "10ms"}},
+			"tags":      map[string]interface{}{"env": "test"},
+		},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := StartExporters(ctx, cfg, r, logger); err != nil {
+		t.Fatal(err)
+	}
+	if tags["env"] != "test" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for e.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if e.count() < 2 {
+		t.Fatalf("unexpected number of exports: %d", e.count())
+	}
+	e.mu.Lock()
+	families := e.exports[0]
+	e.mu.Unlock()
+	if len(families) != 1 || families[0].Name != "requests_total" || families[0].Metrics[0].Value != 1 {
+		t.Errorf("unexpected families: %+v", families)
+	}
+}
+
+func TestStartExporters_ko(t *testing.T) {
+	RegisterExporter("noop", func(map[string]interface{}, map[string]string) (Exporter, error) {
+		return &recordingExporter{}, nil
+	})
+	for _, tc := range []struct {
+		name     string
+		exporter map[string]interface{}
+		check    func(error) bool
+	}{
+		{
+			name:     "unknown type",
+			exporter: map[string]interface{}{"type": "unknown"},
+			check:    func(err error) bool { return errors.Is(err, ErrUnknownExporter) },
+		},
+		{
+			name:     "bad interval",
+			exporter: map[string]interface{}{"type": "noop", "flush_interval": "often"},
+			check:    func(err error) bool { return err != nil },
+		},
+		{
+			name:     "negative interval",
+			exporter: map[string]interface{}{"type": "noop", "flush_interval": "-1s"},
+			check:    func(err error) bool { return err != nil },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{"exporters": []interface{}{tc.exporter}},
+			}}
+			if err := StartExporters(context.Background(), cfg, NewRegistry(), logger); !tc.check(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
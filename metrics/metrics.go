@@ -0,0 +1,333 @@
+// Package metrics provides the registry of the metrics collected by the gateway and their exposition in the
+// Prometheus text format
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// Namespace is the key to look for the metrics options in the extra config of the service
+const Namespace = "github.com/devopsfaith/krakend/metrics"
+
+// DefaultPath is the path of the metrics endpoint when it is not configured
+const DefaultPath = "/metrics"
+
+// DefaultBuckets are the upper bounds of the buckets of the latency histograms, in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Config defines the metrics endpoint and the exporters of the service
+type Config struct {
+	// Path of the endpoint exposing the metrics. By default, the DefaultPath
+	Path string `json:"path"`
+	// DisableEndpoint does not expose the metrics endpoint, so they are only pushed by the exporters
+	DisableEndpoint bool `json:"disable_endpoint"`
+	// Exporters are the options of the push exporters. Every one declares its 'type' and its
+	// 'flush_interval', as a duration string
+	Exporters []map[string]interface{} `json:"exporters"`
+	// Tags are added to all the metrics pushed by the exporters
+	Tags map[string]string `json:"tags"`
+}
+
+// ConfigGetter parses the metrics options from the extra config of the service. The second value is false if
+// they are not declared
+func ConfigGetter(extra config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false, err
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultPath
+	}
+	return cfg, true, nil
+}
+
+// StatusClass returns the class of the status code, like 2xx, or "error" for the zero code of the failed
+// requests
+func StatusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// Kind is the type of a metric family
+type Kind int
+
+// Kinds of the metric families
+const (
+	KindCounter Kind = iota
+	KindGauge
+	KindHistogram
+)
+
+// String returns the type of the family in the Prometheus text format
+func (k Kind) String() string {
+	switch k {
+	case KindCounter:
+		return "counter"
+	case KindGauge:
+		return "gauge"
+	case KindHistogram:
+		return "histogram"
+	}
+	return "untyped"
+}
+
+// Label is a label of a metric
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Bucket is a cumulative bucket of a histogram
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Metric is the value of a family for a set of labels. The counters and the gauges only have a Value, and the
+// histograms have their Buckets, their Count and their Sum
+type Metric struct {
+	Labels  []Label
+	Value   float64
+	Buckets []Bucket
+	Count   uint64
+	Sum     float64
+}
+
+// Family is a group of metrics sharing their name, their help and their kind
+type Family struct {
+	Name    string
+	Help    string
+	Kind    Kind
+	Metrics []Metric
+}
+
+// Collector returns the metrics of a family when the registry is gathered
+type Collector func() []Metric
+
+// Registry keeps the metric families of the gateway
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]*family
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// DefaultRegistry is the registry of the metrics collected by the proxies and the routers of the gateway.
+// It includes the Go runtime metrics
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	RegisterRuntimeMetrics(DefaultRegistry)
+}
+
+type family struct {
+	name      string
+	help      string
+	kind      Kind
+	labels    []string
+	buckets   []float64
+	collector Collector
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (r *Registry) family(name, help string, kind Kind, labels []string, buckets []float64, c Collector) *family {
+	r.mu.RLock()
+	f, ok := r.families[name]
+	r.mu.RUnlock()
+	if ok {
+		if f.kind != kind || len(f.labels) != len(labels) {
+			panic(fmt.Sprintf("metrics: %s is already registered with another kind or labels", name))
+		}
+		return f
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.families[name]; ok {
+		return f
+	}
+	f = &family{
+		name:      name,
+		help:      help,
+		kind:      kind,
+		labels:    labels,
+		buckets:   buckets,
+		collector: c,
+		series:    map[string]*series{},
+	}
+	r.families[name] = f
+	return f
+}
+
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\x00")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: append([]string{}, values...)}
+		if f.kind == KindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// CounterVec is a family of counters
+type CounterVec struct{ f *family }
+
+// Counter returns the family of counters with the name, creating it if it is not registered. The same labels
+// must be used every time
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{r.family(name, help, KindCounter, labels, nil, nil)}
+}
+
+// Add increments the counter with the label values by the delta
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.f.mu.Lock()
+	c.f.get(values).value += delta
+	c.f.mu.Unlock()
+}
+
+// Inc increments the counter with the label values by one
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// GaugeVec is a family of gauges
+type GaugeVec struct{ f *family }
+
+// Gauge returns the family of gauges with the name, creating it if it is not registered. The same labels must
+// be used every time
+func (r *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{r.family(name, help, KindGauge, labels, nil, nil)}
+}
+
+// Set sets the value of the gauge with the label values
+func (g *GaugeVec) Set(v float64, values ...string) {
+	g.f.mu.Lock()
+	g.f.get(values).value = v
+	g.f.mu.Unlock()
+}
+
+// Add adds the delta to the gauge with the label values
+func (g *GaugeVec) Add(delta float64, values ...string) {
+	g.f.mu.Lock()
+	g.f.get(values).value += delta
+	g.f.mu.Unlock()
+}
+
+// HistogramVec is a family of histograms
+type HistogramVec struct{ f *family }
+
+// Histogram returns the family of histograms with the name, creating it with the upper bounds of the buckets
+// if it is not registered. The same labels must be used every time
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	bounds := append([]float64{}, buckets...)
+	sort.Float64s(bounds)
+	return &HistogramVec{r.family(name, help, KindHistogram, labels, bounds, nil)}
+}
+
+// Observe records the value into the histogram with the label values
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.f.mu.Lock()
+	s := h.f.get(values)
+	for i, bound := range h.f.buckets {
+		if v <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+	h.f.mu.Unlock()
+}
+
+// Collect registers a family whose metrics are returned by the collector every time the registry is gathered,
+// like the current state of a component
+func (r *Registry) Collect(name, help string, kind Kind, c Collector) {
+	r.family(name, help, kind, nil, nil, c)
+}
+
+// Gather returns a snapshot of the families of the registry, sorted by name
+func (r *Registry) Gather() []Family {
+	r.mu.RLock()
+	fs := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		fs = append(fs, f)
+	}
+	r.mu.RUnlock()
+	sort.Slice(fs, func(i, j int) bool { return fs[i].name < fs[j].name })
+
+	families := make([]Family, 0, len(fs))
+	for _, f := range fs {
+		families = append(families, f.snapshot())
+	}
+	return families
+}
+
+func (f *family) snapshot() Family {
+	res := Family{Name: f.name, Help: f.help, Kind: f.kind}
+	if f.collector != nil {
+		res.Metrics = f.collector()
+		return res
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res.Metrics = make([]Metric, 0, len(keys))
+	for _, k := range keys {
+		s := f.series[k]
+		m := Metric{Labels: make([]Label, len(f.labels)), Value: s.value, Count: s.count, Sum: s.sum}
+		for i, name := range f.labels {
+			m.Labels[i] = Label{Name: name, Value: s.labels[i]}
+		}
+		if f.kind == KindHistogram {
+			m.Buckets = make([]Bucket, len(f.buckets)+1)
+			var cumulative uint64
+			for i, bound := range f.buckets {
+				cumulative += s.counts[i]
+				m.Buckets[i] = Bucket{UpperBound: bound, Count: cumulative}
+			}
+			m.Buckets[len(f.buckets)] = Bucket{UpperBound: math.Inf(1), Count: s.count}
+		}
+		res.Metrics = append(res.Metrics, m)
+	}
+	return res
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("requests_total", "Requests.", "endpoint", "status_class")
+	requests.Inc("/users", "2xx")
+	requests.Add(2, "/users", "5xx")
+	requests.Inc(`/a"b`, "2xx")
+	r.Gauge("in_flight", "Requests in flight.").Set(3)
+	latency := r.Histogram("latency_seconds", "Latency.", []float64{1, 0.1}, "endpoint")
+	latency.Observe(0.05, "/users")
+	latency.Observe(0.5, "/users")
+	latency.Observe(5, "/users")
+	r.Collect("state", "State.", KindGauge, func() []Metric {
+		return []Metric{{Labels: []Label{{Name: "name", Value: "cb"}}, Value: 1}}
+	})
+	r.Counter("unused_total", "Unused.")
+
+	buff := new(bytes.Buffer)
+	if err := WritePrometheus(buff, r.Gather()); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP in_flight Requests in flight.
+# TYPE in_flight gauge
+in_flight 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{endpoint="/users",le="0.1"} 1
+latency_seconds_bucket{endpoint="/users",le="1"} 2
+latency_seconds_bucket{endpoint="/users",le="+Inf"} 3
+latency_seconds_sum{endpoint="/users"} 5.55
+latency_seconds_count{endpoint="/users"} 3
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{endpoint="/a\"b",status_class="2xx"} 1
+requests_total{endpoint="/users",status_class="2xx"} 1
+requests_total{endpoint="/users",status_class="5xx"} 2
+# HELP state State.
+# TYPE state gauge
+state{name="cb"} 1
+`
+	if buff.String() != expected {
+		t.Errorf("unexpected output:\n%s", buff.String())
+	}
+}
+
+func TestRegistry_conflict(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("requests_total", "Requests.", "endpoint")
+	if r.Counter("requests_total", "Requests.", "endpoint").f != r.families["requests_total"] {
+		t.Error("the family was not reused")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("panic expected")
+		}
+	}()
+	r.Gauge("requests_total", "Requests.", "endpoint")
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler(DefaultRegistry)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", DefaultPath, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != PrometheusContentType {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+	for _, name := range []string{"go_goroutines", "go_memstats_alloc_bytes", "go_gc_cycles_total", "process_start_time_seconds"} {
+		if !strings.Contains(w.Body.String(), "\n"+name+" ") {
+			t.Errorf("%s not exposed", name)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", DefaultPath, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+}
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, _ := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the metrics are not declared")
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{}})
+	if err != nil || !ok || cfg.Path != DefaultPath {
+		t.Errorf("unexpected config: %+v %v %v", cfg, ok, err)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for code, class := range map[int]string{0: "error", 200: "2xx", 304: "3xx", 429: "4xx", 503: "5xx", 600: "error"} {
+		if c := StatusClass(code); c != class {
+			t.Errorf("%d: unexpected class %s", code, c)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package otlp provides a metrics exporter pushing the metrics of the gateway to an OpenTelemetry collector
+// with OTLP
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/devopsfaith/krakend/metrics"
+)
+
+// Name is the type of the exporter in the metrics config of the service
+const Name = "otlp"
+
+// DefaultServiceName is the service.name of the metrics when it is not configured
+const DefaultServiceName = "krakend"
+
+// Protocols of the exporter
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+// ErrNoEndpoint is the error returned when the exporter has no endpoint
+var ErrNoEndpoint = errors.New("otlp: the exporter has no endpoint")
+
+// Config defines the OTLP metrics exporter
+type Config struct {
+	// Protocol is ProtocolGRPC or ProtocolHTTP. By default, ProtocolGRPC
+	Protocol string `json:"protocol"`
+	// Endpoint is the host and the port of the collector
+	Endpoint string `json:"endpoint"`
+	// URLPath is the path receiving the metrics with the ProtocolHTTP. By default, /v1/metrics
+	URLPath string `json:"url_path"`
+	// Insecure disables the TLS of the connections
+	Insecure bool `json:"insecure"`
+	// Headers are sent with every export
+	Headers map[string]string `json:"headers"`
+	// Timeout of every export, as a duration string. By default, 10s
+	Timeout string `json:"timeout"`
+	// ServiceName is the service.name of the resource of the metrics. By default, the DefaultServiceName
+	ServiceName string `json:"service_name"`
+}
+
+// Register registers the OTLP metrics exporter factory
+func Register() error {
+	return metrics.RegisterExporter(Name, ExporterFactory)
+}
+
+// pusher is the subset of the OTLP exporters used by the Exporter
+type pusher interface {
+	Export(context.Context, *metricdata.ResourceMetrics) error
+}
+
+// ExporterFactory creates an OTLP metrics exporter with the options of the exporter config. The global tags
+// are added to the resource of the metrics
+func ExporterFactory(cfg map[string]interface{}, tags map[string]string) (metrics.Exporter, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	oc := Config{}
+	if err := json.Unmarshal(b, &oc); err != nil {
+		return nil, err
+	}
+	p, err := newPusher(oc)
+	if err != nil {
+		return nil, err
+	}
+	serviceName := oc.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	attrs := []attribute.KeyValue{attribute.String("service.name", serviceName)}
+	for k, v := range tags {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	return &Exporter{pusher: p, resource: resource.NewSchemaless(attrs...), start: time.Now()}, nil
+}
+
+func newPusher(cfg Config) (pusher, error) {
+	if cfg.Endpoint == "" {
+		return nil, ErrNoEndpoint
+	}
+	timeout := 10 * time.Second
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		timeout = d
+	}
+
+	switch strings.ToLower(cfg.Protocol) {
+	case "", ProtocolGRPC:
+		opts := []otlpmetricgrpc.Option{
+			otlpmetricgrpc.WithEndpoint(cfg.Endpoint),
+			otlpmetricgrpc.WithTimeout(timeout),
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(cfg.Headers))
+		}
+		return otlpmetricgrpc.New(context.Background(), opts...)
+	case ProtocolHTTP:
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(cfg.Endpoint),
+			otlpmetrichttp.WithTimeout(timeout),
+		}
+		if cfg.URLPath != "" {
+			opts = append(opts, otlpmetrichttp.WithURLPath(cfg.URLPath))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(cfg.Headers))
+		}
+		return otlpmetrichttp.New(context.Background(), opts...)
+	}
+	return nil, fmt.Errorf("otlp: unknown exporter protocol %s", cfg.Protocol)
+}
+
+// Exporter pushes the families of the registry as cumulative OTLP metrics
+type Exporter struct {
+	pusher   pusher
+	resource *resource.Resource
+	start    time.Time
+}
+
+// Export implements the metrics.Exporter interface
+func (e *Exporter) Export(ctx context.Context, families []metrics.Family) error {
+	return e.pusher.Export(ctx, e.resourceMetrics(families, time.Now()))
+}
+
+func (e *Exporter) resourceMetrics(families []metrics.Family, now time.Time) *metricdata.ResourceMetrics {
+	ms := make([]metricdata.Metrics, 0, len(families))
+	for _, f := range families {
+		if len(f.Metrics) == 0 {
+			continue
+		}
+		m := metricdata.Metrics{Name: f.Name, Description: f.Help}
+		switch f.Kind {
+		case metrics.KindCounter:
+			points := make([]metricdata.DataPoint[float64], len(f.Metrics))
+			for i, v := range f.Metrics {
+				points[i] = metricdata.DataPoint[float64]{Attributes: attributes(v.Labels), StartTime: e.start, Time: now, Value: v.Value}
+			}
+			m.Data = metricdata.Sum[float64]{DataPoints: points, Temporality: metricdata.CumulativeTemporality, IsMonotonic: true}
+		case metrics.KindGauge:
+			points := make([]metricdata.DataPoint[float64], len(f.Metrics))
+			for i, v := range f.Metrics {
+				points[i] = metricdata.DataPoint[float64]{Attributes: attributes(v.Labels), Time: now, Value: v.Value}
+			}
+			m.Data = metricdata.Gauge[float64]{DataPoints: points}
+		case metrics.KindHistogram:
+			points := make([]metricdata.HistogramDataPoint[float64], len(f.Metrics))
+			for i, v := range f.Metrics {
+				points[i] = histogramPoint(v, e.start, now)
+			}
+			m.Data = metricdata.Histogram[float64]{DataPoints: points, Temporality: metricdata.CumulativeTemporality}
+		}
+		ms = append(ms, m)
+	}
+	return &metricdata.ResourceMetrics{
+		Resource: e.resource,
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Scope:   instrumentation.Scope{Name: "github.com/devopsfaith/krakend"},
+			Metrics: ms,
+		}},
+	}
+}
+
+// histogramPoint translates the cumulative buckets of the registry into the per bucket counts of OTLP
+func histogramPoint(m metrics.Metric, start, now time.Time) metricdata.HistogramDataPoint[float64] {
+	p := metricdata.HistogramDataPoint[float64]{
+		Attributes: attributes(m.Labels),
+		StartTime:  start,
+		Time:       now,
+		Count:      m.Count,
+		Sum:        m.Sum,
+	}
+	var previous uint64
+	for _, b := range m.Buckets {
+		if !math.IsInf(b.UpperBound, 1) {
+			p.Bounds = append(p.Bounds, b.UpperBound)
+		}
+		p.BucketCounts = append(p.BucketCounts, b.Count-previous)
+		previous = b.Count
+	}
+	return p
+}
+
+func attributes(labels []metrics.Label) attribute.Set {
+	kvs := make([]attribute.KeyValue, len(labels))
+	for i, l := range labels {
+		kvs[i] = attribute.String(l.Name, l.Value)
+	}
+	return attribute.NewSet(kvs...)
+}
@@ -0,0 +1,132 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/devopsfaith/krakend/metrics"
+)
+
+func TestExporter_resourceMetrics(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.Counter("requests_total", "Requests.", "endpoint").Add(3, "/users")
+	r.Gauge("in_flight", "In flight.").Set(2)
+	latency := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1}, "endpoint")
+	latency.Observe(0.05, "/users")
+	latency.Observe(0.5, "/users")
+	latency.Observe(0.7, "/users")
+	latency.Observe(5, "/users")
+	r.Counter("unused_total", "Unused.")
+
+	e, err := ExporterFactory(map[string]interface{}{"endpoint": "localhost:4317"}, map[string]string{"env": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm := e.(*Exporter).resourceMetrics(r.Gather(), time.Now())
+
+	if v, ok := rm.Resource.Set().Value("env"); !ok || v.AsString() != "test" {
+		t.Errorf("unexpected resource: %v", rm.Resource)
+	}
+	if v, ok := rm.Resource.Set().Value("service.name"); !ok || v.AsString() != DefaultServiceName {
+		t.Errorf("unexpected resource: %v", rm.Resource)
+	}
+	ms := rm.ScopeMetrics[0].Metrics
+	if len(ms) != 3 {
+		t.Fatalf("unexpected metrics: %+v", ms)
+	}
+
+	g, ok := ms[0].Data.(metricdata.Gauge[float64])
+	if ms[0].Name != "in_flight" || !ok || g.DataPoints[0].Value != 2 {
+		t.Errorf("unexpected gauge: %+v", ms[0])
+	}
+
+	h, ok := ms[1].Data.(metricdata.Histogram[float64])
+	if ms[1].Name != "latency_seconds" || !ok {
+		t.Fatalf("unexpected histogram: %+v", ms[1])
+	}
+	p := h.DataPoints[0]
+	if p.Count != 4 || p.Sum != 6.25 {
+		t.Errorf("unexpected count and sum: %d %f", p.Count, p.Sum)
+	}
+	if len(p.Bounds) != 2 || p.Bounds[0] != 0.1 || p.Bounds[1] != 1 {
+		t.Errorf("unexpected bounds: %v", p.Bounds)
+	}
+	if len(p.BucketCounts) != 3 || p.BucketCounts[0] != 1 || p.BucketCounts[1] != 2 || p.BucketCounts[2] != 1 {
+		t.Errorf("unexpected bucket counts: %v", p.BucketCounts)
+	}
+	if v, ok := p.Attributes.Value("endpoint"); !ok || v.AsString() != "/users" {
+		t.Errorf("unexpected attributes: %v", p.Attributes)
+	}
+
+	s, ok := ms[2].Data.(metricdata.Sum[float64])
+	if ms[2].Name != "requests_total" || !ok || !s.IsMonotonic || s.Temporality != metricdata.CumulativeTemporality ||
+		s.DataPoints[0].Value != 3 {
+		t.Errorf("unexpected sum: %+v", ms[2])
+	}
+}
+
+func TestExporter_http(t *testing.T) {
+	received := make(chan *colmetricpb.ExportMetricsServiceRequest, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("X-Key") != "secret" {
+			t.Errorf("unexpected request: %s %v", r.URL.Path, r.Header)
+		}
+		b, _ := io.ReadAll(r.Body)
+		req := &colmetricpb.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(b, req); err != nil {
+			t.Error(err)
+		}
+		received <- req
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer s.Close()
+
+	e, err := ExporterFactory(map[string]interface{}{
+		"protocol": "http",
+		"endpoint": strings.TrimPrefix(s.URL, "http://"),
+		"insecure": true,
+		"headers":  map[string]interface{}{"X-Key": "secret"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := metrics.NewRegistry()
+	r.Counter("requests_total", "Requests.").Inc()
+	if err := e.Export(context.Background(), r.Gather()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-received
+	ms := req.GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()
+	if len(ms) != 1 || ms[0].GetName() != "requests_total" || ms[0].GetSum().GetDataPoints()[0].GetAsDouble() != 1 {
+		t.Errorf("unexpected metrics: %v", ms)
+	}
+}
+
+func TestExporterFactory_ko(t *testing.T) {
+	for name, cfg := range map[string]map[string]interface{}{
+		"no endpoint":      {},
+		"unknown protocol": {"endpoint": "localhost:4317", "protocol": "thrift"},
+		"bad timeout":      {"endpoint": "localhost:4317", "timeout": "soon"},
+	} {
+		if _, err := ExporterFactory(cfg, nil); err == nil {
+			t.Errorf("%s: expecting an error", name)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(); err != nil {
+		t.Error(err)
+	}
+}
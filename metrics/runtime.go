@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// RegisterRuntimeMetrics registers the collectors of the Go runtime stats and the start time of the process
+// into the registry
+func RegisterRuntimeMetrics(r *Registry) {
+	start := float64(time.Now().Unix())
+	r.Collect("go_goroutines", "Number of goroutines that currently exist.", KindGauge, func() []Metric {
+		return []Metric{{Value: float64(runtime.NumGoroutine())}}
+	})
+	r.Collect("go_info", "Information about the Go environment.", KindGauge, func() []Metric {
+		return []Metric{{Labels: []Label{{Name: "version", Value: runtime.Version()}}, Value: 1}}
+	})
+	r.Collect("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", KindGauge, func() []Metric {
+		return []Metric{{Value: start}}
+	})
+
+	// the memory stats are read at most once per second, as reading them stops the world
+	var (
+		mu       sync.Mutex
+		ms       runtime.MemStats
+		lastRead time.Time
+	)
+	memStats := func(f func(*runtime.MemStats) float64) Collector {
+		return func() []Metric {
+			mu.Lock()
+			defer mu.Unlock()
+			if time.Since(lastRead) > time.Second {
+				runtime.ReadMemStats(&ms)
+				lastRead = time.Now()
+			}
+			return []Metric{{Value: f(&ms)}}
+		}
+	}
+	r.Collect("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", KindGauge,
+		memStats(func(ms *runtime.MemStats) float64 { return float64(ms.Alloc) }))
+	r.Collect("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", KindGauge,
+		memStats(func(ms *runtime.MemStats) float64 { return float64(ms.HeapInuse) }))
+	r.Collect("go_memstats_heap_objects", "Number of allocated objects.", KindGauge,
+		memStats(func(ms *runtime.MemStats) float64 { return float64(ms.HeapObjects) }))
+	r.Collect("go_memstats_sys_bytes", "Number of bytes obtained from system.", KindGauge,
+		memStats(func(ms *runtime.MemStats) float64 { return float64(ms.Sys) }))
+	r.Collect("go_gc_cycles_total", "Number of completed GC cycles.", KindCounter,
+		memStats(func(ms *runtime.MemStats) float64 { return float64(ms.NumGC) }))
+	r.Collect("go_gc_pause_seconds_total", "Total time the program was stopped by the GC.", KindCounter,
+		memStats(func(ms *runtime.MemStats) float64 { return float64(ms.PauseTotalNs) / float64(time.Second) }))
+}
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Types of the StatsD exporters
+const (
+	StatsDExporterName    = "statsd"
+	DogStatsDExporterName = "dogstatsd"
+)
+
+// DefaultStatsDAddress is the address of the StatsD agent when it is not configured
+const DefaultStatsDAddress = "127.0.0.1:8125"
+
+// maxStatsDPacketSize keeps the datagrams under the usual MTU
+const maxStatsDPacketSize = 1432
+
+// StatsDConfig defines a StatsD exporter
+type StatsDConfig struct {
+	// Address of the agent, as host:port. By default, the DefaultStatsDAddress
+	Address string `json:"address"`
+	// Prefix is added to the name of the metrics
+	Prefix string `json:"prefix"`
+}
+
+// NewStatsDExporterFactory returns the factory of the StatsD exporters. The DogStatsD ones send the labels and
+// the global tags as tags, and the plain StatsD ones append the label values to the name of the metrics and
+// ignore the global tags
+func NewStatsDExporterFactory(dogStatsD bool) ExporterFactory {
+	return func(cfg map[string]interface{}, tags map[string]string) (Exporter, error) {
+		b, err := json.Marshal(cfg)
+		if err != nil {
+			return nil, err
+		}
+		sc := StatsDConfig{}
+		if err := json.Unmarshal(b, &sc); err != nil {
+			return nil, err
+		}
+		if sc.Address == "" {
+			sc.Address = DefaultStatsDAddress
+		}
+		conn, err := net.Dial("udp", sc.Address)
+		if err != nil {
+			return nil, err
+		}
+		return NewStatsDExporter(conn, sc.Prefix, dogStatsD, tags), nil
+	}
+}
+
+// NewStatsDExporter returns an exporter writing the metrics into the connection with the StatsD line protocol.
+// The counters and the histograms are sent as the increments since the previous export: the histograms as the
+// counters <name>.count and <name>.sum. The gauges are sent with their current value
+func NewStatsDExporter(conn net.Conn, prefix string, dogStatsD bool, tags map[string]string) Exporter {
+	e := &statsDExporter{
+		conn:      conn,
+		prefix:    prefix,
+		dogStatsD: dogStatsD,
+		previous:  map[string]float64{},
+	}
+	if dogStatsD {
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			e.tags = append(e.tags, sanitizeStatsD(k)+":"+sanitizeStatsD(tags[k]))
+		}
+	}
+	return e
+}
+
+type statsDExporter struct {
+	conn      net.Conn
+	prefix    string
+	dogStatsD bool
+	tags      []string
+
+	mu       sync.Mutex
+	previous map[string]float64
+}
+
+// Export implements the Exporter interface
+func (e *statsDExporter) Export(_ context.Context, families []Family) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	lines := []string{}
+	for _, f := range families {
+		for _, m := range f.Metrics {
+			switch f.Kind {
+			case KindCounter:
+				if line, ok := e.delta(f.Name, m.Labels, m.Value); ok {
+					lines = append(lines, line)
+				}
+			case KindGauge:
+				lines = append(lines, e.line(f.Name, m.Labels, m.Value, "g"))
+			case KindHistogram:
+				if line, ok := e.delta(f.Name+".count", m.Labels, float64(m.Count)); ok {
+					lines = append(lines, line)
+				}
+				if line, ok := e.delta(f.Name+".sum", m.Labels, m.Sum); ok {
+					lines = append(lines, line)
+				}
+			}
+		}
+	}
+	return e.send(lines)
+}
+
+// delta returns the line of the increment of the counter since the previous export, if any
+func (e *statsDExporter) delta(name string, labels []Label, v float64) (string, bool) {
+	key := name
+	for _, l := range labels {
+		key += "\x00" + l.Value
+	}
+	d := v - e.previous[key]
+	e.previous[key] = v
+	if d <= 0 {
+		return "", false
+	}
+	return e.line(name, labels, d, "c"), true
+}
+
+func (e *statsDExporter) line(name string, labels []Label, v float64, kind string) string {
+	name = e.prefix + name
+	if !e.dogStatsD {
+		for _, l := range labels {
+			// the dots of the values would split the segments of the name
+			name += "." + strings.Replace(sanitizeStatsD(l.Value), ".", "_", -1)
+		}
+		return name + ":" + strconv.FormatFloat(v, 'f', -1, 64) + "|" + kind
+	}
+	tags := append([]string{}, e.tags...)
+	for _, l := range labels {
+		tags = append(tags, sanitizeStatsD(l.Name)+":"+sanitizeStatsD(l.Value))
+	}
+	line := name + ":" + strconv.FormatFloat(v, 'f', -1, 64) + "|" + kind
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
+
+// send writes the lines into datagrams smaller than the maxStatsDPacketSize
+func (e *statsDExporter) send(lines []string) error {
+	var errs []error
+	packet := ""
+	for _, line := range lines {
+		if packet != "" && len(packet)+1+len(line) > maxStatsDPacketSize {
+			if _, err := e.conn.Write([]byte(packet)); err != nil {
+				errs = append(errs, err)
+			}
+			packet = ""
+		}
+		if packet != "" {
+			packet += "\n"
+		}
+		packet += line
+	}
+	if packet != "" {
+		if _, err := e.conn.Write([]byte(packet)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var statsDEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", ",", "_", "#", "_", "\n", "_", " ", "_")
+
+// sanitizeStatsD replaces the characters reserved by the StatsD protocol
+func sanitizeStatsD(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return statsDEscaper.Replace(s)
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsDExporter(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		dogStatsD bool
+		first     []string
+		second    []string
+	}{
+		{
+			name: "statsd",
+			first: []string{
+				"krakend.in_flight:3|g",
+				"krakend.latency_seconds.count./a_b:1|c",
+				"krakend.latency_seconds.sum./a_b:0.5|c",
+				"krakend.requests_total./users.2xx:2|c",
+			},
+			second: []string{
+				"krakend.in_flight:1|g",
+				"krakend.requests_total./users.2xx:1|c",
+			},
+		},
+		{
+			name:      "dogstatsd",
+			dogStatsD: true,
+			first: []string{
+				"krakend.in_flight:3|g|#env:prod,region:eu_west",
+				"krakend.latency_seconds.count:1|c|#env:prod,region:eu_west,endpoint:/a.b",
+				"krakend.latency_seconds.sum:0.5|c|#env:prod,region:eu_west,endpoint:/a.b",
+				"krakend.requests_total:2|c|#env:prod,region:eu_west,endpoint:/users,status_class:2xx",
+			},
+			second: []string{
+				"krakend.in_flight:1|g|#env:prod,region:eu_west",
+				"krakend.requests_total:1|c|#env:prod,region:eu_west,endpoint:/users,status_class:2xx",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry()
+			requests := r.Counter("requests_total", "Requests.", "endpoint", "status_class")
+			inFlight := r.Gauge("in_flight", "In flight.")
+			latency := r.Histogram("latency_seconds", "Latency.", []float64{1}, "endpoint")
+			requests.Add(2, "/users", "2xx")
+			inFlight.Set(3)
+			latency.Observe(0.5, "/a.b")
+
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+			e := NewStatsDExporter(client, "krakend.", tc.dogStatsD, map[string]string{"region": "eu west", "env": "prod"})
+
+			if lines := export(t, e, server, r); strings.Join(lines, "\n") != strings.Join(tc.first, "\n") {
+				t.Errorf("unexpected first export:\n%s", strings.Join(lines, "\n"))
+			}
+
+			requests.Inc("/users", "2xx")
+			inFlight.Set(1)
+			if lines := export(t, e, server, r); strings.Join(lines, "\n") != strings.Join(tc.second, "\n") {
+				t.Errorf("unexpected second export:\n%s", strings.Join(lines, "\n"))
+			}
+		})
+	}
+}
+
+func export(t *testing.T, e Exporter, conn net.Conn, r *Registry) []string {
+	errs := make(chan error, 1)
+	go func() { errs <- e.Export(context.Background(), r.Gather()) }()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, maxStatsDPacketSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(string(buf[:n]), "\n")
+}
+
+func TestStatsDExporter_packets(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("a_very_long_name_for_a_counter_total", "Counter.", "id")
+	for i := 0; i < 100; i++ {
+		c.Inc(strings.Repeat("x", i))
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	e := NewStatsDExporter(client, "", false, nil)
+
+	errs := make(chan error, 1)
+	go func() { errs <- e.Export(context.Background(), r.Gather()) }()
+
+	lines := 0
+	buf := make([]byte, 2*maxStatsDPacketSize)
+	for lines < 100 {
+		server.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := server.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > maxStatsDPacketSize {
+			t.Errorf("packet too big: %d bytes", n)
+		}
+		lines += len(strings.Split(string(buf[:n]), "\n"))
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if lines != 100 {
+		t.Errorf("unexpected number of lines: %d", lines)
+	}
+}
@@ -0,0 +1,98 @@
+// Package plugin loads the go plugins (shared objects built with -buildmode=plugin) extending
+// the gateway without forking it.
+//
+// A decoder plugin must export a symbol named DecoderRegisterer implementing the DecoderRegisterer
+// interface. Only builtin types are used in the interface, so the plugins do not need to import
+// the krakend packages:
+//
+//	type registerer string
+//
+//	func (registerer) RegisterDecoders(f func(string, func(bool) func(io.Reader, *map[string]interface{}) error) error) error {
+//		return f("custom", newDecoder)
+//	}
+//
+//	var DecoderRegisterer = registerer("custom")
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"plugin"
+	"strings"
+
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+// DecoderRegistererSymbol is the name of the symbol to look up in the decoder plugins
+const DecoderRegistererSymbol = "DecoderRegisterer"
+
+// DecoderRegisterer is the interface the decoder plugins must implement. The received function registers
+// a decoder factory with the given name, so it can be used in the encoding of the backends
+type DecoderRegisterer interface {
+	RegisterDecoders(func(name string, factory func(bool) func(io.Reader, *map[string]interface{}) error) error) error
+}
+
+// symbolLookup is the subset of the plugin.Plugin methods required by the loader
+type symbolLookup interface {
+	Lookup(string) (plugin.Symbol, error)
+}
+
+var open = func(path string) (symbolLookup, error) {
+	return plugin.Open(path)
+}
+
+// LoadDecoders opens all the files in the folder with a name containing the pattern and registers the
+// decoders exposed by them. It returns the number of loaded plugins and the errors found.
+//
+// Since the decoders of the backends are resolved while parsing the configuration, the plugins must
+// be loaded before that.
+func LoadDecoders(folder, pattern string) (int, error) {
+	files, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return 0, err
+	}
+	if pattern == "" {
+		pattern = ".so"
+	}
+
+	loaded := 0
+	errs := []string{}
+	for _, f := range files {
+		if f.IsDir() || !strings.Contains(f.Name(), pattern) {
+			continue
+		}
+		if err := loadDecoderPlugin(filepath.Join(folder, f.Name())); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		loaded++
+	}
+	if len(errs) > 0 {
+		return loaded, fmt.Errorf("plugin: %s", strings.Join(errs, "; "))
+	}
+	return loaded, nil
+}
+
+func loadDecoderPlugin(path string) error {
+	p, err := open(path)
+	if err != nil {
+		return fmt.Errorf("opening %s: %s", path, err.Error())
+	}
+	s, err := p.Lookup(DecoderRegistererSymbol)
+	if err != nil {
+		return fmt.Errorf("looking up the registerer at %s: %s", path, err.Error())
+	}
+	r, ok := s.(DecoderRegisterer)
+	if !ok {
+		return fmt.Errorf("the registerer at %s does not implement the DecoderRegisterer interface", path)
+	}
+	return r.RegisterDecoders(registerDecoder)
+}
+
+func registerDecoder(name string, factory func(bool) func(io.Reader, *map[string]interface{}) error) error {
+	return encoding.Register(name, func(isCollection bool) encoding.Decoder {
+		return factory(isCollection)
+	})
+}
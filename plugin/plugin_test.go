@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"plugin"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+type dummyRegisterer string
+
+func (d dummyRegisterer) RegisterDecoders(f func(string, func(bool) func(io.Reader, *map[string]interface{}) error) error) error {
+	return f(string(d), func(_ bool) func(io.Reader, *map[string]interface{}) error {
+		return func(r io.Reader, v *map[string]interface{}) error {
+			b, err := ioutil.ReadAll(r)
+			*(v) = map[string]interface{}{"raw": string(b)}
+			return err
+		}
+	})
+}
+
+type dummyPlugin map[string]plugin.Symbol
+
+func (d dummyPlugin) Lookup(name string) (plugin.Symbol, error) {
+	s, ok := d[name]
+	if !ok {
+		return nil, errors.New("symbol not found")
+	}
+	return s, nil
+}
+
+func TestLoadDecoders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krakend_plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"good.so", "no_symbol.so", "wrong_type.so", "broken.so", "ignored.txt"} {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte{}, 0644)
+	}
+
+	originalOpen := open
+	defer func() { open = originalOpen }()
+	open = func(path string) (symbolLookup, error) {
+		switch filepath.Base(path) {
+		case "good.so":
+			return dummyPlugin{DecoderRegistererSymbol: dummyRegisterer("plugin_raw")}, nil
+		case "no_symbol.so":
+			return dummyPlugin{}, nil
+		case "wrong_type.so":
+			return dummyPlugin{DecoderRegistererSymbol: 42}, nil
+		}
+		return nil, errors.New("invalid plugin")
+	}
+
+	loaded, err := LoadDecoders(dir, ".so")
+	if loaded != 1 {
+		t.Errorf("unexpected number of loaded plugins: %d", loaded)
+	}
+	if err == nil {
+		t.Error("error expected")
+	} else {
+		for _, name := range []string{"no_symbol.so", "wrong_type.so", "broken.so"} {
+			if !strings.Contains(err.Error(), name) {
+				t.Errorf("the error does not report the plugin %s: %s", name, err.Error())
+			}
+		}
+	}
+
+	var result map[string]interface{}
+	if err := encoding.Get("plugin_raw")(false)(strings.NewReader("supu"), &result); err != nil {
+		t.Error(err)
+		return
+	}
+	if result["raw"] != "supu" {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestLoadDecoders_unknownFolder(t *testing.T) {
+	if _, err := LoadDecoders("/this/folder/does/not/exist", ""); err == nil {
+		t.Error("error expected")
+	}
+}
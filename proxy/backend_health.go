@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+// Statuses of the backends reported by BackendsHealth
+const (
+	BackendStatusUp       = "up"
+	BackendStatusDegraded = "degraded"
+	BackendStatusDown     = "down"
+)
+
+// BackendHealth is the status of a backend discovering its hosts or health checking them
+type BackendHealth struct {
+	// Backend is the URL pattern of the backend
+	Backend string `json:"backend"`
+	// Status is BackendStatusUp when all the hosts are healthy, BackendStatusDegraded when some of them are
+	// not and BackendStatusDown when none of them is healthy or the discovery fails
+	Status string `json:"status"`
+	// Hosts are the discovered hosts and their health
+	Hosts []HostHealth `json:"hosts"`
+	// Error is the error of the discovery, if any
+	Error string `json:"error,omitempty"`
+}
+
+// HostHealth is the health of a host of a backend
+type HostHealth struct {
+	Host    string `json:"host"`
+	Healthy bool   `json:"healthy"`
+}
+
+var (
+	backendHealthCheckers   = map[string]*backendHealthChecker{}
+	backendHealthCheckersMu sync.RWMutex
+)
+
+// BackendsHealth returns the status of the backends using a service discovery or the health checks, sorted by
+// URL pattern, so the readiness of the service can depend on them. The fixed hosts of the rest of the
+// backends are not checked
+func BackendsHealth() []BackendHealth {
+	backendHealthCheckersMu.RLock()
+	checkers := make(map[string]*backendHealthChecker, len(backendHealthCheckers))
+	for name, c := range backendHealthCheckers {
+		checkers[name] = c
+	}
+	backendHealthCheckersMu.RUnlock()
+
+	res := make([]BackendHealth, 0, len(checkers))
+	for name, c := range checkers {
+		res = append(res, c.health(name))
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Backend < res[j].Backend })
+	return res
+}
+
+// ResetBackendsHealth forgets the tracked backends, so the ones removed by a new configuration of the service
+// are not reported anymore. The backends are tracked again once their proxies are created
+func ResetBackendsHealth() {
+	backendHealthCheckersMu.Lock()
+	backendHealthCheckers = map[string]*backendHealthChecker{}
+	backendHealthCheckersMu.Unlock()
+}
+
+// registerBackendHealth tracks the health of the backend when it is not using fixed hosts or it is health
+// checked. The backends sharing the URL pattern share their entry
+func registerBackendHealth(remote *config.Backend, subscriber sd.Subscriber, healthChecked *sd.HealthCheckedSubscriber) {
+	if _, ok := subscriber.(sd.FixedSubscriber); ok && healthChecked == nil {
+		return
+	}
+	backendHealthCheckersMu.Lock()
+	backendHealthCheckers[remote.URLPattern] = &backendHealthChecker{subscriber, healthChecked}
+	backendHealthCheckersMu.Unlock()
+}
+
+type backendHealthChecker struct {
+	subscriber    sd.Subscriber
+	healthChecked *sd.HealthCheckedSubscriber
+}
+
+func (c *backendHealthChecker) health(name string) BackendHealth {
+	res := BackendHealth{Backend: name, Status: BackendStatusDown, Hosts: []HostHealth{}}
+
+	var (
+		hosts []string
+		err   error
+	)
+	if c.healthChecked != nil {
+		hosts, err = c.healthChecked.AllHosts()
+	} else {
+		hosts, err = c.subscriber.Hosts()
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	healthy := 0
+	for _, host := range hosts {
+		h := HostHealth{Host: host, Healthy: c.healthChecked == nil || !c.healthChecked.Ejected(host)}
+		if h.Healthy {
+			healthy++
+		}
+		res.Hosts = append(res.Hosts, h)
+	}
+	switch {
+	case healthy == 0:
+		res.Status = BackendStatusDown
+	case healthy < len(hosts):
+		res.Status = BackendStatusDegraded
+	default:
+		res.Status = BackendStatusUp
+	}
+	return res
+}
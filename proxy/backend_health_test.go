@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+func TestBackendsHealth(t *testing.T) {
+	defer ResetBackendsHealth()
+
+	hc := sd.NewHealthCheckedSubscriber(sd.FixedSubscriber{"http://a", "http://b"}, sd.HealthCheckConfig{MaxFailures: 1})
+	hc.Report("http://b", false)
+	registerBackendHealth(&config.Backend{URLPattern: "/degraded"}, sd.FixedSubscriber{"http://a", "http://b"}, hc)
+	registerBackendHealth(&config.Backend{URLPattern: "/fixed"}, sd.FixedSubscriber{"http://a"}, nil)
+	registerBackendHealth(&config.Backend{URLPattern: "/discovered"}, sd.SubscriberFunc(func() ([]string, error) {
+		return []string{"http://c"}, nil
+	}), nil)
+	registerBackendHealth(&config.Backend{URLPattern: "/failing"}, sd.SubscriberFunc(func() ([]string, error) {
+		return nil, errors.New("no dns")
+	}), nil)
+
+	health := BackendsHealth()
+	if len(health) != 3 {
+		t.Fatalf("unexpected health: %+v", health)
+	}
+	if h := health[0]; h.Backend != "/degraded" || h.Status != BackendStatusDegraded || len(h.Hosts) != 2 ||
+		!h.Hosts[0].Healthy || h.Hosts[1].Healthy {
+		t.Errorf("unexpected health: %+v", h)
+	}
+	if h := health[1]; h.Backend != "/discovered" || h.Status != BackendStatusUp || len(h.Hosts) != 1 {
+		t.Errorf("unexpected health: %+v", h)
+	}
+	if h := health[2]; h.Backend != "/failing" || h.Status != BackendStatusDown || h.Error != "no dns" {
+		t.Errorf("unexpected health: %+v", h)
+	}
+
+	hc.Report("http://a", false)
+	if h := BackendsHealth()[0]; h.Status != BackendStatusDown {
+		t.Errorf("unexpected health: %+v", h)
+	}
+
+	ResetBackendsHealth()
+	if health := BackendsHealth(); len(health) != 0 {
+		t.Errorf("unexpected health: %+v", health)
+	}
+}
@@ -34,12 +34,18 @@ func NewRandomLoadBalancedMiddlewareWithSubscriber(subscriber sd.Subscriber) Mid
 }
 
 func newLoadBalancedMiddleware(lb sd.Balancer) Middleware {
+	return NewLoadBalancedMiddleware(sdBalancer{lb})
+}
+
+// NewLoadBalancedMiddleware creates proxy middleware selecting the host of every request with the
+// received LoadBalancer and reporting the result of the request to it
+func NewLoadBalancedMiddleware(lb LoadBalancer) Middleware {
 	return func(next ...Proxy) Proxy {
 		if len(next) > 1 {
 			panic(ErrTooManyProxies)
 		}
 		return func(ctx context.Context, request *Request) (*Response, error) {
-			host, err := lb.Host()
+			host, err := lb.Host(request)
 			if err != nil {
 				return nil, err
 			}
@@ -50,13 +56,17 @@ func newLoadBalancedMiddleware(lb sd.Balancer) Middleware {
 			rawURL = append(rawURL, r.Path...)
 			r.URL, err = url.Parse(string(rawURL))
 			if err != nil {
+				lb.Done(host, 0, err)
 				return nil, err
 			}
 			if len(r.Query) > 0 {
 				r.URL.RawQuery = r.Query.Encode()
 			}
 
-			return next[0](ctx, &r)
+			start := time.Now()
+			resp, err := next[0](ctx, &r)
+			lb.Done(host, time.Since(start), err)
+			return resp, err
 		}
 	}
 }
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const bodyKey = "body"
+
+// ErrInvalidBody is the error returned when the body to transform is not a JSON object. It is translated
+// into a 400 by the routers
+var ErrInvalidBody = badRequestError("the request body must be a JSON object")
+
+type badRequestError string
+
+// Error implements the error interface
+func (b badRequestError) Error() string { return string(b) }
+
+// StatusCode returns the status code to send to the client
+func (b badRequestError) StatusCode() int { return http.StatusBadRequest }
+
+// BodyConfig defines the transformations of the JSON bodies of the requests sent to a backend
+type BodyConfig struct {
+	// Unwrap is the dotted path of the object to send instead of the whole body
+	Unwrap string `json:"unwrap"`
+	// Allow are the dotted paths of the fields to keep
+	Allow []string `json:"allow"`
+	// Deny are the dotted paths of the fields to remove. It is ignored if there are fields to keep
+	Deny []string `json:"deny"`
+	// Mapping maps the dotted paths of the fields into their new ones
+	Mapping map[string]string `json:"mapping"`
+	// Wrap is the name of the field enveloping the body
+	Wrap string `json:"wrap"`
+	// Inject sets the values at their dotted paths, after the rest of the transformations. The values are
+	// templates with the params of the request (.Params), the claims of its bearer token (.Claims) and the
+	// environment (.Env)
+	Inject map[string]string `json:"inject"`
+}
+
+type bodyField struct {
+	path []string
+	tmpl *template.Template
+}
+
+// NewBodyMiddleware creates a proxy middleware reshaping the JSON bodies of the requests sent to the
+// backend with the entity formatter of the responses: the unwrapped object is filtered, its fields are
+// mapped and it is wrapped into an envelope. Then, the injected values are set. The bodies without a JSON
+// content type are sent untouched, and the claims of the bearer tokens are not validated.
+//
+// The middleware is enabled with the 'body' option of the backend proxy extra config
+func NewBodyMiddleware(remote *config.Backend) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	v, ok := extra[bodyKey]
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	cfg := BodyConfig{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+
+	ef := NewEntityFormatter(cfg.Unwrap, cfg.Allow, cfg.Deny, cfg.Wrap, cfg.Mapping)
+	fields := make([]bodyField, 0, len(cfg.Inject))
+	useClaims := false
+	for k, text := range cfg.Inject {
+		tmpl, err := template.New(k).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, bodyField{strings.Split(k, "."), tmpl})
+		useClaims = useClaims || strings.Contains(text, ".Claims")
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return strings.Join(fields[i].path, ".") < strings.Join(fields[j].path, ".")
+	})
+
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			if request.Body == nil || !isJSONBody(request.Headers) {
+				return next[0](ctx, request)
+			}
+			b, err := ioutil.ReadAll(request.Body)
+			request.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			r := request.Clone()
+			if len(bytes.TrimSpace(b)) == 0 {
+				r.Body = ioutil.NopCloser(bytes.NewReader(b))
+				return next[0](ctx, &r)
+			}
+
+			data := map[string]interface{}{}
+			d := json.NewDecoder(bytes.NewReader(b))
+			d.UseNumber()
+			if err := d.Decode(&data); err != nil {
+				return nil, ErrInvalidBody
+			}
+			entity := ef.Format(Response{Data: data})
+
+			tmplData := headerContext{Params: r.Params, Env: env}
+			if useClaims {
+				tmplData.Claims = bearerClaims(r.Headers)
+			}
+			for _, f := range fields {
+				buf := new(bytes.Buffer)
+				if err := f.tmpl.Execute(buf, tmplData); err != nil {
+					return nil, err
+				}
+				if buf.Len() > 0 {
+					setAtPath(entity.Data, f.path, buf.String())
+				}
+			}
+
+			if b, err = json.Marshal(entity.Data); err != nil {
+				return nil, err
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(b))
+			return next[0](ctx, &r)
+		}
+	}, nil
+}
+
+// isJSONBody checks if the content type of the request is JSON. The requests without a content type are
+// considered JSON ones
+func isJSONBody(headers map[string][]string) bool {
+	contentType := headers["Content-Type"]
+	if len(contentType) == 0 {
+		return true
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType[0], ";")[0]))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func bodyBackend(cfg map[string]interface{}) *config.Backend {
+	return &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{bodyKey: cfg}}}
+}
+
+func TestNewBodyMiddleware(t *testing.T) {
+	mw, err := NewBodyMiddleware(bodyBackend(map[string]interface{}{
+		"unwrap":  "data",
+		"deny":    []interface{}{"password", "profile.internal"},
+		"mapping": map[string]interface{}{"name": "profile.name"},
+		"wrap":    "user",
+		"inject": map[string]interface{}{
+			"user.id":    "{{.Params.Id}}",
+			"user.owner": "{{.Claims.sub}}",
+			"user.empty": "{{.Params.Unknown}}",
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := "Bearer e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"supu"}`)) + ".signature"
+	request := &Request{
+		Params:  map[string]string{"Id": "42"},
+		Headers: map[string][]string{"Content-Type": {"application/json; charset=utf-8"}, "Authorization": {token}},
+		Body:    ioutil.NopCloser(bytes.NewBufferString(`{"data":{"name":"tupu","password":"secret","age":3,"profile":{"internal":true}}}`)),
+	}
+
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		expected := map[string]interface{}{
+			"user": map[string]interface{}{
+				"age":     3.0,
+				"profile": map[string]interface{}{"name": "tupu"},
+				"id":      "42",
+				"owner":   "supu",
+			},
+		}
+		if !reflect.DeepEqual(body, expected) {
+			t.Errorf("unexpected body: %v", body)
+		}
+		return &Response{IsComplete: true}, nil
+	})
+	if _, err := p(context.Background(), request); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewBodyMiddleware_untouched(t *testing.T) {
+	mw, err := NewBodyMiddleware(bodyBackend(map[string]interface{}{"wrap": "data"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != "a=b" {
+			t.Errorf("unexpected body: %s", string(b))
+		}
+		return &Response{IsComplete: true}, nil
+	})
+	request := &Request{
+		Headers: map[string][]string{"Content-Type": {"application/x-www-form-urlencoded"}},
+		Body:    ioutil.NopCloser(bytes.NewBufferString("a=b")),
+	}
+	if _, err := p(context.Background(), request); err != nil {
+		t.Error(err)
+	}
+
+	request = &Request{Body: ioutil.NopCloser(bytes.NewBufferString("[1, 2]"))}
+	if _, err := p(context.Background(), request); err != ErrInvalidBody {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const bulkheadKey = "bulkhead"
+
+var (
+	// ErrBulkheadFull is the error returned when the bulkhead of the backend rejects the request. It is
+	// translated into a 503 by the routers
+	ErrBulkheadFull = serviceUnavailableError("bulkhead is full")
+	// ErrInvalidBulkheadSize is the error returned when the max number of concurrent calls is lower than 1
+	ErrInvalidBulkheadSize = errors.New("the max number of concurrent calls must be greater than 0")
+)
+
+// NewBulkheadMiddleware creates a proxy middleware limiting the concurrent calls to the backend, so a
+// slow backend can not consume all the workers of the service. The calls over the 'max_concurrent_calls'
+// wait in a queue of 'max_queue' calls for up to 'max_wait' (by default, until the request is canceled).
+// The calls not fitting in the queue or waiting for too long are rejected.
+//
+// The middleware is enabled with the 'bulkhead' option of the backend proxy extra config
+func NewBulkheadMiddleware(remote *config.Backend) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	cfg, ok := extra[bulkheadKey].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+
+	maxCalls, _ := cfg["max_concurrent_calls"].(float64)
+	if maxCalls < 1 {
+		return nil, ErrInvalidBulkheadSize
+	}
+	maxQueue, _ := cfg["max_queue"].(float64)
+	var maxWait time.Duration
+	if v, ok := cfg["max_wait"].(string); ok {
+		var err error
+		if maxWait, err = time.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		b := &bulkhead{
+			slots:    make(chan struct{}, int(maxCalls)),
+			maxQueue: int(maxQueue),
+			maxWait:  maxWait,
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			if err := b.acquire(ctx); err != nil {
+				return nil, err
+			}
+			defer b.release()
+			return next[0](ctx, request)
+		}
+	}, nil
+}
+
+type bulkhead struct {
+	slots    chan struct{}
+	maxQueue int
+	maxWait  time.Duration
+
+	mu      sync.Mutex
+	waiting int
+}
+
+func (b *bulkhead) acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	b.mu.Lock()
+	if b.waiting >= b.maxQueue {
+		b.mu.Unlock()
+		return ErrBulkheadFull
+	}
+	b.waiting++
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		b.waiting--
+		b.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if b.maxWait > 0 {
+		timer := time.NewTimer(b.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return ErrBulkheadFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *bulkhead) release() {
+	<-b.slots
+}
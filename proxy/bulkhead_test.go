@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewBulkheadMiddleware_disabled(t *testing.T) {
+	mw, err := NewBulkheadMiddleware(&config.Backend{})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	p := mw(dummyProxy(&Response{IsComplete: true}))
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+}
+
+func TestNewBulkheadMiddleware_koConfig(t *testing.T) {
+	for i, cfg := range []map[string]interface{}{
+		{},
+		{"max_concurrent_calls": 0.0},
+		{"max_concurrent_calls": 1.0, "max_wait": "abc"},
+	} {
+		_, err := NewBulkheadMiddleware(&config.Backend{
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{bulkheadKey: cfg}},
+		})
+		if err == nil {
+			t.Errorf("#%d: error expected", i)
+		}
+	}
+}
+
+func TestNewBulkheadMiddleware_queue(t *testing.T) {
+	backend := &config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			bulkheadKey: map[string]interface{}{"max_concurrent_calls": 1.0, "max_queue": 1.0},
+		}},
+	}
+	mw, err := NewBulkheadMiddleware(backend)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	release := make(chan struct{})
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		<-release
+		return &Response{IsComplete: true}, nil
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p(context.Background(), &Request{}); err != nil {
+				t.Error("unexpected error:", err.Error())
+			}
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the slot and the queue are busy
+	if _, err := p(context.Background(), &Request{}); err != ErrBulkheadFull {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+}
+
+func TestNewBulkheadMiddleware_maxWait(t *testing.T) {
+	backend := &config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			bulkheadKey: map[string]interface{}{"max_concurrent_calls": 1.0, "max_queue": 5.0, "max_wait": "10ms"},
+		}},
+	}
+	mw, err := NewBulkheadMiddleware(backend)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	release := make(chan struct{})
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		<-release
+		return &Response{IsComplete: true}, nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p(context.Background(), &Request{})
+		close(done)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := p(context.Background(), &Request{}); err != ErrBulkheadFull {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p(ctx, &Request{}); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	close(release)
+	<-done
+
+	// the slot is released
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
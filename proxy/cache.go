@@ -0,0 +1,305 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	cacheKey = "cache"
+	// MemoryCacheStore is the name of the default cache store, an in-memory LRU
+	MemoryCacheStore = "memory"
+)
+
+// ErrUnknownCacheStore is the error returned when the configured cache store is not registered
+var ErrUnknownCacheStore = errors.New("unknown cache store")
+
+// CacheStore persists the serialized responses of the cache middleware. Since the cache is just an
+// optimization, the stores should report their failures as misses
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// CacheStoreFactory creates a CacheStore with the options declared in the cache extra config of a backend
+type CacheStoreFactory func(cfg map[string]interface{}) (CacheStore, error)
+
+var cacheStoreFactories = map[string]CacheStoreFactory{
+	MemoryCacheStore: newMemoryCacheStoreFromConfig,
+}
+
+// RegisterCacheStore registers the cache store factory with the given name
+func RegisterCacheStore(name string, f CacheStoreFactory) error {
+	cacheStoreFactories[name] = f
+	return nil
+}
+
+// NewCacheMiddleware creates a proxy middleware caching the responses of the GET requests to the backend.
+// The entries are identified by the method, the path, the query string and the configured headers of the
+// request. The TTL of the entries is the fixed one declared in the config or, by default, the one set by
+// the Cache-Control header of the backend. The expired entries with an ETag are revalidated with a
+// conditional request, so they are kept in the store twice their TTL.
+//
+// The expired entries can also be served during the 'stale_while_revalidate' period, while they are
+// refreshed in background, or during the 'stale_if_error' one, when the backend fails.
+//
+// The middleware is enabled with the 'cache' option of the backend proxy extra config
+func NewCacheMiddleware(remote *config.Backend) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	cfg, ok := extra[cacheKey].(map[string]interface{})
+	if !ok || (remote.Method != "" && remote.Method != http.MethodGet) {
+		return EmptyMiddleware, nil
+	}
+
+	c := &cache{timeout: remote.Timeout}
+	if c.timeout == 0 {
+		c.timeout = config.DefaultTimeout
+	}
+	for name, d := range map[string]*time.Duration{
+		"ttl":                    &c.ttl,
+		"stale_while_revalidate": &c.staleWhileRevalidate,
+		"stale_if_error":         &c.staleIfError,
+	} {
+		v, ok := cfg[name].(string)
+		if !ok {
+			continue
+		}
+		var err error
+		if *d, err = time.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := cfg["headers"].([]interface{}); ok {
+		for _, h := range v {
+			if name, ok := h.(string); ok {
+				c.headers = append(c.headers, name)
+			}
+		}
+	}
+
+	storeName := MemoryCacheStore
+	if v, ok := cfg["store"].(string); ok {
+		storeName = v
+	}
+	f, ok := cacheStoreFactories[storeName]
+	if !ok {
+		return nil, ErrUnknownCacheStore
+	}
+	store, err := f(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.store = store
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			return c.call(ctx, next[0], request)
+		}
+	}, nil
+}
+
+type cache struct {
+	store                CacheStore
+	ttl                  time.Duration
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	timeout              time.Duration
+	headers              []string
+	// refreshing tracks the keys being revalidated in background
+	refreshing sync.Map
+}
+
+type cacheEntry struct {
+	Data       map[string]interface{}
+	IsComplete bool
+	Headers    map[string][]string
+	StatusCode int
+	ETag       string
+	TTL        time.Duration
+	Expiration time.Time
+}
+
+func (c *cache) call(ctx context.Context, next Proxy, request *Request) (*Response, error) {
+	key := c.key(request)
+
+	entry, ok := c.get(key)
+	if !ok {
+		result, err := next(ctx, request)
+		c.save(key, result, err)
+		return result, err
+	}
+
+	staleness := time.Since(entry.Expiration)
+	if staleness < 0 {
+		return entry.response(), nil
+	}
+
+	if staleness < c.staleWhileRevalidate {
+		if _, loaded := c.refreshing.LoadOrStore(key, true); !loaded {
+			go func() {
+				// the refresh keeps the values of the context, like the request ID and the trace, but
+				// it is not canceled with the request
+				localCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+				c.revalidate(localCtx, next, request, key, entry)
+				cancel()
+				c.refreshing.Delete(key)
+			}()
+		}
+		return entry.response(), nil
+	}
+
+	result, err := c.revalidate(ctx, next, request, key, entry)
+	if err != nil && staleness < c.staleIfError {
+		return entry.response(), nil
+	}
+	return result, err
+}
+
+// revalidate refreshes the expired entry, with a conditional request if the entry has an ETag
+func (c *cache) revalidate(ctx context.Context, next Proxy, request *Request, key string, entry cacheEntry) (*Response, error) {
+	if entry.ETag == "" {
+		result, err := next(ctx, request)
+		c.save(key, result, err)
+		return result, err
+	}
+
+	r := request.Clone()
+	r.Headers = make(map[string][]string, len(request.Headers)+1)
+	for k, v := range request.Headers {
+		r.Headers[k] = v
+	}
+	r.Headers["If-None-Match"] = []string{entry.ETag}
+
+	result, err := next(ctx, &r)
+	if err == ErrNotModified {
+		entry.Expiration = time.Now().Add(entry.TTL)
+		c.set(key, entry)
+		return entry.response(), nil
+	}
+	c.save(key, result, err)
+	return result, err
+}
+
+func (c *cache) key(request *Request) string {
+	return requestKey(request, c.headers)
+}
+
+// requestKey identifies the request by its method, path, query string and the received headers
+func requestKey(request *Request, headers []string) string {
+	key := request.Method + " " + request.Path
+	if len(request.Query) > 0 {
+		key += "?" + request.Query.Encode()
+	}
+	for _, h := range headers {
+		key += "\n" + h + ": " + strings.Join(request.Headers[h], ",")
+	}
+	return key
+}
+
+func (c *cache) get(key string) (cacheEntry, bool) {
+	entry := cacheEntry{}
+	raw, ok := c.store.Get(key)
+	if !ok {
+		return entry, false
+	}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+func (c *cache) save(key string, result *Response, err error) {
+	if err != nil || result == nil || !result.IsComplete || result.Io != nil {
+		return
+	}
+	headers := http.Header(result.Metadata.Headers)
+	ttl := c.ttl
+	if ttl == 0 {
+		ttl = cacheControlTTL(headers.Get("Cache-Control"))
+	}
+	if ttl <= 0 {
+		return
+	}
+	c.set(key, cacheEntry{
+		Data:       result.Data,
+		IsComplete: result.IsComplete,
+		Headers:    result.Metadata.Headers,
+		StatusCode: result.Metadata.StatusCode,
+		ETag:       headers.Get("ETag"),
+		TTL:        ttl,
+		Expiration: time.Now().Add(ttl),
+	})
+}
+
+func (c *cache) set(key string, entry cacheEntry) {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	// the expired entries are kept while they can be served as stale or revalidated
+	retention := c.staleWhileRevalidate
+	if c.staleIfError > retention {
+		retention = c.staleIfError
+	}
+	if entry.ETag != "" && entry.TTL > retention {
+		retention = entry.TTL
+	}
+	c.store.Set(key, raw, entry.TTL+retention)
+}
+
+func (e cacheEntry) response() *Response {
+	return &Response{
+		Data:       e.Data,
+		IsComplete: e.IsComplete,
+		Metadata: Metadata{
+			Headers:    e.Headers,
+			StatusCode: e.StatusCode,
+		},
+	}
+}
+
+// cacheControlTTL returns the TTL declared by the s-maxage or the max-age directives of the received
+// Cache-Control header. The responses with the no-store, no-cache or private directives are not cached
+func cacheControlTTL(header string) time.Duration {
+	var maxAge, sMaxAge time.Duration
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store", directive == "no-cache", directive == "private":
+			return 0
+		case strings.HasPrefix(directive, "s-maxage="):
+			sMaxAge = parseSeconds(directive[len("s-maxage="):])
+		case strings.HasPrefix(directive, "max-age="):
+			maxAge = parseSeconds(directive[len("max-age="):])
+		}
+	}
+	if sMaxAge > 0 {
+		return sMaxAge
+	}
+	return maxAge
+}
+
+func parseSeconds(v string) time.Duration {
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultMemoryCacheSize is the default max number of entries of the in-memory cache store
+const DefaultMemoryCacheSize = 1000
+
+// ErrInvalidCacheSize is the error returned when the size of the in-memory cache store is not positive
+var ErrInvalidCacheSize = errors.New("the size of the cache must be a positive number")
+
+// NewMemoryCacheStore creates an in-memory CacheStore evicting the least recently used entries
+// when it holds more than the received number of them
+func NewMemoryCacheStore(size int) (CacheStore, error) {
+	if size <= 0 {
+		return nil, ErrInvalidCacheSize
+	}
+	return &memoryCacheStore{
+		size:  size,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+	}, nil
+}
+
+func newMemoryCacheStoreFromConfig(cfg map[string]interface{}) (CacheStore, error) {
+	size := DefaultMemoryCacheSize
+	if v, ok := cfg["size"].(float64); ok {
+		size = int(v)
+	}
+	return NewMemoryCacheStore(size)
+}
+
+type memoryCacheStore struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key        string
+	value      []byte
+	expiration time.Time
+}
+
+// Get implements the CacheStore interface
+func (m *memoryCacheStore) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := e.Value.(*memoryCacheItem)
+	if !item.expiration.IsZero() && time.Now().After(item.expiration) {
+		m.ll.Remove(e)
+		delete(m.items, key)
+		return nil, false
+	}
+	m.ll.MoveToFront(e)
+	return item.value, true
+}
+
+// Set implements the CacheStore interface
+func (m *memoryCacheStore) Set(key string, value []byte, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expiration time.Time
+	if ttl > 0 {
+		expiration = time.Now().Add(ttl)
+	}
+
+	if e, ok := m.items[key]; ok {
+		item := e.Value.(*memoryCacheItem)
+		item.value = value
+		item.expiration = expiration
+		m.ll.MoveToFront(e)
+		return
+	}
+
+	m.items[key] = m.ll.PushFront(&memoryCacheItem{key: key, value: value, expiration: expiration})
+	for m.ll.Len() > m.size {
+		e := m.ll.Back()
+		m.ll.Remove(e)
+		delete(m.items, e.Value.(*memoryCacheItem).key)
+	}
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheStore_lru(t *testing.T) {
+	store, err := NewMemoryCacheStore(2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	store.Set("a", []byte("1"), 0)
+	store.Set("b", []byte("2"), 0)
+	if v, ok := store.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("unexpected value: %s", v)
+	}
+	store.Set("c", []byte("3"), 0)
+
+	if _, ok := store.Get("b"); ok {
+		t.Error("the least recently used entry was not evicted")
+	}
+	if v, ok := store.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("unexpected value: %s", v)
+	}
+	if v, ok := store.Get("c"); !ok || string(v) != "3" {
+		t.Errorf("unexpected value: %s", v)
+	}
+
+	store.Set("c", []byte("4"), 0)
+	if v, ok := store.Get("c"); !ok || string(v) != "4" {
+		t.Errorf("unexpected value: %s", v)
+	}
+}
+
+func TestMemoryCacheStore_ttl(t *testing.T) {
+	store, _ := NewMemoryCacheStore(2)
+	store.Set("a", []byte("1"), time.Millisecond)
+	if _, ok := store.Get("a"); !ok {
+		t.Error("the entry should not be expired yet")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if _, ok := store.Get("a"); ok {
+		t.Error("the entry should be expired")
+	}
+}
+
+func TestNewMemoryCacheStore_ko(t *testing.T) {
+	if _, err := NewMemoryCacheStore(0); err != ErrInvalidCacheSize {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewCacheMiddleware_notConfigured(t *testing.T) {
+	for _, backend := range []*config.Backend{
+		{},
+		{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{}}},
+		{Method: "POST", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{cacheKey: map[string]interface{}{}}}},
+	} {
+		calls := 0
+		mw, err := NewCacheMiddleware(backend)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+			calls++
+			return &Response{Data: map[string]interface{}{}, IsComplete: true}, nil
+		})
+		p(context.Background(), &Request{Method: "GET", Path: "/"})
+		p(context.Background(), &Request{Method: "GET", Path: "/"})
+		if calls != 2 {
+			t.Errorf("unexpected number of calls: %d", calls)
+		}
+	}
+}
+
+func TestNewCacheMiddleware_koConfig(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"ttl": "bad"},
+		{"store": "unknown"},
+		{"size": -1.0},
+	} {
+		backend := &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{cacheKey: cfg}}}
+		if _, err := NewCacheMiddleware(backend); err == nil {
+			t.Errorf("expecting an error with the config %v", cfg)
+		}
+	}
+}
+
+func TestNewCacheMiddleware_fixedTTL(t *testing.T) {
+	backend := &config.Backend{
+		Method: "GET",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			cacheKey: map[string]interface{}{"ttl": "1h", "headers": []interface{}{"Authorization"}},
+		}},
+	}
+	mw, err := NewCacheMiddleware(backend)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	calls := 0
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return &Response{Data: map[string]interface{}{"supu": 42}, IsComplete: true}, nil
+	})
+
+	requests := []*Request{
+		{Method: "GET", Path: "/a", Query: url.Values{"b": []string{"1"}}},
+		{Method: "GET", Path: "/a", Query: url.Values{"b": []string{"1"}}},
+		{Method: "GET", Path: "/a", Query: url.Values{"b": []string{"2"}}},
+		{Method: "GET", Path: "/a", Query: url.Values{"b": []string{"1"}}, Headers: map[string][]string{"Authorization": {"x"}}},
+		{Method: "GET", Path: "/a", Query: url.Values{"b": []string{"1"}}, Headers: map[string][]string{"User-Agent": {"x"}}},
+	}
+	for _, r := range requests {
+		resp, err := p(context.Background(), r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if v, ok := resp.Data["supu"]; !ok || v != 42 && v != json.Number("42") {
+			t.Errorf("unexpected response: %v", resp.Data)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("unexpected number of calls: %d", calls)
+	}
+}
+
+func TestNewCacheMiddleware_cacheControl(t *testing.T) {
+	backend := &config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			cacheKey: map[string]interface{}{},
+		}},
+	}
+	mw, err := NewCacheMiddleware(backend)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, tc := range []struct {
+		header string
+		calls  int
+	}{
+		{header: "", calls: 2},
+		{header: "public, max-age=60", calls: 1},
+		{header: "public, s-maxage=60, max-age=0", calls: 1},
+		{header: "max-age=60, private", calls: 2},
+		{header: "no-store", calls: 2},
+	} {
+		calls := 0
+		p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+			calls++
+			return &Response{
+				Data:       map[string]interface{}{"supu": 42},
+				IsComplete: true,
+				Metadata:   Metadata{Headers: map[string][]string{"Cache-Control": {tc.header}}},
+			}, nil
+		})
+		path := "/" + tc.header
+		p(context.Background(), &Request{Method: "GET", Path: path})
+		p(context.Background(), &Request{Method: "GET", Path: path})
+		if calls != tc.calls {
+			t.Errorf("%s: unexpected number of calls: %d", tc.header, calls)
+		}
+	}
+}
+
+func TestNewCacheMiddleware_errorsAreNotCached(t *testing.T) {
+	backend := &config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			cacheKey: map[string]interface{}{"ttl": "1h"},
+		}},
+	}
+	mw, _ := NewCacheMiddleware(backend)
+	expectedErr := errors.New("some error")
+	calls := 0
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return nil, expectedErr
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := p(context.Background(), &Request{Method: "GET", Path: "/"}); err != expectedErr {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("unexpected number of calls: %d", calls)
+	}
+}
+
+func TestNewCacheMiddleware_etag(t *testing.T) {
+	store, _ := NewMemoryCacheStore(10)
+	c := cache{store: store, ttl: 20 * time.Millisecond}
+	calls := 0
+	p := func(_ context.Context, r *Request) (*Response, error) {
+		calls++
+		if r.Headers["If-None-Match"] != nil {
+			if r.Headers["If-None-Match"][0] != "abc" {
+				t.Errorf("unexpected etag: %v", r.Headers["If-None-Match"])
+			}
+			return nil, ErrNotModified
+		}
+		return &Response{
+			Data:       map[string]interface{}{"supu": 42},
+			IsComplete: true,
+			Metadata:   Metadata{Headers: map[string][]string{"Etag": {"abc"}}, StatusCode: 200},
+		}, nil
+	}
+
+	request := &Request{Method: "GET", Path: "/", Headers: map[string][]string{}}
+	if _, err := c.call(context.Background(), p, request); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(25 * time.Millisecond)
+	resp, err := c.call(context.Background(), p, request)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if calls != 2 {
+		t.Errorf("unexpected number of calls: %d", calls)
+	}
+	if resp.Data["supu"] != json.Number("42") || resp.Metadata.StatusCode != 200 || !resp.IsComplete {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	if len(request.Headers) != 0 {
+		t.Error("the original request was modified")
+	}
+}
+
+func TestNewCacheMiddleware_staleWhileRevalidate(t *testing.T) {
+	store, _ := NewMemoryCacheStore(10)
+	c := cache{store: store, ttl: 20 * time.Millisecond, staleWhileRevalidate: time.Second, timeout: time.Second}
+	calls := make(chan int, 10)
+	total := 0
+	p := func(_ context.Context, _ *Request) (*Response, error) {
+		total++
+		calls <- total
+		return &Response{Data: map[string]interface{}{"calls": total}, IsComplete: true}, nil
+	}
+
+	request := &Request{Method: "GET", Path: "/"}
+	c.call(context.Background(), p, request)
+	<-calls
+	time.Sleep(25 * time.Millisecond)
+
+	resp, err := c.call(context.Background(), p, request)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if resp.Data["calls"] != json.Number("1") {
+		t.Errorf("the stale response was not served: %v", resp.Data)
+	}
+
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Error("the entry was not refreshed in background")
+		return
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := c.refreshing.Load(c.key(request)); !ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, _ = c.call(context.Background(), p, request)
+	if resp.Data["calls"] != json.Number("2") {
+		t.Errorf("the refreshed response was not served: %v", resp.Data)
+	}
+}
+
+func TestNewCacheMiddleware_staleWhileRevalidateContext(t *testing.T) {
+	store, _ := NewMemoryCacheStore(10)
+	c := cache{store: store, ttl: 10 * time.Millisecond, staleWhileRevalidate: time.Second, timeout: time.Second}
+	result := make(chan error, 1)
+	calls := 0
+	p := func(ctx context.Context, _ *Request) (*Response, error) {
+		calls++
+		if calls == 1 {
+			return &Response{Data: map[string]interface{}{}, IsComplete: true}, nil
+		}
+		if id, _ := RequestIDFromContext(ctx); id != "42" {
+			result <- errors.New("the values of the request context were lost")
+			return nil, nil
+		}
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Second {
+			result <- errors.New("unexpected deadline")
+			return nil, nil
+		}
+		// the end of the request does not cancel the refresh
+		time.Sleep(20 * time.Millisecond)
+		result <- ctx.Err()
+		return &Response{Data: map[string]interface{}{}, IsComplete: true}, nil
+	}
+
+	request := &Request{Method: "GET", Path: "/"}
+	c.call(context.Background(), p, request)
+	time.Sleep(15 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(NewRequestIDContext(context.Background(), "X-Request-Id", "42"))
+	c.call(ctx, p, request)
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("the entry was not refreshed in background")
+	}
+}
+
+func TestNewCacheMiddleware_staleIfError(t *testing.T) {
+	store, _ := NewMemoryCacheStore(10)
+	c := cache{store: store, ttl: 20 * time.Millisecond, staleIfError: time.Second}
+	backendErr := errors.New("backend error")
+	failing := false
+	p := func(_ context.Context, _ *Request) (*Response, error) {
+		if failing {
+			return nil, backendErr
+		}
+		return &Response{Data: map[string]interface{}{"supu": 42}, IsComplete: true}, nil
+	}
+
+	request := &Request{Method: "GET", Path: "/"}
+	c.call(context.Background(), p, request)
+	time.Sleep(25 * time.Millisecond)
+	failing = true
+
+	resp, err := c.call(context.Background(), p, request)
+	if err != nil {
+		t.Errorf("the stale response was not served: %v", err)
+		return
+	}
+	if resp.Data["supu"] != json.Number("42") {
+		t.Errorf("unexpected response: %v", resp.Data)
+	}
+
+	c.staleIfError = time.Millisecond
+	if _, err := c.call(context.Background(), p, request); err != backendErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
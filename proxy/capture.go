@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// DefaultRedactedHeaders are the headers never recorded by the captures
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// CapturedLeg is a request sent to a backend and its response, recorded for debugging
+type CapturedLeg struct {
+	// Backend is the URL pattern of the backend
+	Backend        string              `json:"backend"`
+	Method         string              `json:"method"`
+	URL            string              `json:"url"`
+	RequestHeaders map[string][]string `json:"request_headers"`
+	RequestBody    string              `json:"request_body,omitempty"`
+	StatusCode     int                 `json:"status_code"`
+	Headers        map[string][]string `json:"headers,omitempty"`
+	// Data is the decoded response of the backend. The streamed and the no-op responses are not recorded
+	Data       json.RawMessage `json:"data,omitempty"`
+	IsComplete bool            `json:"is_complete"`
+	Error      string          `json:"error,omitempty"`
+	Duration   time.Duration   `json:"duration"`
+}
+
+// Capture collects the legs of a request while it is proxied. The canonical headers in Redacted are replaced
+// and only the first MaxBodySize bytes of the bodies are recorded
+type Capture struct {
+	MaxBodySize int
+	Redacted    []string
+
+	mu   sync.Mutex
+	legs []CapturedLeg
+}
+
+type captureKey struct{}
+
+// NewCaptureContext returns a copy of the context recording the legs of the request into the capture
+func NewCaptureContext(ctx context.Context, c *Capture) context.Context {
+	return context.WithValue(ctx, captureKey{}, c)
+}
+
+// Legs returns the legs recorded, in order of completion
+func (c *Capture) Legs() []CapturedLeg {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CapturedLeg{}, c.legs...)
+}
+
+// Headers returns a copy of the headers with the redacted ones replaced
+func (c *Capture) Headers(headers map[string][]string) map[string][]string {
+	h := make(map[string][]string, len(headers))
+	for k, vs := range headers {
+		h[k] = vs
+	}
+	for _, k := range c.Redacted {
+		if _, ok := h[k]; ok {
+			h[k] = []string{"[REDACTED]"}
+		}
+	}
+	return h
+}
+
+// Truncate returns the body truncated to the MaxBodySize
+func (c *Capture) Truncate(b []byte) string {
+	if len(b) > c.MaxBodySize {
+		return string(b[:c.MaxBodySize])
+	}
+	return string(b)
+}
+
+func (c *Capture) add(leg CapturedLeg) {
+	c.mu.Lock()
+	c.legs = append(c.legs, leg)
+	c.mu.Unlock()
+}
+
+// NewCaptureMiddleware creates a proxy middleware recording the requests sent to the backend and their
+// responses into the capture of the context, if any. Every attempt of the retries and the hedged requests
+// is recorded as a leg
+func NewCaptureMiddleware(remote *config.Backend) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			c, ok := ctx.Value(captureKey{}).(*Capture)
+			if !ok {
+				return next[0](ctx, request)
+			}
+
+			leg := CapturedLeg{
+				Backend:        remote.URLPattern,
+				Method:         request.Method,
+				RequestHeaders: c.Headers(request.Headers),
+			}
+			if request.URL != nil {
+				leg.URL = request.URL.String()
+			}
+			if request.Body != nil {
+				// only the recorded part of the body is buffered
+				b, err := ioutil.ReadAll(io.LimitReader(request.Body, int64(c.MaxBodySize)))
+				if err != nil {
+					return nil, err
+				}
+				leg.RequestBody = string(b)
+				r := request.Clone()
+				r.Body = readCloser{io.MultiReader(bytes.NewReader(b), request.Body), request.Body}
+				request = &r
+			}
+
+			start := time.Now()
+			resp, err := next[0](ctx, request)
+			leg.Duration = time.Since(start)
+			if err != nil {
+				leg.Error = err.Error()
+			}
+			if resp != nil {
+				leg.StatusCode = resp.Metadata.StatusCode
+				leg.Headers = c.Headers(resp.Metadata.Headers)
+				leg.IsComplete = resp.IsComplete
+				if resp.Io == nil && resp.Data != nil {
+					leg.Data, _ = json.Marshal(resp.Data)
+				}
+			}
+			c.add(leg)
+			return resp, err
+		}
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewCaptureMiddleware(t *testing.T) {
+	p := NewCaptureMiddleware(&config.Backend{URLPattern: "/users/{{.Id}}"})(func(_ context.Context, r *Request) (*Response, error) {
+		if b, _ := ioutil.ReadAll(r.Body); string(b) != `{"name":"supu"}` {
+			t.Errorf("the body was not restored: %s", string(b))
+		}
+		return &Response{
+			Data:       map[string]interface{}{"id": 42},
+			IsComplete: true,
+			Metadata:   Metadata{StatusCode: 200, Headers: map[string][]string{"Set-Cookie": {"session=abc"}}},
+		}, nil
+	})
+
+	u, _ := url.Parse("http://backend/users/42")
+	request := &Request{
+		Method:  "POST",
+		URL:     u,
+		Body:    ioutil.NopCloser(strings.NewReader(`{"name":"supu"}`)),
+		Headers: map[string][]string{"Authorization": {"Bearer secret"}, "Accept": {"application/json"}},
+	}
+
+	// the requests without a capture are not recorded
+	if _, err := p(context.Background(), &Request{Body: ioutil.NopCloser(strings.NewReader(`{"name":"supu"}`))}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Capture{MaxBodySize: 8, Redacted: DefaultRedactedHeaders}
+	if _, err := p(NewCaptureContext(context.Background(), c), request); err != nil {
+		t.Fatal(err)
+	}
+	legs := c.Legs()
+	if len(legs) != 1 {
+		t.Fatalf("unexpected legs: %v", legs)
+	}
+	leg := legs[0]
+	if leg.Backend != "/users/{{.Id}}" || leg.Method != "POST" || leg.URL != "http://backend/users/42" || leg.StatusCode != 200 {
+		t.Errorf("unexpected leg: %+v", leg)
+	}
+	if leg.RequestBody != `{"name":` {
+		t.Errorf("unexpected request body: %s", leg.RequestBody)
+	}
+	if !reflect.DeepEqual(leg.RequestHeaders, map[string][]string{"Authorization": {"[REDACTED]"}, "Accept": {"application/json"}}) {
+		t.Errorf("unexpected request headers: %v", leg.RequestHeaders)
+	}
+	if !reflect.DeepEqual(leg.Headers, map[string][]string{"Set-Cookie": {"[REDACTED]"}}) {
+		t.Errorf("unexpected headers: %v", leg.Headers)
+	}
+	if string(leg.Data) != `{"id":42}` {
+		t.Errorf("unexpected data: %s", string(leg.Data))
+	}
+	if request.Headers["Authorization"][0] != "Bearer secret" {
+		t.Error("the original headers have been modified")
+	}
+}
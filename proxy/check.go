@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// Check builds the proxy stack of every endpoint of the initialized configuration with the factory, without
+// serving any request, so the errors parsing the extra_config of the middlewares (regexes, templates,
+// policies...) are detected before deploying the configuration. It returns a diagnostic for every endpoint
+// the factory can not build, including the ones making it panic
+func Check(factory Factory, cfg config.ServiceConfig) config.Diagnostics {
+	diagnostics := config.Diagnostics{}
+	for i, e := range cfg.Endpoints {
+		if err := checkEndpoint(factory, e); err != nil {
+			diagnostics = append(diagnostics, config.Diagnostic{
+				Pointer: "/endpoints/" + strconv.Itoa(i),
+				Message: fmt.Sprintf("%s %s: %s", e.Method, e.Endpoint, err.Error()),
+			})
+		}
+	}
+	return diagnostics
+}
+
+func checkEndpoint(factory Factory, cfg *config.EndpointConfig) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("building the proxy: %v", r)
+		}
+	}()
+	_, err = factory.New(cfg)
+	return
+}
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestCheck(t *testing.T) {
+	factory := FactoryFunc(func(cfg *config.EndpointConfig) (Proxy, error) {
+		switch cfg.Endpoint {
+		case "/error":
+			return nil, errors.New("supu")
+		case "/panic":
+			panic("tupu")
+		}
+		return func(_ context.Context, _ *Request) (*Response, error) { return nil, nil }, nil
+	})
+	cfg := config.ServiceConfig{Endpoints: []*config.EndpointConfig{
+		{Endpoint: "/ok", Method: "GET"},
+		{Endpoint: "/error", Method: "GET"},
+		{Endpoint: "/panic", Method: "POST"},
+	}}
+
+	expected := config.Diagnostics{
+		{Pointer: "/endpoints/1", Message: "GET /error: supu"},
+		{Pointer: "/endpoints/2", Message: "POST /panic: building the proxy: tupu"},
+	}
+	if diagnostics := Check(factory, cfg); !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+}
@@ -0,0 +1,265 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	circuitBreakerKey = "circuit_breaker"
+
+	defaultCircuitBreakerMaxErrors   = 5
+	defaultCircuitBreakerWindow      = 10 * time.Second
+	defaultCircuitBreakerMinRequests = 10
+	defaultCircuitBreakerTimeout     = 30 * time.Second
+	defaultCircuitBreakerProbes      = 1
+)
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed is the state of the circuit breakers letting all the requests pass
+	CircuitClosed CircuitState = iota
+	// CircuitOpen is the state of the circuit breakers rejecting all the requests
+	CircuitOpen
+	// CircuitHalfOpen is the state of the circuit breakers letting some probe requests pass
+	CircuitHalfOpen
+)
+
+// String implements the fmt.Stringer interface
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// ErrCircuitOpen is the error returned when the circuit breaker rejects the request
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreakerListener is notified of every state transition of the circuit breakers
+type CircuitBreakerListener func(name string, from, to CircuitState)
+
+var (
+	circuitBreakerListeners []CircuitBreakerListener
+	circuitBreakers         = map[string]*circuitBreaker{}
+	circuitBreakersMu       sync.RWMutex
+)
+
+// RegisterCircuitBreakerListener registers a listener for the state transitions of the circuit breakers,
+// so the metrics layer can track them. It must be called before creating the proxies
+func RegisterCircuitBreakerListener(l CircuitBreakerListener) {
+	circuitBreakerListeners = append(circuitBreakerListeners, l)
+}
+
+// CircuitBreakerStates returns the current state of the circuit breakers, by name
+func CircuitBreakerStates() map[string]CircuitState {
+	circuitBreakersMu.RLock()
+	defer circuitBreakersMu.RUnlock()
+	states := make(map[string]CircuitState, len(circuitBreakers))
+	for name, cb := range circuitBreakers {
+		states[name] = cb.currentState()
+	}
+	return states
+}
+
+// NewCircuitBreakerMiddleware creates a proxy middleware with a circuit breaker. The circuit opens after
+// 'max_errors' consecutive failures or when the ratio of failures in the 'window' reaches the 'error_rate'
+// (with at least 'min_requests' requests). After the 'timeout', it lets 'half_open_requests' probes pass
+// and closes again if they succeed.
+//
+// The middleware is enabled with the 'circuit_breaker' option of the backend proxy extra config. The
+// circuit breakers are named after the 'name' option or, by default, after the URL pattern of the backend
+func NewCircuitBreakerMiddleware(remote *config.Backend) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	cfg, ok := extra[circuitBreakerKey].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+
+	cb := &circuitBreaker{
+		name:        remote.URLPattern,
+		maxErrors:   defaultCircuitBreakerMaxErrors,
+		window:      defaultCircuitBreakerWindow,
+		minRequests: defaultCircuitBreakerMinRequests,
+		timeout:     defaultCircuitBreakerTimeout,
+		probes:      defaultCircuitBreakerProbes,
+		now:         time.Now,
+	}
+	if v, ok := cfg["name"].(string); ok {
+		cb.name = v
+	}
+	for name, i := range map[string]*int{
+		"max_errors":         &cb.maxErrors,
+		"min_requests":       &cb.minRequests,
+		"half_open_requests": &cb.probes,
+	} {
+		if v, ok := cfg[name].(float64); ok {
+			*i = int(v)
+		}
+	}
+	if v, ok := cfg["error_rate"].(float64); ok {
+		cb.errorRate = v
+	}
+	for name, d := range map[string]*time.Duration{"window": &cb.window, "timeout": &cb.timeout} {
+		v, ok := cfg[name].(string)
+		if !ok {
+			continue
+		}
+		var err error
+		if *d, err = time.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+	cb.windowStart = cb.now()
+
+	circuitBreakersMu.Lock()
+	circuitBreakers[cb.name] = cb
+	circuitBreakersMu.Unlock()
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			allowed, isProbe := cb.allow()
+			if !allowed {
+				return nil, ErrCircuitOpen
+			}
+			resp, err := next[0](ctx, request)
+			cb.done(err == nil || err == context.Canceled, isProbe)
+			return resp, err
+		}
+	}, nil
+}
+
+type circuitBreaker struct {
+	name        string
+	maxErrors   int
+	errorRate   float64
+	window      time.Duration
+	minRequests int
+	timeout     time.Duration
+	probes      int
+	now         func() time.Time
+
+	mu                sync.Mutex
+	state             CircuitState
+	consecutiveErrors int
+	windowStart       time.Time
+	requests          int
+	failures          int
+	openedAt          time.Time
+	inflightProbes    int
+}
+
+func (cb *circuitBreaker) currentState() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// allow checks if the request can pass and if it is a probe of the half-open circuit
+func (cb *circuitBreaker) allow() (bool, bool) {
+	cb.mu.Lock()
+	from := cb.state
+	if cb.state == CircuitOpen && cb.now().Sub(cb.openedAt) >= cb.timeout {
+		cb.state = CircuitHalfOpen
+		cb.inflightProbes = 0
+	}
+	allowed, isProbe := true, false
+	switch cb.state {
+	case CircuitOpen:
+		allowed = false
+	case CircuitHalfOpen:
+		if cb.inflightProbes >= cb.probes {
+			allowed = false
+		} else {
+			cb.inflightProbes++
+			isProbe = true
+		}
+	}
+	to := cb.state
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+	return allowed, isProbe
+}
+
+func (cb *circuitBreaker) done(success, isProbe bool) {
+	cb.mu.Lock()
+	from := cb.state
+	switch {
+	case cb.state == CircuitHalfOpen && isProbe:
+		cb.inflightProbes--
+		if success {
+			cb.reset(CircuitClosed)
+		} else {
+			cb.trip()
+		}
+	case cb.state == CircuitClosed:
+		now := cb.now()
+		if now.Sub(cb.windowStart) >= cb.window {
+			cb.windowStart = now
+			cb.requests = 0
+			cb.failures = 0
+		}
+		cb.requests++
+		if success {
+			cb.consecutiveErrors = 0
+		} else {
+			cb.consecutiveErrors++
+			cb.failures++
+		}
+		if cb.shouldTrip() {
+			cb.trip()
+		}
+	}
+	to := cb.state
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+}
+
+func (cb *circuitBreaker) shouldTrip() bool {
+	if cb.maxErrors > 0 && cb.consecutiveErrors >= cb.maxErrors {
+		return true
+	}
+	return cb.errorRate > 0 && cb.requests >= cb.minRequests &&
+		float64(cb.failures)/float64(cb.requests) >= cb.errorRate
+}
+
+func (cb *circuitBreaker) trip() {
+	cb.reset(CircuitOpen)
+	cb.openedAt = cb.now()
+}
+
+func (cb *circuitBreaker) reset(state CircuitState) {
+	cb.state = state
+	cb.consecutiveErrors = 0
+	cb.requests = 0
+	cb.failures = 0
+	cb.windowStart = cb.now()
+}
+
+func (cb *circuitBreaker) notify(from, to CircuitState) {
+	if from == to {
+		return
+	}
+	for _, l := range circuitBreakerListeners {
+		l(cb.name, from, to)
+	}
+}
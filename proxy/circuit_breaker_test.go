@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewCircuitBreakerMiddleware_consecutiveErrors(t *testing.T) {
+	transitions := []string{}
+	defer func(ls []CircuitBreakerListener) { circuitBreakerListeners = ls }(circuitBreakerListeners)
+	RegisterCircuitBreakerListener(func(name string, from, to CircuitState) {
+		if name == "consecutive" {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		}
+	})
+
+	backend := &config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			circuitBreakerKey: map[string]interface{}{"name": "consecutive", "max_errors": 2.0, "timeout": "20ms"},
+		}},
+	}
+	mw, err := NewCircuitBreakerMiddleware(backend)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	backendErr := errors.New("backend error")
+	failing := true
+	calls := 0
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		if failing {
+			return nil, backendErr
+		}
+		return &Response{IsComplete: true}, nil
+	})
+
+	for _, expected := range []error{backendErr, backendErr, ErrCircuitOpen, ErrCircuitOpen} {
+		if _, err := p(context.Background(), &Request{}); err != expected {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("unexpected number of calls: %d", calls)
+	}
+	if s := CircuitBreakerStates()["consecutive"]; s != CircuitOpen {
+		t.Errorf("unexpected state: %s", s)
+	}
+
+	// the failed probe opens the circuit again
+	time.Sleep(25 * time.Millisecond)
+	if _, err := p(context.Background(), &Request{}); err != backendErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := p(context.Background(), &Request{}); err != ErrCircuitOpen {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// the successful probe closes the circuit
+	time.Sleep(25 * time.Millisecond)
+	failing = false
+	for i := 0; i < 3; i++ {
+		if _, err := p(context.Background(), &Request{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if s := CircuitBreakerStates()["consecutive"]; s != CircuitClosed {
+		t.Errorf("unexpected state: %s", s)
+	}
+
+	expected := []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}
+	if len(transitions) != len(expected) {
+		t.Errorf("unexpected transitions: %v", transitions)
+		return
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("unexpected transitions: %v", transitions)
+			return
+		}
+	}
+}
+
+func TestNewCircuitBreakerMiddleware_errorRate(t *testing.T) {
+	backend := &config.Backend{
+		URLPattern: "/rate",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			circuitBreakerKey: map[string]interface{}{"max_errors": 0.0, "error_rate": 0.5, "min_requests": 4.0},
+		}},
+	}
+	mw, _ := NewCircuitBreakerMiddleware(backend)
+	backendErr := errors.New("backend error")
+	calls := 0
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		if calls%2 == 0 {
+			return nil, backendErr
+		}
+		return &Response{IsComplete: true}, nil
+	})
+
+	for i := 0; i < 4; i++ {
+		p(context.Background(), &Request{})
+	}
+	if _, err := p(context.Background(), &Request{}); err != ErrCircuitOpen {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if s := CircuitBreakerStates()["/rate"]; s != CircuitOpen {
+		t.Errorf("unexpected state: %s", s)
+	}
+}
+
+func TestNewCircuitBreakerMiddleware_halfOpenProbes(t *testing.T) {
+	now := time.Now()
+	cb := &circuitBreaker{name: "probes", maxErrors: 1, timeout: time.Second, probes: 1, now: func() time.Time { return now }}
+	cb.done(false, false)
+	if allowed, _ := cb.allow(); allowed {
+		t.Error("the open circuit must reject the requests")
+	}
+	now = now.Add(time.Second)
+	if allowed, isProbe := cb.allow(); !allowed || !isProbe {
+		t.Error("the half-open circuit must accept a probe")
+	}
+	if allowed, _ := cb.allow(); allowed {
+		t.Error("the half-open circuit must reject the requests exceeding the probes")
+	}
+	// the late responses of the requests sent before opening the circuit are ignored
+	cb.done(true, false)
+	if s := cb.currentState(); s != CircuitHalfOpen {
+		t.Errorf("unexpected state: %s", s)
+	}
+	cb.done(true, true)
+	if s := cb.currentState(); s != CircuitClosed {
+		t.Errorf("unexpected state: %s", s)
+	}
+}
+
+func TestNewCircuitBreakerMiddleware_koConfig(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"window": "bad"},
+		{"timeout": "bad"},
+	} {
+		backend := &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{circuitBreakerKey: cfg}}}
+		if _, err := NewCircuitBreakerMiddleware(backend); err == nil {
+			t.Errorf("expecting an error with the config %v", cfg)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const coerceName = "coerce"
+
+var coercers = map[string]func(interface{}) (interface{}, bool){
+	"int":     coerceInt,
+	"float":   coerceFloat,
+	"bool":    coerceBool,
+	"string":  coerceString,
+	"rfc3339": coerceRFC3339,
+}
+
+// newCoerceFormatter creates a formatter converting the values at the configured dotted paths to
+// the configured types. Values that can not be converted are left untouched
+func newCoerceFormatter(cfg interface{}) (EntityFormatter, error) {
+	rules, ok := cfg.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid coerce config: %v", cfg)
+	}
+	type coercion struct {
+		path    []string
+		coercer func(interface{}) (interface{}, bool)
+	}
+	coercions := make([]coercion, 0, len(rules))
+	for path, t := range rules {
+		name, _ := t.(string)
+		c, ok := coercers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown coercion type for %s: %v", path, t)
+		}
+		coercions = append(coercions, coercion{strings.Split(path, "."), c})
+	}
+
+	return EntityFormatterFunc(func(entity Response) Response {
+		for _, c := range coercions {
+			updateAtPath(entity.Data, c.path, c.coercer)
+		}
+		return entity
+	}), nil
+}
+
+// updateAtPath replaces the values found at the received path with the result of f. Arrays are
+// traversed, so f is applied to the value at the path of every element
+func updateAtPath(v interface{}, path []string, f func(interface{}) (interface{}, bool)) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		value, ok := t[path[0]]
+		if !ok {
+			return
+		}
+		if len(path) > 1 {
+			updateAtPath(value, path[1:], f)
+			return
+		}
+		if res, ok := f(value); ok {
+			t[path[0]] = res
+		}
+	case []interface{}:
+		for _, element := range t {
+			updateAtPath(element, path, f)
+		}
+	}
+}
+
+func coerceInt(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64)
+		if err != nil {
+			f, ferr := strconv.ParseFloat(strings.TrimSpace(t), 64)
+			return int64(f), ferr == nil
+		}
+		return i, true
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, true
+		}
+		f, err := t.Float64()
+		return int64(f), err == nil
+	case float64:
+		return int64(t), true
+	case bool:
+		if t {
+			return int64(1), true
+		}
+		return int64(0), true
+	}
+	return v, false
+}
+
+func coerceFloat(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, err == nil
+	case json.Number:
+		f, err := t.Float64()
+		return f, err == nil
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	}
+	return v, false
+}
+
+func coerceBool(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(t))
+		return b, err == nil
+	case json.Number:
+		f, err := t.Float64()
+		return f != 0, err == nil
+	case float64:
+		return t != 0, true
+	}
+	return v, false
+}
+
+func coerceString(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, false
+	case json.Number:
+		return t.String(), true
+	case map[string]interface{}, []interface{}, nil:
+		return v, false
+	}
+	return fmt.Sprint(v), true
+}
+
+func coerceRFC3339(v interface{}) (interface{}, bool) {
+	epoch, ok := coerceInt(v)
+	if !ok {
+		return v, false
+	}
+	return time.Unix(epoch.(int64), 0).UTC().Format(time.RFC3339), true
+}
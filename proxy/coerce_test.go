@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCoerceFormatter(t *testing.T) {
+	f, err := newCoerceFormatter(map[string]interface{}{
+		"id":               "int",
+		"price":            "float",
+		"active":           "bool",
+		"code":             "string",
+		"created":          "rfc3339",
+		"items.qty":        "int",
+		"nested.deep.flag": "bool",
+		"wrong":            "int",
+		"unknown":          "int",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := f.Format(Response{
+		Data: map[string]interface{}{
+			"id":      "42",
+			"price":   json.Number("4.20"),
+			"active":  "true",
+			"code":    json.Number("7"),
+			"created": "1500000000",
+			"items": []interface{}{
+				map[string]interface{}{"qty": "1"},
+				map[string]interface{}{"qty": "2.0"},
+				map[string]interface{}{"other": "3"},
+			},
+			"nested": map[string]interface{}{"deep": map[string]interface{}{"flag": "0"}},
+			"wrong":  "not a number",
+		},
+		IsComplete: true,
+	})
+
+	expected := map[string]interface{}{
+		"id":      int64(42),
+		"price":   4.2,
+		"active":  true,
+		"code":    "7",
+		"created": "2017-07-14T02:40:00Z",
+		"wrong":   "not a number",
+	}
+	for k, v := range expected {
+		if result.Data[k] != v {
+			t.Errorf("unexpected value for %s: %v (%T)", k, result.Data[k], result.Data[k])
+		}
+	}
+	items := result.Data["items"].([]interface{})
+	for i, qty := range []int64{1, 2} {
+		if v := items[i].(map[string]interface{})["qty"]; v != qty {
+			t.Errorf("unexpected qty for item %d: %v", i, v)
+		}
+	}
+	if v := result.Data["nested"].(map[string]interface{})["deep"].(map[string]interface{})["flag"]; v != false {
+		t.Errorf("unexpected value for nested.deep.flag: %v", v)
+	}
+}
+
+func TestCoerceFormatter_ko(t *testing.T) {
+	for _, cfg := range []interface{}{
+		"int",
+		map[string]interface{}{"a": "unknown"},
+		map[string]interface{}{"a": 42},
+	} {
+		if _, err := newCoerceFormatter(cfg); err == nil {
+			t.Errorf("expecting an error with %v", cfg)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+const collapseKey = "collapse"
+
+// RequestKeyFunc identifies the requests to collapse
+type RequestKeyFunc func(*Request) string
+
+// NewCollapsingMiddleware creates a proxy middleware collapsing the concurrent GET requests to the
+// backend with the same method, path, query string and configured headers, so only one of them
+// reaches the backend and its response is shared by all of them.
+//
+// The middleware is enabled with the 'collapse' option of the backend proxy extra config. Its value
+// can be a boolean or an object with the list of 'headers' to add to the key of the requests. The
+// streamed and the no-op backends are never collapsed, since their responses keep the body reader of
+// the backend, closed with the context of the shared call
+func NewCollapsingMiddleware(remote *config.Backend) Middleware {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware
+	}
+	headers := []string{}
+	switch cfg := extra[collapseKey].(type) {
+	case bool:
+		if !cfg {
+			return EmptyMiddleware
+		}
+	case map[string]interface{}:
+		v, _ := cfg["headers"].([]interface{})
+		for _, h := range v {
+			if name, ok := h.(string); ok {
+				headers = append(headers, name)
+			}
+		}
+	default:
+		return EmptyMiddleware
+	}
+	if (remote.Method != "" && remote.Method != http.MethodGet) || isStreamingEnabled(remote) ||
+		remote.Encoding == encoding.NOOP {
+		return EmptyMiddleware
+	}
+
+	return NewCollapsingMiddlewareWithKeyFunc(remote, func(r *Request) string {
+		return requestKey(r, headers)
+	})
+}
+
+// NewCollapsingMiddlewareWithKeyFunc creates a proxy middleware collapsing the concurrent requests
+// with the same key. The shared call to the backend is not canceled by the callers, but it times
+// out after the backend timeout. It keeps the values of the context of the first caller, like its
+// request ID and its trace
+func NewCollapsingMiddlewareWithKeyFunc(remote *config.Backend, keyF RequestKeyFunc) Middleware {
+	timeout := remote.Timeout
+	if timeout == 0 {
+		timeout = config.DefaultTimeout
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		c := &collapser{
+			calls:   map[string]*inflightCall{},
+			timeout: timeout,
+			next:    next[0],
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			return c.do(ctx, keyF(request), request)
+		}
+	}
+}
+
+type collapser struct {
+	mu      sync.Mutex
+	calls   map[string]*inflightCall
+	timeout time.Duration
+	next    Proxy
+}
+
+type inflightCall struct {
+	done     chan struct{}
+	response *Response
+	err      error
+}
+
+func (c *collapser) do(ctx context.Context, key string, request *Request) (*Response, error) {
+	c.mu.Lock()
+	call, ok := c.calls[key]
+	if !ok {
+		call = &inflightCall{done: make(chan struct{})}
+		c.calls[key] = call
+		go c.run(ctx, key, call, request)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-call.done:
+	}
+	if call.response == nil {
+		return nil, call.err
+	}
+	// every caller gets its own copy, so the next layers can modify it safely
+	response := *call.response
+	response.Data = cloneData(call.response.Data)
+	return &response, call.err
+}
+
+func (c *collapser) run(leaderCtx context.Context, key string, call *inflightCall, request *Request) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(leaderCtx), c.timeout)
+	call.response, call.err = c.next(ctx, request)
+	cancel()
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	close(call.done)
+}
+
+func cloneData(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	res := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		res[k] = cloneValue(v)
+	}
+	return res
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return cloneData(t)
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for i, e := range t {
+			res[i] = cloneValue(e)
+		}
+		return res
+	}
+	return v
+}
@@ -0,0 +1,229 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+func TestNewCollapsingMiddleware(t *testing.T) {
+	backend := &config.Backend{
+		Method:      "GET",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{collapseKey: true}},
+	}
+	var calls int32
+	release := make(chan struct{})
+	p := NewCollapsingMiddleware(backend)(func(_ context.Context, _ *Request) (*Response, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &Response{Data: map[string]interface{}{"supu": map[string]interface{}{"tupu": 42}}, IsComplete: true}, nil
+	})
+
+	total := 10
+	responses := make([]*Response, total)
+	wg := sync.WaitGroup{}
+	wg.Add(total)
+	for i := 0; i < total; i++ {
+		go func(i int) {
+			defer wg.Done()
+			resp, err := p(context.Background(), &Request{Method: "GET", Path: "/a"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			responses[i] = resp
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if c := atomic.LoadInt32(&calls); c != 1 {
+		t.Errorf("unexpected number of calls: %d", c)
+	}
+	responses[0].Data["supu"].(map[string]interface{})["tupu"] = 0
+	for _, resp := range responses[1:] {
+		if resp == nil || resp.Data["supu"].(map[string]interface{})["tupu"] != 42 {
+			t.Errorf("unexpected response: %v", resp)
+		}
+	}
+
+	// once the call is done, the next requests reach the backend
+	if _, err := p(context.Background(), &Request{Method: "GET", Path: "/a"}); err != nil {
+		t.Error(err)
+	}
+	if c := atomic.LoadInt32(&calls); c != 2 {
+		t.Errorf("unexpected number of calls: %d", c)
+	}
+}
+
+func TestNewCollapsingMiddlewareWithKeyFunc(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	backendErr := errors.New("backend error")
+	p := NewCollapsingMiddlewareWithKeyFunc(&config.Backend{}, func(r *Request) string {
+		return r.Path
+	})(func(_ context.Context, _ *Request) (*Response, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return nil, backendErr
+	})
+
+	wg := sync.WaitGroup{}
+	for _, path := range []string{"/a", "/a", "/b"} {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			if _, err := p(context.Background(), &Request{Path: path}); err != backendErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(path)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if c := atomic.LoadInt32(&calls); c != 2 {
+		t.Errorf("unexpected number of calls: %d", c)
+	}
+}
+
+func TestNewCollapsingMiddleware_canceledCaller(t *testing.T) {
+	release := make(chan struct{})
+	p := NewCollapsingMiddlewareWithKeyFunc(&config.Backend{}, func(r *Request) string {
+		return r.Path
+	})(func(_ context.Context, _ *Request) (*Response, error) {
+		<-release
+		return &Response{Data: map[string]interface{}{}, IsComplete: true}, nil
+	})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p(ctx, &Request{Path: "/a"}); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewCollapsingMiddleware_leaderContext(t *testing.T) {
+	type key struct{}
+	result := make(chan error, 1)
+	p := NewCollapsingMiddlewareWithKeyFunc(&config.Backend{Timeout: time.Second}, func(r *Request) string {
+		return r.Path
+	})(func(ctx context.Context, _ *Request) (*Response, error) {
+		if id, _ := RequestIDFromContext(ctx); id != "42" || ctx.Value(key{}) != "supu" {
+			result <- errors.New("the values of the leader context were lost")
+			return nil, nil
+		}
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Second {
+			result <- errors.New("unexpected deadline")
+			return nil, nil
+		}
+		// the cancelation of the leader does not cancel the shared call
+		time.Sleep(20 * time.Millisecond)
+		result <- ctx.Err()
+		return &Response{Data: map[string]interface{}{}, IsComplete: true}, nil
+	})
+
+	ctx := NewRequestIDContext(context.WithValue(context.Background(), key{}, "supu"), "X-Request-Id", "42")
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	p(ctx, &Request{Path: "/a"})
+	if err := <-result; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewCollapsingMiddleware_disabled(t *testing.T) {
+	for _, backend := range []*config.Backend{
+		{},
+		{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{collapseKey: false}}},
+		{Method: "POST", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{collapseKey: true}}},
+		{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{collapseKey: true, streamKey: true}}},
+	} {
+		var calls int32
+		release := make(chan struct{})
+		p := NewCollapsingMiddleware(backend)(func(_ context.Context, _ *Request) (*Response, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return &Response{}, nil
+		})
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				p(context.Background(), &Request{Method: "GET", Path: "/a"})
+				wg.Done()
+			}()
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		if c := atomic.LoadInt32(&calls); c != 2 {
+			t.Errorf("unexpected number of calls: %d", c)
+		}
+	}
+}
+
+func TestNewCollapsingMiddleware_noop(t *testing.T) {
+	backend := &config.Backend{
+		Encoding:    encoding.NOOP,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{collapseKey: true}},
+	}
+	var calls int32
+	release := make(chan struct{})
+	p := NewCollapsingMiddleware(backend)(func(ctx context.Context, _ *Request) (*Response, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &Response{Io: ctxReader{ctx: ctx, r: strings.NewReader("supu")}, IsComplete: true}, nil
+	})
+
+	total := 2
+	errs := make(chan error, total)
+	for i := 0; i < total; i++ {
+		go func() {
+			resp, err := p(context.Background(), &Request{Method: "GET", Path: "/a"})
+			if err != nil {
+				errs <- err
+				return
+			}
+			b, err := io.ReadAll(resp.Io)
+			if err == nil && string(b) != "supu" {
+				err = fmt.Errorf("unexpected body: %s", string(b))
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < total; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if c := atomic.LoadInt32(&calls); c != int32(total) {
+		t.Errorf("unexpected number of calls: %d", c)
+	}
+}
+
+// ctxReader fails once its context is done, like the body of a response bound to its request
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
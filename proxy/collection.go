@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const collectionName = "collection"
+
+// collectionConfig defines the operations to apply over the collection of the response, this is,
+// the array placed under the 'collection' key by the collection decoders
+type collectionConfig struct {
+	// Filter keeps only the elements with the given values at the given dotted paths
+	Filter map[string]interface{} `json:"filter"`
+	// Reverse reverses the order of the elements
+	Reverse bool `json:"reverse"`
+	// Offset is the number of elements to skip
+	Offset int `json:"offset"`
+	// Limit is the max number of elements to keep. If 0, all the elements are kept
+	Limit int `json:"limit"`
+	// Whitelist is the set of fields to keep from every element
+	Whitelist []string `json:"whitelist"`
+	// Blacklist is the set of fields to remove from every element
+	Blacklist []string `json:"blacklist"`
+}
+
+func newCollectionFormatter(cfg interface{}) (EntityFormatter, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := collectionConfig{}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("invalid collection config: %s", err.Error())
+	}
+	if c.Offset < 0 || c.Limit < 0 {
+		return nil, fmt.Errorf("invalid collection config: offset and limit can not be negative")
+	}
+
+	filter := make(map[string]string, len(c.Filter))
+	for k, v := range c.Filter {
+		filter[k] = fmt.Sprint(v)
+	}
+	whitelist := newFieldTree(c.Whitelist)
+	blacklist := newFieldTree(c.Blacklist)
+
+	return EntityFormatterFunc(func(entity Response) Response {
+		collection, ok := entity.Data[collectionName].([]interface{})
+		if !ok {
+			return entity
+		}
+
+		result := make([]interface{}, 0, len(collection))
+		for _, element := range collection {
+			if matchesCollectionFilter(element, filter) {
+				result = append(result, element)
+			}
+		}
+
+		if c.Reverse {
+			for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+				result[i], result[j] = result[j], result[i]
+			}
+		}
+
+		if c.Offset >= len(result) {
+			result = result[:0]
+		} else {
+			result = result[c.Offset:]
+		}
+		if c.Limit > 0 && c.Limit < len(result) {
+			result = result[:c.Limit]
+		}
+
+		if len(whitelist) > 0 {
+			filtered := make([]interface{}, 0, len(result))
+			for _, element := range result {
+				if tmp, ok := whitelistFilterSub(element, whitelist); ok {
+					filtered = append(filtered, tmp)
+				}
+			}
+			result = filtered
+		} else if len(blacklist) > 0 {
+			blacklistFilterSub(result, blacklist)
+		}
+
+		entity.Data[collectionName] = result
+		return entity
+	}), nil
+}
+
+func matchesCollectionFilter(element interface{}, filter map[string]string) bool {
+	for path, expected := range filter {
+		v := element
+		for _, k := range strings.Split(path, ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			if v, ok = m[k]; !ok {
+				return false
+			}
+		}
+		if fmt.Sprint(v) != expected {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func collectionSample() Response {
+	collection := []interface{}{}
+	for i := 0; i < 10; i++ {
+		status := "active"
+		if i%2 == 1 {
+			status = "inactive"
+		}
+		collection = append(collection, map[string]interface{}{
+			"id":     json.Number(strconv.Itoa(i)),
+			"status": map[string]interface{}{"name": status},
+			"secret": "s",
+		})
+	}
+	return Response{Data: map[string]interface{}{"collection": collection}, IsComplete: true}
+}
+
+func TestCollectionFormatter(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      map[string]interface{}
+		expected []string
+		fields   int
+	}{
+		{
+			name:     "noop",
+			cfg:      map[string]interface{}{},
+			expected: []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"},
+			fields:   3,
+		},
+		{
+			name:     "slice",
+			cfg:      map[string]interface{}{"offset": 2.0, "limit": 3.0},
+			expected: []string{"2", "3", "4"},
+			fields:   3,
+		},
+		{
+			name:     "out of range",
+			cfg:      map[string]interface{}{"offset": 20.0},
+			expected: []string{},
+		},
+		{
+			name:     "reverse",
+			cfg:      map[string]interface{}{"reverse": true, "limit": 2.0},
+			expected: []string{"9", "8"},
+			fields:   3,
+		},
+		{
+			name:     "filter",
+			cfg:      map[string]interface{}{"filter": map[string]interface{}{"status.name": "active"}, "whitelist": []interface{}{"id"}},
+			expected: []string{"0", "2", "4", "6", "8"},
+			fields:   1,
+		},
+		{
+			name:     "filter by number",
+			cfg:      map[string]interface{}{"filter": map[string]interface{}{"id": 3.0}, "blacklist": []interface{}{"secret", "status.name"}},
+			expected: []string{"3"},
+			fields:   2,
+		},
+	} {
+		f, err := newCollectionFormatter(tc.cfg)
+		if err != nil {
+			t.Errorf("%s: %s", tc.name, err.Error())
+			continue
+		}
+		result := f.Format(collectionSample())
+		collection := result.Data["collection"].([]interface{})
+		if len(collection) != len(tc.expected) {
+			t.Errorf("%s: unexpected result: %v", tc.name, collection)
+			continue
+		}
+		for i, id := range tc.expected {
+			element := collection[i].(map[string]interface{})
+			if element["id"].(json.Number).String() != id {
+				t.Errorf("%s: unexpected element %d: %v", tc.name, i, element)
+			}
+			if len(element) != tc.fields {
+				t.Errorf("%s: unexpected size of the element %d: %v", tc.name, i, element)
+			}
+		}
+	}
+}
+
+func TestCollectionFormatter_notACollection(t *testing.T) {
+	f, err := newCollectionFormatter(map[string]interface{}{"limit": 1.0})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := f.Format(Response{Data: map[string]interface{}{"supu": 42}})
+	if len(result.Data) != 1 || result.Data["supu"] != 42 {
+		t.Errorf("unexpected result: %v", result.Data)
+	}
+}
+
+func TestCollectionFormatter_ko(t *testing.T) {
+	for _, cfg := range []interface{}{
+		map[string]interface{}{"limit": "wrong"},
+		map[string]interface{}{"offset": -1.0},
+	} {
+		if _, err := newCollectionFormatter(cfg); err == nil {
+			t.Errorf("expecting an error with %v", cfg)
+		}
+	}
+}
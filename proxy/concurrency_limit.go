@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	adaptiveConcurrencyKey = "adaptive_concurrency"
+
+	defaultInitialConcurrencyLimit = 20
+	defaultMinConcurrencyLimit     = 1
+	defaultMaxConcurrencyLimit     = 1000
+	defaultLatencyTolerance        = 2.0
+	defaultConcurrencyBackoff      = 0.9
+	// minRTTResetSamples is the number of samples after which the min RTT is measured again, so the
+	// limiter can adapt to the permanent changes of the backend latency
+	minRTTResetSamples = 1000
+)
+
+// ErrConcurrencyLimitExceeded is the error returned when the adaptive concurrency limit of the backend
+// host is reached. It is translated into a 503 by the routers
+var ErrConcurrencyLimitExceeded = serviceUnavailableError("concurrency limit exceeded")
+
+type serviceUnavailableError string
+
+// Error implements the error interface
+func (s serviceUnavailableError) Error() string { return string(s) }
+
+// StatusCode returns the status code to send to the client
+func (s serviceUnavailableError) StatusCode() int { return http.StatusServiceUnavailable }
+
+// NewAdaptiveConcurrencyMiddleware creates a proxy middleware limiting the concurrent requests to every
+// host of the backend with an AIMD algorithm: the limit grows by one with every successful request sent
+// while the host is busy and it is multiplied by the 'backoff_ratio' when the request fails or its latency
+// exceeds the min observed one times the 'latency_tolerance'. The requests over the limit are rejected.
+//
+// The middleware is enabled with the 'adaptive_concurrency' option of the backend proxy extra config,
+// and it must be placed after the load balancer, so the requests have the URL of the host
+func NewAdaptiveConcurrencyMiddleware(remote *config.Backend) Middleware {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware
+	}
+	cfg, ok := extra[adaptiveConcurrencyKey].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware
+	}
+
+	l := limiterConfig{
+		initial:   defaultInitialConcurrencyLimit,
+		min:       defaultMinConcurrencyLimit,
+		max:       defaultMaxConcurrencyLimit,
+		tolerance: defaultLatencyTolerance,
+		backoff:   defaultConcurrencyBackoff,
+	}
+	for name, v := range map[string]*float64{
+		"initial_limit":     &l.initial,
+		"min_limit":         &l.min,
+		"max_limit":         &l.max,
+		"latency_tolerance": &l.tolerance,
+		"backoff_ratio":     &l.backoff,
+	} {
+		if f, ok := cfg[name].(float64); ok {
+			*v = f
+		}
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		limiters := map[string]*concurrencyLimiter{}
+		mu := &sync.Mutex{}
+
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			host := ""
+			if request.URL != nil {
+				host = request.URL.Host
+			}
+			mu.Lock()
+			limiter, ok := limiters[host]
+			if !ok {
+				limiter = newConcurrencyLimiter(l)
+				limiters[host] = limiter
+			}
+			mu.Unlock()
+
+			if !limiter.acquire() {
+				return nil, ErrConcurrencyLimitExceeded
+			}
+			start := time.Now()
+			resp, err := next[0](ctx, request)
+			limiter.release(time.Since(start), err == nil || err == context.Canceled)
+			return resp, err
+		}
+	}
+}
+
+type limiterConfig struct {
+	initial   float64
+	min       float64
+	max       float64
+	tolerance float64
+	backoff   float64
+}
+
+type concurrencyLimiter struct {
+	cfg      limiterConfig
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	minRTT   time.Duration
+	samples  int
+}
+
+func newConcurrencyLimiter(cfg limiterConfig) *concurrencyLimiter {
+	return &concurrencyLimiter{cfg: cfg, limit: cfg.initial}
+}
+
+func (c *concurrencyLimiter) acquire() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if float64(c.inflight) >= c.limit {
+		return false
+	}
+	c.inflight++
+	return true
+}
+
+func (c *concurrencyLimiter) release(rtt time.Duration, success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the inflight requests include the one being released
+	busy := float64(c.inflight)*2 >= c.limit
+	c.inflight--
+
+	c.samples++
+	if c.samples >= minRTTResetSamples {
+		c.samples = 0
+		c.minRTT = 0
+	}
+	if success && (c.minRTT == 0 || rtt < c.minRTT) {
+		c.minRTT = rtt
+	}
+
+	switch {
+	case !success || float64(rtt) > float64(c.minRTT)*c.cfg.tolerance:
+		c.limit *= c.cfg.backoff
+	case busy:
+		c.limit++
+	}
+
+	if c.limit < c.cfg.min {
+		c.limit = c.cfg.min
+	}
+	if c.limit > c.cfg.max {
+		c.limit = c.cfg.max
+	}
+}
+
+func (c *concurrencyLimiter) currentLimit() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewAdaptiveConcurrencyMiddleware_shedding(t *testing.T) {
+	backend := &config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			adaptiveConcurrencyKey: map[string]interface{}{"initial_limit": 2.0},
+		}},
+	}
+	release := make(chan struct{})
+	p := NewAdaptiveConcurrencyMiddleware(backend)(func(_ context.Context, _ *Request) (*Response, error) {
+		<-release
+		return &Response{IsComplete: true}, nil
+	})
+
+	hostA, _ := url.Parse("http://a.example.com/supu")
+	hostB, _ := url.Parse("http://b.example.com/supu")
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p(context.Background(), &Request{URL: hostA}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := p(context.Background(), &Request{URL: hostA})
+	if err != ErrConcurrencyLimitExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if e, ok := err.(serviceUnavailableError); !ok || e.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// the limits are tracked by host
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := p(context.Background(), &Request{URL: hostB}); err != nil {
+			t.Error(err)
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func TestConcurrencyLimiter_aimd(t *testing.T) {
+	l := newConcurrencyLimiter(limiterConfig{initial: 4, min: 2, max: 5, tolerance: 2, backoff: 0.5})
+
+	for i := 0; i < 2; i++ {
+		l.acquire()
+	}
+	l.release(10*time.Millisecond, true)
+	if limit := l.currentLimit(); limit != 5 {
+		t.Errorf("the limit was not increased: %f", limit)
+	}
+	l.release(10*time.Millisecond, true)
+	if limit := l.currentLimit(); limit != 5 {
+		t.Errorf("the limit was not capped: %f", limit)
+	}
+
+	l.acquire()
+	l.release(30*time.Millisecond, true)
+	if limit := l.currentLimit(); limit != 2.5 {
+		t.Errorf("the limit was not decreased by the latency: %f", limit)
+	}
+
+	l.acquire()
+	l.release(10*time.Millisecond, false)
+	if limit := l.currentLimit(); limit != 2 {
+		t.Errorf("the limit was not decreased by the error: %f", limit)
+	}
+}
+
+func TestNewAdaptiveConcurrencyMiddleware_disabled(t *testing.T) {
+	backendErr := errors.New("backend error")
+	p := NewAdaptiveConcurrencyMiddleware(&config.Backend{})(func(_ context.Context, _ *Request) (*Response, error) {
+		return nil, backendErr
+	})
+	if _, err := p(context.Background(), &Request{}); err != backendErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	deadlineKey = "deadline"
+
+	// DeadlineHeaderName is the name of the header propagating the deadline of the request to the backends
+	DeadlineHeaderName = "X-Request-Deadline"
+)
+
+// ErrInvalidTimeoutFraction is the error returned when the fraction of the endpoint timeout is not in (0, 1]
+var ErrInvalidTimeoutFraction = errors.New("the timeout fraction must be greater than 0 and lower or equal than 1")
+
+type propagateDeadlineKey struct{}
+
+// NewDeadlineMiddleware creates a proxy middleware limiting the time available for the backend to the
+// 'timeout_fraction' of the endpoint timeout, so a slow backend does not consume the budget of the
+// following sequential calls. The timeout is always capped by the deadline of the received context.
+// With the 'propagate' flag, the deadline of every request is sent to the backend in the
+// X-Request-Deadline header (RFC 3339 with milliseconds, in UTC).
+//
+// The middleware is enabled with the 'deadline' option of the backend proxy extra config
+func NewDeadlineMiddleware(remote *config.Backend) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	cfg, ok := extra[deadlineKey].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+
+	var timeout time.Duration
+	if v, ok := cfg["timeout_fraction"].(float64); ok {
+		if v <= 0 || v > 1 {
+			return nil, ErrInvalidTimeoutFraction
+		}
+		endpointTimeout := remote.Timeout
+		if endpointTimeout == 0 {
+			endpointTimeout = config.DefaultTimeout
+		}
+		timeout = time.Duration(v * float64(endpointTimeout))
+	}
+	propagate, _ := cfg["propagate"].(bool)
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			if propagate {
+				ctx = context.WithValue(ctx, propagateDeadlineKey{}, true)
+			}
+			if timeout == 0 {
+				return next[0](ctx, request)
+			}
+			localCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next[0](localCtx, request)
+		}
+	}, nil
+}
+
+// addDeadlineHeader adds the deadline of the context to the headers of the request to the backend, when
+// its propagation is enabled. The headers are copied, since they are shared by the requests
+func addDeadlineHeader(ctx context.Context, r *http.Request) {
+	if propagate, _ := ctx.Value(propagateDeadlineKey{}).(bool); !propagate {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	headers := make(http.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		headers[k] = v
+	}
+	headers.Set(DeadlineHeaderName, deadline.UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+	r.Header = headers
+}
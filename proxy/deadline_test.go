@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+func deadlineBackend(cfg map[string]interface{}) *config.Backend {
+	return &config.Backend{
+		Timeout:     time.Second,
+		Decoder:     encoding.JSONDecoder,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{deadlineKey: cfg}},
+	}
+}
+
+func TestNewDeadlineMiddleware_koConfig(t *testing.T) {
+	for i, fraction := range []float64{0, -0.5, 1.5} {
+		if _, err := NewDeadlineMiddleware(deadlineBackend(map[string]interface{}{"timeout_fraction": fraction})); err != ErrInvalidTimeoutFraction {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestNewDeadlineMiddleware_timeoutFraction(t *testing.T) {
+	mw, err := NewDeadlineMiddleware(deadlineBackend(map[string]interface{}{"timeout_fraction": 0.25}))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	p := mw(func(ctx context.Context, _ *Request) (*Response, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			t.Error("the context has no deadline")
+			return nil, nil
+		}
+		if remaining := time.Until(deadline); remaining > 250*time.Millisecond || remaining < 200*time.Millisecond {
+			t.Errorf("unexpected remaining time: %v", remaining)
+		}
+		return &Response{IsComplete: true}, nil
+	})
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+
+	// the deadline of the received context is respected
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	p = mw(func(ctx context.Context, _ *Request) (*Response, error) {
+		if deadline, _ := ctx.Deadline(); time.Until(deadline) > 10*time.Millisecond {
+			t.Error("the deadline of the parent context was extended")
+		}
+		return &Response{IsComplete: true}, nil
+	})
+	p(ctx, &Request{})
+}
+
+func TestNewDeadlineMiddleware_propagate(t *testing.T) {
+	headers := make(chan string, 1)
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Get(DeadlineHeaderName)
+		fmt.Fprint(w, "{}")
+	}))
+	defer backendServer.Close()
+
+	backend := deadlineBackend(map[string]interface{}{"propagate": true})
+	mw, err := NewDeadlineMiddleware(backend)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	p := mw(HTTPProxyFactory(http.DefaultClient)(backend))
+
+	sharedHeaders := map[string][]string{"X-Supu": {"tupu"}}
+	rpURL, _ := url.Parse(backendServer.URL)
+	deadline := time.Now().Add(time.Second)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	if _, err := p(ctx, &Request{Method: "GET", URL: rpURL, Body: newDummyReadCloser(""), Headers: sharedHeaders}); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+
+	h := <-headers
+	received, err := time.Parse(time.RFC3339Nano, h)
+	if err != nil {
+		t.Errorf("unexpected header %s: %s", h, err.Error())
+		return
+	}
+	if received.Sub(deadline) > time.Millisecond || deadline.Sub(received) > time.Millisecond {
+		t.Errorf("unexpected deadline: %v", received)
+	}
+	if _, ok := sharedHeaders[DeadlineHeaderName]; ok {
+		t.Error("the headers of the request were modified")
+	}
+}
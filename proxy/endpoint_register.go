@@ -0,0 +1,62 @@
+package proxy
+
+import "github.com/devopsfaith/krakend/config"
+
+// EndpointMiddlewareFactory creates a Middleware with the value declared under its name in the proxy
+// extra config of an endpoint
+type EndpointMiddlewareFactory func(cfg interface{}, endpoint *config.EndpointConfig) (Middleware, error)
+
+type namedEndpointMiddlewareFactory struct {
+	name    string
+	factory EndpointMiddlewareFactory
+}
+
+var endpointMiddlewareFactories = []namedEndpointMiddlewareFactory{
+	{templateName, newTemplateMiddleware},
+	{fieldsName, newFieldsMiddleware},
+}
+
+// RegisterEndpointMiddleware registers the endpoint middleware factory with the given name. The
+// enabled middlewares are stacked in order of registration, so the first one is the closest to
+// the backends
+func RegisterEndpointMiddleware(name string, mf EndpointMiddlewareFactory) error {
+	for i := range endpointMiddlewareFactories {
+		if endpointMiddlewareFactories[i].name == name {
+			endpointMiddlewareFactories[i].factory = mf
+			return nil
+		}
+	}
+	endpointMiddlewareFactories = append(endpointMiddlewareFactories, namedEndpointMiddlewareFactory{name, mf})
+	return nil
+}
+
+// NewEndpointMiddleware creates a middleware stacking all the registered endpoint middlewares enabled
+// in the extra config of the received endpoint
+func NewEndpointMiddleware(endpoint *config.EndpointConfig) (Middleware, error) {
+	extra, ok := endpoint.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	mws := []Middleware{}
+	for _, f := range endpointMiddlewareFactories {
+		v, ok := extra[f.name]
+		if !ok {
+			continue
+		}
+		mw, err := f.factory(v, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		mws = append(mws, mw)
+	}
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		p := next[0]
+		for _, mw := range mws {
+			p = mw(p)
+		}
+		return p
+	}, nil
+}
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
 	"github.com/devopsfaith/krakend/logging"
 	"github.com/devopsfaith/krakend/sd"
 )
@@ -40,7 +41,7 @@ func NewDefaultFactory(backendFactory BackendFactory, logger logging.Logger) Fac
 // NewDefaultFactoryWithSubscriber returns a default proxy factory with the injected proxy builder,
 // logger and subscriber factory
 func NewDefaultFactoryWithSubscriber(backendFactory BackendFactory, logger logging.Logger, sF sd.SubscriberFactory) Factory {
-	return defaultFactory{backendFactory, logger, sF}
+	return defaultFactory{backendFactory, logging.Module(logger, logging.ModuleProxy), sF}
 }
 
 type defaultFactory struct {
@@ -51,6 +52,10 @@ type defaultFactory struct {
 
 // New implements the Factory interface
 func (pf defaultFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
+	// the static responses and the redirects do not call the backends
+	if p, err = NewStaticResponseProxy(cfg); p != nil || err != nil {
+		return
+	}
 	switch len(cfg.Backend) {
 	case 0:
 		err = ErrNoBackends
@@ -59,28 +64,134 @@ func (pf defaultFactory) New(cfg *config.EndpointConfig) (p Proxy, err error) {
 	default:
 		p, err = pf.newMulti(cfg)
 	}
+	if err != nil || cfg.OutputEncoding == encoding.NOOP {
+		return
+	}
+	mw, err := NewEndpointMiddleware(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p = mw(p)
 	return
 }
 
 func (pf defaultFactory) newMulti(cfg *config.EndpointConfig) (p Proxy, err error) {
 	backendProxy := make([]Proxy, len(cfg.Backend))
 	for i, backend := range cfg.Backend {
-		backendProxy[i] = pf.newStack(backend)
+		backendProxy[i], err = pf.newBackend(cfg, backend)
+		if err != nil {
+			return
+		}
+	}
+	if cfg.OutputEncoding == encoding.SSE {
+		p = NewSSEFanInMiddleware(cfg)(backendProxy...)
+		return
 	}
 	p = NewMergeDataMiddleware(cfg)(backendProxy...)
 	return
 }
 
 func (pf defaultFactory) newSingle(cfg *config.EndpointConfig) (Proxy, error) {
-	return pf.newStack(cfg.Backend[0]), nil
+	return pf.newBackend(cfg, cfg.Backend[0])
 }
 
-func (pf defaultFactory) newStack(backend *config.Backend) (p Proxy) {
-	p = pf.backendFactory(backend)
-	p = NewRoundRobinLoadBalancedMiddlewareWithSubscriber(pf.subscriberFactory(backend))(p)
+// newBackend returns the stack of the backend, receiving the query string params the endpoint passes to it
+func (pf defaultFactory) newBackend(cfg *config.EndpointConfig, backend *config.Backend) (Proxy, error) {
+	queryStringMiddleware, err := NewQueryStringMiddleware(cfg, backend)
+	if err != nil {
+		return nil, err
+	}
+	p, err := pf.newStack(backend)
+	if err != nil {
+		return nil, err
+	}
+	return NewMetricsMiddleware(cfg, backend)(queryStringMiddleware(p)), nil
+}
+
+func (pf defaultFactory) newStack(backend *config.Backend) (p Proxy, err error) {
+	subscriber := pf.subscriberFactory(backend)
+	healthChecked, err := NewHealthCheckedSubscriber(backend, subscriber)
+	if err != nil {
+		return nil, err
+	}
+
+	registerBackendHealth(backend, subscriber, healthChecked)
+
+	p = NewCaptureMiddleware(backend)(pf.backendFactory(backend))
+	p = NewTracingMiddleware(backend)(NewTimingsMiddleware(backend)(p))
+	if healthChecked != nil {
+		subscriber = healthChecked
+		p = NewPassiveHealthCheckMiddleware(healthChecked)(p)
+	}
+	p = NewAdaptiveConcurrencyMiddleware(backend)(p)
+	balancerMiddleware, err := NewTrafficSplitMiddleware(backend)
+	if err != nil {
+		return nil, err
+	}
+	if balancerMiddleware == nil {
+		balancerMiddleware, err = NewConfiguredLoadBalancedMiddleware(backend, subscriber)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p = balancerMiddleware(p)
+	hedgingMiddleware, err := NewHedgingMiddleware(backend)
+	if err != nil {
+		return nil, err
+	}
+	p = hedgingMiddleware(p)
+	retryMiddleware, err := NewRetryMiddleware(backend)
+	if err != nil {
+		return nil, err
+	}
+	p = retryMiddleware(p)
+	circuitBreakerMiddleware, err := NewCircuitBreakerMiddleware(backend)
+	if err != nil {
+		return nil, err
+	}
+	p = circuitBreakerMiddleware(p)
+	bulkheadMiddleware, err := NewBulkheadMiddleware(backend)
+	if err != nil {
+		return nil, err
+	}
+	p = bulkheadMiddleware(p)
+	mirroringMiddleware, err := NewMirroringMiddleware(backend, pf.backendFactory)
+	if err != nil {
+		return nil, err
+	}
+	p = mirroringMiddleware(p)
 	if backend.ConcurrentCalls > 1 {
 		p = NewConcurrentMiddleware(backend)(p)
 	}
-	p = NewRequestBuilderMiddleware(backend)(p)
+	p = NewCollapsingMiddleware(backend)(p)
+	cacheMiddleware, err := NewCacheMiddleware(backend)
+	if err != nil {
+		return nil, err
+	}
+	p = cacheMiddleware(p)
+	deadlineMiddleware, err := NewDeadlineMiddleware(backend)
+	if err != nil {
+		return nil, err
+	}
+	p = deadlineMiddleware(p)
+	headersMiddleware, err := NewHeadersMiddleware(backend)
+	if err != nil {
+		return nil, err
+	}
+	p = headersMiddleware(p)
+	bodyMiddleware, err := NewBodyMiddleware(backend)
+	if err != nil {
+		return nil, err
+	}
+	p = bodyMiddleware(p)
+	requestBuilderMiddleware, err := NewURLRewriteMiddleware(backend)
+	if err != nil {
+		return nil, err
+	}
+	if requestBuilderMiddleware == nil {
+		requestBuilderMiddleware = NewRequestBuilderMiddleware(backend)
+	}
+	p = requestBuilderMiddleware(p)
+	p = NewStaticMiddleware(backend)(p)
 	return
 }
@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	failurePolicyKey   = "failure_policy"
+	requiredKey        = "require"
+	incompleteFieldKey = "incomplete_field"
+
+	// FailFast is the failure policy returning an error as soon as one of the backends fails,
+	// without waiting for the rest of them
+	FailFast = "fail-fast"
+	// BestEffort is the failure policy returning the data of the available backends without error
+	BestEffort = "best-effort"
+	// RequireBackends is the failure policy returning an error only when one of the backends listed
+	// in the 'require' option fails
+	RequireBackends = "require"
+
+	// IncompleteKey is the key of the field with the names of the missing backends
+	IncompleteKey = "_incomplete"
+)
+
+// ErrInvalidFailurePolicy is the error returned when the failure policy is unknown
+var ErrInvalidFailurePolicy = errors.New("invalid failure policy")
+
+// MissingBackendError is the error returned when a required backend is missing in the merged response
+type MissingBackendError struct {
+	Name string
+}
+
+// Error implements the error interface
+func (m MissingBackendError) Error() string {
+	return fmt.Sprintf("the required backend [%s] failed", m.Name)
+}
+
+// failurePolicy decides what to do with the responses of the merge when some backends are missing.
+// The backends are named after their group or, if they don't have one, after their position
+type failurePolicy struct {
+	policy          string
+	names           []string
+	required        map[string]bool
+	incompleteField bool
+}
+
+func newFailurePolicy(endpointConfig *config.EndpointConfig) failurePolicy {
+	p := failurePolicy{
+		names:    make([]string, len(endpointConfig.Backend)),
+		required: map[string]bool{},
+	}
+	for i, b := range endpointConfig.Backend {
+		p.names[i] = b.Group
+		if p.names[i] == "" {
+			p.names[i] = fmt.Sprintf("backend%d", i)
+		}
+	}
+
+	cfg, ok := endpointConfig.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return p
+	}
+	p.incompleteField, _ = cfg[incompleteFieldKey].(bool)
+	p.policy, _ = cfg[failurePolicyKey].(string)
+
+	switch p.policy {
+	case "", FailFast, BestEffort:
+	case RequireBackends:
+		required, _ := cfg[requiredKey].([]interface{})
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				p.required[name] = true
+			}
+		}
+	default:
+		panic(ErrInvalidFailurePolicy)
+	}
+	return p
+}
+
+func (f failurePolicy) isFailFast() bool {
+	return f.policy == FailFast
+}
+
+// apply annotates the merged response with the missing backends and filters the received error
+// according to the policy
+func (f failurePolicy) apply(result *Response, parts []*Response, err error) (*Response, error) {
+	missing := []string{}
+	for i, part := range parts {
+		if part == nil || !part.IsComplete {
+			missing = append(missing, f.names[i])
+		}
+	}
+	if len(missing) == 0 {
+		return result, err
+	}
+
+	if f.incompleteField {
+		result.Data[IncompleteKey] = missing
+	}
+
+	switch f.policy {
+	case BestEffort:
+		return result, nil
+	case RequireBackends:
+		for _, name := range missing {
+			if f.required[name] {
+				if err == nil {
+					err = MissingBackendError{Name: name}
+				}
+				return result, err
+			}
+		}
+		return result, nil
+	}
+	return result, err
+}
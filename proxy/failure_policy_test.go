@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewMergeDataMiddleware_failurePolicies(t *testing.T) {
+	backendErr := errors.New("backend error")
+
+	for _, tc := range []struct {
+		name     string
+		extra    map[string]interface{}
+		data     map[string]interface{}
+		err      error
+		complete bool
+	}{
+		{
+			name:  "default",
+			extra: map[string]interface{}{},
+			data:  map[string]interface{}{"supu": 42},
+			err:   backendErr,
+		},
+		{
+			name:  "fail-fast",
+			extra: map[string]interface{}{failurePolicyKey: FailFast},
+			data:  map[string]interface{}{},
+			err:   backendErr,
+		},
+		{
+			name:  "best-effort",
+			extra: map[string]interface{}{failurePolicyKey: BestEffort, incompleteFieldKey: true},
+			data:  map[string]interface{}{"supu": 42, IncompleteKey: []string{"tupu"}},
+		},
+		{
+			name:  "require-available",
+			extra: map[string]interface{}{failurePolicyKey: RequireBackends, requiredKey: []interface{}{"backend0"}},
+			data:  map[string]interface{}{"supu": 42},
+		},
+		{
+			name:  "require-missing",
+			extra: map[string]interface{}{failurePolicyKey: RequireBackends, requiredKey: []interface{}{"tupu"}},
+			data:  map[string]interface{}{"supu": 42},
+			err:   backendErr,
+		},
+	} {
+		endpoint := config.EndpointConfig{
+			Backend:     []*config.Backend{{}, {Group: "tupu"}},
+			Timeout:     500 * time.Millisecond,
+			ExtraConfig: config.ExtraConfig{Namespace: tc.extra},
+		}
+		p := NewMergeDataMiddleware(&endpoint)(
+			delayedProxy(t, 20*time.Millisecond, &Response{Data: map[string]interface{}{"supu": 42}, IsComplete: true}),
+			func(_ context.Context, _ *Request) (*Response, error) { return nil, backendErr })
+
+		out, err := p(context.Background(), &Request{})
+		if err != tc.err {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if out == nil {
+			t.Errorf("%s: the proxy returned a null result", tc.name)
+			continue
+		}
+		if !reflect.DeepEqual(out.Data, tc.data) {
+			t.Errorf("%s: unexpected response: %v", tc.name, out.Data)
+		}
+		if out.IsComplete {
+			t.Errorf("%s: unexpected complete response", tc.name)
+		}
+	}
+}
+
+func TestFailurePolicy_missingRequiredBackend(t *testing.T) {
+	endpoint := config.EndpointConfig{
+		Backend: []*config.Backend{{}, {}},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			failurePolicyKey: RequireBackends,
+			requiredKey:      []interface{}{"backend1"},
+		}},
+	}
+	policy := newFailurePolicy(&endpoint)
+	_, err := policy.apply(
+		&Response{Data: map[string]interface{}{}},
+		[]*Response{{IsComplete: true}, {IsComplete: false}},
+		nil,
+	)
+	if err != (MissingBackendError{Name: "backend1"}) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewMergeDataMiddleware_unknownFailurePolicy(t *testing.T) {
+	defer func() {
+		if r := recover(); r != ErrInvalidFailurePolicy {
+			t.Errorf("The code did not panic as expected: %v", r)
+		}
+	}()
+	endpoint := config.EndpointConfig{
+		Backend:     []*config.Backend{{}, {}},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{failurePolicyKey: "unknown"}},
+	}
+	NewMergeDataMiddleware(&endpoint)
+}
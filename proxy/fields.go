@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const fieldsName = "fields"
+
+var (
+	// ErrInvalidFieldsParam is the error returned when the configured query string param is not a string
+	ErrInvalidFieldsParam = errors.New("the fields query string param must be a string")
+	// ErrFieldsParamNotAllowed is the error returned when the configured query string param is not one of
+	// the querystring_params of the endpoint, so the router would never pass it to the proxy
+	ErrFieldsParamNotAllowed = errors.New("the fields query string param must be declared in the querystring_params")
+)
+
+// newFieldsMiddleware requires the param in the querystring_params of the endpoint, instead of adding it,
+// since the endpoint config is shared by every rebuild of its proxy
+func newFieldsMiddleware(cfg interface{}, endpoint *config.EndpointConfig) (Middleware, error) {
+	param, ok := cfg.(string)
+	if !ok || param == "" {
+		return nil, ErrInvalidFieldsParam
+	}
+	for _, q := range endpoint.QueryString {
+		if q == param || q == config.QueryStringWildcard {
+			return NewFieldsMiddleware(param), nil
+		}
+	}
+	return nil, ErrFieldsParamNotAllowed
+}
+
+// NewFieldsMiddleware creates a proxy middleware that lets the clients select the fields of the
+// response they need. The comma separated list of dotted paths to keep is read from the received
+// query string param, that is not forwarded to the backends
+func NewFieldsMiddleware(param string) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			value := request.Query.Get(param)
+			if value == "" {
+				return next[0](ctx, request)
+			}
+
+			r := request.Clone()
+			r.Query = make(url.Values, len(request.Query))
+			for k, v := range request.Query {
+				if k != param {
+					r.Query[k] = v
+				}
+			}
+
+			resp, err := next[0](ctx, &r)
+			if resp == nil {
+				return resp, err
+			}
+
+			fields := []string{}
+			for _, f := range strings.Split(value, ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					fields = append(fields, f)
+				}
+			}
+			filtered := *resp
+			newWhitelistingFilter(fields)(&filtered)
+			return &filtered, err
+		}
+	}
+}
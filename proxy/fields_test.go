@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewFieldsMiddleware(t *testing.T) {
+	p := NewFieldsMiddleware("fields")(func(_ context.Context, r *Request) (*Response, error) {
+		if _, ok := r.Query["fields"]; ok {
+			t.Error("the fields param should not reach the backends")
+		}
+		if r.Query.Get("page") != "2" {
+			t.Error("unexpected query:", r.Query)
+		}
+		return &Response{
+			Data: map[string]interface{}{
+				"id":   42,
+				"name": "supu",
+				"user": map[string]interface{}{"email": "a@b.c", "phone": "123"},
+			},
+			IsComplete: true,
+			Metadata:   Metadata{StatusCode: 200},
+		}, nil
+	})
+
+	resp, err := p(context.Background(), &Request{Query: url.Values{"fields": {"id, user.email"}, "page": {"2"}}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(resp.Data) != 2 || resp.Data["id"] != 42 || !resp.IsComplete || resp.Metadata.StatusCode != 200 {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	if user := resp.Data["user"].(map[string]interface{}); len(user) != 1 || user["email"] != "a@b.c" {
+		t.Errorf("unexpected user: %v", user)
+	}
+
+	resp, err = p(context.Background(), &Request{Query: url.Values{"page": {"2"}}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(resp.Data) != 3 {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestNewFieldsMiddleware_config(t *testing.T) {
+	endpoint := &config.EndpointConfig{QueryString: []string{"page"}}
+	if _, err := newFieldsMiddleware("fields", endpoint); err != ErrFieldsParamNotAllowed {
+		t.Error("unexpected error:", err)
+	}
+	if len(endpoint.QueryString) != 1 {
+		t.Error("the query string params were modified:", endpoint.QueryString)
+	}
+	for _, qs := range [][]string{{"page", "fields"}, {config.QueryStringWildcard}} {
+		endpoint.QueryString = qs
+		if _, err := newFieldsMiddleware("fields", endpoint); err != nil {
+			t.Error(err)
+		}
+		if len(endpoint.QueryString) != len(qs) {
+			t.Error("the query string params were modified:", endpoint.QueryString)
+		}
+	}
+	if _, err := newFieldsMiddleware(true, endpoint); err != ErrInvalidFieldsParam {
+		t.Error("unexpected error:", err)
+	}
+}
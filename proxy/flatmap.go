@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	flatmapName = "flatmap"
+	// FlatmapFlatten is the flatmap mode moving all the nested values to the root, using their
+	// dotted path as key
+	FlatmapFlatten = "flatten"
+	// FlatmapUnflatten is the flatmap mode expanding the dotted keys into nested objects
+	FlatmapUnflatten = "unflatten"
+)
+
+// ErrInvalidFlatmapMode is the error returned when the flatmap mode is unknown
+var ErrInvalidFlatmapMode = errors.New("invalid flatmap mode")
+
+func newFlatmapFormatter(cfg interface{}) (EntityFormatter, error) {
+	mode, _ := cfg.(string)
+	switch mode {
+	case FlatmapFlatten:
+		return EntityFormatterFunc(func(entity Response) Response {
+			data := make(map[string]interface{}, len(entity.Data))
+			flatten("", entity.Data, data)
+			entity.Data = data
+			return entity
+		}), nil
+	case FlatmapUnflatten:
+		return EntityFormatterFunc(func(entity Response) Response {
+			entity.Data = unflatten(entity.Data)
+			return entity
+		}), nil
+	}
+	return nil, ErrInvalidFlatmapMode
+}
+
+// flatten copies all the leaves of v into out. Array elements use their index as path segment
+func flatten(prefix string, v interface{}, out map[string]interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(t) == 0 && prefix != "" {
+			out[prefix] = t
+			return
+		}
+		for k, e := range t {
+			flatten(joinPath(prefix, k), e, out)
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			out[prefix] = t
+			return
+		}
+		for i, e := range t {
+			flatten(joinPath(prefix, strconv.Itoa(i)), e, out)
+		}
+	default:
+		out[prefix] = v
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// unflatten expands the dotted keys of data into nested objects. Objects with consecutive
+// numeric keys starting at 0 are restored as arrays. When a key holds a value and other keys nest
+// values under it, like a and a.b, the nested values win: they are merged into a copy of the value
+// if it is an object, and they replace it otherwise
+func unflatten(data map[string]interface{}) map[string]interface{} {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	// the parents sort before their nested keys, so they are set first
+	sort.Strings(keys)
+
+	res := map[string]interface{}{}
+	// owned are the paths of the objects created here, so the objects of data are never modified
+	owned := map[string]bool{}
+	for _, k := range keys {
+		path := strings.Split(k, ".")
+		parent := res
+		for i, p := range path[:len(path)-1] {
+			prefix := strings.Join(path[:i+1], ".")
+			next, ok := parent[p].(map[string]interface{})
+			if !owned[prefix] {
+				copied := make(map[string]interface{}, len(next))
+				for nk, nv := range next {
+					copied[nk] = nv
+				}
+				next = copied
+				parent[p] = next
+				owned[prefix] = true
+			} else if !ok {
+				next = map[string]interface{}{}
+				parent[p] = next
+			}
+			parent = next
+		}
+		parent[path[len(path)-1]] = data[k]
+	}
+
+	for k, v := range res {
+		res[k] = restoreArrays(v)
+	}
+	return res
+}
+
+func restoreArrays(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	for k, e := range m {
+		m[k] = restoreArrays(e)
+	}
+	if len(m) == 0 {
+		return m
+	}
+	arr := make([]interface{}, len(m))
+	for k, e := range m {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(m) {
+			return m
+		}
+		arr[i] = e
+	}
+	return arr
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestFlatmap_flatten(t *testing.T) {
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{"flatmap": FlatmapFlatten},
+		},
+	}
+	f, err := NewBackendEntityFormatter(remote)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := f.Format(Response{
+		Data: map[string]interface{}{
+			"supu": 42,
+			"user": map[string]interface{}{
+				"address": map[string]interface{}{"city": "Barcelona"},
+				"tags":    []interface{}{"a", "b"},
+				"empty":   map[string]interface{}{},
+			},
+		},
+		IsComplete: true,
+	})
+	expected := map[string]interface{}{
+		"supu":              42,
+		"user.address.city": "Barcelona",
+		"user.tags.0":       "a",
+		"user.tags.1":       "b",
+	}
+	if len(result.Data) != len(expected)+1 || !result.IsComplete {
+		t.Errorf("unexpected result: %v", result.Data)
+	}
+	for k, v := range expected {
+		if result.Data[k] != v {
+			t.Errorf("unexpected value for %s: %v", k, result.Data[k])
+		}
+	}
+	if v, ok := result.Data["user.empty"].(map[string]interface{}); !ok || len(v) != 0 {
+		t.Errorf("unexpected value for user.empty: %v", result.Data["user.empty"])
+	}
+}
+
+func TestFlatmap_unflatten(t *testing.T) {
+	f, err := newFlatmapFormatter(FlatmapUnflatten)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := f.Format(Response{
+		Data: map[string]interface{}{
+			"supu":              42,
+			"user.address.city": "Barcelona",
+			"user.tags.0":       "a",
+			"user.tags.1":       "b",
+			"user.ids.1":        "not an array",
+		},
+		IsComplete: true,
+	})
+	if len(result.Data) != 2 || result.Data["supu"] != 42 {
+		t.Errorf("unexpected result: %v", result.Data)
+		return
+	}
+	user := result.Data["user"].(map[string]interface{})
+	if city := user["address"].(map[string]interface{})["city"]; city != "Barcelona" {
+		t.Errorf("unexpected city: %v", city)
+	}
+	tags, ok := user["tags"].([]interface{})
+	if !ok || len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Errorf("unexpected tags: %v", user["tags"])
+	}
+	if ids, ok := user["ids"].(map[string]interface{}); !ok || ids["1"] != "not an array" {
+		t.Errorf("unexpected ids: %v", user["ids"])
+	}
+}
+
+func TestFlatmap_unflattenConflicts(t *testing.T) {
+	f, err := newFlatmapFormatter(FlatmapUnflatten)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	address := map[string]interface{}{"city": "Barcelona"}
+	for i := 0; i < 10; i++ {
+		result := f.Format(Response{
+			Data: map[string]interface{}{
+				"a":               42,
+				"a.b":             1,
+				"a.c":             2,
+				"address":         address,
+				"address.country": "ES",
+			},
+			IsComplete: true,
+		})
+		a, ok := result.Data["a"].(map[string]interface{})
+		if !ok || len(a) != 2 || a["b"] != 1 || a["c"] != 2 {
+			t.Errorf("the nested values did not win: %v", result.Data["a"])
+		}
+		merged, ok := result.Data["address"].(map[string]interface{})
+		if !ok || len(merged) != 2 || merged["city"] != "Barcelona" || merged["country"] != "ES" {
+			t.Errorf("the nested values were not merged: %v", result.Data["address"])
+		}
+		if len(address) != 1 {
+			t.Errorf("the original object was modified: %v", address)
+		}
+	}
+}
+
+func TestFlatmap_unknownMode(t *testing.T) {
+	if _, err := newFlatmapFormatter("unknown"); err != ErrInvalidFlatmapMode {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -1,6 +1,10 @@
 package proxy
 
-import "strings"
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
 
 // EntityFormatter formats the response data
 type EntityFormatter interface {
@@ -20,10 +24,22 @@ type entityFormatter struct {
 	Prefix         string
 	PropertyFilter propertyFilter
 	Mapping        map[string]string
+	DeepMapping    []fieldMapping
+	Stages         []EntityFormatter
+}
+
+// fieldMapping moves the value at the source path to the target path
+type fieldMapping struct {
+	source []string
+	target []string
 }
 
 // NewEntityFormatter creates an entity formatter with the received params
 func NewEntityFormatter(target string, whitelist, blacklist []string, group string, mappings map[string]string) EntityFormatter {
+	return newEntityFormatter(target, whitelist, blacklist, group, mappings)
+}
+
+func newEntityFormatter(target string, whitelist, blacklist []string, group string, mappings map[string]string) entityFormatter {
 	var propertyFilter propertyFilter
 	if len(whitelist) > 0 {
 		propertyFilter = newWhitelistingFilter(whitelist)
@@ -31,15 +47,26 @@ func NewEntityFormatter(target string, whitelist, blacklist []string, group stri
 		propertyFilter = newBlacklistingFilter(blacklist)
 	}
 	sanitizedMappings := make(map[string]string, len(mappings))
-	for i, m := range mappings {
-		v := strings.Split(m, ".")
-		sanitizedMappings[i] = v[0]
+	deepMappings := []fieldMapping{}
+	for formerKey, newKey := range mappings {
+		if !strings.Contains(formerKey, ".") && !strings.Contains(newKey, ".") {
+			sanitizedMappings[formerKey] = newKey
+			continue
+		}
+		deepMappings = append(deepMappings, fieldMapping{
+			source: strings.Split(formerKey, "."),
+			target: strings.Split(newKey, "."),
+		})
 	}
+	sort.Slice(deepMappings, func(i, j int) bool {
+		return strings.Join(deepMappings[i].source, ".") < strings.Join(deepMappings[j].source, ".")
+	})
 	return entityFormatter{
 		Target:         target,
 		Prefix:         group,
 		PropertyFilter: propertyFilter,
 		Mapping:        sanitizedMappings,
+		DeepMapping:    deepMappings,
 	}
 }
 
@@ -58,6 +85,12 @@ func (e entityFormatter) Format(entity Response) Response {
 				delete(entity.Data, formerKey)
 			}
 		}
+		for _, m := range e.DeepMapping {
+			moveField(entity.Data, m.source, m.target)
+		}
+	}
+	for _, stage := range e.Stages {
+		entity = stage.Format(entity)
 	}
 	if e.Prefix != "" {
 		entity.Data = map[string]interface{}{e.Prefix: entity.Data}
@@ -65,97 +98,192 @@ func (e entityFormatter) Format(entity Response) Response {
 	return entity
 }
 
+// extractTarget replaces the data of the entity with the object found at the target. The target
+// can be a dotted path, including numeric indexes for selecting elements of arrays. If the target
+// is an array, it is placed under the 'collection' key
 func extractTarget(target string, entity *Response) {
-	if tmp, ok := entity.Data[target]; ok {
-		entity.Data, ok = tmp.(map[string]interface{})
-		if !ok {
-			entity.Data = map[string]interface{}{}
-		}
-	} else {
+	tmp, ok := entity.Data[target]
+	if !ok {
+		tmp, ok = lookupPath(entity.Data, strings.Split(target, "."))
+	}
+	if !ok {
+		entity.Data = map[string]interface{}{}
+		return
+	}
+	switch v := tmp.(type) {
+	case map[string]interface{}:
+		entity.Data = v
+	case []interface{}:
+		entity.Data = map[string]interface{}{"collection": v}
+	default:
 		entity.Data = map[string]interface{}{}
 	}
 }
 
-func newWhitelistingFilter(whitelist []string) propertyFilter {
-	wl := make(map[string]map[string]interface{}, len(whitelist))
-	for _, k := range whitelist {
-		keys := strings.Split(k, ".")
-		tmp, ok := wl[keys[0]]
+func lookupPath(v interface{}, path []string) (interface{}, bool) {
+	for _, k := range path {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = t[k]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// moveField moves the value at the source path to the target path, creating the intermediate
+// objects of the target path when required. If the source path does not exist, it does nothing
+func moveField(data map[string]interface{}, source, target []string) {
+	parent := data
+	for _, k := range source[:len(source)-1] {
+		next, ok := parent[k].(map[string]interface{})
 		if !ok {
-			tmp = make(map[string]interface{}, len(keys)-1)
-			wl[keys[0]] = tmp
+			return
 		}
-		for idx := 1; idx < len(keys); idx++ {
-			tmp[keys[idx]] = nil
+		parent = next
+	}
+	v, ok := parent[source[len(source)-1]]
+	if !ok {
+		return
+	}
+	delete(parent, source[len(source)-1])
+	setAtPath(data, target, v)
+}
+
+// setAtPath sets the value at the received path, creating the intermediate objects when required
+func setAtPath(data map[string]interface{}, path []string, v interface{}) {
+	parent := data
+	for _, k := range path[:len(path)-1] {
+		next, ok := parent[k].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			parent[k] = next
 		}
+		parent = next
 	}
+	parent[path[len(path)-1]] = v
+}
+
+func newWhitelistingFilter(whitelist []string) propertyFilter {
+	wl := newFieldTree(whitelist)
 
 	return func(entity *Response) {
-		accumulator := make(map[string]interface{}, len(whitelist))
-		for k, v := range entity.Data {
-			if sub, ok := wl[k]; ok {
-				if len(sub) > 0 {
-					if tmp := whitelistFilterSub(v, sub); len(tmp) > 0 {
-						accumulator[k] = tmp
-					}
-				} else {
-					accumulator[k] = v
-				}
+		accumulator := make(map[string]interface{}, len(wl))
+		for k, sub := range wl {
+			v, ok := entity.Data[k]
+			if !ok {
+				continue
+			}
+			if len(sub) == 0 {
+				accumulator[k] = v
+				continue
+			}
+			if tmp, ok := whitelistFilterSub(v, sub); ok {
+				accumulator[k] = tmp
 			}
 		}
-		*entity = Response{Data: accumulator, IsComplete: entity.IsComplete}
+		entity.Data = accumulator
 	}
 }
 
-func whitelistFilterSub(v interface{}, whitelist map[string]interface{}) map[string]interface{} {
-	entity, ok := v.(map[string]interface{})
-	if !ok {
-		return map[string]interface{}{}
-	}
-	tmp := make(map[string]interface{}, len(whitelist))
-	for k, v := range entity {
-		if _, ok := whitelist[k]; ok {
-			tmp[k] = v
-		}
+// fieldTree is the tree representation of a set of dotted paths. The leaves are the whole paths
+type fieldTree map[string]fieldTree
+
+// newFieldTree returns the tree of the paths. A path covers the whole subtree, so it overrides the deeper
+// paths declared under it, whatever their order
+func newFieldTree(paths []string) fieldTree {
+	sorted := make([][]string, 0, len(paths))
+	for _, path := range paths {
+		sorted = append(sorted, strings.Split(path, "."))
 	}
-	return tmp
-}
+	// the shorter paths are added first, so the deeper ones find their ancestors as leaves
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) < len(sorted[j]) })
 
-func newBlacklistingFilter(blacklist []string) propertyFilter {
-	bl := make(map[string][]string, len(blacklist))
-	for _, key := range blacklist {
-		keys := strings.Split(key, ".")
-		if len(keys) > 1 {
-			if sub, ok := bl[keys[0]]; ok {
-				bl[keys[0]] = append(sub, keys[1])
-			} else {
-				bl[keys[0]] = []string{keys[1]}
+	tree := fieldTree{}
+	for _, keys := range sorted {
+		current := tree
+		for _, key := range keys {
+			next, ok := current[key]
+			if ok && len(next) == 0 {
+				break
+			}
+			if !ok {
+				next = fieldTree{}
+				current[key] = next
 			}
-		} else {
-			bl[keys[0]] = []string{}
+			current = next
 		}
 	}
+	return tree
+}
 
-	return func(entity *Response) {
-		for k, sub := range bl {
+// whitelistFilterSub returns the parts of v allowed by the whitelist tree. Arrays are traversed,
+// so the whitelist is applied to every element. The returned flag is false if nothing survived
+func whitelistFilterSub(v interface{}, whitelist fieldTree) (interface{}, bool) {
+	switch entity := v.(type) {
+	case map[string]interface{}:
+		tmp := make(map[string]interface{}, len(whitelist))
+		for k, sub := range whitelist {
+			value, ok := entity[k]
+			if !ok {
+				continue
+			}
 			if len(sub) == 0 {
-				delete(entity.Data, k)
-			} else {
-				if tmp := blacklistFilterSub(entity.Data[k], sub); len(tmp) > 0 {
-					entity.Data[k] = tmp
-				}
+				tmp[k] = value
+				continue
+			}
+			if filtered, ok := whitelistFilterSub(value, sub); ok {
+				tmp[k] = filtered
+			}
+		}
+		return tmp, len(tmp) > 0
+	case []interface{}:
+		tmp := make([]interface{}, 0, len(entity))
+		for _, element := range entity {
+			if filtered, ok := whitelistFilterSub(element, whitelist); ok {
+				tmp = append(tmp, filtered)
 			}
 		}
+		return tmp, len(tmp) > 0
 	}
+	return nil, false
 }
 
-func blacklistFilterSub(v interface{}, blacklist []string) map[string]interface{} {
-	tmp, ok := v.(map[string]interface{})
-	if !ok {
-		return map[string]interface{}{}
+func newBlacklistingFilter(blacklist []string) propertyFilter {
+	bl := newFieldTree(blacklist)
+
+	return func(entity *Response) {
+		blacklistFilterSub(entity.Data, bl)
 	}
-	for _, key := range blacklist {
-		delete(tmp, key)
+}
+
+// blacklistFilterSub removes the blacklisted paths from v. If v is an array, the paths are
+// removed from every element
+func blacklistFilterSub(v interface{}, blacklist fieldTree) {
+	switch tmp := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range blacklist {
+			if len(sub) == 0 {
+				delete(tmp, k)
+				continue
+			}
+			if value, ok := tmp[k]; ok {
+				blacklistFilterSub(value, sub)
+			}
+		}
+	case []interface{}:
+		for _, element := range tmp {
+			blacklistFilterSub(element, blacklist)
+		}
 	}
-	return tmp
 }
@@ -0,0 +1,61 @@
+package proxy
+
+import "github.com/devopsfaith/krakend/config"
+
+// Namespace is the key to look for extra configuration details
+const Namespace = "github.com/devopsfaith/krakend/proxy"
+
+// EntityFormatterFactory creates an EntityFormatter with the value declared under its name in the
+// proxy extra config of a backend
+type EntityFormatterFactory func(cfg interface{}) (EntityFormatter, error)
+
+type namedEntityFormatterFactory struct {
+	name    string
+	factory EntityFormatterFactory
+}
+
+var entityFormatterFactories = []namedEntityFormatterFactory{
+	{coerceName, newCoerceFormatter},
+	{defaultsName, newDefaultsFormatter},
+	{injectName, newInjectFormatter},
+	{collectionName, newCollectionFormatter},
+	{maskName, newMaskFormatter},
+	{flatmapName, newFlatmapFormatter},
+}
+
+// RegisterEntityFormatter registers the entity formatter factory with the given name. The enabled
+// formatters are applied in order of registration, after the filtering and the mapping of the
+// response and before moving it into its group
+func RegisterEntityFormatter(name string, ef EntityFormatterFactory) error {
+	for i := range entityFormatterFactories {
+		if entityFormatterFactories[i].name == name {
+			entityFormatterFactories[i].factory = ef
+			return nil
+		}
+	}
+	entityFormatterFactories = append(entityFormatterFactories, namedEntityFormatterFactory{name, ef})
+	return nil
+}
+
+// NewBackendEntityFormatter creates an entity formatter with the received backend configuration,
+// including all the registered formatters enabled in its extra config
+func NewBackendEntityFormatter(remote *config.Backend) (EntityFormatter, error) {
+	ef := newEntityFormatter(remote.Target, remote.Whitelist, remote.Blacklist, remote.Group, remote.Mapping)
+
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return ef, nil
+	}
+	for _, f := range entityFormatterFactories {
+		v, ok := extra[f.name]
+		if !ok {
+			continue
+		}
+		stage, err := f.factory(v)
+		if err != nil {
+			return nil, err
+		}
+		ef.Stages = append(ef.Stages, stage)
+	}
+	return ef, nil
+}
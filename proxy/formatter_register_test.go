@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewBackendEntityFormatter(t *testing.T) {
+	original := entityFormatterFactories
+	defer func() { entityFormatterFactories = original }()
+	entityFormatterFactories = []namedEntityFormatterFactory{}
+
+	if err := RegisterEntityFormatter("suffix", func(cfg interface{}) (EntityFormatter, error) {
+		suffix := cfg.(string)
+		return EntityFormatterFunc(func(r Response) Response {
+			for k, v := range r.Data {
+				delete(r.Data, k)
+				r.Data[k+suffix] = v
+			}
+			return r
+		}), nil
+	}); err != nil {
+		t.Error(err)
+	}
+	RegisterEntityFormatter("unused", func(_ interface{}) (EntityFormatter, error) {
+		t.Error("unexpected call to the unused factory")
+		return nil, nil
+	})
+
+	remote := &config.Backend{
+		Whitelist: []string{"supu"},
+		Group:     "group",
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{"suffix": "_x"},
+		},
+	}
+	f, err := NewBackendEntityFormatter(remote)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := f.Format(Response{Data: map[string]interface{}{"supu": 42, "tupu": true}, IsComplete: true})
+	group, ok := result.Data["group"].(map[string]interface{})
+	if !ok || len(group) != 1 || group["supu_x"] != 42 {
+		t.Errorf("unexpected result: %v", result.Data)
+	}
+}
+
+func TestNewBackendEntityFormatter_ko(t *testing.T) {
+	original := entityFormatterFactories
+	defer func() { entityFormatterFactories = original }()
+	entityFormatterFactories = []namedEntityFormatterFactory{}
+
+	expectedErr := errors.New("wrong config")
+	RegisterEntityFormatter("failing", func(_ interface{}) (EntityFormatter, error) {
+		return nil, expectedErr
+	})
+
+	remote := &config.Backend{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{"failing": true},
+		},
+	}
+	if _, err := NewBackendEntityFormatter(remote); err != expectedErr {
+		t.Error("unexpected error:", err)
+	}
+	if _, err := NewHTTPProxyWithHTTPExecutor(remote, nil, nil)(context.Background(), &Request{}); err != expectedErr {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -188,7 +188,7 @@ func TestEntityFormatter_mapping(t *testing.T) {
 		}
 	}
 	group := result.Data["a"].(map[string]interface{})
-	for k, expectedValue := range expected.Data["a"].(map[string]interface{}) {
+	for k, expectedValue := range map[string]interface{}{"BOOOOO": true, "c": 42, "d": "tupu"} {
 		if v, ok := group[k]; !ok || v != expectedValue {
 			t.Errorf("The formatter returned an unexpected result for the key %s: %v\n", k, v)
 		}
@@ -199,6 +199,51 @@ func TestEntityFormatter_mapping(t *testing.T) {
 	}
 }
 
+func TestEntityFormatter_deepMapping(t *testing.T) {
+	mapping := map[string]string{
+		"user.profile.name": "display_name",
+		"user.profile.age":  "details.personal.age",
+		"email":             "user.contact.email",
+		"unknown.field":     "something",
+	}
+	sample := Response{
+		Data: map[string]interface{}{
+			"email": "supu@example.com",
+			"user": map[string]interface{}{
+				"id": 42,
+				"profile": map[string]interface{}{
+					"name": "supu",
+					"age":  33,
+				},
+			},
+		},
+		IsComplete: true,
+	}
+	f := NewEntityFormatter("", []string{}, []string{}, "", mapping)
+	result := f.Format(sample)
+
+	if len(result.Data) != 3 || !result.IsComplete {
+		t.Errorf("The formatter returned an unexpected result size: %v\n", result.Data)
+	}
+	if v := result.Data["display_name"]; v != "supu" {
+		t.Errorf("The formatter returned an unexpected result for the key display_name: %v\n", v)
+	}
+	details := result.Data["details"].(map[string]interface{})
+	if v := details["personal"].(map[string]interface{})["age"]; v != 33 {
+		t.Errorf("The formatter returned an unexpected result for the key details.personal.age: %v\n", v)
+	}
+	user := result.Data["user"].(map[string]interface{})
+	if len(user) != 3 || user["id"] != 42 {
+		t.Errorf("The formatter returned an unexpected result for the key user: %v\n", user)
+	}
+	if v := user["contact"].(map[string]interface{})["email"]; v != "supu@example.com" {
+		t.Errorf("The formatter returned an unexpected result for the key user.contact.email: %v\n", v)
+	}
+	if profile := user["profile"].(map[string]interface{}); len(profile) != 0 {
+		t.Errorf("The formatter returned an unexpected result for the key user.profile: %v\n", profile)
+	}
+}
+
 func TestEntityFormatter_targeting(t *testing.T) {
 	target := "group1"
 	sub := map[string]interface{}{
@@ -305,3 +350,226 @@ func TestEntityFormatter_altogether(t *testing.T) {
 		t.Errorf("The formatter returned an unexpected result size: %v\n", result)
 	}
 }
+
+func TestEntityFormatter_whitelistingFilterOverArrays(t *testing.T) {
+	sample := Response{
+		Data: map[string]interface{}{
+			"collection": []interface{}{
+				map[string]interface{}{
+					"page": 1,
+					"items": []interface{}{
+						map[string]interface{}{"name": "a", "price": 1},
+						map[string]interface{}{"name": "b", "price": 2},
+						map[string]interface{}{"price": 3},
+						"not an object",
+					},
+				},
+			},
+			"foo": "bar",
+		},
+		IsComplete: true,
+	}
+	f := NewEntityFormatter("", []string{"collection.items.name"}, []string{}, "", map[string]string{})
+	result := f.Format(sample)
+	if len(result.Data) != 1 || !result.IsComplete {
+		t.Errorf("The formatter returned an unexpected result size: %v\n", result)
+		return
+	}
+	collection, ok := result.Data["collection"].([]interface{})
+	if !ok || len(collection) != 1 {
+		t.Errorf("The formatter returned an unexpected result for the field collection: %v\n", result)
+		return
+	}
+	page := collection[0].(map[string]interface{})
+	if len(page) != 1 {
+		t.Errorf("The formatter returned an unexpected result size for the page: %v\n", page)
+	}
+	items, ok := page["items"].([]interface{})
+	if !ok || len(items) != 2 {
+		t.Errorf("The formatter returned an unexpected result for the field items: %v\n", page)
+		return
+	}
+	for i, name := range []string{"a", "b"} {
+		item := items[i].(map[string]interface{})
+		if len(item) != 1 || item["name"] != name {
+			t.Errorf("The formatter returned an unexpected result for the item %d: %v\n", i, item)
+		}
+	}
+}
+
+func TestEntityFormatter_whitelistingFilterWholePath(t *testing.T) {
+	for _, whitelist := range [][]string{{"a", "a.b"}, {"a.b", "a"}} {
+		sample := Response{
+			Data: map[string]interface{}{
+				"a":   map[string]interface{}{"b": 1, "c": 2},
+				"foo": "bar",
+			},
+			IsComplete: true,
+		}
+		f := NewEntityFormatter("", whitelist, []string{}, "", map[string]string{})
+		result := f.Format(sample)
+		a, ok := result.Data["a"].(map[string]interface{})
+		if len(result.Data) != 1 || !ok || len(a) != 2 {
+			t.Errorf("%v: the whole path was not kept: %v", whitelist, result.Data)
+		}
+	}
+}
+
+func TestEntityFormatter_blacklistingFilterOverArrays(t *testing.T) {
+	sample := Response{
+		Data: map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"name": "a", "price": 1},
+				map[string]interface{}{"name": "b", "price": 2},
+				42,
+			},
+			"foo": "bar",
+		},
+		IsComplete: true,
+	}
+	f := NewEntityFormatter("", []string{}, []string{"items.price"}, "", map[string]string{})
+	result := f.Format(sample)
+	if len(result.Data) != 2 || !result.IsComplete {
+		t.Errorf("The formatter returned an unexpected result size: %v\n", result)
+		return
+	}
+	items, ok := result.Data["items"].([]interface{})
+	if !ok || len(items) != 3 {
+		t.Errorf("The formatter returned an unexpected result for the field items: %v\n", result)
+		return
+	}
+	for i, name := range []string{"a", "b"} {
+		item := items[i].(map[string]interface{})
+		if len(item) != 1 || item["name"] != name {
+			t.Errorf("The formatter returned an unexpected result for the item %d: %v\n", i, item)
+		}
+	}
+	if items[2] != 42 {
+		t.Errorf("The formatter returned an unexpected result for the item 2: %v\n", items[2])
+	}
+}
+
+func TestEntityFormatter_blacklistingFilterParentFirst(t *testing.T) {
+	sample := Response{
+		Data: map[string]interface{}{
+			"k": map[string]interface{}{
+				"l": map[string]interface{}{"m": 1, "n": 2},
+			},
+			"foo": "bar",
+		},
+		IsComplete: true,
+	}
+	f := NewEntityFormatter("", []string{}, []string{"k", "k.l.m"}, "", map[string]string{})
+	result := f.Format(sample)
+	if len(result.Data) != 1 || result.Data["foo"] != "bar" {
+		t.Errorf("The formatter returned an unexpected result: %v\n", result.Data)
+	}
+}
+
+func TestEntityFormatter_blacklistingFilterDeepPaths(t *testing.T) {
+	sample := Response{
+		Data: map[string]interface{}{
+			"a": map[string]interface{}{
+				"b": map[string]interface{}{
+					"c": map[string]interface{}{
+						"d": 1,
+						"e": 2,
+					},
+					"f": []interface{}{
+						map[string]interface{}{
+							"g": map[string]interface{}{"h": 1, "i": 2},
+						},
+						map[string]interface{}{
+							"g": map[string]interface{}{"h": 3},
+						},
+					},
+				},
+				"j": true,
+			},
+			"k": map[string]interface{}{
+				"l": map[string]interface{}{"m": 1},
+			},
+		},
+		IsComplete: true,
+	}
+	f := NewEntityFormatter("", []string{}, []string{"a.b.c.d", "a.b.f.g.h", "k.l.m", "k", "unknown.x.y.z"}, "", map[string]string{})
+	result := f.Format(sample)
+	if len(result.Data) != 1 || !result.IsComplete {
+		t.Errorf("The formatter returned an unexpected result size: %v\n", result)
+		return
+	}
+	a := result.Data["a"].(map[string]interface{})
+	if len(a) != 2 || a["j"] != true {
+		t.Errorf("The formatter returned an unexpected result for the field a: %v\n", a)
+	}
+	b := a["b"].(map[string]interface{})
+	c := b["c"].(map[string]interface{})
+	if len(c) != 1 || c["e"] != 2 {
+		t.Errorf("The formatter returned an unexpected result for the field a.b.c: %v\n", c)
+	}
+	items := b["f"].([]interface{})
+	if len(items) != 2 {
+		t.Errorf("The formatter returned an unexpected result for the field a.b.f: %v\n", items)
+		return
+	}
+	g := items[0].(map[string]interface{})["g"].(map[string]interface{})
+	if len(g) != 1 || g["i"] != 2 {
+		t.Errorf("The formatter returned an unexpected result for the field a.b.f.0.g: %v\n", g)
+	}
+	g = items[1].(map[string]interface{})["g"].(map[string]interface{})
+	if len(g) != 0 {
+		t.Errorf("The formatter returned an unexpected result for the field a.b.f.1.g: %v\n", g)
+	}
+}
+
+func TestEntityFormatter_targetingDeepPaths(t *testing.T) {
+	sample := func() Response {
+		return Response{
+			Data: map[string]interface{}{
+				"data": map[string]interface{}{
+					"result": map[string]interface{}{
+						"items": []interface{}{
+							map[string]interface{}{"id": 1, "tags": []interface{}{"a", "b"}},
+							map[string]interface{}{"id": 2},
+						},
+					},
+				},
+				"dotted.key": map[string]interface{}{"supu": 42},
+			},
+			IsComplete: true,
+		}
+	}
+
+	for _, tc := range []struct {
+		target   string
+		expected map[string]interface{}
+	}{
+		{target: "data.result.items.1", expected: map[string]interface{}{"id": 2}},
+		{target: "dotted.key", expected: map[string]interface{}{"supu": 42}},
+		{target: "data.result.items.2", expected: map[string]interface{}{}},
+		{target: "data.result.items.first", expected: map[string]interface{}{}},
+		{target: "data.result.items.0.id", expected: map[string]interface{}{}},
+		{target: "data.unknown.items", expected: map[string]interface{}{}},
+	} {
+		result := NewEntityFormatter(tc.target, []string{}, []string{}, "", map[string]string{}).Format(sample())
+		if len(result.Data) != len(tc.expected) || !result.IsComplete {
+			t.Errorf("%s: unexpected result: %v", tc.target, result)
+			continue
+		}
+		for k, v := range tc.expected {
+			if result.Data[k] != v {
+				t.Errorf("%s: unexpected value for %s: %v", tc.target, k, result.Data[k])
+			}
+		}
+	}
+
+	result := NewEntityFormatter("data.result.items", []string{"collection.id"}, []string{}, "", map[string]string{}).Format(sample())
+	collection, ok := result.Data["collection"].([]interface{})
+	if !ok || len(collection) != 2 {
+		t.Errorf("unexpected result: %v", result.Data)
+		return
+	}
+	if item := collection[0].(map[string]interface{}); len(item) != 1 || item["id"] != 1 {
+		t.Errorf("unexpected item: %v", item)
+	}
+}
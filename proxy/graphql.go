@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const graphQLKey = "graphql"
+
+// ErrNoGraphQLQuery is the error returned when the GraphQL config does not define the query
+var ErrNoGraphQLQuery = errors.New("the graphql query is required")
+
+// GraphQLConfig defines the GraphQL operation sent to the backend
+type GraphQLConfig struct {
+	// Query is the query or the mutation to send
+	Query string `json:"query"`
+	// OperationName selects the operation to execute when the query declares several ones
+	OperationName string `json:"operation_name"`
+	// Variables are the static variables of the operation
+	Variables map[string]interface{} `json:"variables"`
+	// Params maps the variables to the params of the request with the given name (ignoring the case)
+	Params map[string]string `json:"params"`
+	// Headers maps the variables to the first value of the header of the request with the given name
+	Headers map[string]string `json:"headers"`
+	// Body is the variable to set with the JSON body of the request. The body is ignored if it is empty
+	Body string `json:"body"`
+}
+
+// GraphQLConfigGetter parses the 'graphql' option of the backend proxy extra config. The second value
+// is false if the backend does not declare it
+func GraphQLConfigGetter(remote *config.Backend) (GraphQLConfig, bool, error) {
+	cfg := GraphQLConfig{}
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg, false, nil
+	}
+	v, ok := extra[graphQLKey]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, err
+	}
+	if cfg.Query == "" {
+		return cfg, true, ErrNoGraphQLQuery
+	}
+	return cfg, true, nil
+}
+
+// NewGraphQLMiddleware creates a proxy middleware replacing the request to the backend with a POST
+// request sending the configured GraphQL operation as JSON. The variables of the operation are the
+// static ones, overridden by the mapped params, headers and body of the request
+func NewGraphQLMiddleware(cfg GraphQLConfig) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			variables := make(map[string]interface{}, len(cfg.Variables)+len(cfg.Params)+len(cfg.Headers)+1)
+			for k, v := range cfg.Variables {
+				variables[k] = v
+			}
+			for k, param := range cfg.Params {
+				for name, v := range request.Params {
+					if strings.EqualFold(name, param) {
+						variables[k] = v
+						break
+					}
+				}
+			}
+			for k, header := range cfg.Headers {
+				if v := request.Headers[http.CanonicalHeaderKey(header)]; len(v) > 0 {
+					variables[k] = v[0]
+				}
+			}
+			if cfg.Body != "" && request.Body != nil {
+				b, err := ioutil.ReadAll(request.Body)
+				request.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				if len(bytes.TrimSpace(b)) > 0 {
+					var v interface{}
+					d := json.NewDecoder(bytes.NewReader(b))
+					d.UseNumber()
+					if err := d.Decode(&v); err != nil {
+						return nil, err
+					}
+					variables[cfg.Body] = v
+				}
+			}
+
+			payload := map[string]interface{}{"query": cfg.Query, "variables": variables}
+			if cfg.OperationName != "" {
+				payload["operationName"] = cfg.OperationName
+			}
+			b, err := json.Marshal(payload)
+			if err != nil {
+				return nil, err
+			}
+
+			headers := make(map[string][]string, len(request.Headers)+1)
+			for k, v := range request.Headers {
+				headers[k] = v
+			}
+			headers["Content-Type"] = []string{"application/json"}
+
+			r := request.Clone()
+			r.Method = "POST"
+			r.Body = ioutil.NopCloser(bytes.NewReader(b))
+			r.Headers = headers
+			return next[0](ctx, &r)
+		}
+	}
+}
+
+// GraphQLErrorMessage is an item of the errors array of a GraphQL response
+type GraphQLErrorMessage struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLError is the error returned when the GraphQL backend returns errors without data
+type GraphQLError struct {
+	Errors []GraphQLErrorMessage
+}
+
+// Error implements the error interface
+func (g GraphQLError) Error() string {
+	msgs := make([]string, len(g.Errors))
+	for i, e := range g.Errors {
+		msgs[i] = e.Message
+	}
+	return "graphql: " + strings.Join(msgs, "; ")
+}
+
+// StatusCode returns the status code to send to the client, depending on the 'code' extension of the
+// first error
+func (g GraphQLError) StatusCode() int {
+	code, _ := g.Errors[0].Extensions["code"].(string)
+	switch code {
+	case "BAD_USER_INPUT", "GRAPHQL_PARSE_FAILED", "GRAPHQL_VALIDATION_FAILED":
+		return http.StatusBadRequest
+	case "UNAUTHENTICATED":
+		return http.StatusUnauthorized
+	case "FORBIDDEN":
+		return http.StatusForbidden
+	case "NOT_FOUND":
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GraphQLResponseParser returns a HTTPResponseParser flattening the data of the GraphQL responses into
+// the data of the proxy response, before applying the received formatter. The responses with errors and
+// no data fail with a GraphQLError and the ones with errors and data are flagged as incomplete
+func GraphQLResponseParser(ef EntityFormatter) HTTPResponseParser {
+	return func(ctx context.Context, resp *http.Response) (*Response, error) {
+		body, err := decompressedBody(resp)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		var result struct {
+			Data   map[string]interface{} `json:"data"`
+			Errors []GraphQLErrorMessage  `json:"errors"`
+		}
+		d := json.NewDecoder(body)
+		d.UseNumber()
+		err = d.Decode(&result)
+		body.Close()
+		resp.Body.Close()
+
+		isSuccess := resp.StatusCode >= 200 && resp.StatusCode < 300
+		if result.Data == nil && len(result.Errors) > 0 {
+			return nil, GraphQLError{result.Errors}
+		}
+		if !isSuccess {
+			return nil, ErrInvalidStatusCode
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r := ef.Format(Response{
+			Data:       result.Data,
+			IsComplete: len(result.Errors) == 0,
+			Metadata: Metadata{
+				Headers:    resp.Header,
+				StatusCode: resp.StatusCode,
+			},
+		})
+		return &r, nil
+	}
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+func TestGraphQLConfigGetter(t *testing.T) {
+	if _, ok, err := GraphQLConfigGetter(&config.Backend{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	remote := &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		graphQLKey: map[string]interface{}{"variables": map[string]interface{}{"a": 1}},
+	}}}
+	if _, ok, err := GraphQLConfigGetter(remote); !ok || err != ErrNoGraphQLQuery {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+}
+
+func graphQLBackend(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %v", r.Method, r.Header)
+		}
+		var payload struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		switch payload.Variables["id"] {
+		case "42":
+			b, _ := json.Marshal(map[string]interface{}{
+				"data": map[string]interface{}{
+					"user":  map[string]interface{}{"name": "supu", "token": payload.Variables["token"]},
+					"input": payload.Variables["input"],
+					"limit": payload.Variables["limit"],
+					"op":    payload.OperationName,
+				},
+			})
+			w.Write(b)
+		case "1":
+			w.Write([]byte(`{"data":{"user":{"name":"tupu"},"posts":null},"errors":[{"message":"posts unavailable","path":["posts"]}]}`))
+		case "2":
+			w.Write([]byte(`{"data":null,"errors":[{"message":"user not found","extensions":{"code":"NOT_FOUND"}}]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"invalid id","extensions":{"code":"BAD_USER_INPUT"}}]}`))
+		}
+	}))
+}
+
+func TestNewHTTPProxy_graphql(t *testing.T) {
+	backend := graphQLBackend(t)
+	defer backend.Close()
+
+	remote := &config.Backend{
+		Method:  "GET",
+		Decoder: encoding.JSONDecoder,
+		Target:  "user",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			graphQLKey: map[string]interface{}{
+				"query":          "query GetUser($id: ID!) { user(id: $id) { name } }",
+				"operation_name": "GetUser",
+				"variables":      map[string]interface{}{"limit": 10, "id": "0"},
+				"params":         map[string]interface{}{"id": "id"},
+				"headers":        map[string]interface{}{"token": "x-token"},
+				"body":           "input",
+			},
+		}},
+	}
+	u, _ := url.Parse(backend.URL)
+	p := HTTPProxyFactory(http.DefaultClient)(remote)
+
+	resp, err := p(context.Background(), &Request{
+		Method:  "GET",
+		URL:     u,
+		Params:  map[string]string{"Id": "42"},
+		Headers: map[string][]string{"X-Token": {"secret"}},
+		Body:    ioutil.NopCloser(strings.NewReader(`{"a":true}`)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsComplete || !reflect.DeepEqual(resp.Data, map[string]interface{}{"name": "supu", "token": "secret"}) {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	remote.Target = ""
+	p = HTTPProxyFactory(http.DefaultClient)(remote)
+	resp, err = p(context.Background(), &Request{
+		URL:    u,
+		Params: map[string]string{"Id": "42"},
+		Body:   ioutil.NopCloser(strings.NewReader(`{"a":true}`)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp.Data["input"], map[string]interface{}{"a": true}) || resp.Data["limit"] != json.Number("10") ||
+		resp.Data["op"] != "GetUser" {
+		t.Errorf("unexpected variables: %+v", resp.Data)
+	}
+
+	resp, err = p(context.Background(), &Request{URL: u, Params: map[string]string{"Id": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.IsComplete || !reflect.DeepEqual(resp.Data, map[string]interface{}{"user": map[string]interface{}{"name": "tupu"}, "posts": nil}) {
+		t.Errorf("the responses with errors should be incomplete: %+v", resp)
+	}
+
+	for id, status := range map[string]int{"2": http.StatusNotFound, "3": http.StatusBadRequest} {
+		_, err = p(context.Background(), &Request{URL: u, Params: map[string]string{"Id": id}})
+		e, ok := err.(GraphQLError)
+		if !ok || e.StatusCode() != status {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestNewHTTPProxy_graphqlBadStatusCode(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer backend.Close()
+
+	remote := &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		graphQLKey: map[string]interface{}{"query": "{ users { name } }"},
+	}}}
+	u, _ := url.Parse(backend.URL)
+	if _, err := HTTPProxyFactory(http.DefaultClient)(remote)(context.Background(), &Request{URL: u}); err != ErrInvalidStatusCode {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGraphQLError(t *testing.T) {
+	err := GraphQLError{[]GraphQLErrorMessage{
+		{Message: "forbidden", Extensions: map[string]interface{}{"code": "FORBIDDEN"}},
+		{Message: "other"},
+	}}
+	if err.Error() != "graphql: forbidden; other" || err.StatusCode() != http.StatusForbidden {
+		t.Errorf("unexpected error: %s %d", err.Error(), err.StatusCode())
+	}
+	if s := (GraphQLError{[]GraphQLErrorMessage{{Message: "boom"}}}).StatusCode(); s != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", s)
+	}
+}
@@ -0,0 +1,254 @@
+// Package grpc provides a backend factory for the gRPC services, transcoding the requests of the gateway
+// into protobuf messages and the responses of the services into the data of the proxy responses
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding/protobuf"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// Namespace is the key to look for the gRPC options in the extra config of the backends
+const Namespace = "github.com/devopsfaith/krakend/proxy/grpc"
+
+var (
+	// ErrNoMethod is the error returned when the extra config does not define the service or the method to call
+	ErrNoMethod = errors.New("grpc: the service and the method are required")
+	// ErrStreamingMethod is the error returned when the configured method streams its request or its response
+	ErrStreamingMethod = errors.New("grpc: the streaming methods are not supported")
+)
+
+// Config is the gRPC options set at the extra config of the backend
+type Config struct {
+	// Service is the full name of the service
+	Service string `json:"service"`
+	// Method is the name of the method of the service to call
+	Method string `json:"method"`
+	// DescriptorSets is the list of descriptor set files (as generated by protoc --descriptor_set_out)
+	// to look for the service. If empty, the descriptors compiled into the binary are used
+	DescriptorSets []string `json:"descriptor_sets"`
+}
+
+// ConfigGetter parses the gRPC options from the extra config. The second value is false if the backend
+// does not declare them
+func ConfigGetter(extra config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, err
+	}
+	if cfg.Service == "" || cfg.Method == "" {
+		return cfg, true, ErrNoMethod
+	}
+	return cfg, true, nil
+}
+
+// MethodDescriptor looks for the descriptor of the configured method
+func MethodDescriptor(cfg Config) (protoreflect.MethodDescriptor, error) {
+	files, err := protobuf.Files(cfg.DescriptorSets)
+	if err != nil {
+		return nil, err
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(cfg.Service))
+	if err != nil {
+		return nil, fmt.Errorf("grpc: looking for the service %s: %s", cfg.Service, err.Error())
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("grpc: %s is not a service", cfg.Service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(cfg.Method))
+	if md == nil {
+		return nil, fmt.Errorf("grpc: the service %s has no method %s", cfg.Service, cfg.Method)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, ErrStreamingMethod
+	}
+	return md, nil
+}
+
+// BackendFactory returns a BackendFactory creating gRPC proxies for the backends declaring the gRPC
+// options in their extra config and delegating the creation of the rest of them to the next factory.
+// The connections to the hosts of the backends are shared by all the proxies created by the factory
+func BackendFactory(next proxy.BackendFactory) proxy.BackendFactory {
+	pool := &connPool{conns: map[string]*gogrpc.ClientConn{}}
+	return func(remote *config.Backend) proxy.Proxy {
+		cfg, ok, err := ConfigGetter(remote.ExtraConfig)
+		if !ok {
+			return next(remote)
+		}
+		if err != nil {
+			return errorProxy(err)
+		}
+		md, err := MethodDescriptor(cfg)
+		if err != nil {
+			return errorProxy(err)
+		}
+		ef, err := proxy.NewBackendEntityFormatter(remote)
+		if err != nil {
+			return errorProxy(err)
+		}
+		return NewProxy(md, pool.invoke, ef)
+	}
+}
+
+// Invoker calls the method of the gRPC service at the host of the received URL
+type Invoker func(ctx context.Context, host *url.URL, method string, in, out interface{}, opts ...gogrpc.CallOption) error
+
+// NewProxy creates a proxy calling the received method with the request transcoded into its input message.
+// The params, the query string and the JSON body of the request are set as the fields of the message with
+// the same name (ignoring the case) and the output message is returned as the data of the response
+func NewProxy(md protoreflect.MethodDescriptor, invoke Invoker, ef proxy.EntityFormatter) proxy.Proxy {
+	method := "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+	return func(ctx context.Context, request *proxy.Request) (*proxy.Response, error) {
+		in, err := NewInputMessage(md.Input(), request)
+		if err != nil {
+			return nil, err
+		}
+		out := dynamicpb.NewMessage(md.Output())
+
+		ctx = metadata.NewOutgoingContext(ctx, outgoingMetadata(request.Headers))
+		header := metadata.MD{}
+		if err := invoke(ctx, request.URL, method, in, out, gogrpc.Header(&header)); err != nil {
+			if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+				return nil, Error{Code: s.Code(), Message: s.Message()}
+			}
+			return nil, err
+		}
+
+		data, err := OutputData(out)
+		if err != nil {
+			return nil, err
+		}
+		headers := map[string][]string{}
+		for k, v := range header {
+			headers[http.CanonicalHeaderKey(k)] = v
+		}
+		r := ef.Format(proxy.Response{
+			Data:       data,
+			IsComplete: true,
+			Metadata:   proxy.Metadata{Headers: headers, StatusCode: http.StatusOK},
+		})
+		return &r, nil
+	}
+}
+
+// Error is the error returned by the proxy when the gRPC service returns a status other than OK
+type Error struct {
+	Code    codes.Code
+	Message string
+}
+
+// Error implements the error interface
+func (e Error) Error() string {
+	return fmt.Sprintf("grpc: %s: %s", e.Code.String(), e.Message)
+}
+
+// StatusCode returns the HTTP status code equivalent to the gRPC status, as mapped by the gRPC gateways
+func (e Error) StatusCode() int {
+	switch e.Code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// outgoingMetadata converts the headers of the request into gRPC metadata. The headers of the HTTP
+// connection are not sent
+func outgoingMetadata(headers map[string][]string) metadata.MD {
+	md := metadata.MD{}
+	for k, v := range headers {
+		k = strings.ToLower(k)
+		switch k {
+		case "connection", "content-length", "content-type", "host", "te", "transfer-encoding", "upgrade":
+			continue
+		}
+		md[k] = v
+	}
+	return md
+}
+
+func errorProxy(err error) proxy.Proxy {
+	return func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) { return nil, err }
+}
+
+// connPool keeps a client connection for every host. The https hosts are dialed with TLS and the rest
+// of them in plaintext
+type connPool struct {
+	mu    sync.Mutex
+	conns map[string]*gogrpc.ClientConn
+}
+
+func (p *connPool) invoke(ctx context.Context, host *url.URL, method string, in, out interface{}, opts ...gogrpc.CallOption) error {
+	conn, err := p.conn(host)
+	if err != nil {
+		return err
+	}
+	return conn.Invoke(ctx, method, in, out, opts...)
+}
+
+func (p *connPool) conn(host *url.URL) (*gogrpc.ClientConn, error) {
+	key := host.Scheme + "://" + host.Host
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if conn, ok := p.conns[key]; ok {
+		return conn, nil
+	}
+	creds := insecure.NewCredentials()
+	if host.Scheme == "https" {
+		creds = credentials.NewTLS(&tls.Config{})
+	}
+	conn, err := gogrpc.Dial(host.Host, gogrpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	p.conns[key] = conn
+	return conn, nil
+}
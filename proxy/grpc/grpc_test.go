@@ -0,0 +1,186 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func testDescriptorSetFile(t *testing.T) string {
+	b, err := proto.Marshal(testFileDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "krakend_grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(b)
+	f.Close()
+	return f.Name()
+}
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, err := ConfigGetter(config.ExtraConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"service": "test.Users"}}); !ok || err != ErrNoMethod {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"service":         "test.Users",
+		"method":          "GetUser",
+		"descriptor_sets": []interface{}{"users.pb"},
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result: %v %v", ok, err)
+	}
+	if !reflect.DeepEqual(cfg, Config{Service: "test.Users", Method: "GetUser", DescriptorSets: []string{"users.pb"}}) {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestMethodDescriptor(t *testing.T) {
+	path := testDescriptorSetFile(t)
+	defer os.Remove(path)
+
+	md, err := MethodDescriptor(Config{Service: "test.Users", Method: "GetUser", DescriptorSets: []string{path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.Input().FullName() != "test.GetUserRequest" || md.Output().FullName() != "test.User" {
+		t.Errorf("unexpected method: %v", md)
+	}
+
+	if _, err := MethodDescriptor(Config{Service: "test.Users", Method: "WatchUser", DescriptorSets: []string{path}}); err != ErrStreamingMethod {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, cfg := range []Config{
+		{Service: "test.Users", Method: "DeleteUser", DescriptorSets: []string{path}},
+		{Service: "test.User", Method: "GetUser", DescriptorSets: []string{path}},
+		{Service: "test.Posts", Method: "GetPost", DescriptorSets: []string{path}},
+		{Service: "test.Users", Method: "GetUser", DescriptorSets: []string{path + ".unknown"}},
+	} {
+		if _, err := MethodDescriptor(cfg); err == nil {
+			t.Errorf("%+v: error expected", cfg)
+		}
+	}
+}
+
+func TestNewProxy(t *testing.T) {
+	path := testDescriptorSetFile(t)
+	defer os.Remove(path)
+	md, err := MethodDescriptor(Config{Service: "test.Users", Method: "GetUser", DescriptorSets: []string{path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoke := func(ctx context.Context, host *url.URL, method string, in, out interface{}, opts ...gogrpc.CallOption) error {
+		if host.Host != "users.example.com:50051" || method != "/test.Users/GetUser" {
+			t.Errorf("unexpected call: %s %s", host, method)
+		}
+		if md, _ := metadata.FromOutgoingContext(ctx); !reflect.DeepEqual(md["x-token"], []string{"supu"}) || len(md["content-type"]) > 0 {
+			t.Errorf("unexpected metadata: %v", md)
+		}
+		req := in.(*dynamicpb.Message)
+		id := req.Get(req.Descriptor().Fields().ByName("user_id")).Int()
+		if id != 42 {
+			return status.Error(codes.NotFound, "unknown user")
+		}
+		resp := out.(*dynamicpb.Message)
+		fields := resp.Descriptor().Fields()
+		resp.Set(fields.ByName("user_id"), protoreflect.ValueOfInt32(int32(id)))
+		resp.Set(fields.ByName("user_name"), protoreflect.ValueOfString("supu"))
+		return nil
+	}
+	p := NewProxy(md, invoke, proxy.NewEntityFormatter("", []string{"user_name"}, nil, "user", nil))
+
+	u, _ := url.Parse("http://users.example.com:50051/users/42")
+	request := &proxy.Request{
+		URL:     u,
+		Params:  map[string]string{"User_id": "42"},
+		Headers: map[string][]string{"X-Token": {"supu"}, "Content-Type": {"application/json"}},
+	}
+	resp, err := p(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsComplete || resp.Metadata.StatusCode != http.StatusOK {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if !reflect.DeepEqual(resp.Data, map[string]interface{}{"user": map[string]interface{}{"user_name": "supu"}}) {
+		t.Errorf("unexpected data: %v", resp.Data)
+	}
+
+	request.Params = map[string]string{"User_id": "1"}
+	_, err = p(context.Background(), request)
+	e, ok := err.(Error)
+	if !ok || e.Code != codes.NotFound || e.StatusCode() != http.StatusNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestBackendFactory(t *testing.T) {
+	path := testDescriptorSetFile(t)
+	defer os.Remove(path)
+
+	next := func(_ *config.Backend) proxy.Proxy {
+		return func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+			return &proxy.Response{Data: map[string]interface{}{"http": true}}, nil
+		}
+	}
+	bf := BackendFactory(next)
+
+	resp, err := bf(&config.Backend{})(context.Background(), &proxy.Request{})
+	if err != nil || resp.Data["http"] != true {
+		t.Errorf("the backends without gRPC options should be created by the next factory: %v %v", resp, err)
+	}
+
+	p := bf(&config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"service":         "test.Users",
+		"method":          "WatchUser",
+		"descriptor_sets": []interface{}{path},
+	}}})
+	if _, err := p(context.Background(), &proxy.Request{}); err != ErrStreamingMethod {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestError_StatusCode(t *testing.T) {
+	for code, expected := range map[codes.Code]int{
+		codes.InvalidArgument:  http.StatusBadRequest,
+		codes.Unauthenticated:  http.StatusUnauthorized,
+		codes.PermissionDenied: http.StatusForbidden,
+		codes.Unavailable:      http.StatusServiceUnavailable,
+		codes.DeadlineExceeded: http.StatusGatewayTimeout,
+		codes.Internal:         http.StatusInternalServerError,
+	} {
+		if s := (Error{Code: code}).StatusCode(); s != expected {
+			t.Errorf("%s: unexpected status code %d", code, s)
+		}
+	}
+}
+
+func TestOutgoingMetadata(t *testing.T) {
+	md := outgoingMetadata(map[string][]string{"X-Token": {"supu"}, "Connection": {"close"}})
+	b, _ := json.Marshal(md)
+	if string(b) != `{"x-token":["supu"]}` {
+		t.Errorf("unexpected metadata: %s", b)
+	}
+}
@@ -0,0 +1,155 @@
+package grpc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/devopsfaith/krakend/encoding"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// NewInputMessage transcodes the request into a message of the received type. The JSON body is decoded
+// first (discarding the unknown fields) and then the query string and the params override the scalar
+// fields with the same name (or JSON name), ignoring the case. The query strings with several values
+// are appended to the repeated fields
+func NewInputMessage(md protoreflect.MessageDescriptor, request *proxy.Request) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(md)
+	if request.Body != nil {
+		b, err := ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(b)) > 0 {
+			if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(b, msg); err != nil {
+				return nil, fmt.Errorf("grpc: decoding the body: %s", err.Error())
+			}
+		}
+	}
+	for k, vs := range request.Query {
+		if err := setField(msg, k, vs); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range request.Params {
+		if err := setField(msg, k, []string{v}); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// OutputData converts the message into the data of a response through its canonical JSON mapping,
+// keeping the field names declared in the proto file
+func OutputData(msg *dynamicpb.Message) (map[string]interface{}, error) {
+	b, err := (protojson.MarshalOptions{UseProtoNames: true}).Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{}
+	if err := encoding.JSONDecoder(bytes.NewReader(b), &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func setField(msg *dynamicpb.Message, name string, values []string) error {
+	fd := fieldByName(msg.Descriptor(), name)
+	if fd == nil || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind || fd.IsMap() {
+		return nil
+	}
+	if !fd.IsList() {
+		if len(values) == 0 {
+			return nil
+		}
+		v, err := scalarValue(fd, values[0])
+		if err != nil {
+			return err
+		}
+		msg.Set(fd, v)
+		return nil
+	}
+	list := msg.Mutable(fd).List()
+	for _, s := range values {
+		v, err := scalarValue(fd, s)
+		if err != nil {
+			return err
+		}
+		list.Append(v)
+	}
+	return nil
+}
+
+func fieldByName(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if strings.EqualFold(string(fd.Name()), name) || strings.EqualFold(fd.JSONName(), name) {
+			return fd
+		}
+	}
+	return nil
+}
+
+func scalarValue(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	var err error
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(s)), nil
+	case protoreflect.BoolKind:
+		var v bool
+		if v, err = strconv.ParseBool(s); err == nil {
+			return protoreflect.ValueOfBool(v), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var v int64
+		if v, err = strconv.ParseInt(s, 10, 32); err == nil {
+			return protoreflect.ValueOfInt32(int32(v)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var v int64
+		if v, err = strconv.ParseInt(s, 10, 64); err == nil {
+			return protoreflect.ValueOfInt64(v), nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var v uint64
+		if v, err = strconv.ParseUint(s, 10, 32); err == nil {
+			return protoreflect.ValueOfUint32(uint32(v)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var v uint64
+		if v, err = strconv.ParseUint(s, 10, 64); err == nil {
+			return protoreflect.ValueOfUint64(v), nil
+		}
+	case protoreflect.FloatKind:
+		var v float64
+		if v, err = strconv.ParseFloat(s, 32); err == nil {
+			return protoreflect.ValueOfFloat32(float32(v)), nil
+		}
+	case protoreflect.DoubleKind:
+		var v float64
+		if v, err = strconv.ParseFloat(s, 64); err == nil {
+			return protoreflect.ValueOfFloat64(v), nil
+		}
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		var v int64
+		if v, err = strconv.ParseInt(s, 10, 32); err == nil {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), nil
+		}
+	default:
+		err = fmt.Errorf("unsupported kind %s", fd.Kind())
+	}
+	return protoreflect.Value{}, fmt.Errorf("grpc: invalid value %q for the field %s: %s", s, fd.Name(), err.Error())
+}
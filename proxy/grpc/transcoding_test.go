@@ -0,0 +1,152 @@
+package grpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func testField(name, jsonName string, number int32, label descriptorpb.FieldDescriptorProto_Label, kind descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(jsonName),
+		Number:   proto.Int32(number),
+		Label:    label.Enum(),
+		Type:     kind.Enum(),
+	}
+}
+
+func testFileDescriptorSet() *descriptorpb.FileDescriptorSet {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	status := testField("status", "status", 4, optional, descriptorpb.FieldDescriptorProto_TYPE_ENUM)
+	status.TypeName = proto.String(".test.Status")
+	return &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("users.proto"),
+				Package: proto.String("test"),
+				Syntax:  proto.String("proto3"),
+				EnumType: []*descriptorpb.EnumDescriptorProto{
+					{
+						Name: proto.String("Status"),
+						Value: []*descriptorpb.EnumValueDescriptorProto{
+							{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+							{Name: proto.String("ACTIVE"), Number: proto.Int32(1)},
+						},
+					},
+				},
+				MessageType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("GetUserRequest"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							testField("user_id", "userId", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+							testField("verbose", "verbose", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_BOOL),
+							testField("fields", "fields", 3, repeated, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+							status,
+						},
+					},
+					{
+						Name: proto.String("User"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							testField("user_id", "userId", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+							testField("user_name", "userName", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+						},
+					},
+				},
+				Service: []*descriptorpb.ServiceDescriptorProto{
+					{
+						Name: proto.String("Users"),
+						Method: []*descriptorpb.MethodDescriptorProto{
+							{
+								Name:       proto.String("GetUser"),
+								InputType:  proto.String(".test.GetUserRequest"),
+								OutputType: proto.String(".test.User"),
+							},
+							{
+								Name:            proto.String("WatchUser"),
+								InputType:       proto.String(".test.GetUserRequest"),
+								OutputType:      proto.String(".test.User"),
+								ServerStreaming: proto.Bool(true),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func testMessageDescriptor(t *testing.T, name string) protoreflect.MessageDescriptor {
+	files, err := protodesc.NewFiles(testFileDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.(protoreflect.MessageDescriptor)
+}
+
+func TestNewInputMessage(t *testing.T) {
+	md := testMessageDescriptor(t, "test.GetUserRequest")
+	msg, err := NewInputMessage(md, &proxy.Request{
+		Body:   ioutil.NopCloser(strings.NewReader(`{"userId": 1, "verbose": true, "unknown": 42, "status": "ACTIVE"}`)),
+		Params: map[string]string{"User_id": "42"},
+		Query:  map[string][]string{"fields": {"a", "b"}, "Verbose": {"false"}, "ignored": {"1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := OutputData(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"user_id": json.Number("42"),
+		"fields":  []interface{}{"a", "b"},
+		"status":  "ACTIVE",
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("unexpected message: %v", data)
+	}
+}
+
+func TestNewInputMessage_ko(t *testing.T) {
+	md := testMessageDescriptor(t, "test.GetUserRequest")
+	for _, r := range []*proxy.Request{
+		{Params: map[string]string{"User_id": "supu"}},
+		{Query: map[string][]string{"verbose": {"maybe"}}},
+		{Query: map[string][]string{"status": {"DELETED"}}},
+		{Body: ioutil.NopCloser(strings.NewReader(`{"userId": "supu"}`))},
+	} {
+		if _, err := NewInputMessage(md, r); err == nil {
+			t.Errorf("%v: error expected", r)
+		}
+	}
+}
+
+func TestOutputData(t *testing.T) {
+	md := testMessageDescriptor(t, "test.User")
+	msg := dynamicpb.NewMessage(md)
+	msg.Set(md.Fields().ByName("user_id"), protoreflect.ValueOfInt32(42))
+	msg.Set(md.Fields().ByName("user_name"), protoreflect.ValueOfString("supu"))
+	data, err := OutputData(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, map[string]interface{}{"user_id": json.Number("42"), "user_name": "supu"}) {
+		t.Errorf("unexpected data: %v", data)
+	}
+}
@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	headersKey = "headers"
+
+	// HeaderActionAdd appends the value to the header
+	HeaderActionAdd = "add"
+	// HeaderActionSet replaces the values of the header with the value
+	HeaderActionSet = "set"
+	// HeaderActionRemove deletes the header
+	HeaderActionRemove = "remove"
+	// HeaderActionRename moves the values of the header to the target one
+	HeaderActionRename = "rename"
+	// HeaderActionCopy replaces the values of the target header with the ones of the header
+	HeaderActionCopy = "copy"
+)
+
+// HeaderRule is a transformation of the headers of the requests sent to a backend
+type HeaderRule struct {
+	// Action is one of add, set, remove, rename or copy
+	Action string `json:"action"`
+	// Name of the header
+	Name string `json:"name"`
+	// Value of the add and set actions. It is a template with the params of the request (.Params), the
+	// claims of its bearer token (.Claims) and the environment (.Env)
+	Value string `json:"value"`
+	// To is the target header of the rename and copy actions
+	To string `json:"to"`
+}
+
+// headerContext is the data available to the templated values of the header rules and the body injections
+type headerContext struct {
+	Params map[string]string
+	Claims map[string]string
+	Env    map[string]string
+}
+
+type headerRule struct {
+	HeaderRule
+	tmpl *template.Template
+}
+
+// NewHeadersMiddleware creates a proxy middleware applying the header rules of the backend, in order, to
+// the requests sent to it. The claims of the bearer tokens are not validated, so it must be done by
+// another layer. The empty values of the add and set actions are skipped, so the missing params and
+// claims do not generate empty headers.
+//
+// The middleware is enabled with the 'headers' option of the backend proxy extra config
+func NewHeadersMiddleware(remote *config.Backend) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	v, ok := extra[headersKey]
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	cfg := []HeaderRule{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+
+	rules := make([]headerRule, len(cfg))
+	useClaims := false
+	for i, r := range cfg {
+		rule, err := newHeaderRule(r)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = rule
+		useClaims = useClaims || strings.Contains(r.Value, ".Claims")
+	}
+
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			r := request.Clone()
+			r.Headers = make(map[string][]string, len(request.Headers))
+			for k, vs := range request.Headers {
+				r.Headers[k] = vs
+			}
+			data := headerContext{Params: r.Params, Env: env}
+			if useClaims {
+				data.Claims = bearerClaims(r.Headers)
+			}
+			for _, rule := range rules {
+				if err := rule.apply(r.Headers, data); err != nil {
+					return nil, err
+				}
+			}
+			return next[0](ctx, &r)
+		}
+	}, nil
+}
+
+func newHeaderRule(r HeaderRule) (headerRule, error) {
+	r.Name = http.CanonicalHeaderKey(r.Name)
+	r.To = http.CanonicalHeaderKey(r.To)
+	rule := headerRule{HeaderRule: r}
+	if r.Name == "" {
+		return rule, fmt.Errorf("the header rules require a name: %+v", r)
+	}
+	switch r.Action {
+	case HeaderActionAdd, HeaderActionSet:
+		tmpl, err := template.New(r.Name).Funcs(templateFuncs).Option("missingkey=zero").Parse(r.Value)
+		if err != nil {
+			return rule, err
+		}
+		rule.tmpl = tmpl
+	case HeaderActionRename, HeaderActionCopy:
+		if r.To == "" {
+			return rule, fmt.Errorf("the %s header rules require a target header: %+v", r.Action, r)
+		}
+	case HeaderActionRemove:
+	default:
+		return rule, fmt.Errorf("unknown header rule action: %s", r.Action)
+	}
+	return rule, nil
+}
+
+func (r headerRule) apply(headers map[string][]string, data headerContext) error {
+	switch r.Action {
+	case HeaderActionAdd, HeaderActionSet:
+		buf := new(bytes.Buffer)
+		if err := r.tmpl.Execute(buf, data); err != nil {
+			return err
+		}
+		if buf.Len() == 0 {
+			return nil
+		}
+		if r.Action == HeaderActionSet {
+			headers[r.Name] = []string{buf.String()}
+			return nil
+		}
+		values := make([]string, len(headers[r.Name]), len(headers[r.Name])+1)
+		copy(values, headers[r.Name])
+		headers[r.Name] = append(values, buf.String())
+	case HeaderActionRemove:
+		delete(headers, r.Name)
+	case HeaderActionRename:
+		if values, ok := headers[r.Name]; ok {
+			delete(headers, r.Name)
+			headers[r.To] = values
+		}
+	case HeaderActionCopy:
+		if values, ok := headers[r.Name]; ok {
+			headers[r.To] = values
+		}
+	}
+	return nil
+}
+
+// bearerClaims returns the claims of the bearer token of the Authorization header, without validating it
+func bearerClaims(headers map[string][]string) map[string]string {
+	claims := map[string]string{}
+	values := headers["Authorization"]
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return claims
+	}
+	parts := strings.Split(strings.TrimPrefix(values[0], "Bearer "), ".")
+	if len(parts) != 3 {
+		return claims
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return claims
+	}
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return claims
+	}
+	for k, v := range raw {
+		claims[k] = fmt.Sprint(v)
+	}
+	return claims
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func headersBackend(rules ...map[string]interface{}) *config.Backend {
+	cfg := make([]interface{}, len(rules))
+	for i, r := range rules {
+		cfg[i] = r
+	}
+	return &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{headersKey: cfg}}}
+}
+
+func TestNewHeadersMiddleware(t *testing.T) {
+	os.Setenv("KRAKEND_TEST_REGION", "eu-west-1")
+	defer os.Unsetenv("KRAKEND_TEST_REGION")
+
+	mw, err := NewHeadersMiddleware(headersBackend(
+		map[string]interface{}{"action": "set", "name": "x-tenant", "value": "{{.Params.Tenant}}"},
+		map[string]interface{}{"action": "add", "name": "X-Forwarded-Region", "value": "{{.Env.KRAKEND_TEST_REGION}}"},
+		map[string]interface{}{"action": "set", "name": "X-User", "value": "{{upper .Claims.sub}}"},
+		map[string]interface{}{"action": "set", "name": "X-Missing", "value": "{{.Params.Unknown}}"},
+		map[string]interface{}{"action": "copy", "name": "Authorization", "to": "X-Original-Authorization"},
+		map[string]interface{}{"action": "remove", "name": "Authorization"},
+		map[string]interface{}{"action": "rename", "name": "X-Api-Version", "to": "Accept-Version"},
+		map[string]interface{}{"action": "rename", "name": "X-Unknown", "to": "X-Other"},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := "Bearer e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"supu","admin":true}`)) + ".signature"
+	original := map[string][]string{
+		"Authorization":      {token},
+		"X-Api-Version":      {"2"},
+		"X-Forwarded-Region": {"us-east-1"},
+	}
+	request := &Request{Params: map[string]string{"Tenant": "acme"}, Headers: original}
+
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		expected := map[string][]string{
+			"X-Tenant":                 {"acme"},
+			"X-Forwarded-Region":       {"us-east-1", "eu-west-1"},
+			"X-User":                   {"SUPU"},
+			"X-Original-Authorization": {token},
+			"Accept-Version":           {"2"},
+		}
+		if !reflect.DeepEqual(r.Headers, expected) {
+			t.Errorf("unexpected headers: %v", r.Headers)
+		}
+		return &Response{IsComplete: true}, nil
+	})
+	if _, err := p(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	if len(original) != 3 || len(original["X-Forwarded-Region"]) != 1 {
+		t.Errorf("the headers of the received request were modified: %v", original)
+	}
+}
+
+func TestNewHeadersMiddleware_disabled(t *testing.T) {
+	mw, err := NewHeadersMiddleware(&config.Backend{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &Request{Headers: map[string][]string{"X-Supu": {"tupu"}}}
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		if r != request {
+			t.Error("the request must not be cloned")
+		}
+		return &Response{IsComplete: true}, nil
+	})
+	p(context.Background(), request)
+}
+
+func TestNewHeadersMiddleware_koConfig(t *testing.T) {
+	for i, rule := range []map[string]interface{}{
+		{"action": "set", "value": "supu"},
+		{"action": "unknown", "name": "X-Supu"},
+		{"action": "rename", "name": "X-Supu"},
+		{"action": "copy", "name": "X-Supu"},
+		{"action": "set", "name": "X-Supu", "value": "{{.Params.Tenant"},
+	} {
+		if _, err := NewHeadersMiddleware(headersBackend(rule)); err == nil {
+			t.Errorf("#%d: error expected", i)
+		}
+	}
+}
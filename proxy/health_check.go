@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+const healthCheckKey = "health_check"
+
+// NewHealthCheckedSubscriber wraps the subscriber of the backend with the health checks declared in the
+// 'health_check' option of the backend proxy extra config: the active probes run every 'interval' with
+// the 'timeout', requesting the 'path' (or opening a TCP connection if it is empty), and they eject the
+// hosts after 'unhealthy_threshold' failures and re-admit them after 'healthy_threshold' successes. The
+// hosts are also ejected for the 'ejection_time' after 'max_failures' consecutive failed requests.
+//
+// It returns a nil subscriber when the health checks are not configured
+func NewHealthCheckedSubscriber(remote *config.Backend, subscriber sd.Subscriber) (*sd.HealthCheckedSubscriber, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	cfg, ok := extra[healthCheckKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	hc := sd.HealthCheckConfig{}
+	hc.Path, _ = cfg["path"].(string)
+	for name, i := range map[string]*int{
+		"healthy_threshold":   &hc.HealthyThreshold,
+		"unhealthy_threshold": &hc.UnhealthyThreshold,
+		"max_failures":        &hc.MaxFailures,
+	} {
+		if v, ok := cfg[name].(float64); ok {
+			*i = int(v)
+		}
+	}
+	for name, d := range map[string]*time.Duration{
+		"interval":      &hc.Interval,
+		"timeout":       &hc.Timeout,
+		"ejection_time": &hc.EjectionTime,
+	} {
+		v, ok := cfg[name].(string)
+		if !ok {
+			continue
+		}
+		var err error
+		if *d, err = time.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return sd.NewHealthCheckedSubscriber(subscriber, hc), nil
+}
+
+// NewPassiveHealthCheckMiddleware creates a proxy middleware reporting the result of every request to the
+// health checked subscriber. The 5xx responses, the network errors and the timeouts are failures. It must
+// be placed after the load balancer, so the requests have the URL of the host
+func NewPassiveHealthCheckMiddleware(hc *sd.HealthCheckedSubscriber) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			recorder := &statusRecorder{}
+			resp, err := next[0](context.WithValue(ctx, statusRecorderKey{}, recorder), request)
+			if recorder.code != 0 {
+				recordStatusCode(ctx, recorder.code)
+			}
+			if request.URL == nil || err == context.Canceled {
+				return resp, err
+			}
+
+			_, isNetworkError := err.(net.Error)
+			failed := recorder.code >= 500 || isNetworkError || err == context.DeadlineExceeded
+			hc.Report(request.URL.Scheme+"://"+request.URL.Host, !failed)
+			return resp, err
+		}
+	}
+}
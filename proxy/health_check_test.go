@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+func TestNewHealthCheckedSubscriber(t *testing.T) {
+	if hc, err := NewHealthCheckedSubscriber(&config.Backend{}, sd.FixedSubscriber{}); hc != nil || err != nil {
+		t.Errorf("unexpected result: %v %v", hc, err)
+	}
+
+	_, err := NewHealthCheckedSubscriber(&config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			healthCheckKey: map[string]interface{}{"ejection_time": "abc"},
+		}},
+	}, sd.FixedSubscriber{})
+	if err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestNewPassiveHealthCheckMiddleware(t *testing.T) {
+	hosts := sd.FixedSubscriber{"http://a:8080", "http://b:8080"}
+	hc, err := NewHealthCheckedSubscriber(&config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			healthCheckKey: map[string]interface{}{"max_failures": 2.0},
+		}},
+	}, hosts)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+
+	responses := map[string]int{"a:8080": 200, "b:8080": 503}
+	p := NewPassiveHealthCheckMiddleware(hc)(func(ctx context.Context, r *Request) (*Response, error) {
+		recordStatusCode(ctx, responses[r.URL.Host])
+		if responses[r.URL.Host] != 200 {
+			return nil, errors.New("wrong status code")
+		}
+		return &Response{IsComplete: true}, nil
+	})
+
+	for _, h := range []string{"http://a:8080/supu", "http://b:8080/supu", "http://b:8080/tupu"} {
+		u, _ := url.Parse(h)
+		recorder := &statusRecorder{}
+		p(context.WithValue(context.Background(), statusRecorderKey{}, recorder), &Request{URL: u})
+		if recorder.code != responses[u.Host] {
+			t.Errorf("the status code was not propagated: %d", recorder.code)
+		}
+	}
+
+	healthy, _ := hc.Hosts()
+	if len(healthy) != 1 || healthy[0] != "http://a:8080" {
+		t.Errorf("unexpected hosts: %v", healthy)
+	}
+}
@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+const (
+	hedgingKey = "hedging"
+
+	defaultMaxHedges = 1
+)
+
+// ErrInvalidHedgingDelay is the error returned when the delay of the hedged requests is not positive
+var ErrInvalidHedgingDelay = errors.New("the delay of the hedged requests must be greater than 0")
+
+// NewHedgingMiddleware creates a proxy middleware sending a duplicate of the request to the backend every
+// time the 'delay' expires without a response, up to 'max_hedges' duplicates. The first successful
+// response is returned and the pending requests are canceled. The delay should be close to a high
+// percentile of the backend latency (e.g. p95), so only the slowest requests are duplicated.
+//
+// The middleware is enabled with the 'hedging' option of the backend proxy extra config. Since the
+// requests are duplicated, it is only enabled for the GET and HEAD backends without streaming, and it
+// must be placed before the load balancer, so every duplicate can reach a different host. The no-op
+// backends are not hedged either, since the body of their responses is closed with the canceled
+// duplicates
+func NewHedgingMiddleware(remote *config.Backend) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	cfg, ok := extra[hedgingKey].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	switch remote.Method {
+	case "", http.MethodGet, http.MethodHead:
+	default:
+		return EmptyMiddleware, nil
+	}
+	if isStreamingEnabled(remote) || remote.Encoding == encoding.NOOP {
+		return EmptyMiddleware, nil
+	}
+
+	h := hedger{maxHedges: defaultMaxHedges}
+	if v, ok := cfg["delay"].(string); ok {
+		var err error
+		if h.delay, err = time.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+	if h.delay <= 0 {
+		return nil, ErrInvalidHedgingDelay
+	}
+	if v, ok := cfg["max_hedges"].(float64); ok {
+		h.maxHedges = int(v)
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			return h.call(ctx, next[0], request)
+		}
+	}, nil
+}
+
+type hedger struct {
+	delay     time.Duration
+	maxHedges int
+}
+
+type hedgedResult struct {
+	response *Response
+	err      error
+	code     int
+}
+
+func (h hedger) call(ctx context.Context, next Proxy, request *Request) (*Response, error) {
+	// the body is buffered, so it can be sent by every duplicate
+	var body []byte
+	if request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgedResult, h.maxHedges+1)
+	launched, pending := 0, 0
+	launch := func() {
+		launched++
+		pending++
+		req := request.Clone()
+		if request.Body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		go func() {
+			// every duplicate records its own status code, so they do not race
+			recorder := &statusRecorder{}
+			resp, err := next(context.WithValue(hedgeCtx, statusRecorderKey{}, recorder), &req)
+			results <- hedgedResult{response: resp, err: err, code: recorder.code}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if launched <= h.maxHedges {
+				launch()
+				timer.Reset(h.delay)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil || pending == 0 {
+				recordStatusCode(ctx, r.code)
+				return r.response, r.err
+			}
+		}
+	}
+}
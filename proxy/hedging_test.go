@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+func hedgingBackend(method string, cfg map[string]interface{}) *config.Backend {
+	return &config.Backend{
+		Method:      method,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{hedgingKey: cfg}},
+	}
+}
+
+func TestNewHedgingMiddleware_koConfig(t *testing.T) {
+	for i, cfg := range []map[string]interface{}{
+		{},
+		{"delay": "abc"},
+		{"delay": "-1s"},
+	} {
+		if _, err := NewHedgingMiddleware(hedgingBackend("GET", cfg)); err == nil {
+			t.Errorf("#%d: error expected", i)
+		}
+	}
+}
+
+func TestNewHedgingMiddleware_notReadOnly(t *testing.T) {
+	mw, err := NewHedgingMiddleware(hedgingBackend("POST", map[string]interface{}{"delay": "1ms"}))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	var calls uint64
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		atomic.AddUint64(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &Response{IsComplete: true}, nil
+	})
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+	if c := atomic.LoadUint64(&calls); c != 1 {
+		t.Errorf("unexpected number of calls: %d", c)
+	}
+}
+
+func TestNewHedgingMiddleware_fastestWins(t *testing.T) {
+	mw, err := NewHedgingMiddleware(hedgingBackend("GET", map[string]interface{}{"delay": "5ms", "max_hedges": 2.0}))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	var calls uint64
+	canceled := make(chan struct{}, 3)
+	p := mw(func(ctx context.Context, r *Request) (*Response, error) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != "supu" {
+			t.Errorf("unexpected body: %s", string(b))
+		}
+		if atomic.AddUint64(&calls, 1) == 2 {
+			recordStatusCode(ctx, 200)
+			return &Response{IsComplete: true, Data: map[string]interface{}{"hedged": true}}, nil
+		}
+		<-ctx.Done()
+		canceled <- struct{}{}
+		return nil, ctx.Err()
+	})
+
+	recorder := &statusRecorder{}
+	ctx := context.WithValue(context.Background(), statusRecorderKey{}, recorder)
+	resp, err := p(ctx, &Request{Body: ioutil.NopCloser(strings.NewReader("supu"))})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if v, ok := resp.Data["hedged"].(bool); !ok || !v {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	if recorder.code != 200 {
+		t.Errorf("unexpected status code: %d", recorder.code)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("the slow request was not canceled")
+	}
+	if c := atomic.LoadUint64(&calls); c != 2 {
+		t.Errorf("unexpected number of calls: %d", c)
+	}
+}
+
+func TestNewHedgingMiddleware_allFailed(t *testing.T) {
+	mw, err := NewHedgingMiddleware(hedgingBackend("", map[string]interface{}{"delay": "1ms", "max_hedges": 2.0}))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	var calls uint64
+	expectedErr := errors.New("expect me")
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		atomic.AddUint64(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return nil, expectedErr
+	})
+	if _, err := p(context.Background(), &Request{}); err != expectedErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if c := atomic.LoadUint64(&calls); c != 3 {
+		t.Errorf("unexpected number of calls: %d", c)
+	}
+}
+
+func TestNewHedgingMiddleware_noop(t *testing.T) {
+	backend := hedgingBackend("GET", map[string]interface{}{"delay": "1ms", "max_hedges": 2.0})
+	backend.Encoding = encoding.NOOP
+	mw, err := NewHedgingMiddleware(backend)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	var calls uint64
+	p := mw(func(ctx context.Context, _ *Request) (*Response, error) {
+		atomic.AddUint64(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &Response{Io: ctxReader{ctx: ctx, r: strings.NewReader("supu")}, IsComplete: true}, nil
+	})
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	b, err := ioutil.ReadAll(resp.Io)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if string(b) != "supu" {
+		t.Errorf("unexpected body: %s", string(b))
+	}
+	if c := atomic.LoadUint64(&calls); c != 1 {
+		t.Errorf("unexpected number of calls: %d", c)
+	}
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/sd"
+)
+
+// HostStatsWindow is the number of recent requests used to calculate the success rate and the latency
+// percentile of every host
+var HostStatsWindow = 256
+
+// HostStats are the statistics of the requests sent to a host of a backend
+type HostStats struct {
+	Host string `json:"host"`
+	// InFlight is the number of requests waiting for a response
+	InFlight int `json:"in_flight"`
+	// Requests is the total number of completed requests
+	Requests int `json:"requests"`
+	// Failures is the total number of failed requests
+	Failures int `json:"failures"`
+	// SuccessRate is the ratio of successful requests in the window
+	SuccessRate float64 `json:"success_rate"`
+	// P99 is the 99th percentile of the latency in the window
+	P99 time.Duration `json:"p99"`
+	// Ejected is true when the health checks of the backend have ejected the host
+	Ejected bool `json:"ejected"`
+}
+
+var (
+	hostStatsTrackers   = map[string]*hostStatsTracker{}
+	hostStatsTrackersMu sync.RWMutex
+)
+
+// BackendHostStats returns the statistics of the hosts of every load balanced backend, by URL pattern,
+// so the operators and the metrics exporters can find the unhealthy instances
+func BackendHostStats() map[string][]HostStats {
+	hostStatsTrackersMu.RLock()
+	defer hostStatsTrackersMu.RUnlock()
+	stats := make(map[string][]HostStats, len(hostStatsTrackers))
+	for name, t := range hostStatsTrackers {
+		stats[name] = t.stats()
+	}
+	return stats
+}
+
+// newHostStatsLB wraps the load balancer, tracking the requests sent to every host. The backends sharing
+// the name share the statistics of their hosts
+func newHostStatsLB(name string, lb LoadBalancer, subscriber sd.Subscriber) LoadBalancer {
+	hostStatsTrackersMu.Lock()
+	t, ok := hostStatsTrackers[name]
+	if !ok {
+		t = &hostStatsTracker{hosts: map[string]*hostStatsWindow{}}
+		hostStatsTrackers[name] = t
+	}
+	if hc, ok := subscriber.(*sd.HealthCheckedSubscriber); ok {
+		t.healthChecked = hc
+	}
+	hostStatsTrackersMu.Unlock()
+	return hostStatsLB{lb, t}
+}
+
+type hostStatsLB struct {
+	LoadBalancer
+	tracker *hostStatsTracker
+}
+
+// Host implements the LoadBalancer interface
+func (b hostStatsLB) Host(r *Request) (string, error) {
+	host, err := b.LoadBalancer.Host(r)
+	if err == nil {
+		b.tracker.start(host)
+	}
+	return host, err
+}
+
+// Done implements the LoadBalancer interface
+func (b hostStatsLB) Done(host string, latency time.Duration, err error) {
+	b.tracker.done(host, latency, err == nil)
+	b.LoadBalancer.Done(host, latency, err)
+}
+
+type hostStatsTracker struct {
+	healthChecked *sd.HealthCheckedSubscriber
+
+	mu    sync.Mutex
+	hosts map[string]*hostStatsWindow
+}
+
+type hostStatsWindow struct {
+	inFlight  int
+	requests  int
+	failures  int
+	latencies []time.Duration
+	successes []bool
+	next      int
+}
+
+func (t *hostStatsTracker) start(host string) {
+	t.mu.Lock()
+	t.windowFor(host).inFlight++
+	t.mu.Unlock()
+}
+
+func (t *hostStatsTracker) done(host string, latency time.Duration, success bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w := t.windowFor(host)
+	if w.inFlight > 0 {
+		w.inFlight--
+	}
+	w.requests++
+	if !success {
+		w.failures++
+	}
+	if len(w.latencies) < HostStatsWindow {
+		w.latencies = append(w.latencies, latency)
+		w.successes = append(w.successes, success)
+		return
+	}
+	w.latencies[w.next] = latency
+	w.successes[w.next] = success
+	w.next = (w.next + 1) % len(w.latencies)
+}
+
+func (t *hostStatsTracker) windowFor(host string) *hostStatsWindow {
+	w, ok := t.hosts[host]
+	if !ok {
+		w = &hostStatsWindow{}
+		t.hosts[host] = w
+	}
+	return w
+}
+
+func (t *hostStatsTracker) stats() []HostStats {
+	t.mu.Lock()
+	stats := make([]HostStats, 0, len(t.hosts))
+	for host, w := range t.hosts {
+		s := HostStats{
+			Host:        host,
+			InFlight:    w.inFlight,
+			Requests:    w.requests,
+			Failures:    w.failures,
+			SuccessRate: 1,
+		}
+		if len(w.successes) > 0 {
+			succeeded := 0
+			for _, ok := range w.successes {
+				if ok {
+					succeeded++
+				}
+			}
+			s.SuccessRate = float64(succeeded) / float64(len(w.successes))
+
+			latencies := make([]time.Duration, len(w.latencies))
+			copy(latencies, w.latencies)
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			s.P99 = latencies[(len(latencies)*99-1)/100]
+		}
+		stats = append(stats, s)
+	}
+	t.mu.Unlock()
+
+	if t.healthChecked != nil {
+		for i := range stats {
+			stats[i].Ejected = t.healthChecked.Ejected(stats[i].Host)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return stats
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+func TestNewConfiguredLoadBalancedMiddleware_hostStats(t *testing.T) {
+	hc := sd.NewHealthCheckedSubscriber(sd.FixedSubscriber{"http://a", "http://b"}, sd.HealthCheckConfig{MaxFailures: 1})
+	mw, err := NewConfiguredLoadBalancedMiddleware(&config.Backend{URLPattern: "/host_stats"}, hc)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	defer func() {
+		hostStatsTrackersMu.Lock()
+		delete(hostStatsTrackers, "/host_stats")
+		hostStatsTrackersMu.Unlock()
+	}()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		if r.URL.Host == "b" {
+			hc.Report("http://b", false)
+			return nil, errors.New("supu")
+		}
+		close(entered)
+		<-release
+		return &Response{IsComplete: true}, nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p(context.Background(), &Request{Path: "/"})
+		close(done)
+	}()
+	<-entered
+	p(context.Background(), &Request{Path: "/"})
+
+	stats := BackendHostStats()["/host_stats"]
+	if len(stats) != 2 {
+		t.Errorf("unexpected stats: %v", stats)
+		return
+	}
+	if a := stats[0]; a.Host != "http://a" || a.InFlight != 1 || a.Requests != 0 || a.Ejected {
+		t.Errorf("unexpected stats: %+v", a)
+	}
+	if b := stats[1]; b.Host != "http://b" || b.InFlight != 0 || b.Requests != 1 || b.Failures != 1 ||
+		b.SuccessRate != 0 || !b.Ejected {
+		t.Errorf("unexpected stats: %+v", b)
+	}
+
+	close(release)
+	<-done
+	if a := BackendHostStats()["/host_stats"][0]; a.InFlight != 0 || a.Requests != 1 || a.SuccessRate != 1 {
+		t.Errorf("unexpected stats: %+v", a)
+	}
+}
+
+func TestHostStatsTracker(t *testing.T) {
+	defaultWindow := HostStatsWindow
+	HostStatsWindow = 100
+	defer func() { HostStatsWindow = defaultWindow }()
+
+	tracker := &hostStatsTracker{hosts: map[string]*hostStatsWindow{}}
+	for i := 1; i <= 150; i++ {
+		tracker.start("http://a")
+		tracker.done("http://a", time.Duration(i)*time.Millisecond, i > 100 || i%2 == 0)
+	}
+
+	stats := tracker.stats()
+	if len(stats) != 1 {
+		t.Errorf("unexpected stats: %v", stats)
+		return
+	}
+	s := stats[0]
+	if s.Requests != 150 || s.Failures != 50 || s.InFlight != 0 {
+		t.Errorf("unexpected counters: %+v", s)
+	}
+	// the window has the last 100 requests: 25 of them failed and the latencies go from 51 to 150 ms
+	if s.SuccessRate != 0.75 {
+		t.Errorf("unexpected success rate: %v", s.SuccessRate)
+	}
+	if s.P99 != 149*time.Millisecond {
+		t.Errorf("unexpected p99: %v", s.P99)
+	}
+}
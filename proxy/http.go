@@ -13,8 +13,14 @@ import (
 // is not a 200 nor a 201
 var ErrInvalidStatusCode = errors.New("Invalid status code")
 
+// ErrNotModified is the error returned by the http proxy when the backend answers a conditional
+// request with a 304 status code
+var ErrNotModified = errors.New("Not modified")
+
 var httpProxy = CustomHTTPProxyFactory(NewHTTPClient)
 
+const streamKey = "stream"
+
 // HTTPProxyFactory returns a BackendFactory. The Proxies it creates will use the received net/http.Client
 func HTTPProxyFactory(client *http.Client) BackendFactory {
 	return CustomHTTPProxyFactory(func(_ context.Context) *http.Client { return client })
@@ -34,9 +40,29 @@ func NewHTTPProxy(remote *config.Backend, clientFactory HTTPClientFactory, decod
 
 // NewHTTPProxyWithHTTPExecutor creates a http proxy with the injected configuration, HTTPRequestExecutor and Decoder
 func NewHTTPProxyWithHTTPExecutor(remote *config.Backend, requestExecutor HTTPRequestExecutor, dec encoding.Decoder) Proxy {
-	ef := NewEntityFormatter(remote.Target, remote.Whitelist, remote.Blacklist, remote.Group, remote.Mapping)
+	if remote.Encoding == encoding.NOOP {
+		return NewHTTPProxyDetailed(remote, requestExecutor, NoOpHTTPStatusHandler, StreamingHTTPResponseParser)
+	}
+	if isStreamingEnabled(remote) {
+		return NewHTTPProxyDetailed(remote, requestExecutor, DefaultHTTPStatusHandler, StreamingHTTPResponseParser)
+	}
+	ef, err := NewBackendEntityFormatter(remote)
+	if err != nil {
+		return newErrorProxy(err)
+	}
+	if gql, ok, err := GraphQLConfigGetter(remote); ok {
+		if err != nil {
+			return newErrorProxy(err)
+		}
+		p := NewHTTPProxyDetailed(remote, requestExecutor, NoOpHTTPStatusHandler, GraphQLResponseParser(ef))
+		return NewGraphQLMiddleware(gql)(p)
+	}
+	ef, sh, err := newStatusFormatting(remote, ef)
+	if err != nil {
+		return newErrorProxy(err)
+	}
 	rp := DefaultHTTPResponseParserFactory(HTTPResponseParserConfig{dec, ef})
-	return NewHTTPProxyDetailed(remote, requestExecutor, DefaultHTTPStatusHandler, rp)
+	return NewHTTPProxyDetailed(remote, requestExecutor, sh, rp)
 }
 
 // NewHTTPProxyDetailed creates a http proxy with the injected configuration, HTTPRequestExecutor, Decoder and HTTPResponseParser
@@ -47,8 +73,10 @@ func NewHTTPProxyDetailed(remote *config.Backend, requestExecutor HTTPRequestExe
 			return nil, err
 		}
 		requestToBakend.Header = request.Headers
+		addDeadlineHeader(ctx, requestToBakend)
+		addRequestIDHeader(ctx, requestToBakend)
 
-		resp, err := requestExecutor(ctx, requestToBakend)
+		resp, err := requestExecutor(withClientTrace(ctx), requestToBakend)
 		requestToBakend.Body.Close()
 		select {
 		case <-ctx.Done():
@@ -58,6 +86,7 @@ func NewHTTPProxyDetailed(remote *config.Backend, requestExecutor HTTPRequestExe
 		if err != nil {
 			return nil, err
 		}
+		recordStatusCode(ctx, resp.StatusCode)
 
 		resp, err = ch(ctx, resp)
 		if err != nil {
@@ -83,3 +112,17 @@ func NewRequestBuilderMiddleware(remote *config.Backend) Middleware {
 		}
 	}
 }
+
+// isStreamingEnabled checks if the backend response should be streamed instead of decoded. It is
+// enabled with the 'stream' flag of the proxy extra config and for the server-sent events backends
+func isStreamingEnabled(remote *config.Backend) bool {
+	if remote.Encoding == encoding.SSE {
+		return true
+	}
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	stream, ok := extra[streamKey].(bool)
+	return ok && stream
+}
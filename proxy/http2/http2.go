@@ -0,0 +1,123 @@
+// Package http2 provides a backend factory for the backends speaking HTTP/2, negotiated with ALPN over TLS
+// or sent with prior knowledge over the plaintext connections (h2c)
+package http2
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// Namespace is the key to look for the HTTP/2 options in the extra config of the backends
+const Namespace = "github.com/devopsfaith/krakend/proxy/http2"
+
+// Config is the HTTP/2 options set at the extra config of the backend
+type Config struct {
+	// PriorKnowledge sends the requests to the plaintext hosts as HTTP/2 without negotiating it (h2c)
+	PriorKnowledge bool `json:"prior_knowledge"`
+	// Disabled forces HTTP/1.1, even if the TLS hosts support HTTP/2
+	Disabled bool `json:"disabled"`
+}
+
+// ConfigGetter parses the HTTP/2 options from the extra config. The second value is false if the backend
+// does not declare them
+func ConfigGetter(extra config.ExtraConfig) (Config, bool) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false
+	}
+	return cfg, true
+}
+
+// BackendFactory returns a BackendFactory creating http proxies with the HTTP/2 clients of the backends
+// declaring the HTTP/2 options in their extra config and delegating the creation of the rest of them to
+// the next factory. The backends with the same options share the same client, so they share its connections
+func BackendFactory(next proxy.BackendFactory) proxy.BackendFactory {
+	clients := map[Config]*http.Client{}
+	mu := &sync.Mutex{}
+	return func(remote *config.Backend) proxy.Proxy {
+		cfg, ok := ConfigGetter(remote.ExtraConfig)
+		if !ok {
+			return next(remote)
+		}
+		mu.Lock()
+		client, ok := clients[cfg]
+		if !ok {
+			client = NewHTTPClient(cfg)
+			clients[cfg] = client
+		}
+		mu.Unlock()
+		return proxy.NewHTTPProxy(remote, func(_ context.Context) *http.Client { return client }, remote.Decoder)
+	}
+}
+
+// NewHTTPClient returns a client with the HTTP/2 options. By default, HTTP/2 is negotiated with the TLS hosts
+// and the plaintext ones are requested with HTTP/1.1
+func NewHTTPClient(cfg Config) *http.Client {
+	t := newTransport()
+	if cfg.Disabled {
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		return &http.Client{Transport: t}
+	}
+	http2.ConfigureTransport(t)
+	if !cfg.PriorKnowledge {
+		return &http.Client{Transport: t}
+	}
+	return &http.Client{Transport: priorKnowledgeTransport{
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+		tls: t,
+	}}
+}
+
+// newTransport returns a transport with the settings of the http.DefaultTransport
+func newTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   config.DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// priorKnowledgeTransport sends the requests to the plaintext hosts with the h2c transport and the rest
+// of them with the tls one
+type priorKnowledgeTransport struct {
+	h2c *http2.Transport
+	tls http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t priorKnowledgeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(r)
+	}
+	return t.tls.RoundTrip(r)
+}
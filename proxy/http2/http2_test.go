@@ -0,0 +1,80 @@
+package http2
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the backend should not declare the http2 options")
+	}
+	cfg, ok := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"prior_knowledge": true}})
+	if !ok || !cfg.PriorKnowledge || cfg.Disabled {
+		t.Errorf("unexpected config: %+v %v", cfg, ok)
+	}
+}
+
+func h2cServer() *httptest.Server {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"proto":"` + r.Proto + `"}`))
+	})
+	s := httptest.NewUnstartedServer(h2c.NewHandler(h, &http2.Server{}))
+	http2.ConfigureServer(s.Config, &http2.Server{})
+	s.Start()
+	return s
+}
+
+func TestBackendFactory(t *testing.T) {
+	s := h2cServer()
+	defer s.Close()
+	u, _ := url.Parse(s.URL)
+
+	next := func(_ *config.Backend) proxy.Proxy {
+		return func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+			return &proxy.Response{Data: map[string]interface{}{"proto": "next"}}, nil
+		}
+	}
+	bf := BackendFactory(next)
+
+	for _, tc := range []struct {
+		extra    config.ExtraConfig
+		expected string
+	}{
+		{config.ExtraConfig{}, "next"},
+		{config.ExtraConfig{Namespace: map[string]interface{}{}}, "HTTP/1.1"},
+		{config.ExtraConfig{Namespace: map[string]interface{}{"disabled": true}}, "HTTP/1.1"},
+		{config.ExtraConfig{Namespace: map[string]interface{}{"prior_knowledge": true}}, "HTTP/2.0"},
+	} {
+		remote := &config.Backend{Decoder: encoding.JSONDecoder, ExtraConfig: tc.extra}
+		resp, err := bf(remote)(context.Background(), &proxy.Request{Method: "GET", URL: u, Body: ioutil.NopCloser(strings.NewReader(""))})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if resp.Data["proto"] != tc.expected {
+			t.Errorf("%v: unexpected protocol: %v", tc.extra, resp.Data["proto"])
+		}
+	}
+}
+
+func TestNewHTTPClient_disabled(t *testing.T) {
+	c := NewHTTPClient(Config{Disabled: true})
+	tr, ok := c.Transport.(*http.Transport)
+	if !ok || tr.TLSNextProto == nil || len(tr.TLSNextProto) != 0 {
+		t.Errorf("the HTTP/2 upgrades should be disabled: %+v", c.Transport)
+	}
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// A Decompressor returns a reader decompressing the content of the received one
+type Decompressor func(io.Reader) (io.ReadCloser, error)
+
+var decompressors = map[string]Decompressor{
+	"gzip":    func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	"x-gzip":  func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	"deflate": func(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) },
+}
+
+// RegisterDecompressor registers the decompressor for the received content coding
+func RegisterDecompressor(name string, d Decompressor) {
+	decompressors[name] = d
+}
+
+// decompressedBody returns the body of the response decoded with the content codings declared
+// in its Content-Encoding header, so the decoders always receive the plain payload
+func decompressedBody(resp *http.Response) (io.ReadCloser, error) {
+	contentEncoding := resp.Header.Get("Content-Encoding")
+	if contentEncoding == "" {
+		return ioutil.NopCloser(resp.Body), nil
+	}
+
+	codings := strings.Split(contentEncoding, ",")
+	var body io.Reader = resp.Body
+	closers := []io.Closer{}
+	// the codings are listed in the order they were applied
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		if coding == "identity" || coding == "" {
+			continue
+		}
+		d, ok := decompressors[coding]
+		if !ok {
+			return nil, fmt.Errorf("unsupported content encoding: %s", coding)
+		}
+		rc, err := d(body)
+		if err != nil {
+			return nil, err
+		}
+		body = rc
+		closers = append(closers, rc)
+	}
+	return multiCloser{body, closers}, nil
+}
+
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m multiCloser) Close() error {
+	var err error
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		if cerr := m.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestDecompressedBody(t *testing.T) {
+	gz := new(bytes.Buffer)
+	gw := gzip.NewWriter(gz)
+	gw.Write([]byte(`{"supu":42}`))
+	gw.Close()
+
+	deflated := new(bytes.Buffer)
+	zw := zlib.NewWriter(deflated)
+	zw.Write(gz.Bytes())
+	zw.Close()
+
+	for _, tc := range []struct {
+		contentEncoding string
+		body            []byte
+	}{
+		{"", []byte(`{"supu":42}`)},
+		{"identity", []byte(`{"supu":42}`)},
+		{"gzip", gz.Bytes()},
+		{"gzip, deflate", deflated.Bytes()},
+	} {
+		resp := &http.Response{
+			Header: http.Header{"Content-Encoding": []string{tc.contentEncoding}},
+			Body:   ioutil.NopCloser(bytes.NewReader(tc.body)),
+		}
+		body, err := decompressedBody(resp)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.contentEncoding, err.Error())
+			continue
+		}
+		b, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.contentEncoding, err.Error())
+			continue
+		}
+		if string(b) != `{"supu":42}` {
+			t.Errorf("%s: unexpected body: %s", tc.contentEncoding, string(b))
+		}
+	}
+}
+
+func TestDecompressedBody_ko(t *testing.T) {
+	for _, contentEncoding := range []string{"unknown", "gzip"} {
+		resp := &http.Response{
+			Header: http.Header{"Content-Encoding": []string{contentEncoding}},
+			Body:   ioutil.NopCloser(bytes.NewReader([]byte("not compressed"))),
+		}
+		if _, err := decompressedBody(resp); err == nil {
+			t.Errorf("%s: error expected", contentEncoding)
+		}
+	}
+}
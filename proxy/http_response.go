@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/devopsfaith/krakend/encoding"
 )
@@ -29,15 +30,54 @@ type HTTPResponseParserFactory func(HTTPResponseParserConfig) HTTPResponseParser
 // DefaultHTTPResponseParserFactory is the default implementation of HTTPResponseParserFactory
 func DefaultHTTPResponseParserFactory(cfg HTTPResponseParserConfig) HTTPResponseParser {
 	return func(ctx context.Context, resp *http.Response) (*Response, error) {
+		_, span := tracer().Start(ctx, SpanDecode)
+		start := time.Now()
+		body, err := decompressedBody(resp)
+		if err != nil {
+			resp.Body.Close()
+			endSpan(span, err)
+			return nil, err
+		}
 		var data map[string]interface{}
-		err := cfg.Decoder(resp.Body, &data)
+		err = cfg.Decoder(body, &data)
+		body.Close()
 		resp.Body.Close()
+		decoding := time.Since(start)
+		decodeDuration.Observe(decoding.Seconds())
+		endSpan(span, err)
 		if err != nil {
+			recordDecoding(ctx, decoding, 0)
 			return nil, err
 		}
 
-		newResponse := Response{Data: data, IsComplete: true}
+		newResponse := Response{
+			Data:       data,
+			IsComplete: true,
+			Metadata: Metadata{
+				Headers:    resp.Header,
+				StatusCode: resp.StatusCode,
+			},
+		}
+		_, span = tracer().Start(ctx, SpanFormat)
+		start = time.Now()
 		newResponse = cfg.EntityFormatter.Format(newResponse)
+		recordDecoding(ctx, decoding, time.Since(start))
+		span.End()
 		return &newResponse, nil
 	}
 }
+
+// StreamingHTTPResponseParser is a HTTPResponseParser that does not decode nor format the body of the
+// response. The body is exposed through the Io field of the Response, so the router can stream it to the
+// client, and it is closed when the context is done
+func StreamingHTTPResponseParser(ctx context.Context, resp *http.Response) (*Response, error) {
+	return &Response{
+		Data:       map[string]interface{}{},
+		IsComplete: true,
+		Io:         NewReadCloserWrapper(ctx, resp.Body),
+		Metadata: Metadata{
+			Headers:    resp.Header,
+			StatusCode: resp.StatusCode,
+		},
+	}, nil
+}
@@ -10,9 +10,19 @@ type HTTPStatusHandler func(context.Context, *http.Response) (*http.Response, er
 
 // DefaultHTTPCodeHandler is the default implementation of HTTPStatusHandler
 func DefaultHTTPStatusHandler(ctx context.Context, resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return nil, ErrNotModified
+	}
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		return nil, ErrInvalidStatusCode
 	}
 
 	return resp, nil
 }
+
+// NoOpHTTPStatusHandler is a HTTPStatusHandler accepting all the status codes, so the responses can
+// be returned to the client untouched
+func NoOpHTTPStatusHandler(_ context.Context, resp *http.Response) (*http.Response, error) {
+	return resp, nil
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const statusName = "status"
+
+// statusFormatterConfig is the formatter configuration to apply to the responses with a given status
+type statusFormatterConfig struct {
+	Target    string            `json:"target"`
+	Whitelist []string          `json:"whitelist"`
+	Blacklist []string          `json:"blacklist"`
+	Group     string            `json:"group"`
+	Mapping   map[string]string `json:"mapping"`
+}
+
+// statusEntityFormatter selects the formatter to apply by the status code of the backend response
+type statusEntityFormatter struct {
+	byCode   map[int]EntityFormatter
+	byClass  map[int]EntityFormatter
+	fallback EntityFormatter
+}
+
+// Format implements the EntityFormatter interface
+func (s statusEntityFormatter) Format(entity Response) Response {
+	f, ok := s.get(entity.Metadata.StatusCode)
+	if !ok {
+		return s.fallback.Format(entity)
+	}
+	entity = f.Format(entity)
+	if entity.Metadata.StatusCode < 200 || entity.Metadata.StatusCode > 299 {
+		entity.IsComplete = false
+	}
+	return entity
+}
+
+func (s statusEntityFormatter) get(code int) (EntityFormatter, bool) {
+	if f, ok := s.byCode[code]; ok {
+		return f, true
+	}
+	f, ok := s.byClass[code/100]
+	return f, ok
+}
+
+// statusHandler accepts all the responses with a status declared in the status formatting config
+// and delegates the rest to the default status handler
+func (s statusEntityFormatter) statusHandler(ctx context.Context, resp *http.Response) (*http.Response, error) {
+	if _, ok := s.get(resp.StatusCode); ok {
+		return resp, nil
+	}
+	return DefaultHTTPStatusHandler(ctx, resp)
+}
+
+// newStatusFormatting wraps the received formatter with the formatters declared by status code
+// ("404") or by status class ("4xx") in the proxy extra config of the backend, and returns the
+// status handler accepting those statuses. Without that config, it returns the received formatter
+// and the DefaultHTTPStatusHandler
+func newStatusFormatting(remote *config.Backend, ef EntityFormatter) (EntityFormatter, HTTPStatusHandler, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return ef, DefaultHTTPStatusHandler, nil
+	}
+	cfg, ok := extra[statusName].(map[string]interface{})
+	if !ok {
+		return ef, DefaultHTTPStatusHandler, nil
+	}
+
+	sf := statusEntityFormatter{
+		byCode:   map[int]EntityFormatter{},
+		byClass:  map[int]EntityFormatter{},
+		fallback: ef,
+	}
+	for status, v := range cfg {
+		f, err := newStatusEntityFormatter(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid formatter for the status %s: %s", status, err.Error())
+		}
+		status = strings.ToLower(status)
+		if len(status) == 3 && strings.HasSuffix(status, "xx") {
+			class, err := strconv.Atoi(status[:1])
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid status class: %s", status)
+			}
+			sf.byClass[class] = f
+			continue
+		}
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid status code: %s", status)
+		}
+		sf.byCode[code] = f
+	}
+	return sf, sf.statusHandler, nil
+}
+
+func newStatusEntityFormatter(v interface{}) (EntityFormatter, error) {
+	extra, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not an object: %v", v)
+	}
+	b, err := json.Marshal(extra)
+	if err != nil {
+		return nil, err
+	}
+	cfg := statusFormatterConfig{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+	return NewBackendEntityFormatter(&config.Backend{
+		Target:      cfg.Target,
+		Whitelist:   cfg.Whitelist,
+		Blacklist:   cfg.Blacklist,
+		Group:       cfg.Group,
+		Mapping:     cfg.Mapping,
+		ExtraConfig: config.ExtraConfig{Namespace: extra},
+	})
+}
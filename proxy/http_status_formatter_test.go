@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+func TestNewHTTPProxy_statusFormatting(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			fmt.Fprintf(w, `{"supu": 42, "tupu": true}`)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error": {"status": 404, "detail": "not found", "trace": "xxx"}}`)
+		case "/teapot":
+			w.WriteHeader(http.StatusTeapot)
+			fmt.Fprintf(w, `{"reason": "I'm a teapot"}`)
+		default:
+			http.Error(w, "booom", http.StatusInternalServerError)
+		}
+	}))
+	defer backendServer.Close()
+
+	backend := &config.Backend{
+		Decoder:   encoding.JSONDecoder,
+		Whitelist: []string{"supu"},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"status": map[string]interface{}{
+					"4xx": map[string]interface{}{
+						"target":    "error",
+						"whitelist": []interface{}{"status", "detail"},
+						"mapping":   map[string]interface{}{"status": "code", "detail": "message"},
+					},
+					"418": map[string]interface{}{
+						"inject": map[string]interface{}{"code": 418.0},
+						"group":  "error",
+					},
+				},
+			},
+		},
+	}
+	p := HTTPProxyFactory(http.DefaultClient)(backend)
+
+	call := func(path string) (*Response, error) {
+		u, _ := url.Parse(backendServer.URL + path)
+		return p(context.Background(), &Request{Method: "GET", URL: u, Body: newDummyReadCloser("")})
+	}
+
+	resp, err := call("/ok")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(resp.Data) != 1 || !resp.IsComplete || resp.Metadata.StatusCode != http.StatusOK {
+		t.Errorf("unexpected response: %v", resp)
+	}
+
+	resp, err = call("/missing")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(resp.Data) != 2 || resp.IsComplete || resp.Metadata.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	if resp.Data["message"] != "not found" || fmt.Sprint(resp.Data["code"]) != "404" {
+		t.Errorf("unexpected response data: %v", resp.Data)
+	}
+
+	resp, err = call("/teapot")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	group, ok := resp.Data["error"].(map[string]interface{})
+	if !ok || len(group) != 2 || group["code"] != 418.0 || group["reason"] != "I'm a teapot" {
+		t.Errorf("unexpected response data: %v", resp.Data)
+	}
+
+	if _, err = call("/broken"); err != ErrInvalidStatusCode {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestNewStatusFormatting_ko(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"4xxx": map[string]interface{}{}},
+		{"axx": map[string]interface{}{}},
+		{"404": "not an object"},
+		{"404": map[string]interface{}{"whitelist": "not an array"}},
+	} {
+		backend := &config.Backend{
+			ExtraConfig: config.ExtraConfig{
+				Namespace: map[string]interface{}{"status": cfg},
+			},
+		}
+		if _, _, err := newStatusFormatting(backend, NewEntityFormatter("", nil, nil, "", nil)); err == nil {
+			t.Errorf("expecting an error with %v", cfg)
+		}
+	}
+}
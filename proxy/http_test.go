@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -297,6 +298,118 @@ func TestNewHTTPProxy_requestKo(t *testing.T) {
 	}
 }
 
+func TestNewHTTPProxy_stream(t *testing.T) {
+	body := "{\"id\":1}\n{\"id\":2}\n"
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprint(w, body)
+	}))
+	defer backendServer.Close()
+
+	rpURL, _ := url.Parse(backendServer.URL)
+	backend := config.Backend{
+		Decoder:     encoding.NDJSONDecoder,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"stream": true}},
+	}
+	request := Request{
+		Method: "GET",
+		Path:   "/",
+		URL:    rpURL,
+		Body:   newDummyReadCloser(""),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	result, err := HTTPProxyFactory(http.DefaultClient)(&backend)(ctx, &request)
+	if err != nil {
+		t.Errorf("The proxy returned an unexpected error: %s\n", err.Error())
+		return
+	}
+	if len(result.Data) != 0 || result.Io == nil {
+		t.Errorf("The proxy returned an unexpected result: %v\n", result)
+		return
+	}
+	if result.Metadata.Headers["Content-Type"][0] != "application/x-ndjson" {
+		t.Errorf("The proxy returned unexpected headers: %v\n", result.Metadata.Headers)
+	}
+	b, err := ioutil.ReadAll(result.Io)
+	if err != nil {
+		t.Errorf("Error reading the response body: %s\n", err.Error())
+		return
+	}
+	if string(b) != body {
+		t.Errorf("The proxy returned an unexpected body: %s\n", string(b))
+	}
+}
+
+func TestNewHTTPProxy_sse(t *testing.T) {
+	body := "event: update\ndata: 1\n\n"
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, body)
+	}))
+	defer backendServer.Close()
+
+	rpURL, _ := url.Parse(backendServer.URL)
+	backend := config.Backend{Encoding: encoding.SSE, Decoder: encoding.SSEDecoder}
+	request := Request{Method: "GET", Path: "/", URL: rpURL, Body: newDummyReadCloser("")}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	result, err := HTTPProxyFactory(http.DefaultClient)(&backend)(ctx, &request)
+	if err != nil {
+		t.Errorf("The proxy returned an unexpected error: %s\n", err.Error())
+		return
+	}
+	if result.Io == nil {
+		t.Errorf("The proxy returned an unexpected result: %v\n", result)
+		return
+	}
+	b, _ := ioutil.ReadAll(result.Io)
+	if string(b) != body {
+		t.Errorf("The proxy returned an unexpected body: %s\n", string(b))
+	}
+}
+
+func TestNewHTTPProxy_noop(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "not found")
+	}))
+	defer backendServer.Close()
+
+	rpURL, _ := url.Parse(backendServer.URL)
+	backend := config.Backend{
+		Encoding: encoding.NOOP,
+		Decoder:  encoding.NoOpDecoder,
+	}
+	request := Request{
+		Method: "GET",
+		Path:   "/",
+		URL:    rpURL,
+		Body:   newDummyReadCloser(""),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	result, err := HTTPProxyFactory(http.DefaultClient)(&backend)(ctx, &request)
+	if err != nil {
+		t.Errorf("The proxy returned an unexpected error: %s\n", err.Error())
+		return
+	}
+	if result.Metadata.StatusCode != http.StatusNotFound {
+		t.Errorf("The proxy returned an unexpected status code: %d\n", result.Metadata.StatusCode)
+	}
+	if result.Metadata.Headers["Content-Type"][0] != "application/pdf" {
+		t.Errorf("The proxy returned unexpected headers: %v\n", result.Metadata.Headers)
+	}
+	b, _ := ioutil.ReadAll(result.Io)
+	if string(b) != "not found" {
+		t.Errorf("The proxy returned an unexpected body: %s\n", string(b))
+	}
+}
+
 func TestNewRequestBuilderMiddleware_ok(t *testing.T) {
 	expected := errors.New("error to be propagated")
 	expectedMethod := "GET"
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	injectName   = "inject"
+	defaultsName = "defaults"
+)
+
+// injectionContext is the data available to the templated values
+type injectionContext struct {
+	Env map[string]string
+}
+
+// newInjectFormatter creates a formatter setting the configured values at their dotted paths,
+// overriding the existing ones
+func newInjectFormatter(cfg interface{}) (EntityFormatter, error) {
+	return newFieldSetter(cfg, true)
+}
+
+// newDefaultsFormatter creates a formatter setting the configured values at their dotted paths
+// only when they are missing
+func newDefaultsFormatter(cfg interface{}) (EntityFormatter, error) {
+	return newFieldSetter(cfg, false)
+}
+
+func newFieldSetter(cfg interface{}, override bool) (EntityFormatter, error) {
+	values, ok := cfg.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid field injection config: %v", cfg)
+	}
+	ctx := injectionContext{Env: map[string]string{}}
+	for _, kv := range os.Environ() {
+		if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+			ctx.Env[parts[0]] = parts[1]
+		}
+	}
+
+	type field struct {
+		path  []string
+		value interface{}
+	}
+	fields := make([]field, 0, len(values))
+	for k, v := range values {
+		if text, ok := v.(string); ok && strings.Contains(text, "{{") {
+			tmpl, err := template.New(k).Option("missingkey=zero").Parse(text)
+			if err != nil {
+				return nil, err
+			}
+			buf := new(bytes.Buffer)
+			if err := tmpl.Execute(buf, ctx); err != nil {
+				return nil, err
+			}
+			v = buf.String()
+		}
+		fields = append(fields, field{strings.Split(k, "."), v})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return strings.Join(fields[i].path, ".") < strings.Join(fields[j].path, ".")
+	})
+
+	return EntityFormatterFunc(func(entity Response) Response {
+		if entity.Data == nil {
+			entity.Data = map[string]interface{}{}
+		}
+		for _, f := range fields {
+			if !override && hasPath(entity.Data, f.path) {
+				continue
+			}
+			setAtPath(entity.Data, f.path, f.value)
+		}
+		return entity
+	}), nil
+}
+
+func hasPath(data map[string]interface{}, path []string) bool {
+	var v interface{} = data
+	for _, k := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if v, ok = m[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
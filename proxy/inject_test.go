@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestInjectAndDefaultsFormatters(t *testing.T) {
+	os.Setenv("KRAKEND_TEST_REGION", "eu-west-1")
+	defer os.Unsetenv("KRAKEND_TEST_REGION")
+
+	remote := &config.Backend{
+		Whitelist: []string{"id", "meta", "name"},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"inject": map[string]interface{}{
+					"api_version": "v2",
+					"meta.region": "{{ .Env.KRAKEND_TEST_REGION }}",
+					"meta.unset":  "{{ .Env.KRAKEND_TEST_UNKNOWN }}",
+					"id":          42.0,
+				},
+				"defaults": map[string]interface{}{
+					"name":         "anonymous",
+					"meta.page":    1.0,
+					"meta.deleted": false,
+				},
+			},
+		},
+	}
+	f, err := NewBackendEntityFormatter(remote)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := f.Format(Response{
+		Data: map[string]interface{}{
+			"id":     1,
+			"meta":   map[string]interface{}{"page": 3},
+			"hidden": true,
+		},
+		IsComplete: true,
+	})
+	if len(result.Data) != 4 {
+		t.Errorf("unexpected result: %v", result.Data)
+	}
+	if result.Data["id"] != 42.0 || result.Data["api_version"] != "v2" || result.Data["name"] != "anonymous" {
+		t.Errorf("unexpected result: %v", result.Data)
+	}
+	meta := result.Data["meta"].(map[string]interface{})
+	if len(meta) != 4 || meta["region"] != "eu-west-1" || meta["unset"] != "" || meta["page"] != 3 || meta["deleted"] != false {
+		t.Errorf("unexpected meta: %v", meta)
+	}
+}
+
+func TestInjectFormatter_ko(t *testing.T) {
+	if _, err := newInjectFormatter([]interface{}{}); err == nil {
+		t.Error("expecting an error")
+	}
+	if _, err := newDefaultsFormatter(map[string]interface{}{"a": "{{ .Env"}); err == nil {
+		t.Error("expecting an error")
+	}
+}
@@ -0,0 +1,82 @@
+// Package jmespath provides an entity formatter applying JMESPath expressions to the backend responses
+package jmespath
+
+import (
+	"encoding/json"
+	"errors"
+
+	jp "github.com/jmespath/go-jmespath"
+
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// Name is the key of the formatter in the proxy extra config of the backends
+const Name = "jmespath"
+
+// ErrInvalidExpression is the error returned when the configured expression is not a string
+var ErrInvalidExpression = errors.New("the jmespath expression must be a string")
+
+// Register registers the JMESPath entity formatter factory
+func Register() error {
+	return proxy.RegisterEntityFormatter(Name, EntityFormatterFactory)
+}
+
+// EntityFormatterFactory creates a JMESPath entity formatter with the expression received as config
+func EntityFormatterFactory(cfg interface{}) (proxy.EntityFormatter, error) {
+	expr, ok := cfg.(string)
+	if !ok {
+		return nil, ErrInvalidExpression
+	}
+	return New(expr)
+}
+
+// New creates an entity formatter replacing the response data with the result of applying the
+// received expression over it. Results that are not objects are placed under the 'collection' key
+func New(expr string) (proxy.EntityFormatter, error) {
+	compiled, err := jp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return proxy.EntityFormatterFunc(func(entity proxy.Response) proxy.Response {
+		result, err := compiled.Search(normalize(entity.Data))
+		if err != nil {
+			entity.Data = map[string]interface{}{}
+			entity.IsComplete = false
+			return entity
+		}
+		switch v := result.(type) {
+		case map[string]interface{}:
+			entity.Data = v
+		case nil:
+			entity.Data = map[string]interface{}{}
+		default:
+			entity.Data = map[string]interface{}{"collection": v}
+		}
+		return entity
+	}), nil
+}
+
+// normalize replaces the json.Number values set by the decoders with float64 values, so they
+// can be compared by the JMESPath functions and filters
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			res[k] = normalize(e)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for i, e := range t {
+			res[i] = normalize(e)
+		}
+		return res
+	case json.Number:
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t.String()
+	}
+	return v
+}
@@ -0,0 +1,67 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestNew_projection(t *testing.T) {
+	f, err := New("items[?price > `10`].{id: id, p: price}")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := f.Format(proxy.Response{
+		Data: map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"id": 1, "price": json.Number("5")},
+				map[string]interface{}{"id": 2, "price": json.Number("15")},
+				map[string]interface{}{"id": 3, "price": json.Number("25")},
+			},
+		},
+		IsComplete: true,
+	})
+	if !result.IsComplete {
+		t.Errorf("unexpected result: %v", result)
+	}
+	collection, ok := result.Data["collection"].([]interface{})
+	if !ok || len(collection) != 2 {
+		t.Errorf("unexpected result: %v", result.Data)
+		return
+	}
+	for i, id := range []int{2, 3} {
+		item := collection[i].(map[string]interface{})
+		if item["id"] != id {
+			t.Errorf("unexpected item %d: %v", i, item)
+		}
+	}
+}
+
+func TestNew_object(t *testing.T) {
+	f, err := New("{name: user.name, total: length(orders)}")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := f.Format(proxy.Response{
+		Data: map[string]interface{}{
+			"user":   map[string]interface{}{"name": "supu"},
+			"orders": []interface{}{1, 2, 3},
+		},
+		IsComplete: true,
+	})
+	if len(result.Data) != 2 || result.Data["name"] != "supu" || result.Data["total"] != 3.0 {
+		t.Errorf("unexpected result: %v", result.Data)
+	}
+}
+
+func TestNew_ko(t *testing.T) {
+	if _, err := New("items[?"); err == nil {
+		t.Error("expecting an error")
+	}
+	if _, err := EntityFormatterFactory(42); err != ErrInvalidExpression {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"errors"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+const (
+	loadBalancerKey = "load_balancer"
+
+	// RoundRobinStrategy selects the hosts in order
+	RoundRobinStrategy = "round_robin"
+	// RandomStrategy selects the hosts randomly
+	RandomStrategy = "random"
+	// WeightedRoundRobinStrategy selects the hosts in order, proportionally to their weights
+	WeightedRoundRobinStrategy = "weighted_round_robin"
+	// LeastConnectionsStrategy selects the host with less requests in flight
+	LeastConnectionsStrategy = "least_connections"
+	// EWMAStrategy selects the host with the lowest latency, measured as an exponentially weighted
+	// moving average
+	EWMAStrategy = "ewma"
+	// ConsistentHashStrategy selects the host with the hash of a header or a param of the request
+	ConsistentHashStrategy = "consistent_hash"
+)
+
+// ErrUnknownLoadBalancer is the error returned when the load balancing strategy is not registered
+var ErrUnknownLoadBalancer = errors.New("unknown load balancing strategy")
+
+// LoadBalancer selects the host for every request to the backend. It is notified of the result of every
+// request, so it can track the load and the latency of the hosts
+type LoadBalancer interface {
+	// Host returns the host to send the request to
+	Host(*Request) (string, error)
+	// Done reports the latency and the error of the request sent to the host
+	Done(host string, latency time.Duration, err error)
+}
+
+// LoadBalancerFactory creates a LoadBalancer over the received subscriber with the load balancer options
+// of the backend
+type LoadBalancerFactory func(subscriber sd.Subscriber, cfg map[string]interface{}) (LoadBalancer, error)
+
+var loadBalancerFactories = map[string]LoadBalancerFactory{
+	RoundRobinStrategy: func(s sd.Subscriber, _ map[string]interface{}) (LoadBalancer, error) {
+		return sdBalancer{sd.NewRoundRobinLB(s)}, nil
+	},
+	RandomStrategy: func(s sd.Subscriber, _ map[string]interface{}) (LoadBalancer, error) {
+		return sdBalancer{sd.NewRandomLB(s, time.Now().UnixNano())}, nil
+	},
+	WeightedRoundRobinStrategy: newWeightedRoundRobinLB,
+	LeastConnectionsStrategy:   newLeastConnectionsLB,
+	EWMAStrategy:               newEWMALB,
+	ConsistentHashStrategy:     newConsistentHashLB,
+}
+
+// RegisterLoadBalancer registers the load balancer factory with the given strategy name
+func RegisterLoadBalancer(name string, f LoadBalancerFactory) error {
+	loadBalancerFactories[name] = f
+	return nil
+}
+
+// NewConfiguredLoadBalancedMiddleware creates proxy middleware adding the load balancer selected in the
+// 'load_balancer' option of the backend proxy extra config over the received subscriber. The option can
+// be the name of the strategy or an object with the 'strategy' and its options. By default, it uses a
+// round robin balancer. The statistics of the hosts are available through BackendHostStats
+func NewConfiguredLoadBalancedMiddleware(remote *config.Backend, subscriber sd.Subscriber) (Middleware, error) {
+	strategy := RoundRobinStrategy
+	cfg := map[string]interface{}{}
+	if extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{}); ok {
+		switch v := extra[loadBalancerKey].(type) {
+		case string:
+			strategy = v
+		case map[string]interface{}:
+			cfg = v
+			if s, ok := v["strategy"].(string); ok {
+				strategy = s
+			}
+		}
+	}
+
+	f, ok := loadBalancerFactories[strategy]
+	if !ok {
+		return nil, ErrUnknownLoadBalancer
+	}
+	lb, err := f(subscriber, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewLoadBalancedMiddleware(newHostStatsLB(remote.URLPattern, lb, subscriber)), nil
+}
+
+// sdBalancer adapts the balancers of the sd package to the LoadBalancer interface
+type sdBalancer struct {
+	sd.Balancer
+}
+
+// Host implements the LoadBalancer interface
+func (b sdBalancer) Host(_ *Request) (string, error) { return b.Balancer.Host() }
+
+// Done implements the LoadBalancer interface
+func (b sdBalancer) Done(_ string, _ time.Duration, _ error) {}
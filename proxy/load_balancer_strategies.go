@@ -0,0 +1,333 @@
+package proxy
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/sd"
+)
+
+const (
+	defaultEWMADecay    = 10 * time.Second
+	defaultEWMAPenalty  = time.Second
+	defaultHashReplicas = 100
+)
+
+// ErrNoHashKey is the error returned when the consistent hash balancer has no header nor param to hash
+var ErrNoHashKey = errors.New("the consistent hash balancer requires a header or a param as key")
+
+// newWeightedRoundRobinLB creates a smooth weighted round robin balancer with the 'weights' of the hosts
+// (1 by default), so the requests to every host are interleaved instead of sent in bursts
+func newWeightedRoundRobinLB(subscriber sd.Subscriber, cfg map[string]interface{}) (LoadBalancer, error) {
+	weights := map[string]int{}
+	if v, ok := cfg["weights"].(map[string]interface{}); ok {
+		for host, w := range v {
+			if weight, ok := w.(float64); ok {
+				weights[host] = int(weight)
+			}
+		}
+	}
+	return &weightedRoundRobinLB{subscriber: subscriber, weights: weights, current: map[string]int{}}, nil
+}
+
+type weightedRoundRobinLB struct {
+	subscriber sd.Subscriber
+	weights    map[string]int
+
+	mu      sync.Mutex
+	current map[string]int
+}
+
+// Host implements the LoadBalancer interface
+func (lb *weightedRoundRobinLB) Host(_ *Request) (string, error) {
+	hosts, err := lb.subscriber.Hosts()
+	if err != nil {
+		return "", err
+	}
+	if len(hosts) == 0 {
+		return "", sd.ErrNoHosts
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if len(lb.current) > len(hosts) {
+		// some hosts are gone
+		lb.current = map[string]int{}
+	}
+	total := 0
+	best := ""
+	for _, h := range hosts {
+		w, ok := lb.weights[h]
+		if !ok {
+			w = 1
+		}
+		lb.current[h] += w
+		total += w
+		if best == "" || lb.current[h] > lb.current[best] {
+			best = h
+		}
+	}
+	lb.current[best] -= total
+	return best, nil
+}
+
+// Done implements the LoadBalancer interface
+func (lb *weightedRoundRobinLB) Done(_ string, _ time.Duration, _ error) {}
+
+// newLeastConnectionsLB creates a balancer selecting the host with less requests in flight. The ties
+// are solved in round robin
+func newLeastConnectionsLB(subscriber sd.Subscriber, _ map[string]interface{}) (LoadBalancer, error) {
+	return &leastConnectionsLB{subscriber: subscriber, inflight: map[string]int{}}, nil
+}
+
+type leastConnectionsLB struct {
+	subscriber sd.Subscriber
+
+	mu       sync.Mutex
+	inflight map[string]int
+	counter  int
+}
+
+// Host implements the LoadBalancer interface
+func (lb *leastConnectionsLB) Host(_ *Request) (string, error) {
+	hosts, err := lb.subscriber.Hosts()
+	if err != nil {
+		return "", err
+	}
+	if len(hosts) == 0 {
+		return "", sd.ErrNoHosts
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.counter++
+	best := ""
+	for i := range hosts {
+		h := hosts[(lb.counter+i)%len(hosts)]
+		if best == "" || lb.inflight[h] < lb.inflight[best] {
+			best = h
+		}
+	}
+	lb.inflight[best]++
+	return best, nil
+}
+
+// Done implements the LoadBalancer interface
+func (lb *leastConnectionsLB) Done(host string, _ time.Duration, _ error) {
+	lb.mu.Lock()
+	lb.inflight[host]--
+	if lb.inflight[host] <= 0 {
+		delete(lb.inflight, host)
+	}
+	lb.mu.Unlock()
+}
+
+// newEWMALB creates a balancer selecting the best of two random hosts (power of two choices), using as
+// cost their latency, measured as an exponentially weighted moving average with the 'decay' period,
+// times their requests in flight. The failed requests add the 'penalty' to their latency
+func newEWMALB(subscriber sd.Subscriber, cfg map[string]interface{}) (LoadBalancer, error) {
+	lb := &ewmaLB{
+		subscriber: subscriber,
+		decay:      defaultEWMADecay,
+		penalty:    defaultEWMAPenalty,
+		stats:      map[string]*ewmaStats{},
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:        time.Now,
+	}
+	for name, d := range map[string]*time.Duration{"decay": &lb.decay, "penalty": &lb.penalty} {
+		v, ok := cfg[name].(string)
+		if !ok {
+			continue
+		}
+		var err error
+		if *d, err = time.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+	return lb, nil
+}
+
+type ewmaLB struct {
+	subscriber sd.Subscriber
+	decay      time.Duration
+	penalty    time.Duration
+	now        func() time.Time
+
+	mu    sync.Mutex
+	stats map[string]*ewmaStats
+	rnd   *rand.Rand
+}
+
+type ewmaStats struct {
+	latency  float64
+	last     time.Time
+	inflight int
+}
+
+func (s *ewmaStats) cost() float64 {
+	return s.latency * float64(s.inflight+1)
+}
+
+// Host implements the LoadBalancer interface
+func (lb *ewmaLB) Host(_ *Request) (string, error) {
+	hosts, err := lb.subscriber.Hosts()
+	if err != nil {
+		return "", err
+	}
+	if len(hosts) == 0 {
+		return "", sd.ErrNoHosts
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	best := hosts[0]
+	if len(hosts) > 1 {
+		i := lb.rnd.Intn(len(hosts))
+		j := lb.rnd.Intn(len(hosts) - 1)
+		if j >= i {
+			j++
+		}
+		best = hosts[i]
+		if lb.statsFor(hosts[j]).cost() < lb.statsFor(best).cost() {
+			best = hosts[j]
+		}
+	}
+	lb.statsFor(best).inflight++
+	return best, nil
+}
+
+// Done implements the LoadBalancer interface
+func (lb *ewmaLB) Done(host string, latency time.Duration, err error) {
+	if err != nil {
+		latency += lb.penalty
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	s := lb.statsFor(host)
+	s.inflight--
+	now := lb.now()
+	if s.last.IsZero() {
+		s.latency = float64(latency)
+	} else {
+		w := math.Exp(-float64(now.Sub(s.last)) / float64(lb.decay))
+		s.latency = s.latency*w + float64(latency)*(1-w)
+	}
+	s.last = now
+}
+
+func (lb *ewmaLB) statsFor(host string) *ewmaStats {
+	s, ok := lb.stats[host]
+	if !ok {
+		s = &ewmaStats{}
+		lb.stats[host] = s
+	}
+	return s
+}
+
+// newConsistentHashLB creates a balancer selecting the host with a consistent hash of the 'header' or
+// the 'param' of the request declared in the 'key' option, so the requests with the same key reach the
+// same host while it is available. The requests without the key are balanced in round robin
+func newConsistentHashLB(subscriber sd.Subscriber, cfg map[string]interface{}) (LoadBalancer, error) {
+	key, _ := cfg["key"].(map[string]interface{})
+	var keyF func(*Request) string
+	if name, ok := key["header"].(string); ok {
+		name = http.CanonicalHeaderKey(name)
+		keyF = func(r *Request) string {
+			if v := r.Headers[name]; len(v) > 0 {
+				return v[0]
+			}
+			return ""
+		}
+	} else if name, ok := key["param"].(string); ok {
+		name = strings.Title(name)
+		keyF = func(r *Request) string { return r.Params[name] }
+	} else {
+		return nil, ErrNoHashKey
+	}
+
+	replicas := defaultHashReplicas
+	if v, ok := cfg["replicas"].(float64); ok && v > 0 {
+		replicas = int(v)
+	}
+
+	return &consistentHashLB{
+		subscriber: subscriber,
+		key:        keyF,
+		replicas:   replicas,
+		fallback:   sd.NewRoundRobinLB(subscriber),
+	}, nil
+}
+
+type consistentHashLB struct {
+	subscriber sd.Subscriber
+	key        func(*Request) string
+	replicas   int
+	fallback   sd.Balancer
+
+	mu       sync.Mutex
+	hostsKey string
+	ring     []uint32
+	owners   map[uint32]string
+}
+
+// Host implements the LoadBalancer interface
+func (lb *consistentHashLB) Host(r *Request) (string, error) {
+	key := lb.key(r)
+	if key == "" {
+		return lb.fallback.Host()
+	}
+	hosts, err := lb.subscriber.Hosts()
+	if err != nil {
+		return "", err
+	}
+	if len(hosts) == 0 {
+		return "", sd.ErrNoHosts
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if hostsKey := strings.Join(hosts, "\n"); hostsKey != lb.hostsKey {
+		lb.build(hosts)
+		lb.hostsKey = hostsKey
+	}
+	h := hashKey(key)
+	i := sort.Search(len(lb.ring), func(i int) bool { return lb.ring[i] >= h })
+	if i == len(lb.ring) {
+		i = 0
+	}
+	return lb.owners[lb.ring[i]], nil
+}
+
+// Done implements the LoadBalancer interface
+func (lb *consistentHashLB) Done(_ string, _ time.Duration, _ error) {}
+
+func (lb *consistentHashLB) build(hosts []string) {
+	lb.ring = make([]uint32, 0, len(hosts)*lb.replicas)
+	lb.owners = make(map[uint32]string, len(hosts)*lb.replicas)
+	for _, host := range hosts {
+		for i := 0; i < lb.replicas; i++ {
+			h := hashKey(strconv.Itoa(i) + host)
+			if _, ok := lb.owners[h]; ok {
+				continue
+			}
+			lb.owners[h] = host
+			lb.ring = append(lb.ring, h)
+		}
+	}
+	sort.Slice(lb.ring, func(i, j int) bool { return lb.ring[i] < lb.ring[j] })
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/sd"
+)
+
+func TestWeightedRoundRobinLB(t *testing.T) {
+	lb, _ := newWeightedRoundRobinLB(sd.FixedSubscriber{"a", "b", "c"}, map[string]interface{}{
+		"weights": map[string]interface{}{"a": 5.0, "b": 1.0},
+	})
+	sequence := ""
+	for i := 0; i < 7; i++ {
+		h, err := lb.Host(&Request{})
+		if err != nil {
+			t.Error("unexpected error:", err.Error())
+			return
+		}
+		sequence += h
+	}
+	if sequence != "aabacaa" {
+		t.Errorf("unexpected sequence: %s", sequence)
+	}
+}
+
+func TestLeastConnectionsLB(t *testing.T) {
+	lb, _ := newLeastConnectionsLB(sd.FixedSubscriber{"a", "b"}, nil)
+	first, _ := lb.Host(&Request{})
+	second, _ := lb.Host(&Request{})
+	if first == second {
+		t.Errorf("the hosts were not balanced: %s %s", first, second)
+	}
+	lb.Done(first, time.Millisecond, nil)
+	for i := 0; i < 3; i++ {
+		h, _ := lb.Host(&Request{})
+		if i == 0 && h != first {
+			t.Errorf("unexpected host: %s", h)
+		}
+		lb.Done(h, time.Millisecond, nil)
+	}
+}
+
+func TestEWMALB(t *testing.T) {
+	lbi, err := newEWMALB(sd.FixedSubscriber{"fast", "slow"}, map[string]interface{}{"decay": "1s", "penalty": "1s"})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	lb := lbi.(*ewmaLB)
+	now := time.Now()
+	lb.now = func() time.Time { return now }
+
+	lb.statsFor("fast").inflight++
+	lb.Done("fast", 10*time.Millisecond, nil)
+	lb.statsFor("slow").inflight++
+	lb.Done("slow", 10*time.Millisecond, errors.New("supu"))
+
+	for i := 0; i < 10; i++ {
+		h, _ := lb.Host(&Request{})
+		if h != "fast" {
+			t.Errorf("#%d: unexpected host: %s", i, h)
+		}
+		lb.Done(h, 10*time.Millisecond, nil)
+	}
+
+	now = now.Add(time.Second)
+	lb.statsFor("fast").inflight++
+	lb.Done("fast", 110*time.Millisecond, nil)
+	if latency := time.Duration(lb.statsFor("fast").latency); latency < 70*time.Millisecond || latency > 80*time.Millisecond {
+		t.Errorf("unexpected latency: %v", latency)
+	}
+
+	if _, err := newEWMALB(sd.FixedSubscriber{}, map[string]interface{}{"decay": "abc"}); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestConsistentHashLB(t *testing.T) {
+	hosts := sd.FixedSubscriber{"a", "b", "c", "d"}
+	lb, err := newConsistentHashLB(hosts, map[string]interface{}{"key": map[string]interface{}{"header": "x-user"}})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+
+	assigned := map[string]string{}
+	used := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		user := fmt.Sprintf("user%d", i)
+		h, _ := lb.Host(&Request{Headers: map[string][]string{"X-User": {user}}})
+		assigned[user] = h
+		used[h] = true
+	}
+	if len(used) != len(hosts) {
+		t.Errorf("unexpected distribution: %v", used)
+	}
+	for user, host := range assigned {
+		if h, _ := lb.Host(&Request{Headers: map[string][]string{"X-User": {user}}}); h != host {
+			t.Errorf("the user %s was moved from %s to %s", user, host, h)
+		}
+	}
+
+	// removing a host only moves its keys
+	chLB := lb.(*consistentHashLB)
+	chLB.subscriber = sd.FixedSubscriber{"a", "b", "c"}
+	for user, host := range assigned {
+		h, _ := lb.Host(&Request{Headers: map[string][]string{"X-User": {user}}})
+		if host != "d" && h != host {
+			t.Errorf("the user %s was moved from %s to %s", user, host, h)
+		}
+	}
+
+	// the requests without the key are balanced
+	first, _ := lb.Host(&Request{})
+	second, _ := lb.Host(&Request{})
+	if first == second {
+		t.Errorf("the requests without key were not balanced: %s %s", first, second)
+	}
+}
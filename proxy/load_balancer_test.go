@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+func TestNewConfiguredLoadBalancedMiddleware(t *testing.T) {
+	subscriber := sd.FixedSubscriber{"http://127.0.0.1:8080"}
+	for i, extra := range []config.ExtraConfig{
+		{},
+		{Namespace: map[string]interface{}{loadBalancerKey: RandomStrategy}},
+		{Namespace: map[string]interface{}{loadBalancerKey: map[string]interface{}{"strategy": LeastConnectionsStrategy}}},
+		{Namespace: map[string]interface{}{loadBalancerKey: EWMAStrategy}},
+		{Namespace: map[string]interface{}{loadBalancerKey: WeightedRoundRobinStrategy}},
+		{Namespace: map[string]interface{}{loadBalancerKey: map[string]interface{}{
+			"strategy": ConsistentHashStrategy,
+			"key":      map[string]interface{}{"param": "id"},
+		}}},
+	} {
+		mw, err := NewConfiguredLoadBalancedMiddleware(&config.Backend{ExtraConfig: extra}, subscriber)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %s", i, err.Error())
+			continue
+		}
+		testLoadBalancedMw(t, mw)
+	}
+}
+
+func TestNewConfiguredLoadBalancedMiddleware_koConfig(t *testing.T) {
+	for i, tc := range []struct {
+		cfg interface{}
+		err error
+	}{
+		{cfg: "unknown", err: ErrUnknownLoadBalancer},
+		{cfg: map[string]interface{}{"strategy": ConsistentHashStrategy}, err: ErrNoHashKey},
+	} {
+		_, err := NewConfiguredLoadBalancedMiddleware(&config.Backend{
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{loadBalancerKey: tc.cfg}},
+		}, sd.FixedSubscriber{})
+		if err != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestRegisterLoadBalancer(t *testing.T) {
+	RegisterLoadBalancer("custom", func(s sd.Subscriber, _ map[string]interface{}) (LoadBalancer, error) {
+		return sdBalancer{sd.NewRoundRobinLB(s)}, nil
+	})
+	defer delete(loadBalancerFactories, "custom")
+
+	mw, err := NewConfiguredLoadBalancedMiddleware(&config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{loadBalancerKey: "custom"}},
+	}, sd.FixedSubscriber{"http://127.0.0.1:8080"})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	testLoadBalancedMw(t, mw)
+}
+
+type recordingLB struct {
+	host    string
+	latency time.Duration
+	err     error
+}
+
+func (r *recordingLB) Host(_ *Request) (string, error) { return r.host, nil }
+
+func (r *recordingLB) Done(host string, latency time.Duration, err error) {
+	r.host, r.latency, r.err = host, latency, err
+}
+
+func TestNewLoadBalancedMiddleware_done(t *testing.T) {
+	expectedErr := errors.New("supu")
+	lb := &recordingLB{host: "http://127.0.0.1:8080"}
+	p := NewLoadBalancedMiddleware(lb)(func(_ context.Context, _ *Request) (*Response, error) {
+		time.Sleep(5 * time.Millisecond)
+		return nil, expectedErr
+	})
+	if _, err := p(context.Background(), &Request{Path: "/"}); err != expectedErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if lb.err != expectedErr || lb.latency < 5*time.Millisecond {
+		t.Errorf("the result was not reported: %v %v", lb.latency, lb.err)
+	}
+}
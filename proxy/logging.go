@@ -7,8 +7,13 @@ import (
 	"github.com/devopsfaith/krakend/logging"
 )
 
-// NewLoggingMiddleware creates proxy middleware for logging requests and responses
+// NewLoggingMiddleware creates proxy middleware for logging requests and responses. The structured loggers
+// get the name of the backend, the duration of the calls and the ID of the request as fields, so their messages
+// can be sampled and correlated
 func NewLoggingMiddleware(logger logging.Logger, name string) Middleware {
+	if sl, ok := logger.(logging.StructuredLogger); ok {
+		return newStructuredLoggingMiddleware(sl.With("backend", name))
+	}
 	return func(next ...Proxy) Proxy {
 		if len(next) > 1 {
 			panic(ErrTooManyProxies)
@@ -33,3 +38,33 @@ func NewLoggingMiddleware(logger logging.Logger, name string) Middleware {
 		}
 	}
 }
+
+func newStructuredLoggingMiddleware(logger logging.StructuredLogger) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			logger := logger
+			if id, ok := RequestIDFromContext(ctx); ok {
+				logger = logger.With("request_id", id)
+			}
+			begin := time.Now()
+			logger.Log(logging.LEVEL_DEBUG, "calling backend", "method", request.Method, "path", request.Path)
+
+			result, err := next[0](ctx, request)
+
+			duration := time.Since(begin)
+			if err != nil {
+				logger.Log(logging.LEVEL_WARNING, "call to backend failed", "duration", duration, "error", err)
+				return result, err
+			}
+			if result == nil {
+				logger.Log(logging.LEVEL_WARNING, "call to backend returned a null response", "duration", duration)
+				return result, err
+			}
+			logger.Log(logging.LEVEL_INFO, "call to backend completed", "duration", duration, "complete", result.IsComplete)
+			return result, err
+		}
+	}
+}
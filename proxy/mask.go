@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	maskName = "mask"
+	// MaskLast4 keeps the last 4 characters of the value and replaces the rest with '*'
+	MaskLast4 = "last4"
+	// MaskHash replaces the value with its hex encoded SHA-256 digest
+	MaskHash = "hash"
+	// MaskFixed replaces the value with a fixed string
+	MaskFixed = "fixed"
+
+	maskChar   = "*"
+	fixedValue = "********"
+)
+
+var maskers = map[string]func(string) string{
+	MaskLast4: func(v string) string {
+		r := []rune(v)
+		if len(r) <= 4 {
+			return strings.Repeat(maskChar, len(r))
+		}
+		return strings.Repeat(maskChar, len(r)-4) + string(r[len(r)-4:])
+	},
+	MaskHash: func(v string) string {
+		sum := sha256.Sum256([]byte(v))
+		return hex.EncodeToString(sum[:])
+	},
+	MaskFixed: func(_ string) string { return fixedValue },
+}
+
+// newMaskFormatter creates a formatter obfuscating the values at the configured dotted paths with
+// the configured strategy. Arrays are traversed and the '*' segment matches any key, so
+// "*.password" masks the password field of every nested object
+func newMaskFormatter(cfg interface{}) (EntityFormatter, error) {
+	rules, ok := cfg.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid mask config: %v", cfg)
+	}
+	type masking struct {
+		path []string
+		mask func(string) string
+	}
+	maskings := make([]masking, 0, len(rules))
+	for path, strategy := range rules {
+		name, _ := strategy.(string)
+		m, ok := maskers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown mask strategy for %s: %v", path, strategy)
+		}
+		maskings = append(maskings, masking{strings.Split(path, "."), m})
+	}
+
+	return EntityFormatterFunc(func(entity Response) Response {
+		for _, m := range maskings {
+			maskAtPath(entity.Data, m.path, m.mask)
+		}
+		return entity
+	}), nil
+}
+
+func maskAtPath(v interface{}, path []string, mask func(string) string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if path[0] == maskChar {
+			for k := range t {
+				maskKey(t, k, path, mask)
+			}
+			return
+		}
+		if _, ok := t[path[0]]; ok {
+			maskKey(t, path[0], path, mask)
+		}
+	case []interface{}:
+		for _, element := range t {
+			maskAtPath(element, path, mask)
+		}
+	}
+}
+
+func maskKey(m map[string]interface{}, k string, path []string, mask func(string) string) {
+	if len(path) > 1 {
+		maskAtPath(m[k], path[1:], mask)
+		return
+	}
+	switch value := m[k].(type) {
+	case nil, map[string]interface{}:
+	case []interface{}:
+		for i, element := range value {
+			switch element.(type) {
+			case nil, map[string]interface{}, []interface{}:
+			default:
+				value[i] = mask(fmt.Sprint(element))
+			}
+		}
+	default:
+		m[k] = mask(fmt.Sprint(value))
+	}
+}
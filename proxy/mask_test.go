@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMaskFormatter(t *testing.T) {
+	f, err := newMaskFormatter(map[string]interface{}{
+		"card.number":   MaskLast4,
+		"card.cvv":      MaskFixed,
+		"email":         MaskHash,
+		"users.token":   MaskFixed,
+		"*.password":    MaskFixed,
+		"codes":         MaskLast4,
+		"unknown.field": MaskHash,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := f.Format(Response{
+		Data: map[string]interface{}{
+			"card":  map[string]interface{}{"number": "4111111111111111", "cvv": json.Number("123"), "password": "a"},
+			"email": "supu@example.com",
+			"users": []interface{}{
+				map[string]interface{}{"name": "a", "token": "t1"},
+				map[string]interface{}{"name": "b", "token": "t2"},
+			},
+			"admin": map[string]interface{}{"password": "secret", "name": "root"},
+			"codes": []interface{}{"123456", 42},
+		},
+		IsComplete: true,
+	})
+
+	card := result.Data["card"].(map[string]interface{})
+	if card["number"] != "************1111" || card["cvv"] != fixedValue || card["password"] != fixedValue {
+		t.Errorf("unexpected card: %v", card)
+	}
+	if result.Data["email"] != "e46419c855d8f2eb6f3cfcbb2c89f486155e98e8846c323f7e26e350bb9c9cfb" {
+		t.Errorf("unexpected email: %v", result.Data["email"])
+	}
+	for i, user := range result.Data["users"].([]interface{}) {
+		if u := user.(map[string]interface{}); u["token"] != fixedValue || len(u["name"].(string)) != 1 {
+			t.Errorf("unexpected user %d: %v", i, u)
+		}
+	}
+	if admin := result.Data["admin"].(map[string]interface{}); admin["password"] != fixedValue || admin["name"] != "root" {
+		t.Errorf("unexpected admin: %v", admin)
+	}
+	if codes := result.Data["codes"].([]interface{}); codes[0] != "**3456" || codes[1] != "**" {
+		t.Errorf("unexpected codes: %v", codes)
+	}
+}
+
+func TestMaskFormatter_ko(t *testing.T) {
+	for _, cfg := range []interface{}{
+		"last4",
+		map[string]interface{}{"a": "unknown"},
+	} {
+		if _, err := newMaskFormatter(cfg); err == nil {
+			t.Errorf("expecting an error with %v", cfg)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package memcached provides a cache store persisting the responses of the proxy cache middleware in
+// a set of Memcached servers
+package memcached
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// Name is the key of the store in the cache config of the backends
+const Name = "memcached"
+
+// ErrNoServers is the error returned when the list of Memcached servers is empty
+var ErrNoServers = errors.New("at least one memcached server is required")
+
+// Register registers the Memcached cache store factory
+func Register() error {
+	return proxy.RegisterCacheStore(Name, CacheStoreFactory)
+}
+
+// CacheStoreFactory creates a Memcached cache store with the list of 'servers' of the cache config
+func CacheStoreFactory(cfg map[string]interface{}) (proxy.CacheStore, error) {
+	v, _ := cfg["servers"].([]interface{})
+	servers := []string{}
+	for _, s := range v {
+		if server, ok := s.(string); ok {
+			servers = append(servers, server)
+		}
+	}
+	if len(servers) == 0 {
+		return nil, ErrNoServers
+	}
+	return New(memcache.New(servers...)), nil
+}
+
+// New creates a cache store using the received Memcached client
+func New(client *memcache.Client) proxy.CacheStore {
+	return store{client}
+}
+
+type store struct {
+	client *memcache.Client
+}
+
+// Get implements the proxy.CacheStore interface
+func (s store) Get(key string) ([]byte, bool) {
+	item, err := s.client.Get(hash(key))
+	if err != nil {
+		return nil, false
+	}
+	return item.Value, true
+}
+
+// Set implements the proxy.CacheStore interface. The TTLs are rounded up to seconds
+func (s store) Set(key string, value []byte, ttl time.Duration) {
+	s.client.Set(&memcache.Item{
+		Key:        hash(key),
+		Value:      value,
+		Expiration: int32((ttl + time.Second - 1) / time.Second),
+	})
+}
+
+// hash adapts the keys of the cache middleware to the restrictions of the memcached protocol
+// (no spaces nor control chars, 250 bytes max)
+func hash(key string) string {
+	h := sha1.Sum([]byte(key))
+	return hex.EncodeToString(h[:])
+}
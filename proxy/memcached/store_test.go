@@ -0,0 +1,36 @@
+package memcached
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestCacheStoreFactory_noServers(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"servers": []interface{}{}},
+		{"servers": []interface{}{42}},
+	} {
+		if _, err := CacheStoreFactory(cfg); err != ErrNoServers {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestStore_unreachable(t *testing.T) {
+	client := memcache.New("127.0.0.1:1")
+	client.Timeout = 10 * time.Millisecond
+	s := New(client)
+	s.Set("supu tupu", []byte("tupu"), time.Second)
+	if _, ok := s.Get("supu tupu"); ok {
+		t.Error("the failures of the servers must be reported as misses")
+	}
+}
+
+func TestHash(t *testing.T) {
+	if h := hash("GET /supu?a=1\nAuthorization: tupu"); len(h) != 40 {
+		t.Errorf("unexpected hash: %s", h)
+	}
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	mergeStrategyKey = "merge_strategy"
+	// MergeFirstWins is the merge strategy keeping the value of the first backend declaring a key
+	MergeFirstWins = "first-wins"
+	// MergeLastWins is the merge strategy keeping the value of the last backend declaring a key. It
+	// is the default one
+	MergeLastWins = "last-wins"
+	// MergeDeep is the merge strategy merging recursively the nested objects. The rest of the
+	// colliding values are resolved as in the last-wins strategy
+	MergeDeep = "deep-merge"
+	// MergeErrorOnConflict is the merge strategy returning an error when several backends declare
+	// the same key
+	MergeErrorOnConflict = "error-on-conflict"
+)
+
+// ErrInvalidMergeStrategy is the error returned when the merge strategy is unknown
+var ErrInvalidMergeStrategy = errors.New("invalid merge strategy")
+
+// MergeConflictError is the error returned by the error-on-conflict strategy
+type MergeConflictError struct {
+	Key string
+}
+
+// Error implements the error interface
+func (m MergeConflictError) Error() string {
+	return fmt.Sprintf("merge conflict: several backends returned the key [%s]", m.Key)
+}
+
+// combiner merges the responses of the backends, sorted by the index of their backend
+type combiner func(total int, parts []*Response) (*Response, error)
+
+func newCombiner(extra config.ExtraConfig) combiner {
+	strategy := MergeLastWins
+	if cfg, ok := extra[Namespace].(map[string]interface{}); ok {
+		if s, ok := cfg[mergeStrategyKey].(string); ok {
+			strategy = s
+		}
+	}
+
+	switch strategy {
+	case MergeFirstWins:
+		return newMergeCombiner(func(dst map[string]interface{}, k string, v interface{}) error {
+			if _, ok := dst[k]; !ok {
+				dst[k] = v
+			}
+			return nil
+		})
+	case MergeLastWins:
+		return newMergeCombiner(func(dst map[string]interface{}, k string, v interface{}) error {
+			dst[k] = v
+			return nil
+		})
+	case MergeDeep:
+		return newMergeCombiner(func(dst map[string]interface{}, k string, v interface{}) error {
+			dst[k] = deepMerge(dst[k], v)
+			return nil
+		})
+	case MergeErrorOnConflict:
+		return newMergeCombiner(func(dst map[string]interface{}, k string, v interface{}) error {
+			if _, ok := dst[k]; ok {
+				return MergeConflictError{Key: k}
+			}
+			dst[k] = v
+			return nil
+		})
+	}
+	panic(ErrInvalidMergeStrategy)
+}
+
+func newMergeCombiner(merge func(dst map[string]interface{}, k string, v interface{}) error) combiner {
+	return func(total int, parts []*Response) (*Response, error) {
+		composedData := make(map[string]interface{})
+		isComplete := len(parts) == total
+
+		for _, part := range parts {
+			if part == nil {
+				isComplete = false
+				continue
+			}
+			// the data of the incomplete parts (ie: static fallbacks) is merged, but the result is
+			// flagged as incomplete
+			isComplete = isComplete && part.IsComplete
+			keys := make([]string, 0, len(part.Data))
+			for k := range part.Data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if err := merge(composedData, k, part.Data[k]); err != nil {
+					return &Response{Data: composedData, IsComplete: false}, err
+				}
+			}
+		}
+
+		return &Response{Data: composedData, IsComplete: isComplete}, nil
+	}
+}
+
+func deepMerge(dst, src interface{}) interface{} {
+	d, ok := dst.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	s, ok := src.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	result := make(map[string]interface{}, len(d)+len(s))
+	for k, v := range d {
+		result[k] = v
+	}
+	for k, v := range s {
+		result[k] = deepMerge(result[k], v)
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewMergeDataMiddleware_strategies(t *testing.T) {
+	first := map[string]interface{}{
+		"a": 1,
+		"b": map[string]interface{}{"c": 1, "d": 1},
+	}
+	second := map[string]interface{}{
+		"a": 2,
+		"b": map[string]interface{}{"d": 2, "e": 2},
+	}
+
+	for _, tc := range []struct {
+		strategy string
+		expected map[string]interface{}
+		err      error
+	}{
+		{
+			strategy: "",
+			expected: second,
+		},
+		{
+			strategy: MergeLastWins,
+			expected: second,
+		},
+		{
+			strategy: MergeFirstWins,
+			expected: first,
+		},
+		{
+			strategy: MergeDeep,
+			expected: map[string]interface{}{
+				"a": 2,
+				"b": map[string]interface{}{"c": 1, "d": 2, "e": 2},
+			},
+		},
+		{
+			strategy: MergeErrorOnConflict,
+			expected: first,
+			err:      MergeConflictError{Key: "a"},
+		},
+	} {
+		extra := config.ExtraConfig{}
+		if tc.strategy != "" {
+			extra[Namespace] = map[string]interface{}{mergeStrategyKey: tc.strategy}
+		}
+		backend := config.Backend{}
+		endpoint := config.EndpointConfig{
+			Backend:     []*config.Backend{&backend, &backend},
+			Timeout:     500 * time.Millisecond,
+			ExtraConfig: extra,
+		}
+		p := NewMergeDataMiddleware(&endpoint)(
+			// the first backend is the slowest one, so the merge must not depend on the order of arrival
+			delayedProxy(t, 20*time.Millisecond, &Response{Data: first, IsComplete: true}),
+			dummyProxy(&Response{Data: second, IsComplete: true}))
+
+		out, err := p(context.Background(), &Request{})
+		if err != tc.err {
+			t.Errorf("%s: unexpected error: %v", tc.strategy, err)
+		}
+		if out == nil {
+			t.Errorf("%s: the proxy returned a null result", tc.strategy)
+			continue
+		}
+		if !reflect.DeepEqual(out.Data, tc.expected) {
+			t.Errorf("%s: unexpected response: %v", tc.strategy, out.Data)
+		}
+		if out.IsComplete != (tc.err == nil) {
+			t.Errorf("%s: unexpected completion flag", tc.strategy)
+		}
+	}
+}
+
+func TestNewMergeDataMiddleware_unknownStrategy(t *testing.T) {
+	defer func() {
+		if r := recover(); r != ErrInvalidMergeStrategy {
+			t.Errorf("The code did not panic as expected: %v", r)
+		}
+	}()
+	backend := config.Backend{}
+	endpoint := config.EndpointConfig{
+		Backend:     []*config.Backend{&backend, &backend},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{mergeStrategyKey: "unknown"}},
+	}
+	NewMergeDataMiddleware(&endpoint)
+}
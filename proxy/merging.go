@@ -2,6 +2,11 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/devopsfaith/krakend/config"
@@ -17,8 +22,18 @@ func NewMergeDataMiddleware(endpointConfig *config.EndpointConfig) Middleware {
 		return EmptyMiddleware
 	}
 	serviceTimeout := time.Duration(85*endpointConfig.Timeout.Nanoseconds()/100) * time.Nanosecond
+	combiner := newCombiner(endpointConfig.ExtraConfig)
+	policy := newFailurePolicy(endpointConfig)
 
-	return func(next ...Proxy) Proxy {
+	instrument := func(mw Middleware) Middleware {
+		return newSpanMiddleware(SpanMerge, newDurationMiddleware(mergeDuration, newMergeTimingsMiddleware(mw), endpointConfig.Endpoint))
+	}
+
+	if endpointConfig.IsSequential() {
+		return instrument(sequentialMergeMiddleware(endpointConfig, serviceTimeout, combiner, policy))
+	}
+
+	return instrument(func(next ...Proxy) Proxy {
 		if len(next) != totalBackends {
 			panic(ErrNotEnoughProxies)
 		}
@@ -26,20 +41,27 @@ func NewMergeDataMiddleware(endpointConfig *config.EndpointConfig) Middleware {
 		return func(ctx context.Context, request *Request) (*Response, error) {
 			localCtx, cancel := context.WithTimeout(ctx, serviceTimeout)
 
-			parts := make(chan *Response, len(next))
+			parts := make(chan indexedResponse, len(next))
 			failed := make(chan error, len(next))
 
-			for _, n := range next {
-				go requestPart(localCtx, n, request, parts, failed)
+			for i, n := range next {
+				go requestPart(localCtx, i, n, request, parts, failed)
 			}
 
 			var err error
+			// the responses are stored by the index of their backend, so the merge does not depend
+			// on the order of arrival
 			responses := make([]*Response, len(next))
 			isEmpty := true
 			for i := 0; i < len(next); i++ {
 				select {
 				case err = <-failed:
-				case responses[i] = <-parts:
+					if policy.isFailFast() {
+						cancel()
+						return &Response{Data: make(map[string]interface{}), IsComplete: false}, err
+					}
+				case part := <-parts:
+					responses[part.index] = part.response
 					isEmpty = false
 				}
 			}
@@ -48,14 +70,22 @@ func NewMergeDataMiddleware(endpointConfig *config.EndpointConfig) Middleware {
 				return &Response{Data: make(map[string]interface{}), IsComplete: false}, err
 			}
 
-			result := combineData(totalBackends, responses)
+			result, mergeErr := combiner(totalBackends, responses)
 			cancel()
-			return result, err
+			if mergeErr != nil {
+				return result, mergeErr
+			}
+			return policy.apply(result, responses, err)
 		}
-	}
+	})
+}
+
+type indexedResponse struct {
+	index    int
+	response *Response
 }
 
-func requestPart(ctx context.Context, next Proxy, request *Request, out chan<- *Response, failed chan<- error) {
+func requestPart(ctx context.Context, index int, next Proxy, request *Request, out chan<- indexedResponse, failed chan<- error) {
 	localCtx, cancel := context.WithCancel(ctx)
 
 	in, err := next(localCtx, request)
@@ -70,27 +100,109 @@ func requestPart(ctx context.Context, next Proxy, request *Request, out chan<- *
 		return
 	}
 	select {
-	case out <- in:
+	case out <- indexedResponse{index: index, response: in}:
 	case <-ctx.Done():
 		failed <- ctx.Err()
 	}
 	cancel()
 }
 
-func combineData(total int, parts []*Response) *Response {
-	composedData := make(map[string]interface{})
-	isComplete := len(parts) == total
+// sequentialMergeMiddleware calls the backends in order, adding the values of the previous responses
+// required by the URL patterns of the next backends to the params of their requests. Since the following
+// backends may depend on the failed one, the sequence stops at the first error
+func sequentialMergeMiddleware(endpointConfig *config.EndpointConfig, serviceTimeout time.Duration, combiner combiner, policy failurePolicy) Middleware {
+	totalBackends := len(endpointConfig.Backend)
+	// sequentialParams collects the params (and the path of their values) taken from every response
+	sequentialParams := make([]map[string][]string, totalBackends)
+	for i := range sequentialParams {
+		sequentialParams[i] = map[string][]string{}
+	}
+	for _, b := range endpointConfig.Backend {
+		for _, key := range b.URLKeys {
+			matches := sequentialKeyPattern.FindStringSubmatch(key)
+			if matches == nil {
+				continue
+			}
+			if idx, err := strconv.Atoi(matches[1]); err == nil && idx < totalBackends {
+				sequentialParams[idx][key] = strings.Split(matches[2], ".")
+			}
+		}
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) != totalBackends {
+			panic(ErrNotEnoughProxies)
+		}
+
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			localCtx, cancel := context.WithTimeout(ctx, serviceTimeout)
 
-	for _, part := range parts {
-		if part != nil && part.IsComplete {
-			for k, v := range part.Data {
-				composedData[k] = v
+			params := make(map[string]string, len(request.Params))
+			for k, v := range request.Params {
+				params[k] = v
 			}
-			isComplete = isComplete && part.IsComplete
-		} else {
-			isComplete = false
+
+			var err error
+			responses := make([]*Response, totalBackends)
+			parts := make(chan indexedResponse, 1)
+			failed := make(chan error, 1)
+			isEmpty := true
+
+		sequence:
+			for i, n := range next {
+				r := request.Clone()
+				r.Params = params
+				go requestPart(localCtx, i, n, &r, parts, failed)
+
+				select {
+				case err = <-failed:
+					break sequence
+				case part := <-parts:
+					responses[i] = part.response
+					isEmpty = false
+				}
+
+				if len(sequentialParams[i]) == 0 {
+					continue
+				}
+				nextParams := make(map[string]string, len(params)+len(sequentialParams[i]))
+				for k, v := range params {
+					nextParams[k] = v
+				}
+				for key, path := range sequentialParams[i] {
+					if v, ok := lookupPath(responses[i].Data, path); ok {
+						nextParams[key] = paramValue(v)
+					}
+				}
+				params = nextParams
+			}
+			if isEmpty {
+				cancel()
+				return &Response{Data: make(map[string]interface{}), IsComplete: false}, err
+			}
+
+			result, mergeErr := combiner(totalBackends, responses)
+			cancel()
+			if mergeErr != nil {
+				return result, mergeErr
+			}
+			return policy.apply(result, responses, err)
 		}
 	}
+}
 
-	return &Response{Data: composedData, IsComplete: isComplete}
+var sequentialKeyPattern = regexp.MustCompile(`^Resp([0-9]+)_(.+)$`)
+
+func paramValue(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
 }
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -207,3 +208,59 @@ func TestNewMergeDataMiddleware_noBackends(t *testing.T) {
 	endpoint := config.EndpointConfig{}
 	NewMergeDataMiddleware(&endpoint)
 }
+
+func TestNewMergeDataMiddleware_sequential(t *testing.T) {
+	timeout := 500
+	endpoint := config.EndpointConfig{
+		Backend: []*config.Backend{
+			{URLPattern: "/user/{{.Id}}", URLKeys: []string{"Id"}},
+			{URLPattern: "/posts/{{.Resp0_user.id}}", URLKeys: []string{"Resp0_user.id"}},
+		},
+		Timeout:     time.Duration(timeout) * time.Millisecond,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"sequential": true}},
+	}
+	mw := NewMergeDataMiddleware(&endpoint)
+	p := mw(
+		dummyProxy(&Response{Data: map[string]interface{}{"user": map[string]interface{}{"id": 42.0}}, IsComplete: true}),
+		func(_ context.Context, r *Request) (*Response, error) {
+			if v := r.Params["Resp0_user.id"]; v != "42" {
+				t.Errorf("unexpected sequential param: %s", v)
+			}
+			if v := r.Params["Id"]; v != "1" {
+				t.Errorf("unexpected param: %s", v)
+			}
+			return &Response{Data: map[string]interface{}{"posts": []interface{}{}}, IsComplete: true}, nil
+		})
+	out, err := p(context.Background(), &Request{Params: map[string]string{"Id": "1"}})
+	if err != nil {
+		t.Errorf("The middleware propagated an unexpected error: %s\n", err.Error())
+		return
+	}
+	if len(out.Data) != 2 {
+		t.Errorf("unexpected response: %v", out.Data)
+	}
+	if !out.IsComplete {
+		t.Error("We were expecting a completed response but we got an incompleted one!")
+	}
+}
+
+func TestNewMergeDataMiddleware_sequentialError(t *testing.T) {
+	timeout := 500
+	expectedErr := errors.New("some error")
+	endpoint := config.EndpointConfig{
+		Backend:     []*config.Backend{{}, {}},
+		Timeout:     time.Duration(timeout) * time.Millisecond,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"sequential": true}},
+	}
+	mw := NewMergeDataMiddleware(&endpoint)
+	p := mw(
+		func(_ context.Context, _ *Request) (*Response, error) { return nil, expectedErr },
+		explosiveProxy(t))
+	out, err := p(context.Background(), &Request{})
+	if err != expectedErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if out == nil || out.IsComplete || len(out.Data) != 0 {
+		t.Errorf("unexpected response: %v", out)
+	}
+}
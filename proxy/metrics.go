@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/metrics"
+)
+
+var (
+	backendRequests = metrics.DefaultRegistry.Counter("krakend_proxy_backend_requests_total",
+		"Requests sent to the backends.", "endpoint", "backend", "status_class")
+	backendDuration = metrics.DefaultRegistry.Histogram("krakend_proxy_backend_duration_seconds",
+		"Latency of the requests sent to the backends, including their retries.", metrics.DefaultBuckets,
+		"endpoint", "backend", "status_class")
+	mergeDuration = metrics.DefaultRegistry.Histogram("krakend_proxy_merge_duration_seconds",
+		"Time spent requesting and merging the responses of the backends.", metrics.DefaultBuckets, "endpoint")
+	decodeDuration = metrics.DefaultRegistry.Histogram("krakend_proxy_decode_duration_seconds",
+		"Time spent decoding the responses of the backends.", metrics.DefaultBuckets)
+	circuitBreakerTransitions = metrics.DefaultRegistry.Counter("krakend_proxy_circuit_breaker_transitions_total",
+		"State transitions of the circuit breakers.", "name", "state")
+)
+
+func init() {
+	metrics.DefaultRegistry.Collect("krakend_proxy_circuit_breaker_state",
+		"State of the circuit breakers: 0 closed, 1 open, 2 half-open.", metrics.KindGauge, func() []metrics.Metric {
+			states := CircuitBreakerStates()
+			res := make([]metrics.Metric, 0, len(states))
+			for name, state := range states {
+				res = append(res, metrics.Metric{
+					Labels: []metrics.Label{{Name: "name", Value: name}},
+					Value:  float64(state),
+				})
+			}
+			return res
+		})
+	RegisterCircuitBreakerListener(func(name string, _, to CircuitState) {
+		circuitBreakerTransitions.Inc(name, to.String())
+	})
+}
+
+// NewMetricsMiddleware creates a proxy middleware recording the requests sent to the backend of the endpoint
+// and their latency, labelled by the class of their status code. The retries, the hedged requests and the
+// mirrors are part of a single request
+func NewMetricsMiddleware(endpoint *config.EndpointConfig, remote *config.Backend) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next[0](ctx, request)
+			status := 0
+			if resp != nil {
+				status = resp.Metadata.StatusCode
+				if status == 0 && err == nil {
+					status = 200
+				}
+			}
+			class := metrics.StatusClass(status)
+			backendRequests.Inc(endpoint.Endpoint, remote.URLPattern, class)
+			backendDuration.Observe(time.Since(start).Seconds(), endpoint.Endpoint, remote.URLPattern, class)
+			return resp, err
+		}
+	}
+}
+
+// newDurationMiddleware records the latency of the proxies returned by the middleware into the histogram
+func newDurationMiddleware(h *metrics.HistogramVec, mw Middleware, labels ...string) Middleware {
+	return func(next ...Proxy) Proxy {
+		p := mw(next...)
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := p(ctx, request)
+			h.Observe(time.Since(start).Seconds(), labels...)
+			return resp, err
+		}
+	}
+}
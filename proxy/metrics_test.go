@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/metrics"
+)
+
+// metricValue returns the value of the counter or the count of the histogram with the labels. The collectors
+// are shared by all the tests, so they must check the increments instead of the absolute values
+func metricValue(name string, labels ...string) float64 {
+	for _, f := range metrics.DefaultRegistry.Gather() {
+		if f.Name != name {
+			continue
+		}
+	next:
+		for _, m := range f.Metrics {
+			for i, l := range m.Labels {
+				if l.Value != labels[i] {
+					continue next
+				}
+			}
+			if f.Kind == metrics.KindHistogram {
+				return float64(m.Count)
+			}
+			return m.Value
+		}
+	}
+	return 0
+}
+
+func TestNewMetricsMiddleware(t *testing.T) {
+	endpoint := &config.EndpointConfig{Endpoint: "/metrics-test"}
+	backend := &config.Backend{URLPattern: "/users"}
+	responses := []*Response{
+		{IsComplete: true, Metadata: Metadata{StatusCode: 201}},
+		{IsComplete: true},
+		nil,
+	}
+	requests2xx := metricValue("krakend_proxy_backend_requests_total", "/metrics-test", "/users", "2xx")
+	requestsKo := metricValue("krakend_proxy_backend_requests_total", "/metrics-test", "/users", "error")
+	observations := metricValue("krakend_proxy_backend_duration_seconds", "/metrics-test", "/users", "2xx")
+	calls := 0
+	p := NewMetricsMiddleware(endpoint, backend)(func(_ context.Context, _ *Request) (*Response, error) {
+		resp := responses[calls]
+		calls++
+		if resp == nil {
+			return nil, errors.New("timeout")
+		}
+		return resp, nil
+	})
+	for range responses {
+		p(context.Background(), &Request{})
+	}
+
+	if v := metricValue("krakend_proxy_backend_requests_total", "/metrics-test", "/users", "2xx") - requests2xx; v != 2 {
+		t.Errorf("unexpected 2xx requests: %v", v)
+	}
+	if v := metricValue("krakend_proxy_backend_requests_total", "/metrics-test", "/users", "error") - requestsKo; v != 1 {
+		t.Errorf("unexpected failed requests: %v", v)
+	}
+	if v := metricValue("krakend_proxy_backend_duration_seconds", "/metrics-test", "/users", "2xx") - observations; v != 2 {
+		t.Errorf("unexpected observations: %v", v)
+	}
+}
+
+func TestNewMergeDataMiddleware_metrics(t *testing.T) {
+	endpoint := &config.EndpointConfig{Endpoint: "/merge-metrics-test", Backend: []*config.Backend{{}, {}}, Timeout: time.Second}
+	backend := func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{Data: map[string]interface{}{"a": 1}, IsComplete: true}, nil
+	}
+	observations := metricValue("krakend_proxy_merge_duration_seconds", "/merge-metrics-test")
+	p := NewMergeDataMiddleware(endpoint)(backend, backend)
+	p(context.Background(), &Request{})
+	p(context.Background(), &Request{})
+	if v := metricValue("krakend_proxy_merge_duration_seconds", "/merge-metrics-test") - observations; v != 2 {
+		t.Errorf("unexpected observations: %v", v)
+	}
+}
+
+func TestCircuitBreakerMetrics(t *testing.T) {
+	transitions := metricValue("krakend_proxy_circuit_breaker_transitions_total", "metrics-test", "open")
+	mw, err := NewCircuitBreakerMiddleware(&config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			circuitBreakerKey: map[string]interface{}{"name": "metrics-test", "max_errors": 1.0, "timeout": "1h"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		return nil, errors.New("backend error")
+	})
+	p(context.Background(), &Request{})
+
+	if v := metricValue("krakend_proxy_circuit_breaker_state", "metrics-test"); v != float64(CircuitOpen) {
+		t.Errorf("unexpected state: %v", v)
+	}
+	if v := metricValue("krakend_proxy_circuit_breaker_transitions_total", "metrics-test", "open") - transitions; v != 1 {
+		t.Errorf("unexpected transitions: %v", v)
+	}
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	mirrorKey = "mirror"
+
+	defaultMirrorPercentage = 100.0
+)
+
+var (
+	// ErrNoMirrorHosts is the error returned when the hosts of the shadow backend are not defined
+	ErrNoMirrorHosts = errors.New("the hosts of the shadow backend are required")
+	// ErrInvalidMirrorPercentage is the error returned when the percentage of mirrored requests is not in [0, 100]
+	ErrInvalidMirrorPercentage = errors.New("the percentage of mirrored requests must be between 0 and 100")
+)
+
+// NewMirroringMiddleware creates a proxy middleware copying the 'percentage' of the requests to a shadow
+// backend with the same config but the received 'host' list. The copies are sent asynchronously and
+// their responses are ignored, so the shadow backend never affects the responses of the endpoint.
+//
+// The middleware is enabled with the 'mirror' option of the backend proxy extra config and the shadow
+// proxy is created with the received BackendFactory
+func NewMirroringMiddleware(remote *config.Backend, bf BackendFactory) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	cfg, ok := extra[mirrorKey].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+
+	shadowCfg := *remote
+	shadowCfg.Host = []string{}
+	hosts, _ := cfg["host"].([]interface{})
+	for _, h := range hosts {
+		if host, ok := h.(string); ok {
+			shadowCfg.Host = append(shadowCfg.Host, host)
+		}
+	}
+	if len(shadowCfg.Host) == 0 {
+		return nil, ErrNoMirrorHosts
+	}
+	percentage := defaultMirrorPercentage
+	if v, ok := cfg["percentage"].(float64); ok {
+		percentage = v
+	}
+	if percentage < 0 || percentage > 100 {
+		return nil, ErrInvalidMirrorPercentage
+	}
+
+	timeout := remote.Timeout
+	if timeout == 0 {
+		timeout = config.DefaultTimeout
+	}
+	shadow := NewRoundRobinLoadBalancedMiddleware(&shadowCfg)(bf(&shadowCfg))
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			if rand.Float64()*100 >= percentage {
+				return next[0](ctx, request)
+			}
+
+			// the body is buffered, so it can be sent to both backends
+			r := request.Clone()
+			mirrored := request.Clone()
+			if request.Body != nil {
+				body, err := ioutil.ReadAll(request.Body)
+				request.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+				mirrored.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+
+			go func() {
+				// the copy is not canceled with the original request, but it times out with the backend timeout
+				shadowCtx, cancel := context.WithTimeout(context.Background(), timeout)
+				shadow(shadowCtx, &mirrored)
+				cancel()
+			}()
+
+			return next[0](ctx, &r)
+		}
+	}, nil
+}
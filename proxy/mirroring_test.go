@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func mirrorBackend(cfg map[string]interface{}) *config.Backend {
+	return &config.Backend{
+		Host:        []string{"http://main"},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{mirrorKey: cfg}},
+	}
+}
+
+func TestNewMirroringMiddleware_koConfig(t *testing.T) {
+	bf := func(_ *config.Backend) Proxy { return dummyProxy(&Response{}) }
+	for i, tc := range []struct {
+		cfg map[string]interface{}
+		err error
+	}{
+		{cfg: map[string]interface{}{}, err: ErrNoMirrorHosts},
+		{cfg: map[string]interface{}{"host": []interface{}{"http://shadow"}, "percentage": 120.0}, err: ErrInvalidMirrorPercentage},
+		{cfg: map[string]interface{}{"host": []interface{}{"http://shadow"}, "percentage": -1.0}, err: ErrInvalidMirrorPercentage},
+	} {
+		if _, err := NewMirroringMiddleware(mirrorBackend(tc.cfg), bf); err != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestNewMirroringMiddleware_ok(t *testing.T) {
+	mirrored := make(chan string, 1)
+	bf := func(remote *config.Backend) Proxy {
+		if len(remote.Host) != 1 || remote.Host[0] != "http://shadow" {
+			t.Errorf("unexpected shadow hosts: %v", remote.Host)
+		}
+		return func(ctx context.Context, r *Request) (*Response, error) {
+			b, _ := ioutil.ReadAll(r.Body)
+			mirrored <- r.URL.String() + " " + string(b)
+			// the response of the shadow backend is ignored
+			return nil, nil
+		}
+	}
+	mw, err := NewMirroringMiddleware(mirrorBackend(map[string]interface{}{"host": []interface{}{"http://shadow"}}), bf)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != "supu" {
+			t.Errorf("unexpected body: %s", string(b))
+		}
+		return &Response{IsComplete: true}, nil
+	})
+
+	resp, err := p(context.Background(), &Request{Path: "/tupu", Body: ioutil.NopCloser(strings.NewReader("supu"))})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if resp == nil || !resp.IsComplete {
+		t.Errorf("unexpected response: %v", resp)
+	}
+
+	select {
+	case m := <-mirrored:
+		if m != "http://shadow/tupu supu" {
+			t.Errorf("unexpected mirrored request: %s", m)
+		}
+	case <-time.After(time.Second):
+		t.Error("the request was not mirrored")
+	}
+}
+
+func TestNewMirroringMiddleware_percentage(t *testing.T) {
+	var mirrored uint64
+	bf := func(_ *config.Backend) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			atomic.AddUint64(&mirrored, 1)
+			return nil, nil
+		}
+	}
+	mw, err := NewMirroringMiddleware(mirrorBackend(map[string]interface{}{
+		"host":       []interface{}{"http://shadow"},
+		"percentage": 0.0,
+	}), bf)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	p := mw(dummyProxy(&Response{IsComplete: true}))
+	for i := 0; i < 100; i++ {
+		p(context.Background(), &Request{})
+	}
+	time.Sleep(10 * time.Millisecond)
+	if m := atomic.LoadUint64(&mirrored); m != 0 {
+		t.Errorf("unexpected mirrored requests: %d", m)
+	}
+}
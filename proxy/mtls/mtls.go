@@ -0,0 +1,195 @@
+// Package mtls provides a backend factory presenting client certificates to the backends requiring mutual
+// TLS, with the certificates and the authorities of their hosts
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// Namespace is the key to look for the mutual TLS options in the extra config of the backends
+const Namespace = "github.com/devopsfaith/krakend/proxy/mtls"
+
+// ErrNoCertificate is the error returned when the public or the private key of the client certificate is
+// missing
+var ErrNoCertificate = errors.New("mtls: the client certificate requires the public and the private keys")
+
+// Config is the mutual TLS options set at the extra config of the backend. The options at the root apply to
+// all the hosts of the backend, and the ones of the Hosts replace them for the hosts declared there
+type Config struct {
+	ClientTLS
+	// Hosts are the options of the hosts requiring their own client certificate, by host (as declared in
+	// the backend, with its scheme)
+	Hosts map[string]ClientTLS `json:"hosts"`
+}
+
+// ClientTLS is the client certificate presented to a host and the authorities verifying its certificate
+type ClientTLS struct {
+	// PublicKey is the PEM encoded client certificate
+	PublicKey string `json:"public_key"`
+	// PrivateKey is the PEM encoded private key of the client certificate
+	PrivateKey string `json:"private_key"`
+	// CACerts are the PEM encoded certificates of the authorities verifying the host. By default, the ones
+	// of the system
+	CACerts []string `json:"ca_certs"`
+	// ServerName is the name verified in the certificate of the host, if it differs from the host name
+	ServerName string `json:"server_name"`
+	// InsecureSkipVerify accepts any certificate of the host. It is meant for testing only
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+// ConfigGetter parses the mutual TLS options from the extra config. The second value is false if the backend
+// does not declare them
+func ConfigGetter(extra config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, err
+	}
+	return cfg, true, nil
+}
+
+// BackendFactory returns a BackendFactory creating http proxies with the mutual TLS clients of the backends
+// declaring the mutual TLS options in their extra config and delegating the creation of the rest of them to
+// the next factory. The backends with the same options share the same client, so they share its connections
+func BackendFactory(next proxy.BackendFactory) proxy.BackendFactory {
+	clients := map[string]*http.Client{}
+	mu := &sync.Mutex{}
+	return func(remote *config.Backend) proxy.Proxy {
+		cfg, ok, err := ConfigGetter(remote.ExtraConfig)
+		if !ok {
+			return next(remote)
+		}
+		if err != nil {
+			return errorProxy(err)
+		}
+		key, err := json.Marshal(cfg)
+		if err != nil {
+			return errorProxy(err)
+		}
+
+		mu.Lock()
+		client, ok := clients[string(key)]
+		if !ok {
+			if client, err = NewHTTPClient(cfg); err == nil {
+				clients[string(key)] = client
+			}
+		}
+		mu.Unlock()
+		if err != nil {
+			return errorProxy(err)
+		}
+		return proxy.NewHTTPProxy(remote, func(_ context.Context) *http.Client { return client }, remote.Decoder)
+	}
+}
+
+// NewHTTPClient returns a client presenting the client certificate of the host of every request
+func NewHTTPClient(cfg Config) (*http.Client, error) {
+	t, err := newTransport(cfg.ClientTLS)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Hosts) == 0 {
+		return &http.Client{Transport: t}, nil
+	}
+	hosts := make(map[string]http.RoundTripper, len(cfg.Hosts))
+	for host, clientTLS := range cfg.Hosts {
+		if hosts[strings.TrimSuffix(host, "/")], err = newTransport(clientTLS); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Client{Transport: hostTransport{hosts: hosts, fallback: t}}, nil
+}
+
+// NewTLSConfig returns the TLS config presenting the client certificate and verifying the host with the
+// authorities
+func NewTLSConfig(cfg ClientTLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.PublicKey != "" || cfg.PrivateKey != "" {
+		if cfg.PublicKey == "" || cfg.PrivateKey == "" {
+			return nil, ErrNoCertificate
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.PublicKey, cfg.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("mtls: loading the key pair %s: %s", cfg.PublicKey, err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if len(cfg.CACerts) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		for _, f := range cfg.CACerts {
+			b, err := ioutil.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(b) {
+				return nil, fmt.Errorf("mtls: no certificates in %s", f)
+			}
+		}
+	}
+	return tlsConfig, nil
+}
+
+// newTransport returns a transport with the settings of the http.DefaultTransport and the TLS config
+func newTransport(cfg ClientTLS) (*http.Transport, error) {
+	tlsConfig, err := NewTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   config.DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}, nil
+}
+
+// hostTransport sends the requests with the transport of their host, or with the fallback one if the host
+// does not have its own transport
+type hostTransport struct {
+	hosts    map[string]http.RoundTripper
+	fallback http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t hostTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if rt, ok := t.hosts[r.URL.Scheme+"://"+r.URL.Host]; ok {
+		return rt.RoundTrip(r)
+	}
+	return t.fallback.RoundTrip(r)
+}
+
+func errorProxy(err error) proxy.Proxy {
+	return func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) { return nil, err }
+}
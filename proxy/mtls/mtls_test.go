@@ -0,0 +1,206 @@
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// writeKeyPair writes a self-signed certificate for the loopback address and its key in the directory
+func writeKeyPair(t *testing.T, dir, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, err := ConfigGetter(config.ExtraConfig{}); ok || err != nil {
+		t.Error("the backend should not declare the mutual TLS options")
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"public_key":  "client.pem",
+		"private_key": "client.key",
+		"hosts": map[string]interface{}{
+			"https://billing:8443": map[string]interface{}{"public_key": "billing.pem", "private_key": "billing.key"},
+		},
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result: %v %v", ok, err)
+	}
+	if cfg.PublicKey != "client.pem" || cfg.PrivateKey != "client.key" || cfg.Hosts["https://billing:8443"].PublicKey != "billing.pem" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestBackendFactory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krakend_mtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	serverCert, serverKey := writeKeyPair(t, dir, "server")
+	clientCert, clientKey := writeKeyPair(t, dir, "client")
+	otherCert, otherKey := writeKeyPair(t, dir, "other")
+
+	clientCAs := x509.NewCertPool()
+	b, _ := ioutil.ReadFile(clientCert)
+	clientCAs.AppendCertsFromPEM(b)
+	cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"client":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	}))
+	s.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	s.StartTLS()
+	defer s.Close()
+	u, _ := url.Parse(s.URL)
+
+	next := func(_ *config.Backend) proxy.Proxy {
+		return func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+			return &proxy.Response{Data: map[string]interface{}{"client": "next"}}, nil
+		}
+	}
+	bf := BackendFactory(next)
+
+	for i, tc := range []struct {
+		extra    config.ExtraConfig
+		expected string
+	}{
+		{
+			extra:    config.ExtraConfig{},
+			expected: "next",
+		},
+		{
+			extra: config.ExtraConfig{Namespace: map[string]interface{}{
+				"public_key":  clientCert,
+				"private_key": clientKey,
+				"ca_certs":    []interface{}{serverCert},
+			}},
+			expected: "client",
+		},
+		{
+			extra: config.ExtraConfig{Namespace: map[string]interface{}{
+				"public_key":  otherCert,
+				"private_key": otherKey,
+				"ca_certs":    []interface{}{serverCert},
+				"hosts": map[string]interface{}{
+					s.URL: map[string]interface{}{
+						"public_key":  clientCert,
+						"private_key": clientKey,
+						"ca_certs":    []interface{}{serverCert},
+					},
+				},
+			}},
+			expected: "client",
+		},
+		{
+			// the host is not trusted
+			extra: config.ExtraConfig{Namespace: map[string]interface{}{
+				"public_key":  clientCert,
+				"private_key": clientKey,
+			}},
+		},
+		{
+			// the client certificate is not trusted
+			extra: config.ExtraConfig{Namespace: map[string]interface{}{
+				"public_key":  otherCert,
+				"private_key": otherKey,
+				"ca_certs":    []interface{}{serverCert},
+			}},
+		},
+		{
+			extra: config.ExtraConfig{Namespace: map[string]interface{}{
+				"public_key": clientCert,
+			}},
+		},
+	} {
+		remote := &config.Backend{Decoder: encoding.JSONDecoder, ExtraConfig: tc.extra}
+		resp, err := bf(remote)(context.Background(), &proxy.Request{Method: "GET", URL: u, Body: ioutil.NopCloser(strings.NewReader(""))})
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("#%d: expecting an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if resp.Data["client"] != tc.expected {
+			t.Errorf("#%d: unexpected client: %v", i, resp.Data["client"])
+		}
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	if _, err := NewTLSConfig(ClientTLS{PrivateKey: "client.key"}); err != ErrNoCertificate {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := NewTLSConfig(ClientTLS{PublicKey: "unknown.pem", PrivateKey: "unknown.key"}); err == nil {
+		t.Error("expecting an error")
+	}
+	if _, err := NewTLSConfig(ClientTLS{CACerts: []string{"unknown.pem"}}); err == nil {
+		t.Error("expecting an error")
+	}
+	tlsConfig, err := NewTLSConfig(ClientTLS{ServerName: "billing.internal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ServerName != "billing.internal" || len(tlsConfig.Certificates) != 0 || tlsConfig.RootCAs != nil {
+		t.Errorf("unexpected config: %+v", tlsConfig)
+	}
+}
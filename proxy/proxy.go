@@ -81,3 +81,9 @@ func EmptyMiddleware(next ...Proxy) Proxy {
 
 // NoopProxy is a do nothing proxy, useful for testing
 func NoopProxy(_ context.Context, _ *Request) (*Response, error) { return nil, nil }
+
+// newErrorProxy returns a proxy that always fails with the received error. It is used when the
+// proxy can not be built because of an invalid configuration
+func newErrorProxy(err error) Proxy {
+	return func(_ context.Context, _ *Request) (*Response, error) { return nil, err }
+}
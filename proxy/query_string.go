@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const queryStringKey = "querystring"
+
+// QueryStringConfig defines the transformations of the query strings of the requests sent to a backend
+type QueryStringConfig struct {
+	// ForwardAll forwards all the query string params of the request, instead of the ones in the
+	// querystring_params of the endpoint
+	ForwardAll bool `json:"forward_all"`
+	// ToPath are the query string params available as placeholders in the URL pattern of the backend. They
+	// are not forwarded in the query string
+	ToPath []string `json:"to_path"`
+	// FromPath maps the params of the endpoint into the query string params to send
+	FromPath map[string]string `json:"from_path"`
+	// Rename maps the query string params into their new names
+	Rename map[string]string `json:"rename"`
+	// Remove are the query string params not forwarded to the backend
+	Remove []string `json:"remove"`
+	// Add are the static query string params added to the requests, replacing the received ones
+	Add map[string]string `json:"add"`
+}
+
+// NewQueryStringMiddleware creates a proxy middleware transforming the query strings of the requests sent
+// to the backend. The params are filtered, mapped from and into the path, renamed, removed and added, in
+// that order. The backends not forwarding all the params only receive the ones in the querystring_params
+// of the endpoint.
+//
+// The middleware is enabled with the 'querystring' option of the backend proxy extra config
+func NewQueryStringMiddleware(endpoint *config.EndpointConfig, remote *config.Backend) (Middleware, error) {
+	cfg := QueryStringConfig{}
+	if extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{}); ok {
+		if v, ok := extra[queryStringKey]; ok {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(b, &cfg); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// the endpoints receive all the params only if some of their backends forward all of them
+	var allowed map[string]bool
+	if !cfg.ForwardAll {
+		for _, q := range endpoint.QueryString {
+			if q == config.QueryStringWildcard {
+				allowed = map[string]bool{}
+				break
+			}
+		}
+		if allowed != nil {
+			for _, q := range endpoint.QueryString {
+				allowed[q] = true
+			}
+			for _, q := range cfg.ToPath {
+				allowed[q] = true
+			}
+		}
+	}
+
+	if allowed == nil && len(cfg.ToPath) == 0 && len(cfg.FromPath) == 0 && len(cfg.Rename) == 0 &&
+		len(cfg.Remove) == 0 && len(cfg.Add) == 0 {
+		return EmptyMiddleware, nil
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			r := request.Clone()
+			r.Query = make(url.Values, len(request.Query)+len(cfg.Add))
+			for k, vs := range request.Query {
+				if allowed == nil || allowed[k] {
+					r.Query[k] = vs
+				}
+			}
+
+			if len(cfg.ToPath) > 0 || len(cfg.FromPath) > 0 {
+				r.Params = make(map[string]string, len(request.Params)+len(cfg.ToPath))
+				for k, v := range request.Params {
+					r.Params[k] = v
+				}
+			}
+			for _, k := range cfg.ToPath {
+				r.Params[strings.Title(k)] = r.Query.Get(k)
+				delete(r.Query, k)
+			}
+			for k, q := range cfg.FromPath {
+				if v, ok := r.Params[strings.Title(k)]; ok && v != "" {
+					r.Query[q] = []string{v}
+				}
+			}
+
+			for from, to := range cfg.Rename {
+				if vs, ok := r.Query[from]; ok {
+					delete(r.Query, from)
+					r.Query[to] = vs
+				}
+			}
+			for _, k := range cfg.Remove {
+				delete(r.Query, k)
+			}
+			for k, v := range cfg.Add {
+				r.Query[k] = []string{v}
+			}
+			return next[0](ctx, &r)
+		}
+	}, nil
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewQueryStringMiddleware(t *testing.T) {
+	endpoint := &config.EndpointConfig{QueryString: []string{"q", "page", "debug", config.QueryStringWildcard}}
+	backend := &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		queryStringKey: map[string]interface{}{
+			"to_path":   []interface{}{"page"},
+			"from_path": map[string]interface{}{"id": "user_id"},
+			"rename":    map[string]interface{}{"q": "query"},
+			"remove":    []interface{}{"debug"},
+			"add":       map[string]interface{}{"source": "gateway"},
+		},
+	}}}
+	mw, err := NewQueryStringMiddleware(endpoint, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := &Request{
+		Params: map[string]string{"Id": "42"},
+		Query:  url.Values{"q": {"supu"}, "page": {"3"}, "debug": {"true"}, "other": {"tupu"}, "source": {"client"}},
+	}
+	p := mw(func(_ context.Context, r *Request) (*Response, error) {
+		expected := url.Values{"query": {"supu"}, "user_id": {"42"}, "source": {"gateway"}}
+		if !reflect.DeepEqual(r.Query, expected) {
+			t.Errorf("unexpected query: %v", r.Query)
+		}
+		if !reflect.DeepEqual(r.Params, map[string]string{"Id": "42", "Page": "3"}) {
+			t.Errorf("unexpected params: %v", r.Params)
+		}
+		return &Response{IsComplete: true}, nil
+	})
+	if _, err := p(context.Background(), request); err != nil {
+		t.Error(err)
+	}
+	if len(request.Params) != 1 || len(request.Query) != 5 {
+		t.Errorf("the original request has been modified: %v %v", request.Params, request.Query)
+	}
+}
+
+func TestNewQueryStringMiddleware_forwardAll(t *testing.T) {
+	endpoint := &config.EndpointConfig{QueryString: []string{"q", config.QueryStringWildcard}}
+	query := url.Values{"q": {"supu"}, "other": {"tupu", "kupu"}}
+
+	for _, tc := range []struct {
+		name     string
+		backend  *config.Backend
+		expected url.Values
+	}{
+		{
+			name:     "forward all",
+			backend:  &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{queryStringKey: map[string]interface{}{"forward_all": true}}}},
+			expected: query,
+		},
+		{
+			name:     "filtered",
+			backend:  &config.Backend{},
+			expected: url.Values{"q": {"supu"}},
+		},
+	} {
+		mw, err := NewQueryStringMiddleware(endpoint, tc.backend)
+		if err != nil {
+			t.Error(tc.name, err)
+			continue
+		}
+		p := mw(func(_ context.Context, r *Request) (*Response, error) {
+			if !reflect.DeepEqual(r.Query, tc.expected) {
+				t.Errorf("%s: unexpected query: %v", tc.name, r.Query)
+			}
+			return &Response{IsComplete: true}, nil
+		})
+		if _, err := p(context.Background(), &Request{Query: query}); err != nil {
+			t.Error(tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Package redis provides a cache store persisting the responses of the proxy cache middleware in a Redis server
+package redis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// Name is the key of the store in the cache config of the backends
+const Name = "redis"
+
+// ErrNoAddress is the error returned when the address of the Redis server is not defined
+var ErrNoAddress = errors.New("the address of the redis server is required")
+
+// Register registers the Redis cache store factory
+func Register() error {
+	return proxy.RegisterCacheStore(Name, CacheStoreFactory)
+}
+
+// CacheStoreFactory creates a Redis cache store with the 'address', 'password' and 'db' options
+// of the cache config
+func CacheStoreFactory(cfg map[string]interface{}) (proxy.CacheStore, error) {
+	addr, ok := cfg["address"].(string)
+	if !ok || addr == "" {
+		return nil, ErrNoAddress
+	}
+	password, _ := cfg["password"].(string)
+	db, _ := cfg["db"].(float64)
+
+	return New(redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       int(db),
+	})), nil
+}
+
+// New creates a cache store using the received Redis client
+func New(client *redis.Client) proxy.CacheStore {
+	return store{client}
+}
+
+type store struct {
+	client *redis.Client
+}
+
+// Get implements the proxy.CacheStore interface
+func (s store) Get(key string) ([]byte, bool) {
+	v, err := s.client.Get(key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// Set implements the proxy.CacheStore interface
+func (s store) Set(key string, value []byte, ttl time.Duration) {
+	s.client.Set(key, value, ttl)
+}
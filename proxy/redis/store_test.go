@@ -0,0 +1,26 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+func TestCacheStoreFactory_noAddress(t *testing.T) {
+	if _, err := CacheStoreFactory(map[string]interface{}{}); err != ErrNoAddress {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStore_unreachable(t *testing.T) {
+	s := New(redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: 10 * time.Millisecond,
+		MaxRetries:  0,
+	}))
+	s.Set("supu", []byte("tupu"), time.Second)
+	if _, ok := s.Get("supu"); ok {
+		t.Error("the failures of the server must be reported as misses")
+	}
+}
@@ -6,7 +6,8 @@ import (
 	"net/url"
 )
 
-// Request contains the data to send to the backend
+// Request contains the data to send to the backend. Until the request builder generates the path to send,
+// the Path is the one requested to the endpoint
 type Request struct {
 	Method  string
 	URL     *url.URL
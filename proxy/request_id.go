@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+)
+
+type requestIDKey struct{}
+
+type requestID struct {
+	header string
+	id     string
+}
+
+// NewRequestIDContext returns a context carrying the ID of the request, so it is sent to the backends in the
+// header with the given name
+func NewRequestIDContext(ctx context.Context, header, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID{header: header, id: id})
+}
+
+// RequestIDFromContext returns the ID of the request carried by the context, if any
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(requestIDKey{}).(requestID)
+	return v.id, ok
+}
+
+// addRequestIDHeader adds the ID of the request carried by the context to the headers of the request to the
+// backend. The headers are copied, since they are shared by the requests
+func addRequestIDHeader(ctx context.Context, r *http.Request) {
+	v, ok := ctx.Value(requestIDKey{}).(requestID)
+	if !ok {
+		return
+	}
+	headers := make(http.Header, len(r.Header)+1)
+	for k, vs := range r.Header {
+		headers[k] = vs
+	}
+	headers.Set(v.header, v.id)
+	r.Header = headers
+}
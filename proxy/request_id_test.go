@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestAddRequestIDHeader(t *testing.T) {
+	shared := http.Header{"Accept": []string{"application/json"}}
+	r, _ := http.NewRequest("GET", "http://example.com", nil)
+	r.Header = shared
+
+	addRequestIDHeader(context.Background(), r)
+	if r.Header.Get("X-Request-Id") != "" {
+		t.Error("unexpected request ID header")
+	}
+
+	addRequestIDHeader(NewRequestIDContext(context.Background(), "X-Request-Id", "abc"), r)
+	if r.Header.Get("X-Request-Id") != "abc" || r.Header.Get("Accept") != "application/json" {
+		t.Errorf("unexpected headers: %v", r.Header)
+	}
+	if shared.Get("X-Request-Id") != "" {
+		t.Error("the shared headers were modified")
+	}
+}
@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	retryKey = "retry"
+
+	defaultRetryAttempts   = 3
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 5 * time.Second
+)
+
+var defaultRetryStatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// ErrInvalidRetryAttempts is the error returned when the max number of attempts is lower than 1
+var ErrInvalidRetryAttempts = errors.New("the max number of attempts must be greater than 0")
+
+// DefaultRetryBudget is the budget shared by all the retry middlewares. It allows retrying up to a 10%
+// of the requests, with a reserve of 10 retries
+var DefaultRetryBudget = NewRetryBudget(0.1, 10)
+
+// NewRetryMiddleware creates a proxy middleware retrying the failed requests to the backend with an
+// exponential backoff with jitter. The network errors are always retried, and the received status codes
+// only if they are in the configured list. The requests with non idempotent methods are only retried
+// when they have an Idempotency-Key header. The retries are limited by the DefaultRetryBudget.
+//
+// The middleware is enabled with the 'retry' option of the backend proxy extra config, accepting the
+// 'max_attempts', 'backoff', 'max_backoff' and 'status_codes' options
+func NewRetryMiddleware(remote *config.Backend) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+	cfg, ok := extra[retryKey].(map[string]interface{})
+	if !ok {
+		return EmptyMiddleware, nil
+	}
+
+	r := retrier{
+		attempts:    defaultRetryAttempts,
+		backoff:     defaultRetryBackoff,
+		maxBackoff:  defaultRetryMaxBackoff,
+		statusCodes: map[int]bool{},
+		budget:      DefaultRetryBudget,
+	}
+	if v, ok := cfg["max_attempts"].(float64); ok {
+		r.attempts = int(v)
+	}
+	if r.attempts < 1 {
+		return nil, ErrInvalidRetryAttempts
+	}
+	for name, d := range map[string]*time.Duration{"backoff": &r.backoff, "max_backoff": &r.maxBackoff} {
+		v, ok := cfg[name].(string)
+		if !ok {
+			continue
+		}
+		var err error
+		if *d, err = time.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := cfg["status_codes"].([]interface{}); ok {
+		for _, code := range v {
+			if c, ok := code.(float64); ok {
+				r.statusCodes[int(c)] = true
+			}
+		}
+	} else {
+		for _, code := range defaultRetryStatusCodes {
+			r.statusCodes[code] = true
+		}
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			return r.call(ctx, next[0], request)
+		}
+	}, nil
+}
+
+type retrier struct {
+	attempts    int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	statusCodes map[int]bool
+	budget      *RetryBudget
+}
+
+func (r retrier) call(ctx context.Context, next Proxy, request *Request) (*Response, error) {
+	r.budget.deposit()
+	if r.attempts == 1 || !isIdempotent(request) {
+		return next(ctx, request)
+	}
+
+	// the body is buffered, so it can be sent in every attempt
+	var body []byte
+	if request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		req := request.Clone()
+		if request.Body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		recorder := &statusRecorder{}
+		resp, err := next(context.WithValue(ctx, statusRecorderKey{}, recorder), &req)
+
+		if attempt >= r.attempts || ctx.Err() != nil || !r.shouldRetry(recorder.code, err) || !r.budget.withdraw() {
+			return resp, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return resp, err
+		case <-time.After(r.wait(attempt)):
+		}
+	}
+}
+
+func (r retrier) shouldRetry(code int, err error) bool {
+	if r.statusCodes[code] {
+		return true
+	}
+	_, isNetworkError := err.(net.Error)
+	return isNetworkError
+}
+
+// wait returns a random duration between 0 and the exponential backoff of the attempt (full jitter)
+func (r retrier) wait(attempt int) time.Duration {
+	d := r.backoff
+	for i := 1; i < attempt && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+func isIdempotent(request *Request) bool {
+	switch request.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	_, ok := request.Headers["Idempotency-Key"]
+	return ok
+}
+
+// RetryBudget limits the number of retries to a ratio of the requests, so the retries can not multiply
+// the load of the backends when they are failing
+type RetryBudget struct {
+	mu        sync.Mutex
+	ratio     float64
+	maxTokens float64
+	tokens    float64
+}
+
+// NewRetryBudget creates a RetryBudget allowing a retry every 1/ratio requests. The reserve is the max
+// number of retries that can be accumulated, and it is available from the start
+func NewRetryBudget(ratio float64, reserve int) *RetryBudget {
+	return &RetryBudget{
+		ratio:     ratio,
+		maxTokens: float64(reserve),
+		tokens:    float64(reserve),
+	}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	b.tokens += b.ratio
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+	b.mu.Unlock()
+}
+
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type statusRecorderKey struct{}
+
+// statusRecorder collects the status code of the last backend response, so the outer middlewares
+// can inspect it even when the response is discarded by the status handler
+type statusRecorder struct {
+	code int
+}
+
+func recordStatusCode(ctx context.Context, code int) {
+	if r, ok := ctx.Value(statusRecorderKey{}).(*statusRecorder); ok {
+		r.code = code
+	}
+}
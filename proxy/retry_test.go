@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+func newRetryBackend(cfg map[string]interface{}) *config.Backend {
+	return &config.Backend{
+		Decoder:     encoding.JSONDecoder,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{retryKey: cfg}},
+	}
+}
+
+func TestNewRetryMiddleware_statusCodes(t *testing.T) {
+	calls := 0
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "supu" {
+			t.Errorf("unexpected body: %s", body)
+		}
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"supu":42}`))
+	}))
+	defer backendServer.Close()
+
+	backend := newRetryBackend(map[string]interface{}{"backoff": "1ms"})
+	mw, err := NewRetryMiddleware(backend)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p := mw(httpProxy(backend))
+
+	rpURL, _ := url.Parse(backendServer.URL)
+	resp, err := p(context.Background(), &Request{
+		Method: "PUT",
+		URL:    rpURL,
+		Body:   ioutil.NopCloser(bytes.NewBufferString("supu")),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if calls != 3 {
+		t.Errorf("unexpected number of calls: %d", calls)
+	}
+	if !resp.IsComplete || len(resp.Data) != 1 {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestNewRetryMiddleware_notRetried(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cfg     map[string]interface{}
+		request *Request
+	}{
+		{
+			name:    "status-not-listed",
+			cfg:     map[string]interface{}{"backoff": "1ms", "status_codes": []interface{}{502.0}},
+			request: &Request{Method: "GET"},
+		},
+		{
+			name:    "non-idempotent",
+			cfg:     map[string]interface{}{"backoff": "1ms"},
+			request: &Request{Method: "POST"},
+		},
+		{
+			name:    "single-attempt",
+			cfg:     map[string]interface{}{"max_attempts": 1.0},
+			request: &Request{Method: "GET"},
+		},
+	} {
+		calls := 0
+		backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+		backend := newRetryBackend(tc.cfg)
+		mw, err := NewRetryMiddleware(backend)
+		if err != nil {
+			t.Error(err)
+			backendServer.Close()
+			continue
+		}
+		p := mw(httpProxy(backend))
+
+		rpURL, _ := url.Parse(backendServer.URL)
+		tc.request.URL = rpURL
+		tc.request.Body = ioutil.NopCloser(&bytes.Buffer{})
+		if _, err := p(context.Background(), tc.request); err != ErrInvalidStatusCode {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if calls != 1 {
+			t.Errorf("%s: unexpected number of calls: %d", tc.name, calls)
+		}
+		backendServer.Close()
+	}
+}
+
+func TestNewRetryMiddleware_idempotencyKey(t *testing.T) {
+	backend := newRetryBackend(map[string]interface{}{"backoff": "1ms", "max_attempts": 2.0})
+	mw, _ := NewRetryMiddleware(backend)
+	calls := 0
+	networkErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return nil, networkErr
+	})
+	_, err := p(context.Background(), &Request{Method: "POST", Headers: map[string][]string{"Idempotency-Key": {"1"}}})
+	if err != networkErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("unexpected number of calls: %d", calls)
+	}
+}
+
+func TestNewRetryMiddleware_budget(t *testing.T) {
+	defer func(b *RetryBudget) { DefaultRetryBudget = b }(DefaultRetryBudget)
+	DefaultRetryBudget = NewRetryBudget(0, 2)
+
+	backend := newRetryBackend(map[string]interface{}{"backoff": "1ms", "max_attempts": 5.0})
+	mw, _ := NewRetryMiddleware(backend)
+	calls := 0
+	networkErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return nil, networkErr
+	})
+
+	p(context.Background(), &Request{Method: "GET"})
+	if calls != 3 {
+		t.Errorf("unexpected number of calls: %d", calls)
+	}
+	calls = 0
+	p(context.Background(), &Request{Method: "GET"})
+	if calls != 1 {
+		t.Errorf("unexpected number of calls: %d", calls)
+	}
+}
+
+func TestNewRetryMiddleware_canceled(t *testing.T) {
+	backend := newRetryBackend(map[string]interface{}{"backoff": "1s", "max_attempts": 5.0})
+	mw, _ := NewRetryMiddleware(backend)
+	calls := 0
+	networkErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		return nil, networkErr
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p(ctx, &Request{Method: "GET"}); err != networkErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if calls > 2 {
+		t.Errorf("unexpected number of calls: %d", calls)
+	}
+}
+
+func TestNewRetryMiddleware_koConfig(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"max_attempts": 0.0},
+		{"backoff": "bad"},
+		{"max_backoff": "bad"},
+	} {
+		if _, err := NewRetryMiddleware(newRetryBackend(cfg)); err == nil {
+			t.Errorf("expecting an error with the config %v", cfg)
+		}
+	}
+}
+
+func TestRetrier_wait(t *testing.T) {
+	r := retrier{backoff: 10 * time.Millisecond, maxBackoff: 30 * time.Millisecond}
+	for attempt, max := range []time.Duration{0, 10, 20, 30, 30} {
+		if attempt == 0 {
+			continue
+		}
+		for i := 0; i < 100; i++ {
+			if d := r.wait(attempt); d < 0 || d >= max*time.Millisecond {
+				t.Errorf("unexpected wait for the attempt %d: %s", attempt, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const rewriteKey = "rewrite"
+
+// RewriteRule replaces the requested path matching the regular expression. The {N} placeholders of the
+// replacement are the numbered capture groups and the {name} ones, the named groups
+type RewriteRule struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+}
+
+type rewriteRule struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+var rewritePlaceholder = regexp.MustCompile(`\{([0-9]+|[a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// NewURLRewriteMiddleware creates a request builder middleware rewriting the paths requested to the endpoint
+// with the rules of the backend. The first matching rule generates the path sent to the backend, and the
+// requests not matching any rule get the url_pattern of the backend. It returns nil if the backend does not
+// declare rewrite rules, so the default request builder can be used.
+//
+// The middleware is enabled with the 'rewrite' option of the backend proxy extra config
+func NewURLRewriteMiddleware(remote *config.Backend) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	v, ok := extra[rewriteKey]
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	cfg := []RewriteRule{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+
+	rules := make([]rewriteRule, len(cfg))
+	for i, r := range cfg {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range rewritePlaceholder.FindAllStringSubmatch(r.Replace, -1) {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				if n > re.NumSubexp() {
+					return nil, fmt.Errorf("the rewrite rule %s has no capture group %d", r.Match, n)
+				}
+			} else if !hasSubexp(re, m[1]) {
+				return nil, fmt.Errorf("the rewrite rule %s has no capture group %s", r.Match, m[1])
+			}
+		}
+		// the placeholders are translated into the template of the regexp package
+		replace := strings.Replace(r.Replace, "$", "$$", -1)
+		rules[i] = rewriteRule{re: re, replace: rewritePlaceholder.ReplaceAllString(replace, "$${$1}")}
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			r := request.Clone()
+			r.Method = remote.Method
+			for _, rule := range rules {
+				if match := rule.re.FindStringSubmatchIndex(request.Path); match != nil {
+					r.Path = string(rule.re.ExpandString(nil, rule.replace, request.Path, match))
+					return next[0](ctx, &r)
+				}
+			}
+			r.GeneratePath(remote.URLPattern)
+			return next[0](ctx, &r)
+		}
+	}, nil
+}
+
+func hasSubexp(re *regexp.Regexp, name string) bool {
+	for _, n := range re.SubexpNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
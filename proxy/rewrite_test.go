@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewURLRewriteMiddleware(t *testing.T) {
+	backend := &config.Backend{
+		Method:     "GET",
+		URLPattern: "/fallback/{{.Id}}",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			rewriteKey: []interface{}{
+				map[string]interface{}{"match": "^/v1/(.*)/old$", "replace": "/api/{1}"},
+				map[string]interface{}{"match": "^/v2/(?P<user>[^/]+)/items/([0-9]+)$", "replace": "/users/{user}/items?id={2}&cost=$5"},
+			},
+		}},
+	}
+	mw, err := NewURLRewriteMiddleware(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]string{
+		"/v1/users/42/old":  "/api/users/42",
+		"/v2/supu/items/42": "/users/supu/items?id=42&cost=$5",
+		"/v3/users":         "/fallback/42",
+	} {
+		var generated string
+		p := mw(func(_ context.Context, r *Request) (*Response, error) {
+			generated = r.Path
+			if r.Method != "GET" {
+				t.Errorf("%s: unexpected method: %s", path, r.Method)
+			}
+			return &Response{IsComplete: true}, nil
+		})
+		request := &Request{Method: "POST", Path: path, Params: map[string]string{"Id": "42"}}
+		if _, err := p(context.Background(), request); err != nil {
+			t.Error(err)
+		}
+		if generated != expected {
+			t.Errorf("%s: unexpected path: %s", path, generated)
+		}
+		if request.Path != path {
+			t.Errorf("%s: the original request has been modified: %s", path, request.Path)
+		}
+	}
+}
+
+func TestNewURLRewriteMiddleware_ko(t *testing.T) {
+	if mw, err := NewURLRewriteMiddleware(&config.Backend{}); mw != nil || err != nil {
+		t.Errorf("unexpected middleware: %v", err)
+	}
+	for _, rule := range []map[string]interface{}{
+		{"match": "^/v1/(.*$", "replace": "/api"},
+		{"match": "^/v1/(.*)$", "replace": "/api/{2}"},
+		{"match": "^/v1/(.*)$", "replace": "/api/{user}"},
+	} {
+		backend := &config.Backend{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			rewriteKey: []interface{}{rule},
+		}}}
+		if _, err := NewURLRewriteMiddleware(backend); err == nil {
+			t.Errorf("%v: error expected", rule)
+		}
+	}
+}
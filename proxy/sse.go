@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+// NewSSEFanInMiddleware creates a proxy middleware merging the server-sent events streams of all the
+// backends of the endpoint into a single one. The events are tagged with their source, the group of their
+// backend (or 'backend' and its index, if it has no group): the source replaces the event type of the
+// untyped events and prefixes the rest, so an 'update' event of the 'users' backend is sent as 'users.update'.
+// The backends not streaming events contribute their response data as a single event.
+//
+// The merged stream ends when all the streams of the backends end or the context is done
+func NewSSEFanInMiddleware(endpoint *config.EndpointConfig) Middleware {
+	sources := make([]string, len(endpoint.Backend))
+	for i, b := range endpoint.Backend {
+		sources[i] = b.Group
+		if sources[i] == "" {
+			sources[i] = "backend" + strconv.Itoa(i)
+		}
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) != len(sources) {
+			panic(ErrNotEnoughProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			responses := make([]*Response, len(next))
+			errs := make([]error, len(next))
+			wg := sync.WaitGroup{}
+			for i, n := range next {
+				wg.Add(1)
+				go func(i int, n Proxy) {
+					r := request.Clone()
+					responses[i], errs[i] = n(ctx, &r)
+					wg.Done()
+				}(i, n)
+			}
+			wg.Wait()
+
+			if allFailed(errs) {
+				return nil, errs[0]
+			}
+
+			isComplete := true
+			pr, pw := io.Pipe()
+			writer := &sseWriter{w: pw}
+			streams := sync.WaitGroup{}
+			for i, resp := range responses {
+				if errs[i] != nil || resp == nil {
+					isComplete = false
+					continue
+				}
+				streams.Add(1)
+				go func(source string, resp *Response) {
+					writer.copy(source, resp)
+					streams.Done()
+				}(sources[i], resp)
+			}
+			go func() {
+				streams.Wait()
+				pw.Close()
+			}()
+
+			return &Response{
+				Data:       map[string]interface{}{},
+				IsComplete: isComplete,
+				Io:         NewReadCloserWrapper(ctx, pr),
+				Metadata: Metadata{
+					Headers:    map[string][]string{"Content-Type": {"text/event-stream"}},
+					StatusCode: 200,
+				},
+			}, nil
+		}
+	}
+}
+
+func allFailed(errs []error) bool {
+	for _, err := range errs {
+		if err == nil {
+			return false
+		}
+	}
+	return len(errs) > 0
+}
+
+// sseWriter writes whole events into the merged stream, so the events of the sources are never interleaved
+type sseWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *sseWriter) write(e encoding.SSEEvent) error {
+	s.mu.Lock()
+	_, err := s.w.Write(e.Bytes())
+	s.mu.Unlock()
+	return err
+}
+
+func (s *sseWriter) copy(source string, resp *Response) {
+	if resp.Io == nil {
+		b, err := json.Marshal(resp.Data)
+		if err == nil {
+			s.write(encoding.SSEEvent{Event: source, Data: string(b)})
+		}
+		return
+	}
+	reader := encoding.NewSSEReader(resp.Io)
+	for {
+		e, err := reader.Read()
+		if err != nil {
+			return
+		}
+		if e.Event == "" {
+			e.Event = source
+		} else {
+			e.Event = source + "." + e.Event
+		}
+		if err := s.write(e); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewSSEFanInMiddleware(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Backend: []*config.Backend{{Group: "users"}, {}, {Group: "static"}, {Group: "broken"}},
+	}
+	stream := func(body string) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			return &Response{IsComplete: true, Io: strings.NewReader(body)}, nil
+		}
+	}
+	static := func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{IsComplete: true, Data: map[string]interface{}{"supu": "tupu"}}, nil
+	}
+	broken := func(_ context.Context, _ *Request) (*Response, error) {
+		return nil, errors.New("broken")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := NewSSEFanInMiddleware(endpoint)(
+		stream("event: update\ndata: 1\n\ndata: 2\n\n"),
+		stream(": comment\nid: 42\ndata: 3\n\n"),
+		static,
+		broken,
+	)
+	resp, err := p(ctx, &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.IsComplete {
+		t.Error("the response should be incomplete")
+	}
+	if resp.Metadata.Headers["Content-Type"][0] != "text/event-stream" {
+		t.Errorf("unexpected headers: %v", resp.Metadata.Headers)
+	}
+	b, err := ioutil.ReadAll(resp.Io)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(b)
+	for _, event := range []string{
+		"event: users.update\ndata: 1\n\n",
+		"event: users\ndata: 2\n\n",
+		"id: 42\nevent: backend1\ndata: 3\n\n",
+		"event: static\ndata: {\"supu\":\"tupu\"}\n\n",
+	} {
+		if !strings.Contains(body, event) {
+			t.Errorf("event %q not found in %q", event, body)
+		}
+	}
+	if strings.Index(body, "data: 1") > strings.Index(body, "data: 2") {
+		t.Errorf("the events of a source should keep their order: %q", body)
+	}
+
+	if _, err := NewSSEFanInMiddleware(&config.EndpointConfig{Backend: []*config.Backend{{}}})(broken)(ctx, &Request{}); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestNewSSEFanInMiddleware_wrongNumberOfProxies(t *testing.T) {
+	defer func() {
+		if r := recover(); r != ErrNotEnoughProxies {
+			t.Errorf("unexpected panic: %v", r)
+		}
+	}()
+	NewSSEFanInMiddleware(&config.EndpointConfig{Backend: []*config.Backend{{}, {}}})(explosiveProxy(t))
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	staticKey = "static"
+	// StaticAlways is the static strategy adding the static data to every response of the backend
+	StaticAlways = "always"
+	// StaticIfErrored is the static strategy returning the static data when the backend fails
+	StaticIfErrored = "errored"
+	// StaticIfIncomplete is the static strategy adding the static data when the backend fails or
+	// returns an incomplete response
+	StaticIfIncomplete = "incomplete"
+)
+
+// ErrInvalidStaticStrategy is the error returned when the static strategy is unknown
+var ErrInvalidStaticStrategy = errors.New("invalid static strategy")
+
+// NewStaticMiddleware creates a proxy middleware serving the static data defined in the backend
+// extra config, so the merged responses can degrade gracefully when the backend fails. The errors
+// covered by the static data are not propagated, but the returned responses are flagged as incomplete
+func NewStaticMiddleware(remote *config.Backend) Middleware {
+	strategy, data, ok := getStaticConfig(remote.ExtraConfig)
+	if !ok {
+		return EmptyMiddleware
+	}
+
+	var useStatic func(*Response, error) bool
+	switch strategy {
+	case StaticAlways:
+		useStatic = func(_ *Response, _ error) bool { return true }
+	case StaticIfErrored:
+		useStatic = func(r *Response, err error) bool { return err != nil || r == nil }
+	case StaticIfIncomplete:
+		useStatic = func(r *Response, err error) bool { return err != nil || r == nil || !r.IsComplete }
+	default:
+		panic(ErrInvalidStaticStrategy)
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			result, err := next[0](ctx, request)
+			if !useStatic(result, err) {
+				return result, err
+			}
+
+			if err != nil || result == nil {
+				result = &Response{Data: make(map[string]interface{}, len(data))}
+			} else if result.Data == nil {
+				result.Data = make(map[string]interface{}, len(data))
+			}
+			for k, v := range data {
+				result.Data[k] = v
+			}
+			return result, nil
+		}
+	}
+}
+
+func getStaticConfig(extra config.ExtraConfig) (string, map[string]interface{}, bool) {
+	cfg, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return "", nil, false
+	}
+	static, ok := cfg[staticKey].(map[string]interface{})
+	if !ok {
+		return "", nil, false
+	}
+	data, ok := static["data"].(map[string]interface{})
+	if !ok {
+		return "", nil, false
+	}
+	strategy, ok := static["strategy"].(string)
+	if !ok {
+		strategy = StaticIfErrored
+	}
+	return strategy, data, true
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const staticResponseKey = "static_response"
+
+// StaticResponseConfig defines the response of an endpoint not calling any backend
+type StaticResponseConfig struct {
+	// StatusCode of the response. By default, 302 for the redirects and 200 for the rest
+	StatusCode int `json:"status_code"`
+	// Redirect is the Location of the redirect. It is a template with the params (.Params), the query
+	// string (.Query) and the path (.Path) of the request
+	Redirect string `json:"redirect"`
+	// PreserveQuery appends the query string of the request to the Location of the redirect
+	PreserveQuery bool `json:"preserve_query"`
+	// Body of the response
+	Body string `json:"body"`
+	// Headers of the response
+	Headers map[string]string `json:"headers"`
+}
+
+// staticResponseContext is the data available to the templated Location of the redirects
+type staticResponseContext struct {
+	Params map[string]string
+	Query  url.Values
+	Path   string
+}
+
+// NewStaticResponseProxy creates a proxy returning the static response or the redirect of the endpoint,
+// without calling its backends. It returns nil if the endpoint does not declare a static response.
+//
+// The proxy is enabled with the 'static_response' option of the endpoint proxy extra config
+func NewStaticResponseProxy(endpoint *config.EndpointConfig) (Proxy, error) {
+	extra, ok := endpoint.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	v, ok := extra[staticResponseKey]
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	cfg := StaticResponseConfig{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string][]string, len(cfg.Headers)+1)
+	for k, v := range cfg.Headers {
+		headers[http.CanonicalHeaderKey(k)] = []string{v}
+	}
+	body := []byte(cfg.Body)
+
+	if cfg.Redirect == "" {
+		if cfg.StatusCode == 0 {
+			cfg.StatusCode = http.StatusOK
+		}
+		if cfg.StatusCode < 100 || cfg.StatusCode > 599 {
+			return nil, fmt.Errorf("invalid status code of the static response: %d", cfg.StatusCode)
+		}
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			return &Response{
+				IsComplete: true,
+				Metadata:   Metadata{StatusCode: cfg.StatusCode, Headers: headers},
+				Io:         bytes.NewReader(body),
+			}, nil
+		}, nil
+	}
+
+	switch cfg.StatusCode {
+	case 0:
+		cfg.StatusCode = http.StatusFound
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+	default:
+		return nil, fmt.Errorf("invalid status code of the redirect: %d", cfg.StatusCode)
+	}
+	tmpl, err := template.New(staticResponseKey).Funcs(templateFuncs).Option("missingkey=zero").Parse(cfg.Redirect)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(_ context.Context, request *Request) (*Response, error) {
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, staticResponseContext{Params: request.Params, Query: request.Query, Path: request.Path}); err != nil {
+			return nil, err
+		}
+		location := buf.String()
+		if cfg.PreserveQuery && len(request.Query) > 0 {
+			separator := "?"
+			if strings.Contains(location, "?") {
+				separator = "&"
+			}
+			location += separator + request.Query.Encode()
+		}
+
+		h := make(map[string][]string, len(headers)+1)
+		for k, v := range headers {
+			h[k] = v
+		}
+		h["Location"] = []string{location}
+		return &Response{
+			IsComplete: true,
+			Metadata:   Metadata{StatusCode: cfg.StatusCode, Headers: h},
+			Io:         bytes.NewReader(body),
+		}, nil
+	}, nil
+}
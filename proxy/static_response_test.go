@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewStaticResponseProxy(t *testing.T) {
+	p, err := NewStaticResponseProxy(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		staticResponseKey: map[string]interface{}{
+			"status_code": 410,
+			"body":        `{"message": "this endpoint is deprecated"}`,
+			"headers":     map[string]interface{}{"content-type": "application/json"},
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Metadata.StatusCode != 410 || !resp.IsComplete {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	if !reflect.DeepEqual(resp.Metadata.Headers, map[string][]string{"Content-Type": {"application/json"}}) {
+		t.Errorf("unexpected headers: %v", resp.Metadata.Headers)
+	}
+	if b, _ := ioutil.ReadAll(resp.Io); string(b) != `{"message": "this endpoint is deprecated"}` {
+		t.Errorf("unexpected body: %s", string(b))
+	}
+}
+
+func TestNewStaticResponseProxy_redirect(t *testing.T) {
+	p, err := NewStaticResponseProxy(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		staticResponseKey: map[string]interface{}{
+			"status_code":    301,
+			"redirect":       "https://example.com/users/{{.Params.Id}}?from={{.Path}}",
+			"preserve_query": true,
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &Request{Path: "/u/42", Params: map[string]string{"Id": "42"}, Query: url.Values{"page": {"2"}}}
+	for i := 0; i < 2; i++ {
+		resp, err := p(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Metadata.StatusCode != 301 {
+			t.Errorf("unexpected status code: %d", resp.Metadata.StatusCode)
+		}
+		if location := resp.Metadata.Headers["Location"]; len(location) != 1 || location[0] != "https://example.com/users/42?from=/u/42&page=2" {
+			t.Errorf("unexpected location: %v", location)
+		}
+	}
+}
+
+func TestNewStaticResponseProxy_ko(t *testing.T) {
+	if p, err := NewStaticResponseProxy(&config.EndpointConfig{}); p != nil || err != nil {
+		t.Errorf("unexpected proxy: %v", err)
+	}
+	for _, cfg := range []map[string]interface{}{
+		{"redirect": "/new", "status_code": 200},
+		{"redirect": "/new/{{.Params.Id"},
+		{"status_code": 1000},
+	} {
+		_, err := NewStaticResponseProxy(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			staticResponseKey: cfg,
+		}}})
+		if err == nil {
+			t.Errorf("%v: error expected", cfg)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewStaticMiddleware(t *testing.T) {
+	backendErr := errors.New("backend error")
+	static := map[string]interface{}{"supu": "static", "foo": "bar"}
+
+	okProxy := func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{Data: map[string]interface{}{"supu": 42}, IsComplete: true}, nil
+	}
+	incompleteProxy := func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{Data: map[string]interface{}{"supu": 42}}, nil
+	}
+	erroredProxy := func(_ context.Context, _ *Request) (*Response, error) { return nil, backendErr }
+
+	for _, tc := range []struct {
+		name     string
+		strategy string
+		next     Proxy
+		data     map[string]interface{}
+		complete bool
+		err      error
+	}{
+		{
+			name:     "always-ok",
+			strategy: StaticAlways,
+			next:     okProxy,
+			data:     map[string]interface{}{"supu": "static", "foo": "bar"},
+			complete: true,
+		},
+		{
+			name:     "always-errored",
+			strategy: StaticAlways,
+			next:     erroredProxy,
+			data:     static,
+		},
+		{
+			name:     "errored-ok",
+			strategy: StaticIfErrored,
+			next:     okProxy,
+			data:     map[string]interface{}{"supu": 42},
+			complete: true,
+		},
+		{
+			name:     "errored-incomplete",
+			strategy: StaticIfErrored,
+			next:     incompleteProxy,
+			data:     map[string]interface{}{"supu": 42},
+		},
+		{
+			name:     "errored-errored",
+			strategy: StaticIfErrored,
+			next:     erroredProxy,
+			data:     static,
+		},
+		{
+			name:     "incomplete-incomplete",
+			strategy: StaticIfIncomplete,
+			next:     incompleteProxy,
+			data:     static,
+		},
+		{
+			name:     "incomplete-ok",
+			strategy: StaticIfIncomplete,
+			next:     okProxy,
+			data:     map[string]interface{}{"supu": 42},
+			complete: true,
+		},
+	} {
+		backend := &config.Backend{
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+				staticKey: map[string]interface{}{"strategy": tc.strategy, "data": static},
+			}},
+		}
+		p := NewStaticMiddleware(backend)(tc.next)
+		out, err := p(context.Background(), &Request{})
+		if err != tc.err {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if out == nil {
+			t.Errorf("%s: the proxy returned a null result", tc.name)
+			continue
+		}
+		if !reflect.DeepEqual(out.Data, tc.data) {
+			t.Errorf("%s: unexpected response: %v", tc.name, out.Data)
+		}
+		if out.IsComplete != tc.complete {
+			t.Errorf("%s: unexpected completion flag", tc.name)
+		}
+	}
+}
+
+func TestNewStaticMiddleware_notConfigured(t *testing.T) {
+	backendErr := errors.New("backend error")
+	p := NewStaticMiddleware(&config.Backend{})(func(_ context.Context, _ *Request) (*Response, error) {
+		return nil, backendErr
+	})
+	if _, err := p(context.Background(), &Request{}); err != backendErr {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewStaticMiddleware_unknownStrategy(t *testing.T) {
+	defer func() {
+		if r := recover(); r != ErrInvalidStaticStrategy {
+			t.Errorf("The code did not panic as expected: %v", r)
+		}
+	}()
+	NewStaticMiddleware(&config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			staticKey: map[string]interface{}{"strategy": "unknown", "data": map[string]interface{}{}},
+		}},
+	})
+}
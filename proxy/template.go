@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"text/template"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+const templateName = "template"
+
+// ErrInvalidTemplate is the error returned when the configured template is not a string
+var ErrInvalidTemplate = errors.New("the response template must be a string")
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+func newTemplateMiddleware(cfg interface{}, _ *config.EndpointConfig) (Middleware, error) {
+	text, ok := cfg.(string)
+	if !ok {
+		return nil, ErrInvalidTemplate
+	}
+	return NewTemplateMiddleware(text)
+}
+
+// NewTemplateMiddleware creates a proxy middleware replacing the data of the response with the
+// JSON document generated by rendering the received template with it. The 'json' function should
+// be used for inserting values into the document, so they get properly encoded
+func NewTemplateMiddleware(text string) (Middleware, error) {
+	tmpl, err := template.New(templateName).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			resp, err := next[0](ctx, request)
+			if resp == nil {
+				return resp, err
+			}
+
+			buf := new(bytes.Buffer)
+			if terr := tmpl.Execute(buf, resp.Data); terr != nil {
+				return nil, terr
+			}
+			var data interface{}
+			d := json.NewDecoder(buf)
+			d.UseNumber()
+			if derr := d.Decode(&data); derr != nil {
+				return nil, derr
+			}
+
+			r := *resp
+			switch v := data.(type) {
+			case map[string]interface{}:
+				r.Data = v
+			default:
+				r.Data = map[string]interface{}{"collection": v}
+			}
+			return &r, err
+		}
+	}, nil
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewTemplateMiddleware(t *testing.T) {
+	mw, err := NewTemplateMiddleware(`{
+		"full_name": {{ json (printf "%s %s" .user.name .user.surname) }},
+		"shout": {{ json (upper .user.name) }},
+		"adult": {{ if ge .user.age 18.0 }}true{{ else }}false{{ end }},
+		"tags": {{ json .tags }}
+	}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{
+			Data: map[string]interface{}{
+				"user": map[string]interface{}{"name": "supu", "surname": "tupu", "age": 21.0},
+				"tags": []interface{}{"a", "b"},
+			},
+			IsComplete: true,
+		}, nil
+	})
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !resp.IsComplete || len(resp.Data) != 4 {
+		t.Errorf("unexpected response: %v", resp)
+	}
+	if resp.Data["full_name"] != "supu tupu" || resp.Data["shout"] != "SUPU" || resp.Data["adult"] != true {
+		t.Errorf("unexpected response: %v", resp.Data)
+	}
+	if tags, ok := resp.Data["tags"].([]interface{}); !ok || len(tags) != 2 {
+		t.Errorf("unexpected tags: %v", resp.Data["tags"])
+	}
+}
+
+func TestNewTemplateMiddleware_collection(t *testing.T) {
+	mw, err := NewTemplateMiddleware(`[{{ range $i, $e := .collection }}{{ if $i }},{{ end }}{{ json $e.id }}{{ end }}]`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{
+			Data: map[string]interface{}{
+				"collection": []interface{}{
+					map[string]interface{}{"id": json.Number("1")},
+					map[string]interface{}{"id": json.Number("2")},
+				},
+			},
+			IsComplete: true,
+		}, nil
+	})
+	resp, err := p(context.Background(), &Request{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	collection, ok := resp.Data["collection"].([]interface{})
+	if !ok || len(collection) != 2 || collection[1].(json.Number).String() != "2" {
+		t.Errorf("unexpected response: %v", resp.Data)
+	}
+}
+
+func TestNewTemplateMiddleware_ko(t *testing.T) {
+	if _, err := NewTemplateMiddleware(`{{ .unclosed`); err == nil {
+		t.Error("expecting a parsing error")
+	}
+	mw, err := NewTemplateMiddleware(`{"not": "json"`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p := mw(func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{Data: map[string]interface{}{}}, nil
+	})
+	if _, err := p(context.Background(), &Request{}); err == nil {
+		t.Error("expecting a decoding error")
+	}
+}
+
+func TestDefaultFactory_template(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Backend: []*config.Backend{{Host: []string{"http://example.com"}}},
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{"template": `{"ok": true}`},
+		},
+	}
+	factory := NewDefaultFactory(func(_ *config.Backend) Proxy {
+		return func(_ context.Context, _ *Request) (*Response, error) {
+			return &Response{Data: map[string]interface{}{"supu": 42}, IsComplete: true}, nil
+		}
+	}, nil)
+	p, err := factory.New(endpoint)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp, err := p(context.Background(), &Request{Params: map[string]string{}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(resp.Data) != 1 || resp.Data["ok"] != true {
+		t.Errorf("unexpected response: %v", resp.Data)
+	}
+
+	endpoint.ExtraConfig[Namespace] = map[string]interface{}{"template": 42}
+	if _, err := factory.New(endpoint); err != ErrInvalidTemplate {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// BackendTiming is the latency of a request sent to a backend
+type BackendTiming struct {
+	// Backend is the URL pattern of the backend
+	Backend    string
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+	// Connect is the time spent opening a new connection to the backend. It is zero when a connection of the
+	// pool is reused
+	Connect time.Duration
+	// TTFB is the time until the first byte of the response of the backend is received
+	TTFB time.Duration
+	// Decode and Format are the time spent decoding the body of the response and applying the formatters
+	Decode time.Duration
+	Format time.Duration
+}
+
+// Timings collects the latencies of the requests sent to the backends while a request is proxied
+type Timings struct {
+	mu      sync.Mutex
+	timings []BackendTiming
+	merge   time.Duration
+}
+
+type timingsKey struct{}
+
+// NewTimingsContext returns a copy of the context recording the latencies of the backends into the timings
+func NewTimingsContext(ctx context.Context, t *Timings) context.Context {
+	return context.WithValue(ctx, timingsKey{}, t)
+}
+
+// TimingsFromContext returns the timings of the context, if any
+func TimingsFromContext(ctx context.Context) (*Timings, bool) {
+	t, ok := ctx.Value(timingsKey{}).(*Timings)
+	return t, ok
+}
+
+// Backends returns the latencies recorded, in order of completion
+func (t *Timings) Backends() []BackendTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]BackendTiming{}, t.timings...)
+}
+
+// Merge returns the time spent by the merge of the responses of the backends, including their calls
+func (t *Timings) Merge() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.merge
+}
+
+func (t *Timings) add(bt BackendTiming) {
+	t.mu.Lock()
+	t.timings = append(t.timings, bt)
+	t.mu.Unlock()
+}
+
+func (t *Timings) addMerge(d time.Duration) {
+	t.mu.Lock()
+	t.merge += d
+	t.mu.Unlock()
+}
+
+// backendPhases collects the phases of a request to a backend, filled by the HTTP proxy and the response
+// parser. The callbacks of the client trace can run after the call returns, so it is locked
+type backendPhases struct {
+	mu                            sync.Mutex
+	connect, ttfb, decode, format time.Duration
+}
+
+type backendPhasesKey struct{}
+
+func backendPhasesFromContext(ctx context.Context) (*backendPhases, bool) {
+	p, ok := ctx.Value(backendPhasesKey{}).(*backendPhases)
+	return p, ok
+}
+
+// withClientTrace returns a copy of the context recording the connect and the time to first byte of the
+// request into the phases of the context, if any
+func withClientTrace(ctx context.Context) context.Context {
+	p, ok := backendPhasesFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	start := time.Now()
+	var connectStart time.Time
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		ConnectStart: func(_, _ string) {
+			p.mu.Lock()
+			connectStart = time.Now()
+			p.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, _ error) {
+			p.mu.Lock()
+			if !connectStart.IsZero() {
+				p.connect = time.Since(connectStart)
+			}
+			p.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			p.mu.Lock()
+			p.ttfb = time.Since(start)
+			p.mu.Unlock()
+		},
+	})
+}
+
+// recordDecoding records the time spent decoding and formatting the response of the backend into the phases
+// of the context, if any
+func recordDecoding(ctx context.Context, decode, format time.Duration) {
+	p, ok := backendPhasesFromContext(ctx)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	p.decode += decode
+	p.format += format
+	p.mu.Unlock()
+}
+
+// NewTimingsMiddleware creates a proxy middleware recording the latency of the requests sent to the backend
+// into the timings of the context, if any, with the phases reported by the HTTP proxy. Every attempt of the
+// retries and the hedged requests is recorded
+func NewTimingsMiddleware(remote *config.Backend) Middleware {
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			t, ok := TimingsFromContext(ctx)
+			if !ok {
+				return next[0](ctx, request)
+			}
+			p := &backendPhases{}
+			start := time.Now()
+			resp, err := next[0](context.WithValue(ctx, backendPhasesKey{}, p), request)
+			bt := BackendTiming{Backend: remote.URLPattern, Duration: time.Since(start), Err: err}
+			if resp != nil {
+				bt.StatusCode = resp.Metadata.StatusCode
+			}
+			p.mu.Lock()
+			bt.Connect, bt.TTFB, bt.Decode, bt.Format = p.connect, p.ttfb, p.decode, p.format
+			p.mu.Unlock()
+			t.add(bt)
+			return resp, err
+		}
+	}
+}
+
+// newMergeTimingsMiddleware records the time spent by the proxies returned by the middleware as the merge
+// duration of the timings of the context, if any
+func newMergeTimingsMiddleware(mw Middleware) Middleware {
+	return func(next ...Proxy) Proxy {
+		p := mw(next...)
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			t, ok := TimingsFromContext(ctx)
+			if !ok {
+				return p(ctx, request)
+			}
+			start := time.Now()
+			resp, err := p(ctx, request)
+			t.addMerge(time.Since(start))
+			return resp, err
+		}
+	}
+}
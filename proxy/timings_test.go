@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+func TestNewTimingsMiddleware(t *testing.T) {
+	calls := 0
+	p := NewTimingsMiddleware(&config.Backend{URLPattern: "/users"})(func(_ context.Context, _ *Request) (*Response, error) {
+		calls++
+		if calls > 2 {
+			return nil, errors.New("unreachable")
+		}
+		return &Response{IsComplete: true, Metadata: Metadata{StatusCode: 200}}, nil
+	})
+
+	// the requests without timings are not recorded
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Fatal(err)
+	}
+
+	timings := &Timings{}
+	ctx := NewTimingsContext(context.Background(), timings)
+	if _, err := p(ctx, &Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p(ctx, &Request{}); err == nil {
+		t.Error("error expected")
+	}
+
+	backends := timings.Backends()
+	if len(backends) != 2 {
+		t.Fatalf("unexpected timings: %v", backends)
+	}
+	if backends[0].Backend != "/users" || backends[0].StatusCode != 200 || backends[0].Err != nil {
+		t.Errorf("unexpected timing: %+v", backends[0])
+	}
+	if backends[1].StatusCode != 0 || backends[1].Err == nil {
+		t.Errorf("unexpected timing: %+v", backends[1])
+	}
+}
+
+func TestNewTimingsMiddleware_phases(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		fmt.Fprint(w, `{"supu":42}`)
+	}))
+	defer backendServer.Close()
+
+	backend := &config.Backend{URLPattern: "/supu", Decoder: encoding.JSONDecoder}
+	// a new transport, so the connection is not reused
+	p := NewTimingsMiddleware(backend)(HTTPProxyFactory(&http.Client{Transport: &http.Transport{}})(backend))
+
+	u, _ := url.Parse(backendServer.URL)
+	timings := &Timings{}
+	ctx := NewTimingsContext(context.Background(), timings)
+	if _, err := p(ctx, &Request{Method: "GET", URL: u, Body: newDummyReadCloser("")}); err != nil {
+		t.Fatal(err)
+	}
+
+	backends := timings.Backends()
+	if len(backends) != 1 {
+		t.Fatalf("unexpected timings: %v", backends)
+	}
+	bt := backends[0]
+	if bt.Connect <= 0 || bt.TTFB < 5*time.Millisecond || bt.Decode <= 0 || bt.Format <= 0 {
+		t.Errorf("unexpected phases: %+v", bt)
+	}
+	if bt.TTFB > bt.Duration || bt.Connect > bt.TTFB {
+		t.Errorf("inconsistent phases: %+v", bt)
+	}
+}
+
+func TestNewMergeTimingsMiddleware(t *testing.T) {
+	p := newMergeTimingsMiddleware(func(next ...Proxy) Proxy { return next[0] })(func(_ context.Context, _ *Request) (*Response, error) {
+		time.Sleep(time.Millisecond)
+		return &Response{}, nil
+	})
+	timings := &Timings{}
+	if _, err := p(NewTimingsContext(context.Background(), timings), &Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if timings.Merge() < time.Millisecond {
+		t.Errorf("unexpected merge duration: %s", timings.Merge())
+	}
+}
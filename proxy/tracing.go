@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// TracerName is the name of the OpenTelemetry tracer of the gateway
+const TracerName = "github.com/devopsfaith/krakend"
+
+// Names of the spans of the proxy layer
+const (
+	SpanMerge  = "merge"
+	SpanDecode = "decode"
+	SpanFormat = "format"
+)
+
+// tracer returns the tracer of the global provider, so the spans are recorded once it is registered
+func tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// endSpan records the error, if any, and ends the span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// newSpanMiddleware wraps the proxy returned by the middleware with a span
+func newSpanMiddleware(name string, mw Middleware) Middleware {
+	return func(next ...Proxy) Proxy {
+		p := mw(next...)
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			ctx, span := tracer().Start(ctx, name)
+			resp, err := p(ctx, request)
+			endSpan(span, err)
+			return resp, err
+		}
+	}
+}
+
+// NewTracingMiddleware creates a proxy middleware recording a client span for every request sent to the
+// backend, and propagating the trace context to it with the headers of the global propagator. Every attempt
+// of the retries and the hedged requests gets its own span
+func NewTracingMiddleware(remote *config.Backend) Middleware {
+	name := "backend " + remote.URLPattern
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			ctx, span := tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+			if !span.IsRecording() && !span.SpanContext().IsValid() {
+				span.End()
+				return next[0](ctx, request)
+			}
+			span.SetAttributes(
+				attribute.String("http.request.method", request.Method),
+				attribute.String("krakend.backend", remote.URLPattern),
+			)
+			if request.URL != nil {
+				span.SetAttributes(attribute.String("url.full", request.URL.String()))
+			}
+
+			// the headers of the request are shared with the rest of the backends
+			r := request.Clone()
+			r.Headers = make(map[string][]string, len(request.Headers)+2)
+			for k, vs := range request.Headers {
+				r.Headers[k] = vs
+			}
+			otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(http.Header(r.Headers)))
+
+			resp, err := next[0](ctx, &r)
+			if resp != nil && resp.Metadata.StatusCode != 0 {
+				span.SetAttributes(attribute.Int("http.response.status_code", resp.Metadata.StatusCode))
+			}
+			endSpan(span, err)
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+func registerTestTracer(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func TestNewTracingMiddleware(t *testing.T) {
+	recorder := registerTestTracer(t)
+
+	headers := map[string][]string{"Accept": {"application/json"}}
+	var traceparent string
+	p := NewTracingMiddleware(&config.Backend{URLPattern: "/users"})(func(_ context.Context, r *Request) (*Response, error) {
+		traceparent = http.Header(r.Headers).Get("traceparent")
+		if r.Headers["Accept"][0] != "application/json" {
+			t.Errorf("unexpected headers: %v", r.Headers)
+		}
+		return &Response{IsComplete: true, Metadata: Metadata{StatusCode: 200}}, errors.New("partial")
+	})
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	if _, err := p(ctx, &Request{Method: "GET", Headers: headers}); err == nil {
+		t.Error("error expected")
+	}
+	parent.End()
+
+	if _, ok := headers["Traceparent"]; ok {
+		t.Error("the headers of the request were modified")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("unexpected spans: %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "backend /users" || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("unexpected span: %s", span.Name())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("unexpected status: %v", span.Status())
+	}
+	expected := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if traceparent != expected {
+		t.Errorf("unexpected traceparent: %s", traceparent)
+	}
+}
+
+func TestDefaultHTTPResponseParserFactory_spans(t *testing.T) {
+	recorder := registerTestTracer(t)
+
+	rp := DefaultHTTPResponseParserFactory(HTTPResponseParserConfig{
+		Decoder:         encoding.NewJSONDecoder(false),
+		EntityFormatter: EntityFormatterFunc(func(r Response) Response { return r }),
+	})
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	if _, err := rp(ctx, &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(`{"a":1}`))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp(ctx, &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(`{`))}); err == nil {
+		t.Error("error expected")
+	}
+	parent.End()
+
+	names := []string{}
+	for _, s := range recorder.Ended() {
+		names = append(names, s.Name()+":"+s.Status().Code.String())
+	}
+	if strings.Join(names, ",") != "decode:Unset,format:Unset,decode:Error,parent:Unset" {
+		t.Errorf("unexpected spans: %v", names)
+	}
+}
+
+func TestNewMergeDataMiddleware_span(t *testing.T) {
+	recorder := registerTestTracer(t)
+
+	endpoint := &config.EndpointConfig{Backend: []*config.Backend{{}, {}}, Timeout: time.Second}
+	backend := func(_ context.Context, _ *Request) (*Response, error) {
+		return &Response{Data: map[string]interface{}{"a": 1}, IsComplete: true}, nil
+	}
+	p := NewMergeDataMiddleware(endpoint)(backend, backend)
+	if _, err := p(context.Background(), &Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if spans := recorder.Ended(); len(spans) != 1 || spans[0].Name() != SpanMerge {
+		t.Errorf("unexpected spans: %v", spans)
+	}
+}
@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+const trafficSplitKey = "split"
+
+var (
+	// ErrNoSplitGroups is the error returned when the traffic split has no groups with hosts
+	ErrNoSplitGroups = errors.New("the traffic split requires at least a group with hosts")
+	// ErrInvalidSplitWeight is the error returned when a weight of the traffic split is negative or all
+	// of them are zero
+	ErrInvalidSplitWeight = errors.New("the weights of the traffic split must be positive")
+)
+
+// NewTrafficSplitMiddleware creates a proxy middleware splitting the requests between several groups of
+// hosts according to their weights, with a round robin balancer in every group. The groups are declared
+// in the 'groups' list of the 'split' option of the backend proxy extra config, with their 'host' list
+// and their 'weight'.
+//
+// With the 'sticky' option, the group is selected with the hash of the value of a 'header' or a 'cookie'
+// of the request, so every client always reaches the same group. The header (or the Cookie one) must be
+// in the headers_to_pass list of the endpoint. The requests without the value are assigned randomly.
+//
+// It returns a nil middleware when the traffic split is not configured, so the default balancer can be used
+func NewTrafficSplitMiddleware(remote *config.Backend) (Middleware, error) {
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	cfg, ok := extra[trafficSplitKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	groups := []splitGroup{}
+	total := 0.0
+	rawGroups, _ := cfg["groups"].([]interface{})
+	for _, g := range rawGroups {
+		group, ok := g.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hosts := []string{}
+		rawHosts, _ := group["host"].([]interface{})
+		for _, h := range rawHosts {
+			if host, ok := h.(string); ok {
+				hosts = append(hosts, host)
+			}
+		}
+		if len(hosts) == 0 {
+			continue
+		}
+		weight, _ := group["weight"].(float64)
+		if weight < 0 {
+			return nil, ErrInvalidSplitWeight
+		}
+		total += weight
+		groups = append(groups, splitGroup{hosts: hosts, weight: weight})
+	}
+	if len(groups) == 0 {
+		return nil, ErrNoSplitGroups
+	}
+	if total == 0 {
+		return nil, ErrInvalidSplitWeight
+	}
+
+	stickyKey := func(_ *Request) (string, bool) { return "", false }
+	if sticky, ok := cfg["sticky"].(map[string]interface{}); ok {
+		if name, ok := sticky["header"].(string); ok {
+			name = http.CanonicalHeaderKey(name)
+			stickyKey = func(r *Request) (string, bool) {
+				v := r.Headers[name]
+				if len(v) == 0 || v[0] == "" {
+					return "", false
+				}
+				return v[0], true
+			}
+		} else if name, ok := sticky["cookie"].(string); ok {
+			stickyKey = func(r *Request) (string, bool) {
+				c, err := (&http.Request{Header: r.Headers}).Cookie(name)
+				if err != nil || c.Value == "" {
+					return "", false
+				}
+				return c.Value, true
+			}
+		}
+	}
+
+	return func(next ...Proxy) Proxy {
+		if len(next) > 1 {
+			panic(ErrTooManyProxies)
+		}
+		proxies := make([]Proxy, len(groups))
+		for i, g := range groups {
+			proxies[i] = NewRoundRobinLoadBalancedMiddlewareWithSubscriber(sd.FixedSubscriber(g.hosts))(next[0])
+		}
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			point := rand.Float64() * total
+			if key, ok := stickyKey(request); ok {
+				h := fnv.New32a()
+				h.Write([]byte(key))
+				point = float64(h.Sum32()) / (1 << 32) * total
+			}
+			return proxies[selectSplitGroup(groups, point)](ctx, request)
+		}
+	}, nil
+}
+
+type splitGroup struct {
+	hosts  []string
+	weight float64
+}
+
+// selectSplitGroup returns the index of the group containing the point in the [0, total weight) range
+func selectSplitGroup(groups []splitGroup, point float64) int {
+	for i, g := range groups {
+		if point < g.weight {
+			return i
+		}
+		point -= g.weight
+	}
+	// the rounding errors can leave the point out of the range
+	for i := len(groups) - 1; i > 0; i-- {
+		if groups[i].weight > 0 {
+			return i
+		}
+	}
+	return 0
+}
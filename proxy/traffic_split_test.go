@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func splitBackend(cfg map[string]interface{}) *config.Backend {
+	return &config.Backend{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{trafficSplitKey: cfg}},
+	}
+}
+
+func hostRecorder(hosts map[string]int) Proxy {
+	return func(_ context.Context, r *Request) (*Response, error) {
+		hosts[r.URL.Host]++
+		return &Response{IsComplete: true}, nil
+	}
+}
+
+func TestNewTrafficSplitMiddleware_disabled(t *testing.T) {
+	mw, err := NewTrafficSplitMiddleware(&config.Backend{})
+	if err != nil || mw != nil {
+		t.Errorf("unexpected result: %v %v", mw, err)
+	}
+}
+
+func TestNewTrafficSplitMiddleware_koConfig(t *testing.T) {
+	for i, tc := range []struct {
+		cfg map[string]interface{}
+		err error
+	}{
+		{cfg: map[string]interface{}{}, err: ErrNoSplitGroups},
+		{cfg: map[string]interface{}{"groups": []interface{}{map[string]interface{}{"weight": 1.0}}}, err: ErrNoSplitGroups},
+		{cfg: map[string]interface{}{"groups": []interface{}{
+			map[string]interface{}{"host": []interface{}{"http://a"}, "weight": -1.0},
+		}}, err: ErrInvalidSplitWeight},
+		{cfg: map[string]interface{}{"groups": []interface{}{
+			map[string]interface{}{"host": []interface{}{"http://a"}},
+		}}, err: ErrInvalidSplitWeight},
+	} {
+		if _, err := NewTrafficSplitMiddleware(splitBackend(tc.cfg)); err != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestNewTrafficSplitMiddleware_weights(t *testing.T) {
+	mw, err := NewTrafficSplitMiddleware(splitBackend(map[string]interface{}{"groups": []interface{}{
+		map[string]interface{}{"host": []interface{}{"http://stable1", "http://stable2"}, "weight": 90.0},
+		map[string]interface{}{"host": []interface{}{"http://canary"}, "weight": 10.0},
+	}}))
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	hosts := map[string]int{}
+	p := mw(hostRecorder(hosts))
+	for i := 0; i < 1000; i++ {
+		p(context.Background(), &Request{Path: "/"})
+	}
+	if canary := hosts["canary"]; canary < 50 || canary > 150 {
+		t.Errorf("unexpected canary requests: %d", canary)
+	}
+	if hosts["stable1"]+hosts["stable2"]+hosts["canary"] != 1000 {
+		t.Errorf("unexpected distribution: %v", hosts)
+	}
+	if hosts["stable1"]-hosts["stable2"] > 1 || hosts["stable2"]-hosts["stable1"] > 1 {
+		t.Errorf("the group is not balanced: %v", hosts)
+	}
+}
+
+func TestNewTrafficSplitMiddleware_sticky(t *testing.T) {
+	groups := []interface{}{
+		map[string]interface{}{"host": []interface{}{"http://stable"}, "weight": 50.0},
+		map[string]interface{}{"host": []interface{}{"http://canary"}, "weight": 50.0},
+	}
+	for _, tc := range []struct {
+		name    string
+		sticky  map[string]interface{}
+		headers func(client string) map[string][]string
+	}{
+		{
+			name:    "header",
+			sticky:  map[string]interface{}{"header": "x-user"},
+			headers: func(client string) map[string][]string { return map[string][]string{"X-User": {client}} },
+		},
+		{
+			name:    "cookie",
+			sticky:  map[string]interface{}{"cookie": "session"},
+			headers: func(client string) map[string][]string { return map[string][]string{"Cookie": {"session=" + client}} },
+		},
+	} {
+		mw, err := NewTrafficSplitMiddleware(splitBackend(map[string]interface{}{"groups": groups, "sticky": tc.sticky}))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+		for _, client := range []string{"a", "b", "c", "d"} {
+			hosts := map[string]int{}
+			p := mw(hostRecorder(hosts))
+			for i := 0; i < 20; i++ {
+				p(context.Background(), &Request{Path: "/", Headers: tc.headers(client)})
+			}
+			if len(hosts) != 1 {
+				t.Errorf("%s: the client %s was not sticky: %v", tc.name, client, hosts)
+			}
+		}
+	}
+}
+
+func TestSelectSplitGroup(t *testing.T) {
+	groups := []splitGroup{{weight: 1}, {weight: 0}, {weight: 2}, {weight: 0}}
+	for point, expected := range map[float64]int{0: 0, 0.99: 0, 1: 2, 2.5: 2, 3: 2, 10: 2} {
+		if i := selectSplitGroup(groups, point); i != expected {
+			t.Errorf("%f: unexpected group %d", point, i)
+		}
+	}
+}
@@ -0,0 +1,473 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+const (
+	// DefaultWebSocketPingInterval is the default time between the pings sent to both peers of a relay
+	DefaultWebSocketPingInterval = 30 * time.Second
+	// DefaultWebSocketMaxMessageSize is the default max size of the relayed messages, in bytes
+	DefaultWebSocketMaxMessageSize = 1 << 20
+	// DefaultWebSocketWriteTimeout is the default time to write a frame
+	DefaultWebSocketWriteTimeout = 10 * time.Second
+
+	// WebSocketTextMessage is the type of the text messages
+	WebSocketTextMessage = 0x1
+	// WebSocketBinaryMessage is the type of the binary messages
+	WebSocketBinaryMessage = 0x2
+
+	webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpContinuation = 0x0
+	wsOpText         = WebSocketTextMessage
+	wsOpBinary       = WebSocketBinaryMessage
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal        = 1000
+	wsCloseGoingAway     = 1001
+	wsCloseProtocolErr   = 1002
+	wsCloseMessageTooBig = 1009
+)
+
+var (
+	// ErrWebSocketHandshake is the error returned when the backend rejects the websocket handshake
+	ErrWebSocketHandshake = errors.New("websocket: bad handshake")
+	// ErrWebSocketMessageTooBig is the error returned when a relayed message exceeds the max size
+	ErrWebSocketMessageTooBig = errors.New("websocket: message too big")
+	// ErrWebSocketProtocol is the error returned when a peer sends a malformed frame
+	ErrWebSocketProtocol = errors.New("websocket: protocol error")
+	// ErrWebSocketClosed is the error returned when the peer closes the connection
+	ErrWebSocketClosed = errors.New("websocket: closed")
+)
+
+// WebSocketConfig defines the relay of the websocket connections
+type WebSocketConfig struct {
+	// PingInterval is the time between the pings sent to both peers. A zero value disables the pings
+	PingInterval time.Duration
+	// PongTimeout is the max time without receiving any frame (the pongs included) from a peer before
+	// closing the connection. A zero value disables the timeout
+	PongTimeout time.Duration
+	// MaxMessageSize is the max size of the relayed messages, in bytes. The larger messages close the
+	// connections with the 1009 status
+	MaxMessageSize int64
+	// WriteTimeout is the max time to write a frame
+	WriteTimeout time.Duration
+}
+
+// WebSocketConn is a websocket connection relaying frames between the client and the backend
+type WebSocketConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	client bool
+	wmu    sync.Mutex
+}
+
+// NewWebSocketConn wraps a connection after a successful handshake. The reader must be the one used for
+// the handshake, so the frames already buffered are not lost. The client connections (the ones dialed to
+// the backends) mask the frames they send
+func NewWebSocketConn(conn net.Conn, r *bufio.Reader, client bool) *WebSocketConn {
+	if r == nil {
+		r = bufio.NewReader(conn)
+	}
+	return &WebSocketConn{conn: conn, r: r, client: client}
+}
+
+// Close closes the underlying connection without sending a close frame
+func (c *WebSocketConn) Close() error {
+	return c.conn.Close()
+}
+
+// ReadMessage reads the next text or binary message, joining its fragments and answering the pings. It
+// returns ErrWebSocketClosed when the peer closes the connection
+func (c *WebSocketConn) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	message := []byte{}
+	for {
+		f, err := c.readFrame(0)
+		if err != nil {
+			return 0, nil, err
+		}
+		switch f.opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsFrame{fin: true, opcode: wsOpPong, payload: f.payload}, 0); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			return 0, nil, ErrWebSocketClosed
+		case wsOpText, wsOpBinary:
+			messageType = int(f.opcode)
+			message = f.payload
+		case wsOpContinuation:
+			message = append(message, f.payload...)
+		default:
+			return 0, nil, ErrWebSocketProtocol
+		}
+		if f.fin {
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage sends the message in a single frame
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	return c.writeFrame(wsFrame{fin: true, opcode: byte(messageType), payload: data}, 0)
+}
+
+type wsFrame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// readFrame reads the next frame, rejecting the data frames larger than max bytes
+func (c *WebSocketConn) readFrame(max int64) (wsFrame, error) {
+	f := wsFrame{}
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return f, err
+	}
+	f.fin = header[0]&0x80 != 0
+	f.opcode = header[0] & 0x0f
+	// no extension is negotiated, so the reserved bits must be zero
+	if header[0]&0x70 != 0 {
+		return f, ErrWebSocketProtocol
+	}
+	masked := header[1]&0x80 != 0
+	if masked == c.client {
+		return f, ErrWebSocketProtocol
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		b := make([]byte, 2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return f, err
+		}
+		length = int64(binary.BigEndian.Uint16(b))
+	case 127:
+		b := make([]byte, 8)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return f, err
+		}
+		length = int64(binary.BigEndian.Uint64(b))
+	}
+	if f.opcode >= wsOpClose && (length > 125 || !f.fin) {
+		return f, ErrWebSocketProtocol
+	}
+	if length < 0 || (max > 0 && length > max) {
+		return f, ErrWebSocketMessageTooBig
+	}
+
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(c.r, mask); err != nil {
+			return f, err
+		}
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.r, f.payload); err != nil {
+		return f, err
+	}
+	if masked {
+		for i := range f.payload {
+			f.payload[i] ^= mask[i%4]
+		}
+	}
+	return f, nil
+}
+
+// writeFrame sends the frame, masking it if the connection is a client one
+func (c *WebSocketConn) writeFrame(f wsFrame, timeout time.Duration) error {
+	b := make([]byte, 0, 14+len(f.payload))
+	first := f.opcode
+	if f.fin {
+		first |= 0x80
+	}
+	b = append(b, first)
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch l := len(f.payload); {
+	case l <= 125:
+		b = append(b, maskBit|byte(l))
+	case l <= 0xffff:
+		b = append(b, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(b[len(b)-2:], uint16(l))
+	default:
+		b = append(b, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(b[len(b)-8:], uint64(l))
+	}
+
+	if c.client {
+		mask := make([]byte, 4)
+		if _, err := rand.Read(mask); err != nil {
+			return err
+		}
+		b = append(b, mask...)
+		start := len(b)
+		b = append(b, f.payload...)
+		for i := start; i < len(b); i++ {
+			b[i] ^= mask[(i-start)%4]
+		}
+	} else {
+		b = append(b, f.payload...)
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+func (c *WebSocketConn) writeClose(code int, timeout time.Duration) error {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return c.writeFrame(wsFrame{fin: true, opcode: wsOpClose, payload: payload}, timeout)
+}
+
+// WebSocketDialer connects to the backend of an endpoint with the path and the headers of the request
+type WebSocketDialer func(ctx context.Context, request *Request) (*WebSocketConn, *http.Response, error)
+
+// NewWebSocketDialer returns a dialer connecting to the hosts of the backend, selected by a round robin
+// balancer over its service discovery subscriber. The http(s) hosts are dialed as ws(s) ones
+func NewWebSocketDialer(remote *config.Backend) WebSocketDialer {
+	lb := sd.NewRoundRobinLB(sd.GetSubscriber(remote))
+	return func(ctx context.Context, request *Request) (*WebSocketConn, *http.Response, error) {
+		host, err := lb.Host()
+		if err != nil {
+			return nil, nil, err
+		}
+		r := request.Clone()
+		r.GeneratePath(remote.URLPattern)
+		rawURL := host + r.Path
+		if len(r.Query) > 0 {
+			rawURL += "?" + r.Query.Encode()
+		}
+		return DialWebSocket(ctx, rawURL, http.Header(r.Headers))
+	}
+}
+
+// DialWebSocket opens a websocket connection to the url (ws, wss, http or https), sending the headers in
+// the handshake. The response of the handshake is returned when the backend rejects it
+func DialWebSocket(ctx context.Context, rawURL string, header http.Header) (*WebSocketConn, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	secure := false
+	switch u.Scheme {
+	case "ws", "http":
+	case "wss", "https":
+		secure = true
+	default:
+		return nil, nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		if secure {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if secure {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tlsConn
+	}
+
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	req := &http.Request{
+		Method:     "GET",
+		URL:        &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Host:       u.Host,
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != WebSocketAccept(key) {
+		conn.Close()
+		return nil, resp, ErrWebSocketHandshake
+	}
+	conn.SetDeadline(time.Time{})
+	return NewWebSocketConn(conn, br, true), resp, nil
+}
+
+// WebSocketAccept returns the value of the Sec-WebSocket-Accept header for the received key
+func WebSocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// RelayWebSocket relays the frames between the client and the backend connections until one of them is
+// closed, the messages exceed the max size or a peer stops responding. The pings are answered on every
+// side of the relay, so the peers keep their connections alive independently. Both connections are
+// closed when it returns
+func RelayWebSocket(client, backend *WebSocketConn, cfg WebSocketConfig) {
+	errs := make(chan error, 2)
+	go func() { errs <- relayFrames(backend, client, cfg) }()
+	go func() { errs <- relayFrames(client, backend, cfg) }()
+
+	done := make(chan struct{})
+	if cfg.PingInterval > 0 {
+		go pingWebSocket(done, cfg, client, backend)
+	}
+
+	err := <-errs
+	close(done)
+	switch err {
+	case ErrWebSocketClosed:
+		// wait for the reply of the other peer to the forwarded close frame
+		select {
+		case <-errs:
+		case <-time.After(closeTimeout(cfg)):
+		}
+	case ErrWebSocketMessageTooBig:
+		client.writeClose(wsCloseMessageTooBig, cfg.WriteTimeout)
+		backend.writeClose(wsCloseMessageTooBig, cfg.WriteTimeout)
+	case ErrWebSocketProtocol:
+		client.writeClose(wsCloseProtocolErr, cfg.WriteTimeout)
+		backend.writeClose(wsCloseProtocolErr, cfg.WriteTimeout)
+	default:
+		client.writeClose(wsCloseGoingAway, cfg.WriteTimeout)
+		backend.writeClose(wsCloseGoingAway, cfg.WriteTimeout)
+	}
+	client.Close()
+	backend.Close()
+}
+
+// relayFrames copies the frames from src to dst, answering the pings of src
+func relayFrames(dst, src *WebSocketConn, cfg WebSocketConfig) error {
+	var size int64
+	for {
+		if cfg.PongTimeout > 0 {
+			src.conn.SetReadDeadline(time.Now().Add(cfg.PongTimeout))
+		}
+		f, err := src.readFrame(cfg.MaxMessageSize)
+		if err != nil {
+			return err
+		}
+		switch f.opcode {
+		case wsOpPing:
+			if err := src.writeFrame(wsFrame{fin: true, opcode: wsOpPong, payload: f.payload}, cfg.WriteTimeout); err != nil {
+				return err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			if len(f.payload) == 0 {
+				dst.writeClose(wsCloseNormal, cfg.WriteTimeout)
+			} else {
+				dst.writeFrame(f, cfg.WriteTimeout)
+			}
+			return ErrWebSocketClosed
+		case wsOpText, wsOpBinary:
+			size = 0
+		case wsOpContinuation:
+		default:
+			return ErrWebSocketProtocol
+		}
+
+		size += int64(len(f.payload))
+		if cfg.MaxMessageSize > 0 && size > cfg.MaxMessageSize {
+			return ErrWebSocketMessageTooBig
+		}
+		if err := dst.writeFrame(f, cfg.WriteTimeout); err != nil {
+			return err
+		}
+	}
+}
+
+func pingWebSocket(done <-chan struct{}, cfg WebSocketConfig, conns ...*WebSocketConn) {
+	ticker := time.NewTicker(cfg.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for _, c := range conns {
+				c.writeFrame(wsFrame{fin: true, opcode: wsOpPing}, cfg.WriteTimeout)
+			}
+		}
+	}
+}
+
+func closeTimeout(cfg WebSocketConfig) time.Duration {
+	if cfg.WriteTimeout > 0 {
+		return cfg.WriteTimeout
+	}
+	return DefaultWebSocketWriteTimeout
+}
@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// webSocketPair returns both ends of a websocket connection: the client one (masking its frames) and the
+// server one
+func webSocketPair() (*WebSocketConn, *WebSocketConn) {
+	c, s := net.Pipe()
+	return NewWebSocketConn(c, nil, true), NewWebSocketConn(s, nil, false)
+}
+
+func TestRelayWebSocket(t *testing.T) {
+	browser, gatewayClient := webSocketPair()
+	gatewayBackend, backend := webSocketPair()
+	done := make(chan struct{})
+	go func() {
+		RelayWebSocket(gatewayClient, gatewayBackend, WebSocketConfig{MaxMessageSize: 10})
+		close(done)
+	}()
+
+	go browser.WriteMessage(WebSocketTextMessage, []byte("hello"))
+	messageType, msg, err := backend.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messageType != WebSocketTextMessage || string(msg) != "hello" {
+		t.Errorf("unexpected message: %d %s", messageType, msg)
+	}
+
+	// the fragments are relayed as they are received
+	go func() {
+		backend.writeFrame(wsFrame{opcode: wsOpBinary, payload: []byte("abc")}, 0)
+		backend.writeFrame(wsFrame{fin: true, opcode: wsOpContinuation, payload: []byte("def")}, 0)
+	}()
+	messageType, msg, err = browser.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messageType != WebSocketBinaryMessage || string(msg) != "abcdef" {
+		t.Errorf("unexpected message: %d %s", messageType, msg)
+	}
+
+	// the pings are answered by the gateway
+	go browser.writeFrame(wsFrame{fin: true, opcode: wsOpPing, payload: []byte("ping")}, 0)
+	f, err := browser.readFrame(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.opcode != wsOpPong || string(f.payload) != "ping" {
+		t.Errorf("unexpected frame: %v", f)
+	}
+
+	go browser.writeFrame(wsFrame{fin: true, opcode: wsOpClose, payload: []byte{0x03, 0xe8}}, 0)
+	if _, _, err := backend.ReadMessage(); err != ErrWebSocketClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+	go backend.writeClose(wsCloseNormal, 0)
+	if _, _, err := browser.ReadMessage(); err != ErrWebSocketClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("the relay has not been closed")
+	}
+}
+
+func TestRelayWebSocket_messageTooBig(t *testing.T) {
+	browser, gatewayClient := webSocketPair()
+	gatewayBackend, backend := webSocketPair()
+	go RelayWebSocket(gatewayClient, gatewayBackend, WebSocketConfig{MaxMessageSize: 4})
+
+	go func() {
+		browser.writeFrame(wsFrame{opcode: wsOpText, payload: []byte("abc")}, 0)
+		browser.writeFrame(wsFrame{fin: true, opcode: wsOpContinuation, payload: []byte("def")}, 0)
+	}()
+	// the first fragment is under the limit
+	if f, err := backend.readFrame(0); err != nil || string(f.payload) != "abc" {
+		t.Fatalf("unexpected frame: %v %v", f, err)
+	}
+
+	// the relay closes the client connection first
+	for _, c := range []*WebSocketConn{browser, backend} {
+		f, err := c.readFrame(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.opcode != wsOpClose || binary.BigEndian.Uint16(f.payload) != wsCloseMessageTooBig {
+			t.Errorf("unexpected frame: %v", f)
+		}
+	}
+}
+
+func TestRelayWebSocket_ping(t *testing.T) {
+	browser, gatewayClient := webSocketPair()
+	gatewayBackend, backend := webSocketPair()
+	go RelayWebSocket(gatewayClient, gatewayBackend, WebSocketConfig{PingInterval: 10 * time.Millisecond})
+
+	for _, c := range []*WebSocketConn{browser, backend} {
+		f, err := c.readFrame(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.opcode != wsOpPing {
+			t.Errorf("unexpected frame: %v", f)
+		}
+	}
+	browser.Close()
+	backend.Close()
+}
+
+func TestWebSocketConn_frames(t *testing.T) {
+	client, server := webSocketPair()
+	for _, size := range []int{0, 125, 126, 0xffff, 0x10000} {
+		payload := bytes.Repeat([]byte("a"), size)
+		go client.WriteMessage(WebSocketBinaryMessage, payload)
+		if _, msg, err := server.ReadMessage(); err != nil || !bytes.Equal(msg, payload) {
+			t.Errorf("size %d: unexpected message (%d bytes): %v", size, len(msg), err)
+		}
+		go server.WriteMessage(WebSocketBinaryMessage, payload)
+		if _, msg, err := client.ReadMessage(); err != nil || !bytes.Equal(msg, payload) {
+			t.Errorf("size %d: unexpected message (%d bytes): %v", size, len(msg), err)
+		}
+	}
+
+	// the servers must reject the unmasked frames
+	go server.WriteMessage(WebSocketTextMessage, []byte("unmasked"))
+	if _, err := (&WebSocketConn{conn: client.conn, r: client.r}).readFrame(0); err != ErrWebSocketProtocol {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func webSocketEchoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.URL.Path != "/ws/42" || r.URL.Query().Get("a") != "1" {
+			http.Error(w, "unexpected request", http.StatusForbidden)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + WebSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n"))
+		ws := NewWebSocketConn(conn, rw.Reader, false)
+		defer ws.Close()
+		for {
+			messageType, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			ws.WriteMessage(messageType, append([]byte(r.Header.Get("X-Test")+":"), msg...))
+		}
+	}))
+}
+
+func TestNewWebSocketDialer(t *testing.T) {
+	backend := webSocketEchoServer(t)
+	defer backend.Close()
+
+	dial := NewWebSocketDialer(&config.Backend{Host: []string{backend.URL}, URLPattern: "/ws/{{.Id}}"})
+	conn, resp, err := dial(context.Background(), &Request{
+		Params:  map[string]string{"Id": "42"},
+		Query:   map[string][]string{"a": {"1"}},
+		Headers: map[string][]string{"X-Test": {"supu"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	conn.WriteMessage(WebSocketTextMessage, []byte("hello"))
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "supu:hello" {
+		t.Errorf("unexpected message: %s %v", msg, err)
+	}
+
+	if _, resp, err = dial(context.Background(), &Request{Params: map[string]string{"Id": "1"}}); err != ErrWebSocketHandshake {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestDialWebSocket_unsupportedScheme(t *testing.T) {
+	if _, _, err := DialWebSocket(context.Background(), "ftp://example.com", nil); err == nil {
+		t.Error("error expected")
+	}
+}
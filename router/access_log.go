@@ -0,0 +1,407 @@
+package router
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// AccessLogNamespace is the key to look for the access log options in the extra config of the service
+const AccessLogNamespace = "github.com/devopsfaith/krakend/router/access_log"
+
+// Formats of the access log lines
+const (
+	AccessLogFormatJSON = "json"
+	AccessLogFormatCLF  = "clf"
+)
+
+// Fields of the access log lines
+const (
+	AccessLogFieldTime      = "time"
+	AccessLogFieldMethod    = "method"
+	AccessLogFieldPath      = "path"
+	AccessLogFieldProto     = "proto"
+	AccessLogFieldEndpoint  = "endpoint"
+	AccessLogFieldStatus    = "status"
+	AccessLogFieldClientIP  = "client_ip"
+	AccessLogFieldLatency   = "latency"
+	AccessLogFieldBackends  = "backends"
+	AccessLogFieldBytes     = "bytes"
+	AccessLogFieldTraceID   = "trace_id"
+	AccessLogFieldRequestID = "request_id"
+	AccessLogFieldUserAgent = "user_agent"
+	AccessLogFieldReferer   = "referer"
+)
+
+// DefaultAccessLogFields are the fields of the access log lines when they are not configured
+var DefaultAccessLogFields = []string{
+	AccessLogFieldTime,
+	AccessLogFieldMethod,
+	AccessLogFieldPath,
+	AccessLogFieldProto,
+	AccessLogFieldEndpoint,
+	AccessLogFieldStatus,
+	AccessLogFieldClientIP,
+	AccessLogFieldLatency,
+	AccessLogFieldBackends,
+	AccessLogFieldBytes,
+	AccessLogFieldTraceID,
+	AccessLogFieldRequestID,
+	AccessLogFieldUserAgent,
+	AccessLogFieldReferer,
+}
+
+// clfFields are the fields always written by the common log format
+var clfFields = map[string]bool{
+	AccessLogFieldTime:     true,
+	AccessLogFieldMethod:   true,
+	AccessLogFieldPath:     true,
+	AccessLogFieldProto:    true,
+	AccessLogFieldStatus:   true,
+	AccessLogFieldClientIP: true,
+	AccessLogFieldBytes:    true,
+}
+
+// AccessLogConfig defines the access log of the service
+type AccessLogConfig struct {
+	// Format of the lines, AccessLogFormatJSON or AccessLogFormatCLF. By default, AccessLogFormatJSON
+	Format string `json:"format"`
+	// Fields written in every line, in order. By default, the DefaultAccessLogFields. The common log format
+	// always writes its own fields and appends the rest of them as key=value pairs
+	Fields []string `json:"fields"`
+	// Output is "stdout", "stderr" or the path of the file where the lines are appended. By default, "stdout"
+	Output string `json:"output"`
+	// TraceHeader is the header carrying the trace ID. By default, the trace ID is taken from the W3C
+	// traceparent header
+	TraceHeader string `json:"trace_header"`
+}
+
+// AccessLogConfigGetter parses the access log options from the extra config of the service. The second value
+// is false if they are not declared
+func AccessLogConfigGetter(extra config.ExtraConfig) (AccessLogConfig, bool, error) {
+	cfg := AccessLogConfig{}
+	v, ok := extra[AccessLogNamespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false, err
+	}
+	if cfg.Format == "" {
+		cfg.Format = AccessLogFormatJSON
+	}
+	if len(cfg.Fields) == 0 {
+		cfg.Fields = DefaultAccessLogFields
+	}
+	if cfg.Output == "" {
+		cfg.Output = "stdout"
+	}
+	return cfg, true, nil
+}
+
+// AccessLogEntry is a request served by the router, as recorded by the access log
+type AccessLogEntry struct {
+	Time      time.Time
+	Method    string
+	Path      string
+	Proto     string
+	Endpoint  string
+	Status    int
+	ClientIP  string
+	Latency   time.Duration
+	Backends  []proxy.BackendTiming
+	Bytes     int64
+	TraceID   string
+	RequestID string
+	UserAgent string
+	Referer   string
+}
+
+// TraceID returns the trace ID of the span of the request or, if there is none, the one of its W3C
+// traceparent header. Without both of them, it returns an empty string
+func TraceID(r *http.Request) string {
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		return sc.TraceID().String()
+	}
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	return parts[1]
+}
+
+// AccessLogHandler decorates the handler, writing a line per request with the access log options declared
+// at the extra config of the service. If they are not declared, the handler is returned untouched
+func AccessLogHandler(extra config.ExtraConfig, next http.Handler) (http.Handler, error) {
+	cfg, ok, err := AccessLogConfigGetter(extra)
+	if err != nil || !ok {
+		return next, err
+	}
+	out, err := accessLogOutput(cfg.Output)
+	if err != nil {
+		return next, err
+	}
+	return NewAccessLogHandler(cfg, out, next)
+}
+
+// NewAccessLogHandler returns a handler writing a line per request into the out writer, with the format and
+// the fields of the config
+func NewAccessLogHandler(cfg AccessLogConfig, out io.Writer, next http.Handler) (http.Handler, error) {
+	var format func(AccessLogEntry, []string) []byte
+	switch strings.ToLower(cfg.Format) {
+	case "", AccessLogFormatJSON:
+		format = formatAccessLogJSON
+	case AccessLogFormatCLF:
+		format = formatAccessLogCLF
+	default:
+		return next, fmt.Errorf("unknown access log format: %s", cfg.Format)
+	}
+	fields := cfg.Fields
+	if len(fields) == 0 {
+		fields = DefaultAccessLogFields
+	}
+	for _, f := range fields {
+		if !isAccessLogField(f) {
+			return next, fmt.Errorf("unknown access log field: %s", f)
+		}
+	}
+	traceID := TraceID
+	if cfg.TraceHeader != "" {
+		traceID = func(r *http.Request) string { return r.Header.Get(cfg.TraceHeader) }
+	}
+	w := &syncAccessLogWriter{w: out}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		entry := &AccessLogEntry{
+			Time:      time.Now(),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Proto:     r.Proto,
+			ClientIP:  ClientIP(r),
+			TraceID:   traceID(r),
+			RequestID: RequestID(r),
+			UserAgent: r.UserAgent(),
+			Referer:   r.Referer(),
+		}
+		r, match := withEndpointMatch(r)
+		timings := &proxy.Timings{}
+		aw := &statusWriter{ResponseWriter: rw}
+		next.ServeHTTP(aw, r.WithContext(proxy.NewTimingsContext(r.Context(), timings)))
+
+		entry.Latency = time.Since(entry.Time)
+		entry.Endpoint = match.endpoint
+		entry.Status = aw.status
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		entry.Bytes = aw.bytes
+		entry.Backends = timings.Backends()
+		w.write(format(*entry, fields))
+	}), nil
+}
+
+func isAccessLogField(name string) bool {
+	for _, f := range DefaultAccessLogFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+func formatAccessLogJSON(e AccessLogEntry, fields []string) []byte {
+	b := []byte{'{'}
+	for i, f := range fields {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendQuote(b, f)
+		b = append(b, ':')
+		v, _ := json.Marshal(accessLogValue(e, f))
+		b = append(b, v...)
+	}
+	return append(b, '}', '\n')
+}
+
+type accessLogBackend struct {
+	Backend string  `json:"backend"`
+	Status  int     `json:"status"`
+	Latency float64 `json:"latency"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// accessLogValue returns the value of the field. The latencies are in milliseconds
+func accessLogValue(e AccessLogEntry, field string) interface{} {
+	switch field {
+	case AccessLogFieldTime:
+		return e.Time.Format(time.RFC3339Nano)
+	case AccessLogFieldMethod:
+		return e.Method
+	case AccessLogFieldPath:
+		return e.Path
+	case AccessLogFieldProto:
+		return e.Proto
+	case AccessLogFieldEndpoint:
+		return e.Endpoint
+	case AccessLogFieldStatus:
+		return e.Status
+	case AccessLogFieldClientIP:
+		return e.ClientIP
+	case AccessLogFieldLatency:
+		return milliseconds(e.Latency)
+	case AccessLogFieldBackends:
+		backends := make([]accessLogBackend, len(e.Backends))
+		for i, bt := range e.Backends {
+			backends[i] = accessLogBackend{Backend: bt.Backend, Status: bt.StatusCode, Latency: milliseconds(bt.Duration)}
+			if bt.Err != nil {
+				backends[i].Error = bt.Err.Error()
+			}
+		}
+		return backends
+	case AccessLogFieldBytes:
+		return e.Bytes
+	case AccessLogFieldTraceID:
+		return e.TraceID
+	case AccessLogFieldRequestID:
+		return e.RequestID
+	case AccessLogFieldUserAgent:
+		return e.UserAgent
+	case AccessLogFieldReferer:
+		return e.Referer
+	}
+	return nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// formatAccessLogCLF writes the entry in the common log format, followed by the rest of the fields as
+// key=value pairs
+func formatAccessLogCLF(e AccessLogEntry, fields []string) []byte {
+	bytes := "-"
+	if e.Bytes > 0 {
+		bytes = strconv.FormatInt(e.Bytes, 10)
+	}
+	line := fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s", e.ClientIP, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.Path, e.Proto, e.Status, bytes)
+	for _, f := range fields {
+		if clfFields[f] {
+			continue
+		}
+		var v string
+		switch f {
+		case AccessLogFieldLatency:
+			v = e.Latency.String()
+		case AccessLogFieldBackends:
+			backends := make([]string, len(e.Backends))
+			for i, bt := range e.Backends {
+				backends[i] = fmt.Sprintf("%s %d %s", bt.Backend, bt.StatusCode, bt.Duration)
+			}
+			v = strings.Join(backends, ";")
+		default:
+			v = fmt.Sprint(accessLogValue(e, f))
+		}
+		line += " " + f + "=" + strconv.Quote(v)
+	}
+	return []byte(line + "\n")
+}
+
+// statusWriter records the status code and the size of the response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the websocket handshakes upgrade the connection
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrHijackNotSupported
+	}
+	w.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the decorated writer, so the http.ResponseController can reach it
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// syncAccessLogWriter serializes the writes of the lines
+type syncAccessLogWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncAccessLogWriter) write(b []byte) {
+	w.mu.Lock()
+	w.w.Write(b)
+	w.mu.Unlock()
+}
+
+var (
+	accessLogOutputsMu sync.Mutex
+	// accessLogOutputs keeps the files opened, so the reloaded configurations share them
+	accessLogOutputs = map[string]io.Writer{}
+)
+
+func accessLogOutput(output string) (io.Writer, error) {
+	switch output {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+	accessLogOutputsMu.Lock()
+	defer accessLogOutputsMu.Unlock()
+	if w, ok := accessLogOutputs[output]; ok {
+		return w, nil
+	}
+	f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	accessLogOutputs[output] = f
+	return f, nil
+}
@@ -0,0 +1,137 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func accessLogTestHandler() http.Handler {
+	backend := proxy.NewTimingsMiddleware(&config.Backend{URLPattern: "/users/{{.Id}}"})(func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Metadata: proxy.Metadata{StatusCode: 200}}, nil
+	})
+	return MatchedEndpointHandler("/users/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backend(r.Context(), &proxy.Request{})
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":42}`))
+	}))
+}
+
+func TestNewAccessLogHandler_json(t *testing.T) {
+	buff := new(bytes.Buffer)
+	h, err := NewAccessLogHandler(AccessLogConfig{}, buff, accessLogTestHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/users/42?secret=1", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("User-Agent", "test")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(buff.Bytes(), &record); err != nil {
+		t.Fatalf("unexpected line: %s", buff.String())
+	}
+	expected := map[string]interface{}{
+		"method":     "POST",
+		"path":       "/users/42",
+		"proto":      "HTTP/1.1",
+		"endpoint":   "/users/{id}",
+		"status":     float64(201),
+		"client_ip":  "1.2.3.4",
+		"bytes":      float64(9),
+		"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+		"user_agent": "test",
+		"referer":    "",
+	}
+	for k, v := range expected {
+		if record[k] != v {
+			t.Errorf("unexpected %s: %v", k, record[k])
+		}
+	}
+	backends, ok := record["backends"].([]interface{})
+	if !ok || len(backends) != 1 {
+		t.Fatalf("unexpected backends: %v", record["backends"])
+	}
+	if b := backends[0].(map[string]interface{}); b["backend"] != "/users/{{.Id}}" || b["status"] != float64(200) {
+		t.Errorf("unexpected backend: %v", b)
+	}
+	if _, ok := record["latency"].(float64); !ok {
+		t.Errorf("unexpected latency: %v", record["latency"])
+	}
+	if !strings.HasPrefix(buff.String(), `{"time":`) {
+		t.Errorf("the fields are not ordered: %s", buff.String())
+	}
+}
+
+func TestNewAccessLogHandler_clf(t *testing.T) {
+	buff := new(bytes.Buffer)
+	h, err := NewAccessLogHandler(AccessLogConfig{
+		Format:      AccessLogFormatCLF,
+		Fields:      []string{AccessLogFieldMethod, AccessLogFieldEndpoint, AccessLogFieldTraceID},
+		TraceHeader: "X-Trace",
+	}, buff, accessLogTestHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	req.Header.Set("X-Trace", "abc")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	pattern := regexp.MustCompile(`^1\.2\.3\.4 - - \[[^\]]+\] "GET /users/42 HTTP/1.1" 201 9 endpoint="/users/\{id\}" trace_id="abc"\n$`)
+	if !pattern.MatchString(buff.String()) {
+		t.Errorf("unexpected line: %s", buff.String())
+	}
+}
+
+func TestNewAccessLogHandler_ko(t *testing.T) {
+	for _, cfg := range []AccessLogConfig{
+		{Format: "xml"},
+		{Fields: []string{"unknown"}},
+	} {
+		if _, err := NewAccessLogHandler(cfg, ioutil.Discard, accessLogTestHandler()); err == nil {
+			t.Errorf("%+v: error expected", cfg)
+		}
+	}
+}
+
+func TestAccessLogHandler(t *testing.T) {
+	next := accessLogTestHandler()
+	h, err := AccessLogHandler(config.ExtraConfig{}, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+
+	output := filepath.Join(t.TempDir(), "access.log")
+	h, err = AccessLogHandler(config.ExtraConfig{AccessLogNamespace: map[string]interface{}{
+		"output": output,
+		"fields": []interface{}{"status"},
+	}}, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42", nil))
+
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "{\"status\":201}\n{\"status\":201}\n" {
+		t.Errorf("unexpected lines: %s", string(b))
+	}
+}
@@ -0,0 +1,150 @@
+// Package acme obtains and renews the certificates of the server with the ACME protocol (like the Let's
+// Encrypt ones), solving the TLS-ALPN-01 challenges in the TLS listener and the HTTP-01 ones in a plaintext
+// listener. The certificates are kept in a pluggable cache, so they survive the restarts
+package acme
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// Namespace is the key to look for the ACME options in the extra config of the service
+const Namespace = "github.com/devopsfaith/krakend/router/acme"
+
+// DefaultHTTPPort is the default port of the listener solving the HTTP-01 challenges
+const DefaultHTTPPort = 80
+
+// ErrNoDomains is the error returned when the ACME options do not declare any domain
+var ErrNoDomains = errors.New("acme: the domains are required")
+
+// Config is the ACME options set at the extra config of the service
+type Config struct {
+	// Domains are the names to get certificates for
+	Domains []string `json:"domains"`
+	// Email is the contact of the account in the ACME server
+	Email string `json:"email"`
+	// DirectoryURL is the directory of the ACME server. By default, the Let's Encrypt production one
+	DirectoryURL string `json:"directory_url"`
+	// RenewBefore is how long before their expiration the certificates are renewed. By default, 30 days
+	RenewBefore string `json:"renew_before"`
+	// HTTPPort is the port of the listener solving the HTTP-01 challenges and redirecting the rest of its
+	// requests to HTTPS. By default, the DefaultHTTPPort
+	HTTPPort int `json:"http_port"`
+	// DisableHTTPChallenge solves only the TLS-ALPN-01 challenges, without the HTTP listener
+	DisableHTTPChallenge bool `json:"disable_http_challenge"`
+	// Cache declares the store of the certificates and its options. By default, the disk one
+	Cache map[string]interface{} `json:"cache"`
+}
+
+// ConfigGetter parses the ACME options from the extra config. The second value is false if ACME is not enabled
+func ConfigGetter(extra config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, err
+	}
+	if len(cfg.Domains) == 0 {
+		return cfg, true, ErrNoDomains
+	}
+	if cfg.HTTPPort == 0 {
+		cfg.HTTPPort = DefaultHTTPPort
+	}
+	return cfg, true, nil
+}
+
+// NewManager returns the manager of the certificates of the configured domains
+func NewManager(cfg Config) (*autocert.Manager, error) {
+	cache, err := NewCache(cfg.Cache)
+	if err != nil {
+		return nil, err
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      cache,
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+	}
+	if cfg.RenewBefore != "" {
+		if m.RenewBefore, err = time.ParseDuration(cfg.RenewBefore); err != nil {
+			return nil, fmt.Errorf("acme: invalid renew_before: %s", err.Error())
+		}
+	}
+	if cfg.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	}
+	return m, nil
+}
+
+// ConfigureServer enables the automatic certificates in the server when the service declares the ACME
+// options. The TLS config of the server solves the TLS-ALPN-01 challenges and serves the certificates of the
+// configured domains, while the certificates of the TLS settings of the service keep serving the rest of the
+// server names. The returned server solves the HTTP-01 challenges and redirects the rest of its requests to
+// HTTPS. It is nil if ACME is not enabled or the HTTP challenges are disabled
+func ConfigureServer(s *http.Server, cfg config.ServiceConfig) (*http.Server, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		return nil, err
+	}
+	m, err := NewManager(c)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := s.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	domains := map[string]bool{}
+	for _, d := range c.Domains {
+		domains[strings.ToLower(d)] = true
+	}
+	tlsConfig.GetCertificate = getCertificate(domains, m.GetCertificate, tlsConfig.GetCertificate)
+	tlsConfig.NextProtos = append(tlsConfig.NextProtos, acme.ALPNProto)
+	s.TLSConfig = tlsConfig
+
+	if c.DisableHTTPChallenge {
+		return nil, nil
+	}
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", c.HTTPPort),
+		Handler:           m.HTTPHandler(nil),
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}, nil
+}
+
+type certificateGetter func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+// getCertificate returns the ACME certificates for the challenges and the configured domains, and the
+// ones of the fallback for the rest of the server names
+func getCertificate(domains map[string]bool, acmeCertificate, fallback certificateGetter) certificateGetter {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if fallback == nil || isChallenge(hello) || domains[strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")] {
+			return acmeCertificate(hello)
+		}
+		return fallback(hello)
+	}
+}
+
+func isChallenge(hello *tls.ClientHelloInfo) bool {
+	return len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto
+}
@@ -0,0 +1,137 @@
+package acme
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// cachePath is never written, as the tests do not request certificates
+var cachePath = filepath.Join(os.TempDir(), "krakend_acme")
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, err := ConfigGetter(config.ExtraConfig{}); ok || err != nil {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{}}); !ok || err != ErrNoDomains {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"domains": []interface{}{"example.com"},
+		"email":   "admin@example.com",
+	}})
+	if !ok || err != nil {
+		t.Errorf("unexpected result. ok: %v, err: %v", ok, err)
+	}
+	if cfg.HTTPPort != DefaultHTTPPort {
+		t.Errorf("unexpected http port: %d", cfg.HTTPPort)
+	}
+	if len(cfg.Domains) != 1 || cfg.Domains[0] != "example.com" || cfg.Email != "admin@example.com" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestNewManager(t *testing.T) {
+	m, err := NewManager(Config{
+		Domains:      []string{"example.com"},
+		RenewBefore:  "240h",
+		DirectoryURL: "https://acme-staging-v02.api.letsencrypt.org/directory",
+		Cache:        map[string]interface{}{"path": cachePath},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.RenewBefore != 240*time.Hour {
+		t.Errorf("unexpected renew before: %v", m.RenewBefore)
+	}
+	if m.Client == nil || m.Client.DirectoryURL != "https://acme-staging-v02.api.letsencrypt.org/directory" {
+		t.Errorf("unexpected client: %+v", m.Client)
+	}
+
+	if _, err := NewManager(Config{Domains: []string{"example.com"}, RenewBefore: "tomorrow"}); err == nil {
+		t.Error("expecting an error")
+	}
+	if _, err := NewManager(Config{Domains: []string{"example.com"}, Cache: map[string]interface{}{"store": "unknown"}}); err != ErrUnknownCache {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestConfigureServer(t *testing.T) {
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"domains":   []interface{}{"example.com"},
+		"http_port": 8080,
+		"cache":     map[string]interface{}{"path": cachePath},
+	}}}
+	s := &http.Server{TLSConfig: &tls.Config{NextProtos: []string{"h2"}}}
+	challenges, err := ConfigureServer(s, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if challenges == nil || challenges.Addr != ":8080" || challenges.Handler == nil {
+		t.Errorf("unexpected challenges server: %+v", challenges)
+	}
+	if s.TLSConfig.GetCertificate == nil {
+		t.Error("the certificates getter was not set")
+	}
+	if len(s.TLSConfig.NextProtos) != 2 || s.TLSConfig.NextProtos[1] != acme.ALPNProto {
+		t.Errorf("unexpected protocols: %v", s.TLSConfig.NextProtos)
+	}
+
+	cfg.ExtraConfig[Namespace].(map[string]interface{})["disable_http_challenge"] = true
+	s = &http.Server{}
+	challenges, err = ConfigureServer(s, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if challenges != nil {
+		t.Errorf("unexpected challenges server: %+v", challenges)
+	}
+	if s.TLSConfig == nil || s.TLSConfig.GetCertificate == nil {
+		t.Error("the TLS config was not set")
+	}
+
+	s = &http.Server{}
+	if challenges, err := ConfigureServer(s, config.ServiceConfig{}); err != nil || challenges != nil || s.TLSConfig != nil {
+		t.Errorf("unexpected result. challenges: %v, err: %v", challenges, err)
+	}
+}
+
+func TestGetCertificate(t *testing.T) {
+	acmeCert, fallbackCert := &tls.Certificate{}, &tls.Certificate{}
+	acmeGetter := func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return acmeCert, nil }
+	fallback := func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return fallbackCert, nil }
+	getter := getCertificate(map[string]bool{"example.com": true}, acmeGetter, fallback)
+
+	for _, tc := range []struct {
+		name     string
+		hello    *tls.ClientHelloInfo
+		expected *tls.Certificate
+	}{
+		{name: "domain", hello: &tls.ClientHelloInfo{ServerName: "Example.com."}, expected: acmeCert},
+		{name: "other", hello: &tls.ClientHelloInfo{ServerName: "other.com"}, expected: fallbackCert},
+		{name: "challenge", hello: &tls.ClientHelloInfo{ServerName: "other.com", SupportedProtos: []string{acme.ALPNProto}}, expected: acmeCert},
+	} {
+		cert, err := getter(tc.hello)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if cert != tc.expected {
+			t.Errorf("%s: unexpected certificate", tc.name)
+		}
+	}
+
+	errACME := errors.New("acme")
+	getter = getCertificate(map[string]bool{}, func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return nil, errACME }, nil)
+	if _, err := getter(&tls.ClientHelloInfo{ServerName: "other.com"}); err != errACME {
+		t.Errorf("without fallback, the acme certificates must be used: %v", err)
+	}
+}
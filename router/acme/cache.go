@@ -0,0 +1,51 @@
+package acme
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// DiskCache is the name of the default cache, storing the certificates in a directory
+const DiskCache = "disk"
+
+// DefaultCachePath is the default directory of the disk cache
+const DefaultCachePath = "acme"
+
+// ErrUnknownCache is the error returned when the configured cache is not registered
+var ErrUnknownCache = errors.New("acme: unknown cache")
+
+// CacheFactory creates a certificates cache with the options declared in the cache config
+type CacheFactory func(cfg map[string]interface{}) (autocert.Cache, error)
+
+var cacheFactories = map[string]CacheFactory{
+	DiskCache: newDiskCache,
+}
+
+// RegisterCache registers the certificates cache factory with the given name
+func RegisterCache(name string, f CacheFactory) error {
+	cacheFactories[name] = f
+	return nil
+}
+
+// NewCache creates the cache named by the 'store' option of the cache config. By default, the disk one
+func NewCache(cfg map[string]interface{}) (autocert.Cache, error) {
+	name, _ := cfg["store"].(string)
+	if name == "" {
+		name = DiskCache
+	}
+	f, ok := cacheFactories[name]
+	if !ok {
+		return nil, ErrUnknownCache
+	}
+	return f(cfg)
+}
+
+// newDiskCache creates a cache storing the certificates in the directory of the 'path' option
+func newDiskCache(cfg map[string]interface{}) (autocert.Cache, error) {
+	path, _ := cfg["path"].(string)
+	if path == "" {
+		path = DefaultCachePath
+	}
+	return autocert.DirCache(path), nil
+}
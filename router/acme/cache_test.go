@@ -0,0 +1,48 @@
+package acme
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func TestNewCache(t *testing.T) {
+	dir := filepath.Join(os.TempDir(), "krakend_acme")
+	c, err := NewCache(map[string]interface{}{"path": dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d, ok := c.(autocert.DirCache); !ok || string(d) != dir {
+		t.Errorf("unexpected cache: %v", c)
+	}
+
+	c, err = NewCache(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d, ok := c.(autocert.DirCache); !ok || string(d) != DefaultCachePath {
+		t.Errorf("unexpected cache: %v", c)
+	}
+
+	if _, err := NewCache(map[string]interface{}{"store": "unknown"}); err != ErrUnknownCache {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRegisterCache(t *testing.T) {
+	errFactory := errors.New("factory")
+	if err := RegisterCache("custom", func(cfg map[string]interface{}) (autocert.Cache, error) {
+		if cfg["option"] != "value" {
+			t.Errorf("unexpected config: %v", cfg)
+		}
+		return nil, errFactory
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewCache(map[string]interface{}{"store": "custom", "option": "value"}); err != errFactory {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
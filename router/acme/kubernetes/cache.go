@@ -0,0 +1,252 @@
+// Package kubernetes provides a cache storing the ACME certificates and account keys in a Kubernetes
+// secret, so all the pods of the gateway share them
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/devopsfaith/krakend/router/acme"
+)
+
+// Name is the key of the cache in the ACME cache config
+const Name = "kubernetes"
+
+// DefaultSecret is the default name of the secret storing the certificates
+const DefaultSecret = "krakend-acme"
+
+// ServiceAccountPath is the directory with the credentials of the service account of the pods
+const ServiceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// ErrNotInCluster is the error returned when the API server is not configured and the gateway is not
+// running in a pod
+var ErrNotInCluster = errors.New("kubernetes: the api server is unknown out of the cluster")
+
+// maxConflicts is the number of times an update is retried when the secret is modified concurrently
+const maxConflicts = 5
+
+var errConflict = errors.New("kubernetes: conflict")
+
+// Register registers the Kubernetes cache factory
+func Register() error {
+	return acme.RegisterCache(Name, CacheFactory)
+}
+
+// CacheFactory creates a cache storing the certificates in the secret of the 'secret' option, in the
+// namespace of the 'namespace' one. By default, the secret is the DefaultSecret in the namespace of the pod,
+// and the API server is reached with the credentials of the service account of the pod
+func CacheFactory(cfg map[string]interface{}) (autocert.Cache, error) {
+	secret, _ := cfg["secret"].(string)
+	if secret == "" {
+		secret = DefaultSecret
+	}
+	namespace, _ := cfg["namespace"].(string)
+	if namespace == "" {
+		b, err := ioutil.ReadFile(ServiceAccountPath + "/namespace")
+		if err != nil {
+			return nil, ErrNotInCluster
+		}
+		namespace = strings.TrimSpace(string(b))
+	}
+	apiServer, _ := cfg["api_server"].(string)
+	if apiServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, ErrNotInCluster
+		}
+		apiServer = "https://" + net.JoinHostPort(host, port)
+	}
+
+	client := http.DefaultClient
+	if ca, err := ioutil.ReadFile(ServiceAccountPath + "/ca.crt"); err == nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		client = &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}}
+	}
+	return New(client, apiServer, ServiceAccountPath+"/token", namespace, secret), nil
+}
+
+// New creates a cache storing the certificates in the secret, using the client and the token in the file
+// to call the API server. The token is read for every call, so its rotations are picked up. An empty token
+// file skips the authentication
+func New(client *http.Client, apiServer, tokenFile, namespace, secret string) autocert.Cache {
+	return &cache{
+		client:    client,
+		url:       strings.TrimSuffix(apiServer, "/") + "/api/v1/namespaces/" + namespace + "/secrets",
+		tokenFile: tokenFile,
+		namespace: namespace,
+		secret:    secret,
+	}
+}
+
+type cache struct {
+	client    *http.Client
+	url       string
+	tokenFile string
+	namespace string
+	secret    string
+}
+
+type secret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   metadata          `json:"metadata"`
+	Type       string            `json:"type,omitempty"`
+	Data       map[string][]byte `json:"data"`
+}
+
+type metadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// Get implements the autocert.Cache interface
+func (c *cache) Get(ctx context.Context, key string) ([]byte, error) {
+	s, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, autocert.ErrCacheMiss
+	}
+	data, ok := s.Data[encodeKey(key)]
+	if !ok {
+		return nil, autocert.ErrCacheMiss
+	}
+	return data, nil
+}
+
+// Put implements the autocert.Cache interface
+func (c *cache) Put(ctx context.Context, key string, data []byte) error {
+	return c.update(ctx, func(s *secret) bool {
+		s.Data[encodeKey(key)] = data
+		return true
+	})
+}
+
+// Delete implements the autocert.Cache interface
+func (c *cache) Delete(ctx context.Context, key string) error {
+	return c.update(ctx, func(s *secret) bool {
+		if _, ok := s.Data[encodeKey(key)]; !ok {
+			return false
+		}
+		delete(s.Data, encodeKey(key))
+		return true
+	})
+}
+
+// update applies the change to the current secret, creating it if it does not exist yet. The update is
+// retried when the secret is modified concurrently
+func (c *cache) update(ctx context.Context, change func(*secret) bool) error {
+	for i := 0; i < maxConflicts; i++ {
+		s, err := c.get(ctx)
+		if err != nil {
+			return err
+		}
+		method, url := "PUT", c.url+"/"+c.secret
+		if s == nil {
+			method, url = "POST", c.url
+			s = &secret{
+				APIVersion: "v1",
+				Kind:       "Secret",
+				Metadata:   metadata{Name: c.secret, Namespace: c.namespace},
+				Type:       "Opaque",
+			}
+		}
+		if s.Data == nil {
+			s.Data = map[string][]byte{}
+		}
+		if !change(s) {
+			return nil
+		}
+		b, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		resp, err := c.do(ctx, method, url, b)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusCreated:
+			return nil
+		case http.StatusConflict:
+			continue
+		}
+		return fmt.Errorf("kubernetes: %s the secret %s: unexpected status code %d", method, c.secret, resp.StatusCode)
+	}
+	return errConflict
+}
+
+// get returns the secret, or nil if it does not exist
+func (c *cache) get(ctx context.Context) (*secret, error) {
+	resp, err := c.do(ctx, "GET", c.url+"/"+c.secret, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("kubernetes: GET the secret %s: unexpected status code %d", c.secret, resp.StatusCode)
+	}
+	s := &secret{}
+	if err := json.NewDecoder(resp.Body).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *cache) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.tokenFile != "" {
+		token, err := ioutil.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return c.client.Do(req)
+}
+
+// encodeKey escapes the characters of the cache key not allowed in the keys of the secrets (and the
+// escaping character) with their hex code
+func encodeKey(key string) string {
+	buf := new(bytes.Buffer)
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c == '-' || c == '.' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			buf.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(buf, "_%02x", c)
+	}
+	return buf.String()
+}
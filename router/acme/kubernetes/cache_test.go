@@ -0,0 +1,176 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func TestCache(t *testing.T) {
+	api := newFakeAPI()
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	tokenFile, err := ioutil.TempFile("", "krakend-acme-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("some-token\n")
+	tokenFile.Close()
+
+	ctx := context.Background()
+	c := New(server.Client(), server.URL, tokenFile.Name(), "default", DefaultSecret)
+
+	if _, err := c.Get(ctx, "example.com"); err != autocert.ErrCacheMiss {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := c.Delete(ctx, "example.com"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := c.Put(ctx, "example.com", []byte("cert")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Put(ctx, "acme_account+key", []byte("key")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := api.data["acme_5faccount_2bkey"]; !ok {
+		t.Errorf("the key was not escaped: %v", api.data)
+	}
+
+	for k, v := range map[string]string{"example.com": "cert", "acme_account+key": "key"} {
+		data, err := c.Get(ctx, k)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", k, err)
+			continue
+		}
+		if string(data) != v {
+			t.Errorf("%s: unexpected value: %s", k, string(data))
+		}
+	}
+
+	if err := c.Delete(ctx, "example.com"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := c.Get(ctx, "example.com"); err != autocert.ErrCacheMiss {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if api.token != "Bearer some-token" {
+		t.Errorf("unexpected authorization: %s", api.token)
+	}
+	if api.conflicts != 1 {
+		t.Errorf("the conflicting update was not retried")
+	}
+}
+
+func TestCache_serverError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	c := New(server.Client(), server.URL, "", "default", DefaultSecret)
+	if _, err := c.Get(context.Background(), "example.com"); err == nil || err == autocert.ErrCacheMiss {
+		t.Errorf("the failures of the server must not be reported as misses: %v", err)
+	}
+	if err := c.Put(context.Background(), "example.com", []byte("cert")); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestCacheFactory_notInCluster(t *testing.T) {
+	if _, err := os.Stat(ServiceAccountPath); err == nil {
+		t.Skip("running in a cluster")
+	}
+	if _, err := CacheFactory(map[string]interface{}{}); err != ErrNotInCluster {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := CacheFactory(map[string]interface{}{"namespace": "default"}); err != ErrNotInCluster && os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEncodeKey(t *testing.T) {
+	for k, v := range map[string]string{
+		"example.com":      "example.com",
+		"*.example.com":    "_2a.example.com",
+		"acme_account+key": "acme_5faccount_2bkey",
+		"example.com+rsa":  "example.com_2brsa",
+	} {
+		if e := encodeKey(k); e != v {
+			t.Errorf("%s: unexpected encoded key: %s", k, e)
+		}
+	}
+}
+
+// fakeAPI stores a single secret, rejecting the first update with a conflict
+type fakeAPI struct {
+	mu        sync.Mutex
+	data      map[string][]byte
+	version   int
+	conflicts int
+	token     string
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{}
+}
+
+func (f *fakeAPI) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.token = req.Header.Get("Authorization")
+
+	const path = "/api/v1/namespaces/default/secrets"
+	switch {
+	case req.Method == "GET" && req.URL.Path == path+"/"+DefaultSecret:
+		if f.data == nil {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(rw).Encode(f.secret())
+	case req.Method == "POST" && req.URL.Path == path:
+		if f.data != nil {
+			rw.WriteHeader(http.StatusConflict)
+			return
+		}
+		s := secret{}
+		json.NewDecoder(req.Body).Decode(&s)
+		f.data = s.Data
+		f.version++
+		rw.WriteHeader(http.StatusCreated)
+	case req.Method == "PUT" && req.URL.Path == path+"/"+DefaultSecret:
+		s := secret{}
+		json.NewDecoder(req.Body).Decode(&s)
+		if f.conflicts == 0 {
+			f.conflicts++
+			f.version++
+		}
+		if s.Metadata.ResourceVersion != f.secret().Metadata.ResourceVersion {
+			rw.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.data = s.Data
+		f.version++
+		rw.WriteHeader(http.StatusOK)
+	default:
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeAPI) secret() secret {
+	return secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   metadata{Name: DefaultSecret, Namespace: "default", ResourceVersion: strconv.Itoa(f.version)},
+		Data:       f.data,
+	}
+}
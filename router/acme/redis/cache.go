@@ -0,0 +1,77 @@
+// Package redis provides a cache storing the ACME certificates and account keys in a Redis server, so
+// several instances of the gateway can share them
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/devopsfaith/krakend/router/acme"
+)
+
+// Name is the key of the cache in the ACME cache config
+const Name = "redis"
+
+// DefaultPrefix is the default prefix of the keys of the cache
+const DefaultPrefix = "krakend:acme:"
+
+// ErrNoAddress is the error returned when the address of the Redis server is not defined
+var ErrNoAddress = errors.New("the address of the redis server is required")
+
+// Register registers the Redis cache factory
+func Register() error {
+	return acme.RegisterCache(Name, CacheFactory)
+}
+
+// CacheFactory creates a Redis cache with the 'address', 'password', 'db' and 'prefix' options of the
+// cache config
+func CacheFactory(cfg map[string]interface{}) (autocert.Cache, error) {
+	addr, ok := cfg["address"].(string)
+	if !ok || addr == "" {
+		return nil, ErrNoAddress
+	}
+	password, _ := cfg["password"].(string)
+	db, _ := cfg["db"].(float64)
+	prefix, ok := cfg["prefix"].(string)
+	if !ok {
+		prefix = DefaultPrefix
+	}
+
+	return New(redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       int(db),
+	}), prefix), nil
+}
+
+// New creates a cache using the received Redis client and prefixing its keys
+func New(client *redis.Client, prefix string) autocert.Cache {
+	return cache{client: client, prefix: prefix}
+}
+
+type cache struct {
+	client *redis.Client
+	prefix string
+}
+
+// Get implements the autocert.Cache interface
+func (c cache) Get(_ context.Context, key string) ([]byte, error) {
+	v, err := c.client.Get(c.prefix + key).Bytes()
+	if err == redis.Nil {
+		return nil, autocert.ErrCacheMiss
+	}
+	return v, err
+}
+
+// Put implements the autocert.Cache interface
+func (c cache) Put(_ context.Context, key string, data []byte) error {
+	return c.client.Set(c.prefix+key, data, 0).Err()
+}
+
+// Delete implements the autocert.Cache interface
+func (c cache) Delete(_ context.Context, key string) error {
+	return c.client.Del(c.prefix + key).Err()
+}
@@ -0,0 +1,30 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func TestCacheFactory_noAddress(t *testing.T) {
+	if _, err := CacheFactory(map[string]interface{}{}); err != ErrNoAddress {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCache_unreachable(t *testing.T) {
+	c := New(redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: 10 * time.Millisecond,
+		MaxRetries:  0,
+	}), DefaultPrefix)
+	if err := c.Put(context.Background(), "example.com", []byte("cert")); err == nil {
+		t.Error("expecting an error")
+	}
+	if _, err := c.Get(context.Background(), "example.com"); err == nil || err == autocert.ErrCacheMiss {
+		t.Errorf("the failures of the server must not be reported as misses: %v", err)
+	}
+}
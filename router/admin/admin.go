@@ -0,0 +1,347 @@
+// Package admin provides an authenticated listener exposing the runtime state of the gateway and some
+// operations, like reloading the configuration or changing the log levels, so it can be operated without
+// restarts
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync/atomic"
+
+	"github.com/devopsfaith/krakend/audit"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
+)
+
+// Namespace is the key to look for the admin options in the extra config of the service
+const Namespace = "github.com/devopsfaith/krakend/router/admin"
+
+// DefaultAddress is the address of the admin listener when it is not configured. It only accepts local
+// connections
+const DefaultAddress = "127.0.0.1:8090"
+
+// Paths of the admin API
+const (
+	EndpointsPattern       = "/endpoints"
+	BackendsPattern        = "/backends"
+	CircuitBreakersPattern = "/circuit-breakers"
+	RateLimitsPattern      = "/rate-limits"
+	ReloadPattern          = "/reload"
+	SnapshotsPattern       = "/config/snapshots"
+	LogLevelPattern        = "/log-level"
+	PprofPattern           = "/debug/pprof/"
+)
+
+// ErrNoCredentials is the error returned when the admin options do not declare any credential
+var ErrNoCredentials = errors.New("admin: at least a token or a user is required")
+
+// Config defines the admin listener
+type Config struct {
+	// Address of the listener, as host:port. By default, the DefaultAddress
+	Address string `json:"address"`
+	// Tokens are the bearer tokens accepted by the listener
+	Tokens []string `json:"tokens"`
+	// Users are the passwords of the users accepted with basic authentication, by user name
+	Users map[string]string `json:"users"`
+	// Pprof exposes the profiles of the runtime under the PprofPattern
+	Pprof bool `json:"pprof"`
+}
+
+// ConfigGetter parses the admin options from the extra config of the service. The second value is false if
+// they are not declared
+func ConfigGetter(extra config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, err
+	}
+	if len(cfg.Tokens) == 0 && len(cfg.Users) == 0 {
+		return cfg, true, ErrNoCredentials
+	}
+	if cfg.Address == "" {
+		cfg.Address = DefaultAddress
+	}
+	return cfg, true, nil
+}
+
+// Server is the admin listener
+type Server struct {
+	cfg     Config
+	logger  logging.Logger
+	current atomic.Value
+	handler http.Handler
+}
+
+// NewServer returns the admin listener of the service, when the service declares it. The reload function is
+// called by the reload endpoint, the snapshots are exposed under the SnapshotsPattern, passing the rolled back
+// configurations to the update function, and the log levels are changed if the logger is a
+// logging.LevelController. The returned server is nil if the admin listener is not enabled
+func NewServer(cfg config.ServiceConfig, reload func(), snapshots *config.Snapshots, update func(config.ServiceConfig), logger logging.Logger) (*Server, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		return nil, err
+	}
+	s := &Server{cfg: c, logger: logger}
+	s.current.Store(cfg)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(EndpointsPattern, get(s.endpoints))
+	mux.HandleFunc(BackendsPattern, get(func() interface{} { return proxy.BackendsHealth() }))
+	mux.HandleFunc(CircuitBreakersPattern, get(circuitBreakers))
+	mux.HandleFunc(RateLimitsPattern, get(s.rateLimits))
+	mux.HandleFunc(LogLevelPattern, s.logLevel)
+	if reload != nil {
+		mux.Handle(ReloadPattern, router.ReloadHandler(reload))
+	}
+	if snapshots != nil && update != nil {
+		rollback := func(cfg config.ServiceConfig) {
+			s.Update(cfg)
+			update(cfg)
+		}
+		// the handler expects the paths without the prefix of the admin API
+		snapshotsHandler := http.StripPrefix(SnapshotsPattern, router.SnapshotsHandler(snapshots, rollback))
+		mux.Handle(SnapshotsPattern, snapshotsHandler)
+		mux.Handle(SnapshotsPattern+"/", snapshotsHandler)
+	}
+	if c.Pprof {
+		mux.HandleFunc(PprofPattern, pprof.Index)
+		mux.HandleFunc(PprofPattern+"cmdline", pprof.Cmdline)
+		mux.HandleFunc(PprofPattern+"profile", pprof.Profile)
+		mux.HandleFunc(PprofPattern+"symbol", pprof.Symbol)
+		mux.HandleFunc(PprofPattern+"trace", pprof.Trace)
+	}
+	s.handler = mux
+	return s, nil
+}
+
+// Update replaces the configuration reported by the admin API with the one loaded by the service. It does
+// nothing on a nil server
+func (s *Server) Update(cfg config.ServiceConfig) {
+	if s == nil {
+		return
+	}
+	s.current.Store(cfg)
+}
+
+// ServeHTTP implements the http.Handler interface, rejecting the requests without valid credentials with a
+// 401 status code. The rejected requests and the actions (the requests with a method other than GET) are
+// recorded into the audit log
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	actor, ok := s.authenticate(r)
+	if !ok {
+		audit.Record(s.auditEvent(audit.EventAuthentication, audit.OutcomeDenied, actor, r))
+		w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if r.Method == "GET" || r.Method == "HEAD" {
+		s.handler.ServeHTTP(w, r)
+		return
+	}
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	s.handler.ServeHTTP(sw, r)
+	e := s.auditEvent(audit.EventAdminAction, audit.OutcomeSuccess, actor, r)
+	if sw.status >= 400 {
+		e.Outcome = audit.OutcomeFailure
+	}
+	e.Details = map[string]interface{}{"status": sw.status}
+	audit.Record(e)
+}
+
+func (s *Server) auditEvent(eventType, outcome, actor string, r *http.Request) audit.Event {
+	return audit.Event{
+		Type:     eventType,
+		Outcome:  outcome,
+		Actor:    actor,
+		ClientIP: router.ClientIP(r),
+		Method:   r.Method,
+		Path:     r.URL.Path,
+	}
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements the http.ResponseWriter interface
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Run serves the admin API until the context is done
+func (s *Server) Run(ctx context.Context) error {
+	server := &http.Server{Addr: s.cfg.Address, Handler: s}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	s.logger.Info("Admin API listening on", s.cfg.Address)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// authenticate returns the actor of the request, the user name or "token" for the bearer tokens, and if the
+// credentials are valid
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		token := strings.TrimPrefix(auth, "Bearer ")
+		for _, t := range s.cfg.Tokens {
+			if equal(token, t) {
+				return "token", true
+			}
+		}
+		return "token", false
+	}
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	expected, ok := s.cfg.Users[user]
+	return user, ok && equal(password, expected)
+}
+
+// equal compares the hashes of the secrets in constant time, so their lengths are not leaked either
+func equal(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// Endpoint is an entry of the endpoint table reported by the admin API
+type Endpoint struct {
+	Endpoint string    `json:"endpoint"`
+	Method   string    `json:"method"`
+	Listener string    `json:"listener,omitempty"`
+	Timeout  string    `json:"timeout"`
+	Backends []Backend `json:"backends"`
+}
+
+// Backend is a backend of an entry of the endpoint table
+type Backend struct {
+	URLPattern string   `json:"url_pattern"`
+	Method     string   `json:"method"`
+	Hosts      []string `json:"hosts"`
+	SD         string   `json:"sd,omitempty"`
+	Encoding   string   `json:"encoding,omitempty"`
+}
+
+func (s *Server) endpoints() interface{} {
+	cfg := s.current.Load().(config.ServiceConfig)
+	table := make([]Endpoint, 0, len(cfg.Endpoints))
+	for _, e := range cfg.Endpoints {
+		entry := Endpoint{
+			Endpoint: e.Endpoint,
+			Method:   e.Method,
+			Listener: e.Listener,
+			Timeout:  e.Timeout.String(),
+			Backends: make([]Backend, 0, len(e.Backend)),
+		}
+		for _, b := range e.Backend {
+			entry.Backends = append(entry.Backends, Backend{
+				URLPattern: b.URLPattern,
+				Method:     b.Method,
+				Hosts:      b.Host,
+				SD:         b.SD,
+				Encoding:   b.Encoding,
+			})
+		}
+		table = append(table, entry)
+	}
+	return table
+}
+
+func circuitBreakers() interface{} {
+	states := proxy.CircuitBreakerStates()
+	res := make(map[string]string, len(states))
+	for name, state := range states {
+		res[name] = state.String()
+	}
+	return res
+}
+
+func (s *Server) rateLimits() interface{} {
+	cfg := s.current.Load().(config.ServiceConfig)
+	states := []router.RateLimitState{}
+	for _, e := range cfg.Endpoints {
+		if state, ok := router.RateLimitStateOf(e); ok {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// logLevelRequest is the body of the requests changing the log level of a module, or the one of the logger
+// if the module is empty
+type logLevelRequest struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+}
+
+func (s *Server) logLevel(w http.ResponseWriter, r *http.Request) {
+	lc, ok := s.logger.(logging.LevelController)
+	if !ok {
+		http.Error(w, "the logger does not support runtime level changes", http.StatusNotImplemented)
+		return
+	}
+	switch r.Method {
+	case "GET":
+	case "PUT", "POST":
+		req := logLevelRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := lc.SetLevel(req.Module, req.Level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Warning("Log level of", moduleName(req.Module), "changed to", strings.ToUpper(req.Level))
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, lc.Levels())
+}
+
+func moduleName(module string) string {
+	if module == "" {
+		return "the logger"
+	}
+	return "the module " + module
+}
+
+func get(f func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, f())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
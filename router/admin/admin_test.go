@@ -0,0 +1,220 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/router"
+)
+
+func newServer(t *testing.T, extra map[string]interface{}, reload func(), logger logging.Logger) *Server {
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{Namespace: extra},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/users",
+				Method:   "GET",
+				Timeout:  time.Second,
+				Backend:  []*config.Backend{{URLPattern: "/api/users", Method: "GET", Host: []string{"http://a"}}},
+				ExtraConfig: config.ExtraConfig{
+					router.RateLimitNamespace: map[string]interface{}{"max_rate": 10},
+				},
+			},
+		},
+	}
+	if _, err := router.NewRateLimiter(cfg.Endpoints[0]); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(cfg, reload, nil, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, err := ConfigGetter(config.ExtraConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if _, _, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{}}); err != ErrNoCredentials {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"tokens": []interface{}{"secret"}}})
+	if !ok || err != nil || cfg.Address != DefaultAddress {
+		t.Errorf("unexpected result: %+v %v %v", cfg, ok, err)
+	}
+}
+
+func TestServer_authentication(t *testing.T) {
+	s := newServer(t, map[string]interface{}{
+		"tokens": []interface{}{"secret"},
+		"users":  map[string]interface{}{"ops": "p4ss"},
+	}, nil, nil)
+
+	for name, tc := range map[string]struct {
+		auth   func(*http.Request)
+		status int
+	}{
+		"no credentials": {func(*http.Request) {}, http.StatusUnauthorized},
+		"bad token":      {func(r *http.Request) { r.Header.Set("Authorization", "Bearer secrets") }, http.StatusUnauthorized},
+		"token":          {func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		"bad password":   {func(r *http.Request) { r.SetBasicAuth("ops", "pass") }, http.StatusUnauthorized},
+		"unknown user":   {func(r *http.Request) { r.SetBasicAuth("root", "p4ss") }, http.StatusUnauthorized},
+		"user":           {func(r *http.Request) { r.SetBasicAuth("ops", "p4ss") }, http.StatusOK},
+	} {
+		r := httptest.NewRequest("GET", EndpointsPattern, nil)
+		tc.auth(r)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Errorf("%s: unexpected status code: %d", name, w.Code)
+		}
+	}
+}
+
+func TestServer_state(t *testing.T) {
+	reloads := 0
+	s := newServer(t, map[string]interface{}{"tokens": []interface{}{"secret"}}, func() { reloads++ }, nil)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("GET", EndpointsPattern)
+	endpoints := []Endpoint{}
+	if err := json.Unmarshal(w.Body.Bytes(), &endpoints); err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 1 || endpoints[0].Endpoint != "/users" || endpoints[0].Timeout != "1s" ||
+		len(endpoints[0].Backends) != 1 || endpoints[0].Backends[0].Hosts[0] != "http://a" {
+		t.Errorf("unexpected endpoints: %s", w.Body.String())
+	}
+
+	w = do("GET", RateLimitsPattern)
+	states := []router.RateLimitState{}
+	if err := json.Unmarshal(w.Body.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].Endpoint != "/users" || states[0].Config.MaxRate != 10 {
+		t.Errorf("unexpected rate limits: %s", w.Body.String())
+	}
+
+	for _, path := range []string{BackendsPattern, CircuitBreakersPattern} {
+		if w := do("GET", path); w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: unexpected response: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+	if w := do("POST", EndpointsPattern); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	if w := do("POST", ReloadPattern); w.Code != http.StatusAccepted || reloads != 1 {
+		t.Errorf("unexpected reload: %d %d", w.Code, reloads)
+	}
+
+	if w := do("GET", PprofPattern); w.Code != http.StatusNotFound {
+		t.Errorf("unexpected pprof status code: %d", w.Code)
+	}
+
+	s.Update(config.ServiceConfig{})
+	if w := do("GET", EndpointsPattern); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("unexpected endpoints: %s", w.Body.String())
+	}
+}
+
+func TestServer_snapshots(t *testing.T) {
+	snapshots, _ := config.NewSnapshots(0, "")
+	snapshots.Add("a.json", []byte(`{"version":2,"port":8080,"endpoints":[{"endpoint":"/a","backend":[{"host":["http://127.0.0.1"],"url_pattern":"/a"}]}]}`))
+	snapshots.Add("a.json", []byte(`{"version":2,"port":8081,"endpoints":[]}`))
+
+	updates := []config.ServiceConfig{}
+	s, err := NewServer(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"tokens": []interface{}{"secret"}}},
+	}, nil, snapshots, func(cfg config.ServiceConfig) { updates = append(updates, cfg) }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		method, path, token string
+		status              int
+	}{
+		{"GET", SnapshotsPattern, "", http.StatusUnauthorized},
+		{"GET", SnapshotsPattern + "/1", "", http.StatusUnauthorized},
+		{"POST", SnapshotsPattern + "/1/rollback", "", http.StatusUnauthorized},
+		{"GET", SnapshotsPattern, "secret", http.StatusOK},
+		{"GET", SnapshotsPattern + "/1", "secret", http.StatusOK},
+		{"POST", SnapshotsPattern + "/1/rollback", "secret", http.StatusAccepted},
+	} {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Errorf("%s %s: unexpected status code: %d", tc.method, tc.path, w.Code)
+		}
+	}
+
+	if len(updates) != 1 || updates[0].Port != 8080 {
+		t.Errorf("unexpected updates: %v", updates)
+	}
+	if endpoints := s.endpoints().([]Endpoint); len(endpoints) != 1 || endpoints[0].Endpoint != "/a" {
+		t.Errorf("the endpoint table was not updated: %v", endpoints)
+	}
+}
+
+func TestServer_logLevel(t *testing.T) {
+	logger, err := logging.NewStructuredLogger(logging.Config{Level: "error"}, logging.NewTextHandler(ioutil.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(t, map[string]interface{}{"tokens": []interface{}{"secret"}, "pprof": true}, nil, logger)
+
+	r := httptest.NewRequest("PUT", LogLevelPattern, bytes.NewBufferString(`{"module":"proxy","level":"debug"}`))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"":"ERROR","proxy":"DEBUG"}` {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("PUT", LogLevelPattern, bytes.NewBufferString(`{"level":"verbose"}`))
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	r = httptest.NewRequest("GET", PprofPattern+"cmdline", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected pprof status code: %d", w.Code)
+	}
+
+	plain, _ := logging.NewLogger("ERROR", ioutil.Discard, "")
+	s = newServer(t, map[string]interface{}{"tokens": []interface{}{"secret"}}, nil, plain)
+	r = httptest.NewRequest("GET", LogLevelPattern, nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+}
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/devopsfaith/krakend/audit"
+	"github.com/devopsfaith/krakend/config"
+)
+
+// auditRequest records the decision about the request to the endpoint into the audit log
+func auditRequest(eventType, outcome string, cfg *config.EndpointConfig, r *http.Request, actor string, reason error) {
+	if !audit.Enabled() {
+		return
+	}
+	e := audit.Event{
+		Type:      eventType,
+		Outcome:   outcome,
+		Actor:     actor,
+		ClientIP:  ClientIP(r),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Endpoint:  cfg.Endpoint,
+		TraceID:   TraceID(r),
+		RequestID: RequestID(r),
+	}
+	if reason != nil {
+		e.Reason = reason.Error()
+	}
+	audit.Record(e)
+}
@@ -0,0 +1,89 @@
+package router
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/devopsfaith/krakend/audit"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+)
+
+type auditSink struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (s *auditSink) Write(events []audit.Event) error {
+	s.mu.Lock()
+	s.events = append(s.events, events...)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *auditSink) Close() error { return nil }
+
+func TestAudit(t *testing.T) {
+	sink := &auditSink{}
+	audit.RegisterSink("router_test", func(_ map[string]interface{}) (audit.Sink, error) { return sink, nil })
+	logger, _ := logging.NewLogger("CRITICAL", io.Discard, "")
+	closeAudit, err := audit.Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{audit.Namespace: map[string]interface{}{
+		"events": []interface{}{audit.EventIPBlock, audit.EventRateLimit},
+		"sinks":  []interface{}{map[string]interface{}{"type": "router_test"}},
+	}}}, logger)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+
+	filter, err := NewIPFilter(&config.EndpointConfig{
+		Endpoint:    "/filtered",
+		ExtraConfig: config.ExtraConfig{IPFilterNamespace: map[string]interface{}{"deny": []interface{}{"10.0.0.0/8"}}},
+	})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	for _, ip := range []string{"1.1.1.1", "10.0.0.1"} {
+		r := httptest.NewRequest("GET", "/filtered", nil)
+		r.RemoteAddr = ip + ":1234"
+		filter(r)
+	}
+
+	rl, err := NewRateLimiter(&config.EndpointConfig{
+		Method:      "GET",
+		Endpoint:    "/limited",
+		ExtraConfig: config.ExtraConfig{RateLimitNamespace: map[string]interface{}{"max_rate": 1, "capacity": 1}},
+	})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("GET", "/limited", nil)
+		r.RemoteAddr = "1.1.1.1:1234"
+		rl(r)
+	}
+
+	if err := closeAudit(context.Background()); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+
+	if len(sink.events) != 2 {
+		t.Errorf("unexpected events: %v", sink.events)
+		return
+	}
+	for i, expected := range []audit.Event{
+		{Type: audit.EventIPBlock, Outcome: audit.OutcomeDenied, ClientIP: "10.0.0.1", Endpoint: "/filtered"},
+		{Type: audit.EventRateLimit, Outcome: audit.OutcomeDenied, ClientIP: "1.1.1.1", Endpoint: "/limited"},
+	} {
+		e := sink.events[i]
+		if e.Type != expected.Type || e.Outcome != expected.Outcome || e.ClientIP != expected.ClientIP ||
+			e.Endpoint != expected.Endpoint || e.Reason == "" {
+			t.Errorf("#%d: unexpected event: %+v", i, e)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package router
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// ErrRequestEntityTooLarge is the error returned when the body of the request exceeds the max size of the
+// endpoint
+var ErrRequestEntityTooLarge = errors.New("request entity too large")
+
+// LimitRequestBody limits the body of the request to the max size of the endpoint. It returns the
+// ErrRequestEntityTooLarge if the declared content length already exceeds it. Otherwise, the body fails
+// once the limit is exceeded, and the returned function reports it, so the endpoint can respond with a 413
+// status code instead of the error of the backends
+func LimitRequestBody(cfg *config.EndpointConfig, r *http.Request) (func() bool, error) {
+	if cfg.MaxBodySize <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return func() bool { return false }, nil
+	}
+	if r.ContentLength > cfg.MaxBodySize {
+		return nil, ErrRequestEntityTooLarge
+	}
+	body := &limitedBody{ReadCloser: r.Body, remaining: cfg.MaxBodySize}
+	r.Body = body
+	return func() bool { return atomic.LoadInt32(&body.exceeded) == 1 }, nil
+}
+
+// limitedBody fails with the ErrRequestEntityTooLarge once more than the remaining bytes are read
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  int32
+}
+
+// Read implements the io.Reader interface
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&b.exceeded) == 1 {
+		return 0, ErrRequestEntityTooLarge
+	}
+	// one more byte than the remaining ones is read, so a body of the exact max size is not rejected
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		atomic.StoreInt32(&b.exceeded, 1)
+		return int(b.remaining), ErrRequestEntityTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
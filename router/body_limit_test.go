@@ -0,0 +1,55 @@
+package router
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestLimitRequestBody(t *testing.T) {
+	cfg := &config.EndpointConfig{MaxBodySize: 4}
+
+	req := httptest.NewRequest("POST", "/supu", strings.NewReader("1234"))
+	exceeded, err := LimitRequestBody(cfg, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadAll(req.Body); err != nil || string(b) != "1234" {
+		t.Errorf("unexpected body: %s %v", string(b), err)
+	}
+	if exceeded() {
+		t.Error("the body of the max size must be accepted")
+	}
+
+	req = httptest.NewRequest("POST", "/supu", strings.NewReader("12345"))
+	if _, err := LimitRequestBody(cfg, req); err != ErrRequestEntityTooLarge {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/supu", strings.NewReader("12345"))
+	req.ContentLength = -1
+	exceeded, err = LimitRequestBody(cfg, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadAll(req.Body); err != ErrRequestEntityTooLarge || string(b) != "1234" {
+		t.Errorf("unexpected body: %s %v", string(b), err)
+	}
+	if !exceeded() {
+		t.Error("the exceeded body was not reported")
+	}
+}
+
+func TestLimitRequestBody_unlimited(t *testing.T) {
+	req := httptest.NewRequest("POST", "/supu", strings.NewReader(strings.Repeat("a", 1024)))
+	exceeded, err := LimitRequestBody(&config.EndpointConfig{}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(req.Body); len(b) != 1024 || exceeded() {
+		t.Errorf("unexpected result: %d bytes", len(b))
+	}
+}
@@ -0,0 +1,406 @@
+package router
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// CaptureNamespace is the key to look for the debug capture options in the extra config of the service and
+// the endpoints
+const CaptureNamespace = "github.com/devopsfaith/krakend/router/capture"
+
+// CapturesPattern is the path of the endpoint exposing the captured exchanges:
+//
+// - GET CapturesPattern lists the kept exchanges, the newest first
+//
+// - GET CapturesPattern/{id} returns the exchange
+const CapturesPattern = "/__captures"
+
+// CaptureHeaderName is the header enabling the capture of a request with a token signed by the secret of the
+// service
+const CaptureHeaderName = "X-Krakend-Capture"
+
+// ErrNoCaptureSecret is the error returned when the service declares the debug capture without a secret, so
+// the captured exchanges could not be protected
+var ErrNoCaptureSecret = errors.New("the debug capture requires a 'secret'")
+
+// Default values of the debug capture options
+const (
+	DefaultCaptureSize        = 100
+	DefaultCaptureMaxBodySize = 64 * 1024
+)
+
+// CaptureConfig defines the debug capture of the service. The endpoints only declare the Enabled option
+type CaptureConfig struct {
+	// Enabled captures all the requests of the endpoint. The rest of the requests are captured when they
+	// carry a valid token in the CaptureHeaderName header
+	Enabled bool `json:"enabled"`
+	// Size is the number of exchanges kept. By default, the DefaultCaptureSize
+	Size int `json:"size"`
+	// Secret signs the capture tokens, which also grant access to the captures endpoint. It is required by
+	// the service
+	Secret string `json:"secret"`
+	// MaxBodySize is the number of bytes recorded of every body. By default, the DefaultCaptureMaxBodySize
+	MaxBodySize int `json:"max_body_size"`
+	// Redact are the headers replaced in the captures, besides the proxy.DefaultRedactedHeaders
+	Redact []string `json:"redact"`
+}
+
+// CaptureConfigGetter parses the debug capture options from the extra config. The second value is false if
+// they are not declared
+func CaptureConfigGetter(extra config.ExtraConfig) (CaptureConfig, bool, error) {
+	cfg := CaptureConfig{}
+	v, ok := extra[CaptureNamespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false, err
+	}
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultCaptureSize
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultCaptureMaxBodySize
+	}
+	return cfg, true, nil
+}
+
+// CaptureToken returns a token enabling the capture of the requests until the expiration
+func CaptureToken(secret string, expiration time.Time) string {
+	exp := strconv.FormatInt(expiration.Unix(), 10)
+	return exp + "." + captureSignature(secret, exp)
+}
+
+func captureSignature(secret, exp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(exp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validCaptureToken(secret, token string) bool {
+	parts := strings.SplitN(token, ".", 2)
+	if secret == "" || len(parts) != 2 {
+		return false
+	}
+	exp, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(parts[1]), []byte(captureSignature(secret, parts[0])))
+}
+
+// CapturedRequest is a request received by an endpoint
+type CapturedRequest struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers"`
+	Body    string              `json:"body,omitempty"`
+}
+
+// CapturedResponse is the response returned to the client
+type CapturedResponse struct {
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body,omitempty"`
+}
+
+// CapturedExchange is a request received by an endpoint, its response and the requests sent to the backends
+type CapturedExchange struct {
+	ID       uint64              `json:"id"`
+	Time     time.Time           `json:"time"`
+	Endpoint string              `json:"endpoint"`
+	Duration time.Duration       `json:"duration"`
+	Request  CapturedRequest     `json:"request"`
+	Response CapturedResponse    `json:"response"`
+	Backends []proxy.CapturedLeg `json:"backends"`
+}
+
+// CaptureBuffer is a ring buffer keeping the last captured exchanges
+type CaptureBuffer struct {
+	mu        sync.RWMutex
+	size      int
+	lastID    uint64
+	exchanges []CapturedExchange
+}
+
+// NewCaptureBuffer returns a CaptureBuffer keeping the last size exchanges
+func NewCaptureBuffer(size int) *CaptureBuffer {
+	return &CaptureBuffer{size: size}
+}
+
+// DefaultCaptureBuffer is the buffer shared by the endpoints of the service and the captures endpoint
+var DefaultCaptureBuffer = NewCaptureBuffer(DefaultCaptureSize)
+
+// Resize changes the number of exchanges kept, discarding the oldest ones
+func (b *CaptureBuffer) Resize(size int) {
+	b.mu.Lock()
+	b.size = size
+	if len(b.exchanges) > size {
+		b.exchanges = append([]CapturedExchange{}, b.exchanges[len(b.exchanges)-size:]...)
+	}
+	b.mu.Unlock()
+}
+
+// Add stores the exchange with a new id, discarding the oldest one if the buffer is full
+func (b *CaptureBuffer) Add(e CapturedExchange) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	e.ID = b.lastID
+	if len(b.exchanges) >= b.size {
+		b.exchanges = b.exchanges[1:]
+	}
+	b.exchanges = append(b.exchanges, e)
+	return e.ID
+}
+
+// List returns the kept exchanges, the newest first
+func (b *CaptureBuffer) List() []CapturedExchange {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	list := make([]CapturedExchange, len(b.exchanges))
+	for i, e := range b.exchanges {
+		list[len(list)-1-i] = e
+	}
+	return list
+}
+
+// Get returns the exchange with the id. The second value is false if it is not kept anymore
+func (b *CaptureBuffer) Get(id uint64) (CapturedExchange, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, e := range b.exchanges {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return CapturedExchange{}, false
+}
+
+// Capturer decides which requests of an endpoint are captured and records them into a CaptureBuffer
+type Capturer struct {
+	buffer      *CaptureBuffer
+	endpoint    string
+	enabled     bool
+	secret      string
+	maxBodySize int
+	redacted    []string
+}
+
+// NewCapturer returns the Capturer of the endpoint, storing the exchanges into the DefaultCaptureBuffer. It
+// returns nil if the service does not declare the debug capture, and ErrNoCaptureSecret if the service does
+// not declare its secret
+func NewCapturer(service config.ExtraConfig, endpoint *config.EndpointConfig) (*Capturer, error) {
+	cfg, ok, err := CaptureConfigGetter(service)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if cfg.Secret == "" {
+		return nil, ErrNoCaptureSecret
+	}
+	endpointCfg, _, err := CaptureConfigGetter(endpoint.ExtraConfig)
+	if err != nil {
+		return nil, err
+	}
+	DefaultCaptureBuffer.Resize(cfg.Size)
+
+	// the capture tokens also grant access to the captures endpoint
+	redacted := append([]string{CaptureHeaderName}, proxy.DefaultRedactedHeaders...)
+	for _, h := range cfg.Redact {
+		redacted = append(redacted, http.CanonicalHeaderKey(h))
+	}
+	return &Capturer{
+		buffer:      DefaultCaptureBuffer,
+		endpoint:    endpoint.Endpoint,
+		enabled:     endpointCfg.Enabled,
+		secret:      cfg.Secret,
+		maxBodySize: cfg.MaxBodySize,
+		redacted:    redacted,
+	}, nil
+}
+
+// Start returns the CaptureRecorder of the request, or nil if it is not captured. The request of the recorder
+// must replace the received one
+func (c *Capturer) Start(r *http.Request) (*CaptureRecorder, error) {
+	if !c.enabled && !validCaptureToken(c.secret, r.Header.Get(CaptureHeaderName)) {
+		return nil, nil
+	}
+	capture := &proxy.Capture{MaxBodySize: c.maxBodySize, Redacted: c.redacted}
+	rec := &CaptureRecorder{
+		capturer: c,
+		capture:  capture,
+		start:    time.Now(),
+		exchange: CapturedExchange{
+			Endpoint: c.endpoint,
+			Request: CapturedRequest{
+				Method:  r.Method,
+				URL:     r.URL.String(),
+				Headers: capture.Headers(r.Header),
+			},
+		},
+	}
+	rec.exchange.Time = rec.start
+	if r.Body != nil && r.Body != http.NoBody {
+		// only the recorded part of the body is buffered
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(c.maxBodySize)))
+		if err != nil {
+			return nil, err
+		}
+		rec.exchange.Request.Body = string(b)
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+	}
+	rec.request = r.WithContext(proxy.NewCaptureContext(r.Context(), capture))
+	return rec, nil
+}
+
+// CaptureRecorder records an exchange while it is served
+type CaptureRecorder struct {
+	capturer *Capturer
+	capture  *proxy.Capture
+	request  *http.Request
+	start    time.Time
+	body     []byte
+	exchange CapturedExchange
+}
+
+// Request returns the request carrying the capture of the backend legs in its context
+func (r *CaptureRecorder) Request() *http.Request {
+	return r.request
+}
+
+// Write records the first bytes of the response body
+func (r *CaptureRecorder) Write(b []byte) {
+	if remaining := r.capturer.maxBodySize - len(r.body); remaining > 0 {
+		if len(b) > remaining {
+			b = b[:remaining]
+		}
+		r.body = append(r.body, b...)
+	}
+}
+
+// Finish stores the exchange with the status code and the headers of the response
+func (r *CaptureRecorder) Finish(status int, headers http.Header) {
+	r.exchange.Duration = time.Since(r.start)
+	r.exchange.Response = CapturedResponse{
+		StatusCode: status,
+		Headers:    r.capture.Headers(headers),
+		Body:       string(r.body),
+	}
+	r.exchange.Backends = r.capture.Legs()
+	r.capturer.buffer.Add(r.exchange)
+}
+
+// CaptureHandler decorates the handler, recording the captured requests of the endpoint. The websocket
+// handshakes are not captured
+func CaptureHandler(c *Capturer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec, err := c.Start(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if rec == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &captureWriter{ResponseWriter: w, rec: rec}
+		next.ServeHTTP(cw, rec.Request())
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		rec.Finish(cw.status, w.Header())
+	})
+}
+
+// captureWriter records the status code and the body of the response
+type captureWriter struct {
+	http.ResponseWriter
+	rec    *CaptureRecorder
+	status int
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.rec.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the decorated writer, so the http.ResponseController can reach it
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// CapturesHandler returns the handler of the CapturesPattern, exposing the exchanges of the
+// DefaultCaptureBuffer. The requests must carry a valid token in the CaptureHeaderName header, so all of them
+// are rejected without a secret. Register it for both the CapturesPattern and the CapturesPattern with a
+// trailing slash
+func CapturesHandler(cfg CaptureConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validCaptureToken(cfg.Secret, r.Header.Get(CaptureHeaderName)) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if r.Method != "GET" {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, CapturesPattern), "/")
+		if id == "" {
+			writeJSON(w, http.StatusOK, DefaultCaptureBuffer.List())
+			return
+		}
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		e, ok := DefaultCaptureBuffer.Get(n)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, e)
+	}
+}
@@ -0,0 +1,144 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestCaptureToken(t *testing.T) {
+	token := CaptureToken("secret", time.Now().Add(time.Minute))
+	if !validCaptureToken("secret", token) {
+		t.Error("the token must be valid")
+	}
+	for _, tc := range []struct{ secret, token string }{
+		{"other", token},
+		{"", token},
+		{"secret", CaptureToken("secret", time.Now().Add(-time.Minute))},
+		{"secret", "supu"},
+		{"secret", strings.Replace(token, ".", ".0", 1)},
+	} {
+		if validCaptureToken(tc.secret, tc.token) {
+			t.Errorf("the token %s must be invalid with the secret %q", tc.token, tc.secret)
+		}
+	}
+}
+
+func TestCaptureBuffer(t *testing.T) {
+	b := NewCaptureBuffer(2)
+	for i := 0; i < 3; i++ {
+		b.Add(CapturedExchange{Endpoint: fmt.Sprintf("/%d", i)})
+	}
+	list := b.List()
+	if len(list) != 2 || list[0].ID != 3 || list[1].ID != 2 || list[0].Endpoint != "/2" {
+		t.Errorf("unexpected exchanges: %v", list)
+	}
+	if _, ok := b.Get(1); ok {
+		t.Error("the oldest exchange must be discarded")
+	}
+	b.Resize(1)
+	if e, ok := b.Get(3); !ok || len(b.List()) != 1 {
+		t.Errorf("unexpected exchanges after resizing: %v", e)
+	}
+}
+
+func TestCaptureHandler(t *testing.T) {
+	DefaultCaptureBuffer = NewCaptureBuffer(DefaultCaptureSize)
+	service := config.ExtraConfig{CaptureNamespace: map[string]interface{}{"secret": "secret", "redact": []interface{}{"x-api-key"}}}
+	endpoint := &config.EndpointConfig{Endpoint: "/users"}
+	capturer, err := NewCapturer(service, endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capturer == nil {
+		t.Fatal("the endpoint must be captured with a token")
+	}
+
+	h := CaptureHandler(capturer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(b)
+	}))
+
+	token := CaptureToken("secret", time.Now().Add(time.Minute))
+	for _, captured := range []bool{false, true} {
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"supu"}`))
+		req.Header.Set("X-Api-Key", "key")
+		if captured {
+			req.Header.Set(CaptureHeaderName, token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated || w.Body.String() != `{"name":"supu"}` {
+			t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	admin := CapturesHandler(CaptureConfig{Secret: "secret"})
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest("GET", CapturesPattern, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", CapturesPattern+"/1", nil)
+	req.Header.Set(CaptureHeaderName, token)
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	}
+	var e CapturedExchange
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Endpoint != "/users" || e.Request.Body != `{"name":"supu"}` || e.Response.StatusCode != http.StatusCreated ||
+		e.Response.Body != `{"name":"supu"}` {
+		t.Errorf("unexpected exchange: %+v", e)
+	}
+	if v := e.Request.Headers["X-Api-Key"]; len(v) != 1 || v[0] != "[REDACTED]" {
+		t.Errorf("unexpected request headers: %v", e.Request.Headers)
+	}
+	if v := e.Request.Headers[CaptureHeaderName]; len(v) != 1 || v[0] != "[REDACTED]" {
+		t.Errorf("unexpected request headers: %v", e.Request.Headers)
+	}
+	if len(DefaultCaptureBuffer.List()) != 1 {
+		t.Errorf("unexpected exchanges: %v", DefaultCaptureBuffer.List())
+	}
+}
+
+func TestNewCapturer_disabled(t *testing.T) {
+	enabled := &config.EndpointConfig{ExtraConfig: config.ExtraConfig{CaptureNamespace: map[string]interface{}{"enabled": true}}}
+	if c, err := NewCapturer(config.ExtraConfig{}, enabled); c != nil || err != nil {
+		t.Errorf("the service does not declare the debug capture: %v", err)
+	}
+	service := config.ExtraConfig{CaptureNamespace: map[string]interface{}{}}
+	if c, err := NewCapturer(service, enabled); c != nil || err != ErrNoCaptureSecret {
+		t.Errorf("the debug capture without a secret must be rejected: %v", err)
+	}
+	service = config.ExtraConfig{CaptureNamespace: map[string]interface{}{"secret": "secret"}}
+	if c, err := NewCapturer(service, enabled); c == nil || err != nil {
+		t.Errorf("the endpoint must be captured: %v", err)
+	}
+}
+
+func TestCapturesHandler_noSecret(t *testing.T) {
+	h := CapturesHandler(CaptureConfig{})
+	for _, token := range []string{"", CaptureToken("", time.Now().Add(time.Minute))} {
+		req := httptest.NewRequest("GET", CapturesPattern, nil)
+		req.Header.Set(CaptureHeaderName, token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("unexpected status code: %d", w.Code)
+		}
+	}
+}
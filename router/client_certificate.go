@@ -0,0 +1,161 @@
+package router
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/devopsfaith/krakend/audit"
+	"github.com/devopsfaith/krakend/config"
+)
+
+// ClientCertificateNamespace is the key to look for the client certificate policy in the extra config of
+// the endpoints
+const ClientCertificateNamespace = "github.com/devopsfaith/krakend/router/client_certificate"
+
+var (
+	// ErrClientCertificateRequired is the error returned when the endpoint requires a verified client
+	// certificate and the request does not present it
+	ErrClientCertificateRequired = errors.New("client certificate required")
+	// ErrClientCertificateNotAllowed is the error returned when the client certificate is not allowed by
+	// the policy of the endpoint
+	ErrClientCertificateNotAllowed = errors.New("client certificate not allowed")
+)
+
+// ClientCertificateConfig is the client certificate policy of an endpoint. Only the certificates verified
+// with the client CAs of the TLS settings of the service are considered
+type ClientCertificateConfig struct {
+	// Required rejects the requests without a verified client certificate
+	Required bool `json:"required"`
+	// AllowedNames restricts the certificates to the ones with any of the names as common name or subject
+	// alternative name (DNS, email or URI)
+	AllowedNames []string `json:"allowed_names"`
+	// Headers are the request headers set with the fields of the certificate (subject, common_name, issuer,
+	// serial, fingerprint, dns_names, emails, uris, not_after or certificate), by header name
+	Headers map[string]string `json:"headers"`
+}
+
+// ClientCertificateVerifier applies the client certificate policy to the request, setting the configured
+// headers. If the request is rejected, it returns the status code and the error
+type ClientCertificateVerifier func(*http.Request) (int, error)
+
+var clientCertificateFields = map[string]func(*x509.Certificate) string{
+	"subject":     func(c *x509.Certificate) string { return c.Subject.String() },
+	"common_name": func(c *x509.Certificate) string { return c.Subject.CommonName },
+	"issuer":      func(c *x509.Certificate) string { return c.Issuer.String() },
+	"serial":      func(c *x509.Certificate) string { return c.SerialNumber.String() },
+	"fingerprint": func(c *x509.Certificate) string {
+		sum := sha256.Sum256(c.Raw)
+		return hex.EncodeToString(sum[:])
+	},
+	"dns_names": func(c *x509.Certificate) string { return strings.Join(c.DNSNames, ",") },
+	"emails":    func(c *x509.Certificate) string { return strings.Join(c.EmailAddresses, ",") },
+	"uris": func(c *x509.Certificate) string {
+		uris := make([]string, len(c.URIs))
+		for i, u := range c.URIs {
+			uris[i] = u.String()
+		}
+		return strings.Join(uris, ",")
+	},
+	"not_after": func(c *x509.Certificate) string { return c.NotAfter.UTC().Format(time.RFC3339) },
+	"certificate": func(c *x509.Certificate) string {
+		return url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})))
+	},
+}
+
+// NewClientCertificateVerifier creates a ClientCertificateVerifier with the policy of the extra config of
+// the endpoint. It returns a nil ClientCertificateVerifier if the endpoint does not declare a policy
+func NewClientCertificateVerifier(cfg *config.EndpointConfig) (ClientCertificateVerifier, error) {
+	v, ok := cfg.ExtraConfig[ClientCertificateNamespace]
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	ccCfg := ClientCertificateConfig{}
+	if err := json.Unmarshal(b, &ccCfg); err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]func(*x509.Certificate) string, len(ccCfg.Headers))
+	for header, field := range ccCfg.Headers {
+		f, ok := clientCertificateFields[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown client certificate field %s", field)
+		}
+		headers[http.CanonicalHeaderKey(header)] = f
+	}
+	allowed := make(map[string]bool, len(ccCfg.AllowedNames))
+	for _, name := range ccCfg.AllowedNames {
+		allowed[name] = true
+	}
+	required := ccCfg.Required || len(allowed) > 0
+
+	return func(r *http.Request) (int, error) {
+		// the headers sent by the client are dropped, so they can not be spoofed
+		for header := range headers {
+			r.Header.Del(header)
+		}
+		cert := verifiedClientCertificate(r)
+		if cert == nil {
+			if required {
+				auditRequest(audit.EventAuthentication, audit.OutcomeDenied, cfg, r, "", ErrClientCertificateRequired)
+				return http.StatusUnauthorized, ErrClientCertificateRequired
+			}
+			return 0, nil
+		}
+		if len(allowed) > 0 && !isAllowedCertificate(cert, allowed) {
+			auditRequest(audit.EventAuthorization, audit.OutcomeDenied, cfg, r, cert.Subject.CommonName,
+				ErrClientCertificateNotAllowed)
+			return http.StatusForbidden, ErrClientCertificateNotAllowed
+		}
+		for header, f := range headers {
+			if v := f(cert); v != "" {
+				r.Header.Set(header, v)
+			}
+		}
+		auditRequest(audit.EventAuthentication, audit.OutcomeAllowed, cfg, r, cert.Subject.CommonName, nil)
+		return 0, nil
+	}, nil
+}
+
+// verifiedClientCertificate returns the leaf of the first verified chain of the client, or nil if the
+// client did not present a certificate or it was not verified
+func verifiedClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+func isAllowedCertificate(cert *x509.Certificate, allowed map[string]bool) bool {
+	if allowed[cert.Subject.CommonName] {
+		return true
+	}
+	for _, name := range cert.DNSNames {
+		if allowed[name] {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if allowed[email] {
+			return true
+		}
+	}
+	for _, u := range cert.URIs {
+		if allowed[u.String()] {
+			return true
+		}
+	}
+	return false
+}
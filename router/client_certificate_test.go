@@ -0,0 +1,152 @@
+package router
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func newClientCertificateVerifier(t *testing.T, cfg map[string]interface{}) ClientCertificateVerifier {
+	verifier, err := NewClientCertificateVerifier(&config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{ClientCertificateNamespace: cfg},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verifier
+}
+
+func requestWithCertificate(cert *x509.Certificate) *http.Request {
+	r, _ := http.NewRequest("GET", "https://example.com/", nil)
+	r.Header.Set("X-Client-Cn", "spoofed")
+	if cert != nil {
+		r.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+	}
+	return r
+}
+
+func TestNewClientCertificateVerifier_disabled(t *testing.T) {
+	verifier, err := NewClientCertificateVerifier(&config.EndpointConfig{})
+	if err != nil || verifier != nil {
+		t.Errorf("unexpected result: %v", err)
+	}
+}
+
+func TestNewClientCertificateVerifier_unknownField(t *testing.T) {
+	_, err := NewClientCertificateVerifier(&config.EndpointConfig{
+		ExtraConfig: config.ExtraConfig{ClientCertificateNamespace: map[string]interface{}{
+			"headers": map[string]interface{}{"X-Client": "unknown"},
+		}},
+	})
+	if err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestClientCertificateVerifier(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.com/billing")
+	cert := &x509.Certificate{
+		Raw:            []byte("certificate"),
+		SerialNumber:   big.NewInt(42),
+		Subject:        pkix.Name{CommonName: "client", Organization: []string{"Example"}},
+		Issuer:         pkix.Name{CommonName: "Example CA"},
+		DNSNames:       []string{"client.example.com", "other.example.com"},
+		EmailAddresses: []string{"client@example.com"},
+		URIs:           []*url.URL{spiffe},
+		NotAfter:       time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	verifier := newClientCertificateVerifier(t, map[string]interface{}{
+		"headers": map[string]interface{}{
+			"X-Client-CN":          "common_name",
+			"X-Client-Subject":     "subject",
+			"X-Client-Issuer":      "issuer",
+			"X-Client-Serial":      "serial",
+			"X-Client-Fingerprint": "fingerprint",
+			"X-Client-DNS":         "dns_names",
+			"X-Client-Emails":      "emails",
+			"X-Client-URIs":        "uris",
+			"X-Client-Not-After":   "not_after",
+		},
+	})
+
+	r := requestWithCertificate(nil)
+	if status, err := verifier(r); status != 0 || err != nil {
+		t.Errorf("unexpected result: %d %v", status, err)
+	}
+	if v := r.Header.Get("X-Client-Cn"); v != "" {
+		t.Errorf("the header sent by the client must be dropped: %s", v)
+	}
+
+	r = requestWithCertificate(cert)
+	if status, err := verifier(r); status != 0 || err != nil {
+		t.Errorf("unexpected result: %d %v", status, err)
+	}
+	for header, expected := range map[string]string{
+		"X-Client-Cn":          "client",
+		"X-Client-Subject":     "CN=client,O=Example",
+		"X-Client-Issuer":      "CN=Example CA",
+		"X-Client-Serial":      "42",
+		"X-Client-Fingerprint": fmt.Sprintf("%x", sha256.Sum256([]byte("certificate"))),
+		"X-Client-Dns":         "client.example.com,other.example.com",
+		"X-Client-Emails":      "client@example.com",
+		"X-Client-Uris":        "spiffe://example.com/billing",
+		"X-Client-Not-After":   "2030-01-02T03:04:05Z",
+	} {
+		if v := r.Header.Get(header); v != expected {
+			t.Errorf("%s: unexpected value: %s", header, v)
+		}
+	}
+
+	r = requestWithCertificate(cert)
+	r.TLS.VerifiedChains = nil
+	if _, err := verifier(r); err != nil || r.Header.Get("X-Client-Cn") != "" {
+		t.Errorf("the fields of the unverified certificates must not be injected: %v", err)
+	}
+}
+
+func TestClientCertificateVerifier_policy(t *testing.T) {
+	verifier := newClientCertificateVerifier(t, map[string]interface{}{"required": true})
+	if status, err := verifier(requestWithCertificate(nil)); status != http.StatusUnauthorized || err != ErrClientCertificateRequired {
+		t.Errorf("unexpected result: %d %v", status, err)
+	}
+
+	verifier = newClientCertificateVerifier(t, map[string]interface{}{
+		"allowed_names": []interface{}{"billing.example.com", "spiffe://example.com/orders"},
+	})
+	if status, err := verifier(requestWithCertificate(nil)); status != http.StatusUnauthorized || err != ErrClientCertificateRequired {
+		t.Errorf("unexpected result: %d %v", status, err)
+	}
+
+	orders, _ := url.Parse("spiffe://example.com/orders")
+	for _, tc := range []struct {
+		name    string
+		cert    *x509.Certificate
+		allowed bool
+	}{
+		{name: "common name", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "billing.example.com"}}, allowed: true},
+		{name: "dns name", cert: &x509.Certificate{DNSNames: []string{"other.com", "billing.example.com"}}, allowed: true},
+		{name: "uri", cert: &x509.Certificate{URIs: []*url.URL{orders}}, allowed: true},
+		{name: "unknown", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "other.example.com"}}},
+	} {
+		status, err := verifier(requestWithCertificate(tc.cert))
+		if tc.allowed && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.allowed && (status != http.StatusForbidden || err != ErrClientCertificateNotAllowed) {
+			t.Errorf("%s: unexpected result: %d %v", tc.name, status, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// ClientIPNamespace is the key to look for the client IP resolution options in the extra config of the service
+const ClientIPNamespace = "github.com/devopsfaith/krakend/router/client_ip"
+
+// DefaultClientIPHeaders are the headers declaring the client IP, in order of preference, when the options
+// do not declare them
+var DefaultClientIPHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"}
+
+// ClientIPConfig defines how the IP of the clients is resolved when the gateway is behind other proxies
+type ClientIPConfig struct {
+	// TrustedProxies are the IPs and CIDRs of the proxies whose headers are trusted. The headers of the
+	// rest of the peers are ignored
+	TrustedProxies []string `json:"trusted_proxies"`
+	// Headers are the headers declaring the client IP, in order of preference: Forwarded (RFC 7239),
+	// X-Forwarded-For or any header with a single IP, like X-Real-IP. By default, the DefaultClientIPHeaders
+	Headers []string `json:"headers"`
+	// ProxyProtocol expects the connections from the trusted proxies to start with a PROXY protocol (v1 or v2)
+	// header, declaring the address of the client
+	ProxyProtocol bool `json:"proxy_protocol"`
+}
+
+// ClientIPConfigGetter parses the client IP resolution options from the extra config of the service. The
+// second value is false if they are not declared
+func ClientIPConfigGetter(extra config.ExtraConfig) (ClientIPConfig, bool, error) {
+	cfg := ClientIPConfig{}
+	v, ok := extra[ClientIPNamespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false, err
+	}
+	if len(cfg.Headers) == 0 {
+		cfg.Headers = DefaultClientIPHeaders
+	}
+	return cfg, true, nil
+}
+
+// TrustedProxies is a set of IP networks
+type TrustedProxies []*net.IPNet
+
+// NewTrustedProxies parses the IPs and CIDRs of the proxies
+func NewTrustedProxies(cidrs []string) (TrustedProxies, error) {
+	networks := make(TrustedProxies, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Contains checks if the IP belongs to any of the networks
+func (t TrustedProxies) Contains(ip net.IP) bool {
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIPResolver resolves the IP of the client of the requests, walking the addresses declared by the
+// trusted proxies from the nearest one
+type ClientIPResolver struct {
+	trusted TrustedProxies
+	headers []string
+}
+
+// NewClientIPResolver returns a resolver with the options
+func NewClientIPResolver(cfg ClientIPConfig) (*ClientIPResolver, error) {
+	trusted, err := NewTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	headers := cfg.Headers
+	if len(headers) == 0 {
+		headers = DefaultClientIPHeaders
+	}
+	return &ClientIPResolver{trusted: trusted, headers: headers}, nil
+}
+
+// Resolve returns the IP of the client of the request. If the peer is a trusted proxy, it is the nearest
+// address not belonging to a trusted proxy declared by the first header present. Otherwise, it is the
+// address of the peer
+func (c *ClientIPResolver) Resolve(r *http.Request) string {
+	remote := remoteIP(r)
+	ip := net.ParseIP(remote)
+	if ip == nil || !c.trusted.Contains(ip) {
+		return remote
+	}
+	for _, name := range c.headers {
+		values := r.Header[http.CanonicalHeaderKey(name)]
+		if len(values) == 0 {
+			continue
+		}
+		var hops []string
+		if strings.EqualFold(name, "Forwarded") {
+			hops = forwardedHops(values)
+		} else {
+			hops = strings.Split(strings.Join(values, ","), ",")
+		}
+		return c.walk(remote, hops)
+	}
+	return remote
+}
+
+// walk returns the nearest hop not belonging to a trusted proxy. If a hop is not a valid IP, the last
+// valid one is returned
+func (c *ClientIPResolver) walk(client string, hops []string) string {
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHop(hops[i])
+		if ip == nil {
+			return client
+		}
+		client = ip.String()
+		if !c.trusted.Contains(ip) {
+			return client
+		}
+	}
+	return client
+}
+
+// forwardedHops returns the for parameters of the elements of the Forwarded headers (RFC 7239)
+func forwardedHops(values []string) []string {
+	hops := []string{}
+	for _, element := range strings.Split(strings.Join(values, ","), ",") {
+		hop := ""
+		for _, pair := range strings.Split(element, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+				hop = strings.Trim(kv[1], `"`)
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// parseHop parses an IP with an optional port, and the IPv6 addresses between brackets
+func parseHop(hop string) net.IP {
+	hop = strings.TrimSpace(hop)
+	if ip := net.ParseIP(hop); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.Trim(hop, "[]"))
+}
+
+type clientIPKey struct{}
+
+// ClientIP returns the IP of the client of the request, resolved by the ClientIPHandler. If it was not
+// resolved, it is the address of the peer
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// ClientIPHandler decorates the handler, resolving the IP of the clients with the options declared at the
+// extra config of the service. If they are not declared, the handler is returned untouched
+func ClientIPHandler(extra config.ExtraConfig, next http.Handler) (http.Handler, error) {
+	cfg, ok, err := ClientIPConfigGetter(extra)
+	if err != nil || !ok {
+		return next, err
+	}
+	resolver, err := NewClientIPResolver(cfg)
+	if err != nil {
+		return next, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, resolver.Resolve(r))))
+	}), nil
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,139 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	resolver, err := NewClientIPResolver(ClientIPConfig{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1", "2001:db8:ffff::/48"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		remote   string
+		headers  map[string]string
+		expected string
+	}{
+		{
+			name:     "untrusted peer",
+			remote:   "1.2.3.4:1234",
+			headers:  map[string]string{"X-Forwarded-For": "5.6.7.8"},
+			expected: "1.2.3.4",
+		},
+		{
+			name:     "trusted peer without headers",
+			remote:   "10.0.0.1:1234",
+			expected: "10.0.0.1",
+		},
+		{
+			name:     "x-forwarded-for",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string]string{"X-Forwarded-For": "6.6.6.6, 5.6.7.8, 192.168.1.1"},
+			expected: "5.6.7.8",
+		},
+		{
+			name:     "x-forwarded-for with trusted hops only",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			expected: "10.0.0.3",
+		},
+		{
+			name:     "x-forwarded-for with invalid hop",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string]string{"X-Forwarded-For": "supu, 10.0.0.2"},
+			expected: "10.0.0.2",
+		},
+		{
+			name:     "forwarded",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`},
+			expected: "2001:db8::1",
+		},
+		{
+			name:     "forwarded preferred over x-forwarded-for",
+			remote:   "10.0.0.1:1234",
+			headers:  map[string]string{"Forwarded": "for=5.6.7.8", "X-Forwarded-For": "6.6.6.6"},
+			expected: "5.6.7.8",
+		},
+		{
+			name:     "x-real-ip",
+			remote:   "[2001:db8:ffff::1]:1234",
+			headers:  map[string]string{"X-Real-IP": "5.6.7.8"},
+			expected: "5.6.7.8",
+		},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remote
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		if ip := resolver.Resolve(req); ip != tc.expected {
+			t.Errorf("%s: unexpected client IP: %s", tc.name, ip)
+		}
+	}
+}
+
+func TestClientIPResolver_headers(t *testing.T) {
+	resolver, err := NewClientIPResolver(ClientIPConfig{TrustedProxies: []string{"10.0.0.0/8"}, Headers: []string{"CF-Connecting-IP"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "6.6.6.6")
+	req.Header.Set("CF-Connecting-IP", "5.6.7.8")
+	if ip := resolver.Resolve(req); ip != "5.6.7.8" {
+		t.Errorf("unexpected client IP: %s", ip)
+	}
+}
+
+func TestNewTrustedProxies_ko(t *testing.T) {
+	if _, err := NewTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("error expected")
+	}
+	if _, err := NewTrustedProxies([]string{"supu"}); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestClientIPHandler(t *testing.T) {
+	var ip string
+	next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ip = ClientIP(r)
+	})
+
+	h, err := ClientIPHandler(config.ExtraConfig{ClientIPNamespace: map[string]interface{}{
+		"trusted_proxies": []interface{}{"127.0.0.1"},
+	}}, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "5.6.7.8")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if ip != "5.6.7.8" {
+		t.Errorf("unexpected client IP: %s", ip)
+	}
+
+	h, err = ClientIPHandler(config.ExtraConfig{}, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if ip != "127.0.0.1" {
+		t.Errorf("the headers must be ignored without options: %s", ip)
+	}
+
+	if _, err := ClientIPHandler(config.ExtraConfig{ClientIPNamespace: map[string]interface{}{
+		"trusted_proxies": []interface{}{"supu"},
+	}}, next); err == nil {
+		t.Error("error expected")
+	}
+}
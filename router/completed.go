@@ -0,0 +1,21 @@
+package router
+
+import (
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// CompletedHeaderName is the name of the header reporting if the response is complete
+const CompletedHeaderName = "X-Krakend-Completed"
+
+// IsCompletedHeaderEnabled checks if the responses of the endpoint must report their completion
+// with the X-Krakend-Completed header. It is enabled with the 'completed_header' flag of the
+// proxy extra config
+func IsCompletedHeaderEnabled(cfg *config.EndpointConfig) bool {
+	extra, ok := cfg.ExtraConfig[proxy.Namespace].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	enabled, ok := extra["completed_header"].(bool)
+	return ok && enabled
+}
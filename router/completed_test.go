@@ -0,0 +1,25 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestIsCompletedHeaderEnabled(t *testing.T) {
+	for i, tc := range []struct {
+		extra    config.ExtraConfig
+		expected bool
+	}{
+		{extra: nil},
+		{extra: config.ExtraConfig{proxy.Namespace: map[string]interface{}{}}},
+		{extra: config.ExtraConfig{proxy.Namespace: map[string]interface{}{"completed_header": "true"}}},
+		{extra: config.ExtraConfig{proxy.Namespace: map[string]interface{}{"completed_header": false}}},
+		{extra: config.ExtraConfig{proxy.Namespace: map[string]interface{}{"completed_header": true}}, expected: true},
+	} {
+		if IsCompletedHeaderEnabled(&config.EndpointConfig{ExtraConfig: tc.extra}) != tc.expected {
+			t.Errorf("unexpected result for the test case #%d", i)
+		}
+	}
+}
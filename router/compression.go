@@ -0,0 +1,235 @@
+package router
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// CompressionNamespace is the key to look for the compression options in the extra config of the service
+const CompressionNamespace = "github.com/devopsfaith/krakend/router/compression"
+
+// DefaultCompressionMinSize is the minimum size of the responses to compress when it is not configured
+const DefaultCompressionMinSize = 1024
+
+// DefaultCompressionContentTypes is the list of content types to compress when it is not configured
+var DefaultCompressionContentTypes = []string{
+	"application/json",
+	"application/xml",
+	"application/x-yaml",
+	"application/x-ndjson",
+	"application/javascript",
+	"text/*",
+}
+
+// A Compressor returns a writer compressing the data written into it
+type Compressor func(io.Writer) (io.WriteCloser, error)
+
+var (
+	compressors = map[string]Compressor{
+		"gzip":    func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		"deflate": func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil },
+	}
+	// compressorPreference breaks the ties between the content codings accepted with the same quality
+	compressorPreference = []string{"br", "zstd", "gzip", "deflate"}
+)
+
+// RegisterCompressor registers the compressor for the received content coding
+func RegisterCompressor(name string, c Compressor) {
+	compressors[name] = c
+}
+
+// CompressionConfig defines which responses are compressed
+type CompressionConfig struct {
+	// MinSize is the minimum size of the responses to compress
+	MinSize int `json:"min_size"`
+	// ContentTypes is the list of content types to compress. Wildcards like 'text/*' are accepted
+	ContentTypes []string `json:"content_types"`
+}
+
+// CompressionConfigGetter parses the compression options from the extra config of the service. The
+// second value is false if the compression is not enabled
+func CompressionConfigGetter(extra config.ExtraConfig) (CompressionConfig, bool) {
+	cfg := CompressionConfig{MinSize: -1}
+	v, ok := extra[CompressionNamespace]
+	if !ok {
+		return cfg, false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false
+	}
+	if cfg.MinSize < 0 {
+		cfg.MinSize = DefaultCompressionMinSize
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultCompressionContentTypes
+	}
+	return cfg, true
+}
+
+// CompressionHandler decorates the handler with the compression enabled at the extra config of the
+// service. If it is not enabled, the handler is returned untouched
+func CompressionHandler(extra config.ExtraConfig, next http.Handler) http.Handler {
+	cfg, ok := CompressionConfigGetter(extra)
+	if !ok {
+		return next
+	}
+	return NewCompressionHandler(cfg, next)
+}
+
+// NewCompressionHandler returns a handler compressing the responses with the content coding preferred by
+// the Accept-Encoding header of the request. Responses smaller than the minimum size, with a content type
+// not included in the configured list or already encoded are sent untouched
+func NewCompressionHandler(cfg CompressionConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the upgraded connections must be hijacked from the original writer
+		if IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		name, compressor := negotiateCompressor(r.Header.Get("Accept-Encoding"))
+		if compressor == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{
+			ResponseWriter: w,
+			cfg:            cfg,
+			name:           name,
+			compressor:     compressor,
+			status:         http.StatusOK,
+		}
+		next.ServeHTTP(cw, r)
+		cw.Close()
+	})
+}
+
+func negotiateCompressor(acceptEncoding string) (string, Compressor) {
+	for _, coding := range parseQualityList(acceptEncoding) {
+		if c, ok := compressors[coding]; ok {
+			return coding, c
+		}
+		if coding == "*" {
+			for _, name := range compressorPreference {
+				if c, ok := compressors[name]; ok {
+					return name, c
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// compressWriter buffers the response until its size reaches the minimum or it is flushed, so it can
+// decide if the response should be compressed
+type compressWriter struct {
+	http.ResponseWriter
+	cfg        CompressionConfig
+	name       string
+	compressor Compressor
+	status     int
+	buf        []byte
+	decided    bool
+	cw         io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.cw != nil {
+			return w.cw.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.cfg.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if f, ok := w.cw.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close sends the buffered response, if it was not sent yet, and closes the compressor
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if err := w.decide(len(w.buf) >= w.cfg.MinSize); err != nil {
+			return err
+		}
+	}
+	if w.cw != nil {
+		return w.cw.Close()
+	}
+	return nil
+}
+
+func (w *compressWriter) decide(bigEnough bool) error {
+	w.decided = true
+	h := w.ResponseWriter.Header()
+	if bigEnough && w.shouldCompress(h) {
+		cw, err := w.compressor(w.ResponseWriter)
+		if err != nil {
+			return err
+		}
+		w.cw = cw
+		h.Set("Content-Encoding", w.name)
+		h.Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	_, err := w.Write(buf)
+	return err
+}
+
+func (w *compressWriter) shouldCompress(h http.Header) bool {
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := strings.ToLower(strings.TrimSpace(strings.Split(h.Get("Content-Type"), ";")[0]))
+	if contentType == "" {
+		return false
+	}
+	for _, allowed := range w.cfg.ContentTypes {
+		if allowed == contentType {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, allowed[:len(allowed)-1]) {
+			return true
+		}
+	}
+	return false
+}
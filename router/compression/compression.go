@@ -0,0 +1,42 @@
+// Package compression adds the brotli and zstd content codings to the response compression of the
+// routers and to the decompression of the backend responses
+package compression
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
+)
+
+const (
+	// Brotli is the name of the brotli content coding
+	Brotli = "br"
+	// Zstd is the name of the zstd content coding
+	Zstd = "zstd"
+)
+
+// Register registers the brotli and zstd compressors and decompressors
+func Register() error {
+	router.RegisterCompressor(Brotli, func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriter(w), nil
+	})
+	router.RegisterCompressor(Zstd, func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	})
+	proxy.RegisterDecompressor(Brotli, func(r io.Reader) (io.ReadCloser, error) {
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	})
+	proxy.RegisterDecompressor(Zstd, func(r io.Reader) (io.ReadCloser, error) {
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	})
+	return nil
+}
@@ -0,0 +1,62 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/devopsfaith/krakend/router"
+)
+
+func TestRegister(t *testing.T) {
+	if err := Register(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	body := strings.Repeat("supu tupu ", 100)
+	handler := router.NewCompressionHandler(router.CompressionConfig{MinSize: 10, ContentTypes: []string{"text/*"}},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, body)
+		}))
+
+	for _, coding := range []string{Brotli, Zstd} {
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/", nil)
+		req.Header.Set("Accept-Encoding", coding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if ce := w.Result().Header.Get("Content-Encoding"); ce != coding {
+			t.Errorf("unexpected content encoding: %s", ce)
+			continue
+		}
+		var r io.Reader
+		switch coding {
+		case Brotli:
+			r = brotli.NewReader(w.Result().Body)
+		case Zstd:
+			d, err := zstd.NewReader(w.Result().Body)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			r = d
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !bytes.Equal(b, []byte(body)) {
+			t.Errorf("%s: unexpected body: %s", coding, string(b))
+		}
+	}
+}
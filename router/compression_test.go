@@ -0,0 +1,153 @@
+package router
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestCompressionHandler(t *testing.T) {
+	body := strings.Repeat("supu tupu ", 100)
+	handler := CompressionHandler(config.ExtraConfig{
+		CompressionNamespace: map[string]interface{}{"min_size": 100},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, "{}")
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, body)
+		case "/encoded":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Encoding", "custom")
+			io.WriteString(w, body)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusCreated)
+			for i := 0; i < 10; i++ {
+				io.WriteString(w, body[:100])
+			}
+		}
+	}))
+
+	for _, tc := range []struct {
+		path, acceptEncoding, contentEncoding string
+		status                                int
+		expected                              string
+	}{
+		{"/", "gzip", "gzip", http.StatusCreated, body},
+		{"/", "br;q=0.5, deflate", "deflate", http.StatusCreated, body},
+		{"/", "*", "gzip", http.StatusCreated, body},
+		{"/", "gzip;q=0", "", http.StatusCreated, body},
+		{"/", "", "", http.StatusCreated, body},
+		{"/small", "gzip", "", http.StatusOK, "{}"},
+		{"/image", "gzip", "", http.StatusOK, body},
+		{"/encoded", "gzip", "custom", http.StatusOK, body},
+		{"/empty", "gzip", "", http.StatusNoContent, ""},
+	} {
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8080"+tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		resp := w.Result()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s (%s): unexpected status code: %d", tc.path, tc.acceptEncoding, resp.StatusCode)
+		}
+		if ce := resp.Header.Get("Content-Encoding"); ce != tc.contentEncoding {
+			t.Errorf("%s (%s): unexpected content encoding: %s", tc.path, tc.acceptEncoding, ce)
+		}
+		if vary := resp.Header.Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("%s (%s): unexpected vary header: %s", tc.path, tc.acceptEncoding, vary)
+		}
+
+		var r io.Reader = resp.Body
+		switch tc.contentEncoding {
+		case "gzip":
+			r, _ = gzip.NewReader(resp.Body)
+		case "deflate":
+			r, _ = zlib.NewReader(resp.Body)
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("%s (%s): reading the body: %s", tc.path, tc.acceptEncoding, err.Error())
+			continue
+		}
+		if string(b) != tc.expected {
+			t.Errorf("%s (%s): unexpected body: %s", tc.path, tc.acceptEncoding, string(b))
+		}
+	}
+}
+
+func TestCompressionHandler_flush(t *testing.T) {
+	handler := NewCompressionHandler(CompressionConfig{MinSize: 1000, ContentTypes: []string{"application/x-ndjson"}},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			io.WriteString(w, "{\"id\":1}\n")
+			w.(http.Flusher).Flush()
+			io.WriteString(w, "{\"id\":2}\n")
+		}))
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if !w.Flushed {
+		t.Error("the response should be flushed")
+	}
+	if ce := w.Result().Header.Get("Content-Encoding"); ce != "gzip" {
+		t.Errorf("unexpected content encoding: %s", ce)
+		return
+	}
+	r, _ := gzip.NewReader(w.Result().Body)
+	b, _ := ioutil.ReadAll(r)
+	if string(b) != "{\"id\":1}\n{\"id\":2}\n" {
+		t.Errorf("unexpected body: %s", string(b))
+	}
+}
+
+func TestCompressionHandler_disabled(t *testing.T) {
+	h := http.NotFoundHandler()
+	if CompressionHandler(config.ExtraConfig{}, h) == nil {
+		t.Error("the handler should be returned")
+	}
+	cfg, ok := CompressionConfigGetter(config.ExtraConfig{CompressionNamespace: map[string]interface{}{}})
+	if !ok {
+		t.Error("the compression should be enabled")
+	}
+	if cfg.MinSize != DefaultCompressionMinSize || len(cfg.ContentTypes) != len(DefaultCompressionContentTypes) {
+		t.Errorf("unexpected config: %v", cfg)
+	}
+}
+
+func TestCompressionHandler_webSocket(t *testing.T) {
+	handler := CompressionHandler(config.ExtraConfig{
+		CompressionNamespace: map[string]interface{}{},
+	}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if _, ok := w.(http.Hijacker); !ok {
+			t.Error("the upgrade requests should receive the original writer")
+		}
+	}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
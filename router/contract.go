@@ -0,0 +1,178 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/devopsfaith/krakend/audit"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// ContractNamespace is the key to look for the contract in the extra config of the endpoints
+const ContractNamespace = "github.com/devopsfaith/krakend/router/contract"
+
+// ContractConfig defines the contract enforced on the requests and the responses of an endpoint
+type ContractConfig struct {
+	// ContentTypes are the media types accepted for the request bodies. If it is empty, any content type is
+	// accepted
+	ContentTypes []string `json:"content_types"`
+	// RequestSchema is the JSON Schema of the request bodies
+	RequestSchema map[string]interface{} `json:"request_schema"`
+	// ResponseSchema is the JSON Schema of the data of the responses returned to the clients. It is ignored
+	// by the no-op endpoints, since their responses are not decoded
+	ResponseSchema map[string]interface{} `json:"response_schema"`
+}
+
+// ContractViolation is the error returned when a request or a response breaks the contract of the endpoint.
+// It contains the detail of all the violations found
+type ContractViolation struct {
+	Status     int
+	Message    string
+	Violations config.ValidationErrors
+}
+
+// Error implements the error interface
+func (c *ContractViolation) Error() string {
+	if len(c.Violations) == 0 {
+		return c.Message
+	}
+	msgs := make([]string, len(c.Violations))
+	for i, v := range c.Violations {
+		msgs[i] = v.Error()
+	}
+	return c.Message + ":\n" + strings.Join(msgs, "\n")
+}
+
+// StatusCode returns the status code to send to the client
+func (c *ContractViolation) StatusCode() int {
+	return c.Status
+}
+
+// Contract validates the requests and the responses of an endpoint
+type Contract struct {
+	endpoint       *config.EndpointConfig
+	contentTypes   map[string]bool
+	requestSchema  map[string]interface{}
+	responseSchema map[string]interface{}
+}
+
+// NewContract returns the Contract declared in the extra config of the endpoint. It returns nil if the
+// endpoint does not declare a contract
+func NewContract(cfg *config.EndpointConfig) (*Contract, error) {
+	v, ok := cfg.ExtraConfig[ContractNamespace]
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	contractCfg := ContractConfig{}
+	if err := json.Unmarshal(b, &contractCfg); err != nil {
+		return nil, err
+	}
+
+	c := &Contract{
+		endpoint:      cfg,
+		requestSchema: contractCfg.RequestSchema,
+	}
+	if cfg.OutputEncoding != encoding.NOOP {
+		c.responseSchema = contractCfg.ResponseSchema
+	}
+	if len(contractCfg.ContentTypes) > 0 {
+		c.contentTypes = make(map[string]bool, len(contractCfg.ContentTypes))
+		for _, contentType := range contractCfg.ContentTypes {
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			if err != nil {
+				return nil, fmt.Errorf("invalid content type %q: %s", contentType, err)
+			}
+			c.contentTypes[mediaType] = true
+		}
+	}
+	return c, nil
+}
+
+// Request validates the content type and the body of the request. The validated body is restored, so it can
+// be sent to the backends. The returned error is a ContractViolation with a 415 or a 400 status code, or the
+// error reading the body. The violations are recorded into the audit log
+func (c *Contract) Request(r *http.Request) error {
+	err := c.validateRequest(r)
+	if _, ok := err.(*ContractViolation); ok {
+		auditRequest(audit.EventSchemaViolation, audit.OutcomeDenied, c.endpoint, r, "", err)
+	}
+	return err
+}
+
+func (c *Contract) validateRequest(r *http.Request) error {
+	hasBody := r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+	if c.contentTypes != nil && hasBody {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || !c.contentTypes[mediaType] {
+			return &ContractViolation{
+				Status:  http.StatusUnsupportedMediaType,
+				Message: fmt.Sprintf("unsupported content type %q", r.Header.Get("Content-Type")),
+			}
+		}
+	}
+	if c.requestSchema == nil {
+		return nil
+	}
+
+	var b []byte
+	if hasBody {
+		var err error
+		b, err = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return &ContractViolation{Status: http.StatusBadRequest, Message: "missing request body"}
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return &ContractViolation{Status: http.StatusBadRequest, Message: "the request body is not valid JSON"}
+	}
+	if errs := config.ValidateJSON(c.requestSchema, v); len(errs) > 0 {
+		return &ContractViolation{Status: http.StatusBadRequest, Message: "invalid request body", Violations: errs}
+	}
+	return nil
+}
+
+// Response validates the data of the response. The returned error is a ContractViolation with a 502 status
+// code, since the backends broke the contract
+func (c *Contract) Response(response *proxy.Response) error {
+	if c.responseSchema == nil {
+		return nil
+	}
+	var data map[string]interface{}
+	if response != nil {
+		data = response.Data
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return err
+	}
+	if errs := config.ValidateJSON(c.responseSchema, v); len(errs) > 0 {
+		return &ContractViolation{Status: http.StatusBadGateway, Message: "invalid response", Violations: errs}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package router
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestContract_Request(t *testing.T) {
+	c, err := NewContract(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{ContractNamespace: map[string]interface{}{
+		"content_types": []interface{}{"application/json"},
+		"request_schema": map[string]interface{}{
+			"type":       "object",
+			"required":   []interface{}{"name"},
+			"properties": map[string]interface{}{"age": map[string]interface{}{"type": "integer"}},
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		contentType string
+		body        string
+		status      int
+		message     string
+	}{
+		{"text/plain", `{"name": "supu"}`, http.StatusUnsupportedMediaType, `unsupported content type "text/plain"`},
+		{"application/json", `{"name"`, http.StatusBadRequest, "the request body is not valid JSON"},
+		{"application/json", `{"age": "42"}`, http.StatusBadRequest, "invalid request body:\n/: missing required key \"name\"\n/age: expected integer, got string"},
+	} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+		r.Header.Set("Content-Type", tc.contentType)
+		err := c.Request(r)
+		if err == nil {
+			t.Errorf("%s: error expected", tc.body)
+			continue
+		}
+		if status := DefaultToHTTPError(err); status != tc.status {
+			t.Errorf("%s: unexpected status code: %d", tc.body, status)
+		}
+		if err.Error() != tc.message {
+			t.Errorf("%s: unexpected error: %s", tc.body, err.Error())
+		}
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "supu", "age": 42}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if err := c.Request(r); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(r.Body); string(b) != `{"name": "supu", "age": 42}` {
+		t.Errorf("the body was not restored: %s", string(b))
+	}
+}
+
+func TestContract_Response(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"id": map[string]interface{}{"type": "integer", "minimum": 1}},
+	}
+	c, err := NewContract(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{ContractNamespace: map[string]interface{}{
+		"response_schema": schema,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Response(&proxy.Response{Data: map[string]interface{}{"id": 42}}); err != nil {
+		t.Error(err)
+	}
+	err = c.Response(&proxy.Response{Data: map[string]interface{}{"id": 0}})
+	if err == nil {
+		t.Fatal("error expected")
+	}
+	if status := DefaultToHTTPError(err); status != http.StatusBadGateway {
+		t.Errorf("unexpected status code: %d", status)
+	}
+	if err.Error() != "invalid response:\n/id: 0 is lower than the minimum 1" {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+
+	// the responses of the no-op endpoints are not decoded
+	c, err = NewContract(&config.EndpointConfig{OutputEncoding: "no-op", ExtraConfig: config.ExtraConfig{ContractNamespace: map[string]interface{}{
+		"response_schema": schema,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Response(&proxy.Response{Data: map[string]interface{}{"id": 0}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewContract_ko(t *testing.T) {
+	if c, err := NewContract(&config.EndpointConfig{}); c != nil || err != nil {
+		t.Errorf("unexpected contract: %v, %v", c, err)
+	}
+	_, err := NewContract(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{ContractNamespace: map[string]interface{}{
+		"content_types": []interface{}{"application/json;;"},
+	}}})
+	if err == nil {
+		t.Error("error expected")
+	}
+}
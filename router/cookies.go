@@ -0,0 +1,170 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// CookiesNamespace is the key to look for the cookie policy in the extra config of the endpoints
+const CookiesNamespace = "github.com/devopsfaith/krakend/router/cookies"
+
+// CookiePolicyConfig defines how the cookies of an endpoint are sent to the backends and returned to the
+// clients
+type CookiePolicyConfig struct {
+	// Forward are the cookies sent to the backends in the Cookie header. If it is empty, the Cookie header
+	// is only sent when it is in the headers to pass
+	Forward []string `json:"forward"`
+	// ToHeaders maps the cookies into the headers sent to the backends
+	ToHeaders map[string]string `json:"to_headers"`
+	// ToParams maps the cookies into params of the endpoint, available in the URL patterns of the backends
+	ToParams map[string]string `json:"to_params"`
+	// StripSetCookie removes the Set-Cookie headers of the backend responses
+	StripSetCookie bool `json:"strip_set_cookie"`
+	// Rewrite overrides the attributes of the cookies set by the backends
+	Rewrite *SetCookieRewrite `json:"rewrite"`
+}
+
+// SetCookieRewrite defines the attributes to override in the cookies set by the backends. The empty options
+// keep the original attributes
+type SetCookieRewrite struct {
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	SameSite string `json:"same_site"`
+	Secure   bool   `json:"secure"`
+	HTTPOnly bool   `json:"http_only"`
+}
+
+// CookiePolicy applies the cookie policy of an endpoint to its requests and responses
+type CookiePolicy struct {
+	forward   map[string]bool
+	toHeaders map[string]string
+	toParams  map[string]string
+	strip     bool
+	rewrite   *SetCookieRewrite
+	sameSite  http.SameSite
+}
+
+// NewCookiePolicy returns the CookiePolicy declared in the extra config of the endpoint. It returns nil if
+// the endpoint does not declare a policy
+func NewCookiePolicy(cfg *config.EndpointConfig) (*CookiePolicy, error) {
+	v, ok := cfg.ExtraConfig[CookiesNamespace]
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	policyCfg := CookiePolicyConfig{}
+	if err := json.Unmarshal(b, &policyCfg); err != nil {
+		return nil, err
+	}
+
+	p := &CookiePolicy{
+		toHeaders: make(map[string]string, len(policyCfg.ToHeaders)),
+		toParams:  make(map[string]string, len(policyCfg.ToParams)),
+		strip:     policyCfg.StripSetCookie,
+		rewrite:   policyCfg.Rewrite,
+	}
+	if len(policyCfg.Forward) > 0 {
+		p.forward = make(map[string]bool, len(policyCfg.Forward))
+		for _, name := range policyCfg.Forward {
+			p.forward[name] = true
+		}
+	}
+	for cookie, header := range policyCfg.ToHeaders {
+		p.toHeaders[cookie] = http.CanonicalHeaderKey(header)
+	}
+	for cookie, param := range policyCfg.ToParams {
+		p.toParams[cookie] = strings.Title(param)
+	}
+	if p.rewrite != nil {
+		switch strings.ToLower(p.rewrite.SameSite) {
+		case "":
+		case "strict":
+			p.sameSite = http.SameSiteStrictMode
+		case "lax":
+			p.sameSite = http.SameSiteLaxMode
+		case "none":
+			p.sameSite = http.SameSiteNoneMode
+		default:
+			return nil, fmt.Errorf("unknown SameSite attribute: %s", p.rewrite.SameSite)
+		}
+	}
+	return p, nil
+}
+
+// Request sends the cookies of the received request to the backends, with the policy of the endpoint
+func (p *CookiePolicy) Request(r *http.Request, request *proxy.Request) {
+	cookies := r.Cookies()
+	if p.forward != nil {
+		forwarded := []string{}
+		for _, c := range cookies {
+			if p.forward[c.Name] {
+				forwarded = append(forwarded, (&http.Cookie{Name: c.Name, Value: c.Value}).String())
+			}
+		}
+		delete(request.Headers, "Cookie")
+		if len(forwarded) > 0 {
+			request.Headers["Cookie"] = []string{strings.Join(forwarded, "; ")}
+		}
+	}
+	for _, c := range cookies {
+		if header, ok := p.toHeaders[c.Name]; ok {
+			request.Headers[header] = []string{c.Value}
+		}
+		if param, ok := p.toParams[c.Name]; ok {
+			request.Params[param] = c.Value
+		}
+	}
+}
+
+// Response returns the response with the cookies set by the backends stripped or rewritten. The received
+// response is not modified
+func (p *CookiePolicy) Response(response *proxy.Response) *proxy.Response {
+	if response == nil || (!p.strip && p.rewrite == nil) {
+		return response
+	}
+	setCookies, ok := response.Metadata.Headers["Set-Cookie"]
+	if !ok {
+		return response
+	}
+	headers := make(map[string][]string, len(response.Metadata.Headers))
+	for k, vs := range response.Metadata.Headers {
+		headers[k] = vs
+	}
+	delete(headers, "Set-Cookie")
+	if !p.strip {
+		cookies := (&http.Response{Header: http.Header{"Set-Cookie": setCookies}}).Cookies()
+		rewritten := make([]string, 0, len(cookies))
+		for _, c := range cookies {
+			p.rewriteCookie(c)
+			if v := c.String(); v != "" {
+				rewritten = append(rewritten, v)
+			}
+		}
+		headers["Set-Cookie"] = rewritten
+	}
+	r := *response
+	r.Metadata.Headers = headers
+	return &r
+}
+
+func (p *CookiePolicy) rewriteCookie(c *http.Cookie) {
+	if p.rewrite.Domain != "" {
+		c.Domain = p.rewrite.Domain
+	}
+	if p.rewrite.Path != "" {
+		c.Path = p.rewrite.Path
+	}
+	if p.sameSite != 0 {
+		c.SameSite = p.sameSite
+	}
+	c.Secure = c.Secure || p.rewrite.Secure
+	c.HttpOnly = c.HttpOnly || p.rewrite.HTTPOnly
+}
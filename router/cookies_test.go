@@ -0,0 +1,89 @@
+package router
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestCookiePolicy_Request(t *testing.T) {
+	p, err := NewCookiePolicy(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{CookiesNamespace: map[string]interface{}{
+		"forward":    []interface{}{"session", "lang"},
+		"to_headers": map[string]interface{}{"token": "authorization"},
+		"to_params":  map[string]interface{}{"tenant": "tenant"},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", "session=abc; token=Bearer-xyz; tenant=acme; tracking=1; lang=es")
+	request := &proxy.Request{
+		Headers: map[string][]string{"Cookie": r.Header["Cookie"]},
+		Params:  map[string]string{},
+	}
+	p.Request(r, request)
+
+	expected := map[string][]string{
+		"Cookie":        {"session=abc; lang=es"},
+		"Authorization": {"Bearer-xyz"},
+	}
+	if !reflect.DeepEqual(request.Headers, expected) {
+		t.Errorf("unexpected headers: %v", request.Headers)
+	}
+	if request.Params["Tenant"] != "acme" {
+		t.Errorf("unexpected params: %v", request.Params)
+	}
+}
+
+func TestCookiePolicy_Response(t *testing.T) {
+	original := &proxy.Response{Metadata: proxy.Metadata{Headers: map[string][]string{
+		"Set-Cookie":   {"session=abc; Domain=backend.internal; Path=/api", "lang=es"},
+		"Content-Type": {"application/json"},
+	}}}
+
+	p, err := NewCookiePolicy(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{CookiesNamespace: map[string]interface{}{
+		"rewrite": map[string]interface{}{"domain": "example.com", "path": "/", "same_site": "lax", "secure": true},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := p.Response(original)
+	expected := []string{
+		"session=abc; Path=/; Domain=example.com; Secure; SameSite=Lax",
+		"lang=es; Path=/; Domain=example.com; Secure; SameSite=Lax",
+	}
+	if !reflect.DeepEqual(resp.Metadata.Headers["Set-Cookie"], expected) {
+		t.Errorf("unexpected cookies: %v", resp.Metadata.Headers["Set-Cookie"])
+	}
+	if len(original.Metadata.Headers["Set-Cookie"]) != 2 || original.Metadata.Headers["Set-Cookie"][1] != "lang=es" {
+		t.Errorf("the original response has been modified: %v", original.Metadata.Headers)
+	}
+
+	p, err = NewCookiePolicy(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{CookiesNamespace: map[string]interface{}{
+		"strip_set_cookie": true,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = p.Response(original)
+	if _, ok := resp.Metadata.Headers["Set-Cookie"]; ok || len(resp.Metadata.Headers) != 1 {
+		t.Errorf("unexpected headers: %v", resp.Metadata.Headers)
+	}
+
+	if _, err := NewCookiePolicy(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{CookiesNamespace: map[string]interface{}{
+		"rewrite": map[string]interface{}{"same_site": "sometimes"},
+	}}}); err == nil {
+		t.Error("expecting an error with an unknown SameSite attribute")
+	}
+}
+
+func TestCookiePolicy_undeclared(t *testing.T) {
+	p, err := NewCookiePolicy(&config.EndpointConfig{})
+	if err != nil || p != nil {
+		t.Errorf("unexpected result: %v %v", p, err)
+	}
+}
@@ -0,0 +1,285 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// CORSNamespace is the key to look for the CORS options in the extra config of the service and the endpoints
+const CORSNamespace = "github.com/devopsfaith/krakend/router/cors"
+
+// DefaultCORSAllowHeaders are the request headers allowed when the CORS options do not declare them
+var DefaultCORSAllowHeaders = []string{"Accept", "Content-Type", "Origin", "X-Requested-With"}
+
+// CORSConfig defines the cross-origin requests accepted by the endpoints. The options of an endpoint replace
+// the ones of the service
+type CORSConfig struct {
+	// Disabled disables the CORS options of the service for the endpoint
+	Disabled bool `json:"disabled"`
+	// AllowOrigins are the origins allowed. '*' allows all of them, and the wildcards like
+	// 'https://*.example.com' match any value in their place. By default, all the origins are allowed
+	AllowOrigins []string `json:"allow_origins"`
+	// AllowOriginPatterns are the regular expressions matching the allowed origins
+	AllowOriginPatterns []string `json:"allow_origin_patterns"`
+	// AllowMethods are the methods allowed. By default, the methods of the endpoints sharing the path
+	AllowMethods []string `json:"allow_methods"`
+	// AllowHeaders are the request headers allowed. '*' allows all of them. By default, the
+	// DefaultCORSAllowHeaders
+	AllowHeaders []string `json:"allow_headers"`
+	// ExposeHeaders are the response headers the browsers expose to the scripts
+	ExposeHeaders []string `json:"expose_headers"`
+	// AllowCredentials allows the requests with cookies or authorization headers
+	AllowCredentials bool `json:"allow_credentials"`
+	// MaxAge is the time the browsers can cache the responses to the preflight requests
+	MaxAge string `json:"max_age"`
+}
+
+// CORSConfigGetter parses the CORS options from the extra config. The second value is false if they are
+// not declared
+func CORSConfigGetter(extra config.ExtraConfig) (CORSConfig, bool, error) {
+	cfg := CORSConfig{}
+	v, ok := extra[CORSNamespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false, err
+	}
+	return cfg, true, nil
+}
+
+// NewEndpointCORS returns the CORS handler of the endpoints sharing a path, with the options of the first
+// endpoint declaring them or the ones of the service. It returns nil if CORS is not enabled for them
+func NewEndpointCORS(service config.ExtraConfig, endpoints []*config.EndpointConfig) (*CORS, error) {
+	cfg, ok, err := CORSConfigGetter(service)
+	if err != nil {
+		return nil, err
+	}
+	methods := []string{}
+	for _, e := range endpoints {
+		methods = append(methods, e.Method)
+	}
+	for _, e := range endpoints {
+		endpointCfg, endpointOK, err := CORSConfigGetter(e.ExtraConfig)
+		if err != nil {
+			return nil, err
+		}
+		if endpointOK {
+			cfg, ok = endpointCfg, true
+			break
+		}
+	}
+	if !ok || cfg.Disabled {
+		return nil, nil
+	}
+	if len(cfg.AllowMethods) == 0 {
+		cfg.AllowMethods = methods
+	}
+	return NewCORS(cfg)
+}
+
+// CORS adds the CORS headers to the responses of the allowed origins and responds to the preflight requests
+type CORS struct {
+	allowAllOrigins  bool
+	origins          map[string]bool
+	originPatterns   []*regexp.Regexp
+	methods          map[string]bool
+	allowMethods     string
+	allowAllHeaders  bool
+	headers          map[string]bool
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// NewCORS returns a CORS handler with the options
+func NewCORS(cfg CORSConfig) (*CORS, error) {
+	c := &CORS{
+		origins:          map[string]bool{},
+		methods:          map[string]bool{},
+		headers:          map[string]bool{},
+		allowCredentials: cfg.AllowCredentials,
+	}
+
+	if len(cfg.AllowOrigins) == 0 && len(cfg.AllowOriginPatterns) == 0 {
+		c.allowAllOrigins = true
+	}
+	for _, origin := range cfg.AllowOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			c.allowAllOrigins = true
+		case strings.Contains(origin, "*"):
+			pattern := strings.Replace(regexp.QuoteMeta(origin), `\*`, `[^/]*`, -1)
+			c.originPatterns = append(c.originPatterns, regexp.MustCompile("^"+pattern+"$"))
+		default:
+			c.origins[origin] = true
+		}
+	}
+	for _, p := range cfg.AllowOriginPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		c.originPatterns = append(c.originPatterns, re)
+	}
+
+	methods := []string{}
+	for _, m := range cfg.AllowMethods {
+		m = strings.ToUpper(m)
+		if !c.methods[m] {
+			c.methods[m] = true
+			methods = append(methods, m)
+		}
+	}
+	sort.Strings(methods)
+	c.allowMethods = strings.Join(methods, ", ")
+
+	headers := cfg.AllowHeaders
+	if len(headers) == 0 {
+		headers = DefaultCORSAllowHeaders
+	}
+	canonical := []string{}
+	for _, h := range headers {
+		if h == "*" {
+			c.allowAllHeaders = true
+			continue
+		}
+		h = http.CanonicalHeaderKey(h)
+		c.headers[h] = true
+		canonical = append(canonical, h)
+	}
+	c.allowHeaders = strings.Join(canonical, ", ")
+	c.exposeHeaders = strings.Join(cfg.ExposeHeaders, ", ")
+
+	if cfg.MaxAge != "" {
+		d, err := time.ParseDuration(cfg.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+		c.maxAge = strconv.Itoa(int(d.Seconds()))
+	}
+	return c, nil
+}
+
+// IsPreflight checks if the request is a CORS preflight request
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// Handler responds to the preflight requests and adds the CORS headers to the responses of the rest of the
+// requests served by the next handler
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsPreflight(r) {
+			c.Preflight(w, r)
+			return
+		}
+		c.SetHeaders(w.Header(), r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Preflight responds to the preflight request, rejecting it with a 403 if the origin, the method or the
+// headers requested are not allowed
+func (c *CORS) Preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := r.Header.Get("Origin")
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requestHeaders := r.Header.Get("Access-Control-Request-Headers")
+	if !c.isOriginAllowed(origin) || !c.methods[method] || !c.areHeadersAllowed(requestHeaders) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	h.Set("Access-Control-Allow-Origin", c.allowOrigin(origin))
+	h.Set("Access-Control-Allow-Methods", c.allowMethods)
+	if requestHeaders != "" {
+		if c.allowAllHeaders {
+			h.Set("Access-Control-Allow-Headers", requestHeaders)
+		} else {
+			h.Set("Access-Control-Allow-Headers", c.allowHeaders)
+		}
+	}
+	if c.allowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetHeaders adds the CORS headers of the response to the request, if it comes from an allowed origin
+func (c *CORS) SetHeaders(h http.Header, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	h.Add("Vary", "Origin")
+	if !c.isOriginAllowed(origin) {
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", c.allowOrigin(origin))
+	if c.allowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if c.exposeHeaders != "" {
+		h.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+	}
+}
+
+// allowOrigin returns the value of the Access-Control-Allow-Origin header. The credentials can not be
+// allowed with the '*' value, so the origin is returned instead
+func (c *CORS) allowOrigin(origin string) string {
+	if c.allowAllOrigins && !c.allowCredentials {
+		return "*"
+	}
+	return origin
+}
+
+func (c *CORS) isOriginAllowed(origin string) bool {
+	if c.allowAllOrigins {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if c.origins[origin] {
+		return true
+	}
+	for _, re := range c.originPatterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) areHeadersAllowed(requestHeaders string) bool {
+	if c.allowAllHeaders {
+		return true
+	}
+	for _, h := range strings.Split(requestHeaders, ",") {
+		h = strings.TrimSpace(h)
+		if h != "" && !c.headers[http.CanonicalHeaderKey(h)] {
+			return false
+		}
+	}
+	return true
+}
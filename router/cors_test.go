@@ -0,0 +1,188 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestCORS_Handler(t *testing.T) {
+	cors, err := NewCORS(CORSConfig{
+		AllowOrigins:        []string{"https://example.com", "https://*.example.org"},
+		AllowOriginPatterns: []string{`^https://app[0-9]+\.example\.net$`},
+		AllowMethods:        []string{"get", "POST"},
+		AllowHeaders:        []string{"content-type", "Authorization"},
+		ExposeHeaders:       []string{"X-Total"},
+		AllowCredentials:    true,
+		MaxAge:              "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	h := cors.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.Write([]byte("ok"))
+	}))
+
+	for _, tc := range []struct {
+		name    string
+		method  string
+		headers map[string]string
+		status  int
+		expect  map[string]string
+	}{
+		{
+			name:    "preflight",
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "Content-Type, authorization"},
+			status:  http.StatusNoContent,
+			expect: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Methods":     "GET, POST",
+				"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Max-Age":           "3600",
+			},
+		},
+		{
+			name:    "preflight wildcard",
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://api.example.org", "Access-Control-Request-Method": "GET"},
+			status:  http.StatusNoContent,
+			expect:  map[string]string{"Access-Control-Allow-Origin": "https://api.example.org"},
+		},
+		{
+			name:    "preflight regexp",
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://app42.example.net", "Access-Control-Request-Method": "GET"},
+			status:  http.StatusNoContent,
+			expect:  map[string]string{"Access-Control-Allow-Origin": "https://app42.example.net"},
+		},
+		{
+			name:    "preflight unknown origin",
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "GET"},
+			status:  http.StatusForbidden,
+			expect:  map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "preflight method not allowed",
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "DELETE"},
+			status:  http.StatusForbidden,
+			expect:  map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "preflight header not allowed",
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Custom"},
+			status:  http.StatusForbidden,
+			expect:  map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "actual request",
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://example.com"},
+			status:  http.StatusOK,
+			expect: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Total",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:    "actual request from an unknown origin",
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://evil.com"},
+			status:  http.StatusOK,
+			expect:  map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+		},
+		{
+			name:   "same origin request",
+			method: "GET",
+			status: http.StatusOK,
+			expect: map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""},
+		},
+	} {
+		req := httptest.NewRequest(tc.method, "/supu", nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tc.status {
+			t.Errorf("%s: unexpected status code: %d", tc.name, w.Code)
+		}
+		for k, v := range tc.expect {
+			if got := w.Header().Get(k); got != v {
+				t.Errorf("%s: unexpected %s header: '%s'", tc.name, k, got)
+			}
+		}
+	}
+	if calls != 3 {
+		t.Errorf("the preflight requests must not reach the endpoint: %d calls", calls)
+	}
+}
+
+func TestCORS_allowAll(t *testing.T) {
+	cors, err := NewCORS(CORSConfig{AllowMethods: []string{"GET"}, AllowHeaders: []string{"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("OPTIONS", "/supu", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "X-Custom")
+	w := httptest.NewRecorder()
+	cors.Preflight(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("unexpected origin: %s", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "X-Custom" {
+		t.Errorf("unexpected headers: %s", got)
+	}
+}
+
+func TestNewEndpointCORS(t *testing.T) {
+	service := config.ExtraConfig{CORSNamespace: map[string]interface{}{"allow_origins": []interface{}{"https://example.com"}}}
+	get := &config.EndpointConfig{Endpoint: "/supu", Method: "GET"}
+	post := &config.EndpointConfig{Endpoint: "/supu", Method: "POST"}
+
+	cors, err := NewEndpointCORS(service, []*config.EndpointConfig{get, post})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cors == nil || cors.allowMethods != "GET, POST" || !cors.origins["https://example.com"] {
+		t.Errorf("unexpected CORS handler: %+v", cors)
+	}
+
+	post.ExtraConfig = config.ExtraConfig{CORSNamespace: map[string]interface{}{"allow_origins": []interface{}{"*"}}}
+	cors, err = NewEndpointCORS(service, []*config.EndpointConfig{get, post})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cors == nil || !cors.allowAllOrigins {
+		t.Errorf("the options of the endpoint must replace the ones of the service: %+v", cors)
+	}
+
+	post.ExtraConfig = config.ExtraConfig{CORSNamespace: map[string]interface{}{"disabled": true}}
+	if cors, err := NewEndpointCORS(service, []*config.EndpointConfig{get, post}); err != nil || cors != nil {
+		t.Errorf("unexpected result: %+v %v", cors, err)
+	}
+
+	if cors, err := NewEndpointCORS(config.ExtraConfig{}, []*config.EndpointConfig{get}); err != nil || cors != nil {
+		t.Errorf("unexpected result: %+v %v", cors, err)
+	}
+
+	get.ExtraConfig = config.ExtraConfig{CORSNamespace: map[string]interface{}{"allow_origin_patterns": []interface{}{"("}}}
+	if _, err := NewEndpointCORS(service, []*config.EndpointConfig{get}); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -0,0 +1,236 @@
+package router
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// DebugTimingsNamespace is the key to look for the debug timings options in the extra config of the endpoint
+const DebugTimingsNamespace = "github.com/devopsfaith/krakend/router/debug_timings"
+
+// DefaultDebugTimingsHeader is the header requesting the debug timings when it is not configured
+const DefaultDebugTimingsHeader = "X-Krakend-Debug"
+
+// DefaultDebugTimingsMaxAge is the validity of the signatures of the debug header when it is not configured
+const DefaultDebugTimingsMaxAge = 5 * time.Minute
+
+// DebugTimingsKey is the key of the timings added to the body of the responses
+const DebugTimingsKey = "_timings"
+
+// Outputs of the debug timings
+const (
+	DebugTimingsOutputHeader = "header"
+	DebugTimingsOutputBody   = "body"
+)
+
+// ErrNoDebugTimingsGate is the error returned when the debug timings are neither always enabled nor gated by
+// a signed header
+var ErrNoDebugTimingsGate = errors.New("the debug timings require 'always' or a 'secret'")
+
+// DebugTimingsConfig defines the debug timings of an endpoint
+type DebugTimingsConfig struct {
+	// Always annotates every response. Otherwise, only the requests with a valid signed header are annotated
+	Always bool `json:"always"`
+	// Secret is the key of the HMAC-SHA256 signatures of the debug header
+	Secret string `json:"secret"`
+	// Header requesting the timings. By default, the DefaultDebugTimingsHeader
+	Header string `json:"header"`
+	// MaxAge is the validity of the signatures, as a duration string. By default, the DefaultDebugTimingsMaxAge
+	MaxAge string `json:"max_age"`
+	// Output of the timings, DebugTimingsOutputHeader (a Server-Timing header) or DebugTimingsOutputBody (a
+	// _timings object added to the body). By default, DebugTimingsOutputHeader
+	Output string `json:"output"`
+}
+
+// DebugTimings annotates the responses of an endpoint with the time spent by the gateway and its backends
+type DebugTimings struct {
+	always bool
+	secret []byte
+	header string
+	maxAge time.Duration
+	body   bool
+}
+
+// NewDebugTimings returns the debug timings declared at the extra config of the endpoint. It returns nil if
+// they are not declared
+func NewDebugTimings(cfg *config.EndpointConfig) (*DebugTimings, error) {
+	v, ok := cfg.ExtraConfig[DebugTimingsNamespace]
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dc := DebugTimingsConfig{}
+	if err := json.Unmarshal(b, &dc); err != nil {
+		return nil, err
+	}
+	if !dc.Always && dc.Secret == "" {
+		return nil, ErrNoDebugTimingsGate
+	}
+	d := &DebugTimings{
+		always: dc.Always,
+		secret: []byte(dc.Secret),
+		header: dc.Header,
+		maxAge: DefaultDebugTimingsMaxAge,
+	}
+	if d.header == "" {
+		d.header = DefaultDebugTimingsHeader
+	}
+	if dc.MaxAge != "" {
+		if d.maxAge, err = time.ParseDuration(dc.MaxAge); err != nil {
+			return nil, err
+		}
+	}
+	switch dc.Output {
+	case "", DebugTimingsOutputHeader:
+	case DebugTimingsOutputBody:
+		d.body = true
+	default:
+		return nil, fmt.Errorf("unknown debug timings output: %s", dc.Output)
+	}
+	return d, nil
+}
+
+type debugTimingsStartKey struct{}
+
+// Start returns the request recording the timings of its backends, and true, if its response must be
+// annotated. The timings of the access log are reused
+func (d *DebugTimings) Start(r *http.Request) (*http.Request, bool) {
+	if !d.always && !d.validSignature(r.Header.Get(d.header), time.Now()) {
+		return r, false
+	}
+	ctx := context.WithValue(r.Context(), debugTimingsStartKey{}, time.Now())
+	if _, ok := proxy.TimingsFromContext(ctx); !ok {
+		ctx = proxy.NewTimingsContext(ctx, &proxy.Timings{})
+	}
+	return r.WithContext(ctx), true
+}
+
+// validSignature checks the value of the debug header: a unix timestamp, a dot and the hex encoded
+// HMAC-SHA256 of the timestamp with the secret. The timestamp must be younger than the max age
+func (d *DebugTimings) validSignature(v string, now time.Time) bool {
+	if len(d.secret) == 0 {
+		return false
+	}
+	parts := strings.SplitN(v, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > d.maxAge || age < -d.maxAge {
+		return false
+	}
+	signature, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte(parts[0]))
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
+// Annotate returns the response with the timings recorded since the request was started: as a Server-Timing
+// header or as a _timings object added to a copy of its data. The responses without data, like the streamed
+// ones, get the header
+func (d *DebugTimings) Annotate(w http.ResponseWriter, r *http.Request, response *proxy.Response) *proxy.Response {
+	start, ok := r.Context().Value(debugTimingsStartKey{}).(time.Time)
+	if !ok {
+		return response
+	}
+	timings, ok := proxy.TimingsFromContext(r.Context())
+	if !ok {
+		return response
+	}
+	total := time.Since(start)
+	if !d.body || response == nil || response.Data == nil || response.Io != nil {
+		w.Header().Add("Server-Timing", ServerTiming(timings, total))
+		return response
+	}
+	// the data can be shared with the cached responses
+	annotated := *response
+	annotated.Data = make(map[string]interface{}, len(response.Data)+1)
+	for k, v := range response.Data {
+		annotated.Data[k] = v
+	}
+	annotated.Data[DebugTimingsKey] = debugTimingsBody(timings, total)
+	return &annotated
+}
+
+// ServerTiming returns the value of the Server-Timing header with the timings: the connect, the time to
+// first byte, the decoding, the formatting and the total of every backend, as b<index>-<phase>, the merge
+// and the total of the gateway. The durations are in milliseconds
+func ServerTiming(t *proxy.Timings, total time.Duration) string {
+	metrics := []string{}
+	for i, bt := range t.Backends() {
+		prefix := "b" + strconv.Itoa(i) + "-"
+		for _, phase := range []struct {
+			name string
+			d    time.Duration
+		}{
+			{"connect", bt.Connect},
+			{"ttfb", bt.TTFB},
+			{"decode", bt.Decode},
+			{"format", bt.Format},
+		} {
+			if phase.d > 0 {
+				metrics = append(metrics, serverTimingMetric(prefix+phase.name, "", phase.d))
+			}
+		}
+		metrics = append(metrics, serverTimingMetric(prefix+"total", bt.Backend, bt.Duration))
+	}
+	if merge := t.Merge(); merge > 0 {
+		metrics = append(metrics, serverTimingMetric("merge", "", merge))
+	}
+	metrics = append(metrics, serverTimingMetric("total", "", total))
+	return strings.Join(metrics, ", ")
+}
+
+func serverTimingMetric(name, desc string, d time.Duration) string {
+	m := name
+	if desc != "" {
+		m += ";desc=" + strconv.Quote(desc)
+	}
+	return m + ";dur=" + strconv.FormatFloat(milliseconds(d), 'f', 3, 64)
+}
+
+// debugTimingsBody returns the _timings object, with the durations in milliseconds
+func debugTimingsBody(t *proxy.Timings, total time.Duration) map[string]interface{} {
+	backends := []interface{}{}
+	for _, bt := range t.Backends() {
+		b := map[string]interface{}{
+			"backend": bt.Backend,
+			"status":  bt.StatusCode,
+			"connect": milliseconds(bt.Connect),
+			"ttfb":    milliseconds(bt.TTFB),
+			"decode":  milliseconds(bt.Decode),
+			"format":  milliseconds(bt.Format),
+			"total":   milliseconds(bt.Duration),
+		}
+		if bt.Err != nil {
+			b["error"] = bt.Err.Error()
+		}
+		backends = append(backends, b)
+	}
+	return map[string]interface{}{
+		"backends": backends,
+		"merge":    milliseconds(t.Merge()),
+		"total":    milliseconds(total),
+	}
+}
@@ -0,0 +1,143 @@
+package router
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestNewDebugTimings(t *testing.T) {
+	d, err := NewDebugTimings(&config.EndpointConfig{})
+	if d != nil || err != nil {
+		t.Errorf("unexpected result: %v %v", d, err)
+	}
+	for i, tc := range []struct {
+		cfg map[string]interface{}
+		err error
+	}{
+		{cfg: map[string]interface{}{}, err: ErrNoDebugTimingsGate},
+		{cfg: map[string]interface{}{"always": true, "output": "unknown"}},
+		{cfg: map[string]interface{}{"secret": "s3cr3t", "max_age": "unknown"}},
+	} {
+		_, err := NewDebugTimings(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{DebugTimingsNamespace: tc.cfg}})
+		if err == nil || (tc.err != nil && err != tc.err) {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func signDebugHeader(secret string, ts time.Time) string {
+	v := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(v))
+	return v + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestDebugTimings_Start(t *testing.T) {
+	d, err := NewDebugTimings(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{
+		DebugTimingsNamespace: map[string]interface{}{"secret": "s3cr3t", "max_age": "1m"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, tc := range []struct {
+		name    string
+		header  string
+		enabled bool
+	}{
+		{name: "valid", header: signDebugHeader("s3cr3t", now), enabled: true},
+		{name: "missing"},
+		{name: "expired", header: signDebugHeader("s3cr3t", now.Add(-2*time.Minute))},
+		{name: "future", header: signDebugHeader("s3cr3t", now.Add(2*time.Minute))},
+		{name: "other secret", header: signDebugHeader("other", now)},
+		{name: "malformed", header: "abc.def"},
+	} {
+		r := httptest.NewRequest("GET", "/supu", nil)
+		if tc.header != "" {
+			r.Header.Set(DefaultDebugTimingsHeader, tc.header)
+		}
+		r, enabled := d.Start(r)
+		if enabled != tc.enabled {
+			t.Errorf("%s: unexpected result: %v", tc.name, enabled)
+		}
+		if _, ok := proxy.TimingsFromContext(r.Context()); ok != tc.enabled {
+			t.Errorf("%s: unexpected timings in the context: %v", tc.name, ok)
+		}
+	}
+}
+
+// recordTimings proxies a call through the timings middleware of the backend
+func recordTimings(ctx context.Context, backend string, status int) {
+	p := proxy.NewTimingsMiddleware(&config.Backend{URLPattern: backend})(func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{Metadata: proxy.Metadata{StatusCode: status}}, nil
+	})
+	p(ctx, &proxy.Request{})
+}
+
+func TestDebugTimings_Annotate(t *testing.T) {
+	for _, output := range []string{DebugTimingsOutputHeader, DebugTimingsOutputBody} {
+		d, err := NewDebugTimings(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{
+			DebugTimingsNamespace: map[string]interface{}{"always": true, "output": output},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, enabled := d.Start(httptest.NewRequest("GET", "/supu", nil))
+		if !enabled {
+			t.Fatalf("%s: the timings are not enabled", output)
+		}
+		recordTimings(r.Context(), "/a", 200)
+		recordTimings(r.Context(), "/b", 404)
+
+		data := map[string]interface{}{"supu": 42}
+		w := httptest.NewRecorder()
+		response := d.Annotate(w, r, &proxy.Response{Data: data, IsComplete: true})
+
+		if _, ok := data[DebugTimingsKey]; ok {
+			t.Errorf("%s: the original data was modified", output)
+		}
+		header := w.Header().Get("Server-Timing")
+		timings, ok := response.Data[DebugTimingsKey].(map[string]interface{})
+
+		if output == DebugTimingsOutputHeader {
+			if ok {
+				t.Errorf("unexpected timings in the body: %v", timings)
+			}
+			for _, expected := range []string{`b0-total;desc="/a";dur=`, `b1-total;desc="/b";dur=`, ", total;dur="} {
+				if !strings.Contains(header, expected) {
+					t.Errorf("unexpected Server-Timing header: %s", header)
+				}
+			}
+			continue
+		}
+
+		if header != "" {
+			t.Errorf("unexpected Server-Timing header: %s", header)
+		}
+		if !ok || response.Data["supu"] != 42 {
+			t.Errorf("unexpected data: %v", response.Data)
+			continue
+		}
+		backends, _ := timings["backends"].([]interface{})
+		if len(backends) != 2 {
+			t.Errorf("unexpected backends: %v", timings)
+			continue
+		}
+		if b := backends[1].(map[string]interface{}); b["backend"] != "/b" || b["status"] != 404 {
+			t.Errorf("unexpected backend: %v", b)
+		}
+		if _, ok := timings["total"].(float64); !ok {
+			t.Errorf("unexpected total: %v", timings)
+		}
+	}
+}
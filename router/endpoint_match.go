@@ -0,0 +1,47 @@
+package router
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type endpointMatchKey struct{}
+
+// endpointMatch keeps the endpoint matched by a request, so the handlers wrapping the router can report it
+type endpointMatch struct {
+	endpoint string
+}
+
+// withEndpointMatch returns the request carrying the endpointMatch of the request, reusing the one of the
+// outer handlers, if any
+func withEndpointMatch(r *http.Request) (*http.Request, *endpointMatch) {
+	if m, ok := r.Context().Value(endpointMatchKey{}).(*endpointMatch); ok {
+		return r, m
+	}
+	m := &endpointMatch{}
+	return r.WithContext(context.WithValue(r.Context(), endpointMatchKey{}, m)), m
+}
+
+// MatchEndpoint records the endpoint matched by the request for its access log entry and its metrics, and
+// into the name and the route of its server span
+func MatchEndpoint(r *http.Request, endpoint string) {
+	if m, ok := r.Context().Value(endpointMatchKey{}).(*endpointMatch); ok {
+		m.endpoint = endpoint
+	}
+	if span := trace.SpanFromContext(r.Context()); span.IsRecording() {
+		span.SetName(r.Method + " " + endpoint)
+		span.SetAttributes(attribute.String("http.route", endpoint))
+	}
+}
+
+// MatchedEndpointHandler decorates the handler of the endpoint, recording it into the access log entries, the
+// metrics and the server spans of its requests
+func MatchedEndpointHandler(endpoint string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		MatchEndpoint(r, endpoint)
+		next.ServeHTTP(w, r)
+	})
+}
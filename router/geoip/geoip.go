@@ -0,0 +1,76 @@
+// Package geoip provides a country resolver for the IP filters of the endpoints, backed by a MaxMind
+// database (GeoIP2 or GeoLite2, Country or City)
+package geoip
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/devopsfaith/krakend/router"
+)
+
+// Name is the key of the resolver in the IP filter config of the endpoints
+const Name = "maxmind"
+
+// ErrNoDatabase is the error returned when the path of the MaxMind database is not defined
+var ErrNoDatabase = errors.New("the path of the maxmind database is required")
+
+var (
+	databases   = map[string]router.CountryResolver{}
+	databasesMu = new(sync.Mutex)
+)
+
+// Register registers the MaxMind country resolver factory
+func Register() error {
+	return router.RegisterCountryResolver(Name, CountryResolverFactory)
+}
+
+// CountryResolverFactory creates a country resolver with the MaxMind database at the 'database' option of
+// the IP filter config. The endpoints declaring the same database share it
+func CountryResolverFactory(cfg map[string]interface{}) (router.CountryResolver, error) {
+	path, ok := cfg["database"].(string)
+	if !ok || path == "" {
+		return nil, ErrNoDatabase
+	}
+
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+
+	if r, ok := databases[path]; ok {
+		return r, nil
+	}
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := New(reader)
+	databases[path] = r
+	return r, nil
+}
+
+// New creates a country resolver using the received MaxMind database reader
+func New(reader *maxminddb.Reader) router.CountryResolver {
+	return resolver{reader: reader}
+}
+
+type resolver struct {
+	reader *maxminddb.Reader
+}
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// Country implements the router.CountryResolver interface
+func (r resolver) Country(ip net.IP) (string, error) {
+	record := countryRecord{}
+	if err := r.reader.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+	return record.Country.ISOCode, nil
+}
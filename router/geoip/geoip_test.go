@@ -0,0 +1,17 @@
+package geoip
+
+import (
+	"testing"
+)
+
+func TestCountryResolverFactory_noDatabase(t *testing.T) {
+	if _, err := CountryResolverFactory(map[string]interface{}{}); err != ErrNoDatabase {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCountryResolverFactory_unknownDatabase(t *testing.T) {
+	if _, err := CountryResolverFactory(map[string]interface{}{"database": "/unknown/GeoLite2-Country.mmdb"}); err == nil {
+		t.Error("error expected")
+	}
+}
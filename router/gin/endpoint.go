@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,15 +29,96 @@ func CustomErrorEndpointHandler(configuration *config.EndpointConfig, proxy prox
 	endpointTimeout := time.Duration(configuration.Timeout) * time.Millisecond
 	cacheControlHeaderValue := fmt.Sprintf("public, max-age=%d", int(configuration.CacheTTL.Seconds()))
 	isCacheEnabled := configuration.CacheTTL.Seconds() != 0
-	emptyResponse := gin.H{}
+	isCompletedHeaderEnabled := router.IsCompletedHeaderEnabled(configuration)
+	render := getRender(configuration)
 	requestGenerator := NewRequest(configuration.HeadersToPass)
+	rateLimiter, rateLimitErr := router.NewRateLimiter(configuration)
+	certVerifier, certVerifierErr := router.NewClientCertificateVerifier(configuration)
+	ipFilter, ipFilterErr := router.NewIPFilter(configuration)
+	cookiePolicy, cookiePolicyErr := router.NewCookiePolicy(configuration)
+	contract, contractErr := router.NewContract(configuration)
+	debugTimings, debugTimingsErr := router.NewDebugTimings(configuration)
 
 	return func(c *gin.Context) {
-		requestCtx, cancel := context.WithTimeout(c, endpointTimeout)
-
 		c.Header(core.KrakendHeaderName, core.KrakendHeaderValue)
 
-		response, err := proxy(requestCtx, requestGenerator(c, configuration.QueryString))
+		if ipFilterErr != nil {
+			c.AbortWithError(http.StatusInternalServerError, ipFilterErr)
+			return
+		}
+		if ipFilter != nil {
+			if err := ipFilter(c.Request); err != nil {
+				c.AbortWithError(http.StatusForbidden, err)
+				return
+			}
+		}
+		if certVerifierErr != nil {
+			c.AbortWithError(http.StatusInternalServerError, certVerifierErr)
+			return
+		}
+		if certVerifier != nil {
+			if status, err := certVerifier(c.Request); err != nil {
+				c.AbortWithError(status, err)
+				return
+			}
+		}
+		if rateLimitErr != nil {
+			c.AbortWithError(http.StatusInternalServerError, rateLimitErr)
+			return
+		}
+		if rateLimiter != nil {
+			if ok, wait := rateLimiter(c.Request); !ok {
+				c.Header("Retry-After", router.RetryAfter(wait))
+				c.AbortWithError(http.StatusTooManyRequests, router.ErrTooManyRequests)
+				return
+			}
+		}
+		if cookiePolicyErr != nil {
+			c.AbortWithError(http.StatusInternalServerError, cookiePolicyErr)
+			return
+		}
+		if contractErr != nil {
+			c.AbortWithError(http.StatusInternalServerError, contractErr)
+			return
+		}
+		if debugTimingsErr != nil {
+			c.AbortWithError(http.StatusInternalServerError, debugTimingsErr)
+			return
+		}
+		annotateTimings := false
+		if debugTimings != nil {
+			c.Request, annotateTimings = debugTimings.Start(c.Request)
+		}
+		bodyExceeded, err := router.LimitRequestBody(configuration, c.Request)
+		if err != nil {
+			c.AbortWithError(http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		if contract != nil {
+			if err := contract.Request(c.Request); err != nil {
+				if bodyExceeded() {
+					c.AbortWithError(http.StatusRequestEntityTooLarge, router.ErrRequestEntityTooLarge)
+					return
+				}
+				c.AbortWithError(router.DefaultToHTTPError(err), err)
+				return
+			}
+		}
+
+		requestCtx, cancel := context.WithTimeout(c.Request.Context(), endpointTimeout)
+
+		req := requestGenerator(c, configuration.QueryString)
+		router.WildcardParams(configuration, req.Params, c.Request.URL.Path)
+		if cookiePolicy != nil {
+			cookiePolicy.Request(c.Request, req)
+		}
+
+		response, err := proxy(requestCtx, req)
+		if bodyExceeded() {
+			c.AbortWithError(http.StatusRequestEntityTooLarge, router.ErrRequestEntityTooLarge)
+			cancel()
+			return
+		}
 		if err != nil {
 			c.AbortWithError(errF(err), err)
 			cancel()
@@ -51,16 +133,27 @@ func CustomErrorEndpointHandler(configuration *config.EndpointConfig, proxy prox
 		default:
 		}
 
+		if contract != nil {
+			if err := contract.Response(response); err != nil {
+				c.AbortWithError(router.DefaultToHTTPError(err), err)
+				cancel()
+				return
+			}
+		}
+		if cookiePolicy != nil {
+			response = cookiePolicy.Response(response)
+		}
 		if isCacheEnabled && response != nil && response.IsComplete {
 			c.Header("Cache-Control", cacheControlHeaderValue)
 		}
-
-		if response == nil {
-			c.JSON(http.StatusOK, emptyResponse)
-			cancel()
-			return
+		if isCompletedHeaderEnabled && response != nil {
+			c.Header(router.CompletedHeaderName, strconv.FormatBool(response.IsComplete))
 		}
-		c.JSON(http.StatusOK, response.Data)
+		if annotateTimings {
+			response = debugTimings.Annotate(c.Writer, c.Request, response)
+		}
+
+		render(c, response)
 		cancel()
 	}
 }
@@ -78,7 +171,7 @@ func NewRequest(headersToSend []string) func(*gin.Context, []string) *proxy.Requ
 		}
 
 		headers := make(map[string][]string, 2+len(headersToSend))
-		headers["X-Forwarded-For"] = []string{c.ClientIP()}
+		headers["X-Forwarded-For"] = []string{router.ClientIP(c.Request)}
 		headers["User-Agent"] = router.UserAgentHeaderValue
 
 		for _, k := range headersToSend {
@@ -87,16 +180,10 @@ func NewRequest(headersToSend []string) func(*gin.Context, []string) *proxy.Requ
 			}
 		}
 
-		query := make(map[string][]string, len(queryString))
-		for i := range queryString {
-			if v := c.Request.URL.Query().Get(queryString[i]); v != "" {
-				query[queryString[i]] = []string{v}
-			}
-		}
-
 		return &proxy.Request{
 			Method:  c.Request.Method,
-			Query:   query,
+			Query:   router.QueryParams(c.Request, queryString),
+			Path:    c.Request.URL.Path,
 			Body:    c.Request.Body,
 			Params:  params,
 			Headers: headers,
@@ -14,6 +14,7 @@ import (
 
 	"github.com/devopsfaith/krakend/config"
 	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
 )
 
 func TestEndpointHandler_ok(t *testing.T) {
@@ -114,3 +115,36 @@ func startGinServer(handlerFunc gin.HandlerFunc) *gin.Engine {
 
 	return router
 }
+
+func TestEndpointHandler_negotiate(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"supu": "tupu"},
+		}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Timeout:        10,
+		OutputEncoding: router.NEGOTIATE,
+	}
+	server := startGinServer(EndpointHandler(endpoint, p))
+
+	for _, tc := range []struct {
+		accept, contentType string
+	}{
+		{"", "application/json; charset=utf-8"},
+		{"text/html, application/x-yaml;q=0.9", "application/x-yaml"},
+	} {
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/_gin_endpoint/a", nil)
+		req.Header.Set("Accept", tc.accept)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+
+		if content := w.Result().Header.Get("Content-Type"); content != tc.contentType {
+			t.Errorf("%s: unexpected content type: %s", tc.accept, content)
+		}
+		if vary := w.Result().Header.Values("Vary"); len(vary) != 1 || vary[0] != "Accept" {
+			t.Errorf("%s: unexpected vary header: %v", tc.accept, vary)
+		}
+	}
+}
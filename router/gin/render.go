@@ -0,0 +1,153 @@
+package gin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
+)
+
+// Render defines the signature of the functions to be use for the final response
+// encoding and rendering
+type Render func(*gin.Context, *proxy.Response)
+
+var (
+	renderRegister = map[string]Render{
+		encoding.JSON:    jsonRender,
+		encoding.XML:     encoderRender(encoding.XMLEncoder, "application/xml"),
+		encoding.YAML:    encoderRender(encoding.YAMLEncoder, "application/x-yaml"),
+		encoding.MSGPACK: encoderRender(encoding.MsgPackEncoder, "application/msgpack"),
+		encoding.CBOR:    encoderRender(encoding.CBOREncoder, "application/cbor"),
+		encoding.NDJSON:  ndjsonRender,
+		encoding.NOOP:    noopRender,
+	}
+	emptyResponse = gin.H{}
+)
+
+// RegisterRender allows clients to register their custom renders
+func RegisterRender(name string, r Render) {
+	renderRegister[name] = r
+}
+
+func getRender(cfg *config.EndpointConfig) Render {
+	if cfg.OutputEncoding != router.NEGOTIATE {
+		return resolveRender(cfg.OutputEncoding, cfg)
+	}
+
+	renders := map[string]Render{}
+	for _, name := range encoding.MediaTypes {
+		renders[name] = resolveRender(name, cfg)
+	}
+	return func(c *gin.Context, response *proxy.Response) {
+		// the response depends on the Accept header, so the shared caches must not mix the encodings
+		c.Writer.Header().Add("Vary", "Accept")
+		render, ok := renders[router.NegotiateEncoding(c.GetHeader("Accept"), encoding.JSON)]
+		if !ok {
+			render = jsonRender
+		}
+		render(c, response)
+	}
+}
+
+// resolveRender looks for the render registered with the received name. If there is none, it tries
+// with the encoders registered at the encoding package, configured with the extra config of the endpoint.
+// Unknown names fall back to the JSON render
+func resolveRender(name string, cfg *config.EndpointConfig) Render {
+	if r, ok := renderRegister[name]; ok {
+		return r
+	}
+	if name == encoding.SSE {
+		return sseRender(cfg)
+	}
+	encoder, contentType, err := encoding.GetEncoder(name, cfg.ExtraConfig)
+	if err == encoding.ErrUnknownEncoder {
+		return jsonRender
+	}
+	if err != nil {
+		return errorRender(err)
+	}
+	return encoderRender(encoder, contentType)
+}
+
+func errorRender(err error) Render {
+	return func(c *gin.Context, _ *proxy.Response) {
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+func jsonRender(c *gin.Context, response *proxy.Response) {
+	if response == nil {
+		c.JSON(http.StatusOK, emptyResponse)
+		return
+	}
+	c.JSON(http.StatusOK, response.Data)
+}
+
+// ndjsonRender streams the body of the backend response when it was not decoded. Otherwise, it
+// encodes the response data
+func ndjsonRender(c *gin.Context, response *proxy.Response) {
+	if response == nil || response.Io == nil {
+		encoderRender(encoding.NDJSONEncoder, router.NDJSONContentType)(c, response)
+		return
+	}
+	c.Header("Content-Type", router.NDJSONContentType)
+	c.Status(http.StatusOK)
+	router.StreamLines(c.Writer, response.Io)
+}
+
+// sseRender streams the server-sent events of the backend response when it was not decoded, sending the
+// heartbeats configured for the endpoint. Otherwise, it encodes the response data as events
+func sseRender(cfg *config.EndpointConfig) Render {
+	sseCfg, _ := router.SSEConfigGetter(cfg.ExtraConfig)
+	return func(c *gin.Context, response *proxy.Response) {
+		if response == nil || response.Io == nil {
+			encoderRender(encoding.SSEEncoder, router.SSEContentType)(c, response)
+			return
+		}
+		router.SetSSEHeaders(c.Writer.Header())
+		c.Status(http.StatusOK)
+		router.StreamEvents(c.Writer, response.Io, sseCfg.Heartbeat)
+	}
+}
+
+// noopRender writes the status code, the headers and the body of the backend response untouched
+func noopRender(c *gin.Context, response *proxy.Response) {
+	if response == nil {
+		c.Status(http.StatusOK)
+		return
+	}
+	for k, vs := range response.Metadata.Headers {
+		for _, v := range vs {
+			c.Writer.Header().Add(k, v)
+		}
+	}
+	if response.Metadata.StatusCode == 0 {
+		c.Status(http.StatusOK)
+	} else {
+		c.Status(response.Metadata.StatusCode)
+	}
+	if response.Io != nil {
+		io.Copy(c.Writer, response.Io)
+	}
+}
+
+func encoderRender(encoder encoding.Encoder, contentType string) Render {
+	return func(c *gin.Context, response *proxy.Response) {
+		data := map[string]interface{}{}
+		if response != nil {
+			data = response.Data
+		}
+		buf := new(bytes.Buffer)
+		if err := encoder(buf, data); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.Data(http.StatusOK, contentType, buf.Bytes())
+	}
+}
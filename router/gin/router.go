@@ -5,13 +5,20 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/devopsfaith/krakend/config"
 	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/metrics"
 	"github.com/devopsfaith/krakend/proxy"
 	"github.com/devopsfaith/krakend/router"
+	"github.com/devopsfaith/krakend/router/acme"
+	"github.com/devopsfaith/krakend/router/graphql"
+	"github.com/devopsfaith/krakend/router/http2"
+	"github.com/devopsfaith/krakend/router/http3"
 )
 
 // Config is the struct that collects the parts the router should be builded from
@@ -21,6 +28,11 @@ type Config struct {
 	HandlerFactory HandlerFactory
 	ProxyFactory   proxy.Factory
 	Logger         logging.Logger
+	// EngineFactory creates the engines of the listeners of the service. By default, gin.Default
+	EngineFactory func() *gin.Engine
+	// ListenerMiddlewares are the middlewares of the listeners of the service, by name. The Middlewares
+	// are only applied to the endpoints served on the port of the service
+	ListenerMiddlewares map[string][]gin.HandlerFunc
 }
 
 // DefaultFactory returns a gin router factory with the injected proxy factory and logger.
@@ -32,7 +44,7 @@ func DefaultFactory(proxyFactory proxy.Factory, logger logging.Logger) router.Fa
 			Middlewares:    []gin.HandlerFunc{},
 			HandlerFactory: EndpointHandler,
 			ProxyFactory:   proxyFactory,
-			Logger:         logger,
+			Logger:         logging.Module(logger, logging.ModuleRouter),
 		},
 	)
 }
@@ -48,20 +60,22 @@ type factory struct {
 
 // New implements the factory interface
 func (rf factory) New() router.Router {
-	return ginRouter{rf.cfg, context.Background()}
+	return ginRouter{rf.cfg, context.Background(), router.NewLifecycle()}
 }
 
 // NewWithContext implements the factory interface
 func (rf factory) NewWithContext(ctx context.Context) router.Router {
-	return ginRouter{rf.cfg, ctx}
+	return ginRouter{rf.cfg, ctx, router.NewLifecycle()}
 }
 
 type ginRouter struct {
-	cfg Config
-	ctx context.Context
+	cfg       Config
+	ctx       context.Context
+	lifecycle *router.Lifecycle
 }
 
-// Run implements the router interface
+// Run implements the router interface. The debug, the OpenAPI, the captures, the metrics and the GraphQL
+// endpoints and the static directories are only registered on the port of the service
 func (r ginRouter) Run(cfg config.ServiceConfig) {
 	if !cfg.Debug {
 		gin.SetMode(gin.ReleaseMode)
@@ -70,37 +84,154 @@ func (r ginRouter) Run(cfg config.ServiceConfig) {
 	}
 
 	http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	r.lifecycle.ConfigLoaded()
+
+	r.setupEngine(r.cfg.Middlewares)
+	if cfg.Debug {
+		r.registerDebugEndpoints()
+	}
+	if oc, ok := router.OpenAPIConfigGetter(cfg.ExtraConfig); ok {
+		r.cfg.Engine.GET(oc.Path, gin.WrapH(router.OpenAPIHandler(cfg, oc)))
+	}
+	if mc, ok, err := metrics.ConfigGetter(cfg.ExtraConfig); err != nil {
+		r.cfg.Logger.Error("parsing the metrics options:", err.Error())
+	} else if ok && !mc.DisableEndpoint {
+		r.cfg.Engine.GET(mc.Path, gin.WrapH(metrics.Handler(metrics.DefaultRegistry)))
+	}
+	if gc, ok := graphql.ConfigGetter(cfg.ExtraConfig); ok {
+		if h, err := graphql.NewHandler(cfg, r.cfg.ProxyFactory); err != nil {
+			r.cfg.Logger.Error("creating the graphql handler", err.Error())
+		} else {
+			r.cfg.Engine.Any(gc.Path, gin.WrapH(h))
+		}
+	}
+	if cc, ok, err := router.CaptureConfigGetter(cfg.ExtraConfig); err != nil {
+		r.cfg.Logger.Error("parsing the debug capture options:", err.Error())
+	} else if ok && cc.Secret == "" {
+		r.cfg.Logger.Error("parsing the debug capture options:", router.ErrNoCaptureSecret.Error())
+	} else if ok {
+		h := gin.WrapF(router.CapturesHandler(cc))
+		r.cfg.Engine.GET(router.CapturesPattern, h)
+		r.cfg.Engine.GET(router.CapturesPattern+"/:id", h)
+	}
+	// the static directories are served for the paths not matching any endpoint
+	if h, err := router.StaticHandler(cfg.ExtraConfig); err != nil {
+		r.cfg.Logger.Error("serving the static directories:", err.Error())
+	} else if h != nil {
+		r.cfg.Engine.NoRoute(gin.WrapH(h))
+	}
+	r.registerKrakendEndpoints(cfg.ListenerConfig(""))
+
+	// the first server is the one of the port of the service
+	s, err := r.newServer(cfg, "")
+	if err != nil {
+		r.cfg.Logger.Critical("enabling TLS:", err.Error())
+		return
+	}
+	servers := []*http.Server{s}
+	for _, l := range cfg.Listeners {
+		lr := r
+		lr.cfg.Engine = r.newEngine()
+		lr.setupEngine(r.cfg.ListenerMiddlewares[l.Name])
+		lr.registerKrakendEndpoints(cfg.ListenerConfig(l.Name))
+		ls, err := lr.newServer(cfg, l.Name)
+		if err != nil {
+			r.cfg.Logger.Critical("enabling TLS:", err.Error())
+			return
+		}
+		servers = append(servers, ls)
+	}
+
+	challenges, err := acme.ConfigureServer(s, cfg)
+	if err != nil {
+		r.cfg.Logger.Critical("enabling ACME:", err.Error())
+		return
+	}
+	h3, err := http3.NewServer(s, cfg)
+	if err != nil {
+		r.cfg.Logger.Error("enabling HTTP/3:", err.Error())
+	}
+	for _, s := range servers {
+		if err := http2.ConfigureServer(s, cfg); err != nil {
+			r.cfg.Logger.Error("enabling HTTP/2:", err.Error())
+		}
+	}
+
+	for _, s := range servers {
+		go func(s *http.Server) {
+			r.cfg.Logger.Critical(router.ListenAndServe(s, cfg))
+		}(s)
+	}
+	if h3 != nil {
+		go func() {
+			r.cfg.Logger.Critical(h3.ListenAndServe())
+		}()
+	}
+	if challenges != nil {
+		go func() {
+			r.cfg.Logger.Critical(router.ListenAndServe(challenges, cfg))
+		}()
+	}
+
+	<-r.ctx.Done()
+	// the HTTP/3 listener keeps serving its requests in flight while the rest of them are drained
+	r.lifecycle.Shutdown(cfg, r.cfg.Logger, append(servers, challenges)...)
+	if h3 != nil {
+		h3.Close()
+	}
+	r.cfg.Logger.Info("Router execution ended")
+}
+
+func (r ginRouter) newEngine() *gin.Engine {
+	if r.cfg.EngineFactory != nil {
+		return r.cfg.EngineFactory()
+	}
+	return gin.Default()
+}
 
+// setupEngine adds the middlewares and the health, the readiness and the liveness endpoints to the engine of
+// the router
+func (r ginRouter) setupEngine(middlewares []gin.HandlerFunc) {
 	r.cfg.Engine.RedirectTrailingSlash = true
 	r.cfg.Engine.RedirectFixedPath = true
 	r.cfg.Engine.HandleMethodNotAllowed = true
 
-	r.cfg.Engine.Use(r.cfg.Middlewares...)
-
-	if cfg.Debug {
-		r.registerDebugEndpoints()
-	}
+	r.cfg.Engine.Use(middlewares...)
 
-	r.registerKrakendEndpoints(cfg.Endpoints)
+	r.cfg.Engine.GET(router.ReadinessPattern, gin.WrapF(r.lifecycle.ReadinessHandler))
+	r.cfg.Engine.GET(router.HealthPattern, gin.WrapF(r.lifecycle.HealthHandler))
+	r.cfg.Engine.GET(router.LivenessPattern, gin.WrapF(router.LivenessHandler))
+}
 
+// newServer returns the server of the listener with the name, serving the engine of the router
+func (r ginRouter) newServer(cfg config.ServiceConfig, name string) (*http.Server, error) {
+	handler, err := router.AccessLogHandler(cfg.ExtraConfig, router.CompressionHandler(cfg.ExtraConfig, r.cfg.Engine))
+	if err != nil {
+		r.cfg.Logger.Error("enabling the access log:", err.Error())
+	}
+	handler, err = router.RequestIDHandler(cfg.ExtraConfig, router.MetricsHandler(handler))
+	if err != nil {
+		r.cfg.Logger.Error("enabling the request IDs:", err.Error())
+	}
+	handler, err = router.ClientIPHandler(cfg.ExtraConfig, router.TracingHandler(handler))
+	if err != nil {
+		r.cfg.Logger.Error("resolving the client IPs:", err.Error())
+	}
+	lCfg := cfg.ListenerConfig(name)
 	s := &http.Server{
-		Addr:              fmt.Sprintf(":%d", cfg.Port),
-		Handler:           r.cfg.Engine,
+		Addr:              fmt.Sprintf(":%d", lCfg.Port),
+		Handler:           handler,
 		ReadTimeout:       cfg.ReadTimeout,
 		WriteTimeout:      cfg.WriteTimeout,
 		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
 		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		BaseContext:       r.lifecycle.BaseContext,
 	}
-
-	go func() {
-		r.cfg.Logger.Critical(s.ListenAndServe())
-	}()
-
-	<-r.ctx.Done()
-	if err := s.Shutdown(context.Background()); err != nil {
-		r.cfg.Logger.Error(err.Error())
+	if err := router.ConfigureTLS(r.ctx, s, lCfg, r.cfg.Logger); err != nil {
+		return nil, err
 	}
-	r.cfg.Logger.Info("Router execution ended")
+	return s, nil
 }
 
 func (r ginRouter) registerDebugEndpoints() {
@@ -108,17 +239,193 @@ func (r ginRouter) registerDebugEndpoints() {
 	r.cfg.Engine.GET("/__debug/*param", handler)
 	r.cfg.Engine.POST("/__debug/*param", handler)
 	r.cfg.Engine.PUT("/__debug/*param", handler)
+	r.cfg.Engine.GET(router.HostStatsPattern, gin.WrapF(router.HostStatsHandler))
 }
 
-func (r ginRouter) registerKrakendEndpoints(endpoints []*config.EndpointConfig) {
-	for _, c := range endpoints {
-		proxyStack, err := r.cfg.ProxyFactory.New(c)
+func (r ginRouter) registerKrakendEndpoints(cfg config.ServiceConfig) {
+	paths := []string{}
+	endpoints := map[string][]*config.EndpointConfig{}
+	for _, c := range cfg.Endpoints {
+		if _, ok := endpoints[c.Endpoint]; !ok {
+			paths = append(paths, c.Endpoint)
+		}
+		endpoints[c.Endpoint] = append(endpoints[c.Endpoint], c)
+	}
+
+	for _, path := range paths {
+		cors, err := router.NewEndpointCORS(cfg.ExtraConfig, endpoints[path])
 		if err != nil {
-			r.cfg.Logger.Error("calling the ProxyFactory", err.Error())
-			continue
+			r.cfg.Logger.Error("enabling CORS for", path, err.Error())
+		} else if cors != nil {
+			r.cfg.Engine.OPTIONS(path, gin.WrapF(cors.Preflight))
+		}
+
+		for _, c := range endpoints[path] {
+			proxyStack, err := r.cfg.ProxyFactory.New(c)
+			if err != nil {
+				r.cfg.Logger.Error("calling the ProxyFactory", err.Error())
+				continue
+			}
+
+			// the endpoint handlers can only report the invalid rate limits when they are requested
+			if _, _, err := router.RateLimitConfigGetter(c.ExtraConfig); err != nil {
+				r.cfg.Logger.Error("rate limiting the requests of", c.Endpoint, err.Error())
+				continue
+			}
+
+			handler := r.cfg.HandlerFactory(c, proxyStack)
+			if wsCfg, ok := router.WebSocketConfigGetter(c.ExtraConfig); ok {
+				handler = webSocketHandler(c, wsCfg, handler)
+			}
+			rh, err := router.NewEndpointResponseHeaders(cfg.ExtraConfig, c)
+			if err != nil {
+				r.cfg.Logger.Error("transforming the response headers of", c.Endpoint, err.Error())
+				continue
+			}
+			if rh != nil {
+				handler = responseHeadersHandler(rh, handler)
+			}
+			capturer, err := router.NewCapturer(cfg.ExtraConfig, c)
+			if err != nil {
+				r.cfg.Logger.Error("capturing the requests of", c.Endpoint, err.Error())
+				continue
+			}
+			if capturer != nil {
+				handler = captureHandler(capturer, handler)
+			}
+			if cors != nil {
+				handler = corsHandler(cors, handler)
+			}
+			r.registerKrakendEndpoint(c.Method, c.Endpoint, matchedEndpointHandler(c.Endpoint, handler), len(c.Backend))
+		}
+	}
+}
+
+// matchedEndpointHandler records the endpoint into the access log entries of its requests
+func matchedEndpointHandler(endpoint string, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		router.MatchEndpoint(c.Request, endpoint)
+		next(c)
+	}
+}
+
+// corsHandler adds the CORS headers to the responses of the next handler
+func corsHandler(cors *router.CORS, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cors.SetHeaders(c.Writer.Header(), c.Request)
+		next(c)
+	}
+}
+
+// responseHeadersHandler transforms the headers of the responses of the next handler just before they are
+// sent. The websocket handshakes are served untouched
+func responseHeadersHandler(rh router.ResponseHeaders, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if router.IsWebSocketUpgrade(c.Request) {
+			next(c)
+			return
 		}
+		w := &responseHeadersWriter{ResponseWriter: c.Writer, apply: rh}
+		c.Writer = w
+		next(c)
+		c.Writer = w.ResponseWriter
+		// the responses without a body are sent by gin after the handlers return
+		w.applyOnce()
+	}
+}
+
+// responseHeadersWriter applies the transformations to the headers the first time the response is written
+type responseHeadersWriter struct {
+	gin.ResponseWriter
+	apply router.ResponseHeaders
+	once  sync.Once
+}
+
+func (w *responseHeadersWriter) WriteHeaderNow() {
+	w.applyOnce()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *responseHeadersWriter) Write(b []byte) (int, error) {
+	w.applyOnce()
+	return w.ResponseWriter.Write(b)
+}
 
-		r.registerKrakendEndpoint(c.Method, c.Endpoint, r.cfg.HandlerFactory(c, proxyStack), len(c.Backend))
+func (w *responseHeadersWriter) WriteString(s string) (int, error) {
+	w.applyOnce()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseHeadersWriter) Flush() {
+	w.applyOnce()
+	w.ResponseWriter.Flush()
+}
+
+func (w *responseHeadersWriter) applyOnce() {
+	w.once.Do(func() { w.apply(w.ResponseWriter.Header()) })
+}
+
+// captureHandler records the captured requests of the endpoint. The websocket handshakes are not captured
+func captureHandler(capturer *router.Capturer, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if router.IsWebSocketUpgrade(c.Request) {
+			next(c)
+			return
+		}
+		rec, err := capturer.Start(c.Request)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if rec == nil {
+			next(c)
+			return
+		}
+		c.Request = rec.Request()
+		w := &captureWriter{ResponseWriter: c.Writer, rec: rec}
+		c.Writer = w
+		next(c)
+		c.Writer = w.ResponseWriter
+		rec.Finish(w.Status(), w.Header())
+	}
+}
+
+// captureWriter records the body of the response
+type captureWriter struct {
+	gin.ResponseWriter
+	rec *router.CaptureRecorder
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.rec.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.rec.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+type paramsKey struct{}
+
+// webSocketHandler proxies the websocket handshakes of the endpoint with the params of the gin context,
+// serving the rest of the requests with the next handler
+func webSocketHandler(cfg *config.EndpointConfig, wsCfg proxy.WebSocketConfig, next gin.HandlerFunc) gin.HandlerFunc {
+	ws := router.WebSocketHandler(cfg, wsCfg, func(r *http.Request) map[string]string {
+		params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+		return params
+	}, http.NotFoundHandler())
+
+	return func(c *gin.Context) {
+		if !router.IsWebSocketUpgrade(c.Request) {
+			next(c)
+			return
+		}
+		params := make(map[string]string, len(c.Params))
+		for _, param := range c.Params {
+			params[strings.Title(param.Key)] = param.Value
+		}
+		ws.ServeHTTP(c.Writer, c.Request.WithContext(context.WithValue(c.Request.Context(), paramsKey{}, params)))
 	}
 }
 
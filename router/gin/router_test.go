@@ -9,9 +9,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
+
 	"github.com/devopsfaith/krakend/config"
 	"github.com/devopsfaith/krakend/logging"
 	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
 )
 
 func TestDefaultFactory_ok(t *testing.T) {
@@ -215,6 +218,73 @@ func TestDefaultFactory_proxyFactoryCrash(t *testing.T) {
 	}
 }
 
+func TestDefaultFactory_listeners(t *testing.T) {
+	logger, err := logging.NewLogger("ERROR", bytes.NewBuffer(nil), "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		time.Sleep(5 * time.Millisecond)
+	}()
+
+	r := NewFactory(Config{
+		Engine:         gin.New(),
+		Middlewares:    []gin.HandlerFunc{},
+		HandlerFactory: EndpointHandler,
+		ProxyFactory:   noopProxyFactory(map[string]interface{}{"supu": "tupu"}),
+		Logger:         logger,
+		EngineFactory:  gin.New,
+		ListenerMiddlewares: map[string][]gin.HandlerFunc{
+			"admin": {func(c *gin.Context) { c.Header("X-Listener", "admin") }},
+		},
+	}).NewWithContext(ctx)
+
+	endpoint := func(path, listener string) *config.EndpointConfig {
+		return &config.EndpointConfig{
+			Endpoint: path,
+			Method:   "GET",
+			Timeout:  time.Second,
+			Backend:  []*config.Backend{{}},
+			Listener: listener,
+		}
+	}
+	go r.Run(config.ServiceConfig{
+		Port:      8078,
+		Listeners: []*config.Listener{{Name: "admin", Port: 8079}},
+		Endpoints: []*config.EndpointConfig{endpoint("/public", ""), endpoint("/admin", "admin")},
+	})
+	time.Sleep(5 * time.Millisecond)
+
+	for _, tc := range []struct {
+		url      string
+		status   int
+		listener string
+	}{
+		{url: "http://127.0.0.1:8078/public", status: http.StatusOK},
+		{url: "http://127.0.0.1:8078/admin", status: http.StatusNotFound},
+		{url: "http://127.0.0.1:8079/admin", status: http.StatusOK, listener: "admin"},
+		{url: "http://127.0.0.1:8079/public", status: http.StatusNotFound, listener: "admin"},
+		{url: "http://127.0.0.1:8079" + router.ReadinessPattern, status: http.StatusOK, listener: "admin"},
+	} {
+		resp, err := http.Get(tc.url)
+		if err != nil {
+			t.Error("requesting", tc.url, err.Error())
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("unexpected status code for %s: %d", tc.url, resp.StatusCode)
+		}
+		if resp.Header.Get("X-Listener") != tc.listener {
+			t.Errorf("unexpected middlewares for %s: %s", tc.url, resp.Header.Get("X-Listener"))
+		}
+	}
+}
+
 func checkResponseIs404(t *testing.T, req *http.Request) {
 	expectedBody := "404 page not found"
 	resp, err := http.DefaultClient.Do(req)
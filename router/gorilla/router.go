@@ -24,8 +24,9 @@ func DefaultConfig(pf proxy.Factory, logger logging.Logger) mux.Config {
 		Middlewares:    []mux.HandlerMiddleware{},
 		HandlerFactory: mux.CustomEndpointHandler(mux.NewRequestBuilder(gorillaParamsExtractor)),
 		ProxyFactory:   pf,
-		Logger:         logger,
+		Logger:         logging.Module(logger, logging.ModuleRouter),
 		DebugPattern:   "/__debug/{params}",
+		EngineFactory:  func() mux.Engine { return gorillaEngine{gorilla.NewRouter()} },
 	}
 }
 
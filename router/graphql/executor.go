@@ -0,0 +1,318 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/devopsfaith/krakend/router"
+)
+
+// resolver resolves a root field with the values of its arguments
+type resolver func(ctx context.Context, r *http.Request, args map[string]interface{}) (interface{}, error)
+
+// schema holds the resolvers of the root fields of the query and mutation types
+type schema struct {
+	query    map[string]resolver
+	mutation map[string]resolver
+}
+
+// gqlError is an item of the errors array of the GraphQL responses
+type gqlError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// requestError is the error returned when the request can not be executed
+type requestError string
+
+// Error implements the error interface
+func (e requestError) Error() string { return string(e) }
+
+// object is a response object keeping the order of the fields of the selection set
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{values: map[string]interface{}{}}
+}
+
+func (o *object) set(k string, v interface{}) {
+	if _, ok := o.values[k]; !ok {
+		o.keys = append(o.keys, k)
+	}
+	o.values[k] = v
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (o *object) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte(':')
+		if b, err = json.Marshal(o.values[k]); err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// executor executes an operation of a document
+type executor struct {
+	schema    schema
+	doc       *document
+	variables map[string]interface{}
+
+	mu     sync.Mutex
+	errors []gqlError
+}
+
+// execute runs the selected operation of the document. The fields of the queries are resolved concurrently
+// and the ones of the mutations, serially. The errors of the fields are returned with the data, and the
+// request errors (like an unknown operation or a missing variable) are returned alone
+func execute(ctx context.Context, s schema, doc *document, operationName string, variables map[string]interface{}, r *http.Request) (*object, []gqlError, error) {
+	op, err := selectOperation(doc, operationName)
+	if err != nil {
+		return nil, nil, err
+	}
+	e := &executor{schema: s, doc: doc}
+	if e.variables, err = coerceVariables(op, variables); err != nil {
+		return nil, nil, err
+	}
+
+	typeName, resolvers := "Query", s.query
+	switch op.kind {
+	case "mutation":
+		typeName, resolvers = "Mutation", s.mutation
+	case "subscription":
+		return nil, nil, requestError("the subscriptions are not supported")
+	}
+	fields, err := e.collectFields(op.selectionSet)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range fields {
+		if f.name == "__schema" || f.name == "__type" {
+			return nil, nil, requestError("the introspection is not supported")
+		}
+		if _, ok := resolvers[f.name]; !ok && f.name != "__typename" {
+			return nil, nil, requestError(fmt.Sprintf("unknown field %s on type %s", f.name, typeName))
+		}
+	}
+
+	data := newObject()
+	values := make([]interface{}, len(fields))
+	resolve := func(i int) {
+		f := fields[i]
+		if f.name == "__typename" {
+			values[i] = typeName
+			return
+		}
+		v, err := resolvers[f.name](ctx, r, e.resolveArguments(f.arguments))
+		if err != nil {
+			e.addError(gqlError{
+				Message:    err.Error(),
+				Path:       []interface{}{f.key()},
+				Extensions: map[string]interface{}{"status": router.DefaultToHTTPError(err)},
+			})
+			return
+		}
+		values[i] = e.complete(v, f.selectionSet, []interface{}{f.key()})
+	}
+	if op.kind == "mutation" {
+		for i := range fields {
+			resolve(i)
+		}
+	} else {
+		wg := sync.WaitGroup{}
+		for i := range fields {
+			wg.Add(1)
+			go func(i int) {
+				resolve(i)
+				wg.Done()
+			}(i)
+		}
+		wg.Wait()
+	}
+	for i, f := range fields {
+		data.set(f.key(), values[i])
+	}
+	return data, e.errors, nil
+}
+
+func selectOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, requestError("the operation name is required when the document has several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, requestError(fmt.Sprintf("unknown operation %s", name))
+}
+
+func coerceVariables(op *operation, values map[string]interface{}) (map[string]interface{}, error) {
+	variables := map[string]interface{}{}
+	for _, def := range op.variables {
+		v, ok := values[def.name]
+		if !ok && def.hasDefault {
+			v, ok = resolveValue(def.defaultValue, nil), true
+		}
+		if def.nonNull && v == nil {
+			return nil, requestError(fmt.Sprintf("the variable $%s is required", def.name))
+		}
+		if ok {
+			variables[def.name] = v
+		}
+	}
+	return variables, nil
+}
+
+func (e *executor) addError(err gqlError) {
+	e.mu.Lock()
+	e.errors = append(e.errors, err)
+	e.mu.Unlock()
+}
+
+// collectFields returns the fields of the selection set, expanding the fragments, skipping the excluded
+// fields and merging the selection sets of the fields with the same response key
+func (e *executor) collectFields(selectionSet []selection) ([]selection, error) {
+	fields := []selection{}
+	index := map[string]int{}
+	var collect func([]selection, map[string]bool) error
+	collect = func(ss []selection, visited map[string]bool) error {
+		for _, s := range ss {
+			if !e.included(s.directives) {
+				continue
+			}
+			switch {
+			case s.spread != "":
+				if visited[s.spread] {
+					continue
+				}
+				f, ok := e.doc.fragments[s.spread]
+				if !ok {
+					return requestError(fmt.Sprintf("unknown fragment %s", s.spread))
+				}
+				visited[s.spread] = true
+				if err := collect(f.selectionSet, visited); err != nil {
+					return err
+				}
+			case s.inline:
+				if err := collect(s.selectionSet, visited); err != nil {
+					return err
+				}
+			default:
+				if i, ok := index[s.key()]; ok {
+					fields[i].selectionSet = append(fields[i].selectionSet, s.selectionSet...)
+					continue
+				}
+				index[s.key()] = len(fields)
+				fields = append(fields, s)
+			}
+		}
+		return nil
+	}
+	err := collect(selectionSet, map[string]bool{})
+	return fields, err
+}
+
+// included evaluates the skip and include directives
+func (e *executor) included(directives []directive) bool {
+	for _, d := range directives {
+		v, _ := resolveValue(d.arguments["if"], e.variables).(bool)
+		if (d.name == "skip" && v) || (d.name == "include" && !v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *executor) resolveArguments(args map[string]interface{}) map[string]interface{} {
+	resolved := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if name, ok := v.(variable); ok {
+			if _, ok := e.variables[string(name)]; !ok {
+				continue
+			}
+		}
+		resolved[k] = resolveValue(v, e.variables)
+	}
+	return resolved
+}
+
+// resolveValue replaces the variables with their values and the enums with their names
+func resolveValue(v interface{}, variables map[string]interface{}) interface{} {
+	switch t := v.(type) {
+	case variable:
+		return variables[string(t)]
+	case enumValue:
+		return string(t)
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, item := range t {
+			list[i] = resolveValue(item, variables)
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			obj[k] = resolveValue(item, variables)
+		}
+		return obj
+	}
+	return v
+}
+
+// complete selects the fields of the selection set from the resolved value. The fields without a selection
+// set return their values as they are
+func (e *executor) complete(v interface{}, selectionSet []selection, path []interface{}) interface{} {
+	if len(selectionSet) == 0 || v == nil {
+		return v
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		fields, err := e.collectFields(selectionSet)
+		if err != nil {
+			e.addError(gqlError{Message: err.Error(), Path: path})
+			return nil
+		}
+		obj := newObject()
+		for _, f := range fields {
+			if f.name == "__typename" {
+				obj.set(f.key(), "Object")
+				continue
+			}
+			obj.set(f.key(), e.complete(t[f.name], f.selectionSet, append(path[:len(path):len(path)], f.key())))
+		}
+		return obj
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, item := range t {
+			list[i] = e.complete(item, selectionSet, append(path[:len(path):len(path)], i))
+		}
+		return list
+	}
+	e.addError(gqlError{Message: "the field is not an object, so it can not have a selection set", Path: path})
+	return nil
+}
@@ -0,0 +1,130 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func executeJSON(t *testing.T, s schema, src, operationName string, variables map[string]interface{}) (string, []gqlError, error) {
+	doc, err := parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest("POST", "/graphql", nil)
+	data, errs, err := execute(context.Background(), s, doc, operationName, variables, r)
+	if err != nil {
+		return "", nil, err
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), errs, nil
+}
+
+func TestExecute(t *testing.T) {
+	var args map[string]interface{}
+	mu := sync.Mutex{}
+	s := schema{query: map[string]resolver{
+		"user": func(_ context.Context, _ *http.Request, a map[string]interface{}) (interface{}, error) {
+			mu.Lock()
+			args = a
+			mu.Unlock()
+			return map[string]interface{}{
+				"name":  "supu",
+				"email": "supu@example.com",
+				"posts": []interface{}{
+					map[string]interface{}{"title": "a", "body": "aaa"},
+					map[string]interface{}{"title": "b", "body": "bbb"},
+				},
+			}, nil
+		},
+		"version": func(_ context.Context, _ *http.Request, _ map[string]interface{}) (interface{}, error) {
+			return "1.0", nil
+		},
+		"broken": func(_ context.Context, _ *http.Request, _ map[string]interface{}) (interface{}, error) {
+			return nil, errors.New("boom")
+		},
+	}}
+
+	data, errs, err := executeJSON(t, s, `
+		query Q($id: ID!, $full: Boolean = false) {
+			v: version
+			broken
+			user(id: $id, order: ASC) {
+				name
+				email @include(if: $full)
+				...posts
+			}
+			__typename
+		}
+		fragment posts on User { posts { title } }
+	`, "Q", map[string]interface{}{"id": "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"v":"1.0","broken":null,"user":{"name":"supu","posts":[{"title":"a"},{"title":"b"}]},"__typename":"Query"}`
+	if data != expected {
+		t.Errorf("unexpected data: %s", data)
+	}
+	if !reflect.DeepEqual(args, map[string]interface{}{"id": "42", "order": "ASC"}) {
+		t.Errorf("unexpected arguments: %v", args)
+	}
+	if len(errs) != 1 || errs[0].Message != "boom" || !reflect.DeepEqual(errs[0].Path, []interface{}{"broken"}) ||
+		errs[0].Extensions["status"] != http.StatusInternalServerError {
+		t.Errorf("unexpected errors: %+v", errs)
+	}
+
+	_, errs, err = executeJSON(t, s, `{ version { name } }`, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || !reflect.DeepEqual(errs[0].Path, []interface{}{"version"}) {
+		t.Errorf("unexpected errors: %+v", errs)
+	}
+}
+
+func TestExecute_mutationsAreSerial(t *testing.T) {
+	calls := []string{}
+	newResolver := func(name string) resolver {
+		return func(_ context.Context, _ *http.Request, _ map[string]interface{}) (interface{}, error) {
+			calls = append(calls, name)
+			return name, nil
+		}
+	}
+	s := schema{mutation: map[string]resolver{"a": newResolver("a"), "b": newResolver("b"), "c": newResolver("c")}}
+	data, _, err := executeJSON(t, s, `mutation { c a b }`, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != `{"c":"c","a":"a","b":"b"}` || !reflect.DeepEqual(calls, []string{"c", "a", "b"}) {
+		t.Errorf("unexpected result: %s %v", data, calls)
+	}
+}
+
+func TestExecute_requestErrors(t *testing.T) {
+	s := schema{query: map[string]resolver{
+		"a": func(_ context.Context, _ *http.Request, _ map[string]interface{}) (interface{}, error) { return 1, nil },
+	}}
+	for _, tc := range []struct {
+		src, operationName string
+	}{
+		{src: `{ unknown }`},
+		{src: `mutation { a }`},
+		{src: `subscription { a }`},
+		{src: `{ __schema { types { name } } }`},
+		{src: `query A { a } query B { a }`},
+		{src: `query A { a }`, operationName: "B"},
+		{src: `query ($id: ID!) { a }`},
+		{src: `{ ...missing }`},
+	} {
+		if _, _, err := executeJSON(t, s, tc.src, tc.operationName, nil); err == nil {
+			t.Errorf("expecting an error executing %q", tc.src)
+		}
+	}
+}
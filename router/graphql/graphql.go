@@ -0,0 +1,285 @@
+// Package graphql provides a GraphQL endpoint whose root fields are resolved by the configured endpoints, so
+// the clients can query several endpoints at once and select the fields of their responses they need
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
+)
+
+// Namespace is the key to look for the GraphQL options in the extra config of the service and the endpoints
+const Namespace = "github.com/devopsfaith/krakend/router/graphql"
+
+// DefaultPath is the default path of the GraphQL endpoint
+const DefaultPath = "/graphql"
+
+// Config defines the GraphQL endpoint of the service
+type Config struct {
+	// Path of the GraphQL endpoint. By default, the DefaultPath
+	Path string `json:"path"`
+}
+
+// ConfigGetter parses the GraphQL options from the extra config of the service. The second value is false
+// if the GraphQL endpoint is not enabled
+func ConfigGetter(extra config.ExtraConfig) (Config, bool) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultPath
+	}
+	return cfg, true
+}
+
+// FieldGetter returns the name of the root field resolved by the endpoint. The second value is false if the
+// endpoint is not exposed in the GraphQL schema
+func FieldGetter(extra config.ExtraConfig) (string, bool) {
+	v, ok := extra[Namespace].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	field, ok := v["field"].(string)
+	return field, ok && field != ""
+}
+
+// NewHandler returns a handler executing the GraphQL requests. The endpoints declaring a field in their extra
+// config are the root fields of the schema: the GET ones are fields of the query type and the rest of them,
+// of the mutation type. The arguments of the fields are sent as the params, the query string or the JSON body
+// of the requests to the proxies of the endpoints, created with the received factory
+func NewHandler(cfg config.ServiceConfig, pf proxy.Factory) (http.Handler, error) {
+	s := schema{query: map[string]resolver{}, mutation: map[string]resolver{}}
+	for _, e := range cfg.Endpoints {
+		field, ok := FieldGetter(e.ExtraConfig)
+		if !ok {
+			continue
+		}
+		resolvers := s.mutation
+		if e.Method == "GET" {
+			resolvers = s.query
+		}
+		if _, ok := resolvers[field]; ok {
+			return nil, fmt.Errorf("graphql: the field %s is declared by several endpoints", field)
+		}
+		p, err := pf.New(e)
+		if err != nil {
+			return nil, err
+		}
+		resolvers[field] = endpointResolver(e, p)
+	}
+	return handler{s}, nil
+}
+
+type handler struct {
+	schema schema
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// ServeHTTP implements the http.Handler interface
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := graphQLRequest{}
+	switch r.Method {
+	case "GET":
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := decodeJSON(strings.NewReader(v), &req.Variables); err != nil {
+				writeErrors(w, http.StatusBadRequest, "invalid variables: "+err.Error())
+				return
+			}
+		}
+	case "POST":
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				writeErrors(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			req.Query = string(b)
+			break
+		}
+		if err := decodeJSON(r.Body, &req); err != nil {
+			writeErrors(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeErrors(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+
+	doc, err := parse(req.Query)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.Method == "GET" {
+		if op, err := selectOperation(doc, req.OperationName); err == nil && op.kind == "mutation" {
+			w.Header().Set("Allow", "POST")
+			writeErrors(w, http.StatusMethodNotAllowed, "the mutations must be sent with POST requests")
+			return
+		}
+	}
+
+	data, errs, err := execute(r.Context(), h.schema, doc, req.OperationName, req.Variables, r)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	resp := map[string]interface{}{"data": data}
+	if len(errs) > 0 {
+		resp["errors"] = errs
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func decodeJSON(r io.Reader, v interface{}) error {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func writeErrors(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"errors": []gqlError{{Message: msg}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+var (
+	colonParamPattern = regexp.MustCompile(`/:([a-zA-Z\-_0-9\.]+)`)
+	braceParamPattern = regexp.MustCompile(`/\{([a-zA-Z\-_0-9\.]+)\}`)
+)
+
+// endpointResolver returns a resolver calling the proxy of the endpoint. The arguments named as the params
+// of the endpoint are sent as params and the ones declared in its query string, as query params. The rest of
+// them are sent as a JSON object in the body of the non GET requests
+func endpointResolver(e *config.EndpointConfig, p proxy.Proxy) resolver {
+	params := map[string]bool{}
+	for _, pattern := range []*regexp.Regexp{colonParamPattern, braceParamPattern} {
+		for _, m := range pattern.FindAllStringSubmatch(e.Endpoint, -1) {
+			params[m[1]] = true
+		}
+	}
+	queryString := map[string]bool{}
+	for _, q := range e.QueryString {
+		queryString[q] = true
+	}
+	headersToSend := e.HeadersToPass
+	if len(headersToSend) == 0 {
+		headersToSend = router.HeadersToSend
+	}
+
+	return func(ctx context.Context, r *http.Request, args map[string]interface{}) (interface{}, error) {
+		request := &proxy.Request{
+			Method: e.Method,
+			Params: map[string]string{},
+			Query:  map[string][]string{},
+			Headers: map[string][]string{
+				"X-Forwarded-For": {router.ClientIP(r)},
+				"User-Agent":      router.UserAgentHeaderValue,
+			},
+		}
+		for _, k := range headersToSend {
+			if h, ok := r.Header[k]; ok {
+				request.Headers[k] = h
+			}
+		}
+
+		body := map[string]interface{}{}
+		for k, v := range args {
+			switch {
+			case params[k]:
+				request.Params[strings.ToUpper(k[:1])+k[1:]] = argumentString(v)
+			case queryString[k]:
+				if list, ok := v.([]interface{}); ok {
+					for _, item := range list {
+						request.Query[k] = append(request.Query[k], argumentString(item))
+					}
+					continue
+				}
+				request.Query[k] = []string{argumentString(v)}
+			case e.Method == "GET":
+				return nil, fmt.Errorf("unknown argument %s", k)
+			default:
+				body[k] = v
+			}
+		}
+		for k := range params {
+			if _, ok := args[k]; !ok {
+				return nil, fmt.Errorf("the argument %s is required", k)
+			}
+		}
+		if len(body) > 0 {
+			b, err := json.Marshal(body)
+			if err != nil {
+				return nil, err
+			}
+			request.Body = ioutil.NopCloser(bytes.NewReader(b))
+			request.Headers["Content-Type"] = []string{"application/json"}
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+		resp, err := p(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			return nil, nil
+		}
+		if collection, ok := resp.Data["collection"]; ok && len(resp.Data) == 1 {
+			return collection, nil
+		}
+		return resp.Data, nil
+	}
+}
+
+func argumentString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	case nil:
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,246 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the graphql endpoint should be disabled")
+	}
+	cfg, ok := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{}})
+	if !ok || cfg.Path != DefaultPath {
+		t.Errorf("unexpected config: %+v %v", cfg, ok)
+	}
+	cfg, _ = ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"path": "/gql"}})
+	if cfg.Path != "/gql" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestFieldGetter(t *testing.T) {
+	for _, extra := range []config.ExtraConfig{
+		{},
+		{Namespace: map[string]interface{}{}},
+		{Namespace: map[string]interface{}{"field": ""}},
+	} {
+		if _, ok := FieldGetter(extra); ok {
+			t.Errorf("the endpoint should not be exposed: %v", extra)
+		}
+	}
+	if field, ok := FieldGetter(config.ExtraConfig{Namespace: map[string]interface{}{"field": "user"}}); !ok || field != "user" {
+		t.Errorf("unexpected field: %s %v", field, ok)
+	}
+}
+
+func newTestHandler(t *testing.T) (http.Handler, *recorder) {
+	rec := &recorder{requests: map[string]*proxy.Request{}}
+	cfg := config.ServiceConfig{Endpoints: []*config.EndpointConfig{
+		{
+			Endpoint:    "/users/:id",
+			Method:      "GET",
+			Timeout:     time.Second,
+			QueryString: []string{"fields"},
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"field": "user"}},
+		},
+		{
+			Endpoint:    "/users",
+			Method:      "GET",
+			Timeout:     time.Second,
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"field": "users"}},
+		},
+		{
+			Endpoint:      "/users/{id}/posts",
+			Method:        "POST",
+			Timeout:       time.Second,
+			HeadersToPass: []string{"Authorization"},
+			ExtraConfig:   config.ExtraConfig{Namespace: map[string]interface{}{"field": "createPost"}},
+		},
+		{
+			Endpoint: "/hidden",
+			Method:   "GET",
+			Timeout:  time.Second,
+		},
+	}}
+	h, err := NewHandler(cfg, proxy.FactoryFunc(func(e *config.EndpointConfig) (proxy.Proxy, error) {
+		return rec.proxy(e.Endpoint), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, rec
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	requests map[string]*proxy.Request
+	bodies   map[string]string
+}
+
+func (r *recorder) proxy(endpoint string) proxy.Proxy {
+	return func(_ context.Context, req *proxy.Request) (*proxy.Response, error) {
+		r.mu.Lock()
+		r.requests[endpoint] = req
+		if req.Body != nil {
+			b, _ := ioutil.ReadAll(req.Body)
+			if r.bodies == nil {
+				r.bodies = map[string]string{}
+			}
+			r.bodies[endpoint] = string(b)
+		}
+		r.mu.Unlock()
+
+		switch endpoint {
+		case "/users":
+			return &proxy.Response{Data: map[string]interface{}{"collection": []interface{}{
+				map[string]interface{}{"id": 1, "name": "supu"},
+				map[string]interface{}{"id": 2, "name": "tupu"},
+			}}}, nil
+		case "/users/:id":
+			if req.Params["Id"] == "0" {
+				return nil, proxy.ErrInvalidStatusCode
+			}
+			return &proxy.Response{Data: map[string]interface{}{"id": req.Params["Id"], "name": "supu", "email": "supu@example.com"}}, nil
+		}
+		return &proxy.Response{Data: map[string]interface{}{"id": 42, "title": "new post"}}, nil
+	}
+}
+
+func serveGraphQL(h http.Handler, r *http.Request) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	resp := map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestNewHandler_query(t *testing.T) {
+	h, rec := newTestHandler(t)
+
+	body := `{"query":"query Q($id: ID!) { user(id: $id, fields: [\"name\", \"email\"]) { name } users { name } }","variables":{"id":"42"}}`
+	r, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.RemoteAddr = "1.2.3.4"
+	status, resp := serveGraphQL(h, r)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", status)
+	}
+	expected := map[string]interface{}{"data": map[string]interface{}{
+		"user":  map[string]interface{}{"name": "supu"},
+		"users": []interface{}{map[string]interface{}{"name": "supu"}, map[string]interface{}{"name": "tupu"}},
+	}}
+	if !reflect.DeepEqual(resp, expected) {
+		t.Errorf("unexpected response: %v", resp)
+	}
+
+	req := rec.requests["/users/:id"]
+	if req.Method != "GET" || req.Params["Id"] != "42" || !reflect.DeepEqual(req.Query, url.Values{"fields": {"name", "email"}}) ||
+		req.Headers["X-Forwarded-For"][0] != "1.2.3.4" || req.Headers["Content-Type"][0] != "application/json" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+}
+
+func TestNewHandler_get(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	q := url.Values{"query": {"{ user(id: 0) { name } ok: user(id: 1) { id } }"}}
+	r, _ := http.NewRequest("GET", "/graphql?"+q.Encode(), nil)
+	status, resp := serveGraphQL(h, r)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", status)
+	}
+	data := resp["data"].(map[string]interface{})
+	if data["user"] != nil || !reflect.DeepEqual(data["ok"], map[string]interface{}{"id": "1"}) {
+		t.Errorf("unexpected data: %v", data)
+	}
+	errs := resp["errors"].([]interface{})
+	if len(errs) != 1 || errs[0].(map[string]interface{})["message"] != proxy.ErrInvalidStatusCode.Error() {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	q = url.Values{"query": {"mutation { createPost(id: 1) { id } }"}}
+	r, _ = http.NewRequest("GET", "/graphql?"+q.Encode(), nil)
+	if status, _ := serveGraphQL(h, r); status != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code: %d", status)
+	}
+}
+
+func TestNewHandler_mutation(t *testing.T) {
+	h, rec := newTestHandler(t)
+
+	r, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`mutation { createPost(id: 7, title: "new post", tags: [A, B]) { title } }`))
+	r.Header.Set("Content-Type", "application/graphql")
+	r.Header.Set("Authorization", "Bearer token")
+	status, resp := serveGraphQL(h, r)
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", status)
+	}
+	if !reflect.DeepEqual(resp, map[string]interface{}{"data": map[string]interface{}{"createPost": map[string]interface{}{"title": "new post"}}}) {
+		t.Errorf("unexpected response: %v", resp)
+	}
+
+	req := rec.requests["/users/{id}/posts"]
+	if req.Method != "POST" || req.Params["Id"] != "7" || req.Headers["Authorization"][0] != "Bearer token" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+	if body := rec.bodies["/users/{id}/posts"]; body != `{"tags":["A","B"],"title":"new post"}` {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+func TestNewHandler_errors(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for _, tc := range []struct {
+		method, body string
+		status       int
+	}{
+		{"PUT", `{"query":"{ users { name } }"}`, http.StatusMethodNotAllowed},
+		{"POST", `not json`, http.StatusBadRequest},
+		{"POST", `{"query":"{ users { name }"}`, http.StatusBadRequest},
+		{"POST", `{"query":"{ hidden }"}`, http.StatusBadRequest},
+		{"POST", `{"query":"{ __schema { types { name } } }"}`, http.StatusBadRequest},
+	} {
+		r, _ := http.NewRequest(tc.method, "/graphql", strings.NewReader(tc.body))
+		status, resp := serveGraphQL(h, r)
+		if status != tc.status {
+			t.Errorf("%s %s: unexpected status code: %d", tc.method, tc.body, status)
+		}
+		if errs, ok := resp["errors"].([]interface{}); !ok || len(errs) != 1 {
+			t.Errorf("%s %s: unexpected response: %v", tc.method, tc.body, resp)
+		}
+	}
+
+	r, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ users(page: 1) { name } user { name } }"}`))
+	_, resp := serveGraphQL(h, r)
+	if errs, ok := resp["errors"].([]interface{}); !ok || len(errs) != 2 {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestNewHandler_duplicatedField(t *testing.T) {
+	extra := config.ExtraConfig{Namespace: map[string]interface{}{"field": "user"}}
+	cfg := config.ServiceConfig{Endpoints: []*config.EndpointConfig{
+		{Endpoint: "/a", Method: "GET", ExtraConfig: extra},
+		{Endpoint: "/b", Method: "GET", ExtraConfig: extra},
+	}}
+	_, err := NewHandler(cfg, proxy.FactoryFunc(func(_ *config.EndpointConfig) (proxy.Proxy, error) {
+		return proxy.NoopProxy, nil
+	}))
+	if err == nil {
+		t.Error("expecting an error")
+	}
+}
@@ -0,0 +1,639 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed GraphQL request
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind         string
+	name         string
+	variables    []variableDefinition
+	selectionSet []selection
+}
+
+type variableDefinition struct {
+	name         string
+	nonNull      bool
+	defaultValue interface{}
+	hasDefault   bool
+}
+
+type fragment struct {
+	name         string
+	selectionSet []selection
+}
+
+// selection is a field, a fragment spread (with the name of the fragment) or an inline fragment
+type selection struct {
+	alias        string
+	name         string
+	arguments    map[string]interface{}
+	directives   []directive
+	selectionSet []selection
+	spread       string
+	inline       bool
+}
+
+// key returns the key of the field in the response
+func (s selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type directive struct {
+	name      string
+	arguments map[string]interface{}
+}
+
+// variable is a reference to a variable in the values of the arguments
+type variable string
+
+// enumValue is an enum literal. It is resolved as its name
+type enumValue string
+
+// parseError is the error returned when the request is not a valid GraphQL document
+type parseError struct {
+	msg  string
+	line int
+}
+
+// Error implements the error interface
+func (e parseError) Error() string {
+	return fmt.Sprintf("syntax error at line %d: %s", e.line, e.msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return l.token()
+		}
+	}
+	return token{kind: tokenEOF, line: l.line}, nil
+}
+
+func (l *lexer) token() (token, error) {
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("!$():=@[]{}|&", c) >= 0:
+		l.pos++
+		return token{tokenPunctuator, string(c), l.line}, nil
+	case c == '.':
+		if strings.HasPrefix(l.src[l.pos:], "...") {
+			l.pos += 3
+			return token{tokenPunctuator, "...", l.line}, nil
+		}
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{tokenName, l.src[start:l.pos], l.line}, nil
+	case c == '-' || isDigit(c):
+		return l.number()
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString()
+		}
+		return l.string()
+	}
+	return token{}, parseError{fmt.Sprintf("unexpected character %q", c), l.line}
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	kind := tokenInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, parseError{"invalid number", l.line}
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokenFloat
+		l.pos++
+		if digits() == 0 {
+			return token{}, parseError{"invalid number", l.line}
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokenFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return token{}, parseError{"invalid number", l.line}
+		}
+	}
+	return token{kind, l.src[start:l.pos], l.line}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	l.pos++
+	buf := new(bytes.Buffer)
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return token{tokenString, buf.String(), l.line}, nil
+		case '\n':
+			return token{}, parseError{"unterminated string", l.line}
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, parseError{"unterminated string", l.line}
+			}
+			l.pos++
+			switch e := l.src[l.pos]; e {
+			case '"', '\\', '/':
+				buf.WriteByte(e)
+			case 'b':
+				buf.WriteByte('\b')
+			case 'f':
+				buf.WriteByte('\f')
+			case 'n':
+				buf.WriteByte('\n')
+			case 'r':
+				buf.WriteByte('\r')
+			case 't':
+				buf.WriteByte('\t')
+			case 'u':
+				if l.pos+5 > len(l.src) {
+					return token{}, parseError{"invalid unicode escape", l.line}
+				}
+				r, err := strconv.ParseUint(l.src[l.pos+1:l.pos+5], 16, 32)
+				if err != nil {
+					return token{}, parseError{"invalid unicode escape", l.line}
+				}
+				buf.WriteRune(rune(r))
+				l.pos += 4
+			default:
+				return token{}, parseError{fmt.Sprintf("invalid escape \\%c", e), l.line}
+			}
+			l.pos++
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			buf.WriteRune(r)
+			l.pos += size
+		}
+	}
+	return token{}, parseError{"unterminated string", l.line}
+}
+
+func (l *lexer) blockString() (token, error) {
+	l.pos += 3
+	line := l.line
+	end := strings.Index(l.src[l.pos:], `"""`)
+	if end < 0 {
+		return token{}, parseError{"unterminated block string", l.line}
+	}
+	raw := l.src[l.pos : l.pos+end]
+	l.line += strings.Count(raw, "\n")
+	l.pos += end + 3
+	return token{tokenString, strings.TrimSpace(raw), line}, nil
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+type parser struct {
+	lexer *lexer
+	tok   token
+}
+
+// parse parses the GraphQL request
+func parse(src string) (*document, error) {
+	p := &parser{lexer: &lexer{src: src, line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc := &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokenEOF {
+		if p.tok.kind == tokenName && p.tok.value == "fragment" {
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[f.name]; ok {
+				return nil, parseError{fmt.Sprintf("duplicated fragment %s", f.name), p.tok.line}
+			}
+			doc.fragments[f.name] = f
+			continue
+		}
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		doc.operations = append(doc.operations, op)
+	}
+	if len(doc.operations) == 0 {
+		return nil, parseError{"the document has no operations", p.tok.line}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return parseError{"unexpected end of the document", p.tok.line}
+	}
+	return parseError{fmt.Sprintf("unexpected %q", p.tok.value), p.tok.line}
+}
+
+// skip consumes the punctuator if it is the current token
+func (p *parser) skip(punctuator string) (bool, error) {
+	if p.tok.kind != tokenPunctuator || p.tok.value != punctuator {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) expect(punctuator string) error {
+	ok, err := p.skip(punctuator)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: "query"}
+	if p.tok.kind == tokenPunctuator && p.tok.value == "{" {
+		ss, err := p.selectionSet()
+		op.selectionSet = ss
+		return op, err
+	}
+	kind, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if kind != "query" && kind != "mutation" && kind != "subscription" {
+		return nil, parseError{fmt.Sprintf("unknown operation type %s", kind), p.tok.line}
+	}
+	op.kind = kind
+	if p.tok.kind == tokenName {
+		if op.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for {
+			v, err := p.variableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, v)
+			if ok, err := p.skip(")"); err != nil {
+				return nil, err
+			} else if ok {
+				break
+			}
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	op.selectionSet, err = p.selectionSet()
+	return op, err
+}
+
+func (p *parser) variableDefinition() (variableDefinition, error) {
+	v := variableDefinition{}
+	if err := p.expect("$"); err != nil {
+		return v, err
+	}
+	var err error
+	if v.name, err = p.name(); err != nil {
+		return v, err
+	}
+	if err := p.expect(":"); err != nil {
+		return v, err
+	}
+	if v.nonNull, err = p.typeReference(); err != nil {
+		return v, err
+	}
+	if ok, err := p.skip("="); err != nil {
+		return v, err
+	} else if ok {
+		if v.defaultValue, err = p.value(true); err != nil {
+			return v, err
+		}
+		v.hasDefault = true
+	}
+	_, err = p.directives()
+	return v, err
+}
+
+// typeReference parses a type and returns if it is a non null type
+func (p *parser) typeReference() (bool, error) {
+	if ok, err := p.skip("["); err != nil {
+		return false, err
+	} else if ok {
+		if _, err := p.typeReference(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.name(); err != nil {
+		return false, err
+	}
+	return p.skip("!")
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	f := &fragment{}
+	var err error
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.name == "on" {
+		return nil, parseError{"invalid fragment name on", p.tok.line}
+	}
+	if on, err := p.name(); err != nil || on != "on" {
+		if err == nil {
+			err = parseError{fmt.Sprintf("unexpected %q", on), p.tok.line}
+		}
+		return nil, err
+	}
+	if _, err := p.name(); err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	f.selectionSet, err = p.selectionSet()
+	return f, err
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	selections := []selection{}
+	for {
+		if ok, err := p.skip("}"); err != nil {
+			return nil, err
+		} else if ok {
+			if len(selections) == 0 {
+				return nil, parseError{"empty selection set", p.tok.line}
+			}
+			return selections, nil
+		}
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s)
+	}
+}
+
+func (p *parser) selection() (selection, error) {
+	s := selection{}
+	var err error
+	if ok, err := p.skip("..."); err != nil {
+		return s, err
+	} else if ok {
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			if s.spread, err = p.name(); err != nil {
+				return s, err
+			}
+			s.directives, err = p.directives()
+			return s, err
+		}
+		s.inline = true
+		if p.tok.kind == tokenName {
+			if err := p.advance(); err != nil {
+				return s, err
+			}
+			if _, err := p.name(); err != nil {
+				return s, err
+			}
+		}
+		if s.directives, err = p.directives(); err != nil {
+			return s, err
+		}
+		s.selectionSet, err = p.selectionSet()
+		return s, err
+	}
+
+	if s.name, err = p.name(); err != nil {
+		return s, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return s, err
+	} else if ok {
+		s.alias = s.name
+		if s.name, err = p.name(); err != nil {
+			return s, err
+		}
+	}
+	if s.arguments, err = p.arguments(); err != nil {
+		return s, err
+	}
+	if s.directives, err = p.directives(); err != nil {
+		return s, err
+	}
+	if p.tok.kind == tokenPunctuator && p.tok.value == "{" {
+		s.selectionSet, err = p.selectionSet()
+	}
+	return s, err
+}
+
+func (p *parser) arguments() (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	if ok, err := p.skip("("); err != nil || !ok {
+		return args, err
+	}
+	for {
+		if ok, err := p.skip(")"); err != nil {
+			return nil, err
+		} else if ok {
+			if len(args) == 0 {
+				return nil, parseError{"empty arguments", p.tok.line}
+			}
+			return args, nil
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(false); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) directives() ([]directive, error) {
+	directives := []directive{}
+	for p.tok.kind == tokenPunctuator && p.tok.value == "@" {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		d := directive{}
+		var err error
+		if d.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if d.arguments, err = p.arguments(); err != nil {
+			return nil, err
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+// value parses a value. The constant values can not reference variables
+func (p *parser) value(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenInt, tokenFloat:
+		return json.Number(tok.value), p.advance()
+	case tokenString:
+		return tok.value, p.advance()
+	case tokenName:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		switch tok.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return enumValue(tok.value), nil
+	case tokenPunctuator:
+		switch tok.value {
+		case "$":
+			if constant {
+				return nil, p.unexpected()
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			return variable(name), err
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			list := []interface{}{}
+			for {
+				if ok, err := p.skip("]"); err != nil || ok {
+					return list, err
+				}
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+		case "{":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			obj := map[string]interface{}{}
+			for {
+				if ok, err := p.skip("}"); err != nil || ok {
+					return obj, err
+				}
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if obj[name], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return nil, p.unexpected()
+}
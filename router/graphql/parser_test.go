@@ -0,0 +1,106 @@
+package graphql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+		# a comment
+		query GetUser($id: ID!, $full: Boolean = true, $tags: [String!]) {
+			user(id: $id, order: ASC, filter: {name: "su\"pu", age: 42, ratio: 1.5e2}, tags: [1, 2,], active: null) {
+				name
+				alias: email @include(if: $full)
+				...postFields
+				... on User { id }
+			}
+			__typename
+		}
+		fragment postFields on User {
+			posts { title description: body(text: """a "block" string""") }
+		}
+		mutation { createUser(name: "tupu") { id } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.operations) != 2 || len(doc.fragments) != 1 {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+
+	op := doc.operations[0]
+	if op.kind != "query" || op.name != "GetUser" || len(op.variables) != 3 {
+		t.Fatalf("unexpected operation: %+v", op)
+	}
+	if v := op.variables[0]; v.name != "id" || !v.nonNull || v.hasDefault {
+		t.Errorf("unexpected variable: %+v", v)
+	}
+	if v := op.variables[1]; v.name != "full" || v.nonNull || !v.hasDefault || v.defaultValue != true {
+		t.Errorf("unexpected variable: %+v", v)
+	}
+
+	user := op.selectionSet[0]
+	expectedArgs := map[string]interface{}{
+		"id":     variable("id"),
+		"order":  enumValue("ASC"),
+		"filter": map[string]interface{}{"name": `su"pu`, "age": json.Number("42"), "ratio": json.Number("1.5e2")},
+		"tags":   []interface{}{json.Number("1"), json.Number("2")},
+		"active": nil,
+	}
+	if user.name != "user" || !reflect.DeepEqual(user.arguments, expectedArgs) {
+		t.Errorf("unexpected arguments: %+v", user.arguments)
+	}
+	if len(user.selectionSet) != 4 {
+		t.Fatalf("unexpected selection set: %+v", user.selectionSet)
+	}
+	if s := user.selectionSet[1]; s.key() != "alias" || s.name != "email" || len(s.directives) != 1 ||
+		s.directives[0].name != "include" || s.directives[0].arguments["if"] != variable("full") {
+		t.Errorf("unexpected field: %+v", s)
+	}
+	if s := user.selectionSet[2]; s.spread != "postFields" {
+		t.Errorf("unexpected spread: %+v", s)
+	}
+	if s := user.selectionSet[3]; !s.inline || len(s.selectionSet) != 1 {
+		t.Errorf("unexpected inline fragment: %+v", s)
+	}
+
+	posts := doc.fragments["postFields"].selectionSet[0]
+	if body := posts.selectionSet[1]; body.key() != "description" || body.arguments["text"] != `a "block" string` {
+		t.Errorf("unexpected field: %+v", body)
+	}
+
+	if op := doc.operations[1]; op.kind != "mutation" || op.name != "" || op.selectionSet[0].arguments["name"] != "tupu" {
+		t.Errorf("unexpected operation: %+v", op)
+	}
+}
+
+func TestParse_shorthand(t *testing.T) {
+	doc, err := parse("{ a b }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op := doc.operations[0]; op.kind != "query" || len(op.selectionSet) != 2 {
+		t.Errorf("unexpected operation: %+v", op)
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"{ a ",
+		"{ a(b: ) }",
+		"query ($a: ) { a }",
+		"{ a(b: \"unterminated) }",
+		"{ a } fragment f on T { b } fragment f on T { c }",
+		"{ a(b: $c) } extra",
+		"{ a % }",
+	} {
+		if _, err := parse(src); err == nil {
+			t.Errorf("expecting an error parsing %q", src)
+		} else if _, ok := err.(parseError); !ok {
+			t.Errorf("unexpected error type parsing %q: %T", src, err)
+		}
+	}
+}
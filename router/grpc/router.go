@@ -0,0 +1,305 @@
+// Package grpc provides a router serving the configured endpoints to the gRPC clients, transcoding the
+// calls to the methods declared in descriptor sets into requests for the proxies of the endpoints
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding/protobuf"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/proxy"
+	proxygrpc "github.com/devopsfaith/krakend/proxy/grpc"
+	"github.com/devopsfaith/krakend/router"
+)
+
+// Namespace is the key to look for the gRPC options in the extra config of the service and the endpoints
+const Namespace = "github.com/devopsfaith/krakend/router/grpc"
+
+// DefaultPort is the default port of the gRPC server
+const DefaultPort = 9090
+
+// ErrNoMethod is the error returned when the extra config of an endpoint does not define the method to serve
+var ErrNoMethod = errors.New("grpc: the method is required")
+
+// ServerConfig is the gRPC options set at the extra config of the service
+type ServerConfig struct {
+	// Port is the port of the gRPC server
+	Port int `json:"port"`
+	// DescriptorSets is the list of descriptor set files (as generated by protoc --descriptor_set_out)
+	// declaring the services. If empty, the descriptors compiled into the binary are used
+	DescriptorSets []string `json:"descriptor_sets"`
+}
+
+// ServerConfigGetter parses the gRPC options of the service. The second value is false if the service
+// does not declare them
+func ServerConfigGetter(extra config.ExtraConfig) (ServerConfig, bool) {
+	cfg := ServerConfig{Port: DefaultPort}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false
+	}
+	if cfg.Port == 0 {
+		cfg.Port = DefaultPort
+	}
+	return cfg, true
+}
+
+// EndpointConfig is the gRPC options set at the extra config of an endpoint
+type EndpointConfig struct {
+	// Method is the full name of the method served by the endpoint, like 'users.Users.GetUser'
+	Method string `json:"method"`
+}
+
+// EndpointConfigGetter parses the gRPC options of an endpoint. The second value is false if the endpoint
+// does not declare them
+func EndpointConfigGetter(extra config.ExtraConfig) (EndpointConfig, bool, error) {
+	cfg := EndpointConfig{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, err
+	}
+	if cfg.Method == "" {
+		return cfg, true, ErrNoMethod
+	}
+	return cfg, true, nil
+}
+
+// Config is the struct that collects the parts the router should be built from
+type Config struct {
+	ProxyFactory  proxy.Factory
+	Logger        logging.Logger
+	ServerOptions []gogrpc.ServerOption
+}
+
+// DefaultFactory returns a gRPC router factory with the injected proxy factory and logger
+func DefaultFactory(pf proxy.Factory, logger logging.Logger) router.Factory {
+	return NewFactory(Config{ProxyFactory: pf, Logger: logging.Module(logger, logging.ModuleRouter)})
+}
+
+// NewFactory returns a gRPC router factory with the injected configuration
+func NewFactory(cfg Config) router.Factory {
+	return factory{cfg}
+}
+
+type factory struct {
+	cfg Config
+}
+
+// New implements the factory interface
+func (rf factory) New() router.Router {
+	return grpcRouter{rf.cfg, context.Background()}
+}
+
+// NewWithContext implements the factory interface
+func (rf factory) NewWithContext(ctx context.Context) router.Router {
+	return grpcRouter{rf.cfg, ctx}
+}
+
+type grpcRouter struct {
+	cfg Config
+	ctx context.Context
+}
+
+// Run implements the router interface
+func (r grpcRouter) Run(cfg config.ServiceConfig) {
+	r.RunWithUpdates(cfg, nil)
+}
+
+// RunWithUpdates implements the router.UpdatableRouter interface. The methods of every received
+// configuration replace the current ones atomically, but the port of the server is not updated.
+// The router does nothing if the service does not declare the gRPC options
+func (r grpcRouter) RunWithUpdates(cfg config.ServiceConfig, updates <-chan config.ServiceConfig) {
+	sc, ok := ServerConfigGetter(cfg.ExtraConfig)
+	if !ok {
+		r.cfg.Logger.Info("The gRPC server is not enabled")
+		return
+	}
+
+	current := &atomic.Value{}
+	current.Store(r.newMethodTable(cfg))
+
+	opts := append([]gogrpc.ServerOption{}, r.cfg.ServerOptions...)
+	opts = append(opts, gogrpc.UnknownServiceHandler(func(_ interface{}, stream gogrpc.ServerStream) error {
+		method, _ := gogrpc.MethodFromServerStream(stream)
+		return current.Load().(methodTable).serve(method, stream)
+	}))
+	server := gogrpc.NewServer(opts...)
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", sc.Port))
+	if err != nil {
+		r.cfg.Logger.Critical(err)
+		return
+	}
+	go func() {
+		r.cfg.Logger.Critical(server.Serve(l))
+	}()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			server.GracefulStop()
+			r.cfg.Logger.Info("gRPC router execution ended")
+			return
+		case newCfg := <-updates:
+			current.Store(r.newMethodTable(newCfg))
+			r.cfg.Logger.Info("gRPC methods updated")
+		}
+	}
+}
+
+// newMethodTable builds the proxies of the endpoints declaring a gRPC method, indexed by the path of the method
+func (r grpcRouter) newMethodTable(cfg config.ServiceConfig) methodTable {
+	table := methodTable{}
+	sc, _ := ServerConfigGetter(cfg.ExtraConfig)
+	files, err := protobuf.Files(sc.DescriptorSets)
+	if err != nil {
+		r.cfg.Logger.Error("loading the gRPC descriptors:", err.Error())
+		return table
+	}
+
+	for _, e := range cfg.Endpoints {
+		ec, ok, err := EndpointConfigGetter(e.ExtraConfig)
+		if !ok {
+			continue
+		}
+		if err != nil {
+			r.cfg.Logger.Error("gRPC method of the endpoint", e.Endpoint, err.Error())
+			continue
+		}
+		d, err := files.FindDescriptorByName(protoreflect.FullName(ec.Method))
+		if err != nil {
+			r.cfg.Logger.Error("looking for the gRPC method", ec.Method, err.Error())
+			continue
+		}
+		md, ok := d.(protoreflect.MethodDescriptor)
+		if !ok || md.IsStreamingClient() || md.IsStreamingServer() {
+			r.cfg.Logger.Error("the gRPC method", ec.Method, "is not a unary method")
+			continue
+		}
+		path := "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+		if _, ok := table[path]; ok {
+			r.cfg.Logger.Error("the gRPC method", ec.Method, "is already served by another endpoint")
+			continue
+		}
+		p, err := r.cfg.ProxyFactory.New(e)
+		if err != nil {
+			r.cfg.Logger.Error("calling the ProxyFactory", err.Error())
+			continue
+		}
+		table[path] = method{descriptor: md, endpoint: e, proxy: p}
+		r.cfg.Logger.Debug("gRPC method", path, "served by the endpoint", e.Method, e.Endpoint)
+	}
+	return table
+}
+
+type methodTable map[string]method
+
+type method struct {
+	descriptor protoreflect.MethodDescriptor
+	endpoint   *config.EndpointConfig
+	proxy      proxy.Proxy
+}
+
+func (t methodTable) serve(path string, stream gogrpc.ServerStream) error {
+	m, ok := t[path]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", path)
+	}
+
+	in := dynamicpb.NewMessage(m.descriptor.Input())
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	request, err := NewRequest(m.endpoint, in, md)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(stream.Context(), m.endpoint.Timeout)
+	defer cancel()
+	response, err := m.proxy(ctx, request)
+	if err != nil {
+		return toStatusError(err)
+	}
+	if router.IsCompletedHeaderEnabled(m.endpoint) && response != nil {
+		stream.SetTrailer(metadata.Pairs(router.CompletedHeaderName, strconv.FormatBool(response.IsComplete)))
+	}
+
+	var data map[string]interface{}
+	if response != nil {
+		data = response.Data
+	}
+	out, err := NewOutputMessage(m.descriptor.Output(), data)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return stream.SendMsg(out)
+}
+
+// toStatusError converts the errors of the proxies into gRPC status errors. The errors of the gRPC backends
+// keep their status and the rest of the errors get the status equivalent to the HTTP status code the
+// routers would return
+func toStatusError(err error) error {
+	if e, ok := err.(proxygrpc.Error); ok {
+		return status.Error(e.Code, e.Message)
+	}
+	if err == context.DeadlineExceeded {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codeFromStatusCode(router.DefaultToHTTPError(err)), err.Error())
+}
+
+func codeFromStatusCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,192 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/proxy"
+	proxygrpc "github.com/devopsfaith/krakend/proxy/grpc"
+)
+
+func testDescriptorSetFile(t *testing.T) string {
+	b, err := proto.Marshal(testFileDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "krakend_grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(b)
+	f.Close()
+	return f.Name()
+}
+
+type fakeStream struct {
+	ctx     context.Context
+	in      proto.Message
+	out     proto.Message
+	trailer metadata.MD
+}
+
+func (f *fakeStream) SetHeader(metadata.MD) error  { return nil }
+func (f *fakeStream) SendHeader(metadata.MD) error { return nil }
+func (f *fakeStream) SetTrailer(md metadata.MD)    { f.trailer = md }
+func (f *fakeStream) Context() context.Context     { return f.ctx }
+func (f *fakeStream) SendMsg(m interface{}) error {
+	f.out = m.(proto.Message)
+	return nil
+}
+
+// RecvMsg copies the input through the wire format, as the message is built from another instance of its
+// descriptor
+func (f *fakeStream) RecvMsg(m interface{}) error {
+	b, err := proto.Marshal(f.in)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m.(proto.Message))
+}
+
+func TestServerConfigGetter(t *testing.T) {
+	if _, ok := ServerConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the server should be disabled")
+	}
+	cfg, ok := ServerConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{}})
+	if !ok || cfg.Port != DefaultPort {
+		t.Errorf("unexpected config: %v %+v", ok, cfg)
+	}
+	cfg, _ = ServerConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"port":            50051,
+		"descriptor_sets": []interface{}{"users.pb"},
+	}})
+	if cfg.Port != 50051 || !reflect.DeepEqual(cfg.DescriptorSets, []string{"users.pb"}) {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestEndpointConfigGetter(t *testing.T) {
+	if _, ok, err := EndpointConfigGetter(config.ExtraConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if _, ok, err := EndpointConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{}}); !ok || err != ErrNoMethod {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	cfg, _, err := EndpointConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"method": "test.Users.GetUser"}})
+	if err != nil || cfg.Method != "test.Users.GetUser" {
+		t.Errorf("unexpected result: %+v %v", cfg, err)
+	}
+}
+
+func TestMethodTable(t *testing.T) {
+	path := testDescriptorSetFile(t)
+	defer os.Remove(path)
+
+	buff := new(bytes.Buffer)
+	logger, _ := logging.NewLogger("ERROR", buff, "")
+	pf := proxy.FactoryFunc(func(e *config.EndpointConfig) (proxy.Proxy, error) {
+		return func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+			if r.Params["Id"] == "0" {
+				return nil, proxygrpc.Error{Code: codes.NotFound, Message: "unknown user"}
+			}
+			return &proxy.Response{
+				Data:       map[string]interface{}{"id": r.Params["Id"], "user_name": r.Headers["Authorization"][0]},
+				IsComplete: false,
+			}, nil
+		}, nil
+	})
+	r := DefaultFactory(pf, logger).New().(grpcRouter)
+
+	method := func(m string) config.ExtraConfig {
+		return config.ExtraConfig{Namespace: map[string]interface{}{"method": m}}
+	}
+	table := r.newMethodTable(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"descriptor_sets": []interface{}{path}}},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint:      "/users/{id}",
+				Method:        "GET",
+				Timeout:       time.Second,
+				HeadersToPass: []string{"Authorization"},
+				ExtraConfig: config.ExtraConfig{
+					Namespace:       map[string]interface{}{"method": "test.Users.GetUser"},
+					proxy.Namespace: map[string]interface{}{"completed_header": true},
+				},
+			},
+			{Endpoint: "/users/{id}", Method: "POST", ExtraConfig: method("test.Users.GetUser")},
+			{Endpoint: "/watch/{id}", ExtraConfig: method("test.Users.WatchUser")},
+			{Endpoint: "/unknown", ExtraConfig: method("test.Users.DeleteUser")},
+			{Endpoint: "/http"},
+		},
+	})
+	if len(table) != 1 {
+		t.Fatalf("unexpected methods: %v", table)
+	}
+	for _, msg := range []string{"already served", "WatchUser is not a unary method", "DeleteUser"} {
+		if !strings.Contains(buff.String(), msg) {
+			t.Errorf("the error %q has not been logged: %s", msg, buff.String())
+		}
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "supu"))
+	stream := &fakeStream{ctx: ctx, in: testRequestMessage(t, "42", 1)}
+	if err := table.serve("/test.Users/GetUser", stream); err != nil {
+		t.Fatal(err)
+	}
+	data, err := proxygrpc.OutputData(stream.out.(*dynamicpb.Message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, map[string]interface{}{"id": "42", "user_name": "supu"}) {
+		t.Errorf("unexpected response: %v", data)
+	}
+	if v := stream.trailer.Get("x-krakend-completed"); len(v) != 1 || v[0] != "false" {
+		t.Errorf("unexpected trailer: %v", stream.trailer)
+	}
+
+	stream = &fakeStream{ctx: ctx, in: testRequestMessage(t, "0", 1)}
+	if err := table.serve("/test.Users/GetUser", stream); status.Code(err) != codes.NotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := table.serve("/test.Users/DeleteUser", stream); status.Code(err) != codes.Unimplemented {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+type statusCodeError int
+
+func (s statusCodeError) Error() string   { return "status code error" }
+func (s statusCodeError) StatusCode() int { return int(s) }
+
+func TestToStatusError(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected codes.Code
+	}{
+		{proxygrpc.Error{Code: codes.AlreadyExists}, codes.AlreadyExists},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{statusCodeError(401), codes.Unauthenticated},
+		{statusCodeError(429), codes.ResourceExhausted},
+		{proxy.ErrConcurrencyLimitExceeded, codes.Unavailable},
+		{errors.New("supu"), codes.Internal},
+	} {
+		if c := status.Code(toStatusError(tc.err)); c != tc.expected {
+			t.Errorf("%v: unexpected code %s", tc.err, c)
+		}
+	}
+}
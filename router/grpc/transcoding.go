@@ -0,0 +1,122 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
+)
+
+// NewRequest transcodes the input message of a call into a request for the proxy of the endpoint. The
+// scalar fields of the message are the params of the request (with their first letter in upper case, as
+// the routers do), the fields declared in the query string of the endpoint are sent as query params, the
+// whole message is the JSON body of the request and the metadata declared in the headers to pass of the
+// endpoint are sent as headers
+func NewRequest(endpoint *config.EndpointConfig, msg protoreflect.Message, md map[string][]string) (*proxy.Request, error) {
+	body, err := (protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}).Marshal(msg.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{}
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsList() || fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+			continue
+		}
+		name := string(fd.Name())
+		params[strings.ToUpper(name[:1])+name[1:]] = scalarString(fd, msg.Get(fd))
+	}
+
+	query := map[string][]string{}
+	for _, k := range endpoint.QueryString {
+		fd := fieldByName(msg.Descriptor(), k)
+		if fd == nil || !msg.Has(fd) || fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+			continue
+		}
+		if !fd.IsList() {
+			query[k] = []string{scalarString(fd, msg.Get(fd))}
+			continue
+		}
+		list := msg.Get(fd).List()
+		for j := 0; j < list.Len(); j++ {
+			query[k] = append(query[k], scalarString(fd, list.Get(j)))
+		}
+	}
+
+	headers := map[string][]string{
+		"Content-Type": {"application/json"},
+		"User-Agent":   router.UserAgentHeaderValue,
+	}
+	for _, k := range endpoint.HeadersToPass {
+		if v, ok := md[strings.ToLower(k)]; ok {
+			headers[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+
+	return &proxy.Request{
+		Method:  endpoint.Method,
+		Query:   query,
+		Body:    ioutil.NopCloser(bytes.NewReader(body)),
+		Params:  params,
+		Headers: headers,
+	}, nil
+}
+
+// NewOutputMessage transcodes the data of the response of the proxy into a message of the received type,
+// discarding the data not declared in the message
+func NewOutputMessage(md protoreflect.MessageDescriptor, data map[string]interface{}) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(md)
+	if len(data) == 0 {
+		return msg, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(b, msg); err != nil {
+		return nil, fmt.Errorf("grpc: encoding the response: %s", err.Error())
+	}
+	return msg, nil
+}
+
+func fieldByName(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if strings.EqualFold(string(fd.Name()), name) || strings.EqualFold(fd.JSONName(), name) {
+			return fd
+		}
+	}
+	return nil
+}
+
+func scalarString(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.BytesKind:
+		return string(v.Bytes())
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32)
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	default:
+		return v.String()
+	}
+}
@@ -0,0 +1,153 @@
+package grpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func testField(name string, number int32, label descriptorpb.FieldDescriptorProto_Label, kind descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  label.Enum(),
+		Type:   kind.Enum(),
+	}
+}
+
+func testFileDescriptorSet() *descriptorpb.FileDescriptorSet {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	return &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("users.proto"),
+				Package: proto.String("test"),
+				Syntax:  proto.String("proto3"),
+				MessageType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("GetUserRequest"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							testField("id", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+							testField("fields", 2, repeated, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+							testField("page", 3, optional, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+						},
+					},
+					{
+						Name: proto.String("User"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							testField("id", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+							testField("user_name", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+						},
+					},
+				},
+				Service: []*descriptorpb.ServiceDescriptorProto{
+					{
+						Name: proto.String("Users"),
+						Method: []*descriptorpb.MethodDescriptorProto{
+							{
+								Name:       proto.String("GetUser"),
+								InputType:  proto.String(".test.GetUserRequest"),
+								OutputType: proto.String(".test.User"),
+							},
+							{
+								Name:            proto.String("WatchUser"),
+								InputType:       proto.String(".test.GetUserRequest"),
+								OutputType:      proto.String(".test.User"),
+								ServerStreaming: proto.Bool(true),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func testMessageDescriptor(t *testing.T, name string) protoreflect.MessageDescriptor {
+	files, err := protodesc.NewFiles(testFileDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.(protoreflect.MessageDescriptor)
+}
+
+func testRequestMessage(t *testing.T, id string, page int32, fields ...string) *dynamicpb.Message {
+	md := testMessageDescriptor(t, "test.GetUserRequest")
+	msg := dynamicpb.NewMessage(md)
+	msg.Set(md.Fields().ByName("id"), protoreflect.ValueOfString(id))
+	msg.Set(md.Fields().ByName("page"), protoreflect.ValueOfInt32(page))
+	list := msg.Mutable(md.Fields().ByName("fields")).List()
+	for _, f := range fields {
+		list.Append(protoreflect.ValueOfString(f))
+	}
+	return msg
+}
+
+func TestNewRequest(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Endpoint:      "/users/{id}",
+		Method:        "POST",
+		QueryString:   []string{"fields", "page", "unknown"},
+		HeadersToPass: []string{"Authorization"},
+	}
+	msg := testRequestMessage(t, "42", 0, "a", "b")
+	r, err := NewRequest(endpoint, msg, map[string][]string{"authorization": {"Bearer supu"}, "x-ignored": {"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Method != "POST" {
+		t.Errorf("unexpected method: %s", r.Method)
+	}
+	if !reflect.DeepEqual(r.Params, map[string]string{"Id": "42", "Page": "0"}) {
+		t.Errorf("unexpected params: %v", r.Params)
+	}
+	if !reflect.DeepEqual(map[string][]string(r.Query), map[string][]string{"fields": {"a", "b"}}) {
+		t.Errorf("unexpected query: %v", r.Query)
+	}
+	if h := r.Headers["Authorization"]; len(h) != 1 || h[0] != "Bearer supu" {
+		t.Errorf("unexpected headers: %v", r.Headers)
+	}
+	if _, ok := r.Headers["X-Ignored"]; ok {
+		t.Errorf("unexpected headers: %v", r.Headers)
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	var body map[string]interface{}
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(body, map[string]interface{}{"id": "42", "fields": []interface{}{"a", "b"}, "page": 0.0}) {
+		t.Errorf("unexpected body: %s", b)
+	}
+}
+
+func TestNewOutputMessage(t *testing.T) {
+	md := testMessageDescriptor(t, "test.User")
+	msg, err := NewOutputMessage(md, map[string]interface{}{"id": "42", "user_name": "supu", "unknown": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := dynamicpb.NewMessage(md)
+	expected.Set(md.Fields().ByName("id"), protoreflect.ValueOfString("42"))
+	expected.Set(md.Fields().ByName("user_name"), protoreflect.ValueOfString("supu"))
+	if !proto.Equal(msg, expected) {
+		t.Errorf("unexpected message: %v", msg)
+	}
+
+	if _, err := NewOutputMessage(md, map[string]interface{}{"id": 42}); err == nil {
+		t.Error("error expected")
+	}
+}
@@ -0,0 +1,117 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// HealthPattern is the path of the endpoint reporting the status of the service and its backends
+const HealthPattern = "/__health"
+
+// LivenessPattern is the path of the endpoint reporting if the process is alive
+const LivenessPattern = "/__live"
+
+// Statuses reported by the health and the readiness endpoints
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusDegraded    = "degraded"
+	HealthStatusUnavailable = "unavailable"
+	HealthStatusDraining    = "draining"
+)
+
+// Health is the payload of the health endpoint
+type Health struct {
+	// Status is HealthStatusOK, HealthStatusDegraded when some hosts of the backends are unhealthy,
+	// HealthStatusUnavailable when a backend is down or HealthStatusDraining once the shutdown has started
+	Status string `json:"status"`
+	// ConfigVersion is the number of configurations loaded by the router, starting with 1
+	ConfigVersion int `json:"config_version"`
+	// ConfigLoadedAt is the time the current configuration was loaded
+	ConfigLoadedAt time.Time `json:"config_loaded_at"`
+	// Uptime is the time since the router was created
+	Uptime string `json:"uptime"`
+	// Backends are the backends using a service discovery or health checks
+	Backends []proxy.BackendHealth `json:"backends"`
+}
+
+// ConfigLoaded registers the load of a new configuration by the router, increasing the config version
+// reported by the health endpoint
+func (l *Lifecycle) ConfigLoaded() {
+	l.configMu.Lock()
+	l.configVersion++
+	l.configLoadedAt = time.Now()
+	l.configMu.Unlock()
+}
+
+// Health returns the status of the service and its backends
+func (l *Lifecycle) Health() Health {
+	l.configMu.RLock()
+	h := Health{
+		Status:         HealthStatusOK,
+		ConfigVersion:  l.configVersion,
+		ConfigLoadedAt: l.configLoadedAt,
+		Uptime:         time.Since(l.started).Round(time.Second).String(),
+		Backends:       proxy.BackendsHealth(),
+	}
+	l.configMu.RUnlock()
+
+	for _, b := range h.Backends {
+		if b.Status == proxy.BackendStatusDown {
+			h.Status = HealthStatusUnavailable
+			break
+		}
+		if b.Status == proxy.BackendStatusDegraded {
+			h.Status = HealthStatusDegraded
+		}
+	}
+	if !l.IsReady() {
+		h.Status = HealthStatusDraining
+	}
+	return h
+}
+
+// HealthHandler responds with the Health of the service. The status code is 503 when the service is draining
+// or a backend is down, so it can be used as a readiness check too
+func (l *Lifecycle) HealthHandler(w http.ResponseWriter, _ *http.Request) {
+	h := l.Health()
+	w.Header().Set("Content-Type", "application/json")
+	if h.Status == HealthStatusUnavailable || h.Status == HealthStatusDraining {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(h)
+}
+
+// ReadinessHandler responds with a 200 status code while the service is ready to receive traffic. It responds
+// with a 503 once its shutdown has started or when a backend using a service discovery or health checks has
+// no healthy host or fails to discover them, listing the backends down
+func (l *Lifecycle) ReadinessHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !l.IsReady() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"draining"}`))
+		return
+	}
+	down := []string{}
+	for _, b := range proxy.BackendsHealth() {
+		if b.Status == proxy.BackendStatusDown {
+			down = append(down, b.Backend)
+		}
+	}
+	if len(down) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": HealthStatusUnavailable, "backends": down})
+		return
+	}
+	w.Write([]byte(`{"status":"ready"}`))
+}
+
+// LivenessHandler responds with a 200 status code while the process is able to serve requests. It does not
+// check the backends nor the shutdown, so the orchestrators do not restart the service when its dependencies
+// fail or while it is draining
+func LivenessHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"alive"}`))
+}
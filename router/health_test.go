@@ -0,0 +1,101 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+func TestLifecycle_HealthHandler(t *testing.T) {
+	l := NewLifecycle()
+	l.ConfigLoaded()
+	l.ConfigLoaded()
+
+	w := httptest.NewRecorder()
+	l.HealthHandler(w, httptest.NewRequest("GET", HealthPattern, nil))
+	h := Health{}
+	if err := json.Unmarshal(w.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || h.Status != HealthStatusOK || h.ConfigVersion != 2 || h.ConfigLoadedAt.IsZero() ||
+		len(h.Backends) != 0 {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	l.Shutdown(config.ServiceConfig{}, noopLogger())
+
+	w = httptest.NewRecorder()
+	l.HealthHandler(w, httptest.NewRequest("GET", HealthPattern, nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusServiceUnavailable || h.Status != HealthStatusDraining {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLifecycle_backendsDown(t *testing.T) {
+	defer proxy.ResetBackendsHealth()
+
+	pf := proxy.NewDefaultFactory(proxy.CustomHTTPProxyFactory(proxy.NewHTTPClient), noopLogger())
+	cfg := &config.EndpointConfig{
+		Endpoint: "/down",
+		Backend: []*config.Backend{{
+			URLPattern: "/down",
+			Host:       []string{"http://a"},
+			ExtraConfig: config.ExtraConfig{
+				proxy.Namespace: map[string]interface{}{"health_check": map[string]interface{}{"max_failures": 1.0}},
+			},
+		}},
+	}
+	if _, err := pf.New(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLifecycle()
+	w := httptest.NewRecorder()
+	l.ReadinessHandler(w, httptest.NewRequest("GET", ReadinessPattern, nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"ready"}` {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	proxy.ResetBackendsHealth()
+	cfg.Backend[0].SD = "health_test"
+	sd.RegisterSubscriberFactory("health_test", func(*config.Backend) sd.Subscriber {
+		return sd.FixedSubscriber{}
+	})
+	pf = proxy.NewDefaultFactoryWithSubscriber(proxy.CustomHTTPProxyFactory(proxy.NewHTTPClient), noopLogger(), sd.GetSubscriber)
+	if _, err := pf.New(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	l.ReadinessHandler(w, httptest.NewRequest("GET", ReadinessPattern, nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != `{"backends":["/down"],"status":"unavailable"}`+"\n" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	l.HealthHandler(w, httptest.NewRequest("GET", HealthPattern, nil))
+	h := Health{}
+	if err := json.Unmarshal(w.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusServiceUnavailable || h.Status != HealthStatusUnavailable || len(h.Backends) != 1 ||
+		h.Backends[0].Status != proxy.BackendStatusDown {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLivenessHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	LivenessHandler(w, httptest.NewRequest("GET", LivenessPattern, nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"alive"}` {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,17 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// HostStatsPattern is the path of the endpoint exposing the statistics of the backend hosts
+const HostStatsPattern = "/__stats/hosts"
+
+// HostStatsHandler responds with the statistics of the hosts of the load balanced backends, by URL pattern
+func HostStatsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proxy.BackendHostStats())
+}
@@ -0,0 +1,26 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestHostStatsHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	HostStatsHandler(w, httptest.NewRequest("GET", HostStatsPattern, nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	stats := map[string][]proxy.HostStats{}
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+}
@@ -0,0 +1,55 @@
+// Package http2 enables HTTP/2 in the servers of the routers, negotiated with ALPN over TLS and served
+// over the plaintext connections with prior knowledge or upgrading the HTTP/1.1 ones (h2c)
+package http2
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// Namespace is the key to look for the HTTP/2 options in the extra config of the service
+const Namespace = "github.com/devopsfaith/krakend/router/http2"
+
+// Config is the HTTP/2 options set at the extra config of the service
+type Config struct {
+	// MaxConcurrentStreams is the max number of streams of every client connection. By default, 250
+	MaxConcurrentStreams uint32 `json:"max_concurrent_streams"`
+}
+
+// ConfigGetter parses the HTTP/2 options from the extra config. The second value is false if HTTP/2 is
+// not enabled
+func ConfigGetter(extra config.ExtraConfig) (Config, bool) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false
+	}
+	return cfg, true
+}
+
+// ConfigureServer enables HTTP/2 in the server when the service declares the HTTP/2 options. It wraps the
+// handler of the server, so it must be called once the handler is set
+func ConfigureServer(s *http.Server, cfg config.ServiceConfig) error {
+	c, ok := ConfigGetter(cfg.ExtraConfig)
+	if !ok {
+		return nil
+	}
+	h2s := &http2.Server{
+		MaxConcurrentStreams: c.MaxConcurrentStreams,
+		IdleTimeout:          cfg.IdleTimeout,
+	}
+	s.Handler = h2c.NewHandler(s.Handler, h2s)
+	return http2.ConfigureServer(s, h2s)
+}
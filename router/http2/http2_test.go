@@ -0,0 +1,76 @@
+package http2
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/http2"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok := ConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("HTTP/2 should be disabled")
+	}
+	cfg, ok := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"max_concurrent_streams": 10}})
+	if !ok || cfg.MaxConcurrentStreams != 10 {
+		t.Errorf("unexpected config: %+v %v", cfg, ok)
+	}
+}
+
+func protoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+}
+
+func TestConfigureServer_disabled(t *testing.T) {
+	h := protoHandler()
+	s := &http.Server{Handler: h}
+	if err := ConfigureServer(s, config.ServiceConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Handler.(http.HandlerFunc); !ok {
+		t.Errorf("the handler should not be wrapped: %T", s.Handler)
+	}
+}
+
+func TestConfigureServer(t *testing.T) {
+	s := httptest.NewUnstartedServer(protoHandler())
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{}}}
+	if err := ConfigureServer(s.Config, cfg); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Close()
+
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	for _, tc := range []struct {
+		client   *http.Client
+		expected string
+	}{
+		{http.DefaultClient, "HTTP/1.1"},
+		{h2cClient, "HTTP/2.0"},
+	} {
+		resp, err := tc.client.Get(s.URL)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != tc.expected {
+			t.Errorf("unexpected protocol: %s", string(b))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package http3 adds a QUIC listener serving HTTP/3 alongside the TCP listener of the routers, sharing
+// their handler and advertising it to the clients of the TCP listener with the Alt-Svc header
+package http3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// Namespace is the key to look for the HTTP/3 options in the extra config of the service
+const Namespace = "github.com/devopsfaith/krakend/router/http3"
+
+// DefaultMaxAge is the default number of seconds the clients should remember the HTTP/3 listener
+const DefaultMaxAge = 86400
+
+// ErrNoCertificate is the error returned when the HTTP/3 options do not declare the certificate of the
+// listener. QUIC always requires TLS
+var ErrNoCertificate = errors.New("http3: the public and the private keys are required")
+
+// Config is the HTTP/3 options set at the extra config of the service
+type Config struct {
+	// Port is the UDP port of the QUIC listener. By default, the port of the service
+	Port int `json:"port"`
+	// PublicKey is the path of the PEM encoded certificate of the listener
+	PublicKey string `json:"public_key"`
+	// PrivateKey is the path of the PEM encoded private key of the listener
+	PrivateKey string `json:"private_key"`
+	// MaxAge is the number of seconds the clients should remember the HTTP/3 listener. By default, the
+	// DefaultMaxAge
+	MaxAge int `json:"max_age"`
+}
+
+// ConfigGetter parses the HTTP/3 options from the extra config. The second value is false if HTTP/3 is
+// not enabled
+func ConfigGetter(extra config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, true, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, true, err
+	}
+	if cfg.PublicKey == "" || cfg.PrivateKey == "" {
+		return cfg, true, ErrNoCertificate
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = DefaultMaxAge
+	}
+	return cfg, true, nil
+}
+
+// Server is a QUIC listener serving HTTP/3
+type Server struct {
+	cfg    Config
+	server *http3.Server
+}
+
+// NewServer returns a HTTP/3 server sharing the handler of the received server, when the service enables
+// HTTP/3. The handler of the received server is wrapped, so its responses advertise the HTTP/3 listener with
+// the Alt-Svc header. The returned server is nil if HTTP/3 is not enabled
+func NewServer(s *http.Server, cfg config.ServiceConfig) (*Server, error) {
+	c, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if !ok || err != nil {
+		return nil, err
+	}
+	if c.Port == 0 {
+		c.Port = cfg.Port
+	}
+	h3 := &http3.Server{
+		Addr:    fmt.Sprintf(":%d", c.Port),
+		Handler: s.Handler,
+	}
+	s.Handler = AltSvcHandler(c, s.Handler)
+	return &Server{cfg: c, server: h3}, nil
+}
+
+// ListenAndServe listens on the UDP port and serves the HTTP/3 requests until the server is closed
+func (s *Server) ListenAndServe() error {
+	return s.server.ListenAndServeTLS(s.cfg.PublicKey, s.cfg.PrivateKey)
+}
+
+// Close closes the listener and the connections of the server
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// AltSvcHandler decorates the handler, so its responses advertise the HTTP/3 listener
+func AltSvcHandler(cfg Config, h http.Handler) http.Handler {
+	altSvc := fmt.Sprintf(`h3=":%d"; ma=%d`, cfg.Port, cfg.MaxAge)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", altSvc)
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,79 @@
+package http3
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestConfigGetter(t *testing.T) {
+	if _, ok, err := ConfigGetter(config.ExtraConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if _, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{"public_key": "cert.pem"}}); !ok || err != ErrNoCertificate {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	cfg, ok, err := ConfigGetter(config.ExtraConfig{Namespace: map[string]interface{}{
+		"public_key":  "cert.pem",
+		"private_key": "key.pem",
+		"port":        8443,
+	}})
+	if !ok || err != nil {
+		t.Fatalf("unexpected result: %v %v", ok, err)
+	}
+	if cfg.Port != 8443 || cfg.MaxAge != DefaultMaxAge || cfg.PublicKey != "cert.pem" || cfg.PrivateKey != "key.pem" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestNewServer_disabled(t *testing.T) {
+	h := http.NotFoundHandler()
+	s := &http.Server{Handler: h}
+	h3, err := NewServer(s, config.ServiceConfig{Port: 8080})
+	if h3 != nil || err != nil {
+		t.Errorf("unexpected result: %v %v", h3, err)
+	}
+
+	h3, err = NewServer(s, config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{}}})
+	if h3 != nil || err != ErrNoCertificate {
+		t.Errorf("unexpected result: %v %v", h3, err)
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	h3, err := NewServer(s, config.ServiceConfig{Port: 8080, ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"public_key":  "cert.pem",
+		"private_key": "key.pem",
+		"max_age":     60,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h3.server.Addr != ":8080" {
+		t.Errorf("unexpected address: %s", h3.server.Addr)
+	}
+
+	for _, h := range []http.Handler{s.Handler, h3.server.Handler} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Body.String() != "ok" {
+			t.Errorf("unexpected body: %s", w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if altSvc := w.Header().Get("Alt-Svc"); altSvc != `h3=":8080"; ma=60` {
+		t.Errorf("unexpected Alt-Svc header: %s", altSvc)
+	}
+	w = httptest.NewRecorder()
+	h3.server.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if altSvc := w.Header().Get("Alt-Svc"); altSvc != "" {
+		t.Errorf("the HTTP/3 responses should not advertise the listener: %s", altSvc)
+	}
+}
@@ -0,0 +1,142 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/devopsfaith/krakend/audit"
+	"github.com/devopsfaith/krakend/config"
+)
+
+// IPFilterNamespace is the key to look for the IP filter of the endpoints in their extra config
+const IPFilterNamespace = "github.com/devopsfaith/krakend/router/ip_filter"
+
+var (
+	// ErrIPNotAllowed is the error returned when the IP of the client is not allowed by the filter of the
+	// endpoint
+	ErrIPNotAllowed = errors.New("ip not allowed")
+	// ErrUnknownCountryResolver is the error returned when the filter declares countries and its country
+	// resolver is not registered
+	ErrUnknownCountryResolver = errors.New("unknown country resolver")
+)
+
+// IPFilterConfig defines the clients allowed to access an endpoint. The denied IPs and countries take
+// precedence over the allowed ones. If there are allowed IPs or countries, the rest of the clients are
+// rejected
+type IPFilterConfig struct {
+	// Allow are the IPs and CIDRs of the allowed clients
+	Allow []string `json:"allow"`
+	// Deny are the IPs and CIDRs of the rejected clients
+	Deny []string `json:"deny"`
+	// AllowCountries are the ISO 3166-1 alpha-2 codes of the countries of the allowed clients
+	AllowCountries []string `json:"allow_countries"`
+	// DenyCountries are the ISO 3166-1 alpha-2 codes of the countries of the rejected clients
+	DenyCountries []string `json:"deny_countries"`
+	// CountryResolver is the name of the registered CountryResolver locating the clients. It is required
+	// by the country filters
+	CountryResolver string `json:"country_resolver"`
+}
+
+// CountryResolver returns the ISO 3166-1 alpha-2 code of the country of an IP
+type CountryResolver interface {
+	Country(ip net.IP) (string, error)
+}
+
+// CountryResolverFactory creates a CountryResolver with the IP filter options of an endpoint
+type CountryResolverFactory func(cfg map[string]interface{}) (CountryResolver, error)
+
+var countryResolvers = map[string]CountryResolverFactory{}
+
+// RegisterCountryResolver registers the country resolver factory with the given name
+func RegisterCountryResolver(name string, f CountryResolverFactory) error {
+	countryResolvers[name] = f
+	return nil
+}
+
+// IPFilter checks if the client of the request is allowed to access the endpoint. If not, it returns the
+// ErrIPNotAllowed
+type IPFilter func(*http.Request) error
+
+// NewIPFilter creates an IPFilter with the options of the extra config of the endpoint. The clients are
+// identified by the IP resolved by the ClientIPHandler. It returns a nil IPFilter if the endpoint does not
+// declare a filter
+func NewIPFilter(cfg *config.EndpointConfig) (IPFilter, error) {
+	v, ok := cfg.ExtraConfig[IPFilterNamespace].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	filterCfg := IPFilterConfig{}
+	if err := json.Unmarshal(b, &filterCfg); err != nil {
+		return nil, err
+	}
+
+	allow, err := NewTrustedProxies(filterCfg.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := NewTrustedProxies(filterCfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+	allowCountries := countrySet(filterCfg.AllowCountries)
+	denyCountries := countrySet(filterCfg.DenyCountries)
+
+	var resolver CountryResolver
+	if len(allowCountries) > 0 || len(denyCountries) > 0 {
+		rf, ok := countryResolvers[filterCfg.CountryResolver]
+		if !ok {
+			return nil, ErrUnknownCountryResolver
+		}
+		if resolver, err = rf(v); err != nil {
+			return nil, err
+		}
+	}
+	restricted := len(allow) > 0 || len(allowCountries) > 0
+
+	filter := func(r *http.Request) error {
+		ip := net.ParseIP(ClientIP(r))
+		if ip == nil {
+			return ErrIPNotAllowed
+		}
+		if deny.Contains(ip) {
+			return ErrIPNotAllowed
+		}
+		country := ""
+		if resolver != nil {
+			// the clients not located are only rejected by the allowed countries
+			if c, err := resolver.Country(ip); err == nil {
+				country = strings.ToUpper(c)
+			}
+		}
+		if denyCountries[country] {
+			return ErrIPNotAllowed
+		}
+		if !restricted || allow.Contains(ip) || allowCountries[country] {
+			return nil
+		}
+		return ErrIPNotAllowed
+	}
+	return func(r *http.Request) error {
+		err := filter(r)
+		if err != nil {
+			auditRequest(audit.EventIPBlock, audit.OutcomeDenied, cfg, r, "", err)
+		}
+		return err
+	}, nil
+}
+
+func countrySet(countries []string) map[string]bool {
+	set := make(map[string]bool, len(countries))
+	for _, c := range countries {
+		set[strings.ToUpper(c)] = true
+	}
+	delete(set, "")
+	return set
+}
@@ -0,0 +1,100 @@
+package router
+
+import (
+	"errors"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+type countryResolver map[string]string
+
+func (c countryResolver) Country(ip net.IP) (string, error) {
+	country, ok := c[ip.String()]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return country, nil
+}
+
+func TestNewIPFilter(t *testing.T) {
+	RegisterCountryResolver("test", func(_ map[string]interface{}) (CountryResolver, error) {
+		return countryResolver{"1.1.1.1": "es", "2.2.2.2": "US", "3.3.3.3": "FR", "10.0.0.1": "ES"}, nil
+	})
+	defer delete(countryResolvers, "test")
+
+	for _, tc := range []struct {
+		name    string
+		cfg     map[string]interface{}
+		allowed []string
+		denied  []string
+	}{
+		{
+			name:    "deny",
+			cfg:     map[string]interface{}{"deny": []interface{}{"10.0.0.0/8", "2001:db8::/32"}},
+			allowed: []string{"1.1.1.1", "2001:db9::1"},
+			denied:  []string{"10.0.0.1", "2001:db8::1"},
+		},
+		{
+			name:    "allow",
+			cfg:     map[string]interface{}{"allow": []interface{}{"10.0.0.0/8"}, "deny": []interface{}{"10.0.0.1"}},
+			allowed: []string{"10.0.0.2"},
+			denied:  []string{"10.0.0.1", "1.1.1.1"},
+		},
+		{
+			name:    "deny countries",
+			cfg:     map[string]interface{}{"deny_countries": []interface{}{"us", "FR"}, "country_resolver": "test"},
+			allowed: []string{"1.1.1.1", "4.4.4.4"},
+			denied:  []string{"2.2.2.2", "3.3.3.3"},
+		},
+		{
+			name: "allow countries",
+			cfg: map[string]interface{}{
+				"allow_countries":  []interface{}{"ES"},
+				"allow":            []interface{}{"3.3.3.3"},
+				"deny":             []interface{}{"10.0.0.0/8"},
+				"country_resolver": "test",
+			},
+			allowed: []string{"1.1.1.1", "3.3.3.3"},
+			denied:  []string{"2.2.2.2", "4.4.4.4", "10.0.0.1"},
+		},
+	} {
+		filter, err := NewIPFilter(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{IPFilterNamespace: tc.cfg}})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		for _, ip := range tc.allowed {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = net.JoinHostPort(ip, "1234")
+			if err := filter(req); err != nil {
+				t.Errorf("%s: %s must be allowed: %v", tc.name, ip, err)
+			}
+		}
+		for _, ip := range tc.denied {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = net.JoinHostPort(ip, "1234")
+			if err := filter(req); err != ErrIPNotAllowed {
+				t.Errorf("%s: %s must be denied: %v", tc.name, ip, err)
+			}
+		}
+	}
+}
+
+func TestNewIPFilter_ko(t *testing.T) {
+	if filter, err := NewIPFilter(&config.EndpointConfig{}); filter != nil || err != nil {
+		t.Errorf("unexpected result: %v", err)
+	}
+	for _, cfg := range []map[string]interface{}{
+		{"allow": []interface{}{"supu"}},
+		{"deny": []interface{}{"10.0.0.0/33"}},
+		{"allow_countries": []interface{}{"ES"}},
+		{"deny_countries": []interface{}{"ES"}, "country_resolver": "unknown"},
+	} {
+		if _, err := NewIPFilter(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{IPFilterNamespace: cfg}}); err == nil {
+			t.Errorf("%v: error expected", cfg)
+		}
+	}
+}
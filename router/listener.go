@@ -0,0 +1,252 @@
+package router
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+)
+
+// ListenersEnv is the environment variable declaring the addresses of the listeners inherited from the
+// previous process of the gateway, in the order of their file descriptors (starting at 3)
+const ListenersEnv = "KRAKEND_LISTENERS"
+
+// ErrReusePortUnsupported is the error returned when the platform does not support the SO_REUSEPORT option
+var ErrReusePortUnsupported = errors.New("listener: SO_REUSEPORT is not supported in this platform")
+
+var (
+	listenersMu sync.Mutex
+	// inherited are the files of the listeners passed by the previous process, by address
+	inherited map[string]*os.File
+	// active are the listeners of the process, passed to the new one on restart
+	active = map[string]*trackedListener{}
+	// parentPID is the process notified once a listener is taken over. It is zero if the listeners were
+	// not inherited
+	parentPID int
+)
+
+// Listen returns a TCP listener on the address. If the process was started by Restart with a listener on the
+// address, it takes it over and sends a SIGTERM to the previous process, so it drains its requests in flight.
+// Otherwise, it creates a new one, setting the SO_REUSEPORT option if reusePort is true, so a new process can
+// bind the same port before the current one stops
+func Listen(addr string, reusePort bool) (net.Listener, error) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	if inherited == nil {
+		loadInheritedListeners()
+	}
+
+	var ln net.Listener
+	var err error
+	if f, ok := inherited[addr]; ok {
+		delete(inherited, addr)
+		ln, err = net.FileListener(f)
+		f.Close()
+		if err == nil && parentPID != 0 {
+			if p, err := os.FindProcess(parentPID); err == nil {
+				p.Signal(syscall.SIGTERM)
+			}
+			parentPID = 0
+		}
+	} else if reusePort {
+		ln, err = listenReusePort(addr)
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tl := &trackedListener{Listener: ln, addr: addr}
+	active[addr] = tl
+	return tl, nil
+}
+
+// loadInheritedListeners wraps the file descriptors declared by the ListenersEnv variable, removing it so
+// the processes started by the gateway do not inherit it
+func loadInheritedListeners() {
+	inherited = map[string]*os.File{}
+	v := os.Getenv(ListenersEnv)
+	if v == "" {
+		return
+	}
+	os.Unsetenv(ListenersEnv)
+	for i, addr := range strings.Split(v, ",") {
+		inherited[addr] = os.NewFile(uintptr(3+i), "listener "+addr)
+	}
+	parentPID = os.Getppid()
+}
+
+// ListenAndServe listens on the address of the server with Listen and serves it with TLS if the TLS config of
+// the server has a source of certificates, like the store set by ConfigureTLS or the ACME manager, and with
+// plaintext otherwise. The TLS config set by other settings, like HTTP/2, does not enable TLS. The listener reads the PROXY protocol header of the load
+// balancers declared by the PROXY protocol settings of the config or, in their absence, of the trusted
+// proxies if the client IP options of the service enable it
+func ListenAndServe(s *http.Server, cfg config.ServiceConfig) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	allowed, err := proxyProtocolAllowedIPs(cfg)
+	if err != nil {
+		return err
+	}
+	ln, err := Listen(addr, cfg.ReusePort)
+	if err != nil {
+		return err
+	}
+	if len(allowed) > 0 {
+		ln = NewProxyProtocolListener(ln, allowed)
+	}
+	if hasCertificates(s.TLSConfig) {
+		return s.ServeTLS(ln, "", "")
+	}
+	return s.Serve(ln)
+}
+
+// hasCertificates checks if the TLS config is able to present a certificate to the clients
+func hasCertificates(c *tls.Config) bool {
+	return c != nil && (len(c.Certificates) > 0 || c.GetCertificate != nil || c.GetConfigForClient != nil)
+}
+
+// proxyProtocolAllowedIPs returns the peers allowed to send a PROXY protocol header. It is empty if the
+// PROXY protocol is not enabled
+func proxyProtocolAllowedIPs(cfg config.ServiceConfig) (TrustedProxies, error) {
+	if cfg.ProxyProtocol != nil {
+		if cfg.ProxyProtocol.IsDisabled {
+			return nil, nil
+		}
+		return NewTrustedProxies(cfg.ProxyProtocol.AllowedIPs)
+	}
+	ipCfg, _, err := ClientIPConfigGetter(cfg.ExtraConfig)
+	if err != nil || !ipCfg.ProxyProtocol {
+		return nil, err
+	}
+	return NewTrustedProxies(ipCfg.TrustedProxies)
+}
+
+// Restart starts a new process of the gateway with the same arguments and environment, passing it the active
+// listeners. The binary is read again, so it can be upgraded. The new process takes the listeners over with
+// Listen and then sends a SIGTERM to the current one, while the pending connections stay in the shared
+// sockets, so none of them is lost
+func Restart() (*os.Process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, files, err := activeListenerFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	env := []string{}
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, ListenersEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(env, ListenersEnv+"="+strings.Join(addrs, ","))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go cmd.Wait()
+	return cmd.Process, nil
+}
+
+// activeListenerFiles returns the sorted addresses of the active listeners and copies of their files
+func activeListenerFiles() ([]string, []*os.File, error) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	addrs := make([]string, 0, len(active))
+	for addr := range active {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	files := make([]*os.File, 0, len(addrs))
+	for _, addr := range addrs {
+		filer, ok := active[addr].Listener.(interface {
+			File() (*os.File, error)
+		})
+		var f *os.File
+		var err error
+		if !ok {
+			err = fmt.Errorf("listener: the listener of %s can not be passed", addr)
+		} else {
+			f, err = filer.File()
+		}
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	return addrs, files, nil
+}
+
+// NotifyRestart calls Restart every time the process receives a SIGUSR2, until the context is canceled. It
+// does nothing in the platforms without that signal
+func NotifyRestart(ctx context.Context, logger logging.Logger) {
+	if restartSignal == nil {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, restartSignal)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				p, err := Restart()
+				if err != nil {
+					logger.Error("restarting the gateway:", err.Error())
+					continue
+				}
+				logger.Info("Gateway restarted. New process:", p.Pid)
+			}
+		}
+	}()
+}
+
+// trackedListener removes itself from the active listeners once closed
+type trackedListener struct {
+	net.Listener
+	addr string
+}
+
+// Close implements the net.Listener interface
+func (l *trackedListener) Close() error {
+	listenersMu.Lock()
+	if active[l.addr] == l {
+		delete(active, l.addr)
+	}
+	listenersMu.Unlock()
+	return l.Listener.Close()
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package router
+
+import (
+	"net"
+	"os"
+)
+
+var restartSignal os.Signal
+
+func listenReusePort(_ string) (net.Listener, error) {
+	return nil, ErrReusePortUnsupported
+}
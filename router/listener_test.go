@@ -0,0 +1,175 @@
+package router
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestListen(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs, files, err := activeListenerFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		f.Close()
+	}
+	if len(addrs) != 1 || addrs[0] != "127.0.0.1:0" {
+		t.Errorf("unexpected active listeners: %v", addrs)
+	}
+
+	ln.Close()
+	if addrs, _, _ := activeListenerFiles(); len(addrs) != 0 {
+		t.Errorf("the closed listener is still active: %v", addrs)
+	}
+}
+
+func TestListen_inherited(t *testing.T) {
+	previous, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := previous.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := previous.Addr().String()
+
+	listenersMu.Lock()
+	inherited = map[string]*os.File{addr: f}
+	listenersMu.Unlock()
+
+	ln, err := Listen(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if ln.Addr().String() != addr {
+		t.Errorf("the listener was not taken over: %s", ln.Addr().String())
+	}
+
+	// the connections pending in the socket of the previous listener are accepted by the new one
+	previous.Close()
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	resp, err := http.Get("http://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("unexpected response: %s", string(body))
+	}
+
+	listenersMu.Lock()
+	_, ok := inherited[addr]
+	listenersMu.Unlock()
+	if ok {
+		t.Error("the inherited listener can not be taken over twice")
+	}
+}
+
+func TestListen_reusePort(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0", true)
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		if err != ErrReusePortUnsupported {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	other, err := Listen(ln.Addr().String(), true)
+	if err != nil {
+		t.Fatalf("the port can not be reused: %s", err.Error())
+	}
+	other.Close()
+}
+
+func TestProxyProtocolAllowedIPs(t *testing.T) {
+	clientIP := config.ExtraConfig{ClientIPNamespace: map[string]interface{}{
+		"trusted_proxies": []interface{}{"192.168.1.1"},
+		"proxy_protocol":  true,
+	}}
+	for _, tc := range []struct {
+		name     string
+		cfg      config.ServiceConfig
+		expected []string
+	}{
+		{name: "disabled", cfg: config.ServiceConfig{}},
+		{name: "allowed ips", cfg: config.ServiceConfig{ProxyProtocol: &config.ProxyProtocol{AllowedIPs: []string{"10.0.0.0/8"}}, ExtraConfig: clientIP}, expected: []string{"10.0.0.0/8"}},
+		{name: "disabled settings", cfg: config.ServiceConfig{ProxyProtocol: &config.ProxyProtocol{IsDisabled: true}, ExtraConfig: clientIP}},
+		{name: "trusted proxies", cfg: config.ServiceConfig{ExtraConfig: clientIP}, expected: []string{"192.168.1.1/32"}},
+	} {
+		allowed, err := proxyProtocolAllowedIPs(tc.cfg)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if len(allowed) != len(tc.expected) {
+			t.Errorf("%s: unexpected allowed IPs: %v", tc.name, allowed)
+			continue
+		}
+		for i, network := range allowed {
+			if network.String() != tc.expected[i] {
+				t.Errorf("%s: unexpected allowed IPs: %v", tc.name, allowed)
+			}
+		}
+	}
+}
+
+func TestListenAndServe_plaintextWithTLSConfig(t *testing.T) {
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := free.Addr().String()
+	free.Close()
+
+	// the TLS config without certificates set by the HTTP/2 settings must not enable TLS
+	s := &http.Server{
+		Addr:      addr,
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) }),
+		TLSConfig: &tls.Config{NextProtos: []string{"h2", "http/1.1"}},
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- ListenAndServe(s, config.ServiceConfig{}) }()
+	defer s.Close()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://" + addr); err == nil {
+			break
+		}
+		select {
+		case err := <-errs:
+			t.Fatal(err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "ok" {
+		t.Errorf("unexpected response: %s", b)
+	}
+}
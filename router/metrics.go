@@ -0,0 +1,37 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/devopsfaith/krakend/metrics"
+)
+
+var (
+	routerRequests = metrics.DefaultRegistry.Counter("krakend_router_requests_total",
+		"Requests served by the router. The requests not matching any endpoint have an empty endpoint.",
+		"endpoint", "method", "status_class")
+	routerDuration = metrics.DefaultRegistry.Histogram("krakend_router_request_duration_seconds",
+		"Latency of the requests served by the router.", metrics.DefaultBuckets, "endpoint", "method", "status_class")
+	rateLimitRequests = metrics.DefaultRegistry.Counter("krakend_router_rate_limit_requests_total",
+		"Requests checked by the rate limiters of the endpoints, by result: allowed or rejected.", "endpoint", "result")
+)
+
+// MetricsHandler decorates the handler, recording the requests and their latency into the metrics.DefaultRegistry,
+// labelled by the matched endpoint, the method and the class of the status code
+func MetricsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, match := withEndpointMatch(r)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		class := metrics.StatusClass(status)
+		routerRequests.Inc(match.endpoint, r.Method, class)
+		routerDuration.Observe(time.Since(start).Seconds(), match.endpoint, r.Method, class)
+	})
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/metrics"
+)
+
+// routerMetricValue returns the value of the counter or the count of the histogram with the labels. The
+// collectors are shared by all the tests, so they must check the increments instead of the absolute values
+func routerMetricValue(name string, labels ...string) float64 {
+	for _, f := range metrics.DefaultRegistry.Gather() {
+		if f.Name != name {
+			continue
+		}
+	next:
+		for _, m := range f.Metrics {
+			for i, l := range m.Labels {
+				if l.Value != labels[i] {
+					continue next
+				}
+			}
+			if f.Kind == metrics.KindHistogram {
+				return float64(m.Count)
+			}
+			return m.Value
+		}
+	}
+	return 0
+}
+
+func TestMetricsHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics-test/", MatchedEndpointHandler("/metrics-test/{id}", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})))
+	h := MetricsHandler(mux)
+	requests := routerMetricValue("krakend_router_requests_total", "/metrics-test/{id}", "POST", "2xx")
+	observations := routerMetricValue("krakend_router_request_duration_seconds", "/metrics-test/{id}", "POST", "2xx")
+	unmatched := routerMetricValue("krakend_router_requests_total", "", "PUT", "4xx")
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/metrics-test/1", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/metrics-test/2", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/unknown", nil))
+
+	if v := routerMetricValue("krakend_router_requests_total", "/metrics-test/{id}", "POST", "2xx") - requests; v != 2 {
+		t.Errorf("unexpected requests: %v", v)
+	}
+	if v := routerMetricValue("krakend_router_request_duration_seconds", "/metrics-test/{id}", "POST", "2xx") - observations; v != 2 {
+		t.Errorf("unexpected observations: %v", v)
+	}
+	if v := routerMetricValue("krakend_router_requests_total", "", "PUT", "4xx") - unmatched; v != 1 {
+		t.Errorf("unexpected unmatched requests: %v", v)
+	}
+}
+
+func TestNewRateLimiter_metrics(t *testing.T) {
+	limiter, err := NewRateLimiter(&config.EndpointConfig{
+		Endpoint: "/rate-limit-metrics",
+		ExtraConfig: config.ExtraConfig{RateLimitNamespace: map[string]interface{}{
+			"max_rate": 0.001,
+			"capacity": 1,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := routerMetricValue("krakend_router_rate_limit_requests_total", "/rate-limit-metrics", "allowed")
+	rejected := routerMetricValue("krakend_router_rate_limit_requests_total", "/rate-limit-metrics", "rejected")
+	req := httptest.NewRequest("GET", "/rate-limit-metrics", nil)
+	limiter(req)
+	limiter(req)
+	limiter(req)
+	if v := routerMetricValue("krakend_router_rate_limit_requests_total", "/rate-limit-metrics", "allowed") - allowed; v != 1 {
+		t.Errorf("unexpected allowed requests: %v", v)
+	}
+	if v := routerMetricValue("krakend_router_rate_limit_requests_total", "/rate-limit-metrics", "rejected") - rejected; v != 2 {
+		t.Errorf("unexpected rejected requests: %v", v)
+	}
+}
@@ -2,9 +2,9 @@ package mux
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/devopsfaith/krakend/config"
@@ -31,7 +31,14 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF router.ToHTTPErr
 		endpointTimeout := time.Duration(configuration.Timeout) * time.Millisecond
 		cacheControlHeaderValue := fmt.Sprintf("public, max-age=%d", int(configuration.CacheTTL.Seconds()))
 		isCacheEnabled := configuration.CacheTTL.Seconds() != 0
-		emptyResponse := []byte("{}")
+		isCompletedHeaderEnabled := router.IsCompletedHeaderEnabled(configuration)
+		render := getRender(configuration)
+		rateLimiter, rateLimitErr := router.NewRateLimiter(configuration)
+		certVerifier, certVerifierErr := router.NewClientCertificateVerifier(configuration)
+		ipFilter, ipFilterErr := router.NewIPFilter(configuration)
+		cookiePolicy, cookiePolicyErr := router.NewCookiePolicy(configuration)
+		contract, contractErr := router.NewContract(configuration)
+		debugTimings, debugTimingsErr := router.NewDebugTimings(configuration)
 
 		headersToSend := configuration.HeadersToPass
 		if len(headersToSend) == 0 {
@@ -44,10 +51,83 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF router.ToHTTPErr
 				http.Error(w, "", http.StatusMethodNotAllowed)
 				return
 			}
+			if ipFilterErr != nil {
+				http.Error(w, ipFilterErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			if ipFilter != nil {
+				if err := ipFilter(r); err != nil {
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				}
+			}
+			if certVerifierErr != nil {
+				http.Error(w, certVerifierErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			if certVerifier != nil {
+				if status, err := certVerifier(r); err != nil {
+					http.Error(w, err.Error(), status)
+					return
+				}
+			}
+			if rateLimitErr != nil {
+				http.Error(w, rateLimitErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			if rateLimiter != nil {
+				if ok, wait := rateLimiter(r); !ok {
+					w.Header().Set("Retry-After", router.RetryAfter(wait))
+					http.Error(w, router.ErrTooManyRequests.Error(), http.StatusTooManyRequests)
+					return
+				}
+			}
+			if cookiePolicyErr != nil {
+				http.Error(w, cookiePolicyErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			if contractErr != nil {
+				http.Error(w, contractErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			if debugTimingsErr != nil {
+				http.Error(w, debugTimingsErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			annotateTimings := false
+			if debugTimings != nil {
+				r, annotateTimings = debugTimings.Start(r)
+			}
+			bodyExceeded, err := router.LimitRequestBody(configuration, r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if contract != nil {
+				if err := contract.Request(r); err != nil {
+					if bodyExceeded() {
+						http.Error(w, router.ErrRequestEntityTooLarge.Error(), http.StatusRequestEntityTooLarge)
+						return
+					}
+					http.Error(w, err.Error(), router.DefaultToHTTPError(err))
+					return
+				}
+			}
+
+			requestCtx, cancel := context.WithTimeout(r.Context(), endpointTimeout)
 
-			requestCtx, cancel := context.WithTimeout(context.Background(), endpointTimeout)
+			req := rb(r, configuration.QueryString, headersToSend)
+			router.WildcardParams(configuration, req.Params, r.URL.Path)
+			if cookiePolicy != nil {
+				cookiePolicy.Request(r, req)
+			}
 
-			response, err := proxy(requestCtx, rb(r, configuration.QueryString, headersToSend))
+			response, err := proxy(requestCtx, req)
+			if bodyExceeded() {
+				http.Error(w, router.ErrRequestEntityTooLarge.Error(), http.StatusRequestEntityTooLarge)
+				cancel()
+				return
+			}
 			if err != nil {
 				http.Error(w, err.Error(), errF(err))
 				cancel()
@@ -62,25 +142,27 @@ func CustomEndpointHandlerWithHTTPError(rb RequestBuilder, errF router.ToHTTPErr
 			default:
 			}
 
-			if response == nil {
-				w.Header().Set("Content-Type", "application/json")
-				w.Write(emptyResponse)
-				cancel()
-				return
+			if contract != nil {
+				if err := contract.Response(response); err != nil {
+					http.Error(w, err.Error(), router.DefaultToHTTPError(err))
+					cancel()
+					return
+				}
 			}
-
-			js, err := json.Marshal(response.Data)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				cancel()
-				return
+			if cookiePolicy != nil {
+				response = cookiePolicy.Response(response)
 			}
-
-			if isCacheEnabled && response.IsComplete {
+			if isCacheEnabled && response != nil && response.IsComplete {
 				w.Header().Set("Cache-Control", cacheControlHeaderValue)
 			}
-			w.Header().Set("Content-Type", "application/json")
-			w.Write(js)
+			if isCompletedHeaderEnabled && response != nil {
+				w.Header().Set(router.CompletedHeaderName, strconv.FormatBool(response.IsComplete))
+			}
+			if annotateTimings {
+				response = debugTimings.Annotate(w, r, response)
+			}
+
+			render(w, r, response)
 			cancel()
 		}
 	}
@@ -104,7 +186,7 @@ func NewRequestBuilder(paramExtractor ParamExtractor) RequestBuilder {
 	return func(r *http.Request, queryString, headersToSend []string) *proxy.Request {
 		params := paramExtractor(r)
 		headers := make(map[string][]string, 2+len(headersToSend))
-		headers["X-Forwarded-For"] = []string{r.RemoteAddr}
+		headers["X-Forwarded-For"] = []string{router.ClientIP(r)}
 		headers["User-Agent"] = router.UserAgentHeaderValue
 
 		for _, k := range headersToSend {
@@ -113,16 +195,10 @@ func NewRequestBuilder(paramExtractor ParamExtractor) RequestBuilder {
 			}
 		}
 
-		query := make(map[string][]string, len(queryString))
-		for i := range queryString {
-			if v := r.URL.Query().Get(queryString[i]); v != "" {
-				query[queryString[i]] = []string{v}
-			}
-		}
-
 		return &proxy.Request{
 			Method:  r.Method,
-			Query:   query,
+			Query:   router.QueryParams(r, queryString),
+			Path:    r.URL.Path,
 			Body:    r.Body,
 			Params:  params,
 			Headers: headers,
@@ -3,10 +3,14 @@ package mux
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -39,6 +43,312 @@ func TestEndpointHandler_incomplete(t *testing.T) {
 	time.Sleep(5 * time.Millisecond)
 }
 
+func TestEndpointHandler_completedHeader(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: false,
+			Data:       map[string]interface{}{"foo": "bar"},
+		}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:      "GET",
+		Timeout:     10,
+		ExtraConfig: config.ExtraConfig{proxy.Namespace: map[string]interface{}{"completed_header": true}},
+	}
+
+	server := startMuxServer(EndpointHandler(endpoint, p))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if h := w.Result().Header.Get(router.CompletedHeaderName); h != "false" {
+		t.Errorf("unexpected %s header: %s", router.CompletedHeaderName, h)
+	}
+	if w.Result().StatusCode != http.StatusOK {
+		t.Error("Unexpected status code:", w.Result().StatusCode)
+	}
+}
+
+func TestEndpointHandler_rateLimit(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"foo": "bar"}}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:   "GET",
+		Endpoint: "/_mux_endpoint",
+		Timeout:  10,
+		ExtraConfig: config.ExtraConfig{router.RateLimitNamespace: map[string]interface{}{
+			"max_rate": 0.5,
+			"capacity": 1,
+		}},
+	}
+
+	server := startMuxServer(EndpointHandler(endpoint, p))
+
+	for i, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+
+		if w.Result().StatusCode != expected {
+			t.Errorf("request %d: unexpected status code: %d", i, w.Result().StatusCode)
+		}
+	}
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if h := w.Result().Header.Get("Retry-After"); h != "2" {
+		t.Errorf("unexpected Retry-After header: %s", h)
+	}
+}
+
+func TestEndpointHandler_maxBodySize(t *testing.T) {
+	p := func(_ context.Context, req *proxy.Request) (*proxy.Response, error) {
+		if _, err := ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"foo": "bar"}}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:      "POST",
+		Endpoint:    "/_mux_endpoint",
+		Timeout:     time.Second,
+		MaxBodySize: 8,
+	}
+
+	server := startMuxServer(EndpointHandler(endpoint, p))
+
+	for _, tc := range []struct {
+		body          string
+		contentLength int64
+		expected      int
+	}{
+		{body: "12345678", contentLength: 8, expected: http.StatusOK},
+		{body: "123456789", contentLength: 9, expected: http.StatusRequestEntityTooLarge},
+		{body: "12345678", contentLength: -1, expected: http.StatusOK},
+		{body: strings.Repeat("a", 1024), contentLength: -1, expected: http.StatusRequestEntityTooLarge},
+	} {
+		req, _ := http.NewRequest("POST", "http://127.0.0.1:8081/_mux_endpoint", strings.NewReader(tc.body))
+		req.ContentLength = tc.contentLength
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+
+		if w.Result().StatusCode != tc.expected {
+			t.Errorf("%d bytes: unexpected status code: %d", len(tc.body), w.Result().StatusCode)
+		}
+	}
+}
+
+func TestEndpointHandler_badRateLimit(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		t.Error("the proxy should not be called")
+		return nil, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:  "GET",
+		Timeout: 10,
+		ExtraConfig: config.ExtraConfig{router.RateLimitNamespace: map[string]interface{}{
+			"max_rate": 10,
+			"store":    "unknown",
+		}},
+	}
+
+	server := startMuxServer(EndpointHandler(endpoint, p))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if w.Result().StatusCode != http.StatusInternalServerError {
+		t.Error("Unexpected status code:", w.Result().StatusCode)
+	}
+}
+
+func TestEndpointHandler_clientCertificate(t *testing.T) {
+	p := func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"client": r.Headers["X-Client-Cn"][0]}}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:        "GET",
+		Endpoint:      "/_mux_endpoint",
+		Timeout:       10,
+		HeadersToPass: []string{"X-Client-Cn"},
+		ExtraConfig: config.ExtraConfig{router.ClientCertificateNamespace: map[string]interface{}{
+			"required": true,
+			"headers":  map[string]interface{}{"X-Client-CN": "common_name"},
+		}},
+	}
+
+	server := startMuxServer(EndpointHandler(endpoint, p))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	req.Header.Set("X-Client-CN", "spoofed")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusUnauthorized {
+		t.Error("Unexpected status code:", w.Result().StatusCode)
+	}
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}}
+	req, _ = http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Error("Unexpected status code:", w.Result().StatusCode)
+	}
+	if body := w.Body.String(); body != `{"client":"client"}` {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+func TestEndpointHandler_ipFilter(t *testing.T) {
+	calls := 0
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		calls++
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"supu": "tupu"}}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:   "GET",
+		Endpoint: "/_mux_endpoint",
+		Timeout:  10,
+		ExtraConfig: config.ExtraConfig{router.IPFilterNamespace: map[string]interface{}{
+			"allow": []interface{}{"10.0.0.0/8"},
+			"deny":  []interface{}{"10.0.0.1"},
+		}},
+	}
+
+	server := startMuxServer(EndpointHandler(endpoint, p))
+
+	for remote, status := range map[string]int{
+		"10.0.0.2:1234":  http.StatusOK,
+		"10.0.0.1:1234":  http.StatusForbidden,
+		"192.0.2.1:1234": http.StatusForbidden,
+	} {
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Result().StatusCode != status {
+			t.Errorf("%s: unexpected status code: %d", remote, w.Result().StatusCode)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("the rejected requests must not reach the backends: %d calls", calls)
+	}
+}
+
+func TestEndpointHandler_cookies(t *testing.T) {
+	p := func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		if cookie := r.Headers["Cookie"]; len(cookie) != 1 || cookie[0] != "session=abc" {
+			t.Errorf("unexpected cookies: %v", cookie)
+		}
+		return &proxy.Response{
+			IsComplete: true,
+			Metadata:   proxy.Metadata{Headers: map[string][]string{"Set-Cookie": {"session=def; Domain=backend.internal"}}},
+			Io:         strings.NewReader("{}"),
+		}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:         "GET",
+		Endpoint:       "/_mux_endpoint",
+		Timeout:        10 * time.Second,
+		OutputEncoding: "no-op",
+		ExtraConfig: config.ExtraConfig{router.CookiesNamespace: map[string]interface{}{
+			"forward": []interface{}{"session"},
+			"rewrite": map[string]interface{}{"domain": "example.com"},
+		}},
+	}
+
+	server := startMuxServer(EndpointHandler(endpoint, p))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	req.Header.Set("Cookie", "session=abc; tracking=1")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+	if cookie := w.Result().Header.Get("Set-Cookie"); cookie != "session=def; Domain=example.com" {
+		t.Errorf("unexpected Set-Cookie header: %s", cookie)
+	}
+}
+
+func TestEndpointHandler_contract(t *testing.T) {
+	calls := 0
+	p := func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+		calls++
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"id": "a"}}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:   "POST",
+		Endpoint: "/_mux_endpoint",
+		Timeout:  10 * time.Second,
+		ExtraConfig: config.ExtraConfig{router.ContractNamespace: map[string]interface{}{
+			"content_types": []interface{}{"application/json"},
+			"request_schema": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"name"},
+			},
+			"response_schema": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"id": map[string]interface{}{"type": "integer"}},
+			},
+		}},
+	}
+
+	server := startMuxServer(EndpointHandler(endpoint, p))
+
+	for _, tc := range []struct {
+		body   string
+		status int
+		calls  int
+	}{
+		{`{}`, http.StatusBadRequest, 0},
+		{`{"name": "supu"}`, http.StatusBadGateway, 1},
+	} {
+		req, _ := http.NewRequest("POST", "http://127.0.0.1:8081/_mux_endpoint", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Result().StatusCode != tc.status {
+			t.Errorf("%s: unexpected status code: %d", tc.body, w.Result().StatusCode)
+		}
+		if calls != tc.calls {
+			t.Errorf("%s: unexpected calls to the backends: %d", tc.body, calls)
+		}
+	}
+}
+
+func TestEndpointHandler_staticResponse(t *testing.T) {
+	endpoint := &config.EndpointConfig{
+		Method:         "GET",
+		Endpoint:       "/_mux_endpoint",
+		Timeout:        10 * time.Second,
+		OutputEncoding: "no-op",
+		ExtraConfig: config.ExtraConfig{proxy.Namespace: map[string]interface{}{
+			"static_response": map[string]interface{}{"redirect": "/new{{.Path}}", "status_code": 307},
+		}},
+	}
+	p, err := proxy.NewStaticResponseProxy(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := startMuxServer(EndpointHandler(endpoint, p))
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+	if location := w.Result().Header.Get("Location"); location != "/new/_mux_endpoint" {
+		t.Errorf("unexpected location: %s", location)
+	}
+}
+
 func TestEndpointHandler_ko(t *testing.T) {
 	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
 		return nil, fmt.Errorf("This is %s", "a dummy error")
@@ -0,0 +1,151 @@
+package mux
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
+)
+
+// Render defines the signature of the functions to be use for the final response
+// encoding and rendering
+type Render func(http.ResponseWriter, *http.Request, *proxy.Response)
+
+var (
+	renderRegister = map[string]Render{
+		encoding.JSON:    jsonRender,
+		encoding.XML:     encoderRender(encoding.XMLEncoder, "application/xml"),
+		encoding.YAML:    encoderRender(encoding.YAMLEncoder, "application/x-yaml"),
+		encoding.MSGPACK: encoderRender(encoding.MsgPackEncoder, "application/msgpack"),
+		encoding.CBOR:    encoderRender(encoding.CBOREncoder, "application/cbor"),
+		encoding.NDJSON:  ndjsonRender,
+		encoding.NOOP:    noopRender,
+	}
+	emptyResponse = []byte("{}")
+)
+
+// RegisterRender allows clients to register their custom renders
+func RegisterRender(name string, r Render) {
+	renderRegister[name] = r
+}
+
+func getRender(cfg *config.EndpointConfig) Render {
+	if cfg.OutputEncoding != router.NEGOTIATE {
+		return resolveRender(cfg.OutputEncoding, cfg)
+	}
+
+	renders := map[string]Render{}
+	for _, name := range encoding.MediaTypes {
+		renders[name] = resolveRender(name, cfg)
+	}
+	return func(w http.ResponseWriter, r *http.Request, response *proxy.Response) {
+		// the response depends on the Accept header, so the shared caches must not mix the encodings
+		w.Header().Add("Vary", "Accept")
+		render, ok := renders[router.NegotiateEncoding(r.Header.Get("Accept"), encoding.JSON)]
+		if !ok {
+			render = jsonRender
+		}
+		render(w, r, response)
+	}
+}
+
+// resolveRender looks for the render registered with the received name. If there is none, it tries
+// with the encoders registered at the encoding package, configured with the extra config of the endpoint.
+// Unknown names fall back to the JSON render
+func resolveRender(name string, cfg *config.EndpointConfig) Render {
+	if r, ok := renderRegister[name]; ok {
+		return r
+	}
+	if name == encoding.SSE {
+		return sseRender(cfg)
+	}
+	encoder, contentType, err := encoding.GetEncoder(name, cfg.ExtraConfig)
+	if err == encoding.ErrUnknownEncoder {
+		return jsonRender
+	}
+	if err != nil {
+		return errorRender(err)
+	}
+	return encoderRender(encoder, contentType)
+}
+
+func errorRender(err error) Render {
+	return func(w http.ResponseWriter, _ *http.Request, _ *proxy.Response) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func jsonRender(w http.ResponseWriter, r *http.Request, response *proxy.Response) {
+	if response == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(emptyResponse)
+		return
+	}
+	encoderRender(encoding.JSONEncoder, "application/json")(w, r, response)
+}
+
+// ndjsonRender streams the body of the backend response when it was not decoded. Otherwise, it
+// encodes the response data
+func ndjsonRender(w http.ResponseWriter, r *http.Request, response *proxy.Response) {
+	if response == nil || response.Io == nil {
+		encoderRender(encoding.NDJSONEncoder, router.NDJSONContentType)(w, r, response)
+		return
+	}
+	w.Header().Set("Content-Type", router.NDJSONContentType)
+	router.StreamLines(w, response.Io)
+}
+
+// sseRender streams the server-sent events of the backend response when it was not decoded, sending the
+// heartbeats configured for the endpoint. Otherwise, it encodes the response data as events
+func sseRender(cfg *config.EndpointConfig) Render {
+	sseCfg, _ := router.SSEConfigGetter(cfg.ExtraConfig)
+	return func(w http.ResponseWriter, r *http.Request, response *proxy.Response) {
+		if response == nil || response.Io == nil {
+			encoderRender(encoding.SSEEncoder, router.SSEContentType)(w, r, response)
+			return
+		}
+		router.SetSSEHeaders(w.Header())
+		router.StreamEvents(w, response.Io, sseCfg.Heartbeat)
+	}
+}
+
+// noopRender writes the status code, the headers and the body of the backend response untouched
+func noopRender(w http.ResponseWriter, _ *http.Request, response *proxy.Response) {
+	if response == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	for k, vs := range response.Metadata.Headers {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	if response.Metadata.StatusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(response.Metadata.StatusCode)
+	}
+	if response.Io != nil {
+		io.Copy(w, response.Io)
+	}
+}
+
+func encoderRender(encoder encoding.Encoder, contentType string) Render {
+	return func(w http.ResponseWriter, _ *http.Request, response *proxy.Response) {
+		data := map[string]interface{}{}
+		if response != nil {
+			data = response.Data
+		}
+		buf := new(bytes.Buffer)
+		if err := encoder(buf, data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(buf.Bytes())
+	}
+}
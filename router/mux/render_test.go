@@ -0,0 +1,233 @@
+package mux
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/encoding"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestRender_outputEncoding(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{"supu": "tupu"},
+		}, nil
+	}
+	for _, tc := range []struct {
+		encoding, accept, contentType, body string
+	}{
+		{"", "", "application/json", `{"supu":"tupu"}`},
+		{"json", "application/xml", "application/json", `{"supu":"tupu"}`},
+		{"xml", "", "application/xml", xml.Header + "<response><supu>tupu</supu></response>"},
+		{"yaml", "", "application/x-yaml", "\"supu\": \"tupu\"\n"},
+		{"negotiate", "", "application/json", `{"supu":"tupu"}`},
+		{"negotiate", "text/html, text/xml;q=0.9", "application/xml", xml.Header + "<response><supu>tupu</supu></response>"},
+		{"negotiate", "application/x-yaml", "application/x-yaml", "\"supu\": \"tupu\"\n"},
+		{"msgpack", "", "application/msgpack", "\x81\xa4supu\xa4tupu"},
+		{"negotiate", "application/cbor", "application/cbor", "\xa1\x64supu\x64tupu"},
+		{"ndjson", "", "application/x-ndjson", "{\"supu\":\"tupu\"}\n"},
+	} {
+		endpoint := &config.EndpointConfig{
+			Method:         "GET",
+			Timeout:        10,
+			OutputEncoding: tc.encoding,
+		}
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+		req.Header.Set("Accept", tc.accept)
+		w := httptest.NewRecorder()
+		EndpointHandler(endpoint, p).ServeHTTP(w, req)
+
+		body, _ := ioutil.ReadAll(w.Result().Body)
+		if content := w.Result().Header.Get("Content-Type"); content != tc.contentType {
+			t.Errorf("%s (%s): unexpected content type: %s", tc.encoding, tc.accept, content)
+		}
+		if string(body) != tc.body {
+			t.Errorf("%s (%s): unexpected body: %s", tc.encoding, tc.accept, string(body))
+		}
+		vary := w.Result().Header.Values("Vary")
+		if tc.encoding == "negotiate" && (len(vary) != 1 || vary[0] != "Accept") {
+			t.Errorf("%s (%s): unexpected vary header: %v", tc.encoding, tc.accept, vary)
+		}
+		if tc.encoding != "negotiate" && len(vary) != 0 {
+			t.Errorf("%s (%s): unexpected vary header: %v", tc.encoding, tc.accept, vary)
+		}
+	}
+}
+
+func TestRegisterRender(t *testing.T) {
+	RegisterRender("custom", func(w http.ResponseWriter, _ *http.Request, _ *proxy.Response) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("custom"))
+	})
+	endpoint := &config.EndpointConfig{
+		Method:         "GET",
+		Timeout:        10,
+		OutputEncoding: "custom",
+	}
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	w := httptest.NewRecorder()
+	EndpointHandler(endpoint, proxy.NoopProxy).ServeHTTP(w, req)
+
+	body, _ := ioutil.ReadAll(w.Result().Body)
+	if string(body) != "custom" {
+		t.Errorf("unexpected body: %s", string(body))
+	}
+}
+
+func TestRender_registeredEncoder(t *testing.T) {
+	encoding.RegisterEncoder("custom_encoder", "text/custom", func(extra map[string]interface{}) (encoding.Encoder, error) {
+		prefix, ok := extra["prefix"].(string)
+		if !ok {
+			return nil, errors.New("prefix required")
+		}
+		return func(w io.Writer, v interface{}) error {
+			_, err := fmt.Fprintf(w, "%s%v", prefix, v)
+			return err
+		}, nil
+	})
+
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"supu": "tupu"}}, nil
+	}
+	for _, tc := range []struct {
+		extra       map[string]interface{}
+		status      int
+		contentType string
+		body        string
+	}{
+		{map[string]interface{}{"prefix": "> "}, http.StatusOK, "text/custom", "> map[supu:tupu]"},
+		{map[string]interface{}{}, http.StatusInternalServerError, "text/plain; charset=utf-8", "prefix required\n"},
+	} {
+		endpoint := &config.EndpointConfig{
+			Method:         "GET",
+			Timeout:        10,
+			OutputEncoding: "custom_encoder",
+			ExtraConfig:    tc.extra,
+		}
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+		w := httptest.NewRecorder()
+		EndpointHandler(endpoint, p).ServeHTTP(w, req)
+
+		body, _ := ioutil.ReadAll(w.Result().Body)
+		if w.Result().StatusCode != tc.status {
+			t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+		}
+		if content := w.Result().Header.Get("Content-Type"); content != tc.contentType {
+			t.Errorf("unexpected content type: %s", content)
+		}
+		if string(body) != tc.body {
+			t.Errorf("unexpected body: %s", string(body))
+		}
+	}
+}
+
+func TestRender_ndjsonStream(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{},
+			Io:         strings.NewReader("{\"id\":1}\n{\"id\":2}\n"),
+		}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:         "GET",
+		Timeout:        10,
+		OutputEncoding: "ndjson",
+	}
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	w := httptest.NewRecorder()
+	EndpointHandler(endpoint, p).ServeHTTP(w, req)
+
+	body, _ := ioutil.ReadAll(w.Result().Body)
+	if content := w.Result().Header.Get("Content-Type"); content != "application/x-ndjson" {
+		t.Errorf("unexpected content type: %s", content)
+	}
+	if string(body) != "{\"id\":1}\n{\"id\":2}\n" {
+		t.Errorf("unexpected body: %s", string(body))
+	}
+}
+
+func TestRender_sseStream(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: true,
+			Data:       map[string]interface{}{},
+			Io:         strings.NewReader("event: update\ndata: 1\n\n"),
+		}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:         "GET",
+		Timeout:        10,
+		OutputEncoding: encoding.SSE,
+	}
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	w := httptest.NewRecorder()
+	EndpointHandler(endpoint, p).ServeHTTP(w, req)
+
+	body, _ := ioutil.ReadAll(w.Result().Body)
+	if content := w.Result().Header.Get("Content-Type"); content != "text/event-stream" {
+		t.Errorf("unexpected content type: %s", content)
+	}
+	if cache := w.Result().Header.Get("Cache-Control"); cache != "no-cache" {
+		t.Errorf("unexpected cache control: %s", cache)
+	}
+	if !w.Flushed || string(body) != "event: update\ndata: 1\n\n" {
+		t.Errorf("unexpected body: %s", string(body))
+	}
+
+	p = func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"supu": "tupu"}}, nil
+	}
+	w = httptest.NewRecorder()
+	EndpointHandler(endpoint, p).ServeHTTP(w, req)
+	body, _ = ioutil.ReadAll(w.Result().Body)
+	if string(body) != "data: {\"supu\":\"tupu\"}\n\n" {
+		t.Errorf("unexpected body: %s", string(body))
+	}
+}
+
+func TestRender_noop(t *testing.T) {
+	p := func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+		return &proxy.Response{
+			IsComplete: true,
+			Io:         strings.NewReader("supu"),
+			Metadata: proxy.Metadata{
+				StatusCode: http.StatusTeapot,
+				Headers:    map[string][]string{"Content-Type": {"application/octet-stream"}, "X-Supu": {"a", "b"}},
+			},
+		}, nil
+	}
+	endpoint := &config.EndpointConfig{
+		Method:         "GET",
+		Timeout:        10,
+		OutputEncoding: "no-op",
+	}
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8081/_mux_endpoint", nil)
+	w := httptest.NewRecorder()
+	EndpointHandler(endpoint, p).ServeHTTP(w, req)
+
+	body, _ := ioutil.ReadAll(w.Result().Body)
+	if w.Result().StatusCode != http.StatusTeapot {
+		t.Errorf("unexpected status code: %d", w.Result().StatusCode)
+	}
+	if content := w.Result().Header.Get("Content-Type"); content != "application/octet-stream" {
+		t.Errorf("unexpected content type: %s", content)
+	}
+	if h := w.Result().Header["X-Supu"]; len(h) != 2 {
+		t.Errorf("unexpected header: %v", h)
+	}
+	if string(body) != "supu" {
+		t.Errorf("unexpected body: %s", string(body))
+	}
+}
@@ -5,11 +5,21 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/devopsfaith/krakend/audit"
 	"github.com/devopsfaith/krakend/config"
 	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/metrics"
 	"github.com/devopsfaith/krakend/proxy"
 	"github.com/devopsfaith/krakend/router"
+	"github.com/devopsfaith/krakend/router/acme"
+	"github.com/devopsfaith/krakend/router/graphql"
+	"github.com/devopsfaith/krakend/router/http2"
+	"github.com/devopsfaith/krakend/router/http3"
 )
 
 // DefaultDebugPattern is the default pattern used to define the debug endpoint
@@ -34,6 +44,12 @@ type Config struct {
 	ProxyFactory   proxy.Factory
 	Logger         logging.Logger
 	DebugPattern   string
+	// EngineFactory creates the engines for the endpoints of the reloaded configurations and of the
+	// listeners of the service. By default, the DefaultEngine
+	EngineFactory func() Engine
+	// ListenerMiddlewares are the middlewares of the listeners of the service, by name. The Middlewares
+	// are only applied to the endpoints served on the port of the service
+	ListenerMiddlewares map[string][]HandlerMiddleware
 }
 
 // HandlerMiddleware is the interface for the decorators over the http.Handler
@@ -49,7 +65,7 @@ func DefaultFactory(pf proxy.Factory, logger logging.Logger) router.Factory {
 			Middlewares:    []HandlerMiddleware{},
 			HandlerFactory: EndpointHandler,
 			ProxyFactory:   pf,
-			Logger:         logger,
+			Logger:         logging.Module(logger, logging.ModuleRouter),
 			DebugPattern:   DefaultDebugPattern,
 		},
 	}
@@ -69,65 +85,342 @@ type factory struct {
 
 // New implements the factory interface
 func (rf factory) New() router.Router {
-	return httpRouter{rf.cfg, context.Background()}
+	return httpRouter{rf.cfg, context.Background(), router.NewLifecycle()}
 }
 
 // NewWithContext implements the factory interface
 func (rf factory) NewWithContext(ctx context.Context) router.Router {
-	return httpRouter{rf.cfg, ctx}
+	return httpRouter{rf.cfg, ctx, router.NewLifecycle()}
 }
 
 type httpRouter struct {
-	cfg Config
-	ctx context.Context
+	cfg       Config
+	ctx       context.Context
+	lifecycle *router.Lifecycle
 }
 
 // Run implements the router interface
 func (r httpRouter) Run(cfg config.ServiceConfig) {
-	if cfg.Debug {
-		r.cfg.Engine.Handle(r.cfg.DebugPattern, DebugHandler(r.cfg.Logger))
-	}
+	r.RunWithUpdates(cfg, nil)
+}
 
+// RunWithUpdates implements the router.UpdatableRouter interface. The endpoints of every received
+// configuration are registered in a new engine and swapped atomically with the current ones, letting
+// the requests in flight finish with the previous endpoints. The server settings (port, timeouts,
+// listeners...) are not updated
+func (r httpRouter) RunWithUpdates(cfg config.ServiceConfig, updates <-chan config.ServiceConfig) {
 	http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	r.lifecycle.ConfigLoaded()
+
+	// the first listener is the port of the service
+	listeners := []*listener{}
+	servers := []*http.Server{}
+	for _, name := range listenerNames(cfg) {
+		engine := r.cfg.Engine
+		if name != "" {
+			engine = r.newEngine()
+		}
+		l, err := r.newListener(engine, cfg, name)
+		if err != nil {
+			r.cfg.Logger.Critical("enabling TLS:", err.Error())
+			return
+		}
+		listeners = append(listeners, l)
+		servers = append(servers, l.server)
+	}
+
+	challenges, err := acme.ConfigureServer(servers[0], cfg)
+	if err != nil {
+		r.cfg.Logger.Critical("enabling ACME:", err.Error())
+		return
+	}
+	h3, err := http3.NewServer(servers[0], cfg)
+	if err != nil {
+		r.cfg.Logger.Error("enabling HTTP/3:", err.Error())
+	}
+	for _, s := range servers {
+		if err := http2.ConfigureServer(s, cfg); err != nil {
+			r.cfg.Logger.Error("enabling HTTP/2:", err.Error())
+		}
+	}
+
+	for _, s := range servers {
+		go func(s *http.Server) {
+			r.cfg.Logger.Critical(router.ListenAndServe(s, cfg))
+		}(s)
+	}
+	if h3 != nil {
+		go func() {
+			r.cfg.Logger.Critical(h3.ListenAndServe())
+		}()
+	}
+	if challenges != nil {
+		go func() {
+			r.cfg.Logger.Critical(router.ListenAndServe(challenges, cfg))
+		}()
+	}
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			// the HTTP/3 listener keeps serving its requests in flight while the rest of them are drained
+			r.lifecycle.Shutdown(cfg, r.cfg.Logger, append(servers, challenges)...)
+			if h3 != nil {
+				h3.Close()
+			}
+			r.cfg.Logger.Info("Router execution ended")
+			return
+		case newCfg := <-updates:
+			// the backends removed by the new configuration must not fail the readiness check
+			proxy.ResetBackendsHealth()
+			for _, l := range listeners {
+				previous := l.current.Load().(*endpointTable)
+				l.current.Store(r.newEndpointTable(r.newEngine(), newCfg, l.name))
+				go func() {
+					previous.drain()
+					r.cfg.Logger.Debug("The previous endpoints have been drained")
+				}()
+			}
+			r.lifecycle.ConfigLoaded()
+			audit.Record(audit.Event{
+				Type:    audit.EventConfigReload,
+				Outcome: audit.OutcomeSuccess,
+				Details: map[string]interface{}{"endpoints": len(newCfg.Endpoints)},
+			})
+			r.cfg.Logger.Info("Endpoints updated")
+		}
+	}
+}
+
+// listener is a server of the router, serving the endpoints bound to one of the listeners of the service
+type listener struct {
+	name    string
+	server  *http.Server
+	current *atomic.Value
+}
+
+// listenerNames returns the names of the listeners of the service, starting with the empty one of its port
+func listenerNames(cfg config.ServiceConfig) []string {
+	names := []string{""}
+	for _, l := range cfg.Listeners {
+		names = append(names, l.Name)
+	}
+	return names
+}
 
-	r.registerKrakendEndpoints(cfg.Endpoints)
+// newListener returns the server of the listener with the name, serving its endpoints with the engine
+func (r httpRouter) newListener(engine Engine, cfg config.ServiceConfig, name string) (*listener, error) {
+	current := &atomic.Value{}
+	current.Store(r.newEndpointTable(engine, cfg, name))
 
-	server := http.Server{
-		Addr:              fmt.Sprintf(":%d", cfg.Port),
-		Handler:           r.handler(),
+	lCfg := cfg.ListenerConfig(name)
+	server := &http.Server{
+		Addr: fmt.Sprintf(":%d", lCfg.Port),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			current.Load().(*endpointTable).ServeHTTP(w, req)
+		}),
 		ReadTimeout:       cfg.ReadTimeout,
 		WriteTimeout:      cfg.WriteTimeout,
 		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
 		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		BaseContext:       r.lifecycle.BaseContext,
+	}
+	if err := router.ConfigureTLS(r.ctx, server, lCfg, r.cfg.Logger); err != nil {
+		return nil, err
+	}
+	return &listener{name: name, server: server, current: current}, nil
+}
+
+func (r httpRouter) newEngine() Engine {
+	if r.cfg.EngineFactory != nil {
+		return r.cfg.EngineFactory()
+	}
+	return DefaultEngine()
+}
+
+// newEndpointTable registers the endpoints of the listener with the name in the engine. The debug, the
+// OpenAPI, the captures, the metrics and the GraphQL endpoints and the static directories are only registered
+// on the port of the service
+func (r httpRouter) newEndpointTable(engine Engine, cfg config.ServiceConfig, name string) *endpointTable {
+	r.cfg.Engine = engine
+	r.cfg.Engine.Handle(router.ReadinessPattern, http.HandlerFunc(r.lifecycle.ReadinessHandler))
+	r.cfg.Engine.Handle(router.HealthPattern, http.HandlerFunc(r.lifecycle.HealthHandler))
+	r.cfg.Engine.Handle(router.LivenessPattern, http.HandlerFunc(router.LivenessHandler))
+	if name != "" {
+		r.cfg.Middlewares = r.cfg.ListenerMiddlewares[name]
+		r.registerKrakendEndpoints(cfg.ListenerConfig(name))
+		return &endpointTable{handler: r.serviceHandler(cfg)}
+	}
+
+	if cfg.Debug {
+		r.cfg.Engine.Handle(r.cfg.DebugPattern, DebugHandler(r.cfg.Logger))
+		r.cfg.Engine.Handle(router.HostStatsPattern, http.HandlerFunc(router.HostStatsHandler))
+	}
+	if oc, ok := router.OpenAPIConfigGetter(cfg.ExtraConfig); ok {
+		r.cfg.Engine.Handle(oc.Path, router.OpenAPIHandler(cfg, oc))
+	}
+	if cc, ok, err := router.CaptureConfigGetter(cfg.ExtraConfig); err != nil {
+		r.cfg.Logger.Error("parsing the debug capture options:", err.Error())
+	} else if ok && cc.Secret == "" {
+		r.cfg.Logger.Error("parsing the debug capture options:", router.ErrNoCaptureSecret.Error())
+	} else if ok {
+		h := router.CapturesHandler(cc)
+		r.cfg.Engine.Handle(router.CapturesPattern, h)
+		r.cfg.Engine.Handle(router.CapturesPattern+"/", h)
+	}
+	if mc, ok, err := metrics.ConfigGetter(cfg.ExtraConfig); err != nil {
+		r.cfg.Logger.Error("parsing the metrics options:", err.Error())
+	} else if ok && !mc.DisableEndpoint {
+		r.cfg.Engine.Handle(mc.Path, metrics.Handler(metrics.DefaultRegistry))
+	}
+	if gc, ok := graphql.ConfigGetter(cfg.ExtraConfig); ok {
+		if h, err := graphql.NewHandler(cfg, r.cfg.ProxyFactory); err != nil {
+			r.cfg.Logger.Error("creating the graphql handler", err.Error())
+		} else {
+			r.cfg.Engine.Handle(gc.Path, h)
+		}
+	}
+	r.registerStaticDirectories(cfg)
+	r.registerKrakendEndpoints(cfg.ListenerConfig(""))
+	return &endpointTable{handler: r.serviceHandler(cfg)}
+}
+
+// registerStaticDirectories registers the static directories of the service on their prefixes, so the
+// endpoints with a longer path take precedence over them
+func (r httpRouter) registerStaticDirectories(cfg config.ServiceConfig) {
+	cfgs, _, err := router.StaticConfigGetter(cfg.ExtraConfig)
+	if err != nil {
+		r.cfg.Logger.Error("parsing the static directories:", err.Error())
+		return
+	}
+	for _, c := range cfgs {
+		h, err := router.NewStaticHandler(c)
+		if err != nil {
+			r.cfg.Logger.Error("serving the static directory", c.Directory, err.Error())
+			continue
+		}
+		r.cfg.Engine.Handle(c.Prefix, h)
 	}
+}
 
-	go func() {
-		r.cfg.Logger.Critical(server.ListenAndServe())
-	}()
+// serviceHandler decorates the handler of the router with the compression, the access log, the metrics, the
+// request IDs, the tracing and the client IP resolution of the service
+func (r httpRouter) serviceHandler(cfg config.ServiceConfig) http.Handler {
+	handler, err := router.AccessLogHandler(cfg.ExtraConfig, router.CompressionHandler(cfg.ExtraConfig, r.handler()))
+	if err != nil {
+		r.cfg.Logger.Error("enabling the access log:", err.Error())
+	}
+	handler, err = router.RequestIDHandler(cfg.ExtraConfig, router.MetricsHandler(handler))
+	if err != nil {
+		r.cfg.Logger.Error("enabling the request IDs:", err.Error())
+	}
+	handler, err = router.ClientIPHandler(cfg.ExtraConfig, router.TracingHandler(handler))
+	if err != nil {
+		r.cfg.Logger.Error("resolving the client IPs:", err.Error())
+	}
+	return handler
+}
+
+// endpointTable is a handler counting its requests in flight, so it can be drained once replaced
+type endpointTable struct {
+	handler  http.Handler
+	inFlight int64
+}
 
-	<-r.ctx.Done()
-	if err := server.Shutdown(context.Background()); err != nil {
-		r.cfg.Logger.Error(err.Error())
+// ServeHTTP implements the http.Handler interface
+func (t *endpointTable) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	atomic.AddInt64(&t.inFlight, 1)
+	defer atomic.AddInt64(&t.inFlight, -1)
+	t.handler.ServeHTTP(w, req)
+}
+
+// drain waits until all the requests in flight are completed
+func (t *endpointTable) drain() {
+	for atomic.LoadInt64(&t.inFlight) > 0 {
+		time.Sleep(drainInterval)
 	}
-	r.cfg.Logger.Info("Router execution ended")
 }
 
-func (r httpRouter) registerKrakendEndpoints(endpoints []*config.EndpointConfig) {
-	for _, c := range endpoints {
+var drainInterval = 10 * time.Millisecond
+
+func (r httpRouter) registerKrakendEndpoints(cfg config.ServiceConfig) {
+	// the endpoints sharing the path with different methods are registered as a single handler
+	paths := []string{}
+	handlers := map[string]map[string]http.Handler{}
+	endpoints := map[string][]*config.EndpointConfig{}
+	for _, c := range cfg.Endpoints {
 		proxyStack, err := r.cfg.ProxyFactory.New(c)
 		if err != nil {
 			r.cfg.Logger.Error("calling the ProxyFactory", err.Error())
 			continue
 		}
 
-		r.registerKrakendEndpoint(c.Method, c.Endpoint, r.cfg.HandlerFactory(c, proxyStack), len(c.Backend))
+		// the endpoint handlers can only report the invalid rate limits when they are requested
+		if _, _, err := router.RateLimitConfigGetter(c.ExtraConfig); err != nil {
+			r.cfg.Logger.Error("rate limiting the requests of", c.Endpoint, err.Error())
+			continue
+		}
+
+		path := c.Endpoint
+		if c.Wildcard != "" {
+			// the patterns ending with a slash match the whole subtree of the http.ServeMux
+			path = strings.TrimSuffix(path, "*"+c.Wildcard)
+		}
+		if !r.isValidEndpoint(c.Method, path, len(c.Backend)) {
+			continue
+		}
+		if _, ok := handlers[path]; !ok {
+			paths = append(paths, path)
+			handlers[path] = map[string]http.Handler{}
+		}
+		var handler http.Handler = r.cfg.HandlerFactory(c, proxyStack)
+		if wsCfg, ok := router.WebSocketConfigGetter(c.ExtraConfig); ok {
+			handler = router.WebSocketHandler(c, wsCfg, nil, handler)
+		}
+		rh, err := router.NewEndpointResponseHeaders(cfg.ExtraConfig, c)
+		if err != nil {
+			r.cfg.Logger.Error("transforming the response headers of", c.Endpoint, err.Error())
+			continue
+		}
+		if rh != nil {
+			handler = router.ResponseHeadersHandler(rh, handler)
+		}
+		capturer, err := router.NewCapturer(cfg.ExtraConfig, c)
+		if err != nil {
+			r.cfg.Logger.Error("capturing the requests of", c.Endpoint, err.Error())
+			continue
+		}
+		if capturer != nil {
+			handler = router.CaptureHandler(capturer, handler)
+		}
+		handlers[path][c.Method] = router.MatchedEndpointHandler(c.Endpoint, handler)
+		endpoints[path] = append(endpoints[path], c)
+	}
+
+	for _, path := range paths {
+		var handler http.Handler
+		for method, h := range handlers[path] {
+			r.cfg.Logger.Debug("registering the endpoint", method, path)
+			handler = h
+		}
+		if len(handlers[path]) > 1 {
+			handler = methodHandler(handlers[path])
+		}
+		// the preflight requests are answered before dispatching the methods
+		if cors, err := router.NewEndpointCORS(cfg.ExtraConfig, endpoints[path]); err != nil {
+			r.cfg.Logger.Error("enabling CORS for", path, err.Error())
+		} else if cors != nil {
+			handler = cors.Handler(handler)
+		}
+		r.cfg.Engine.Handle(path, handler)
 	}
 }
 
-func (r httpRouter) registerKrakendEndpoint(method, path string, handler http.HandlerFunc, totBackends int) {
+func (r httpRouter) isValidEndpoint(method, path string, totBackends int) bool {
 	if method != "GET" && totBackends > 1 {
 		r.cfg.Logger.Error(method, "endpoints must have a single backend! Ignoring", path)
-		return
+		return false
 	}
 
 	switch method {
@@ -138,10 +431,29 @@ func (r httpRouter) registerKrakendEndpoint(method, path string, handler http.Ha
 	case "DELETE":
 	default:
 		r.cfg.Logger.Error("Unsupported method", method)
-		return
+		return false
 	}
-	r.cfg.Logger.Debug("registering the endpoint", method, path)
-	r.cfg.Engine.Handle(path, handler)
+	return true
+}
+
+// methodHandler dispatches the requests to the handler of their method
+func methodHandler(handlers map[string]http.Handler) http.Handler {
+	allowed := make([]string, 0, len(handlers))
+	for method := range handlers {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	allow := strings.Join(allowed, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		handler, ok := handlers[req.Method]
+		if !ok {
+			w.Header().Set("Allow", allow)
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
 }
 
 func (r httpRouter) handler() http.Handler {
@@ -3,15 +3,26 @@ package mux
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	xhttp2 "golang.org/x/net/http2"
+
 	"github.com/devopsfaith/krakend/config"
 	"github.com/devopsfaith/krakend/logging"
 	"github.com/devopsfaith/krakend/proxy"
+	"github.com/devopsfaith/krakend/router"
+	"github.com/devopsfaith/krakend/router/graphql"
+	"github.com/devopsfaith/krakend/router/http2"
 )
 
 func TestDefaultFactory_ok(t *testing.T) {
@@ -140,6 +151,11 @@ func TestDefaultFactory_ko(t *testing.T) {
 	serviceCfg := config.ServiceConfig{
 		Debug: true,
 		Port:  8063,
+		ExtraConfig: config.ExtraConfig{
+			router.OpenAPINamespace: map[string]interface{}{"path": "/__api"},
+			graphql.Namespace:       map[string]interface{}{},
+			http2.Namespace:         map[string]interface{}{},
+		},
 		Endpoints: []*config.EndpointConfig{
 			{
 				Endpoint: "/ignored",
@@ -177,6 +193,52 @@ func TestDefaultFactory_ko(t *testing.T) {
 		req.Header.Set("Content-Type", "application/json")
 		checkResponseIs404(t, req)
 	}
+
+	resp, err := http.Get("http://127.0.0.1:8063" + router.HostStatsPattern)
+	if err != nil {
+		t.Error("requesting the host stats:", err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code for the host stats: %d", resp.StatusCode)
+	}
+
+	h2cClient := &http.Client{Transport: &xhttp2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err = h2cClient.Get("http://127.0.0.1:8063" + router.HostStatsPattern)
+	if err != nil {
+		t.Error("requesting the host stats with h2c:", err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Errorf("unexpected response for the h2c request: %d %s", resp.StatusCode, resp.Proto)
+	}
+
+	resp, err = http.Get("http://127.0.0.1:8063/__api")
+	if err != nil {
+		t.Error("requesting the openapi document:", err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code for the openapi document: %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post("http://127.0.0.1:8063"+graphql.DefaultPath, "application/graphql", strings.NewReader("{ __typename }"))
+	if err != nil {
+		t.Error("requesting the graphql endpoint:", err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code for the graphql endpoint: %d", resp.StatusCode)
+	}
 }
 
 func TestDefaultFactory_proxyFactoryCrash(t *testing.T) {
@@ -221,6 +283,159 @@ func TestDefaultFactory_proxyFactoryCrash(t *testing.T) {
 	}
 }
 
+func TestDefaultFactory_updates(t *testing.T) {
+	buff := bytes.NewBuffer(make([]byte, 1024))
+	logger, err := logging.NewLogger("ERROR", buff, "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		time.Sleep(5 * time.Millisecond)
+	}()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	pf := proxy.FactoryFunc(func(cfg *config.EndpointConfig) (proxy.Proxy, error) {
+		return func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+			if cfg.Endpoint == "/slow" {
+				close(entered)
+				<-release
+			}
+			return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"endpoint": cfg.Endpoint}}, nil
+		}, nil
+	})
+	r, ok := DefaultFactory(pf, logger).NewWithContext(ctx).(router.UpdatableRouter)
+	if !ok {
+		t.Error("the router does not accept updates")
+		return
+	}
+
+	endpoint := func(path string) *config.EndpointConfig {
+		return &config.EndpointConfig{Endpoint: path, Method: "GET", Timeout: time.Second, Backend: []*config.Backend{{}}}
+	}
+	updates := make(chan config.ServiceConfig)
+	go r.RunWithUpdates(config.ServiceConfig{
+		Port:      8065,
+		Endpoints: []*config.EndpointConfig{endpoint("/slow"), endpoint("/old")},
+	}, updates)
+	time.Sleep(5 * time.Millisecond)
+
+	slowResponse := make(chan *http.Response)
+	go func() {
+		resp, err := http.Get("http://127.0.0.1:8065/slow")
+		if err != nil {
+			t.Error("requesting the slow endpoint:", err.Error())
+		}
+		slowResponse <- resp
+	}()
+	<-entered
+
+	updates <- config.ServiceConfig{Endpoints: []*config.EndpointConfig{endpoint("/new")}}
+	time.Sleep(5 * time.Millisecond)
+
+	resp, err := http.Get("http://127.0.0.1:8065/new")
+	if err != nil {
+		t.Error("requesting the new endpoint:", err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code for the new endpoint: %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8065/old", nil)
+	checkResponseIs404(t, req)
+
+	// the requests in flight are completed by the previous endpoints
+	close(release)
+	if resp := <-slowResponse; resp != nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status code for the slow endpoint: %d", resp.StatusCode)
+		}
+	}
+}
+
+func TestDefaultFactory_listeners(t *testing.T) {
+	logger, err := logging.NewLogger("ERROR", bytes.NewBuffer(nil), "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		time.Sleep(5 * time.Millisecond)
+	}()
+
+	r := NewFactory(Config{
+		Engine:         DefaultEngine(),
+		HandlerFactory: EndpointHandler,
+		ProxyFactory:   noopProxyFactory{"supu": "tupu"},
+		Logger:         logger,
+		ListenerMiddlewares: map[string][]HandlerMiddleware{
+			"admin": {headerMiddleware{"X-Listener", "admin"}},
+		},
+	}).NewWithContext(ctx)
+
+	endpoint := func(path, listener string) *config.EndpointConfig {
+		return &config.EndpointConfig{
+			Endpoint: path,
+			Method:   "GET",
+			Timeout:  time.Second,
+			Backend:  []*config.Backend{{}},
+			Listener: listener,
+		}
+	}
+	go r.Run(config.ServiceConfig{
+		Port:      8076,
+		Listeners: []*config.Listener{{Name: "admin", Port: 8077}},
+		Endpoints: []*config.EndpointConfig{endpoint("/public", ""), endpoint("/admin", "admin")},
+	})
+	time.Sleep(5 * time.Millisecond)
+
+	for _, tc := range []struct {
+		url      string
+		status   int
+		listener string
+	}{
+		{url: "http://127.0.0.1:8076/public", status: http.StatusOK},
+		{url: "http://127.0.0.1:8076/admin", status: http.StatusNotFound},
+		{url: "http://127.0.0.1:8077/admin", status: http.StatusOK, listener: "admin"},
+		{url: "http://127.0.0.1:8077/public", status: http.StatusNotFound, listener: "admin"},
+		{url: "http://127.0.0.1:8077" + router.ReadinessPattern, status: http.StatusOK, listener: "admin"},
+	} {
+		resp, err := http.Get(tc.url)
+		if err != nil {
+			t.Error("requesting", tc.url, err.Error())
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("unexpected status code for %s: %d", tc.url, resp.StatusCode)
+		}
+		if resp.Header.Get("X-Listener") != tc.listener {
+			t.Errorf("unexpected middlewares for %s: %s", tc.url, resp.Header.Get("X-Listener"))
+		}
+	}
+}
+
+type headerMiddleware struct {
+	name, value string
+}
+
+func (m headerMiddleware) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(m.name, m.value)
+		h.ServeHTTP(w, r)
+	})
+}
+
 func checkResponseIs404(t *testing.T, req *http.Request) {
 	expectedBody := "404 page not found\n"
 	resp, err := http.DefaultClient.Do(req)
@@ -276,3 +491,329 @@ type identityMiddleware struct{}
 func (i identityMiddleware) Handler(h http.Handler) http.Handler {
 	return h
 }
+
+func TestDefaultFactory_wildcard(t *testing.T) {
+	logger, err := logging.NewLogger("ERROR", bytes.NewBuffer(nil), "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	pf := proxy.FactoryFunc(func(cfg *config.EndpointConfig) (proxy.Proxy, error) {
+		return func(_ context.Context, req *proxy.Request) (*proxy.Response, error) {
+			req.GeneratePath(cfg.Backend[0].URLPattern)
+			return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"path": req.Path}}, nil
+		}, nil
+	})
+
+	serviceCfg := config.ServiceConfig{
+		Version: config.ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users/*", Timeout: time.Second, Backend: []*config.Backend{{URLPattern: "/api/users"}}},
+		},
+	}
+	if err := serviceCfg.Init(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	table := DefaultFactory(pf, logger).New().(httpRouter).newEndpointTable(DefaultEngine(), serviceCfg, "")
+
+	for path, expected := range map[string]string{
+		"/users/42/posts":       `{"path":"/api/users/42/posts"}`,
+		"/users/":               `{"path":"/api/users/"}`,
+		router.ReadinessPattern: `{"status":"ready"}`,
+		router.LivenessPattern:  `{"status":"alive"}`,
+	} {
+		w := httptest.NewRecorder()
+		table.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != expected {
+			t.Errorf("%s: unexpected response: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestDefaultFactory_methods(t *testing.T) {
+	logger, err := logging.NewLogger("ERROR", bytes.NewBuffer(nil), "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	pf := proxy.FactoryFunc(func(cfg *config.EndpointConfig) (proxy.Proxy, error) {
+		return func(_ context.Context, req *proxy.Request) (*proxy.Response, error) {
+			req.GeneratePath(cfg.Backend[0].URLPattern)
+			return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"path": req.Path}}, nil
+		}, nil
+	})
+
+	serviceCfg := config.ServiceConfig{
+		Version: config.ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/supu", Method: config.AnyMethod, Timeout: time.Second, Backend: []*config.Backend{{URLPattern: "/api/{method}"}}},
+		},
+	}
+	if err := serviceCfg.Init(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	table := DefaultFactory(pf, logger).New().(httpRouter).newEndpointTable(DefaultEngine(), serviceCfg, "")
+
+	for _, method := range []string{"GET", "POST", "DELETE"} {
+		w := httptest.NewRecorder()
+		table.ServeHTTP(w, httptest.NewRequest(method, "/supu", nil))
+		if expected := fmt.Sprintf(`{"path":"/api/%s"}`, strings.ToLower(method)); w.Code != http.StatusOK || w.Body.String() != expected {
+			t.Errorf("%s: unexpected response: %d %s", method, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	table.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/supu", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "DELETE, GET, PATCH, POST, PUT" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestDefaultFactory_cors(t *testing.T) {
+	logger, err := logging.NewLogger("ERROR", bytes.NewBuffer(nil), "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	serviceCfg := config.ServiceConfig{
+		Version:     config.ConfigVersion,
+		Host:        []string{"http://127.0.0.1:8080"},
+		ExtraConfig: config.ExtraConfig{router.CORSNamespace: map[string]interface{}{"allow_origins": []string{"https://example.com"}}},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/supu", Method: "GET", Timeout: time.Second, Backend: []*config.Backend{{URLPattern: "/"}}},
+			{Endpoint: "/supu", Method: "POST", Timeout: time.Second, Backend: []*config.Backend{{URLPattern: "/"}}},
+		},
+	}
+	if err := serviceCfg.Init(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	table := DefaultFactory(noopProxyFactory{"supu": "tupu"}, logger).New().(httpRouter).newEndpointTable(DefaultEngine(), serviceCfg, "")
+
+	req := httptest.NewRequest("OPTIONS", "/supu", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	table.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Methods") != "GET, POST" {
+		t.Errorf("unexpected preflight response: %d %s", w.Code, w.Header().Get("Access-Control-Allow-Methods"))
+	}
+
+	req = httptest.NewRequest("GET", "/supu", nil)
+	req.Header.Set("Origin", "https://example.com")
+	w = httptest.NewRecorder()
+	table.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestDefaultFactory_responseHeaders(t *testing.T) {
+	logger, err := logging.NewLogger("ERROR", bytes.NewBuffer(nil), "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	serviceCfg := config.ServiceConfig{
+		Version:     config.ConfigVersion,
+		Host:        []string{"http://127.0.0.1:8080"},
+		ExtraConfig: config.ExtraConfig{router.ResponseHeadersNamespace: map[string]interface{}{"security_headers": map[string]interface{}{}}},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/supu", Method: "GET", Timeout: time.Second, Backend: []*config.Backend{{URLPattern: "/"}}},
+			{
+				Endpoint: "/tupu",
+				Method:   "GET",
+				Timeout:  time.Second,
+				Backend:  []*config.Backend{{URLPattern: "/"}},
+				ExtraConfig: config.ExtraConfig{router.ResponseHeadersNamespace: map[string]interface{}{
+					"rules": []interface{}{map[string]interface{}{"action": "set", "name": "X-Supu", "value": "tupu"}},
+				}},
+			},
+		},
+	}
+	if err := serviceCfg.Init(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	table := DefaultFactory(noopProxyFactory{"supu": "tupu"}, logger).New().(httpRouter).newEndpointTable(DefaultEngine(), serviceCfg, "")
+
+	w := httptest.NewRecorder()
+	table.ServeHTTP(w, httptest.NewRequest("GET", "/supu", nil))
+	if w.Code != http.StatusOK || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	table.ServeHTTP(w, httptest.NewRequest("GET", "/tupu", nil))
+	if w.Code != http.StatusOK || w.Header().Get("X-Supu") != "tupu" || w.Header().Get("X-Content-Type-Options") != "" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestDefaultFactory_badRateLimit(t *testing.T) {
+	buff := bytes.NewBuffer(make([]byte, 1024))
+	logger, err := logging.NewLogger("ERROR", buff, "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	serviceCfg := config.ServiceConfig{
+		Version: config.ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/supu", Method: "GET", Timeout: time.Second, Backend: []*config.Backend{{URLPattern: "/"}}},
+			{
+				Endpoint: "/tupu",
+				Method:   "GET",
+				Timeout:  time.Second,
+				Backend:  []*config.Backend{{URLPattern: "/"}},
+				ExtraConfig: config.ExtraConfig{router.RateLimitNamespace: map[string]interface{}{
+					"max_rate": 10,
+					"store":    "unknown",
+				}},
+			},
+		},
+	}
+	if err := serviceCfg.Init(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	table := DefaultFactory(noopProxyFactory{"supu": "tupu"}, logger).New().(httpRouter).newEndpointTable(DefaultEngine(), serviceCfg, "")
+
+	w := httptest.NewRecorder()
+	table.ServeHTTP(w, httptest.NewRequest("GET", "/supu", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	table.ServeHTTP(w, httptest.NewRequest("GET", "/tupu", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("the endpoint with an invalid rate limit must not be registered: %d", w.Code)
+	}
+	if !strings.Contains(buff.String(), "rate limiting the requests of /tupu "+router.ErrUnknownRateLimitStore.Error()) {
+		t.Errorf("the error was not logged: %s", buff.String())
+	}
+}
+
+func TestDefaultFactory_static(t *testing.T) {
+	logger, err := logging.NewLogger("ERROR", bytes.NewBuffer(nil), "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+	dir, err := ioutil.TempDir("", "krakend_static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>app</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	serviceCfg := config.ServiceConfig{
+		Version: config.ConfigVersion,
+		Host:    []string{"http://127.0.0.1:8080"},
+		ExtraConfig: config.ExtraConfig{router.StaticNamespace: []interface{}{
+			map[string]interface{}{"directory": dir, "spa": true},
+		}},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/supu", Method: "GET", Timeout: time.Second, Backend: []*config.Backend{{URLPattern: "/"}}},
+		},
+	}
+	if err := serviceCfg.Init(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	table := DefaultFactory(noopProxyFactory{"supu": "tupu"}, logger).New().(httpRouter).newEndpointTable(DefaultEngine(), serviceCfg, "")
+
+	for path, expected := range map[string]string{
+		"/supu":     `{"supu":"tupu"}`,
+		"/users/42": "<html>app</html>",
+		"/":         "<html>app</html>",
+	} {
+		w := httptest.NewRecorder()
+		table.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != expected {
+			t.Errorf("%s: unexpected response: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestDefaultFactory_webSocket(t *testing.T) {
+	logger, err := logging.NewLogger("ERROR", bytes.NewBuffer(nil), "pref")
+	if err != nil {
+		t.Error("building the logger:", err.Error())
+		return
+	}
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := router.UpgradeWebSocket(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append([]byte(r.URL.Path+":"), msg...))
+		}
+	}))
+	defer backend.Close()
+
+	pf := proxy.FactoryFunc(func(_ *config.EndpointConfig) (proxy.Proxy, error) {
+		return func(_ context.Context, _ *proxy.Request) (*proxy.Response, error) {
+			return &proxy.Response{IsComplete: true, Data: map[string]interface{}{"supu": "tupu"}}, nil
+		}, nil
+	})
+
+	serviceCfg := config.ServiceConfig{
+		Version: config.ConfigVersion,
+		Host:    []string{backend.URL},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint:    "/chat/*",
+				Method:      "GET",
+				Timeout:     time.Second,
+				Backend:     []*config.Backend{{URLPattern: "/ws"}},
+				ExtraConfig: config.ExtraConfig{router.WebSocketNamespace: map[string]interface{}{}},
+			},
+		},
+	}
+	if err := serviceCfg.Init(); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	server := httptest.NewServer(DefaultFactory(pf, logger).New().(httpRouter).newEndpointTable(DefaultEngine(), serviceCfg, ""))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/chat/room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	conn, _, err := proxy.DialWebSocket(context.Background(), server.URL+"/chat/room", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.WriteMessage(proxy.WebSocketTextMessage, []byte("hello"))
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "/ws/room:hello" {
+		t.Errorf("unexpected message: %s %v", msg, err)
+	}
+}
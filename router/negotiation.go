@@ -0,0 +1,58 @@
+package router
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/devopsfaith/krakend/encoding"
+)
+
+// NEGOTIATE is the output encoding selecting the render by the Accept header of the request
+const NEGOTIATE = "negotiate"
+
+// NegotiateEncoding returns the name of the output encoding preferred by the received Accept header.
+// If none of the accepted media types is supported, it returns the fallback
+func NegotiateEncoding(accept, fallback string) string {
+	for _, mediaType := range parseQualityList(accept) {
+		if name, ok := encoding.MediaTypes[mediaType]; ok {
+			return name
+		}
+		if mediaType == "*/*" || mediaType == "application/*" {
+			return fallback
+		}
+	}
+	return fallback
+}
+
+// parseQualityList returns the values of a header like Accept or Accept-Encoding sorted by their
+// quality, discarding the ones with a zero quality
+func parseQualityList(header string) []string {
+	type qualityValue struct {
+		value string
+		q     float64
+	}
+	values := []qualityValue{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		qv := qualityValue{value: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					qv.q = q
+				}
+			}
+		}
+		if qv.value != "" && qv.q > 0 {
+			values = append(values, qv)
+		}
+	}
+	sort.SliceStable(values, func(i, j int) bool { return values[i].q > values[j].q })
+
+	res := make([]string, len(values))
+	for i, qv := range values {
+		res[i] = qv.value
+	}
+	return res
+}
@@ -0,0 +1,21 @@
+package router
+
+import "testing"
+
+func TestNegotiateEncoding(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"":                 "json",
+		"application/json": "json",
+		"text/xml":         "xml",
+		"application/xml;q=0.5, application/x-yaml": "yaml",
+		"text/html, application/msgpack;q=0.9":      "msgpack",
+		"text/html, */*;q=0.1":                      "json",
+		"application/xml;q=0, text/html":            "json",
+		"APPLICATION/XML ; q=0.8":                   "xml",
+		"application/cbor":                          "cbor",
+	} {
+		if result := NegotiateEncoding(accept, "json"); result != expected {
+			t.Errorf("unexpected encoding for '%s': %s", accept, result)
+		}
+	}
+}
@@ -28,6 +28,7 @@ func DefaultConfig(pf proxy.Factory, logger logging.Logger, middlewares []negron
 func DefaultConfigWithRouter(pf proxy.Factory, logger logging.Logger, muxEngine *gorilla.Router, middlewares []negroni.Handler) mux.Config {
 	cfg := krakendgorilla.DefaultConfig(pf, logger)
 	cfg.Engine = newNegroniEngine(muxEngine, middlewares...)
+	cfg.EngineFactory = func() mux.Engine { return newNegroniEngine(NewGorillaRouter(), middlewares...) }
 	return cfg
 }
 
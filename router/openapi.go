@@ -0,0 +1,66 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/config/openapi"
+)
+
+// OpenAPINamespace is the key to look for the options of the OpenAPI endpoint in the extra config of the service
+const OpenAPINamespace = "github.com/devopsfaith/krakend/router/openapi"
+
+// DefaultOpenAPIPattern is the default path of the endpoint exposing the OpenAPI document of the service
+const DefaultOpenAPIPattern = "/__openapi"
+
+// OpenAPIConfig defines the endpoint exposing the OpenAPI document of the service
+type OpenAPIConfig struct {
+	// Path of the endpoint. By default, the DefaultOpenAPIPattern
+	Path string `json:"path"`
+	// Title, Description and Version are the metadata of the API
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	// Servers is the list of urls where the API is served
+	Servers []string `json:"servers"`
+}
+
+// OpenAPIConfigGetter parses the OpenAPI options from the extra config of the service. The second value is
+// false if the OpenAPI endpoint is not enabled
+func OpenAPIConfigGetter(extra config.ExtraConfig) (OpenAPIConfig, bool) {
+	cfg := OpenAPIConfig{}
+	v, ok := extra[OpenAPINamespace]
+	if !ok {
+		return cfg, false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultOpenAPIPattern
+	}
+	return cfg, true
+}
+
+// OpenAPIHandler returns a handler responding with the OpenAPI document describing the endpoints of the
+// service configuration
+func OpenAPIHandler(cfg config.ServiceConfig, oc OpenAPIConfig) http.Handler {
+	doc, err := json.Marshal(openapi.Export(cfg, openapi.Info{
+		Title:       oc.Title,
+		Description: oc.Description,
+		Version:     oc.Version,
+	}, oc.Servers...))
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	})
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/config/openapi"
+)
+
+func TestOpenAPIConfigGetter(t *testing.T) {
+	if _, ok := OpenAPIConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the openapi endpoint should be disabled")
+	}
+	cfg, ok := OpenAPIConfigGetter(config.ExtraConfig{OpenAPINamespace: map[string]interface{}{"title": "supu"}})
+	if !ok || cfg.Path != DefaultOpenAPIPattern || cfg.Title != "supu" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	cfg := config.ServiceConfig{Endpoints: []*config.EndpointConfig{
+		{Endpoint: "/supu/:id", Method: "GET", Backend: []*config.Backend{{URLPattern: "/tupu/{id}"}}},
+	}}
+	w := httptest.NewRecorder()
+	OpenAPIHandler(cfg, OpenAPIConfig{Title: "supu", Servers: []string{"https://api.example.com"}}).
+		ServeHTTP(w, httptest.NewRequest("GET", DefaultOpenAPIPattern, nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	doc := openapi.Document{}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if doc.Info.Title != "supu" || len(doc.Servers) != 1 || doc.Paths["/supu/{id}"]["get"].OperationID != "get_supu_id" {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+}
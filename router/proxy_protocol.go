@@ -0,0 +1,172 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidProxyProtocolHeader is the error returned when a connection from a trusted proxy does not start
+// with a valid PROXY protocol header
+var ErrInvalidProxyProtocolHeader = errors.New("proxy protocol: invalid header")
+
+// ProxyProtocolHeaderTimeout is the max time to read the PROXY protocol header of the connections
+var ProxyProtocolHeaderTimeout = 5 * time.Second
+
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	// proxyProtocolV1MaxLength is the max length of a v1 header, including the CRLF
+	proxyProtocolV1MaxLength = 107
+	proxyProtocolV2Local     = 0x20
+	proxyProtocolV2Proxy     = 0x21
+	proxyProtocolV2TCP4      = 0x11
+	proxyProtocolV2TCP6      = 0x21
+)
+
+// NewProxyProtocolListener returns a listener reading the PROXY protocol (v1 or v2) header of the
+// connections from the trusted proxies, so their remote address is the one of the client. The header is
+// read on the first use of the connection, so the slow proxies do not block the accept loop. The
+// connections from the rest of the peers are returned untouched
+func NewProxyProtocolListener(ln net.Listener, trusted TrustedProxies) net.Listener {
+	return &proxyProtocolListener{Listener: ln, trusted: trusted}
+}
+
+type proxyProtocolListener struct {
+	net.Listener
+	trusted TrustedProxies
+}
+
+// Accept implements the net.Listener interface
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	addr, ok := c.RemoteAddr().(*net.TCPAddr)
+	if !ok || !l.trusted.Contains(addr.IP) {
+		return c, nil
+	}
+	return &proxyProtocolConn{Conn: c, reader: bufio.NewReader(c)}, nil
+}
+
+type proxyProtocolConn struct {
+	net.Conn
+	reader *bufio.Reader
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(ProxyProtocolHeaderTimeout))
+		c.remote, c.err = readProxyProtocolHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+// Read implements the net.Conn interface
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr implements the net.Conn interface. It is the address of the client declared by the header, or
+// the one of the proxy when the header does not declare it
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyProtocolHeader reads the header, returning the source address. It is nil for the health checks
+// of the proxies and the unknown protocols
+func readProxyProtocolHeader(r *bufio.Reader) (net.Addr, error) {
+	signature, err := r.Peek(len(proxyProtocolV2Signature))
+	if err != nil {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	if bytes.Equal(signature, proxyProtocolV2Signature) {
+		return readProxyProtocolV2(r)
+	}
+	if bytes.HasPrefix(signature, []byte("PROXY ")) {
+		return readProxyProtocolV1(r)
+	}
+	return nil, ErrInvalidProxyProtocolHeader
+}
+
+func readProxyProtocolV1(r *bufio.Reader) (net.Addr, error) {
+	line := make([]byte, 0, proxyProtocolV1MaxLength)
+	for len(line) < proxyProtocolV1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrInvalidProxyProtocolHeader
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+func readProxyProtocolV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	command, family := header[12], header[13]
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+
+	switch command {
+	case proxyProtocolV2Local:
+		return nil, nil
+	case proxyProtocolV2Proxy:
+	default:
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	switch family {
+	case proxyProtocolV2TCP4:
+		if len(payload) < 12 {
+			return nil, ErrInvalidProxyProtocolHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case proxyProtocolV2TCP6:
+		if len(payload) < 36 {
+			return nil, ErrInvalidProxyProtocolHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,114 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+)
+
+func proxyProtocolV2Header(command, family byte, payload []byte) []byte {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(payload)))
+	return append(header, payload...)
+}
+
+func TestReadProxyProtocolHeader(t *testing.T) {
+	tcp4 := []byte{1, 2, 3, 4, 10, 0, 0, 1, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(tcp4[8:10], 4711)
+	binary.BigEndian.PutUint16(tcp4[10:12], 8080)
+
+	for _, tc := range []struct {
+		name     string
+		header   []byte
+		expected string
+		err      error
+	}{
+		{name: "v1 tcp4", header: []byte("PROXY TCP4 1.2.3.4 10.0.0.1 4711 8080\r\n"), expected: "1.2.3.4:4711"},
+		{name: "v1 tcp6", header: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 4711 8080\r\n"), expected: "[2001:db8::1]:4711"},
+		{name: "v1 unknown", header: []byte("PROXY UNKNOWN\r\n")},
+		{name: "v1 without crlf", header: []byte("PROXY TCP4 1.2.3.4 10.0.0.1 4711 8080\n"), err: ErrInvalidProxyProtocolHeader},
+		{name: "v1 invalid ip", header: []byte("PROXY TCP4 supu 10.0.0.1 4711 8080\r\n"), err: ErrInvalidProxyProtocolHeader},
+		{name: "v1 too long", header: []byte("PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n"), err: ErrInvalidProxyProtocolHeader},
+		{name: "v2 tcp4", header: proxyProtocolV2Header(proxyProtocolV2Proxy, proxyProtocolV2TCP4, tcp4), expected: "1.2.3.4:4711"},
+		{name: "v2 local", header: proxyProtocolV2Header(proxyProtocolV2Local, 0, nil)},
+		{name: "v2 short payload", header: proxyProtocolV2Header(proxyProtocolV2Proxy, proxyProtocolV2TCP4, tcp4[:8]), err: ErrInvalidProxyProtocolHeader},
+		{name: "v2 unknown command", header: proxyProtocolV2Header(0x2f, proxyProtocolV2TCP4, tcp4), err: ErrInvalidProxyProtocolHeader},
+		{name: "no header", header: []byte("GET / HTTP/1.1\r\n\r\n"), err: ErrInvalidProxyProtocolHeader},
+	} {
+		r := bufio.NewReader(bytes.NewReader(append(tc.header, []byte("GET / HTTP/1.1\r\n")...)))
+		addr, err := readProxyProtocolHeader(r)
+		if err != tc.err {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if tc.expected == "" {
+			if addr != nil {
+				t.Errorf("%s: unexpected address: %v", tc.name, addr)
+			}
+		} else if addr == nil || addr.String() != tc.expected {
+			t.Errorf("%s: unexpected address: %v", tc.name, addr)
+		}
+		if rest, _ := ioutil.ReadAll(r); string(rest) != "GET / HTTP/1.1\r\n" {
+			t.Errorf("%s: unexpected remaining data: %q", tc.name, string(rest))
+		}
+	}
+}
+
+func TestNewProxyProtocolListener(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		trusted  []string
+		expected string
+	}{
+		{name: "trusted", trusted: []string{"127.0.0.1"}, expected: "1.2.3.4:4711"},
+		{name: "untrusted", trusted: []string{"10.0.0.0/8"}, expected: "127.0.0.1"},
+	} {
+		trusted, err := NewTrustedProxies(tc.trusted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln = NewProxyProtocolListener(ln, trusted)
+
+		go func() {
+			c, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				return
+			}
+			c.Write([]byte("PROXY TCP4 1.2.3.4 10.0.0.1 4711 8080\r\nsupu"))
+			c.Close()
+		}()
+
+		c, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		remote := c.RemoteAddr().String()
+		if tc.name == "untrusted" {
+			remote, _, _ = net.SplitHostPort(remote)
+		}
+		if remote != tc.expected {
+			t.Errorf("%s: unexpected remote address: %s", tc.name, remote)
+		}
+		b, _ := ioutil.ReadAll(c)
+		if tc.name == "trusted" && string(b) != "supu" {
+			t.Errorf("%s: unexpected data: %q", tc.name, string(b))
+		}
+		if tc.name == "untrusted" && !strings.HasPrefix(string(b), "PROXY ") {
+			t.Errorf("%s: unexpected data: %q", tc.name, string(b))
+		}
+		c.Close()
+		ln.Close()
+	}
+}
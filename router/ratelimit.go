@@ -0,0 +1,354 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devopsfaith/krakend/audit"
+	"github.com/devopsfaith/krakend/config"
+)
+
+// RateLimitNamespace is the key to look for the rate limit options in the extra config of the endpoints
+const RateLimitNamespace = "github.com/devopsfaith/krakend/router/ratelimit"
+
+const (
+	// ClientByIP is the strategy identifying the clients by their IP
+	ClientByIP = "ip"
+	// ClientByHeader is the strategy identifying the clients by the value of a header
+	ClientByHeader = "header"
+	// ClientByJWTClaim is the strategy identifying the clients by a claim of their bearer token.
+	//
+	// WARNING: the token is NOT validated, so any client can forge a token with a different claim in
+	// every request and bypass its own limit. Only use it for endpoints behind a layer rejecting the
+	// requests without a valid JWT, or the client limit is useless
+	ClientByJWTClaim = "jwt"
+
+	memoryRateLimitStoreName = "memory"
+	// rateLimitCleanupPeriod is the number of takes between the cleanups of the in-memory store
+	rateLimitCleanupPeriod = 1000
+)
+
+var (
+	// ErrUnknownRateLimitStrategy is the error returned when the client strategy is unknown
+	ErrUnknownRateLimitStrategy = errors.New("unknown rate limit client strategy")
+	// ErrUnknownRateLimitStore is the error returned when the rate limit store is not registered
+	ErrUnknownRateLimitStore = errors.New("unknown rate limit store")
+)
+
+// RateLimitConfig defines the token buckets of an endpoint. The rates are tokens per second and the
+// capacities, the max burst. A zero rate disables its limit
+type RateLimitConfig struct {
+	MaxRate        float64 `json:"max_rate"`
+	Capacity       int     `json:"capacity"`
+	ClientMaxRate  float64 `json:"client_max_rate"`
+	ClientCapacity int     `json:"client_capacity"`
+	// Strategy defines how the clients are identified: ip (default), header or jwt. The jwt one reads the
+	// claim of an unverified token, so it must only be used behind a layer validating the JWTs
+	Strategy string `json:"strategy"`
+	// Key is the name of the header or the claim identifying the clients
+	Key string `json:"key"`
+	// Store is the name of the registered RateLimitStore to use. By default, an in-memory one
+	Store string `json:"store"`
+}
+
+// RateLimitStore keeps the token buckets of the rate limiters. The distributed implementations allow
+// several instances of the service to share their limits
+type RateLimitStore interface {
+	// Take consumes a token of the bucket with the received key. If the bucket is empty, it returns
+	// false and the time until the next token
+	Take(key string, rate float64, capacity int) (bool, time.Duration)
+}
+
+// RateLimitStoreInspector is implemented by the stores able to report the tokens left in their buckets
+type RateLimitStoreInspector interface {
+	// Tokens returns the tokens left in the bucket with the received key. The second value is false if the
+	// bucket does not exist
+	Tokens(key string) (float64, bool)
+}
+
+// RateLimitState is the state of the rate limiter of an endpoint
+type RateLimitState struct {
+	Endpoint string          `json:"endpoint"`
+	Method   string          `json:"method"`
+	Config   RateLimitConfig `json:"config"`
+	// Allowed and Rejected count the requests checked by the rate limiter
+	Allowed  uint64 `json:"allowed"`
+	Rejected uint64 `json:"rejected"`
+	// Tokens are the tokens left in the bucket of the endpoint, when its store is a RateLimitStoreInspector.
+	// A full bucket may be reported as missing
+	Tokens *float64 `json:"tokens,omitempty"`
+}
+
+type rateLimiterState struct {
+	cfg      RateLimitConfig
+	store    RateLimitStore
+	capacity int
+	allowed  uint64
+	rejected uint64
+}
+
+var (
+	rateLimiterStates   = map[string]*rateLimiterState{}
+	rateLimiterStatesMu sync.RWMutex
+)
+
+// RateLimitStateOf returns the state of the rate limiter of the endpoint. The second value is false if the
+// endpoint has no rate limiter
+func RateLimitStateOf(cfg *config.EndpointConfig) (RateLimitState, bool) {
+	rateLimiterStatesMu.RLock()
+	s, ok := rateLimiterStates[cfg.Method+" "+cfg.Endpoint]
+	rateLimiterStatesMu.RUnlock()
+	if !ok {
+		return RateLimitState{}, false
+	}
+	state := RateLimitState{
+		Endpoint: cfg.Endpoint,
+		Method:   cfg.Method,
+		Config:   s.cfg,
+		Allowed:  atomic.LoadUint64(&s.allowed),
+		Rejected: atomic.LoadUint64(&s.rejected),
+	}
+	if i, ok := s.store.(RateLimitStoreInspector); ok && s.cfg.MaxRate > 0 {
+		tokens := float64(s.capacity)
+		if v, ok := i.Tokens(cfg.Method + " " + cfg.Endpoint); ok {
+			tokens = v
+		}
+		state.Tokens = &tokens
+	}
+	return state, true
+}
+
+// RateLimitStoreFactory creates a RateLimitStore with the rate limit options of an endpoint
+type RateLimitStoreFactory func(cfg map[string]interface{}) (RateLimitStore, error)
+
+var rateLimitStores = map[string]RateLimitStoreFactory{
+	memoryRateLimitStoreName: func(_ map[string]interface{}) (RateLimitStore, error) { return NewMemoryRateLimitStore(), nil },
+}
+
+// RegisterRateLimitStore registers the rate limit store factory with the given name
+func RegisterRateLimitStore(name string, f RateLimitStoreFactory) error {
+	rateLimitStores[name] = f
+	return nil
+}
+
+// RateLimiter checks if the request can be processed. If not, it returns the time to wait before retrying
+type RateLimiter func(*http.Request) (bool, time.Duration)
+
+// RateLimitConfigGetter parses and validates the rate limit options from the extra config of an endpoint, so
+// the routers can reject the invalid ones when the endpoints are registered. The second value is false if
+// the rate limit is not enabled
+func RateLimitConfigGetter(extra config.ExtraConfig) (RateLimitConfig, bool, error) {
+	cfg := RateLimitConfig{}
+	v, ok := extra[RateLimitNamespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false, err
+	}
+	if cfg.MaxRate <= 0 && cfg.ClientMaxRate <= 0 {
+		return cfg, false, nil
+	}
+	if cfg.Store == "" {
+		cfg.Store = memoryRateLimitStoreName
+	}
+	if _, ok := rateLimitStores[cfg.Store]; !ok {
+		return cfg, false, ErrUnknownRateLimitStore
+	}
+	if _, err := clientIdentifier(cfg); err != nil {
+		return cfg, false, err
+	}
+	return cfg, true, nil
+}
+
+// NewRateLimiter creates a RateLimiter with the options of the extra config of the endpoint. It returns a
+// nil RateLimiter if the rate limit is not enabled
+func NewRateLimiter(cfg *config.EndpointConfig) (RateLimiter, error) {
+	rlCfg, ok, err := RateLimitConfigGetter(cfg.ExtraConfig)
+	if err != nil || !ok {
+		return nil, err
+	}
+	v, _ := cfg.ExtraConfig[RateLimitNamespace].(map[string]interface{})
+	store, err := rateLimitStores[rlCfg.Store](v)
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, _ := clientIdentifier(rlCfg)
+
+	endpointKey := cfg.Method + " " + cfg.Endpoint
+	capacity := bucketCapacity(rlCfg.MaxRate, rlCfg.Capacity)
+	clientCapacity := bucketCapacity(rlCfg.ClientMaxRate, rlCfg.ClientCapacity)
+
+	state := &rateLimiterState{cfg: rlCfg, store: store, capacity: capacity}
+	rateLimiterStatesMu.Lock()
+	rateLimiterStates[endpointKey] = state
+	rateLimiterStatesMu.Unlock()
+
+	// the client bucket is checked first, so the requests rejected by the client limit do not drain the
+	// bucket shared by all the clients of the endpoint
+	limiter := func(r *http.Request) (bool, time.Duration) {
+		if rlCfg.ClientMaxRate > 0 {
+			if ok, wait := store.Take(endpointKey+"\n"+clientID(r), rlCfg.ClientMaxRate, clientCapacity); !ok {
+				return false, wait
+			}
+		}
+		if rlCfg.MaxRate > 0 {
+			return store.Take(endpointKey, rlCfg.MaxRate, capacity)
+		}
+		return true, 0
+	}
+	return func(r *http.Request) (bool, time.Duration) {
+		ok, wait := limiter(r)
+		result := "allowed"
+		if ok {
+			atomic.AddUint64(&state.allowed, 1)
+		} else {
+			atomic.AddUint64(&state.rejected, 1)
+			result = "rejected"
+			auditRequest(audit.EventRateLimit, audit.OutcomeDenied, cfg, r, "", ErrTooManyRequests)
+		}
+		rateLimitRequests.Inc(cfg.Endpoint, result)
+		return ok, wait
+	}, nil
+}
+
+// RetryAfter returns the value of the Retry-After header for the received wait, in seconds
+func RetryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+func bucketCapacity(rate float64, capacity int) int {
+	if capacity > 0 {
+		return capacity
+	}
+	return int(math.Max(1, math.Ceil(rate)))
+}
+
+func clientIdentifier(cfg RateLimitConfig) (func(*http.Request) string, error) {
+	switch cfg.Strategy {
+	case "", ClientByIP:
+		return ClientIP, nil
+	case ClientByHeader:
+		return func(r *http.Request) string { return r.Header.Get(cfg.Key) }, nil
+	case ClientByJWTClaim:
+		return func(r *http.Request) string {
+			if claim, ok := jwtClaim(r, cfg.Key); ok {
+				return claim
+			}
+			return ClientIP(r)
+		}, nil
+	}
+	return nil, ErrUnknownRateLimitStrategy
+}
+
+func jwtClaim(r *http.Request, claim string) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", false
+	}
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", false
+	}
+	v, ok := claims[claim]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+// NewMemoryRateLimitStore creates a RateLimitStore keeping the token buckets in memory
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{buckets: map[string]*tokenBucket{}, now: time.Now}
+}
+
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	takes   int
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	rate     float64
+	capacity float64
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// Take implements the RateLimitStore interface
+func (m *memoryRateLimitStore) Take(key string, rate float64, capacity int) (bool, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.takes++
+	if m.takes >= rateLimitCleanupPeriod {
+		m.takes = 0
+		m.cleanup(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(capacity), last: now}
+		m.buckets[key] = b
+	}
+	b.rate = rate
+	b.capacity = float64(capacity)
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// Tokens implements the RateLimitStoreInspector interface
+func (m *memoryRateLimitStore) Tokens(key string) (float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[key]
+	if !ok {
+		return 0, false
+	}
+	b.refill(m.now())
+	return b.tokens, true
+}
+
+// cleanup removes the full buckets, since they are equivalent to the missing ones
+func (m *memoryRateLimitStore) cleanup(now time.Time) {
+	for k, b := range m.buckets {
+		b.refill(now)
+		if b.tokens >= b.capacity {
+			delete(m.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Package redis provides a rate limit store sharing the token buckets of the endpoints between several
+// instances of the service through a Redis server
+package redis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/devopsfaith/krakend/router"
+)
+
+// Name is the key of the store in the rate limit config of the endpoints
+const Name = "redis"
+
+// ErrNoAddress is the error returned when the address of the Redis server is not defined
+var ErrNoAddress = errors.New("the address of the redis server is required")
+
+// takeScript refills and consumes the bucket atomically. It returns if the token was taken and the
+// milliseconds to wait for the next one
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(bucket[1]) or capacity
+local last = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - last) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate * 1000))
+return {allowed, wait}
+`)
+
+// Register registers the Redis rate limit store factory
+func Register() error {
+	return router.RegisterRateLimitStore(Name, RateLimitStoreFactory)
+}
+
+// RateLimitStoreFactory creates a Redis rate limit store with the 'address', 'password' and 'db' options
+// of the rate limit config
+func RateLimitStoreFactory(cfg map[string]interface{}) (router.RateLimitStore, error) {
+	addr, ok := cfg["address"].(string)
+	if !ok || addr == "" {
+		return nil, ErrNoAddress
+	}
+	password, _ := cfg["password"].(string)
+	db, _ := cfg["db"].(float64)
+
+	return New(redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       int(db),
+	})), nil
+}
+
+// New creates a rate limit store using the received Redis client. The requests are allowed when the
+// server is not available, so its failures do not bring down the endpoints
+func New(client *redis.Client) router.RateLimitStore {
+	return store{client: client, now: time.Now}
+}
+
+type store struct {
+	client *redis.Client
+	now    func() time.Time
+}
+
+// Take implements the router.RateLimitStore interface
+func (s store) Take(key string, rate float64, capacity int) (bool, time.Duration) {
+	now := s.now().UnixNano() / int64(time.Millisecond)
+	res, err := takeScript.Run(s.client, []string{key}, rate, capacity, now).Result()
+	if err != nil {
+		return true, 0
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 2 {
+		return true, 0
+	}
+	allowed, _ := values[0].(int64)
+	wait, _ := values[1].(int64)
+	return allowed == 1, time.Duration(wait) * time.Millisecond
+}
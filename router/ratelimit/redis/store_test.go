@@ -0,0 +1,25 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+func TestRateLimitStoreFactory_noAddress(t *testing.T) {
+	if _, err := RateLimitStoreFactory(map[string]interface{}{}); err != ErrNoAddress {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStore_unreachable(t *testing.T) {
+	s := New(redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: 10 * time.Millisecond,
+		MaxRetries:  0,
+	}))
+	if ok, _ := s.Take("supu", 1, 1); !ok {
+		t.Error("the failures of the server must not reject the requests")
+	}
+}
@@ -0,0 +1,241 @@
+package router
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestNewRateLimiter_disabled(t *testing.T) {
+	for i, extra := range []config.ExtraConfig{
+		{},
+		{RateLimitNamespace: map[string]interface{}{}},
+		{RateLimitNamespace: map[string]interface{}{"capacity": 10}},
+	} {
+		rl, err := NewRateLimiter(&config.EndpointConfig{ExtraConfig: extra})
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %s", i, err.Error())
+		}
+		if rl != nil {
+			t.Errorf("#%d: unexpected rate limiter", i)
+		}
+	}
+}
+
+func TestNewRateLimiter_koConfig(t *testing.T) {
+	for i, tc := range []struct {
+		cfg map[string]interface{}
+		err error
+	}{
+		{cfg: map[string]interface{}{"max_rate": 1, "store": "unknown"}, err: ErrUnknownRateLimitStore},
+		{cfg: map[string]interface{}{"client_max_rate": 1, "strategy": "unknown"}, err: ErrUnknownRateLimitStrategy},
+	} {
+		_, err := NewRateLimiter(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{RateLimitNamespace: tc.cfg}})
+		if err != tc.err {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestRateLimitConfigGetter(t *testing.T) {
+	if _, ok, err := RateLimitConfigGetter(config.ExtraConfig{RateLimitNamespace: map[string]interface{}{"capacity": 10}}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if _, _, err := RateLimitConfigGetter(config.ExtraConfig{RateLimitNamespace: map[string]interface{}{"max_rate": "fast"}}); err == nil {
+		t.Error("the invalid options must be rejected")
+	}
+	cfg, ok, err := RateLimitConfigGetter(config.ExtraConfig{RateLimitNamespace: map[string]interface{}{"client_max_rate": 1}})
+	if !ok || err != nil || cfg.Store != memoryRateLimitStoreName {
+		t.Errorf("unexpected result: %+v %v %v", cfg, ok, err)
+	}
+}
+
+func TestNewRateLimiter_endpoint(t *testing.T) {
+	rl, err := NewRateLimiter(&config.EndpointConfig{
+		Method:      "GET",
+		Endpoint:    "/supu",
+		ExtraConfig: config.ExtraConfig{RateLimitNamespace: map[string]interface{}{"max_rate": 1, "capacity": 2}},
+	})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	for i, expected := range []bool{true, true, false} {
+		r, _ := http.NewRequest("GET", "/supu", nil)
+		r.RemoteAddr = "127.0.0.1:1234"
+		ok, wait := rl(r)
+		if ok != expected {
+			t.Errorf("#%d: unexpected result: %v", i, ok)
+		}
+		if !ok && (wait <= 0 || wait > time.Second) {
+			t.Errorf("#%d: unexpected wait: %v", i, wait)
+		}
+	}
+}
+
+func TestRateLimitStateOf(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Method:      "GET",
+		Endpoint:    "/state",
+		ExtraConfig: config.ExtraConfig{RateLimitNamespace: map[string]interface{}{"max_rate": 0.001, "capacity": 2}},
+	}
+	if _, ok := RateLimitStateOf(cfg); ok {
+		t.Error("unexpected state of an unknown rate limiter")
+	}
+	rl, err := NewRateLimiter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		rateLimiterStatesMu.Lock()
+		delete(rateLimiterStates, cfg.Method+" "+cfg.Endpoint)
+		rateLimiterStatesMu.Unlock()
+	}()
+	if s, ok := RateLimitStateOf(cfg); !ok || s.Tokens == nil || *s.Tokens != 2 {
+		t.Errorf("unexpected state: %+v", s)
+	}
+	for i := 0; i < 3; i++ {
+		r, _ := http.NewRequest("GET", "/state", nil)
+		rl(r)
+	}
+	s, ok := RateLimitStateOf(cfg)
+	if !ok || s.Allowed != 2 || s.Rejected != 1 || s.Config.Capacity != 2 || s.Tokens == nil || *s.Tokens >= 1 {
+		t.Errorf("unexpected state: %+v", s)
+	}
+}
+
+func TestNewRateLimiter_clients(t *testing.T) {
+	token := func(payload string) string {
+		return "Bearer header." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+	}
+	for _, tc := range []struct {
+		name    string
+		cfg     map[string]interface{}
+		prepare func(r *http.Request, client int)
+	}{
+		{
+			name: "ip",
+			cfg:  map[string]interface{}{},
+			prepare: func(r *http.Request, client int) {
+				r.RemoteAddr = []string{"10.0.0.1:1234", "10.0.0.2:1234"}[client]
+			},
+		},
+		{
+			name: "header",
+			cfg:  map[string]interface{}{"strategy": "header", "key": "X-Api-Key"},
+			prepare: func(r *http.Request, client int) {
+				r.Header.Set("X-Api-Key", []string{"a", "b"}[client])
+			},
+		},
+		{
+			name: "jwt",
+			cfg:  map[string]interface{}{"strategy": "jwt", "key": "sub"},
+			prepare: func(r *http.Request, client int) {
+				r.Header.Set("Authorization", token([]string{`{"sub":"a"}`, `{"sub":"b"}`}[client]))
+			},
+		},
+	} {
+		tc.cfg["client_max_rate"] = 1
+		rl, err := NewRateLimiter(&config.EndpointConfig{ExtraConfig: config.ExtraConfig{RateLimitNamespace: tc.cfg}})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+		for i, step := range []struct {
+			client   int
+			expected bool
+		}{{0, true}, {0, false}, {1, true}, {1, false}} {
+			r, _ := http.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "127.0.0.1:1234"
+			tc.prepare(r, step.client)
+			if ok, _ := rl(r); ok != step.expected {
+				t.Errorf("%s #%d: unexpected result: %v", tc.name, i, ok)
+			}
+		}
+	}
+}
+
+func TestNewRateLimiter_clientRejectionsKeepEndpointTokens(t *testing.T) {
+	rl, err := NewRateLimiter(&config.EndpointConfig{
+		Method:   "GET",
+		Endpoint: "/noisy",
+		ExtraConfig: config.ExtraConfig{RateLimitNamespace: map[string]interface{}{
+			"max_rate":        0.001,
+			"capacity":        3,
+			"client_max_rate": 0.001,
+		}},
+	})
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	for i, step := range []struct {
+		addr     string
+		expected bool
+	}{
+		{"10.0.0.1:1234", true},
+		{"10.0.0.1:1234", false},
+		{"10.0.0.1:1234", false},
+		{"10.0.0.1:1234", false},
+		{"10.0.0.2:1234", true},
+		{"10.0.0.3:1234", true},
+		{"10.0.0.4:1234", false},
+	} {
+		r, _ := http.NewRequest("GET", "/noisy", nil)
+		r.RemoteAddr = step.addr
+		if ok, _ := rl(r); ok != step.expected {
+			t.Errorf("#%d: unexpected result: %v", i, ok)
+		}
+	}
+}
+
+func TestJWTClaim(t *testing.T) {
+	for i, auth := range []string{"", "Basic abc", "Bearer abc", "Bearer a.!!!.c", "Bearer a.e30.c"} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", auth)
+		if _, ok := jwtClaim(r, "sub"); ok {
+			t.Errorf("#%d: unexpected claim", i)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		100 * time.Millisecond:  "1",
+		time.Second:             "1",
+		1500 * time.Millisecond: "2",
+	} {
+		if v := RetryAfter(d); v != expected {
+			t.Errorf("%v: unexpected value %s", d, v)
+		}
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	now := time.Now()
+	store := &memoryRateLimitStore{buckets: map[string]*tokenBucket{}, now: func() time.Time { return now }}
+
+	for i, expected := range []bool{true, true, false} {
+		if ok, _ := store.Take("a", 10, 2); ok != expected {
+			t.Errorf("#%d: unexpected result: %v", i, ok)
+		}
+	}
+	ok, wait := store.Take("a", 10, 2)
+	if ok || wait != 100*time.Millisecond {
+		t.Errorf("unexpected result: %v %v", ok, wait)
+	}
+
+	now = now.Add(100 * time.Millisecond)
+	if ok, _ := store.Take("a", 10, 2); !ok {
+		t.Error("the bucket should be refilled")
+	}
+
+	now = now.Add(time.Second)
+	store.cleanup(now)
+	if len(store.buckets) != 0 {
+		t.Errorf("unexpected buckets after the cleanup: %d", len(store.buckets))
+	}
+}
@@ -0,0 +1,34 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// ReloadPattern is the path of the endpoint triggering the reload of the service configuration
+const ReloadPattern = "/__reload"
+
+// UpdatableRouter is a Router able to replace its endpoints with the received configurations without
+// restarting the server, so the connections are not dropped
+type UpdatableRouter interface {
+	Router
+	// RunWithUpdates runs the router with the configuration, replacing the endpoints with every
+	// configuration received from the updates channel
+	RunWithUpdates(cfg config.ServiceConfig, updates <-chan config.ServiceConfig)
+}
+
+// ReloadHandler returns a handler calling the reload function on every POST request, so the reloads of
+// the configuration can be triggered from an admin API. The handler does not authenticate the requests, so
+// it must only be served by an authenticated listener and never by the public engine
+func ReloadHandler(reload func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		reload()
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReloadHandler(t *testing.T) {
+	calls := 0
+	handler := ReloadHandler(func() { calls++ })
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", ReloadPattern, nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+	if calls != 0 {
+		t.Error("the reload was triggered by a GET request")
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", ReloadPattern, nil))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if calls != 1 {
+		t.Errorf("unexpected number of reloads: %d", calls)
+	}
+}
@@ -0,0 +1,221 @@
+package router
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// RequestIDNamespace is the key to look for the request ID options in the extra config of the service
+const RequestIDNamespace = "github.com/devopsfaith/krakend/router/request_id"
+
+// DefaultRequestIDHeader is the header carrying the request IDs when it is not configured
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// Formats of the generated request IDs
+const (
+	RequestIDFormatUUIDv7 = "uuidv7"
+	RequestIDFormatKSUID  = "ksuid"
+)
+
+// maxRequestIDLength is the max length of the honoured incoming request IDs
+const maxRequestIDLength = 128
+
+// RequestIDConfig defines the generation and the propagation of the request IDs
+type RequestIDConfig struct {
+	// Header carrying the request ID in the requests, the responses and the requests to the backends. By
+	// default, the DefaultRequestIDHeader
+	Header string `json:"header"`
+	// Format of the generated IDs, RequestIDFormatUUIDv7 or RequestIDFormatKSUID. By default,
+	// RequestIDFormatUUIDv7
+	Format string `json:"format"`
+	// TrustedProxies are the IPs and CIDRs of the peers whose request IDs are honoured. The IDs received from
+	// the rest of the peers are replaced by a generated one
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+// RequestIDConfigGetter parses the request ID options from the extra config of the service. The second value
+// is false if they are not declared
+func RequestIDConfigGetter(extra config.ExtraConfig) (RequestIDConfig, bool, error) {
+	cfg := RequestIDConfig{}
+	v, ok := extra[RequestIDNamespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false, err
+	}
+	if cfg.Header == "" {
+		cfg.Header = DefaultRequestIDHeader
+	}
+	if cfg.Format == "" {
+		cfg.Format = RequestIDFormatUUIDv7
+	}
+	return cfg, true, nil
+}
+
+// RequestIDGenerator returns a new request ID
+type RequestIDGenerator func() string
+
+var (
+	requestIDGeneratorsMu sync.RWMutex
+	requestIDGenerators   = map[string]RequestIDGenerator{
+		RequestIDFormatUUIDv7: NewUUIDv7,
+		RequestIDFormatKSUID:  NewKSUID,
+	}
+)
+
+// RegisterRequestIDGenerator registers the generator of the request IDs with the given format
+func RegisterRequestIDGenerator(format string, g RequestIDGenerator) error {
+	requestIDGeneratorsMu.Lock()
+	requestIDGenerators[format] = g
+	requestIDGeneratorsMu.Unlock()
+	return nil
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request, assigned by the RequestIDHandler. Without it, it returns an empty
+// string
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDHandler decorates the handler, assigning an ID to every request with the options declared at the
+// extra config of the service. If they are not declared, the handler is returned untouched
+func RequestIDHandler(extra config.ExtraConfig, next http.Handler) (http.Handler, error) {
+	cfg, ok, err := RequestIDConfigGetter(extra)
+	if err != nil || !ok {
+		return next, err
+	}
+	return NewRequestIDHandler(cfg, next)
+}
+
+// NewRequestIDHandler returns a handler assigning an ID to every request: the one received in the header
+// from a trusted proxy or a generated one. The ID is added to the response, to the requests to the backends,
+// to the span of the request and to the access log and the audit log entries
+func NewRequestIDHandler(cfg RequestIDConfig, next http.Handler) (http.Handler, error) {
+	header := cfg.Header
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	format := cfg.Format
+	if format == "" {
+		format = RequestIDFormatUUIDv7
+	}
+	requestIDGeneratorsMu.RLock()
+	generate, ok := requestIDGenerators[format]
+	requestIDGeneratorsMu.RUnlock()
+	if !ok {
+		return next, fmt.Errorf("unknown request ID format: %s", cfg.Format)
+	}
+	trusted, err := NewTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return next, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(header)
+		if !validRequestID(id) || !trusted.Contains(net.ParseIP(remoteIP(r))) {
+			id = generate()
+			r.Header.Set(header, id)
+		}
+		w.Header().Set(header, id)
+		if span := trace.SpanFromContext(r.Context()); span.IsRecording() {
+			span.SetAttributes(attribute.String("krakend.request_id", id))
+		}
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(proxy.NewRequestIDContext(ctx, header, id)))
+	}), nil
+}
+
+// validRequestID accepts the non empty IDs with up to maxRequestIDLength visible ASCII characters, so the
+// received values can not forge the log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	return strings.IndexFunc(id, func(c rune) bool { return c <= ' ' || c > '~' }) == -1
+}
+
+// NewUUIDv7 returns a random UUID of the version 7 (RFC 9562), starting with the current unix time in
+// milliseconds, so the IDs are sorted by their creation time
+func NewUUIDv7() string {
+	var b [16]byte
+	rand.Read(b[6:])
+	ms := uint64(time.Now().UnixMilli())
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
+
+// ksuidEpoch is the start of the timestamps of the KSUIDs, in unix seconds
+const ksuidEpoch = 1400000000
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// NewKSUID returns a random K-Sortable Unique ID: 27 base62 characters encoding the seconds since the KSUID
+// epoch and 16 random bytes
+func NewKSUID() string {
+	var b [20]byte
+	binary.BigEndian.PutUint32(b[:4], uint32(time.Now().Unix()-ksuidEpoch))
+	rand.Read(b[4:])
+
+	var s [27]byte
+	for i := range s {
+		s[i] = '0'
+	}
+	// long division of the big endian number by 62, taking the remainders as the digits from the end
+	n := b[:]
+	for i := len(s) - 1; i >= 0 && len(n) > 0; i-- {
+		var rem uint32
+		quotient := n[:0:0]
+		for _, d := range n {
+			acc := rem<<8 | uint32(d)
+			q := acc / 62
+			rem = acc % 62
+			if len(quotient) > 0 || q > 0 {
+				quotient = append(quotient, byte(q))
+			}
+		}
+		s[i] = base62Alphabet[rem]
+		n = quotient
+	}
+	return string(s[:])
+}
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestRequestIDHandler(t *testing.T) {
+	var seen, propagated string
+	next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r)
+		propagated, _ = proxy.RequestIDFromContext(r.Context())
+	})
+	h, err := RequestIDHandler(config.ExtraConfig{RequestIDNamespace: map[string]interface{}{
+		"header":          "X-Correlation-Id",
+		"trusted_proxies": []interface{}{"10.0.0.0/8"},
+	}}, next)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	uuidv7 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	for _, tc := range []struct {
+		name     string
+		remote   string
+		incoming string
+		honoured bool
+	}{
+		{name: "trusted", remote: "10.0.0.1:1234", incoming: "abc-123", honoured: true},
+		{name: "untrusted", remote: "1.1.1.1:1234", incoming: "abc-123"},
+		{name: "missing", remote: "10.0.0.1:1234"},
+		{name: "invalid", remote: "10.0.0.1:1234", incoming: "abc\n123"},
+		{name: "too long", remote: "10.0.0.1:1234", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+	} {
+		r := httptest.NewRequest("GET", "/supu", nil)
+		r.RemoteAddr = tc.remote
+		if tc.incoming != "" {
+			r.Header.Set("X-Correlation-Id", tc.incoming)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		id := w.Header().Get("X-Correlation-Id")
+		if tc.honoured && id != tc.incoming {
+			t.Errorf("%s: the incoming ID was not honoured: %s", tc.name, id)
+		}
+		if !tc.honoured && !uuidv7.MatchString(id) {
+			t.Errorf("%s: unexpected generated ID: %s", tc.name, id)
+		}
+		if seen != id || propagated != id {
+			t.Errorf("%s: unexpected IDs in the context: %s, %s", tc.name, seen, propagated)
+		}
+	}
+}
+
+func TestRequestIDHandler_ko(t *testing.T) {
+	for i, cfg := range []map[string]interface{}{
+		{"format": "unknown"},
+		{"trusted_proxies": []interface{}{"not an ip"}},
+	} {
+		if _, err := RequestIDHandler(config.ExtraConfig{RequestIDNamespace: cfg}, http.NotFoundHandler()); err == nil {
+			t.Errorf("#%d: expecting an error", i)
+		}
+	}
+}
+
+func TestNewKSUID(t *testing.T) {
+	ksuid := regexp.MustCompile(`^[0-9A-Za-z]{27}$`)
+	a, b := NewKSUID(), NewKSUID()
+	if !ksuid.MatchString(a) || a == b {
+		t.Errorf("unexpected IDs: %s, %s", a, b)
+	}
+
+	// decode the base62 digits and check the timestamp of the first 4 bytes
+	n := make([]byte, 20)
+	for _, c := range a {
+		carry := uint32(strings.IndexRune(base62Alphabet, c))
+		for i := len(n) - 1; i >= 0; i-- {
+			v := uint32(n[i])*62 + carry
+			n[i] = byte(v)
+			carry = v >> 8
+		}
+	}
+	ts := time.Unix(int64(binary.BigEndian.Uint32(n[:4]))+ksuidEpoch, 0)
+	if d := time.Since(ts); d < 0 || d > 2*time.Second {
+		t.Errorf("unexpected timestamp: %s", ts)
+	}
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	a := NewUUIDv7()
+	time.Sleep(2 * time.Millisecond)
+	b := NewUUIDv7()
+	if a >= b {
+		t.Errorf("the IDs are not sorted by their creation time: %s, %s", a, b)
+	}
+}
@@ -0,0 +1,223 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// ResponseHeadersNamespace is the key to look for the response header options in the extra config of the
+// service and the endpoints
+const ResponseHeadersNamespace = "github.com/devopsfaith/krakend/router/response_headers"
+
+// Default values of the security headers preset
+const (
+	DefaultHSTSMaxAge            = 365 * 24 * time.Hour
+	DefaultContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+	DefaultReferrerPolicy        = "no-referrer"
+	DefaultFrameOptions          = "DENY"
+)
+
+// ResponseHeadersConfig defines the transformations of the headers returned to the clients. The options of
+// an endpoint replace the ones of the service
+type ResponseHeadersConfig struct {
+	// Disabled disables the options of the service for the endpoint
+	Disabled bool `json:"disabled"`
+	// SecurityHeaders enables the security headers preset
+	SecurityHeaders *SecurityHeadersConfig `json:"security_headers"`
+	// Rules are applied in order, after the security headers preset. The add and set actions use the
+	// literal values
+	Rules []proxy.HeaderRule `json:"rules"`
+}
+
+// SecurityHeadersConfig defines the values of the security headers preset. The empty options get their
+// default values
+type SecurityHeadersConfig struct {
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header. By default, a year
+	HSTSMaxAge string `json:"hsts_max_age"`
+	// HSTSIncludeSubdomains adds the includeSubDomains directive to the Strict-Transport-Security header
+	HSTSIncludeSubdomains bool `json:"hsts_include_subdomains"`
+	// HSTSPreload adds the preload directive to the Strict-Transport-Security header
+	HSTSPreload bool `json:"hsts_preload"`
+	// ContentSecurityPolicy is the value of the Content-Security-Policy header. By default, the
+	// DefaultContentSecurityPolicy
+	ContentSecurityPolicy string `json:"content_security_policy"`
+	// ReferrerPolicy is the value of the Referrer-Policy header. By default, the DefaultReferrerPolicy
+	ReferrerPolicy string `json:"referrer_policy"`
+	// FrameOptions is the value of the X-Frame-Options header. By default, the DefaultFrameOptions
+	FrameOptions string `json:"frame_options"`
+}
+
+// ResponseHeadersConfigGetter parses the response header options from the extra config. The second value
+// is false if they are not declared
+func ResponseHeadersConfigGetter(extra config.ExtraConfig) (ResponseHeadersConfig, bool, error) {
+	cfg := ResponseHeadersConfig{}
+	v, ok := extra[ResponseHeadersNamespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false, err
+	}
+	return cfg, true, nil
+}
+
+// ResponseHeaders transforms the headers of a response before they are sent to the client
+type ResponseHeaders func(http.Header)
+
+// NewEndpointResponseHeaders returns the ResponseHeaders of the endpoint, with its options or the ones of the
+// service. It returns nil if the response headers are not transformed for the endpoint
+func NewEndpointResponseHeaders(service config.ExtraConfig, endpoint *config.EndpointConfig) (ResponseHeaders, error) {
+	cfg, ok, err := ResponseHeadersConfigGetter(endpoint.ExtraConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if cfg, ok, err = ResponseHeadersConfigGetter(service); err != nil {
+			return nil, err
+		}
+	}
+	if !ok || cfg.Disabled {
+		return nil, nil
+	}
+	return NewResponseHeaders(cfg)
+}
+
+// NewResponseHeaders returns a ResponseHeaders adding the security headers preset, if enabled, and applying
+// the rules. The headers of the preset do not replace the ones already in the response
+func NewResponseHeaders(cfg ResponseHeadersConfig) (ResponseHeaders, error) {
+	preset := map[string]string{}
+	if s := cfg.SecurityHeaders; s != nil {
+		maxAge := DefaultHSTSMaxAge
+		if s.HSTSMaxAge != "" {
+			d, err := time.ParseDuration(s.HSTSMaxAge)
+			if err != nil {
+				return nil, err
+			}
+			maxAge = d
+		}
+		hsts := "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+		if s.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if s.HSTSPreload {
+			hsts += "; preload"
+		}
+		preset["Strict-Transport-Security"] = hsts
+		preset["X-Content-Type-Options"] = "nosniff"
+		preset["Content-Security-Policy"] = valueOrDefault(s.ContentSecurityPolicy, DefaultContentSecurityPolicy)
+		preset["Referrer-Policy"] = valueOrDefault(s.ReferrerPolicy, DefaultReferrerPolicy)
+		preset["X-Frame-Options"] = valueOrDefault(s.FrameOptions, DefaultFrameOptions)
+	}
+
+	rules := make([]proxy.HeaderRule, len(cfg.Rules))
+	for i, r := range cfg.Rules {
+		r.Name = http.CanonicalHeaderKey(r.Name)
+		r.To = http.CanonicalHeaderKey(r.To)
+		if r.Name == "" {
+			return nil, fmt.Errorf("the header rules require a name: %+v", r)
+		}
+		switch r.Action {
+		case proxy.HeaderActionAdd, proxy.HeaderActionSet, proxy.HeaderActionRemove:
+		case proxy.HeaderActionRename, proxy.HeaderActionCopy:
+			if r.To == "" {
+				return nil, fmt.Errorf("the %s header rules require a target header: %+v", r.Action, r)
+			}
+		default:
+			return nil, fmt.Errorf("unknown header rule action: %s", r.Action)
+		}
+		rules[i] = r
+	}
+
+	return func(h http.Header) {
+		for k, v := range preset {
+			if _, ok := h[k]; !ok {
+				h[k] = []string{v}
+			}
+		}
+		for _, r := range rules {
+			switch r.Action {
+			case proxy.HeaderActionAdd:
+				h[r.Name] = append(h[r.Name], r.Value)
+			case proxy.HeaderActionSet:
+				h[r.Name] = []string{r.Value}
+			case proxy.HeaderActionRemove:
+				delete(h, r.Name)
+			case proxy.HeaderActionRename:
+				if values, ok := h[r.Name]; ok {
+					delete(h, r.Name)
+					h[r.To] = values
+				}
+			case proxy.HeaderActionCopy:
+				if values, ok := h[r.Name]; ok {
+					h[r.To] = append([]string{}, values...)
+				}
+			}
+		}
+	}, nil
+}
+
+func valueOrDefault(v, d string) string {
+	if v == "" {
+		return d
+	}
+	return v
+}
+
+// ResponseHeadersHandler decorates the handler, transforming the headers of its responses just before they
+// are sent. The websocket handshakes are served untouched
+func ResponseHeadersHandler(rh ResponseHeaders, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rw := &responseHeadersWriter{ResponseWriter: w, apply: rh}
+		next.ServeHTTP(rw, r)
+		// the responses without a body are sent after the handler returns
+		rw.applyOnce()
+	})
+}
+
+// responseHeadersWriter applies the transformations to the headers the first time the response is written
+type responseHeadersWriter struct {
+	http.ResponseWriter
+	apply ResponseHeaders
+	once  sync.Once
+}
+
+func (w *responseHeadersWriter) WriteHeader(code int) {
+	w.applyOnce()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseHeadersWriter) Write(b []byte) (int, error) {
+	w.applyOnce()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseHeadersWriter) Flush() {
+	w.applyOnce()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the decorated writer, so the http.ResponseController can reach it
+func (w *responseHeadersWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseHeadersWriter) applyOnce() {
+	w.once.Do(func() { w.apply(w.ResponseWriter.Header()) })
+}
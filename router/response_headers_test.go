@@ -0,0 +1,96 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestResponseHeadersHandler(t *testing.T) {
+	rh, err := NewResponseHeaders(ResponseHeadersConfig{
+		SecurityHeaders: &SecurityHeadersConfig{
+			HSTSMaxAge:            "24h",
+			HSTSIncludeSubdomains: true,
+			ReferrerPolicy:        "same-origin",
+		},
+		Rules: []proxy.HeaderRule{
+			{Action: "set", Name: "x-frame-options", Value: "SAMEORIGIN"},
+			{Action: "add", Name: "Vary", Value: "Accept"},
+			{Action: "rename", Name: "X-Backend", To: "X-Upstream"},
+			{Action: "remove", Name: "Server"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := ResponseHeadersHandler(rh, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'")
+		w.Header().Set("Vary", "Origin")
+		w.Header().Set("X-Backend", "supu")
+		w.Header().Set("Server", "tupu")
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	for k, v := range map[string]string{
+		"Strict-Transport-Security": "max-age=86400; includeSubDomains",
+		"X-Content-Type-Options":    "nosniff",
+		"Content-Security-Policy":   "default-src 'self'",
+		"Referrer-Policy":           "same-origin",
+		"X-Frame-Options":           "SAMEORIGIN",
+		"X-Upstream":                "supu",
+		"X-Backend":                 "",
+		"Server":                    "",
+	} {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("unexpected %s header: %q", k, got)
+		}
+	}
+	if vary := w.Header().Values("Vary"); len(vary) != 2 || vary[1] != "Accept" {
+		t.Errorf("unexpected Vary header: %v", vary)
+	}
+}
+
+func TestResponseHeadersHandler_noBody(t *testing.T) {
+	rh, err := NewResponseHeaders(ResponseHeadersConfig{SecurityHeaders: &SecurityHeadersConfig{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := ResponseHeadersHandler(rh, http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=31536000" {
+		t.Errorf("unexpected Strict-Transport-Security header: %q", got)
+	}
+	if got := w.Header().Get("Content-Security-Policy"); got != DefaultContentSecurityPolicy {
+		t.Errorf("unexpected Content-Security-Policy header: %q", got)
+	}
+}
+
+func TestNewEndpointResponseHeaders(t *testing.T) {
+	service := config.ExtraConfig{ResponseHeadersNamespace: map[string]interface{}{"security_headers": map[string]interface{}{}}}
+
+	rh, err := NewEndpointResponseHeaders(service, &config.EndpointConfig{})
+	if err != nil || rh == nil {
+		t.Errorf("unexpected result: %v", err)
+	}
+
+	rh, err = NewEndpointResponseHeaders(service, &config.EndpointConfig{ExtraConfig: config.ExtraConfig{
+		ResponseHeadersNamespace: map[string]interface{}{"disabled": true},
+	}})
+	if err != nil || rh != nil {
+		t.Errorf("unexpected result: %v", err)
+	}
+
+	if _, err = NewEndpointResponseHeaders(nil, &config.EndpointConfig{ExtraConfig: config.ExtraConfig{
+		ResponseHeadersNamespace: map[string]interface{}{"rules": []interface{}{map[string]interface{}{"action": "copy", "name": "X-Supu"}}},
+	}}); err == nil {
+		t.Error("expecting an error with a copy rule without target")
+	}
+}
@@ -31,12 +31,20 @@ type Factory interface {
 // ToHTTPError translates an error into a HTTP status code
 type ToHTTPError func(error) int
 
-// DefaultToHTTPError is a ToHTTPError transalator that always returns an
-// internal server error
-func DefaultToHTTPError(_ error) int {
+// DefaultToHTTPError is a ToHTTPError transalator that returns the status code of the
+// errors declaring it and an internal server error for the rest of them
+func DefaultToHTTPError(err error) int {
+	if e, ok := err.(statusCodeError); ok {
+		return e.StatusCode()
+	}
 	return http.StatusInternalServerError
 }
 
+// statusCodeError is implemented by the errors declaring the status code to return to the client
+type statusCodeError interface {
+	StatusCode() int
+}
+
 var (
 	// HeadersToSend are the headers to pass from the router request to the proxy
 	HeadersToSend = []string{"Content-Type"}
@@ -44,4 +52,28 @@ var (
 	UserAgentHeaderValue = []string{core.KrakendUserAgent}
 	// ErrInternalError is the error returned by the router when something went wrong
 	ErrInternalError = errors.New("internal server error")
+	// ErrTooManyRequests is the error returned by the router when the request exceeds the rate limit
+	ErrTooManyRequests = errors.New("too many requests")
 )
+
+// QueryParams returns the query string params of the request to pass to the proxy. The endpoints with the
+// config.QueryStringWildcard pass all the params with all their values, and the rest only the first value
+// of the declared ones
+func QueryParams(r *http.Request, queryString []string) map[string][]string {
+	values := r.URL.Query()
+	query := make(map[string][]string, len(queryString))
+	for _, k := range queryString {
+		if k == config.QueryStringWildcard {
+			for k, vs := range values {
+				query[k] = vs
+			}
+			return query
+		}
+	}
+	for _, k := range queryString {
+		if v := values.Get(k); v != "" {
+			query[k] = []string{v}
+		}
+	}
+	return query
+}
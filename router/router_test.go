@@ -0,0 +1,21 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type dummyStatusCodeError int
+
+func (d dummyStatusCodeError) Error() string   { return "dummy" }
+func (d dummyStatusCodeError) StatusCode() int { return int(d) }
+
+func TestDefaultToHTTPError(t *testing.T) {
+	if code := DefaultToHTTPError(errors.New("dummy")); code != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", code)
+	}
+	if code := DefaultToHTTPError(dummyStatusCodeError(http.StatusServiceUnavailable)); code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status code: %d", code)
+	}
+}
@@ -0,0 +1,144 @@
+package router
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+)
+
+// ReadinessPattern is the path of the endpoint reporting if the service accepts traffic
+const ReadinessPattern = "/__ready"
+
+// DefaultDrainTimeout is the default max time to wait for the requests in flight on shutdown
+const DefaultDrainTimeout = 20 * time.Second
+
+// ShutdownHookTimeout is the max time given to the shutdown hooks
+const ShutdownHookTimeout = 5 * time.Second
+
+// ShutdownHook is called once the servers are drained, so the components buffering data (like the
+// telemetry exporters) can flush it before the process exits
+type ShutdownHook func(ctx context.Context) error
+
+var (
+	shutdownHooks   = []ShutdownHook{}
+	shutdownHooksMu = &sync.Mutex{}
+)
+
+// RegisterShutdownHook registers a hook to call when the service shuts down
+func RegisterShutdownHook(h ShutdownHook) error {
+	shutdownHooksMu.Lock()
+	shutdownHooks = append(shutdownHooks, h)
+	shutdownHooksMu.Unlock()
+	return nil
+}
+
+// NotifyShutdown returns a copy of the context canceled when the process receives a SIGINT or a SIGTERM, so
+// it can be used as the context of the routers to drain them instead of dropping the requests in flight
+func NotifyShutdown(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Lifecycle coordinates the shutdown of the servers of a router. It reports the health, the readiness and the
+// liveness of the service and provides the base context of the requests, canceled when they must be aborted
+type Lifecycle struct {
+	draining int32
+	ctx      context.Context
+	cancel   context.CancelFunc
+	started  time.Time
+
+	configMu       sync.RWMutex
+	configVersion  int
+	configLoadedAt time.Time
+}
+
+// NewLifecycle returns a Lifecycle of a service ready to receive traffic
+func NewLifecycle() *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{ctx: ctx, cancel: cancel, started: time.Now()}
+}
+
+// BaseContext returns the context of the requests accepted by the listener, canceled when the drain timeout
+// expires. It can be used as the BaseContext function of an http.Server
+func (l *Lifecycle) BaseContext(_ net.Listener) context.Context {
+	return l.ctx
+}
+
+// IsReady returns false once the shutdown has started
+func (l *Lifecycle) IsReady() bool {
+	return atomic.LoadInt32(&l.draining) == 0
+}
+
+// Shutdown drains the servers. It fails the readiness check, waits for the shutdown delay of the service, so
+// the load balancers stop sending traffic, and then stops accepting connections and waits for the requests
+// in flight. When the drain timeout expires, the contexts of the remaining requests are canceled, aborting
+// their backend calls, and their connections are closed. Finally, the shutdown hooks are called
+func (l *Lifecycle) Shutdown(cfg config.ServiceConfig, logger logging.Logger, servers ...*http.Server) {
+	atomic.StoreInt32(&l.draining, 1)
+	if cfg.ShutdownDelay > 0 {
+		logger.Info("Failing the readiness check for", cfg.ShutdownDelay.String(), "before draining")
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	timeout := cfg.DrainTimeout
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	logger.Info("Draining the requests in flight")
+	wg := &sync.WaitGroup{}
+	for _, s := range servers {
+		if s == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(s *http.Server) {
+			defer wg.Done()
+			if err := s.Shutdown(ctx); err != nil {
+				logger.Warning("The drain timeout expired, canceling the requests in flight:", err.Error())
+				l.cancel()
+				s.Close()
+			}
+		}(s)
+	}
+	wg.Wait()
+	l.cancel()
+
+	runShutdownHooks(logger)
+}
+
+func runShutdownHooks(logger logging.Logger) {
+	shutdownHooksMu.Lock()
+	hooks := make([]ShutdownHook, len(shutdownHooks))
+	copy(hooks, shutdownHooks)
+	shutdownHooksMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownHookTimeout)
+	defer cancel()
+	for _, h := range hooks {
+		if err := h(ctx); err != nil {
+			logger.Error("running a shutdown hook:", err.Error())
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+)
+
+func noopLogger() logging.Logger {
+	logger, _ := logging.NewLogger("CRITICAL", ioutil.Discard, "")
+	return logger
+}
+
+func TestLifecycle_ReadinessHandler(t *testing.T) {
+	l := NewLifecycle()
+
+	w := httptest.NewRecorder()
+	l.ReadinessHandler(w, httptest.NewRequest("GET", ReadinessPattern, nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"ready"}` {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	l.Shutdown(config.ServiceConfig{}, noopLogger())
+
+	w = httptest.NewRecorder()
+	l.ReadinessHandler(w, httptest.NewRequest("GET", ReadinessPattern, nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != `{"status":"draining"}` {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+	if l.BaseContext(nil).Err() == nil {
+		t.Error("the base context must be canceled once the service is drained")
+	}
+}
+
+// startServer starts a server with the base context of the lifecycle, returning its URL
+func startServer(t *testing.T, l *Lifecycle, h http.Handler) (*http.Server, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &http.Server{Handler: h, BaseContext: l.BaseContext}
+	go s.Serve(ln)
+	return s, "http://" + ln.Addr().String()
+}
+
+func TestLifecycle_Shutdown(t *testing.T) {
+	var hooks int32
+	RegisterShutdownHook(func(_ context.Context) error {
+		atomic.AddInt32(&hooks, 1)
+		return nil
+	})
+
+	l := NewLifecycle()
+	started, release := make(chan struct{}), make(chan struct{})
+	s, url := startServer(t, l, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	}))
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Error(err)
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		l.Shutdown(config.ServiceConfig{DrainTimeout: time.Second}, noopLogger(), s, nil)
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if l.IsReady() {
+		t.Error("the readiness check must fail while draining")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("the new connections must be rejected while draining")
+	}
+	select {
+	case <-done:
+		t.Fatal("the shutdown must wait for the requests in flight")
+	default:
+	}
+
+	close(release)
+	if code := <-status; code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", code)
+	}
+	<-done
+	if atomic.LoadInt32(&hooks) == 0 {
+		t.Error("the shutdown hooks were not called")
+	}
+}
+
+func TestLifecycle_Shutdown_timeout(t *testing.T) {
+	l := NewLifecycle()
+	started, canceled := make(chan struct{}), make(chan error, 1)
+	s, url := startServer(t, l, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		canceled <- r.Context().Err()
+	}))
+	go http.Get(url)
+	<-started
+
+	buf := new(bytes.Buffer)
+	logger, _ := logging.NewLogger("WARNING", buf, "")
+	begin := time.Now()
+	l.Shutdown(config.ServiceConfig{DrainTimeout: 50 * time.Millisecond}, logger, s)
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("the drain timeout was not respected: %v", elapsed)
+	}
+
+	select {
+	case err := <-canceled:
+		if err == nil {
+			t.Error("the context of the request must be canceled")
+		}
+	case <-time.After(time.Second):
+		t.Error("the request in flight was not canceled")
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("The drain timeout expired")) {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+}
+
+func TestLifecycle_Shutdown_delay(t *testing.T) {
+	l := NewLifecycle()
+	begin := time.Now()
+	l.Shutdown(config.ServiceConfig{ShutdownDelay: 50 * time.Millisecond}, noopLogger())
+	if elapsed := time.Since(begin); elapsed < 50*time.Millisecond {
+		t.Errorf("the shutdown delay was not respected: %v", elapsed)
+	}
+}
+
+func TestNotifyShutdown(t *testing.T) {
+	ctx, cancel := NotifyShutdown(context.Background())
+	defer cancel()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Error("the context was not canceled")
+	}
+}
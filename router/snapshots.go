@@ -0,0 +1,99 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// SnapshotsPattern is the path of the endpoints exposing the snapshots of the service configuration:
+//
+// - GET SnapshotsPattern lists the kept snapshots and the current one
+//
+// - GET SnapshotsPattern/{id} returns the snapshot, with its rendered configuration
+//
+// - POST SnapshotsPattern/{id}/rollback rolls the service back to the configuration of the snapshot
+const SnapshotsPattern = "/__config/snapshots"
+
+// SnapshotsHandler returns a handler exposing the snapshots over an admin API. The rollbacks only call the
+// update function when the configuration of the snapshot is valid, so a broken snapshot never replaces the
+// running configuration. Register it for both the SnapshotsPattern and the SnapshotsPattern with a trailing
+// slash. The handler does not authenticate the requests and the snapshots contain the raw configurations, so
+// it must only be served by an authenticated listener, like the admin API, and never by the public engine
+func SnapshotsHandler(snapshots *config.Snapshots, update func(config.ServiceConfig)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, SnapshotsPattern), "/"), "/")
+		switch {
+		case parts[0] == "":
+			if r.Method != "GET" {
+				snapshotsMethodNotAllowed(w, "GET")
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"current":   snapshots.Current(),
+				"snapshots": snapshots.List(),
+			})
+
+		case len(parts) == 1:
+			if r.Method != "GET" {
+				snapshotsMethodNotAllowed(w, "GET")
+				return
+			}
+			id, err := strconv.Atoi(parts[0])
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			snapshot, err := snapshots.Get(id)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, http.StatusOK, snapshot)
+
+		case len(parts) == 2 && parts[1] == "rollback":
+			if r.Method != "POST" {
+				snapshotsMethodNotAllowed(w, "POST")
+				return
+			}
+			id, err := strconv.Atoi(parts[0])
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			cfg, err := snapshots.Rollback(id)
+			if err == config.ErrUnknownSnapshot {
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			update(cfg)
+			w.WriteHeader(http.StatusAccepted)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func snapshotsMethodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
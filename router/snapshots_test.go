@@ -0,0 +1,66 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestSnapshotsHandler(t *testing.T) {
+	snapshots, _ := config.NewSnapshots(0, "")
+	snapshots.Add("a.json", []byte(`{"version":2,"port":8080,"endpoints":[{"endpoint":"/a","backend":[{"host":["http://127.0.0.1"],"url_pattern":"/a"}]}]}`))
+	snapshots.Add("a.json", []byte(`{"version":2,"port":8081,"endpoints":[{"endpoint":"/a","backend":[]}]}`))
+
+	updates := []config.ServiceConfig{}
+	handler := SnapshotsHandler(snapshots, func(cfg config.ServiceConfig) { updates = append(updates, cfg) })
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", SnapshotsPattern, http.StatusOK},
+		{"GET", SnapshotsPattern + "/", http.StatusOK},
+		{"POST", SnapshotsPattern, http.StatusMethodNotAllowed},
+		{"GET", SnapshotsPattern + "/1", http.StatusOK},
+		{"GET", SnapshotsPattern + "/42", http.StatusNotFound},
+		{"GET", SnapshotsPattern + "/a", http.StatusNotFound},
+		{"GET", SnapshotsPattern + "/1/rollback", http.StatusMethodNotAllowed},
+		{"POST", SnapshotsPattern + "/42/rollback", http.StatusNotFound},
+		{"POST", SnapshotsPattern + "/2/rollback", http.StatusUnprocessableEntity},
+		{"POST", SnapshotsPattern + "/1/unknown", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.status {
+			t.Errorf("%s %s: unexpected status code: %d", tc.method, tc.path, w.Code)
+		}
+	}
+	if len(updates) != 0 {
+		t.Fatalf("unexpected updates: %v", updates)
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", SnapshotsPattern+"/1/rollback", nil))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	if len(updates) != 1 || updates[0].Port != 8080 {
+		t.Errorf("unexpected updates: %v", updates)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", SnapshotsPattern, nil))
+	var list struct {
+		Current   int               `json:"current"`
+		Snapshots []config.Snapshot `json:"snapshots"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Current != 1 || len(list.Snapshots) != 2 {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+}
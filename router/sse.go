@@ -0,0 +1,145 @@
+package router
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// SSEContentType is the content type of the server-sent events streams
+const SSEContentType = "text/event-stream"
+
+// SSENamespace is the key to look for the server-sent events options in the extra config of the endpoints
+const SSENamespace = "github.com/devopsfaith/krakend/router/sse"
+
+// DefaultSSEHeartbeat is the default time without events before sending a heartbeat to the client
+const DefaultSSEHeartbeat = 15 * time.Second
+
+var sseHeartbeat = []byte(": heartbeat\n")
+
+// SSEConfig defines the streaming of the server-sent events of an endpoint
+type SSEConfig struct {
+	// Heartbeat is the time without events before sending a heartbeat comment to the client. A zero value
+	// disables the heartbeats
+	Heartbeat time.Duration
+}
+
+// SSEConfigGetter parses the server-sent events options of an endpoint. The second value is false if the
+// endpoint does not declare them, but the returned config always has the default values
+func SSEConfigGetter(extra config.ExtraConfig) (SSEConfig, bool) {
+	cfg := SSEConfig{Heartbeat: DefaultSSEHeartbeat}
+	v, ok := extra[SSENamespace]
+	if !ok {
+		return cfg, false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false
+	}
+	tmp := struct {
+		Heartbeat string `json:"heartbeat"`
+	}{}
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return cfg, false
+	}
+	if d, err := time.ParseDuration(tmp.Heartbeat); err == nil {
+		cfg.Heartbeat = d
+	}
+	return cfg, true
+}
+
+// SetSSEHeaders sets the headers of a server-sent events response, disabling the caches and the buffering
+// of the intermediaries
+func SetSSEHeaders(h http.Header) {
+	h.Set("Content-Type", SSEContentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+}
+
+// StreamEvents copies the server-sent events stream of the reader into the writer without buffering it,
+// flushing the writer after every line (if it supports it). When no line is received for the heartbeat
+// interval, a comment is sent to the client, so the intermediaries keep the connection open and the
+// disconnected clients are detected. The comments are ignored by the clients, even in the middle of an event
+func StreamEvents(w io.Writer, r io.Reader, heartbeat time.Duration) error {
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	type chunk struct {
+		line []byte
+		err  error
+	}
+	lines := make(chan chunk)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadBytes('\n')
+			select {
+			case lines <- chunk{line, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// the headers are sent before the first event
+	flush()
+
+	var timer *time.Timer
+	var ticks <-chan time.Time
+	if heartbeat > 0 {
+		timer = time.NewTimer(heartbeat)
+		defer timer.Stop()
+		ticks = timer.C
+	}
+	for {
+		select {
+		case c := <-lines:
+			if err := writeChunk(w, c.line, flush); err != nil {
+				return err
+			}
+			if c.err == io.EOF {
+				return nil
+			}
+			if c.err != nil {
+				return c.err
+			}
+		case <-ticks:
+			if err := writeChunk(w, sseHeartbeat, flush); err != nil {
+				return err
+			}
+		}
+		if timer != nil {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(heartbeat)
+		}
+	}
+}
+
+func writeChunk(w io.Writer, b []byte, flush func()) error {
+	if len(b) == 0 {
+		return nil
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	flush()
+	return nil
+}
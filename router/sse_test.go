@@ -0,0 +1,56 @@
+package router
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestSSEConfigGetter(t *testing.T) {
+	cfg, ok := SSEConfigGetter(config.ExtraConfig{})
+	if ok || cfg.Heartbeat != DefaultSSEHeartbeat {
+		t.Errorf("unexpected config: %v %v", cfg, ok)
+	}
+	cfg, ok = SSEConfigGetter(config.ExtraConfig{SSENamespace: map[string]interface{}{"heartbeat": "0s"}})
+	if !ok || cfg.Heartbeat != 0 {
+		t.Errorf("unexpected config: %v %v", cfg, ok)
+	}
+	cfg, _ = SSEConfigGetter(config.ExtraConfig{SSENamespace: map[string]interface{}{"heartbeat": "1m"}})
+	if cfg.Heartbeat != time.Minute {
+		t.Errorf("unexpected config: %v", cfg)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := StreamEvents(w, strings.NewReader("data: 1\n\ndata: 2\n\n"), 0); err != nil {
+		t.Error(err)
+	}
+	if !w.Flushed || w.Body.String() != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("unexpected body: %q", w.Body.String())
+	}
+}
+
+func TestStreamEvents_heartbeat(t *testing.T) {
+	pr, pw := io.Pipe()
+	w := httptest.NewRecorder()
+	done := make(chan error)
+	go func() { done <- StreamEvents(w, pr, 10*time.Millisecond) }()
+
+	pw.Write([]byte("data: 1\n"))
+	time.Sleep(35 * time.Millisecond)
+	pw.Write([]byte("\n"))
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "data: 1\n: heartbeat\n") || !strings.HasSuffix(body, ": heartbeat\n\n") {
+		t.Errorf("unexpected body: %q", body)
+	}
+}
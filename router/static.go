@@ -0,0 +1,188 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// StaticNamespace is the key to look for the static directories in the extra config of the service
+const StaticNamespace = "github.com/devopsfaith/krakend/router/static"
+
+// DefaultStaticIndex is the file served for the directories and as the fallback of the single page apps
+const DefaultStaticIndex = "index.html"
+
+// ErrNoStaticDirectory is the error returned when a static mount does not declare its directory
+var ErrNoStaticDirectory = errors.New("static: the directory is required")
+
+// StaticConfig defines a directory served on a path prefix
+type StaticConfig struct {
+	// Prefix is the path prefix of the files. By default, '/'
+	Prefix string `json:"prefix"`
+	// Directory is the directory of the files
+	Directory string `json:"directory"`
+	// Index is the file served for the directories. By default, the DefaultStaticIndex
+	Index string `json:"index"`
+	// SPA serves the index of the directory for the missing paths without an extension, so the routes of a
+	// single page app are resolved by its scripts
+	SPA bool `json:"spa"`
+	// MaxAge is the time the files can be cached by the clients. The index files are always revalidated
+	MaxAge string `json:"max_age"`
+}
+
+// StaticConfigGetter parses the static directories from the extra config of the service. The second value
+// is false if there are none
+func StaticConfigGetter(extra config.ExtraConfig) ([]StaticConfig, bool, error) {
+	v, ok := extra[StaticNamespace]
+	if !ok {
+		return nil, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, false, err
+	}
+	cfgs := []StaticConfig{}
+	if err := json.Unmarshal(b, &cfgs); err != nil {
+		return nil, false, err
+	}
+	for i := range cfgs {
+		if cfgs[i].Directory == "" {
+			return nil, false, ErrNoStaticDirectory
+		}
+		cfgs[i].Prefix = "/" + strings.Trim(cfgs[i].Prefix, "/")
+		if cfgs[i].Prefix != "/" {
+			cfgs[i].Prefix += "/"
+		}
+		if cfgs[i].Index == "" {
+			cfgs[i].Index = DefaultStaticIndex
+		}
+	}
+	return cfgs, len(cfgs) > 0, nil
+}
+
+// NewStaticHandler returns a handler serving the files of the directory for the paths under the prefix. The
+// range and conditional requests are supported, and the directories are not listed
+func NewStaticHandler(cfg StaticConfig) (http.Handler, error) {
+	cacheControl := "no-cache"
+	if cfg.MaxAge != "" {
+		d, err := time.ParseDuration(cfg.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+		cacheControl = fmt.Sprintf("public, max-age=%d", int(d.Seconds()))
+	}
+	if info, err := os.Stat(cfg.Directory); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("static: %s is not a directory", cfg.Directory)
+	}
+	s := &staticHandler{
+		cfg:          cfg,
+		dir:          http.Dir(cfg.Directory),
+		cacheControl: cacheControl,
+	}
+	return http.StripPrefix(strings.TrimSuffix(cfg.Prefix, "/"), s), nil
+}
+
+// StaticHandler returns a handler serving the static directories declared at the extra config of the
+// service, by the longest matching prefix, and responding with a 404 to the rest of the requests. It
+// returns nil if there are no static directories
+func StaticHandler(extra config.ExtraConfig) (http.Handler, error) {
+	cfgs, ok, err := StaticConfigGetter(extra)
+	if err != nil || !ok {
+		return nil, err
+	}
+	prefixes := make([]string, len(cfgs))
+	handlers := map[string]http.Handler{}
+	for i, cfg := range cfgs {
+		h, err := NewStaticHandler(cfg)
+		if err != nil {
+			return nil, err
+		}
+		prefixes[i] = cfg.Prefix
+		handlers[cfg.Prefix] = h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := ""
+		for _, prefix := range prefixes {
+			if (strings.HasPrefix(r.URL.Path, prefix) || r.URL.Path+"/" == prefix) && len(prefix) > len(match) {
+				match = prefix
+			}
+		}
+		if match == "" {
+			http.NotFound(w, r)
+			return
+		}
+		handlers[match].ServeHTTP(w, r)
+	}), nil
+}
+
+type staticHandler struct {
+	cfg          StaticConfig
+	dir          http.Dir
+	cacheControl string
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requested := path.Clean("/" + r.URL.Path)
+	// the hidden files and directories are never served
+	if strings.Contains(requested, "/.") {
+		http.NotFound(w, r)
+		return
+	}
+	name := requested
+	f, info, err := s.open(name)
+	if err == nil && info.IsDir() {
+		f.Close()
+		name = path.Join(name, s.cfg.Index)
+		f, info, err = s.open(name)
+	}
+	if err != nil && s.cfg.SPA && path.Ext(requested) == "" {
+		name = "/" + s.cfg.Index
+		f, info, err = s.open(name)
+	}
+	if err != nil || info.IsDir() {
+		if f != nil {
+			f.Close()
+		}
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	if path.Base(name) == s.cfg.Index {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", s.cacheControl)
+	}
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	http.ServeContent(w, r, filepath.Base(name), info.ModTime(), f)
+}
+
+func (s *staticHandler) open(name string) (http.File, os.FileInfo, error) {
+	f, err := s.dir.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
@@ -0,0 +1,157 @@
+package router
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func newStaticDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "krakend_static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"index.html":         "<html>app</html>",
+		"assets/app.js":      "console.log('app')",
+		"docs/index.html":    "<html>docs</html>",
+		"docs/guide.txt":     "0123456789",
+		".env":               "SECRET=supu",
+		"empty/.placeholder": "",
+	} {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestStaticConfigGetter(t *testing.T) {
+	cfgs, ok, err := StaticConfigGetter(config.ExtraConfig{StaticNamespace: []interface{}{
+		map[string]interface{}{"directory": "./public"},
+		map[string]interface{}{"directory": "./docs", "prefix": "docs", "index": "README.html"},
+	}})
+	if err != nil || !ok {
+		t.Fatalf("unexpected result: %v %v", ok, err)
+	}
+	if cfgs[0].Prefix != "/" || cfgs[0].Index != DefaultStaticIndex {
+		t.Errorf("unexpected defaults: %+v", cfgs[0])
+	}
+	if cfgs[1].Prefix != "/docs/" || cfgs[1].Index != "README.html" {
+		t.Errorf("unexpected config: %+v", cfgs[1])
+	}
+
+	if _, ok, err := StaticConfigGetter(config.ExtraConfig{}); ok || err != nil {
+		t.Errorf("unexpected result: %v %v", ok, err)
+	}
+	if _, _, err := StaticConfigGetter(config.ExtraConfig{StaticNamespace: []interface{}{map[string]interface{}{}}}); err != ErrNoStaticDirectory {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStaticHandler(t *testing.T) {
+	dir := newStaticDir(t)
+	defer os.RemoveAll(dir)
+
+	h, err := StaticHandler(config.ExtraConfig{StaticNamespace: []interface{}{
+		map[string]interface{}{"directory": dir, "prefix": "/app", "spa": true, "max_age": "1h"},
+		map[string]interface{}{"directory": filepath.Join(dir, "docs"), "prefix": "/app/docs"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path         string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{path: "/app/", status: http.StatusOK, body: "<html>app</html>", cacheControl: "no-cache"},
+		{path: "/app", status: http.StatusOK, body: "<html>app</html>", cacheControl: "no-cache"},
+		{path: "/app/assets/app.js", status: http.StatusOK, body: "console.log('app')", cacheControl: "public, max-age=3600"},
+		{path: "/app/users/42", status: http.StatusOK, body: "<html>app</html>", cacheControl: "no-cache"},
+		{path: "/app/assets/missing.js", status: http.StatusNotFound},
+		{path: "/app/docs/", status: http.StatusOK, body: "<html>docs</html>", cacheControl: "no-cache"},
+		{path: "/app/docs/guide.txt", status: http.StatusOK, body: "0123456789", cacheControl: "no-cache"},
+		{path: "/app/docs/missing", status: http.StatusNotFound},
+		{path: "/app/.env", status: http.StatusNotFound},
+		{path: "/app/docs/../../../etc/passwd.txt", status: http.StatusNotFound},
+		{path: "/other", status: http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		if w.Code != tc.status {
+			t.Errorf("%s: unexpected status code: %d", tc.path, w.Code)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		if w.Body.String() != tc.body {
+			t.Errorf("%s: unexpected body: %s", tc.path, w.Body.String())
+		}
+		if w.Header().Get("Cache-Control") != tc.cacheControl {
+			t.Errorf("%s: unexpected cache control: %s", tc.path, w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestStaticHandler_ranges(t *testing.T) {
+	dir := newStaticDir(t)
+	defer os.RemoveAll(dir)
+
+	h, err := NewStaticHandler(StaticConfig{Prefix: "/", Directory: dir, Index: DefaultStaticIndex})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/docs/guide.txt", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	etag := w.Header().Get("ETag")
+	req = httptest.NewRequest("GET", "/docs/guide.txt", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/docs/guide.txt", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/empty/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("the directories must not be listed: %d", w.Code)
+	}
+}
+
+func TestNewStaticHandler_ko(t *testing.T) {
+	if _, err := NewStaticHandler(StaticConfig{Directory: "/unknown/directory"}); err == nil {
+		t.Error("error expected")
+	}
+	dir := newStaticDir(t)
+	defer os.RemoveAll(dir)
+	if _, err := NewStaticHandler(StaticConfig{Directory: dir, MaxAge: "forever"}); err == nil {
+		t.Error("error expected")
+	}
+}
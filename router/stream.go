@@ -0,0 +1,34 @@
+package router
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+)
+
+// NDJSONContentType is the content type of the newline delimited json responses
+const NDJSONContentType = "application/x-ndjson"
+
+// StreamLines copies the content of the reader into the writer line by line, flushing the writer
+// after every line (if it supports it), so the client receives every item as soon as it is available
+func StreamLines(w io.Writer, r io.Reader) error {
+	flusher, _ := w.(http.Flusher)
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := w.Write(line); werr != nil {
+				return werr
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
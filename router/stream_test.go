@@ -0,0 +1,36 @@
+package router
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStreamLines(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := StreamLines(w, strings.NewReader("{\"id\":1}\n{\"id\":2}\n{\"id\":3}")); err != nil {
+		t.Error(err)
+		return
+	}
+	if body := w.Body.String(); body != "{\"id\":1}\n{\"id\":2}\n{\"id\":3}" {
+		t.Errorf("unexpected body: %s", body)
+	}
+	if !w.Flushed {
+		t.Error("the writer should be flushed")
+	}
+}
+
+func TestStreamLines_ko(t *testing.T) {
+	errExpected := errors.New("expected")
+	w := httptest.NewRecorder()
+	if err := StreamLines(w, erroredReader{errExpected}); err != errExpected {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+type erroredReader struct {
+	err error
+}
+
+func (r erroredReader) Read(_ []byte) (int, error) { return 0, r.err }
@@ -0,0 +1,256 @@
+package router
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+)
+
+// DefaultCertificatesReloadInterval is the default time between two checks of the files of the certificates
+const DefaultCertificatesReloadInterval = time.Minute
+
+// ErrNoCertificates is the error returned when the TLS settings do not declare any key pair
+var ErrNoCertificates = errors.New("tls: no certificates")
+
+var tlsVersions = map[string]uint16{
+	"SSL3.0": tls.VersionSSL30,
+	"TLS10":  tls.VersionTLS10,
+	"TLS11":  tls.VersionTLS11,
+	"TLS12":  tls.VersionTLS12,
+	"TLS13":  tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// NewTLSConfig returns the TLS config of the server and the store of its certificates. Both of them are nil
+// when the TLS settings are missing or disabled
+func NewTLSConfig(cfg *config.TLS) (*tls.Config, *CertificateStore, error) {
+	if cfg == nil || cfg.IsDisabled {
+		return nil, nil, nil
+	}
+	store, err := NewCertificateStore(cfg.Keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig := &tls.Config{
+		GetCertificate:           store.GetCertificate,
+		PreferServerCipherSuites: cfg.PreferServerCipherSuites,
+		CipherSuites:             cfg.CipherSuites,
+	}
+	for _, c := range cfg.CurvePreferences {
+		tlsConfig.CurvePreferences = append(tlsConfig.CurvePreferences, tls.CurveID(c))
+	}
+	if tlsConfig.MinVersion, err = parseTLSVersion(cfg.MinVersion); err != nil {
+		return nil, nil, err
+	}
+	if tlsConfig.MaxVersion, err = parseTLSVersion(cfg.MaxVersion); err != nil {
+		return nil, nil, err
+	}
+	if tlsConfig.ClientCAs, err = loadCertPool(cfg.ClientCAs); err != nil {
+		return nil, nil, err
+	}
+	if tlsConfig.ClientAuth, err = parseClientAuth(cfg.ClientAuth, tlsConfig.ClientCAs != nil); err != nil {
+		return nil, nil, err
+	}
+	return tlsConfig, store, nil
+}
+
+// loadCertPool returns a pool with the certificates of the PEM files, or nil if there are no files
+func loadCertPool(files []string) (*x509.CertPool, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("tls: no certificates in %s", f)
+		}
+	}
+	return pool, nil
+}
+
+func parseClientAuth(v string, hasCAs bool) (tls.ClientAuthType, error) {
+	if v == "" {
+		if hasCAs {
+			return tls.VerifyClientCertIfGiven, nil
+		}
+		return tls.NoClientCert, nil
+	}
+	clientAuth, ok := clientAuthTypes[v]
+	if !ok {
+		return tls.NoClientCert, fmt.Errorf("tls: unknown client auth %s", v)
+	}
+	if !hasCAs && (clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert) {
+		return tls.NoClientCert, fmt.Errorf("tls: the client auth %s requires the client CAs", v)
+	}
+	return clientAuth, nil
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	if v == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[v]
+	if !ok {
+		return 0, fmt.Errorf("tls: unknown version %s", v)
+	}
+	return version, nil
+}
+
+// ConfigureTLS sets the TLS config of the server when the service enables TLS, and watches the files of its
+// certificates until the context is canceled, reloading them when they change
+func ConfigureTLS(ctx context.Context, s *http.Server, cfg config.ServiceConfig, logger logging.Logger) error {
+	tlsConfig, store, err := NewTLSConfig(cfg.TLS)
+	if err != nil || tlsConfig == nil {
+		return err
+	}
+	s.TLSConfig = tlsConfig
+	interval := cfg.TLS.ReloadInterval
+	if interval == 0 {
+		interval = DefaultCertificatesReloadInterval
+	}
+	go store.Watch(ctx, interval, logger)
+	return nil
+}
+
+// CertificateStore keeps the certificates of the server, selecting them by the server name requested by the
+// clients (SNI). The first certificate is the default one
+type CertificateStore struct {
+	keys []config.TLSKeyPair
+
+	mu      sync.RWMutex
+	certs   []*tls.Certificate
+	names   map[string]*tls.Certificate
+	version string
+}
+
+// NewCertificateStore returns a store with the certificates of the key pairs
+func NewCertificateStore(keys []config.TLSKeyPair) (*CertificateStore, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoCertificates
+	}
+	s := &CertificateStore{keys: keys}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCertificate returns the certificate for the server name of the handshake, matching the wildcard names
+// too. It returns the default certificate if none of them matches it. It can be used as the GetCertificate
+// function of a tls.Config
+func (s *CertificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if cert, ok := s.names[name]; ok {
+		return cert, nil
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		if cert, ok := s.names["*"+name[i:]]; ok {
+			return cert, nil
+		}
+	}
+	return s.certs[0], nil
+}
+
+// Reload loads the key pairs again. The current certificates are kept if any of them fails
+func (s *CertificateStore) Reload() error {
+	// the version is taken before loading the files, so the changes made while loading them are not missed
+	version := s.filesVersion()
+	certs := make([]*tls.Certificate, len(s.keys))
+	names := map[string]*tls.Certificate{}
+	for i, k := range s.keys {
+		cert, err := tls.LoadX509KeyPair(k.PublicKey, k.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("tls: loading the key pair %s: %s", k.PublicKey, err.Error())
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("tls: parsing the certificate %s: %s", k.PublicKey, err.Error())
+		}
+		cert.Leaf = leaf
+		certs[i] = &cert
+
+		certNames := leaf.DNSNames
+		if len(certNames) == 0 && leaf.Subject.CommonName != "" {
+			certNames = []string{leaf.Subject.CommonName}
+		}
+		for _, name := range certNames {
+			name = strings.ToLower(name)
+			if _, ok := names[name]; !ok {
+				names[name] = &cert
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.certs = certs
+	s.names = names
+	s.version = version
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch checks the files of the key pairs every interval until the context is canceled, reloading them
+// when they change. The reloading errors are logged and the current certificates are kept
+func (s *CertificateStore) Watch(ctx context.Context, interval time.Duration, logger logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			changed := s.version != s.filesVersion()
+			s.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				logger.Error(err.Error())
+				continue
+			}
+			logger.Info("TLS certificates reloaded")
+		}
+	}
+}
+
+// filesVersion summarizes the modification times and the sizes of the files of the key pairs
+func (s *CertificateStore) filesVersion() string {
+	parts := make([]string, 0, 2*len(s.keys))
+	for _, k := range s.keys {
+		for _, path := range []string{k.PublicKey, k.PrivateKey} {
+			info, err := os.Stat(path)
+			if err != nil {
+				parts = append(parts, path+":missing")
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s:%d:%d", path, info.ModTime().UnixNano(), info.Size()))
+		}
+	}
+	return strings.Join(parts, ",")
+}
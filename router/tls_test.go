@@ -0,0 +1,265 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+)
+
+// writeKeyPair writes a self-signed certificate for the names and its key in the directory
+func writeKeyPair(t *testing.T, dir, name string, names ...string) config.TLSKeyPair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair := config.TLSKeyPair{
+		PublicKey:  filepath.Join(dir, name+".pem"),
+		PrivateKey: filepath.Join(dir, name+".key"),
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(pair.PublicKey, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pair.PrivateKey, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return pair
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "krakend_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	pair := writeKeyPair(t, dir, "default", "example.com")
+
+	for _, cfg := range []*config.TLS{nil, {IsDisabled: true, Keys: []config.TLSKeyPair{pair}}} {
+		if tlsConfig, store, err := NewTLSConfig(cfg); tlsConfig != nil || store != nil || err != nil {
+			t.Errorf("unexpected result: %v %v %v", tlsConfig, store, err)
+		}
+	}
+
+	for _, cfg := range []*config.TLS{
+		{},
+		{Keys: []config.TLSKeyPair{{PublicKey: "unknown.pem", PrivateKey: "unknown.key"}}},
+		{Keys: []config.TLSKeyPair{pair}, MinVersion: "TLS9"},
+		{Keys: []config.TLSKeyPair{pair}, MaxVersion: "TLS14"},
+		{Keys: []config.TLSKeyPair{pair}, ClientAuth: "always"},
+		{Keys: []config.TLSKeyPair{pair}, ClientAuth: "require_and_verify"},
+		{Keys: []config.TLSKeyPair{pair}, ClientCAs: []string{"unknown.pem"}},
+		{Keys: []config.TLSKeyPair{pair}, ClientCAs: []string{pair.PrivateKey}},
+	} {
+		if _, _, err := NewTLSConfig(cfg); err == nil {
+			t.Errorf("expecting an error with %+v", cfg)
+		}
+	}
+
+	tlsConfig, _, err := NewTLSConfig(&config.TLS{
+		Keys:             []config.TLSKeyPair{pair},
+		MinVersion:       "TLS12",
+		MaxVersion:       "TLS13",
+		CurvePreferences: []uint16{uint16(tls.X25519), uint16(tls.CurveP256)},
+		CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 || tlsConfig.MaxVersion != tls.VersionTLS13 ||
+		len(tlsConfig.CurvePreferences) != 2 || tlsConfig.CurvePreferences[0] != tls.X25519 ||
+		len(tlsConfig.CipherSuites) != 1 || tlsConfig.GetCertificate == nil {
+		t.Errorf("unexpected config: %+v", tlsConfig)
+	}
+	if tlsConfig.ClientAuth != tls.NoClientCert {
+		t.Errorf("unexpected client auth: %v", tlsConfig.ClientAuth)
+	}
+
+	tlsConfig, _, err = NewTLSConfig(&config.TLS{Keys: []config.TLSKeyPair{pair}, ClientCAs: []string{pair.PublicKey}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ClientAuth != tls.VerifyClientCertIfGiven || tlsConfig.ClientCAs == nil {
+		t.Errorf("unexpected client auth: %v", tlsConfig.ClientAuth)
+	}
+}
+
+func TestNewTLSConfig_clientCertificates(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	serverPair := writeKeyPair(t, dir, "server", "example.com")
+	clientPair := writeKeyPair(t, dir, "client", "client.example.com")
+	unknownPair := writeKeyPair(t, dir, "unknown", "unknown.example.com")
+
+	tlsConfig, _, err := NewTLSConfig(&config.TLS{
+		Keys:       []config.TLSKeyPair{serverPair},
+		ClientAuth: "require_and_verify",
+		ClientCAs:  []string{clientPair.PublicKey},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+		}),
+		TLSConfig: tlsConfig,
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServeTLS(ln, "", "")
+	defer s.Close()
+
+	get := func(pair *config.TLSKeyPair) (string, error) {
+		clientConfig := &tls.Config{InsecureSkipVerify: true}
+		if pair != nil {
+			cert, err := tls.LoadX509KeyPair(pair.PublicKey, pair.PrivateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clientConfig.Certificates = []tls.Certificate{cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+		resp, err := client.Get("https://" + ln.Addr().String())
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	if body, err := get(&clientPair); err != nil || body != "client.example.com" {
+		t.Errorf("unexpected result: %s %v", body, err)
+	}
+	if _, err := get(&unknownPair); err == nil {
+		t.Error("the unknown certificates must be rejected")
+	}
+	if _, err := get(nil); err == nil {
+		t.Error("the client certificate is required")
+	}
+}
+
+func TestCertificateStore_GetCertificate(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	store, err := NewCertificateStore([]config.TLSKeyPair{
+		writeKeyPair(t, dir, "default", "example.com"),
+		writeKeyPair(t, dir, "api", "*.api.example.com", "api.example.com"),
+		writeKeyPair(t, dir, "other", "other.com"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for serverName, expected := range map[string]string{
+		"example.com":          "example.com",
+		"EXAMPLE.com.":         "example.com",
+		"api.example.com":      "*.api.example.com",
+		"v1.api.example.com":   "*.api.example.com",
+		"a.v1.api.example.com": "example.com",
+		"other.com":            "other.com",
+		"":                     "example.com",
+		"unknown.com":          "example.com",
+	} {
+		cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if cert.Leaf.Subject.CommonName != expected {
+			t.Errorf("%s: unexpected certificate: %s", serverName, cert.Leaf.Subject.CommonName)
+		}
+	}
+}
+
+func TestConfigureTLS(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	pairs := []config.TLSKeyPair{
+		writeKeyPair(t, dir, "default", "example.com"),
+		writeKeyPair(t, dir, "other", "other.com"),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	buf := new(bytes.Buffer)
+	logger, _ := logging.NewLogger("INFO", buf, "")
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})}
+	cfg := config.ServiceConfig{TLS: &config.TLS{Keys: pairs, ReloadInterval: 10 * time.Millisecond}}
+	if err := ConfigureTLS(ctx, s, cfg, logger); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServeTLS(ln, "", "")
+	defer s.Close()
+
+	peerNames := func(serverName string) []string {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].DNSNames
+	}
+	if names := peerNames("other.com"); len(names) != 1 || names[0] != "other.com" {
+		t.Errorf("unexpected certificate: %v", names)
+	}
+
+	// the rotated certificate is loaded without restarting the server
+	time.Sleep(20 * time.Millisecond)
+	writeKeyPair(t, dir, "other", "other.com", "www.other.com")
+	for i := 0; i < 100; i++ {
+		if names := peerNames("other.com"); len(names) == 2 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("the certificate has not been reloaded")
+}
@@ -0,0 +1,64 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// without a provider, the spans are not recorded
+	var traceID string
+	next := MatchedEndpointHandler("/users/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID = TraceID(r)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	TracingHandler(next).ServeHTTP(httptest.NewRecorder(), req)
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected trace ID: %s", traceID)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	// the handler gets its tracer when it is created, so it must be created after setting the provider
+	traceID = ""
+	TracingHandler(next).ServeHTTP(httptest.NewRecorder(), req)
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("unexpected spans: %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /users/{id}" || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("unexpected span: %s", span.Name())
+	}
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.SpanContext().TraceID().String() != traceID {
+		t.Errorf("unexpected trace ID: %s", traceID)
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("unexpected status: %v", span.Status())
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, a := range span.Attributes() {
+		attrs[a.Key] = a.Value
+	}
+	if attrs["http.route"].AsString() != "/users/{id}" || attrs["http.response.status_code"].AsInt64() != 502 {
+		t.Errorf("unexpected attributes: %v", span.Attributes())
+	}
+}
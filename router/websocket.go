@@ -0,0 +1,189 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/core"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+// WebSocketNamespace is the key to look for the websocket options in the extra config of the endpoints
+const WebSocketNamespace = "github.com/devopsfaith/krakend/router/websocket"
+
+var (
+	// ErrNotWebSocketUpgrade is the error returned when the request is not a valid websocket handshake
+	ErrNotWebSocketUpgrade = errors.New("websocket: not a websocket handshake")
+	// ErrHijackNotSupported is the error returned when the response writer can not be hijacked
+	ErrHijackNotSupported = errors.New("websocket: the response writer does not support hijacking")
+)
+
+type webSocketConfig struct {
+	PingInterval   string `json:"ping_interval"`
+	PongTimeout    string `json:"pong_timeout"`
+	MaxMessageSize int64  `json:"max_message_size"`
+	WriteTimeout   string `json:"write_timeout"`
+}
+
+// WebSocketConfigGetter parses the websocket options of an endpoint. The second value is false if the
+// endpoint does not proxy websockets. The pong timeout defaults to twice the ping interval and the
+// rest of the options, to their proxy.DefaultWebSocket* values
+func WebSocketConfigGetter(extra config.ExtraConfig) (proxy.WebSocketConfig, bool) {
+	cfg := proxy.WebSocketConfig{}
+	v, ok := extra[WebSocketNamespace]
+	if !ok {
+		return cfg, false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false
+	}
+	tmp := webSocketConfig{}
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return cfg, false
+	}
+
+	cfg.PingInterval = proxy.DefaultWebSocketPingInterval
+	if d, err := time.ParseDuration(tmp.PingInterval); err == nil {
+		cfg.PingInterval = d
+	}
+	cfg.PongTimeout = 2 * cfg.PingInterval
+	if d, err := time.ParseDuration(tmp.PongTimeout); err == nil {
+		cfg.PongTimeout = d
+	}
+	cfg.MaxMessageSize = tmp.MaxMessageSize
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = proxy.DefaultWebSocketMaxMessageSize
+	}
+	cfg.WriteTimeout = proxy.DefaultWebSocketWriteTimeout
+	if d, err := time.ParseDuration(tmp.WriteTimeout); err == nil && d > 0 {
+		cfg.WriteTimeout = d
+	}
+	return cfg, true
+}
+
+// IsWebSocketUpgrade checks if the request asks for upgrading the connection to a websocket
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return r.Method == "GET" &&
+		headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// UpgradeWebSocket completes the websocket handshake of the request, adding the received headers to the
+// response, and returns the hijacked connection
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request, header http.Header) (*proxy.WebSocketConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !IsWebSocketUpgrade(r) || key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, ErrNotWebSocketUpgrade.Error(), http.StatusBadRequest)
+		return nil, ErrNotWebSocketUpgrade
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, ErrHijackNotSupported.Error(), http.StatusInternalServerError)
+		return nil, ErrHijackNotSupported
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + proxy.WebSocketAccept(key) + "\r\n"
+	for k, vs := range header {
+		for _, v := range vs {
+			response += k + ": " + v + "\r\n"
+		}
+	}
+	if _, err := conn.Write([]byte(response + "\r\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return proxy.NewWebSocketConn(conn, rw.Reader, false), nil
+}
+
+// WebSocketHandler returns a handler proxying the websocket handshakes of the endpoint to its first backend
+// and relaying the frames of both connections. The rest of the requests are served by the next handler.
+// The params are extracted from the requests with the received function, if any, and the query string
+// params and the headers to pass of the endpoint are sent in the handshake to the backend.
+// The subprotocol accepted by the backend is returned to the client
+func WebSocketHandler(endpoint *config.EndpointConfig, cfg proxy.WebSocketConfig, params func(*http.Request) map[string]string, next http.Handler) http.Handler {
+	if len(endpoint.Backend) == 0 {
+		return next
+	}
+	dial := proxy.NewWebSocketDialer(endpoint.Backend[0])
+	headersToSend := endpoint.HeadersToPass
+	if len(headersToSend) == 0 {
+		headersToSend = HeadersToSend
+	}
+	timeout := endpoint.Timeout
+	if timeout <= 0 {
+		timeout = config.DefaultTimeout
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		request := &proxy.Request{
+			Method:  r.Method,
+			Query:   QueryParams(r, endpoint.QueryString),
+			Params:  map[string]string{},
+			Headers: map[string][]string{"X-Forwarded-For": {ClientIP(r)}, "User-Agent": UserAgentHeaderValue},
+		}
+		if params != nil {
+			request.Params = params(r)
+		}
+		WildcardParams(endpoint, request.Params, r.URL.Path)
+		for _, k := range headersToSend {
+			if h, ok := r.Header[k]; ok {
+				request.Headers[k] = h
+			}
+		}
+		if protocols, ok := r.Header["Sec-Websocket-Protocol"]; ok {
+			request.Headers["Sec-Websocket-Protocol"] = protocols
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		backend, resp, err := dial(ctx, request)
+		cancel()
+		if err != nil {
+			status := http.StatusBadGateway
+			if resp != nil && resp.StatusCode >= http.StatusBadRequest {
+				status = resp.StatusCode
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		header := http.Header{}
+		header.Set(core.KrakendHeaderName, core.KrakendHeaderValue)
+		if protocol := resp.Header.Get("Sec-WebSocket-Protocol"); protocol != "" {
+			header.Set("Sec-WebSocket-Protocol", protocol)
+		}
+		client, err := UpgradeWebSocket(w, r, header)
+		if err != nil {
+			backend.Close()
+			return
+		}
+		proxy.RelayWebSocket(client, backend, cfg)
+	})
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, v := range header[name] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/proxy"
+)
+
+func TestWebSocketConfigGetter(t *testing.T) {
+	if _, ok := WebSocketConfigGetter(config.ExtraConfig{}); ok {
+		t.Error("the websockets should be disabled")
+	}
+
+	cfg, ok := WebSocketConfigGetter(config.ExtraConfig{WebSocketNamespace: map[string]interface{}{}})
+	if !ok {
+		t.Fatal("the websockets should be enabled")
+	}
+	if cfg.PingInterval != proxy.DefaultWebSocketPingInterval || cfg.PongTimeout != 2*proxy.DefaultWebSocketPingInterval ||
+		cfg.MaxMessageSize != proxy.DefaultWebSocketMaxMessageSize || cfg.WriteTimeout != proxy.DefaultWebSocketWriteTimeout {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	cfg, _ = WebSocketConfigGetter(config.ExtraConfig{WebSocketNamespace: map[string]interface{}{
+		"ping_interval":    "0s",
+		"pong_timeout":     "1m",
+		"max_message_size": 1024,
+		"write_timeout":    "1s",
+	}})
+	if cfg.PingInterval != 0 || cfg.PongTimeout != time.Minute || cfg.MaxMessageSize != 1024 || cfg.WriteTimeout != time.Second {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	for _, tc := range []struct {
+		method, connection, upgrade string
+		expected                    bool
+	}{
+		{"GET", "Upgrade", "websocket", true},
+		{"GET", "keep-alive, Upgrade", "WebSocket", true},
+		{"POST", "Upgrade", "websocket", false},
+		{"GET", "keep-alive", "websocket", false},
+		{"GET", "Upgrade", "h2c", false},
+	} {
+		r, _ := http.NewRequest(tc.method, "http://example.com/ws", nil)
+		r.Header.Set("Connection", tc.connection)
+		r.Header.Set("Upgrade", tc.upgrade)
+		if IsWebSocketUpgrade(r) != tc.expected {
+			t.Errorf("%v: unexpected result", tc)
+		}
+	}
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws/42" || r.URL.RawQuery != "room=a" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusNotFound)
+			return
+		}
+		conn, err := UpgradeWebSocket(w, r, http.Header{"Sec-Websocket-Protocol": {"chat"}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append([]byte(r.Header.Get("X-Token")+":"), msg...))
+		}
+	}))
+	defer backend.Close()
+
+	endpoint := &config.EndpointConfig{
+		Endpoint:      "/ws/:id",
+		Method:        "GET",
+		Timeout:       time.Second,
+		QueryString:   []string{"room"},
+		HeadersToPass: []string{"X-Token"},
+		Backend:       []*config.Backend{{Host: []string{backend.URL}, URLPattern: "/ws/{{.Id}}"}},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	params := func(r *http.Request) map[string]string {
+		return map[string]string{"Id": strings.TrimPrefix(r.URL.Path, "/ws/")}
+	}
+	gateway := httptest.NewServer(WebSocketHandler(endpoint, proxy.WebSocketConfig{MaxMessageSize: 1024}, params, next))
+	defer gateway.Close()
+
+	resp, err := http.Get(gateway.URL + "/ws/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("the regular requests should be served by the next handler: %d", resp.StatusCode)
+	}
+
+	conn, resp, err := proxy.DialWebSocket(context.Background(), gateway.URL+"/ws/42?room=a&ignored=1", http.Header{
+		"X-Token":                {"supu"},
+		"Sec-Websocket-Protocol": {"chat, superchat"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if p := resp.Header.Get("Sec-WebSocket-Protocol"); p != "chat" {
+		t.Errorf("unexpected subprotocol: %s", p)
+	}
+	for _, msg := range []string{"hello", "world"} {
+		if err := conn.WriteMessage(proxy.WebSocketTextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		messageType, echo, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if messageType != proxy.WebSocketTextMessage || string(echo) != "supu:"+msg {
+			t.Errorf("unexpected message: %d %s", messageType, echo)
+		}
+	}
+
+	_, resp, err = proxy.DialWebSocket(context.Background(), gateway.URL+"/ws/1", nil)
+	if err != proxy.ErrWebSocketHandshake {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("the errors of the backend should be returned: %v", resp)
+	}
+}
+
+func TestWebSocketHandler_backendDown(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	backend.Close()
+
+	endpoint := &config.EndpointConfig{
+		Endpoint: "/ws",
+		Timeout:  time.Second,
+		Backend:  []*config.Backend{{Host: []string{backend.URL}, URLPattern: "/ws"}},
+	}
+	gateway := httptest.NewServer(WebSocketHandler(endpoint, proxy.WebSocketConfig{}, nil, http.NotFoundHandler()))
+	defer gateway.Close()
+
+	_, resp, err := proxy.DialWebSocket(context.Background(), gateway.URL+"/ws", nil)
+	if err != proxy.ErrWebSocketHandshake {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestUpgradeWebSocket_badHandshake(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	if _, err := UpgradeWebSocket(w, r, nil); err != ErrNotWebSocketUpgrade {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusBadRequest || w.Header().Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,28 @@
+package router
+
+import (
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// WildcardParams sets the param of the catch-all endpoints with the rest of the requested path, without the
+// leading slash. The routers without catch-all routes don't extract the param, so it is taken from the path
+func WildcardParams(cfg *config.EndpointConfig, params map[string]string, path string) {
+	if cfg.Wildcard == "" || params == nil {
+		return
+	}
+	key := strings.Title(cfg.Wildcard)
+	if v, ok := params[key]; ok {
+		params[key] = strings.TrimPrefix(v, "/")
+		return
+	}
+	// the wildcard is the last segment of the endpoint
+	n := strings.Count(cfg.Endpoint, "/") - 1
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", n+1)
+	if len(parts) <= n {
+		params[key] = ""
+		return
+	}
+	params[key] = parts[n]
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestWildcardParams(t *testing.T) {
+	for _, tc := range []struct {
+		endpoint string
+		params   map[string]string
+		path     string
+		expected string
+	}{
+		{endpoint: "/users/*path", params: map[string]string{}, path: "/users/a/b", expected: "a/b"},
+		{endpoint: "/users/:id/*path", params: map[string]string{"Id": "42"}, path: "/users/42/a/b", expected: "a/b"},
+		{endpoint: "/*path", params: map[string]string{}, path: "/a/b", expected: "a/b"},
+		{endpoint: "/users/*path", params: map[string]string{}, path: "/users/", expected: ""},
+		{endpoint: "/users/*path", params: map[string]string{}, path: "/users", expected: ""},
+		{endpoint: "/users/*path", params: map[string]string{"Path": "/a/b"}, path: "/users/a/b", expected: "a/b"},
+		{endpoint: "/users/{path:.*}", params: map[string]string{"Path": "a/b"}, path: "/users/a/b", expected: "a/b"},
+	} {
+		WildcardParams(&config.EndpointConfig{Endpoint: tc.endpoint, Wildcard: "path"}, tc.params, tc.path)
+		if tc.params["Path"] != tc.expected {
+			t.Errorf("%s %s: unexpected param: %q", tc.endpoint, tc.path, tc.params["Path"])
+		}
+	}
+
+	params := map[string]string{}
+	WildcardParams(&config.EndpointConfig{Endpoint: "/users"}, params, "/users")
+	if len(params) != 0 {
+		t.Errorf("unexpected params: %v", params)
+	}
+}
@@ -0,0 +1,222 @@
+// Package consul provides a subscriber watching the healthy instances of a service registered in Consul
+package consul
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+const (
+	// Name is the name of the subscriber and the scheme of its hosts
+	Name = "consul"
+	// Namespace is the key to look for the options of the subscriber in the extra config of the backends
+	Namespace = "github.com/devopsfaith/krakend/sd/consul"
+)
+
+var (
+	// DefaultAddress is the address of the Consul agent used when the host does not declare it
+	DefaultAddress = "http://127.0.0.1:8500"
+	// DefaultWait is the max duration of the blocking queries
+	DefaultWait = 5 * time.Minute
+	// MaxRetryWait is the max time to wait before retrying a failed query
+	MaxRetryWait = 30 * time.Second
+
+	// ErrNoService is the error returned when the host does not declare the name of the service
+	ErrNoService = errors.New("the name of the consul service is required")
+)
+
+// Register registers the Consul subscriber factory
+func Register() error {
+	return sd.RegisterSubscriberFactory(Name, SubscriberFactory)
+}
+
+// Config defines the service to watch
+type Config struct {
+	// Address is the URL of the Consul agent
+	Address string
+	// Service is the name of the service
+	Service string
+	// Datacenter is the datacenter of the service. By default, the one of the agent
+	Datacenter string
+	// Tags filters the instances of the service
+	Tags []string
+	// Scheme is the scheme of the hosts. By default, http
+	Scheme string
+	// Token is the ACL token of the requests
+	Token string
+	// Wait is the max duration of the blocking queries
+	Wait time.Duration
+}
+
+// SubscriberFactory builds a Consul subscriber with the first host of the backend, with the format
+// consul://[agent host]/<service>?dc=<datacenter>&tag=<tag>&scheme=<scheme>. The 'address' of the agent,
+// the 'token' and the 'wait' time of the queries can be declared in the extra config of the backend
+func SubscriberFactory(cfg *config.Backend) sd.Subscriber {
+	c, err := ParseHost(cfg.Host[0])
+	if err != nil {
+		return sd.SubscriberFunc(func() ([]string, error) { return nil, err })
+	}
+	if extra, ok := cfg.ExtraConfig[Namespace].(map[string]interface{}); ok {
+		if v, ok := extra["address"].(string); ok && c.Address == "" {
+			c.Address = v
+		}
+		c.Token, _ = extra["token"].(string)
+		if v, ok := extra["wait"].(string); ok {
+			c.Wait, _ = time.ParseDuration(v)
+		}
+	}
+	return New(c, http.DefaultClient)
+}
+
+// ParseHost parses the host of a backend with the consul scheme
+func ParseHost(host string) (Config, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return Config{}, err
+	}
+	c := Config{
+		Service:    strings.Trim(u.Path, "/"),
+		Datacenter: u.Query().Get("dc"),
+		Tags:       u.Query()["tag"],
+		Scheme:     u.Query().Get("scheme"),
+	}
+	if c.Service == "" {
+		return c, ErrNoService
+	}
+	if u.Host != "" {
+		c.Address = "http://" + u.Host
+	}
+	return c, nil
+}
+
+// New creates a subscriber watching the healthy instances of the service with blocking queries
+func New(cfg Config, client *http.Client) sd.Subscriber {
+	if cfg.Address == "" {
+		cfg.Address = DefaultAddress
+	}
+	if cfg.Scheme == "" {
+		cfg.Scheme = "http"
+	}
+	if cfg.Wait <= 0 {
+		cfg.Wait = DefaultWait
+	}
+	s := &subscriber{cfg: cfg, client: client, hosts: []string{}}
+	index, _ := s.update(0)
+	go s.loop(index)
+	return s
+}
+
+type subscriber struct {
+	cfg    Config
+	client *http.Client
+
+	mu    sync.RWMutex
+	hosts []string
+}
+
+type serviceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+// Hosts implements the sd.Subscriber interface
+func (s *subscriber) Hosts() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hosts, nil
+}
+
+func (s *subscriber) loop(index uint64) {
+	retryWait := time.Second
+	for {
+		next, err := s.update(index)
+		if err != nil {
+			time.Sleep(retryWait)
+			if retryWait *= 2; retryWait > MaxRetryWait {
+				retryWait = MaxRetryWait
+			}
+			continue
+		}
+		retryWait = time.Second
+		// the index must grow, so it is reset when the agent returns a lower one
+		if next < index {
+			next = 0
+		}
+		index = next
+		if index == 0 {
+			// without index the query does not block, so it is throttled
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// update queries the healthy instances of the service, blocking until the index changes
+func (s *subscriber) update(index uint64) (uint64, error) {
+	query := url.Values{}
+	query.Set("passing", "1")
+	if s.cfg.Datacenter != "" {
+		query.Set("dc", s.cfg.Datacenter)
+	}
+	for _, tag := range s.cfg.Tags {
+		query.Add("tag", tag)
+	}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(s.cfg.Wait.Seconds())))
+	}
+
+	req, err := http.NewRequest("GET", s.cfg.Address+"/v1/health/service/"+url.PathEscape(s.cfg.Service)+"?"+query.Encode(), nil)
+	if err != nil {
+		return index, err
+	}
+	if s.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", s.cfg.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return index, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return index, fmt.Errorf("consul: unexpected status code %d", resp.StatusCode)
+	}
+
+	entries := []serviceEntry{}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return index, err
+	}
+	hosts := make([]string, 0, len(entries))
+	for _, e := range entries {
+		addr := e.Service.Address
+		if addr == "" {
+			addr = e.Node.Address
+		}
+		hosts = append(hosts, s.cfg.Scheme+"://"+net.JoinHostPort(addr, strconv.Itoa(e.Service.Port)))
+	}
+
+	s.mu.Lock()
+	s.hosts = hosts
+	s.mu.Unlock()
+
+	next, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+	return next, nil
+}
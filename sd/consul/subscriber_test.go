@@ -0,0 +1,100 @@
+package consul
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+func TestParseHost(t *testing.T) {
+	c, err := ParseHost("consul://agent:8500/payments?tag=grpc&tag=v2&dc=eu&scheme=https")
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	expected := Config{
+		Address:    "http://agent:8500",
+		Service:    "payments",
+		Datacenter: "eu",
+		Tags:       []string{"grpc", "v2"},
+		Scheme:     "https",
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("unexpected config: %+v", c)
+	}
+
+	if _, err := ParseHost("consul:///"); err != ErrNoService {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSubscriber(t *testing.T) {
+	responses := []string{
+		`[{"Node":{"Address":"10.0.0.1"},"Service":{"Address":"","Port":8080}}]`,
+		`[{"Node":{"Address":"10.0.0.1"},"Service":{"Address":"","Port":8080}},{"Node":{"Address":"10.0.0.2"},"Service":{"Address":"10.1.0.2","Port":8081}}]`,
+	}
+	requests := make(chan *http.Request, 10)
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requests <- r:
+		default:
+		}
+		index := int(atomic.AddInt32(&calls, 1))
+		if index > len(responses) {
+			// block like the consul agent while there are no changes
+			time.Sleep(100 * time.Millisecond)
+			index = len(responses)
+		}
+		w.Header().Set("X-Consul-Index", fmt.Sprint(index))
+		fmt.Fprint(w, responses[index-1])
+	}))
+	defer server.Close()
+
+	Register()
+	s := sd.GetSubscriber(&config.Backend{
+		Host: []string{"consul:///payments?tag=grpc&dc=eu"},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"address": server.URL,
+			"token":   "secret",
+			"wait":    "10s",
+		}},
+	})
+
+	hosts, _ := s.Hosts()
+	if !reflect.DeepEqual(hosts, []string{"http://10.0.0.1:8080"}) {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+	r := <-requests
+	if r.URL.Path != "/v1/health/service/payments" || r.URL.Query().Get("tag") != "grpc" ||
+		r.URL.Query().Get("dc") != "eu" || r.URL.Query().Get("passing") != "1" {
+		t.Errorf("unexpected request: %s", r.URL.String())
+	}
+	if r.Header.Get("X-Consul-Token") != "secret" {
+		t.Error("the token was not sent")
+	}
+
+	r = <-requests
+	if r.URL.Query().Get("index") != "1" || r.URL.Query().Get("wait") != "10s" {
+		t.Errorf("the query was not blocking: %s", r.URL.String())
+	}
+	time.Sleep(10 * time.Millisecond)
+	hosts, _ = s.Hosts()
+	if !reflect.DeepEqual(hosts, []string{"http://10.0.0.1:8080", "http://10.1.0.2:8081"}) {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+}
+
+func TestSubscriberFactory_badHost(t *testing.T) {
+	s := SubscriberFactory(&config.Backend{Host: []string{"consul:///"}})
+	if _, err := s.Hosts(); err != ErrNoService {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package dns
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	maxUDPSize     = 4096
+	defaultDNSPort = "53"
+	resolvConfPath = "/etc/resolv.conf"
+	// defaultNdots is the ndots option of the resolver when the system configuration does not declare it
+	defaultNdots = 1
+	// maxNdots is the max value of the ndots option accepted by the system resolvers
+	maxNdots = 15
+)
+
+var (
+	// ErrNoNameservers is the error returned when there are no nameservers to query
+	ErrNoNameservers = errors.New("no nameservers available")
+	// ErrMalformedMessage is the error returned when the response of the nameserver can not be parsed
+	ErrMalformedMessage = errors.New("malformed dns message")
+	// ErrInvalidName is the error returned when the name to resolve is not valid
+	ErrInvalidName = errors.New("invalid dns name")
+	// ErrNameNotFound is the error returned when the name does not exist (NXDOMAIN) with any of the search
+	// domains
+	ErrNameNotFound = errors.New("the name does not exist")
+	// ErrServerFailure is the error returned when the nameserver fails to resolve the name (SERVFAIL)
+	ErrServerFailure = errors.New("the nameserver failed to resolve the name")
+)
+
+// resolvConf is the configuration of the system resolver
+type resolvConf struct {
+	servers []string
+	search  []string
+	ndots   int
+}
+
+// readResolvConf reads the nameservers, the search domains and the ndots option of the system
+// configuration at the path
+func readResolvConf(path string) resolvConf {
+	conf := resolvConf{servers: []string{}, search: []string{}, ndots: defaultNdots}
+	f, err := os.Open(path)
+	if err != nil {
+		return conf
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			conf.servers = append(conf.servers, net.JoinHostPort(fields[1], defaultDNSPort))
+		case "domain":
+			conf.search = searchDomains(fields[1:2])
+		case "search":
+			// the last search or domain line wins
+			conf.search = searchDomains(fields[1:])
+		case "options":
+			for _, option := range fields[1:] {
+				if !strings.HasPrefix(option, "ndots:") {
+					continue
+				}
+				n, err := strconv.Atoi(strings.TrimPrefix(option, "ndots:"))
+				if err != nil || n < 0 {
+					continue
+				}
+				if n > maxNdots {
+					n = maxNdots
+				}
+				conf.ndots = n
+			}
+		}
+	}
+	return conf
+}
+
+func searchDomains(domains []string) []string {
+	res := make([]string, 0, len(domains))
+	for _, d := range domains {
+		if d = strings.Trim(d, "."); d != "" {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// client sends the queries to the nameservers, over UDP and over TCP when the response is truncated. The
+// relative names are completed with the search domains, as the system resolver does
+type client struct {
+	servers []string
+	search  []string
+	ndots   int
+	timeout time.Duration
+}
+
+func newClient(cfg Config) client {
+	return client{servers: cfg.Nameservers, search: cfg.Search, ndots: cfg.Ndots, timeout: cfg.Timeout}
+}
+
+// names returns the fully qualified names to query, in order. The names with at least ndots dots are tried
+// as they are before the search domains, and the rest of the relative names after them. The names ending
+// with a dot are already fully qualified
+func (c client) names(name string) []string {
+	if strings.HasSuffix(name, ".") {
+		return []string{name}
+	}
+	names := make([]string, 0, len(c.search)+1)
+	for _, domain := range c.search {
+		names = append(names, name+"."+domain+".")
+	}
+	if strings.Count(name, ".") >= c.ndots {
+		return append([]string{name + "."}, names...)
+	}
+	return append(names, name+".")
+}
+
+// query resolves the records of the received types for the first name, completed with the search domains,
+// having any of them. It returns ErrNameNotFound if none of the names exists, and an empty list if they
+// exist without records of the types. The failures of the nameservers are returned without trying the rest
+// of the names, so they are not hidden by the answers for other names
+func (c client) query(name string, qtypes ...dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	if len(c.servers) == 0 {
+		return nil, ErrNoNameservers
+	}
+	if !validName(name) {
+		return nil, ErrInvalidName
+	}
+	exists := false
+	for _, n := range c.names(name) {
+		records := []dnsmessage.Resource{}
+		for _, qtype := range qtypes {
+			rs, err := c.queryServers(n, qtype)
+			if err == ErrNameNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			exists = true
+			records = append(records, rs...)
+		}
+		if len(records) > 0 {
+			return records, nil
+		}
+	}
+	if exists {
+		return []dnsmessage.Resource{}, nil
+	}
+	return nil, ErrNameNotFound
+}
+
+// queryServers tries every nameserver in order until one of them answers. A missing name is an answer, so
+// the rest of the nameservers are not queried
+func (c client) queryServers(name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	var err error
+	for _, server := range c.servers {
+		var records []dnsmessage.Resource
+		records, err = c.exchange(server, name, qtype)
+		if err == nil || err == ErrNameNotFound {
+			return records, err
+		}
+	}
+	return nil, err
+}
+
+func (c client) exchange(server, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	id, err := queryID()
+	if err != nil {
+		return nil, err
+	}
+	q, err := buildQuery(id, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := c.roundTrip("udp", server, q)
+	if err != nil {
+		return nil, err
+	}
+	records, truncated, err := parseResponse(msg, id, qtype)
+	if err != nil || !truncated {
+		return records, err
+	}
+
+	msg, err = c.roundTrip("tcp", server, q)
+	if err != nil {
+		return nil, err
+	}
+	records, _, err = parseResponse(msg, id, qtype)
+	return records, err
+}
+
+func (c client) roundTrip(network, server string, q []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(network, server, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if network == "udp" {
+		if _, err := conn.Write(q); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, maxUDPSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	// the tcp messages are prefixed with their length
+	framed := make([]byte, 2+len(q))
+	binary.BigEndian.PutUint16(framed, uint16(len(q)))
+	copy(framed[2:], q)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	var size uint16
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// queryID returns a random ID for a query, so the answers can not be spoofed by guessing it
+func queryID() (uint16, error) {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b[:]), nil
+}
+
+// validName checks the length of the labels of the name, since the empty ones are not rejected by the
+// builder of the messages
+func validName(name string) bool {
+	if name == "" || name == "." {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+	}
+	return true
+}
+
+// buildQuery returns the query of the records of the fully qualified name, advertising the max size of the
+// udp responses with EDNS(0)
+func buildQuery(id uint16, name string, qtype dnsmessage.Type) ([]byte, error) {
+	n, err := dnsmessage.NewName(name)
+	if err != nil || !validName(name) {
+		return nil, ErrInvalidName
+	}
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: n, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, ErrInvalidName
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(maxUDPSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// parseResponse returns the answers of the received type and if the response was truncated. The missing
+// names and the failures of the nameserver are returned as errors
+func parseResponse(msg []byte, id uint16, qtype dnsmessage.Type) ([]dnsmessage.Resource, bool, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil || h.ID != id || !h.Response {
+		return nil, false, ErrMalformedMessage
+	}
+	if h.Truncated {
+		return nil, true, nil
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, false, ErrNameNotFound
+	case dnsmessage.RCodeServerFailure:
+		return nil, false, ErrServerFailure
+	default:
+		return nil, false, fmt.Errorf("the nameserver answered with %s", h.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, false, ErrMalformedMessage
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return nil, false, ErrMalformedMessage
+	}
+	records := []dnsmessage.Resource{}
+	for _, a := range answers {
+		// the CNAME records are skipped, since the resolver follows them
+		if a.Header.Type == qtype {
+			records = append(records, a)
+		}
+	}
+	return records, false, nil
+}
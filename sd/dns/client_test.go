@@ -0,0 +1,393 @@
+package dns
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// answer is a record of the responses of the fake nameservers: an AResource, an AAAAResource, an
+// SRVResource or a CNAMEResource
+type answer struct {
+	ttl  uint32
+	body dnsmessage.ResourceBody
+}
+
+func aAnswer(ttl uint32, ip string) answer {
+	r := &dnsmessage.AResource{}
+	copy(r.A[:], net.ParseIP(ip).To4())
+	return answer{ttl: ttl, body: r}
+}
+
+func aaaaAnswer(ttl uint32, ip string) answer {
+	r := &dnsmessage.AAAAResource{}
+	copy(r.AAAA[:], net.ParseIP(ip))
+	return answer{ttl: ttl, body: r}
+}
+
+func srvAnswer(ttl uint32, priority, port uint16, target string) answer {
+	return answer{ttl: ttl, body: &dnsmessage.SRVResource{
+		Priority: priority,
+		Port:     port,
+		Target:   dnsmessage.MustNewName(target),
+	}}
+}
+
+// parseQuery returns the ID and the question of the query
+func parseQuery(query []byte) (uint16, dnsmessage.Question) {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return 0, dnsmessage.Question{}
+	}
+	q, _ := p.Question()
+	return h.ID, q
+}
+
+// buildResponse answers the query with the received records
+func buildResponse(query []byte, rcode dnsmessage.RCode, truncated bool, answers ...answer) []byte {
+	id, q := parseQuery(query)
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, Response: true, RCode: rcode, Truncated: truncated})
+	b.EnableCompression()
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+	for _, a := range answers {
+		h := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: a.ttl}
+		switch body := a.body.(type) {
+		case *dnsmessage.AResource:
+			b.AResource(h, *body)
+		case *dnsmessage.AAAAResource:
+			b.AAAAResource(h, *body)
+		case *dnsmessage.SRVResource:
+			b.SRVResource(h, *body)
+		case *dnsmessage.CNAMEResource:
+			b.CNAMEResource(h, *body)
+		}
+	}
+	msg, _ := b.Finish()
+	return msg
+}
+
+// startServer starts a fake nameserver over UDP answering with the response built by the handler
+func startServer(t *testing.T, handler func(query []byte) []byte) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, maxUDPSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(handler(buf[:n]), addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestReadResolvConf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krakend_dns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	content := "# comment\ndomain example.\nnameserver 10.0.0.1\nnameserver ::1\nsearch svc.cluster.local cluster.local.\noptions timeout:1 ndots:5\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := readResolvConf(path)
+	if len(conf.servers) != 2 || conf.servers[0] != "10.0.0.1:53" || conf.servers[1] != "[::1]:53" {
+		t.Errorf("unexpected nameservers: %v", conf.servers)
+	}
+	if len(conf.search) != 2 || conf.search[0] != "svc.cluster.local" || conf.search[1] != "cluster.local" {
+		t.Errorf("unexpected search domains: %v", conf.search)
+	}
+	if conf.ndots != 5 {
+		t.Errorf("unexpected ndots: %d", conf.ndots)
+	}
+
+	conf = readResolvConf(filepath.Join(dir, "unknown"))
+	if len(conf.servers) != 0 || len(conf.search) != 0 || conf.ndots != defaultNdots {
+		t.Errorf("unexpected configuration: %+v", conf)
+	}
+}
+
+func TestClient_names(t *testing.T) {
+	c := client{search: []string{"svc.local", "local"}, ndots: 1}
+	for name, expected := range map[string][]string{
+		"supu":          {"supu.svc.local.", "supu.local.", "supu."},
+		"supu.example":  {"supu.example.", "supu.example.svc.local.", "supu.example.local."},
+		"supu.example.": {"supu.example."},
+	} {
+		names := c.names(name)
+		if len(names) != len(expected) {
+			t.Errorf("%s: unexpected names: %v", name, names)
+			continue
+		}
+		for i := range names {
+			if names[i] != expected[i] {
+				t.Errorf("%s: unexpected names: %v", name, names)
+			}
+		}
+	}
+}
+
+func TestBuildQuery(t *testing.T) {
+	q, err := buildQuery(42, "supu.example.", dnsmessage.TypeSRV)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	var p dnsmessage.Parser
+	h, err := p.Start(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.ID != 42 || !h.RecursionDesired || h.Response {
+		t.Errorf("unexpected header: %+v", h)
+	}
+	question, err := p.Question()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if question.Name.String() != "supu.example." || question.Type != dnsmessage.TypeSRV || question.Class != dnsmessage.ClassINET {
+		t.Errorf("unexpected question: %+v", question)
+	}
+	p.SkipAllQuestions()
+	p.SkipAllAnswers()
+	p.SkipAllAuthorities()
+	opt, err := p.AdditionalHeader()
+	if err != nil || opt.Type != dnsmessage.TypeOPT || int(opt.Class) != maxUDPSize {
+		t.Errorf("unexpected EDNS(0) record: %+v %v", opt, err)
+	}
+
+	for _, name := range []string{"supu..example.", "", ".", string(make([]byte, 64)) + "."} {
+		if _, err := buildQuery(42, name, dnsmessage.TypeA); err != ErrInvalidName {
+			t.Errorf("%q: unexpected error: %v", name, err)
+		}
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	q, _ := buildQuery(1, "supu.example.", dnsmessage.TypeSRV)
+	msg := buildResponse(q, dnsmessage.RCodeSuccess, false,
+		answer{ttl: 1, body: &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("tupu.example.")}},
+		srvAnswer(30, 1, 8080, "a.example."),
+		srvAnswer(10, 0, 8081, "b.example."),
+	)
+
+	records, truncated, err := parseResponse(msg, 1, dnsmessage.TypeSRV)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if truncated {
+		t.Error("unexpected truncated response")
+	}
+	if len(records) != 2 {
+		t.Errorf("unexpected records: %v", records)
+		return
+	}
+	srv, ok := records[0].Body.(*dnsmessage.SRVResource)
+	if !ok || srv.Target.String() != "a.example." || srv.Port != 8080 || srv.Priority != 1 {
+		t.Errorf("unexpected srv: %v", records[0].Body)
+	}
+	if ttl := minTTL(records); ttl != 10*time.Second {
+		t.Errorf("unexpected ttl: %v", ttl)
+	}
+
+	if _, _, err := parseResponse(msg, 2, dnsmessage.TypeSRV); err != ErrMalformedMessage {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, _, err := parseResponse(msg[:len(msg)-3], 1, dnsmessage.TypeSRV); err != ErrMalformedMessage {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, _, err := parseResponse(q, 1, dnsmessage.TypeSRV); err != ErrMalformedMessage {
+		t.Errorf("the queries are not responses: %v", err)
+	}
+	if _, truncated, err := parseResponse(buildResponse(q, dnsmessage.RCodeSuccess, true), 1, dnsmessage.TypeSRV); err != nil || !truncated {
+		t.Errorf("unexpected result: %v %v", truncated, err)
+	}
+	for rcode, expected := range map[dnsmessage.RCode]error{
+		dnsmessage.RCodeServerFailure: ErrServerFailure,
+		dnsmessage.RCodeNameError:     ErrNameNotFound,
+	} {
+		if _, _, err := parseResponse(buildResponse(q, rcode, false), 1, dnsmessage.TypeSRV); err != expected {
+			t.Errorf("%s: unexpected error: %v", rcode, err)
+		}
+	}
+	if _, _, err := parseResponse(buildResponse(q, dnsmessage.RCodeRefused, false), 1, dnsmessage.TypeSRV); err == nil {
+		t.Error("the refused queries must fail")
+	}
+}
+
+func TestQueryID(t *testing.T) {
+	ids := map[uint16]struct{}{}
+	for i := 0; i < 10; i++ {
+		id, err := queryID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[id] = struct{}{}
+	}
+	if len(ids) < 2 {
+		t.Errorf("the ids are not random: %v", ids)
+	}
+}
+
+func TestClient_query(t *testing.T) {
+	server := startServer(t, func(query []byte) []byte {
+		return buildResponse(query, dnsmessage.RCodeSuccess, false, aAnswer(5, "10.0.0.1"))
+	})
+	c := newClient(Config{Nameservers: []string{"127.0.0.1:1", server}, Ndots: 1, Timeout: 50 * time.Millisecond})
+
+	records, err := c.query("supu.example", dnsmessage.TypeA)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if len(records) != 1 {
+		t.Errorf("unexpected records: %v", records)
+		return
+	}
+	if a, ok := records[0].Body.(*dnsmessage.AResource); !ok || net.IP(a.A[:]).String() != "10.0.0.1" {
+		t.Errorf("unexpected record: %v", records[0].Body)
+	}
+
+	if _, err := newClient(Config{Timeout: time.Second}).query("supu.example", dnsmessage.TypeA); err != ErrNoNameservers {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := c.query("supu..example", dnsmessage.TypeA); err != ErrInvalidName {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_search(t *testing.T) {
+	mu := sync.Mutex{}
+	queried := []string{}
+	server := startServer(t, func(query []byte) []byte {
+		_, q := parseQuery(query)
+		mu.Lock()
+		queried = append(queried, q.Name.String())
+		mu.Unlock()
+		switch q.Name.String() {
+		case "supu.local.":
+			return buildResponse(query, dnsmessage.RCodeSuccess, false, aAnswer(5, "10.0.0.1"))
+		case "tupu.local.":
+			// the name exists without records of the type
+			return buildResponse(query, dnsmessage.RCodeSuccess, false)
+		case "failing.svc.local.":
+			return buildResponse(query, dnsmessage.RCodeServerFailure, false)
+		}
+		return buildResponse(query, dnsmessage.RCodeNameError, false)
+	})
+	c := newClient(Config{Nameservers: []string{server}, Search: []string{"svc.local", "local"}, Ndots: 1, Timeout: time.Second})
+
+	reset := func() {
+		mu.Lock()
+		queried = []string{}
+		mu.Unlock()
+	}
+	queries := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, queried...)
+	}
+
+	records, err := c.query("supu", dnsmessage.TypeA)
+	if err != nil || len(records) != 1 {
+		t.Errorf("unexpected result: %v %v", records, err)
+	}
+	if q := queries(); len(q) != 2 || q[0] != "supu.svc.local." || q[1] != "supu.local." {
+		t.Errorf("unexpected queries: %v", q)
+	}
+
+	reset()
+	if records, err := c.query("tupu", dnsmessage.TypeA); err != nil || len(records) != 0 {
+		t.Errorf("unexpected result: %v %v", records, err)
+	}
+	if q := queries(); len(q) != 3 {
+		t.Errorf("unexpected queries: %v", q)
+	}
+
+	reset()
+	if _, err := c.query("unknown", dnsmessage.TypeA); err != ErrNameNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if q := queries(); len(q) != 3 {
+		t.Errorf("unexpected queries: %v", q)
+	}
+
+	reset()
+	if _, err := c.query("failing", dnsmessage.TypeA); err != ErrServerFailure {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if q := queries(); len(q) != 1 {
+		t.Errorf("the failures must not be hidden by the rest of the names: %v", q)
+	}
+}
+
+func TestClient_truncated(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		size := make([]byte, 2)
+		conn.Read(size)
+		query := make([]byte, binary.BigEndian.Uint16(size))
+		conn.Read(query)
+		resp := buildResponse(query, dnsmessage.RCodeSuccess, false, aAnswer(5, "10.0.0.2"))
+		framed := make([]byte, 2)
+		binary.BigEndian.PutUint16(framed, uint16(len(resp)))
+		conn.Write(append(framed, resp...))
+	}()
+
+	// the udp and the tcp servers share the port
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	conn, err := net.ListenPacket("udp", "127.0.0.1:"+port)
+	if err != nil {
+		t.Skip("the udp port is not available:", err.Error())
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, maxUDPSize)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		conn.WriteTo(buildResponse(buf[:n], dnsmessage.RCodeSuccess, true), addr)
+	}()
+
+	c := newClient(Config{Nameservers: []string{l.Addr().String()}, Ndots: 1, Timeout: time.Second})
+	records, err := c.query("supu.example", dnsmessage.TypeA)
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if len(records) != 1 {
+		t.Errorf("unexpected records: %v", records)
+		return
+	}
+	if a, ok := records[0].Body.(*dnsmessage.AResource); !ok || net.IP(a.A[:]).String() != "10.0.0.2" {
+		t.Errorf("unexpected record: %v", records[0].Body)
+	}
+}
@@ -0,0 +1,328 @@
+// Package dns provides subscribers watching the SRV or the A/AAAA records of a name and refreshing the
+// hosts of the backend when the TTL of the records expires
+package dns
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+const (
+	// Namespace is the key to look for the options of the dns subscribers in the extra config of the backends
+	Namespace = "github.com/devopsfaith/krakend/sd/dns"
+	// SRVName is the name of the subscriber resolving SRV records
+	SRVName = "dns-srv"
+	// AName is the name of the subscriber resolving A and AAAA records
+	AName = "dns-a"
+)
+
+var (
+	// DefaultRefreshInterval is the refresh interval used when the TTL of the records is unknown
+	DefaultRefreshInterval = 30 * time.Second
+	// DefaultMinRefresh is the min interval between resolutions, so the records with low TTLs do not
+	// flood the nameservers
+	DefaultMinRefresh = time.Second
+	// DefaultJitter is the default max fraction of the interval added to every refresh
+	DefaultJitter = 0.1
+	// DefaultTimeout is the default timeout of the queries
+	DefaultTimeout = 2 * time.Second
+)
+
+// Register registers the dns subscriber factories
+func Register() error {
+	if err := sd.RegisterSubscriberFactory(SRVName, SRVSubscriberFactory); err != nil {
+		return err
+	}
+	return sd.RegisterSubscriberFactory(AName, ASubscriberFactory)
+}
+
+// Config defines the resolution of the records
+type Config struct {
+	// Scheme is the scheme of the hosts. By default, http
+	Scheme string
+	// Port is the port of the hosts resolved with A records
+	Port int
+	// RefreshInterval is the interval between resolutions when the TTL of the records is unknown
+	RefreshInterval time.Duration
+	// MinRefresh is the min interval between resolutions
+	MinRefresh time.Duration
+	// Jitter is the max fraction of the interval added randomly to every refresh, so the instances of
+	// the service do not query the nameservers at the same time
+	Jitter float64
+	// Nameservers is the list of the nameservers to query. By default, the ones of the system
+	Nameservers []string
+	// Search is the list of the domains completing the relative names. By default, the ones of the system
+	Search []string
+	// Ndots is the number of dots making a relative name be tried as it is before the search domains. By
+	// default, the one of the system
+	Ndots int
+	// Timeout is the timeout of every query
+	Timeout time.Duration
+}
+
+// SRVSubscriberFactory builds a subscriber resolving the SRV records of the first host of the backend
+func SRVSubscriberFactory(cfg *config.Backend) sd.Subscriber {
+	return NewSRV(cfg.Host[0], parseConfig(cfg))
+}
+
+// ASubscriberFactory builds a subscriber resolving the A and AAAA records of the first host of the backend
+func ASubscriberFactory(cfg *config.Backend) sd.Subscriber {
+	return NewA(cfg.Host[0], parseConfig(cfg))
+}
+
+// NewSRV creates a subscriber with the targets of the SRV records of the name, sorted by priority
+func NewSRV(name string, cfg Config) sd.Subscriber {
+	cfg = withDefaults(cfg)
+	c := newClient(cfg)
+	return newSubscriber(cfg, func() ([]string, time.Duration, error) {
+		return resolveSRV(c, name, cfg.Scheme)
+	})
+}
+
+// NewA creates a subscriber with the addresses of the A and AAAA records of the name
+func NewA(name string, cfg Config) sd.Subscriber {
+	cfg = withDefaults(cfg)
+	c := newClient(cfg)
+	return newSubscriber(cfg, func() ([]string, time.Duration, error) {
+		return resolveA(c, name, cfg.Scheme, cfg.Port)
+	})
+}
+
+func parseConfig(remote *config.Backend) Config {
+	cfg := Config{}
+	extra, ok := remote.ExtraConfig[Namespace].(map[string]interface{})
+	if !ok {
+		return cfg
+	}
+	cfg.Scheme, _ = extra["scheme"].(string)
+	if v, ok := extra["port"].(float64); ok {
+		cfg.Port = int(v)
+	}
+	if v, ok := extra["jitter"].(float64); ok {
+		cfg.Jitter = v
+	}
+	if v, ok := extra["ndots"].(float64); ok {
+		cfg.Ndots = int(v)
+	}
+	if v, ok := extra["search"].([]interface{}); ok {
+		cfg.Search = []string{}
+		for _, domain := range v {
+			if d, ok := domain.(string); ok {
+				cfg.Search = append(cfg.Search, d)
+			}
+		}
+		cfg.Search = searchDomains(cfg.Search)
+	}
+	if v, ok := extra["nameservers"].([]interface{}); ok {
+		for _, ns := range v {
+			if server, ok := ns.(string); ok {
+				if _, _, err := net.SplitHostPort(server); err != nil {
+					server = net.JoinHostPort(server, defaultDNSPort)
+				}
+				cfg.Nameservers = append(cfg.Nameservers, server)
+			}
+		}
+	}
+	for name, d := range map[string]*time.Duration{
+		"refresh_interval": &cfg.RefreshInterval,
+		"min_refresh":      &cfg.MinRefresh,
+		"timeout":          &cfg.Timeout,
+	} {
+		if v, ok := extra[name].(string); ok {
+			*d, _ = time.ParseDuration(v)
+		}
+	}
+	return cfg
+}
+
+func withDefaults(cfg Config) Config {
+	if cfg.Scheme == "" {
+		cfg.Scheme = "http"
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = DefaultRefreshInterval
+	}
+	if cfg.MinRefresh <= 0 {
+		cfg.MinRefresh = DefaultMinRefresh
+	}
+	if cfg.Jitter <= 0 {
+		cfg.Jitter = DefaultJitter
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	system := readResolvConf(resolvConfPath)
+	if len(cfg.Nameservers) == 0 {
+		cfg.Nameservers = system.servers
+	}
+	if cfg.Search == nil {
+		cfg.Search = system.search
+	}
+	if cfg.Ndots <= 0 {
+		cfg.Ndots = system.ndots
+	}
+	return cfg
+}
+
+// resolver returns the hosts and their TTL. A zero TTL means it is unknown
+type resolver func() ([]string, time.Duration, error)
+
+type subscriber struct {
+	cfg     Config
+	resolve resolver
+
+	mu    sync.RWMutex
+	hosts []string
+	err   error
+}
+
+func newSubscriber(cfg Config, resolve resolver) *subscriber {
+	s := &subscriber{cfg: cfg, resolve: resolve, hosts: []string{}}
+	ttl := s.update()
+	go s.loop(ttl)
+	return s
+}
+
+// Hosts implements the sd.Subscriber interface. The error of the last resolution is only returned when no
+// hosts were resolved before
+func (s *subscriber) Hosts() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.hosts) == 0 {
+		return s.hosts, s.err
+	}
+	return s.hosts, nil
+}
+
+func (s *subscriber) loop(ttl time.Duration) {
+	for {
+		<-time.After(s.refreshInterval(ttl))
+		ttl = s.update()
+	}
+}
+
+// update resolves the hosts, keeping the previous ones if the resolution fails
+func (s *subscriber) update() time.Duration {
+	hosts, ttl, err := s.resolve()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	if err != nil {
+		return 0
+	}
+	s.hosts = hosts
+	return ttl
+}
+
+func (s *subscriber) refreshInterval(ttl time.Duration) time.Duration {
+	d := ttl
+	if d <= 0 {
+		d = s.cfg.RefreshInterval
+	}
+	if d < s.cfg.MinRefresh {
+		d = s.cfg.MinRefresh
+	}
+	return d + time.Duration(rand.Float64()*s.cfg.Jitter*float64(d))
+}
+
+func resolveSRV(c client, name, scheme string) ([]string, time.Duration, error) {
+	records, err := c.query(name, dnsmessage.TypeSRV)
+	if err == ErrNoNameservers {
+		_, addrs, err := net.LookupSRV("", "", name)
+		return srvHosts(addrs, scheme), 0, err
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	addrs := make([]*net.SRV, 0, len(records))
+	for _, r := range records {
+		srv, ok := r.Body.(*dnsmessage.SRVResource)
+		if !ok {
+			return nil, 0, ErrMalformedMessage
+		}
+		addrs = append(addrs, &net.SRV{
+			Target:   srv.Target.String(),
+			Port:     srv.Port,
+			Priority: srv.Priority,
+			Weight:   srv.Weight,
+		})
+	}
+	return srvHosts(addrs, scheme), minTTL(records), nil
+}
+
+func srvHosts(addrs []*net.SRV, scheme string) []string {
+	sort.SliceStable(addrs, func(i, j int) bool { return addrs[i].Priority < addrs[j].Priority })
+	hosts := make([]string, len(addrs))
+	for i, addr := range addrs {
+		hosts[i] = fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(trimDot(addr.Target), fmt.Sprint(addr.Port)))
+	}
+	return hosts
+}
+
+func resolveA(c client, name, scheme string, port int) ([]string, time.Duration, error) {
+	records, err := c.query(name, dnsmessage.TypeA, dnsmessage.TypeAAAA)
+	if err == ErrNoNameservers {
+		ips, err := net.LookupIP(name)
+		return ipHosts(ips, scheme, port), 0, err
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ips := make([]net.IP, 0, len(records))
+	for _, r := range records {
+		switch body := r.Body.(type) {
+		case *dnsmessage.AResource:
+			ips = append(ips, net.IP(body.A[:]))
+		case *dnsmessage.AAAAResource:
+			ips = append(ips, net.IP(body.AAAA[:]))
+		default:
+			return nil, 0, ErrMalformedMessage
+		}
+	}
+	return ipHosts(ips, scheme, port), minTTL(records), nil
+}
+
+func ipHosts(ips []net.IP, scheme string, port int) []string {
+	hosts := make([]string, len(ips))
+	for i, ip := range ips {
+		host := ip.String()
+		if port > 0 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		} else if ip.To4() == nil {
+			host = "[" + host + "]"
+		}
+		hosts[i] = scheme + "://" + host
+	}
+	return hosts
+}
+
+func minTTL(records []dnsmessage.Resource) time.Duration {
+	if len(records) == 0 {
+		return 0
+	}
+	ttl := records[0].Header.TTL
+	for _, r := range records[1:] {
+		if r.Header.TTL < ttl {
+			ttl = r.Header.TTL
+		}
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+func trimDot(name string) string {
+	if len(name) > 0 && name[len(name)-1] == '.' {
+		return name[:len(name)-1]
+	}
+	return name
+}
@@ -0,0 +1,135 @@
+package dns
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+func TestRegister(t *testing.T) {
+	if err := Register(); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+}
+
+func TestSRVSubscriberFactory(t *testing.T) {
+	server := startServer(t, func(query []byte) []byte {
+		return buildResponse(query, dnsmessage.RCodeSuccess, false,
+			srvAnswer(30, 1, 8080, "a.example."),
+			srvAnswer(30, 0, 8081, "b.example."),
+		)
+	})
+	Register()
+	s := sd.GetSubscriber(&config.Backend{
+		Host: []string{"supu.example"},
+		SD:   SRVName,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"nameservers": []interface{}{server},
+			"scheme":      "https",
+		}},
+	})
+	hosts, err := s.Hosts()
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if len(hosts) != 2 || hosts[0] != "https://b.example:8081" || hosts[1] != "https://a.example:8080" {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+}
+
+func TestASubscriberFactory(t *testing.T) {
+	server := startServer(t, func(query []byte) []byte {
+		if _, q := parseQuery(query); q.Type == dnsmessage.TypeAAAA {
+			return buildResponse(query, dnsmessage.RCodeSuccess, false, aaaaAnswer(30, "::1"))
+		}
+		return buildResponse(query, dnsmessage.RCodeSuccess, false, aAnswer(30, "10.0.0.1"))
+	})
+	s := ASubscriberFactory(&config.Backend{
+		Host: []string{"supu.example"},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"nameservers": []interface{}{server},
+			"port":        9000.0,
+		}},
+	})
+	hosts, err := s.Hosts()
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	if len(hosts) != 2 || hosts[0] != "http://10.0.0.1:9000" || hosts[1] != "http://[::1]:9000" {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+}
+
+func TestASubscriberFactory_notFound(t *testing.T) {
+	server := startServer(t, func(query []byte) []byte {
+		return buildResponse(query, dnsmessage.RCodeNameError, false)
+	})
+	s := ASubscriberFactory(&config.Backend{
+		Host: []string{"supu.example."},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"nameservers": []interface{}{server},
+		}},
+	})
+	if hosts, err := s.Hosts(); err != ErrNameNotFound || len(hosts) != 0 {
+		t.Errorf("unexpected result: %v %v", hosts, err)
+	}
+}
+
+func TestSubscriber_refresh(t *testing.T) {
+	responses := make(chan []string, 3)
+	responses <- []string{"http://a"}
+	responses <- nil
+	responses <- []string{"http://b"}
+	resolved := make(chan struct{}, 3)
+
+	s := newSubscriber(Config{RefreshInterval: 10 * time.Millisecond, MinRefresh: 5 * time.Millisecond, Jitter: 0.1},
+		func() ([]string, time.Duration, error) {
+			defer func() { resolved <- struct{}{} }()
+			select {
+			case hosts := <-responses:
+				if hosts == nil {
+					return nil, 0, errors.New("supu")
+				}
+				return hosts, time.Millisecond, nil
+			default:
+				return []string{"http://b"}, time.Hour, nil
+			}
+		})
+
+	<-resolved
+	if hosts, _ := s.Hosts(); len(hosts) != 1 || hosts[0] != "http://a" {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+
+	// the failed resolutions keep the previous hosts
+	<-resolved
+	if hosts, _ := s.Hosts(); len(hosts) != 1 || hosts[0] != "http://a" {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+
+	<-resolved
+	if hosts, _ := s.Hosts(); len(hosts) != 1 || hosts[0] != "http://b" {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+}
+
+func TestSubscriber_refreshInterval(t *testing.T) {
+	s := &subscriber{cfg: Config{RefreshInterval: 10 * time.Second, MinRefresh: time.Second, Jitter: 0.5}}
+	for ttl, expected := range map[time.Duration]time.Duration{
+		0:                      10 * time.Second,
+		100 * time.Millisecond: time.Second,
+		20 * time.Second:       20 * time.Second,
+	} {
+		d := s.refreshInterval(ttl)
+		if d < expected || d > expected+expected/2 {
+			t.Errorf("%v: unexpected interval %v", ttl, d)
+		}
+	}
+}
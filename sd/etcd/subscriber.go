@@ -0,0 +1,246 @@
+// Package etcd provides a subscriber watching the hosts registered under a key prefix of etcd
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+const (
+	// Name is the name of the subscriber and the scheme of its hosts
+	Name = "etcd"
+	// Namespace is the key to look for the options of the subscriber in the extra config of the backends
+	Namespace = "github.com/devopsfaith/krakend/sd/etcd"
+
+	deleteEvent = "DELETE"
+)
+
+var (
+	// DefaultEndpoint is the etcd endpoint used when the host does not declare it
+	DefaultEndpoint = "http://127.0.0.1:2379"
+	// MaxRetryWait is the max time to wait before watching again after a failure
+	MaxRetryWait = 30 * time.Second
+
+	// ErrNoPrefix is the error returned when the host does not declare the key prefix
+	ErrNoPrefix = errors.New("the etcd key prefix is required")
+)
+
+// Register registers the etcd subscriber factory
+func Register() error {
+	return sd.RegisterSubscriberFactory(Name, SubscriberFactory)
+}
+
+// SubscriberFactory builds an etcd subscriber with the first host of the backend, with the format
+// etcd://[endpoint host]/<key prefix>. The value of every key under the prefix is a host of the backend,
+// so the services can register themselves with a key attached to a lease. The 'endpoint' can also be
+// declared in the extra config of the backend
+func SubscriberFactory(cfg *config.Backend) sd.Subscriber {
+	u, err := url.Parse(cfg.Host[0])
+	if err != nil {
+		return sd.SubscriberFunc(func() ([]string, error) { return nil, err })
+	}
+	if u.Path == "" || u.Path == "/" {
+		return sd.SubscriberFunc(func() ([]string, error) { return nil, ErrNoPrefix })
+	}
+	endpoint := DefaultEndpoint
+	if extra, ok := cfg.ExtraConfig[Namespace].(map[string]interface{}); ok {
+		if v, ok := extra["endpoint"].(string); ok {
+			endpoint = v
+		}
+	}
+	if u.Host != "" {
+		endpoint = "http://" + u.Host
+	}
+	return New(endpoint, u.Path, http.DefaultClient)
+}
+
+// New creates a subscriber with the values of the keys under the prefix, updated with a watch
+func New(endpoint, prefix string, client *http.Client) sd.Subscriber {
+	s := &subscriber{
+		endpoint: endpoint,
+		prefix:   []byte(prefix),
+		client:   client,
+		hosts:    map[string]string{},
+	}
+	revision, _ := s.list()
+	go s.loop(revision)
+	return s
+}
+
+type subscriber struct {
+	endpoint string
+	prefix   []byte
+	client   *http.Client
+
+	mu    sync.RWMutex
+	hosts map[string]string
+}
+
+type keyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type responseHeader struct {
+	Revision string `json:"revision"`
+}
+
+type rangeResponse struct {
+	Header responseHeader `json:"header"`
+	Kvs    []keyValue     `json:"kvs"`
+}
+
+type watchResponse struct {
+	Result struct {
+		Header   responseHeader `json:"header"`
+		Canceled bool           `json:"canceled"`
+		Events   []struct {
+			Type string   `json:"type"`
+			Kv   keyValue `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Hosts implements the sd.Subscriber interface
+func (s *subscriber) Hosts() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.hosts))
+	for k := range s.hosts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	hosts := make([]string, len(keys))
+	for i, k := range keys {
+		hosts[i] = s.hosts[k]
+	}
+	return hosts, nil
+}
+
+func (s *subscriber) loop(revision int64) {
+	retryWait := time.Second
+	for {
+		if revision > 0 {
+			// the watch only returns on failures, so the hosts are listed again before watching
+			s.watch(revision + 1)
+		}
+		time.Sleep(retryWait)
+		if retryWait *= 2; retryWait > MaxRetryWait {
+			retryWait = MaxRetryWait
+		}
+		next, err := s.list()
+		if err != nil {
+			continue
+		}
+		revision = next
+		retryWait = time.Second
+	}
+}
+
+// list replaces the hosts with the current values of the keys, returning the revision of the store
+func (s *subscriber) list() (int64, error) {
+	resp := rangeResponse{}
+	if err := s.post("/v3/kv/range", map[string]interface{}{
+		"key":       s.prefix,
+		"range_end": prefixEnd(s.prefix),
+	}, &resp); err != nil {
+		return 0, err
+	}
+	hosts := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		hosts[string(kv.Key)] = string(kv.Value)
+	}
+	s.mu.Lock()
+	s.hosts = hosts
+	s.mu.Unlock()
+	return strconv.ParseInt(resp.Header.Revision, 10, 64)
+}
+
+// watch applies the changes of the keys since the revision until the stream fails
+func (s *subscriber) watch(revision int64) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            s.prefix,
+			"range_end":      prefixEnd(s.prefix),
+			"start_revision": strconv.FormatInt(revision, 10),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.endpoint+"/v3/watch", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("etcd: unexpected status code %d", resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		w := watchResponse{}
+		if err := decoder.Decode(&w); err != nil {
+			return err
+		}
+		if w.Error != nil {
+			return errors.New("etcd: " + w.Error.Message)
+		}
+		if w.Result.Canceled {
+			return errors.New("etcd: the watch was canceled")
+		}
+		s.mu.Lock()
+		for _, e := range w.Result.Events {
+			if e.Type == deleteEvent {
+				delete(s.hosts, string(e.Kv.Key))
+				continue
+			}
+			s.hosts[string(e.Kv.Key)] = string(e.Kv.Value)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *subscriber) post(path string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := s.client.Post(s.endpoint+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("etcd: unexpected status code %d", r.StatusCode)
+	}
+	return json.NewDecoder(r.Body).Decode(resp)
+}
+
+// prefixEnd returns the end of the range of the keys with the prefix
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// all the keys
+	return []byte{0}
+}
@@ -0,0 +1,105 @@
+package etcd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+func encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestSubscriber(t *testing.T) {
+	watches := make(chan map[string]map[string]string, 1)
+	events := make(chan string, 2)
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			fmt.Fprintf(w, `{"header":{"revision":"7"},"kvs":[{"key":"%s","value":"%s"},{"key":"%s","value":"%s"}]}`,
+				encode("/services/payments/b"), encode("http://10.0.0.2:8080"),
+				encode("/services/payments/a"), encode("http://10.0.0.1:8080"))
+		case "/v3/watch":
+			req := map[string]map[string]string{}
+			json.NewDecoder(r.Body).Decode(&req)
+			select {
+			case watches <- req:
+			default:
+			}
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case e := <-events:
+					fmt.Fprint(w, e)
+					w.(http.Flusher).Flush()
+				case <-done:
+					return
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	Register()
+	s := sd.GetSubscriber(&config.Backend{
+		Host:        []string{"etcd:///services/payments/"},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"endpoint": server.URL}},
+	})
+
+	hosts, _ := s.Hosts()
+	if !reflect.DeepEqual(hosts, []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}) {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+
+	req := <-watches
+	expected := map[string]string{
+		"key":            encode("/services/payments/"),
+		"range_end":      encode("/services/payments0"),
+		"start_revision": "8",
+	}
+	if !reflect.DeepEqual(req["create_request"], expected) {
+		t.Errorf("unexpected watch request: %v", req)
+	}
+
+	events <- `{"result":{"header":{"revision":"8"},"created":true}}`
+	events <- fmt.Sprintf(`{"result":{"header":{"revision":"9"},"events":[{"type":"DELETE","kv":{"key":"%s"}},{"kv":{"key":"%s","value":"%s"}}]}}`,
+		encode("/services/payments/a"), encode("/services/payments/c"), encode("http://10.0.0.3:8080"))
+	time.Sleep(50 * time.Millisecond)
+
+	hosts, _ = s.Hosts()
+	if !reflect.DeepEqual(hosts, []string{"http://10.0.0.2:8080", "http://10.0.0.3:8080"}) {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+}
+
+func TestSubscriberFactory_noPrefix(t *testing.T) {
+	s := SubscriberFactory(&config.Backend{Host: []string{"etcd://127.0.0.1:2379"}})
+	if _, err := s.Hosts(); err != ErrNoPrefix {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	for prefix, expected := range map[string]string{
+		"/supu/":     "/supu0",
+		"a\xff":      "b",
+		"\xff\xff":   "\x00",
+		"/supu/tupu": "/supu/tupv",
+	} {
+		if end := string(prefixEnd([]byte(prefix))); end != expected {
+			t.Errorf("%q: unexpected end %q", prefix, end)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Package eureka provides a subscriber watching the instances of an application registered in Eureka
+package eureka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+const (
+	// Name is the name of the subscriber and the scheme of its hosts
+	Name = "eureka"
+	// Namespace is the key to look for the options of the subscriber in the extra config of the backends
+	Namespace = "github.com/devopsfaith/krakend/sd/eureka"
+
+	statusUp       = "UP"
+	actionAdded    = "ADDED"
+	actionModified = "MODIFIED"
+	actionDeleted  = "DELETED"
+)
+
+var (
+	// DefaultServer is the URL of the Eureka server used when the host does not declare it
+	DefaultServer = "http://127.0.0.1:8761/eureka"
+	// DefaultRefreshInterval is the time between two polls of the registry
+	DefaultRefreshInterval = 30 * time.Second
+
+	// ErrNoApp is the error returned when the host does not declare the name of the application
+	ErrNoApp = errors.New("the name of the eureka application is required")
+)
+
+// Register registers the Eureka subscriber factory
+func Register() error {
+	return sd.RegisterSubscriberFactory(Name, SubscriberFactory)
+}
+
+// Config defines the application to watch
+type Config struct {
+	// Server is the URL of the Eureka server, including its context path
+	Server string
+	// App is the name of the application
+	App string
+	// Zone is the zone of the gateway. When there are instances up in the same zone, the rest are ignored
+	Zone string
+	// RefreshInterval is the time between two polls of the deltas of the registry
+	RefreshInterval time.Duration
+}
+
+// SubscriberFactory builds a Eureka subscriber with the first host of the backend, with the format
+// eureka://[server host]/<app>?zone=<zone>. The 'server' URL, the 'zone' and the 'refresh_interval'
+// can be declared in the extra config of the backend
+func SubscriberFactory(cfg *config.Backend) sd.Subscriber {
+	c, err := ParseHost(cfg.Host[0])
+	if err != nil {
+		return sd.SubscriberFunc(func() ([]string, error) { return nil, err })
+	}
+	if extra, ok := cfg.ExtraConfig[Namespace].(map[string]interface{}); ok {
+		if v, ok := extra["server"].(string); ok && c.Server == "" {
+			c.Server = v
+		}
+		if v, ok := extra["zone"].(string); ok && c.Zone == "" {
+			c.Zone = v
+		}
+		if v, ok := extra["refresh_interval"].(string); ok {
+			c.RefreshInterval, _ = time.ParseDuration(v)
+		}
+	}
+	return New(c, http.DefaultClient)
+}
+
+// ParseHost parses the host of a backend with the eureka scheme
+func ParseHost(host string) (Config, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return Config{}, err
+	}
+	c := Config{
+		App:  strings.ToUpper(strings.Trim(u.Path, "/")),
+		Zone: u.Query().Get("zone"),
+	}
+	if c.App == "" {
+		return c, ErrNoApp
+	}
+	if u.Host != "" {
+		c.Server = "http://" + u.Host + "/eureka"
+	}
+	return c, nil
+}
+
+// New creates a subscriber with a full fetch of the application, updated by polling the deltas of the registry
+func New(cfg Config, client *http.Client) sd.Subscriber {
+	if cfg.Server == "" {
+		cfg.Server = DefaultServer
+	}
+	cfg.Server = strings.TrimRight(cfg.Server, "/")
+	cfg.App = strings.ToUpper(cfg.App)
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = DefaultRefreshInterval
+	}
+	s := &subscriber{cfg: cfg, client: client, instances: map[string]instance{}, hosts: []string{}}
+	err := s.fetch()
+	go s.loop(err == nil)
+	return s
+}
+
+type subscriber struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.RWMutex
+	instances map[string]instance
+	hosts     []string
+}
+
+type port struct {
+	Port    int    `json:"$"`
+	Enabled string `json:"@enabled"`
+}
+
+type instance struct {
+	InstanceID     string            `json:"instanceId"`
+	HostName       string            `json:"hostName"`
+	App            string            `json:"app"`
+	IPAddr         string            `json:"ipAddr"`
+	Status         string            `json:"status"`
+	Port           port              `json:"port"`
+	SecurePort     port              `json:"securePort"`
+	Metadata       map[string]string `json:"metadata"`
+	DataCenterInfo struct {
+		Metadata map[string]string `json:"metadata"`
+	} `json:"dataCenterInfo"`
+	ActionType string `json:"actionType"`
+}
+
+type application struct {
+	Name     string          `json:"name"`
+	Instance json.RawMessage `json:"instance"`
+}
+
+// Hosts implements the sd.Subscriber interface
+func (s *subscriber) Hosts() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hosts, nil
+}
+
+func (s *subscriber) loop(fetched bool) {
+	for {
+		time.Sleep(s.cfg.RefreshInterval)
+		// the deltas only can be applied over a complete view of the application
+		if !fetched {
+			fetched = s.fetch() == nil
+			continue
+		}
+		fetched = s.delta() == nil
+	}
+}
+
+// fetch replaces the instances with the ones of the application registered in the server
+func (s *subscriber) fetch() error {
+	resp := struct {
+		Application application `json:"application"`
+	}{}
+	if err := s.get("/apps/"+url.PathEscape(s.cfg.App), &resp); err != nil {
+		return err
+	}
+	received, err := decodeInstances(resp.Application.Instance)
+	if err != nil {
+		return err
+	}
+	instances := make(map[string]instance, len(received))
+	for _, i := range received {
+		instances[instanceKey(i)] = i
+	}
+	s.mu.Lock()
+	s.instances = instances
+	s.hosts = s.cfg.hosts(instances)
+	s.mu.Unlock()
+	return nil
+}
+
+// delta applies the changes of the registry received since the last poll
+func (s *subscriber) delta() error {
+	resp := struct {
+		Applications struct {
+			Application json.RawMessage `json:"application"`
+		} `json:"applications"`
+	}{}
+	if err := s.get("/apps/delta", &resp); err != nil {
+		return err
+	}
+	apps := []application{}
+	if err := decodeList(resp.Applications.Application, &apps); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, app := range apps {
+		if strings.ToUpper(app.Name) != s.cfg.App {
+			continue
+		}
+		received, err := decodeInstances(app.Instance)
+		if err != nil {
+			return err
+		}
+		for _, i := range received {
+			switch i.ActionType {
+			case actionAdded, actionModified:
+				s.instances[instanceKey(i)] = i
+			case actionDeleted:
+				delete(s.instances, instanceKey(i))
+			}
+		}
+	}
+	s.hosts = s.cfg.hosts(s.instances)
+	return nil
+}
+
+func (s *subscriber) get(path string, resp interface{}) error {
+	req, err := http.NewRequest("GET", s.cfg.Server+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	r, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("eureka: unexpected status code %d", r.StatusCode)
+	}
+	return json.NewDecoder(r.Body).Decode(resp)
+}
+
+// hosts returns the sorted hosts of the instances up, preferring the ones in the zone of the gateway
+func (c Config) hosts(instances map[string]instance) []string {
+	all := []string{}
+	local := []string{}
+	for _, i := range instances {
+		if i.Status != statusUp {
+			continue
+		}
+		host := i.host()
+		all = append(all, host)
+		if c.Zone != "" && i.zone() == c.Zone {
+			local = append(local, host)
+		}
+	}
+	if len(local) > 0 {
+		all = local
+	}
+	sort.Strings(all)
+	return all
+}
+
+func (i instance) host() string {
+	addr := i.IPAddr
+	if addr == "" {
+		addr = i.HostName
+	}
+	if i.SecurePort.Enabled == "true" {
+		return "https://" + net.JoinHostPort(addr, strconv.Itoa(i.SecurePort.Port))
+	}
+	return "http://" + net.JoinHostPort(addr, strconv.Itoa(i.Port.Port))
+}
+
+func (i instance) zone() string {
+	if z, ok := i.Metadata["zone"]; ok {
+		return z
+	}
+	return i.DataCenterInfo.Metadata["availability-zone"]
+}
+
+func instanceKey(i instance) string {
+	if i.InstanceID != "" {
+		return i.InstanceID
+	}
+	return i.HostName + ":" + strconv.Itoa(i.Port.Port)
+}
+
+func decodeInstances(data json.RawMessage) ([]instance, error) {
+	instances := []instance{}
+	err := decodeList(data, &instances)
+	return instances, err
+}
+
+// decodeList decodes the lists of the server, encoded as a single object when they have just one element
+func decodeList(data json.RawMessage, v interface{}) error {
+	trimmed := strings.TrimSpace(string(data))
+	switch {
+	case trimmed == "" || trimmed == "null":
+		return nil
+	case trimmed[0] == '{':
+		trimmed = "[" + trimmed + "]"
+	}
+	return json.Unmarshal([]byte(trimmed), v)
+}
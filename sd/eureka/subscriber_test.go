@@ -0,0 +1,131 @@
+package eureka
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/sd"
+)
+
+func TestParseHost(t *testing.T) {
+	c, err := ParseHost("eureka://registry:8761/payments?zone=eu-1")
+	if err != nil {
+		t.Error("unexpected error:", err.Error())
+		return
+	}
+	expected := Config{Server: "http://registry:8761/eureka", App: "PAYMENTS", Zone: "eu-1"}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("unexpected config: %+v", c)
+	}
+
+	if _, err := ParseHost("eureka:///"); err != ErrNoApp {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSubscriber(t *testing.T) {
+	deltas := []string{
+		`{"applications":{"application":{"name":"PAYMENTS","instance":[
+			{"instanceId":"c","ipAddr":"10.0.0.3","status":"UP","port":{"$":8080,"@enabled":"true"},"metadata":{"zone":"eu-1"},"actionType":"ADDED"},
+			{"instanceId":"a","actionType":"DELETED"}
+		]}}}`,
+		`{"applications":{"application":[
+			{"name":"ORDERS","instance":{"instanceId":"x","ipAddr":"10.0.1.1","status":"UP","port":{"$":80},"actionType":"ADDED"}},
+			{"name":"PAYMENTS","instance":{"instanceId":"c","ipAddr":"10.0.0.3","status":"DOWN","port":{"$":8080},"metadata":{"zone":"eu-1"},"actionType":"MODIFIED"}}
+		]}}`,
+	}
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json" {
+			t.Error("unexpected accept header:", r.Header.Get("Accept"))
+		}
+		switch r.URL.Path {
+		case "/eureka/apps/PAYMENTS":
+			fmt.Fprint(w, `{"application":{"name":"PAYMENTS","instance":[
+				{"instanceId":"a","ipAddr":"10.0.0.1","status":"UP","port":{"$":8080,"@enabled":"true"},"dataCenterInfo":{"metadata":{"availability-zone":"eu-2"}}},
+				{"instanceId":"b","ipAddr":"10.0.0.2","status":"UP","port":{"$":8080,"@enabled":"false"},"securePort":{"$":8443,"@enabled":"true"}},
+				{"instanceId":"d","ipAddr":"10.0.0.4","status":"OUT_OF_SERVICE","port":{"$":8080,"@enabled":"true"}}
+			]}}`)
+		case "/eureka/apps/delta":
+			index := int(atomic.AddInt32(&calls, 1))
+			if index > len(deltas) {
+				fmt.Fprint(w, `{"applications":{}}`)
+				return
+			}
+			fmt.Fprint(w, deltas[index-1])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	Register()
+	s := sd.GetSubscriber(&config.Backend{
+		Host: []string{"eureka:///payments"},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"server":           server.URL + "/eureka/",
+			"zone":             "eu-1",
+			"refresh_interval": "50ms",
+		}},
+	})
+
+	hosts, _ := s.Hosts()
+	if !reflect.DeepEqual(hosts, []string{"http://10.0.0.1:8080", "https://10.0.0.2:8443"}) {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+
+	// the instances in the zone of the gateway are preferred
+	time.Sleep(75 * time.Millisecond)
+	hosts, _ = s.Hosts()
+	if !reflect.DeepEqual(hosts, []string{"http://10.0.0.3:8080"}) {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+
+	// without instances up in the zone, all the rest are used
+	time.Sleep(50 * time.Millisecond)
+	hosts, _ = s.Hosts()
+	if !reflect.DeepEqual(hosts, []string{"https://10.0.0.2:8443"}) {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+}
+
+func TestSubscriber_fetchFailure(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/eureka/apps/delta" {
+			t.Error("the delta was requested without a complete view of the application")
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"application":{"name":"PAYMENTS","instance":{"instanceId":"a","ipAddr":"10.0.0.1","status":"UP","port":{"$":8080}}}}`)
+	}))
+	defer server.Close()
+
+	s := New(Config{Server: server.URL + "/eureka", App: "payments", RefreshInterval: time.Hour}, http.DefaultClient)
+	if hosts, _ := s.Hosts(); len(hosts) != 0 {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+
+	sub := s.(*subscriber)
+	if err := sub.fetch(); err != nil {
+		t.Error("unexpected error:", err.Error())
+	}
+	if hosts, _ := s.Hosts(); !reflect.DeepEqual(hosts, []string{"http://10.0.0.1:8080"}) {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+}
+
+func TestSubscriberFactory_badHost(t *testing.T) {
+	s := SubscriberFactory(&config.Backend{Host: []string{"eureka:///"}})
+	if _, err := s.Hosts(); err != ErrNoApp {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package sd
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthCheckTimeout = time.Second
+	defaultMaxFailures        = 5
+	defaultEjectionTime       = 30 * time.Second
+	defaultHealthyThreshold   = 1
+	defaultUnhealthyThreshold = 2
+	defaultHealthCheckPort    = "80"
+)
+
+// HealthCheckConfig defines the active and passive health checks of the hosts
+type HealthCheckConfig struct {
+	// Interval is the period of the active probes. Zero disables them
+	Interval time.Duration
+	// Timeout is the max duration of every active probe
+	Timeout time.Duration
+	// Path is the path requested by the HTTP probes. If empty, the probes just open a TCP connection
+	Path string
+	// HealthyThreshold is the number of consecutive successful probes required to re-admit a host
+	HealthyThreshold int
+	// UnhealthyThreshold is the number of consecutive failed probes required to eject a host
+	UnhealthyThreshold int
+	// MaxFailures is the number of consecutive failed requests required to eject a host
+	MaxFailures int
+	// EjectionTime is the time a host ejected by the failed requests is skipped
+	EjectionTime time.Duration
+}
+
+// HealthCheckedSubscriber is a Subscriber skipping the unhealthy hosts of the wrapped one. The hosts are
+// ejected when they fail the active probes or when the reported requests fail consecutively, and they
+// are re-admitted once they pass the probes again or after the ejection time, respectively. If all the
+// hosts are unhealthy, all of them are returned, so the backend can still be reached
+type HealthCheckedSubscriber struct {
+	subscriber Subscriber
+	cfg        HealthCheckConfig
+	client     *http.Client
+	now        func() time.Time
+	stop       chan struct{}
+
+	mu     sync.Mutex
+	health map[string]*hostHealth
+}
+
+type hostHealth struct {
+	probeFailures  int
+	probeSuccesses int
+	unhealthy      bool
+	failures       int
+	ejectedUntil   time.Time
+}
+
+// NewHealthCheckedSubscriber wraps the received subscriber with the health checks. The active probes
+// start in background when the interval is defined
+func NewHealthCheckedSubscriber(subscriber Subscriber, cfg HealthCheckConfig) *HealthCheckedSubscriber {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHealthCheckTimeout
+	}
+	if cfg.HealthyThreshold <= 0 {
+		cfg.HealthyThreshold = defaultHealthyThreshold
+	}
+	if cfg.UnhealthyThreshold <= 0 {
+		cfg.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = defaultMaxFailures
+	}
+	if cfg.EjectionTime <= 0 {
+		cfg.EjectionTime = defaultEjectionTime
+	}
+	h := &HealthCheckedSubscriber{
+		subscriber: subscriber,
+		cfg:        cfg,
+		client:     &http.Client{Timeout: cfg.Timeout},
+		now:        time.Now,
+		stop:       make(chan struct{}),
+		health:     map[string]*hostHealth{},
+	}
+	if cfg.Interval > 0 {
+		go h.loop()
+	}
+	return h
+}
+
+// Hosts implements the Subscriber interface
+func (h *HealthCheckedSubscriber) Hosts() ([]string, error) {
+	hosts, err := h.subscriber.Hosts()
+	if err != nil {
+		return hosts, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	healthy := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		s, ok := h.health[HostKey(host)]
+		if ok && (s.unhealthy || now.Before(s.ejectedUntil)) {
+			continue
+		}
+		healthy = append(healthy, host)
+	}
+	if len(healthy) == 0 {
+		return hosts, nil
+	}
+	return healthy, nil
+}
+
+// AllHosts returns the hosts of the wrapped subscriber, including the unhealthy ones
+func (h *HealthCheckedSubscriber) AllHosts() ([]string, error) {
+	return h.subscriber.Hosts()
+}
+
+// Report registers the result of a request sent to the host, for the passive health checks
+func (h *HealthCheckedSubscriber) Report(host string, success bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.stateFor(HostKey(host))
+	if success {
+		s.failures = 0
+		return
+	}
+	s.failures++
+	if s.failures >= h.cfg.MaxFailures {
+		s.failures = 0
+		s.ejectedUntil = h.now().Add(h.cfg.EjectionTime)
+	}
+}
+
+// Ejected returns true if the host is failing the active probes or it is ejected by the passive health checks
+func (h *HealthCheckedSubscriber) Ejected(host string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.health[HostKey(host)]
+	return ok && (s.unhealthy || h.now().Before(s.ejectedUntil))
+}
+
+// Close stops the active probes
+func (h *HealthCheckedSubscriber) Close() {
+	close(h.stop)
+}
+
+func (h *HealthCheckedSubscriber) loop() {
+	ticker := time.NewTicker(h.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		h.checkAll()
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *HealthCheckedSubscriber) checkAll() {
+	hosts, err := h.subscriber.Hosts()
+	if err != nil {
+		return
+	}
+	wg := sync.WaitGroup{}
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			h.probeResult(host, h.probe(host))
+			wg.Done()
+		}(host)
+	}
+	wg.Wait()
+}
+
+func (h *HealthCheckedSubscriber) probe(host string) bool {
+	u, err := url.Parse(host)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if h.cfg.Path == "" {
+		addr := u.Host
+		if u.Port() == "" {
+			port := defaultHealthCheckPort
+			if u.Scheme == "https" {
+				port = "443"
+			}
+			addr = net.JoinHostPort(u.Hostname(), port)
+		}
+		conn, err := net.DialTimeout("tcp", addr, h.cfg.Timeout)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+	resp, err := h.client.Get(u.Scheme + "://" + u.Host + h.cfg.Path)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
+
+func (h *HealthCheckedSubscriber) probeResult(host string, success bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.stateFor(HostKey(host))
+	if success {
+		s.probeFailures = 0
+		s.probeSuccesses++
+		if s.probeSuccesses >= h.cfg.HealthyThreshold {
+			s.unhealthy = false
+		}
+		return
+	}
+	s.probeSuccesses = 0
+	s.probeFailures++
+	if s.probeFailures >= h.cfg.UnhealthyThreshold {
+		s.unhealthy = true
+	}
+}
+
+func (h *HealthCheckedSubscriber) stateFor(key string) *hostHealth {
+	s, ok := h.health[key]
+	if !ok {
+		s = &hostHealth{}
+		h.health[key] = s
+	}
+	return s
+}
+
+// HostKey normalizes the host to its scheme and authority, so the hosts of the subscribers and the URLs
+// of the requests can be compared
+func HostKey(host string) string {
+	u, err := url.Parse(host)
+	if err != nil || u.Host == "" {
+		return host
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,117 @@
+package sd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckedSubscriber_passive(t *testing.T) {
+	h := NewHealthCheckedSubscriber(FixedSubscriber{"http://a:8080", "http://b:8080/"}, HealthCheckConfig{
+		MaxFailures:  2,
+		EjectionTime: time.Second,
+	})
+	now := time.Now()
+	h.now = func() time.Time { return now }
+
+	h.Report("http://b:8080", false)
+	h.Report("http://b:8080", true)
+	h.Report("http://b:8080", false)
+	if hosts, _ := h.Hosts(); len(hosts) != 2 {
+		t.Errorf("the non consecutive failures ejected the host: %v", hosts)
+	}
+
+	h.Report("http://b:8080", false)
+	hosts, _ := h.Hosts()
+	if len(hosts) != 1 || hosts[0] != "http://a:8080" {
+		t.Errorf("the host was not ejected: %v", hosts)
+	}
+	if !h.Ejected("http://b:8080/supu") || h.Ejected("http://a:8080") {
+		t.Error("unexpected ejection state")
+	}
+
+	// all the hosts are returned when none of them is healthy
+	h.Report("http://a:8080", false)
+	h.Report("http://a:8080", false)
+	if hosts, _ := h.Hosts(); len(hosts) != 2 {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+
+	now = now.Add(time.Second)
+	if hosts, _ := h.Hosts(); len(hosts) != 2 {
+		t.Errorf("the hosts were not re-admitted: %v", hosts)
+	}
+	if h.Ejected("http://b:8080") {
+		t.Error("the host is still ejected")
+	}
+}
+
+func TestHealthCheckedSubscriber_active(t *testing.T) {
+	var healthy int32 = 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/__health" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	h := NewHealthCheckedSubscriber(FixedSubscriber{server.URL, "http://127.0.0.1:1"}, HealthCheckConfig{
+		Interval:           5 * time.Millisecond,
+		Path:               "/__health",
+		UnhealthyThreshold: 1,
+	})
+	defer h.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	hosts, _ := h.Hosts()
+	if len(hosts) != 1 || hosts[0] != server.URL {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+
+	atomic.StoreInt32(&healthy, 0)
+	time.Sleep(50 * time.Millisecond)
+	if hosts, _ := h.Hosts(); len(hosts) != 2 {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(50 * time.Millisecond)
+	if hosts, _ := h.Hosts(); len(hosts) != 1 || hosts[0] != server.URL {
+		t.Errorf("the host was not re-admitted: %v", hosts)
+	}
+}
+
+func TestHealthCheckedSubscriber_tcpProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the tcp probes should not send requests")
+	}))
+	defer server.Close()
+
+	h := NewHealthCheckedSubscriber(FixedSubscriber{}, HealthCheckConfig{Timeout: 10 * time.Millisecond})
+	if !h.probe(server.URL) {
+		t.Error("the probe failed")
+	}
+	if h.probe("http://127.0.0.1:1") {
+		t.Error("the probe succeeded")
+	}
+	if h.probe("not a url") {
+		t.Error("the probe succeeded")
+	}
+}
+
+func TestHostKey(t *testing.T) {
+	for host, expected := range map[string]string{
+		"http://supu:8080":       "http://supu:8080",
+		"http://supu:8080/tupu/": "http://supu:8080",
+		"supu":                   "supu",
+	} {
+		if k := HostKey(host); k != expected {
+			t.Errorf("%s: unexpected key %s", host, k)
+		}
+	}
+}
@@ -1,21 +1,69 @@
 package sd
 
-import "github.com/devopsfaith/krakend/config"
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"sync"
 
-// RegisterSubscriberFactory registers the received subscriber factory for later usage
+	"github.com/devopsfaith/krakend/config"
+)
+
+var (
+	// ErrNoSubscriberName is the error returned when registering a subscriber factory without name
+	ErrNoSubscriberName = errors.New("the name of the subscriber factory is required")
+	// ErrNilSubscriberFactory is the error returned when registering a nil subscriber factory
+	ErrNilSubscriberFactory = errors.New("the subscriber factory can not be nil")
+)
+
+// RegisterSubscriberFactory registers the received subscriber factory for later usage, so the
+// backends declaring the name in their 'sd' field (or as the scheme of their first host) get
+// their subscribers from it. Registering a name twice replaces the previous factory
 func RegisterSubscriberFactory(name string, sf SubscriberFactory) error {
-	subscriberFactories[name] = sf
+	if name == "" {
+		return ErrNoSubscriberName
+	}
+	if sf == nil {
+		return ErrNilSubscriberFactory
+	}
+	subscriberFactories.Lock()
+	subscriberFactories.data[name] = sf
+	subscriberFactories.Unlock()
 	return nil
 }
 
+// SubscriberFactories returns the sorted names of the registered subscriber factories
+func SubscriberFactories() []string {
+	subscriberFactories.RLock()
+	names := make([]string, 0, len(subscriberFactories.data))
+	for name := range subscriberFactories.data {
+		names = append(names, name)
+	}
+	subscriberFactories.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
 // GetSubscriber gets the subscriber factory by name or a fixed subscriber factory if
-// the name is not registered
+// the name is not registered. If the backend does not declare the name, the scheme of
+// its first host is used, so hosts like consul:///service select their subscriber
 func GetSubscriber(cfg *config.Backend) Subscriber {
-	sf, ok := subscriberFactories[cfg.SD]
+	name := cfg.SD
+	if name == "" && len(cfg.Host) > 0 {
+		if u, err := url.Parse(cfg.Host[0]); err == nil {
+			name = u.Scheme
+		}
+	}
+	subscriberFactories.RLock()
+	sf, ok := subscriberFactories.data[name]
+	subscriberFactories.RUnlock()
 	if !ok {
 		return FixedSubscriberFactory(cfg)
 	}
 	return sf(cfg)
 }
 
-var subscriberFactories = map[string]SubscriberFactory{}
+var subscriberFactories = struct {
+	sync.RWMutex
+	data map[string]SubscriberFactory
+}{data: map[string]SubscriberFactory{}}
@@ -1,6 +1,7 @@
 package sd
 
 import (
+	"sort"
 	"testing"
 
 	"github.com/devopsfaith/krakend/config"
@@ -31,4 +32,44 @@ func TestRegisterSubscriberFactory(t *testing.T) {
 	if h, err := GetSubscriber(&config.Backend{Host: []string{"name"}}).Hosts(); err != nil || len(h) != 1 {
 		t.Error("error using the default sd")
 	}
+
+	if h, err := GetSubscriber(&config.Backend{Host: []string{"name2:///service"}}).Hosts(); err != nil || len(h) != 2 {
+		t.Error("error using the scheme of the host as sd name")
+	}
+}
+
+func TestRegisterSubscriberFactory_invalid(t *testing.T) {
+	sf := func(*config.Backend) Subscriber { return FixedSubscriber{} }
+	if err := RegisterSubscriberFactory("", sf); err != ErrNoSubscriberName {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := RegisterSubscriberFactory("invalid", nil); err != ErrNilSubscriberFactory {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, name := range SubscriberFactories() {
+		if name == "" || name == "invalid" {
+			t.Errorf("the invalid factory %q was registered", name)
+		}
+	}
+}
+
+func TestSubscriberFactories(t *testing.T) {
+	sf := func(*config.Backend) Subscriber { return FixedSubscriber{} }
+	RegisterSubscriberFactory("supu", sf)
+	RegisterSubscriberFactory("custom", sf)
+	RegisterSubscriberFactory("custom", sf)
+
+	names := SubscriberFactories()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("unsorted names: %v", names)
+	}
+	found := 0
+	for _, name := range names {
+		if name == "supu" || name == "custom" {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("unexpected names: %v", names)
+	}
 }
@@ -0,0 +1,186 @@
+// Package opentelemetry registers the OpenTelemetry tracer provider and propagator of the gateway, exporting
+// the spans of the router and the proxy layers with the OTLP exporters declared at the service config
+package opentelemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+// Namespace is the key to look for the OpenTelemetry options in the extra config of the service
+const Namespace = "github.com/devopsfaith/krakend/telemetry/opentelemetry"
+
+// DefaultServiceName is the name of the service reported when it is not configured
+const DefaultServiceName = "krakend"
+
+// Protocols of the OTLP exporters
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+// Config defines the tracing of the service
+type Config struct {
+	// ServiceName is the service.name of the resource of the spans. By default, the DefaultServiceName
+	ServiceName string `json:"service_name"`
+	// SampleRate is the ratio of the traces started by the gateway that are sampled. The traces started by the
+	// clients follow their sampling decision. By default, all of them are sampled
+	SampleRate *float64 `json:"sample_rate"`
+	// Attributes are added to the resource of the spans
+	Attributes map[string]string `json:"attributes"`
+	// Exporters receive the spans
+	Exporters []ExporterConfig `json:"exporters"`
+}
+
+// ExporterConfig defines an OTLP exporter
+type ExporterConfig struct {
+	// Protocol is ProtocolGRPC or ProtocolHTTP. By default, ProtocolGRPC
+	Protocol string `json:"protocol"`
+	// Endpoint is the host and the port of the collector
+	Endpoint string `json:"endpoint"`
+	// URLPath is the path receiving the spans with the ProtocolHTTP. By default, /v1/traces
+	URLPath string `json:"url_path"`
+	// Insecure disables the TLS of the connections
+	Insecure bool `json:"insecure"`
+	// Headers are sent with every export
+	Headers map[string]string `json:"headers"`
+	// Timeout of every export, as a duration string. By default, 10s
+	Timeout string `json:"timeout"`
+}
+
+// ErrNoExporters is the error returned when the tracing does not declare any exporter
+var ErrNoExporters = errors.New("opentelemetry: no exporters declared")
+
+// ConfigGetter parses the OpenTelemetry options from the extra config of the service. The second value is
+// false if they are not declared
+func ConfigGetter(extra config.ExtraConfig) (Config, bool, error) {
+	cfg := Config{}
+	v, ok := extra[Namespace]
+	if !ok {
+		return cfg, false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, false, err
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = DefaultServiceName
+	}
+	return cfg, true, nil
+}
+
+// Register registers the tracer provider built with the options declared at the extra config of the service as
+// the global one, along with the W3C trace context and baggage propagator. The returned function flushes the
+// pending spans and stops the exporters. If the tracing is not declared, nothing is registered
+func Register(ctx context.Context, cfg config.ServiceConfig) (func(context.Context) error, error) {
+	tc, ok, err := ConfigGetter(cfg.ExtraConfig)
+	if err != nil || !ok {
+		return func(context.Context) error { return nil }, err
+	}
+	tp, err := NewTracerProvider(ctx, tc)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
+
+// NewTracerProvider returns a tracer provider batching the spans into the exporters of the config
+func NewTracerProvider(ctx context.Context, cfg Config) (*sdktrace.TracerProvider, error) {
+	if len(cfg.Exporters) == 0 {
+		return nil, ErrNoExporters
+	}
+	rate := 1.0
+	if cfg.SampleRate != nil {
+		rate = *cfg.SampleRate
+	}
+	if rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("opentelemetry: invalid sample rate %v", rate)
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	attrs := []attribute.KeyValue{attribute.String("service.name", serviceName)}
+	for k, v := range cfg.Attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(attrs...)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(rate))),
+	}
+	for _, ec := range cfg.Exporters {
+		exporter, err := NewExporter(ctx, ec)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// NewExporter returns the OTLP exporter of the config
+func NewExporter(ctx context.Context, cfg ExporterConfig) (*otlptrace.Exporter, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("opentelemetry: the exporter has no endpoint")
+	}
+	timeout := 10 * time.Second
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		timeout = d
+	}
+
+	switch strings.ToLower(cfg.Protocol) {
+	case "", ProtocolGRPC:
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(cfg.Endpoint),
+			otlptracegrpc.WithTimeout(timeout),
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(cfg.Headers))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case ProtocolHTTP:
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(cfg.Endpoint),
+			otlptracehttp.WithTimeout(timeout),
+		}
+		if cfg.URLPath != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(cfg.URLPath))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	return nil, fmt.Errorf("opentelemetry: unknown exporter protocol %s", cfg.Protocol)
+}
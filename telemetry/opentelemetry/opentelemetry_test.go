@@ -0,0 +1,78 @@
+package opentelemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestRegister_http(t *testing.T) {
+	received := make(chan *http.Request, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer s.Close()
+
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	shutdown, err := Register(context.Background(), config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		Namespace: map[string]interface{}{
+			"service_name": "gateway",
+			"exporters": []interface{}{map[string]interface{}{
+				"protocol": "http",
+				"endpoint": strings.TrimPrefix(s.URL, "http://"),
+				"insecure": true,
+				"headers":  map[string]interface{}{"X-Api-Key": "secret"},
+			}},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "test")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-received:
+		if r.URL.Path != "/v1/traces" || r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("unexpected export: %s %v", r.URL.Path, r.Header)
+		}
+	default:
+		t.Error("the spans were not exported")
+	}
+}
+
+func TestRegister_notDeclared(t *testing.T) {
+	shutdown, err := Register(context.Background(), config.ServiceConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewTracerProvider_ko(t *testing.T) {
+	rate := 2.0
+	for _, cfg := range []Config{
+		{},
+		{SampleRate: &rate, Exporters: []ExporterConfig{{Endpoint: "localhost:4317"}}},
+		{Exporters: []ExporterConfig{{}}},
+		{Exporters: []ExporterConfig{{Endpoint: "localhost:4317", Protocol: "udp"}}},
+		{Exporters: []ExporterConfig{{Endpoint: "localhost:4317", Timeout: "soon"}}},
+	} {
+		if _, err := NewTracerProvider(context.Background(), cfg); err == nil {
+			t.Errorf("%+v: error expected", cfg)
+		}
+	}
+}